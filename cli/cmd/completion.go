@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/pritunl/pritunl-client-electron/cli/completion"
+	"github.com/spf13/cobra"
+)
+
+func completeProfiles(action string) func(*cobra.Command, []string,
+	string) ([]string, cobra.ShellCompDirective) {
+
+	return func(cmd *cobra.Command, args []string, toComplete string) (
+		[]string, cobra.ShellCompDirective) {
+
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		cmpls, err := completion.GetAll()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		ids := []string{}
+		for _, cmpl := range cmpls {
+			if !cmpl.HasAction(action) {
+				continue
+			}
+
+			if cmpl.Name != "" {
+				ids = append(ids, cmpl.Id+"\t"+cmpl.Name)
+			} else {
+				ids = append(ids, cmpl.Id)
+			}
+		}
+
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func init() {
+	StartCmd.ValidArgsFunction = completeProfiles("start")
+	StopCmd.ValidArgsFunction = completeProfiles("stop")
+	EnableCmd.ValidArgsFunction = completeProfiles("enable")
+	DisableCmd.ValidArgsFunction = completeProfiles("disable")
+	RemoveCmd.ValidArgsFunction = completeProfiles("remove")
+	LogsCmd.ValidArgsFunction = completeProfiles("logs")
+}
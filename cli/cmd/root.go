@@ -34,4 +34,5 @@ func init() {
 	RootCmd.AddCommand(StartCmd)
 	RootCmd.AddCommand(StopCmd)
 	RootCmd.AddCommand(WatchCmd)
+	RootCmd.AddCommand(SupportCmd)
 }
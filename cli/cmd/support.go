@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/cli/support"
+	"github.com/spf13/cobra"
+)
+
+var SupportCmd = &cobra.Command{
+	Use:   "support",
+	Short: "Manage support log streaming session",
+}
+
+var SupportStartCmd = &cobra.Command{
+	Use:   "start [url]",
+	Short: "Stream service logs to a support endpoint",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cobra.CheckErr("cmd: Missing support url")
+		}
+
+		if supportToken == "" {
+			cobra.CheckErr("cmd: Missing support token")
+		}
+
+		sess, err := support.Start(args[0], supportToken, supportTtl)
+		cobra.CheckErr(err)
+
+		fmt.Printf("Streaming logs to %s until %s\n", sess.Url,
+			sess.Expires.Local().Format(time.RFC1123))
+	},
+}
+
+var SupportStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop support log streaming",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := support.Stop()
+		cobra.CheckErr(err)
+	},
+}
+
+var SupportStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show support log streaming session",
+	Run: func(cmd *cobra.Command, args []string) {
+		sess, err := support.Get()
+		cobra.CheckErr(err)
+
+		if sess == nil {
+			fmt.Println("No active support session")
+			return
+		}
+
+		fmt.Printf("Streaming logs to %s until %s, sent %d dropped %d\n",
+			sess.Url, sess.Expires.Local().Format(time.RFC1123),
+			sess.Sent, sess.Dropped)
+	},
+}
+
+func init() {
+	SupportCmd.AddCommand(SupportStartCmd)
+	SupportCmd.AddCommand(SupportStopCmd)
+	SupportCmd.AddCommand(SupportStatusCmd)
+}
@@ -1,11 +1,17 @@
 package cmd
 
+import (
+	"time"
+)
+
 var (
 	mode           string
 	password       string
 	passwordPrompt bool
 	jsonFormat     bool
 	jsonFormated   bool
+	supportToken   string
+	supportTtl     time.Duration
 )
 
 func init() {
@@ -46,4 +52,19 @@ func init() {
 		false,
 		"Format output in indented JSON",
 	)
+
+	SupportStartCmd.Flags().StringVarP(
+		&supportToken,
+		"token",
+		"t",
+		"",
+		"Support session token provided by support",
+	)
+	SupportStartCmd.Flags().DurationVarP(
+		&supportTtl,
+		"duration",
+		"d",
+		time.Hour,
+		"Support session duration, at most 4h",
+	)
 }
@@ -0,0 +1,77 @@
+package completion
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/service"
+)
+
+type Completion struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+func (c *Completion) HasAction(action string) bool {
+	for _, act := range c.Actions {
+		if act == action {
+			return true
+		}
+	}
+	return false
+}
+
+func GetAll() (cmpls []*Completion, err error) {
+	reqUrl := service.GetAddress() + "/completion"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", reqUrl, nil)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "completion: Get request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "completion: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("completion: Unknown request error %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	cmpls = []*Completion{}
+	err = json.NewDecoder(resp.Body).Decode(&cmpls)
+	if err != nil {
+		err = errortypes.ParseError{
+			errors.Wrap(err, "completion: Failed to parse response"),
+		}
+		return
+	}
+
+	return
+}
@@ -28,6 +28,7 @@ type Profile struct {
 	GatewayAddr6 string   `json:"gateway_addr6"`
 	ServerAddr   string   `json:"server_addr"`
 	ClientAddr   string   `json:"client_addr"`
+	Cipher       string   `json:"cipher"`
 	MacAddr      string   `json:"mac_addr"`
 	MacAddrs     []string `json:"mac_addrs"`
 }
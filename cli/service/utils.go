@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"runtime"
 	"strings"
 	"time"
@@ -14,15 +16,91 @@ import (
 	"github.com/pritunl/pritunl-client-electron/cli/utils"
 )
 
+const (
+	pipePath      = `\\.\pipe\pritunl`
+	socketPath    = "/var/run/pritunl.sock"
+	address       = "127.0.0.1:9770"
+	socketPathEnv = "PRITUNL_API_SOCKET"
+	addressEnv    = "PRITUNL_API_ADDRESS"
+)
+
+// Services configured with a different api socket or port are reached
+// with the environment overrides
+func getSocketPath() string {
+	pth := os.Getenv(socketPathEnv)
+	if pth != "" {
+		return pth
+	}
+	return socketPath
+}
+
+func getAddress() string {
+	addr := os.Getenv(addressEnv)
+	if addr != "" {
+		return addr
+	}
+	return address
+}
+
+// Named pipe client connection, the pipe does not support deadlines
+type pipeConn struct {
+	*os.File
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return pipeAddr{}
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return pipeAddr{}
+}
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type pipeAddr struct{}
+
+func (a pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return pipePath
+}
+
+// Connect to the service named pipe, services without the pipe or with
+// the pipe busy are reached on the tcp port
 var httpClient = &http.Client{
 	Timeout: 1 * time.Minute,
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (
+			net.Conn, error) {
+
+			file, err := os.OpenFile(pipePath, os.O_RDWR, 0)
+			if err == nil {
+				return &pipeConn{file}, nil
+			}
+
+			dialer := &net.Dialer{}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	},
 }
 
 var unixClient = &http.Client{
 	Timeout: 1 * time.Minute,
 	Transport: &http.Transport{
 		DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-			return net.Dial("unix", "/var/run/pritunl.sock")
+			return net.Dial("unix", getSocketPath())
 		},
 	},
 }
@@ -31,7 +109,7 @@ func GetAddress() string {
 	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
 		return "http://unix"
 	} else {
-		return "http://127.0.0.1:9770"
+		return "http://" + getAddress()
 	}
 }
 
@@ -58,3 +136,30 @@ func GetClient() *http.Client {
 		return httpClient
 	}
 }
+
+// Error response body of the service, the message is an English fallback
+// for the error code
+type ErrorData struct {
+	Error   string                 `json:"error"`
+	Params  map[string]interface{} `json:"params"`
+	Message string                 `json:"message"`
+}
+
+func GetError(resp *http.Response) (data *ErrorData) {
+	errData := &ErrorData{}
+	err := json.NewDecoder(resp.Body).Decode(errData)
+	if err != nil || errData.Error == "" {
+		return
+	}
+
+	data = errData
+	return
+}
+
+func GetErrorMessage(resp *http.Response, fallback string) string {
+	data := GetError(resp)
+	if data == nil || data.Message == "" {
+		return fallback
+	}
+	return data.Message
+}
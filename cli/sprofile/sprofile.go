@@ -1,6 +1,7 @@
 package sprofile
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,38 +15,73 @@ import (
 )
 
 type Sprofile struct {
-	Id                 string           `json:"id"`
-	Name               string           `json:"name"`
-	State              bool             `json:"state"`
-	Wg                 bool             `json:"wg"`
-	LastMode           string           `json:"last_mode"`
-	OrganizationId     string           `json:"organization_id"`
-	Organization       string           `json:"organization"`
-	ServerId           string           `json:"server_id"`
-	Server             string           `json:"server"`
-	UserId             string           `json:"user_id"`
-	User               string           `json:"user"`
-	PreConnectMsg      string           `json:"pre_connect_msg"`
-	DynamicFirewall    bool             `json:"dynamic_firewall"`
-	DeviceAuth         bool             `json:"device_auth"`
-	DisableGateway     bool             `json:"disable_gateway"`
-	DisableDns         bool             `json:"disable_dns"`
-	ForceDns           bool             `json:"force_dns"`
-	SsoAuth            bool             `json:"sso_auth"`
-	PasswordMode       string           `json:"password_mode"`
-	Token              bool             `json:"token"`
-	TokenTtl           int              `json:"token_ttl"`
-	Disabled           bool             `json:"disabled"`
-	SyncHosts          []string         `json:"sync_hosts"`
-	SyncHash           string           `json:"sync_hash"`
-	SyncSecret         string           `json:"sync_secret"`
-	SyncToken          string           `json:"sync_token"`
-	ServerPublicKey    []string         `json:"server_public_key"`
-	ServerBoxPublicKey string           `json:"server_box_public_key"`
-	RegistrationKey    string           `json:"registration_key"`
-	OvpnData           string           `json:"ovpn_data"`
-	Password           string           `json:"password"`
-	Profile            *profile.Profile `json:"-"`
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	State              bool              `json:"state"`
+	Wg                 bool              `json:"wg"`
+	LastMode           string            `json:"last_mode"`
+	OrganizationId     string            `json:"organization_id"`
+	Organization       string            `json:"organization"`
+	ServerId           string            `json:"server_id"`
+	Server             string            `json:"server"`
+	UserId             string            `json:"user_id"`
+	User               string            `json:"user"`
+	PreConnectMsg      string            `json:"pre_connect_msg"`
+	DynamicFirewall    bool              `json:"dynamic_firewall"`
+	DeviceAuth         bool              `json:"device_auth"`
+	DisableGateway     bool              `json:"disable_gateway"`
+	DisableDns         bool              `json:"disable_dns"`
+	ForceDns           bool              `json:"force_dns"`
+	SplitDnsDomains    []string          `json:"split_dns_domains"`
+	Env                map[string]string `json:"env"`
+	Hooks              map[string]string `json:"hooks"`
+	CredentialProvider string            `json:"credential_provider"`
+	CredentialCacheTtl int               `json:"credential_cache_ttl"`
+	RoutePriority      int               `json:"route_priority"`
+	IncludeRoutes      []string          `json:"include_routes"`
+	ExcludeRoutes      []string          `json:"exclude_routes"`
+	PersistTun         bool              `json:"persist_tun"`
+	HttpProxy          string            `json:"http_proxy"`
+	SocksProxy         string            `json:"socks_proxy"`
+	AlwaysOn           bool              `json:"always_on"`
+	KillSwitch         bool              `json:"kill_switch"`
+	CredentialId       string            `json:"credential_id"`
+	HostFailover       bool              `json:"host_failover"`
+	Verbosity          int               `json:"verbosity"`
+	SplitDnsPushed     bool              `json:"split_dns_pushed"`
+	DnsHijackMode      string            `json:"dns_hijack_mode"`
+	DisableIpv6        bool              `json:"disable_ipv6"`
+	SyslogTag          string            `json:"syslog_tag"`
+	WgTcpFallback      bool              `json:"wg_tcp_fallback"`
+	Mtu                int               `json:"mtu"`
+	MssFix             int               `json:"mss_fix"`
+	MtuProbe           bool              `json:"mtu_probe"`
+	SummarizeRoutes    bool              `json:"summarize_routes"`
+	ExpiresAt          int64             `json:"expires_at"`
+	ExpireDelete       bool              `json:"expire_delete"`
+	OnDemandSubnets    []string          `json:"on_demand_subnets"`
+	OnDemandIdle       int               `json:"on_demand_idle"`
+	RestartTriggers    []string          `json:"restart_triggers"`
+	StartDelay         int               `json:"start_delay"`
+	StartJitter        int               `json:"start_jitter"`
+	Features           json.RawMessage   `json:"features"`
+	Managed            bool              `json:"managed"`
+	ReadOnly           bool              `json:"read_only"`
+	SsoAuth            bool              `json:"sso_auth"`
+	PasswordMode       string            `json:"password_mode"`
+	Token              bool              `json:"token"`
+	TokenTtl           int               `json:"token_ttl"`
+	Disabled           bool              `json:"disabled"`
+	SyncHosts          []string          `json:"sync_hosts"`
+	SyncHash           string            `json:"sync_hash"`
+	SyncSecret         string            `json:"sync_secret"`
+	SyncToken          string            `json:"sync_token"`
+	ServerPublicKey    []string          `json:"server_public_key"`
+	ServerBoxPublicKey string            `json:"server_box_public_key"`
+	RegistrationKey    string            `json:"registration_key"`
+	OvpnData           string            `json:"ovpn_data"`
+	Password           string            `json:"password"`
+	Profile            *profile.Profile  `json:"-"`
 }
 
 func (s *Sprofile) FormatedName() (name string) {
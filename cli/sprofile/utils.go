@@ -148,8 +148,9 @@ func Stop(sprflId string) (err error) {
 
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
-			errors.Wrapf(err, "sprofile: Unknown request error %d",
-				resp.StatusCode),
+			errors.Wrapf(err, "sprofile: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
 		}
 		return
 	}
@@ -206,10 +207,19 @@ func Delete(sprflId string) (err error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 403 {
+		err = errortypes.RequestError{
+			errors.New("sprofile: " + service.GetErrorMessage(
+				resp, "System profile is read-only")),
+		}
+		return
+	}
+
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
-			errors.Wrapf(err, "sprofile: Unknown request error %d",
-				resp.StatusCode),
+			errors.Wrapf(err, "sprofile: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
 		}
 		return
 	}
@@ -347,8 +357,9 @@ func Start(sprflId, mode, password string) (err error) {
 
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
-			errors.Wrapf(err, "sprofile: Unknown request error %d",
-				resp.StatusCode),
+			errors.Wrapf(err, "sprofile: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
 		}
 		return
 	}
@@ -405,10 +416,19 @@ func SetState(sprflId string, state bool) (err error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 403 {
+		err = errortypes.RequestError{
+			errors.New("sprofile: " + service.GetErrorMessage(
+				resp, "System profile is read-only")),
+		}
+		return
+	}
+
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
-			errors.Wrapf(err, "sprofile: Unknown request error %d",
-				resp.StatusCode),
+			errors.Wrapf(err, "sprofile: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
 		}
 		return
 	}
@@ -508,8 +528,9 @@ func Import(data string) (err error) {
 
 	if resp.StatusCode != 200 {
 		err = errortypes.RequestError{
-			errors.Wrapf(err, "sprofile: Unknown request error %d",
-				resp.StatusCode),
+			errors.Wrapf(err, "sprofile: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
 		}
 		return
 	}
@@ -0,0 +1,126 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/cli/errortypes"
+	"github.com/pritunl/pritunl-client-electron/cli/service"
+)
+
+type Session struct {
+	Id      string    `json:"id"`
+	Url     string    `json:"url"`
+	Start   time.Time `json:"start"`
+	Expires time.Time `json:"expires"`
+	Sent    int       `json:"sent"`
+	Dropped int       `json:"dropped"`
+}
+
+type sessionData struct {
+	Url      string `json:"url"`
+	Token    string `json:"token"`
+	Duration int    `json:"duration"`
+}
+
+func request(method string, body io.Reader, sess **Session) (err error) {
+	reqUrl := service.GetAddress() + "/support_session"
+
+	authKey, err := service.GetAuthKey()
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(method, reqUrl, body)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "support: Request failed"),
+		}
+		return
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		req.Host = "unix"
+	}
+	req.Header.Set("Auth-Key", authKey)
+	req.Header.Set("User-Agent", "pritunl")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := service.GetClient().Do(req)
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "support: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = errortypes.RequestError{
+			errors.Newf("support: Request error %d: %s",
+				resp.StatusCode, service.GetErrorMessage(
+					resp, "Unknown request error")),
+		}
+		return
+	}
+
+	if sess != nil {
+		err = json.NewDecoder(resp.Body).Decode(sess)
+		if err != nil {
+			err = errortypes.ParseError{
+				errors.Wrap(err, "support: Failed to parse response"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func Start(url, token string, duration time.Duration) (
+	sess *Session, err error) {
+
+	data, err := json.Marshal(&sessionData{
+		Url:      url,
+		Token:    token,
+		Duration: int(duration.Seconds()),
+	})
+	if err != nil {
+		err = errortypes.RequestError{
+			errors.Wrap(err, "support: Json marshal error"),
+		}
+		return
+	}
+
+	err = request("POST", bytes.NewBuffer(data), &sess)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Stop() (err error) {
+	err = request("DELETE", nil, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Get() (sess *Session, err error) {
+	err = request("GET", nil, &sess)
+	if err != nil {
+		return
+	}
+
+	return
+}
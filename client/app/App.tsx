@@ -154,13 +154,15 @@ Config.load().then((): void => {
 		Theme.dark();
 	}
 
-	Constants.load();
-	Auth.load().then((): void => {
-		Event.init();
-
-		ReactDOM.render(
-			<div><Main/></div>,
-			document.getElementById("app"),
-		);
+	Constants.detectPipe().then((): void => {
+		Constants.load();
+		Auth.load().then((): void => {
+			Event.init();
+
+			ReactDOM.render(
+				<div><Main/></div>,
+				document.getElementById("app"),
+			);
+		});
 	});
 });
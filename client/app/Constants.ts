@@ -7,14 +7,16 @@ import * as Auth from "./Auth";
 import path from "path";
 import process from "process";
 import os from "os";
+import net from "net";
 
 export const loadDelay = 700;
 export let unix = false;
-export const unixPath = "/var/run/pritunl.sock";
+export let unixPath = "/var/run/pritunl.sock";
 export const webHost = 'http://127.0.0.1:9770';
-export const unixWsHost = 'ws+unix://' + path.join(
+export let unixWsHost = 'ws+unix://' + path.join(
 	path.sep, 'var', 'run', 'pritunl.sock') + ':';
 export const webWsHost = 'ws://127.0.0.1:9770';
+export const pipePath = '\\\\.\\pipe\\pritunl';
 export const platform = os.platform()
 export const hostname = os.hostname()
 
@@ -62,6 +64,8 @@ if (args.get("frameless") === "true") {
 }
 
 export const dataPath = args.get('dataPath');
+export const version = args.get('version') || '';
+export const apiVersion = 1;
 
 export let state: State = {}
 
@@ -98,6 +102,36 @@ function _load(): void {
 	setInterval(syncState, 5000)
 }
 
+// The service named pipe is only accessible to the console user, other
+// sessions and services without the pipe use the tcp port. Access is
+// granted by the service shortly after the user logs on.
+export function detectPipe(attempts = 5): Promise<void> {
+	return new Promise<void>((resolve): void => {
+		if (process.platform !== 'win32') {
+			resolve();
+			return;
+		}
+
+		let conn = net.connect(pipePath);
+		conn.on('connect', (): void => {
+			conn.destroy();
+			unix = true;
+			unixPath = pipePath;
+			unixWsHost = 'ws+unix:' + pipePath + ':';
+			resolve();
+		});
+		conn.on('error', (err: NodeJS.ErrnoException): void => {
+			if (err.code === 'EACCES' && attempts > 1) {
+				setTimeout((): void => {
+					detectPipe(attempts - 1).then(resolve);
+				}, 1000);
+				return;
+			}
+			resolve();
+		});
+	});
+}
+
 let started = false
 export function load(): void {
 	if (started) {
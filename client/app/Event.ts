@@ -23,6 +23,8 @@ function connect(): void {
 	let headers = {
 		'User-Agent': 'pritunl',
 		'Auth-Token': Auth.token,
+		'Client-Version': Constants.version,
+		'Client-Api-Version': Constants.apiVersion.toString(),
 	} as any;
 
 	if (Constants.unix) {
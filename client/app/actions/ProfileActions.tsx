@@ -475,5 +475,12 @@ EventDispatcher.register((action: ProfileTypes.ProfileDispatch) => {
 				}
 			}
 			break
+		case "ui_incompatible":
+			let data = action.data as any
+			Alert.warning("Client version " + (data && data.ui_version) +
+				" is not compatible with service version " +
+				(data && data.service_version) +
+				", reinstall the client to update both")
+			break
 	}
 });
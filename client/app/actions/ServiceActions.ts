@@ -145,46 +145,95 @@ export async function tokenDelete(prfl: ProfileTypes.Profile,
 	}
 }
 
-export function resetDns(noLoading?: boolean): Promise<void> {
-	let loader: Loader
-	if (!noLoading) {
-		loader = new Loader().loading()
+export async function otpSeedGet(
+	prfl: ProfileTypes.Profile): Promise<boolean> {
+
+	let hasOtpSeed = false
+
+	try {
+		let resp = await RequestUtils
+			.get('/otp_seed/' + prfl.id)
+			.set('Accept', 'application/json')
+			.end()
+		if (resp.status === 200) {
+			let data = resp.jsonPassive()
+			if (data) {
+				hasOtpSeed = !!data.has_otp_seed
+			}
+		}
+	} catch (err) {
+		err = new Errors.RequestError(
+			err, "Profiles: OTP seed request failed")
+		Logger.error(err)
 	}
 
-	return new Promise<void>((resolve): void => {
+	return hasOtpSeed
+}
+
+function resetConfirm(path: string): Promise<string> {
+	return new Promise<string>((resolve, reject): void => {
 		RequestUtils
-			.post("/network/reset_dns")
+			.post(path + "?dry_run=true")
 			.set("Accept", "application/json")
 			.end()
 			.then((resp: Request.Response) => {
-				if (loader) {
-					loader.done()
-				}
-
 				if (resp.status !== 200) {
-					let err = new Errors.RequestError(null,
-						"System: DNS reset failed", {
+					reject(new Errors.RequestError(null,
+						"System: Reset confirmation failed", {
 							status: resp.status.toString()
-						})
-					Logger.errorAlert(err)
+						}))
 					return
 				}
 
-				Alert.success("System: DNS reset successful")
-
-				resolve()
+				let data = resp.jsonPassive() || {}
+				resolve(data.confirm_token || "")
 			}, (err) => {
-				if (loader) {
-					loader.done()
-				}
+				reject(err)
+			})
+	})
+}
 
-				err = new Errors.RequestError(err,
-					"System: DNS reset failed")
-				Logger.errorAlert(err)
+export function resetDns(noLoading?: boolean): Promise<void> {
+	let loader: Loader
+	if (!noLoading) {
+		loader = new Loader().loading()
+	}
 
-				resolve()
+	return new Promise<void>((resolve): void => {
+		resetConfirm("/network/reset_dns").then((token: string) => {
+			return RequestUtils
+				.post("/network/reset_dns?confirm=" + encodeURIComponent(token))
+				.set("Accept", "application/json")
+				.end()
+		}).then((resp: Request.Response) => {
+			if (loader) {
+				loader.done()
+			}
+
+			if (resp.status !== 200) {
+				let err = new Errors.RequestError(null,
+					"System: DNS reset failed", {
+						status: resp.status.toString()
+					})
+				Logger.errorAlert(err)
 				return
-			})
+			}
+
+			Alert.success("System: DNS reset successful")
+
+			resolve()
+		}, (err) => {
+			if (loader) {
+				loader.done()
+			}
+
+			err = new Errors.RequestError(err,
+				"System: DNS reset failed")
+			Logger.errorAlert(err)
+
+			resolve()
+			return
+		})
 	})
 }
 
@@ -195,38 +244,39 @@ export function resetAll(noLoading?: boolean): Promise<void> {
 	}
 
 	return new Promise<void>((resolve): void => {
-		RequestUtils
-			.post("/network/reset_all")
-			.set("Accept", "application/json")
-			.end()
-			.then((resp: Request.Response) => {
-				if (loader) {
-					loader.done()
-				}
+		resetConfirm("/network/reset_all").then((token: string) => {
+			return RequestUtils
+				.post("/network/reset_all?confirm=" + encodeURIComponent(token))
+				.set("Accept", "application/json")
+				.end()
+		}).then((resp: Request.Response) => {
+			if (loader) {
+				loader.done()
+			}
 
-				if (resp.status !== 200) {
-					let err = new Errors.RequestError(null,
-						"System: Network reset failed", {
-							status: resp.status.toString()
-						})
-					Logger.errorAlert(err)
-					return
-				}
+			if (resp.status !== 200) {
+				let err = new Errors.RequestError(null,
+					"System: Network reset failed", {
+						status: resp.status.toString()
+					})
+				Logger.errorAlert(err)
+				return
+			}
 
-				Alert.success("System: Network reset successful")
+			Alert.success("System: Network reset successful")
 
-				resolve()
-			}, (err) => {
-				if (loader) {
-					loader.done()
-				}
+			resolve()
+		}, (err) => {
+			if (loader) {
+				loader.done()
+			}
 
-				err = new Errors.RequestError(err,
-					"System: Network reset failed")
-				Logger.errorAlert(err)
+			err = new Errors.RequestError(err,
+				"System: Network reset failed")
+			Logger.errorAlert(err)
 
-				resolve()
-				return
-			})
+			resolve()
+			return
+		})
 	})
 }
@@ -141,16 +141,72 @@ export default class ConfigView extends React.Component<{}, State> {
 			<div className="layout horizontal">
 				<PageSwitch
 					disabled={this.state.disabled}
-					label="Disable network clean"
-					help="Disable Windows VPN interface cleanup on startup."
-					checked={!!this.state.config.disable_net_clean}
+					label="Disable adapter clean"
+					help="Disable removal of leftover Windows VPN interfaces on startup."
+					checked={!!this.state.config.disable_clean_adapters}
 					onToggle={(): void => {
-						this.set("disable_net_clean",
-							!this.state.config.disable_net_clean)
+						this.set("disable_clean_adapters",
+							!this.state.config.disable_clean_adapters)
 					}}
 				/>
 			</div>
 			<div className="layout horizontal">
+				<PageSwitch
+					disabled={this.state.disabled}
+					label="Disable route clean"
+					help="Disable removal of leftover VPN routes on startup."
+					checked={!!this.state.config.disable_clean_routes}
+					onToggle={(): void => {
+						this.set("disable_clean_routes",
+							!this.state.config.disable_clean_routes)
+					}}
+				/>
+			</div>
+			<div className="layout horizontal">
+				<PageSwitch
+					disabled={this.state.disabled}
+					label="Disable DNS clean"
+					help="Disable removal of leftover VPN DNS configuration on startup."
+					checked={!!this.state.config.disable_clean_dns}
+					onToggle={(): void => {
+						this.set("disable_clean_dns",
+							!this.state.config.disable_clean_dns)
+					}}
+				/>
+			</div>
+			<div className="layout horizontal">
+				<PageSwitch
+					disabled={this.state.disabled}
+					label="Disable firewall clean"
+					help="Disable removal of leftover kill switch firewall rules on startup."
+					checked={!!this.state.config.disable_clean_firewall}
+					onToggle={(): void => {
+						this.set("disable_clean_firewall",
+							!this.state.config.disable_clean_firewall)
+					}}
+				/>
+				<PageSwitch
+					disabled={this.state.disabled}
+					label="Enable automatic updates"
+					help="Automatically install signed service and command line updates from the configured update channel. Requires an update url and public key in the service configuration."
+					checked={!!this.state.config.auto_update}
+					onToggle={(): void => {
+						this.set("auto_update",
+							!this.state.config.auto_update)
+					}}
+				/>
+			</div>
+			<div className="layout horizontal">
+				<PageSwitch
+					disabled={this.state.disabled}
+					label="Disable IPv6 clean"
+					help="Disable restoring IPv6 on physical interfaces left disabled by a previous service instance on startup."
+					checked={!!this.state.config.disable_clean_ipv6}
+					onToggle={(): void => {
+						this.set("disable_clean_ipv6",
+							!this.state.config.disable_clean_ipv6)
+					}}
+				/>
 				<PageSwitch
 					disabled={this.state.disabled}
 					label="Enable safe storage"
@@ -141,6 +141,12 @@ export default class ProfileConnect extends React.Component<Props, State> {
 			}
 		}
 
+		// Service appends the code from the stored OTP seed
+		if (authTypes.indexOf("otp") !== -1 &&
+				await ServiceActions.otpSeedGet(prfl)) {
+			authTypes.splice(authTypes.indexOf("otp"), 1)
+		}
+
 		let autoFocus = ""
 		let hasUsername = false
 		let hasPassword = false
@@ -262,6 +268,7 @@ export default class ProfileConnect extends React.Component<Props, State> {
 			device_auth: prfl.device_auth,
 			disable_gateway: prfl.disable_gateway,
 			force_dns: prfl.force_dns,
+			disable_ipv6: prfl.disable_ipv6,
 			sso_auth: prfl.sso_auth,
 			server_public_key: serverPubKey,
 			server_box_public_key: prfl.server_box_public_key,
@@ -305,6 +305,14 @@ export default class ProfileSettings extends React.Component<Props, State> {
 							this.set("force_dns", !profile.force_dns)
 						}}
 					/>
+					<PageSwitch
+						label="Disable IPv6"
+						help="Disable IPv6 on other network interfaces while connected to prevent IPv6 traffic from bypassing a VPN connection without IPv6."
+						checked={!!profile.disable_ipv6}
+						onToggle={(): void => {
+							this.set("disable_ipv6", !profile.disable_ipv6)
+						}}
+					/>
 					<PageInfo
 						fields={[
 							{
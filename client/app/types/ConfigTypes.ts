@@ -5,8 +5,14 @@ export const CHANGE = 'config.change';
 export interface Config {
 	disable_dns_watch?: boolean
 	disable_wake_watch?: boolean
-	disable_net_clean?: boolean
+	disable_clean_adapters?: boolean
+	disable_clean_routes?: boolean
+	disable_clean_dns?: boolean
+	disable_clean_firewall?: boolean
+	disable_clean_ipv6?: boolean
 	interface_metric?: number
+	auto_update?: boolean
+	update_channel?: string
 }
 
 export type ConfigRo = Readonly<Config>;
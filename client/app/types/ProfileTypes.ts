@@ -45,6 +45,7 @@ export interface Profile {
 	device_auth?: boolean
 	disable_gateway?: boolean
 	force_dns?: boolean
+	disable_ipv6?: boolean
 	sso_auth?: boolean
 	password_mode?: string
 	token?: boolean
@@ -56,6 +57,7 @@ export interface Profile {
 	server_public_key?: string[]
 	server_box_public_key?: string
 	registration_key?: string
+	features?: {[key: string]: any}
 	sync_time?: number
 	status?: string
 	timestamp?: number
@@ -134,6 +136,7 @@ export interface ProfileData {
 	device_auth?: boolean
 	disable_gateway?: boolean
 	force_dns?: boolean
+	disable_ipv6?: boolean
 	sso_auth?: boolean
 	server_public_key?: string
 	server_box_public_key?: string
@@ -407,6 +410,7 @@ export function New(self: Profile): Profile {
 			disable_reconnect_local: this.disable_reconnect_local,
 			disable_gateway: this.disable_gateway,
 			force_dns: this.force_dns,
+			disable_ipv6: this.disable_ipv6,
 			sso_auth: this.sso_auth,
 			password_mode: this.password_mode,
 			token: this.token,
@@ -421,6 +425,7 @@ export function New(self: Profile): Profile {
 			server_public_key: this.server_public_key,
 			server_box_public_key: this.server_box_public_key,
 			registration_key: this.registration_key,
+			features: this.features,
 			key_data: this.key_data,
 		})
 	}
@@ -440,6 +445,7 @@ export function New(self: Profile): Profile {
 		this.disable_reconnect_local = data.disable_reconnect_local
 		this.disable_gateway = data.disable_gateway
 		this.force_dns = data.force_dns
+		this.disable_ipv6 = data.disable_ipv6
 		this.sso_auth = data.sso_auth
 		this.password_mode = data.password_mode
 		this.token = data.token
@@ -452,6 +458,7 @@ export function New(self: Profile): Profile {
 		this.sync_token = data.sync_token
 		this.server_public_key = data.server_public_key
 		this.server_box_public_key = data.server_box_public_key
+		this.features = data.features
 		this.key_data = data.key_data
 	}
 
@@ -472,6 +479,7 @@ export function New(self: Profile): Profile {
 			device_auth: this.device_auth,
 			disable_gateway: this.disable_gateway,
 			force_dns: this.force_dns,
+			disable_ipv6: this.disable_ipv6,
 			sso_auth: this.sso_auth,
 			password_mode: this.password_mode,
 			token: this.token,
@@ -486,6 +494,7 @@ export function New(self: Profile): Profile {
 			server_public_key: this.server_public_key,
 			server_box_public_key: this.server_box_public_key,
 			registration_key: this.registration_key,
+			features: this.features,
 			ovpn_data: this.ovpn_data,
 		}
 	}
@@ -513,6 +522,7 @@ export function New(self: Profile): Profile {
 		this.sync_hash = data.sync_hash
 		this.server_public_key = data.server_public_key
 		this.server_box_public_key = data.server_box_public_key
+		this.features = data.features
 	}
 
 	self.convertSystem = async function(): Promise<void> {
@@ -5,13 +5,18 @@ import {winDrive} from "./Service";
 
 export let token = '';
 export let unix = false
-export const unixPath = "/var/run/pritunl.sock"
+export let unixPath = "/var/run/pritunl.sock"
 export const webHost = "http://127.0.0.1:9770"
 
 if (process.platform === "linux" || process.platform === "darwin") {
 	unix = true
 }
 
+export function usePipe(path: string): void {
+	unix = true
+	unixPath = path
+}
+
 function getAuthPath(): string {
 	if (process.argv.indexOf("--dev") !== -1) {
 		return path.join(__dirname, "..", "..", "dev", "auth")
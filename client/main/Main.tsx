@@ -5,6 +5,7 @@ import childprocess from "child_process"
 import electron from "electron"
 import * as Utils from "./Utils";
 import * as Service from "./Service"
+import * as Auth from "./Auth"
 import Config from "./Config"
 import * as Errors from "../app/Errors";
 
@@ -123,11 +124,17 @@ electron.ipcMain.on(
 	},
 )
 
-Service.wakeup().then((awake: boolean) => {
-	awaken = awake
-	if (ready) {
-		init()
+Service.detectPipe().then((pipe: boolean) => {
+	if (pipe) {
+		Auth.usePipe(Service.pipePath)
 	}
+
+	Service.wakeup().then((awake: boolean) => {
+		awaken = awake
+		if (ready) {
+			init()
+		}
+	})
 })
 
 class Main {
@@ -262,6 +269,8 @@ class Main {
 		indexUrl += "&dataPath=" + encodeURIComponent(
 			electron.app.getPath("userData"))
 		indexUrl += "&frameless=" + (framelessClient ? "true" : "false")
+		indexUrl += "&version=" + encodeURIComponent(
+			electron.app.getVersion())
 
 		this.window.loadURL(indexUrl, {
 			userAgent: "pritunl",
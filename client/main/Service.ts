@@ -1,5 +1,6 @@
 import WebSocket from "ws"
 import fs from "fs"
+import net from "net"
 import path from "path"
 import process from "process"
 import * as Request from "./Request"
@@ -16,11 +17,12 @@ export interface Event {
 export type Callback = (event: Event) => void
 
 let unix = false
-const unixPath = "/var/run/pritunl.sock"
+let unixPath = "/var/run/pritunl.sock"
 const webHost = "http://127.0.0.1:9770"
-const unixWsHost = "ws+unix://" + path.join(
+let unixWsHost = "ws+unix://" + path.join(
 	path.sep, "var", "run", "pritunl.sock") + ":"
 const webWsHost = "ws://127.0.0.1:9770"
+export const pipePath = "\\\\.\\pipe\\pritunl"
 
 let showConnect = false
 let socket: WebSocket.WebSocket
@@ -36,6 +38,36 @@ if (process.platform === "linux" || process.platform === "darwin") {
 	unix = true
 }
 
+// The service named pipe is only accessible to the console user, other
+// sessions and services without the pipe use the tcp port. Access is
+// granted by the service shortly after the user logs on.
+export function detectPipe(attempts = 5): Promise<boolean> {
+	return new Promise<boolean>((resolve): void => {
+		if (process.platform !== "win32") {
+			resolve(false)
+			return
+		}
+
+		let conn = net.connect(pipePath)
+		conn.on("connect", (): void => {
+			conn.destroy()
+			unix = true
+			unixPath = pipePath
+			unixWsHost = "ws+unix:" + pipePath + ":"
+			resolve(true)
+		})
+		conn.on("error", (err: NodeJS.ErrnoException): void => {
+			if (err.code === "EACCES" && attempts > 1) {
+				setTimeout((): void => {
+					detectPipe(attempts - 1).then(resolve)
+				}, 1000)
+				return
+			}
+			resolve(false)
+		})
+	})
+}
+
 function getAuthPath(): string {
 	if (process.argv.indexOf("--dev") !== -1) {
 		return path.join(__dirname, "..", "..", "dev", "auth")
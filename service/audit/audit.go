@@ -0,0 +1,338 @@
+// Append only audit log of profile connections, kept separate from the
+// debug logs for export to incident timelines.
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Connect    = "connect"
+	Disconnect = "disconnect"
+
+	defaultRetention = 90
+	pruneInterval    = 24 * time.Hour
+)
+
+var (
+	lock      = sync.Mutex{}
+	lastPrune = time.Time{}
+	csvHeader = []string{
+		"timestamp",
+		"action",
+		"profile_id",
+		"profile_name",
+		"mode",
+		"server_host",
+		"client_addr",
+		"user_id",
+		"username",
+		"reason",
+	}
+)
+
+type Entry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Action      string    `json:"action"`
+	ProfileId   string    `json:"profile_id"`
+	ProfileName string    `json:"profile_name"`
+	Mode        string    `json:"mode"`
+	ServerHost  string    `json:"server_host"`
+	ClientAddr  string    `json:"client_addr"`
+	UserId      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+func (e *Entry) csvRecord() []string {
+	return []string{
+		e.Timestamp.UTC().Format(time.RFC3339),
+		e.Action,
+		e.ProfileId,
+		e.ProfileName,
+		e.Mode,
+		e.ServerHost,
+		e.ClientAddr,
+		e.UserId,
+		e.Username,
+		e.Reason,
+	}
+}
+
+func GetPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "audit.log")
+}
+
+// Retention of audit entries in days
+func GetRetention() int {
+	if config.Config.AuditRetention > 0 {
+		return config.Config.AuditRetention
+	}
+	return defaultRetention
+}
+
+func readEntries(pth string, handler func(ent *Entry)) (err error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "audit: Failed to open audit file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		ent := &Entry{}
+
+		e := json.Unmarshal(scanner.Bytes(), ent)
+		if e != nil {
+			continue
+		}
+
+		handler(ent)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "audit: Failed to read audit file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Remove entries older than the retention, entries are only removed
+// from the start of the file
+func prune(pth string) (err error) {
+	cutoff := time.Now().Add(
+		-time.Duration(GetRetention()) * 24 * time.Hour)
+
+	entries := []*Entry{}
+	expired := 0
+	err = readEntries(pth, func(ent *Entry) {
+		if ent.Timestamp.Before(cutoff) {
+			expired += 1
+			return
+		}
+		entries = append(entries, ent)
+	})
+	if err != nil || expired == 0 {
+		return
+	}
+
+	tmpPth := pth + ".tmp"
+	file, err := os.OpenFile(tmpPth,
+		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to open audit file"),
+		}
+		return
+	}
+
+	err = writeEntries(file, entries)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to write audit file"),
+		}
+		return
+	}
+
+	err = os.Rename(tmpPth, pth)
+	if err != nil {
+		_ = os.Remove(tmpPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to replace audit file"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"expired": expired,
+	}).Info("audit: Pruned expired audit entries")
+
+	return
+}
+
+func writeEntries(file *os.File, entries []*Entry) (err error) {
+	writer := bufio.NewWriter(file)
+	for _, ent := range entries {
+		data, e := json.Marshal(ent)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "audit: Failed to marshal audit entry"),
+			}
+			return
+		}
+
+		_, _ = writer.Write(data)
+		_ = writer.WriteByte('\n')
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Record(ent *Entry) {
+	if ent.Timestamp.IsZero() {
+		ent.Timestamp = time.Now()
+	}
+
+	err := record(ent)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": ent.ProfileId,
+			"action":     ent.Action,
+			"error":      err,
+		}).Error("audit: Failed to record audit entry")
+	}
+}
+
+func record(ent *Entry) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	pth := GetPath()
+
+	err = platform.MkdirReadSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	if time.Since(lastPrune) > pruneInterval {
+		lastPrune = time.Now()
+
+		err = prune(pth)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("audit: Failed to prune audit file")
+			err = nil
+		}
+	}
+
+	file, err := os.OpenFile(pth, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to open audit file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	err = writeEntries(file, []*Entry{ent})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to write audit file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Entries in the time range, a zero time leaves that end of the range open
+func Get(from, to time.Time) (entries []*Entry, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries = []*Entry{}
+
+	err = readEntries(GetPath(), func(ent *Entry) {
+		if !from.IsZero() && ent.Timestamp.Before(from) {
+			return
+		}
+		if !to.IsZero() && ent.Timestamp.After(to) {
+			return
+		}
+		entries = append(entries, ent)
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func WriteCsv(w io.Writer, entries []*Entry) (err error) {
+	writer := csv.NewWriter(w)
+
+	err = writer.Write(csvHeader)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to write csv"),
+		}
+		return
+	}
+
+	for _, ent := range entries {
+		record := ent.csvRecord()
+		for i, val := range record {
+			record[i] = escapeCsv(val)
+		}
+
+		err = writer.Write(record)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "audit: Failed to write csv"),
+			}
+			return
+		}
+	}
+
+	writer.Flush()
+	err = writer.Error()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "audit: Failed to write csv"),
+		}
+		return
+	}
+
+	return
+}
+
+// Profile names are user controlled, prevent spreadsheet formula injection
+func escapeCsv(val string) string {
+	if val != "" && strings.ContainsAny(val[:1], "=+-@\t\r") {
+		return "'" + val
+	}
+	return val
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
@@ -19,8 +20,7 @@ func Init() (err error) {
 	pth := utils.GetAuthPath()
 
 	if runtime.GOOS == "windows" {
-		tempPth := filepath.Join(
-			utils.GetWinDrive(), "ProgramData", "Pritunl", "Temp")
+		tempPth := utils.GetTempPath()
 
 		exists, _ := utils.ExistsDir(tempPth)
 		if exists {
@@ -46,8 +46,7 @@ func Init() (err error) {
 			return
 		}
 
-		profilesPth := filepath.Join(utils.GetWinDrive(), "ProgramData",
-			"Pritunl", "Profiles")
+		profilesPth := sprofile.GetPath()
 		err = platform.MkdirSecure(profilesPth)
 		if err != nil {
 			err = &WriteError{
@@ -92,6 +91,7 @@ func Init() (err error) {
 		}
 
 		Key = strings.TrimSpace(string(data))
+		secret.Wipe(data)
 
 		if Key == "" {
 			err = os.Remove(pth)
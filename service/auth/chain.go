@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+const (
+	TransportTcp  = "tcp"
+	TransportUnix = "unix"
+	TransportPipe = "pipe"
+)
+
+var (
+	chains    = map[string]*Chain{}
+	chainLock = sync.RWMutex{}
+)
+
+// Validator checks a single property of a request, a request is accepted
+// only when every validator in the transport chain passes.
+type Validator interface {
+	Name() string
+	Validate(r *http.Request) (err error)
+}
+
+type ValidatorFunc struct {
+	name     string
+	validate func(r *http.Request) (err error)
+}
+
+func (v *ValidatorFunc) Name() string {
+	return v.name
+}
+
+func (v *ValidatorFunc) Validate(r *http.Request) (err error) {
+	err = v.validate(r)
+	return
+}
+
+func NewValidator(name string,
+	validate func(r *http.Request) (err error)) Validator {
+
+	return &ValidatorFunc{
+		name:     name,
+		validate: validate,
+	}
+}
+
+type Chain struct {
+	validators []Validator
+}
+
+func (c *Chain) Validate(r *http.Request) (err error) {
+	if len(c.validators) == 0 {
+		err = &AuthenticationError{
+			errors.New("auth: No validators configured"),
+		}
+		return
+	}
+
+	for _, validator := range c.validators {
+		err = validator.Validate(r)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func GetTransport(r *http.Request) string {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if ok {
+		switch addr.Network() {
+		case TransportUnix:
+			return TransportUnix
+		case TransportPipe:
+			return TransportPipe
+		}
+	}
+	return TransportTcp
+}
+
+// Add a validator to the chain of a transport, validators with the same
+// name are replaced. Chains are replaced rather than modified so requests
+// in progress are not affected.
+func Register(transport string, validator Validator) {
+	chainLock.Lock()
+	defer chainLock.Unlock()
+
+	validators := []Validator{}
+	replaced := false
+	if chain := chains[transport]; chain != nil {
+		for _, vldtr := range chain.validators {
+			if vldtr.Name() == validator.Name() {
+				validators = append(validators, validator)
+				replaced = true
+			} else {
+				validators = append(validators, vldtr)
+			}
+		}
+	}
+	if !replaced {
+		validators = append(validators, validator)
+	}
+
+	chains[transport] = &Chain{
+		validators: validators,
+	}
+}
+
+func Unregister(transport, name string) {
+	chainLock.Lock()
+	defer chainLock.Unlock()
+
+	chain := chains[transport]
+	if chain == nil {
+		return
+	}
+
+	validators := []Validator{}
+	for _, vldtr := range chain.validators {
+		if vldtr.Name() != name {
+			validators = append(validators, vldtr)
+		}
+	}
+
+	chains[transport] = &Chain{
+		validators: validators,
+	}
+}
+
+func GetValidators(transport string) (names []string) {
+	chainLock.RLock()
+	defer chainLock.RUnlock()
+
+	names = []string{}
+	chain := chains[transport]
+	if chain == nil {
+		return
+	}
+
+	for _, vldtr := range chain.validators {
+		names = append(names, vldtr.Name())
+	}
+
+	return
+}
+
+func Validate(r *http.Request) (err error) {
+	transport := GetTransport(r)
+
+	chainLock.RLock()
+	chain := chains[transport]
+	chainLock.RUnlock()
+
+	if chain == nil {
+		err = &AuthenticationError{
+			errors.Newf("auth: No chain for transport '%s'", transport),
+		}
+		return
+	}
+
+	err = chain.Validate(r)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetToken(r *http.Request) (token string) {
+	token = r.Header.Get("Auth-Token")
+	if token == "" {
+		token = r.Header.Get("Auth-Key")
+	}
+	return
+}
+
+func IsGrpc(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(
+		r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// Reject requests originating from a browser, metrics scrapers cannot set
+// the user agent and are exempt when authenticated with an api token. The
+// grpc library appends its version to the user agent of the client.
+func ValidateClient(r *http.Request) (err error) {
+	userAgent := r.Header.Get("User-Agent")
+	if r.Method == "GET" && r.URL.Path == "/metrics" &&
+		TokenScope(r) != "" {
+
+		userAgent = "pritunl"
+	} else if IsGrpc(r) && strings.HasPrefix(userAgent, "pritunl ") {
+		userAgent = "pritunl"
+	}
+
+	if r.Header.Get("Origin") != "" ||
+		r.Header.Get("Referer") != "" ||
+		userAgent != "pritunl" {
+
+		err = &AuthenticationError{
+			errors.New("auth: Invalid client headers"),
+		}
+		return
+	}
+
+	return
+}
+
+func ValidateKey(r *http.Request) (err error) {
+	if Key == "" || subtle.ConstantTimeCompare(
+		[]byte(GetToken(r)), []byte(Key)) != 1 {
+
+		err = &AuthenticationError{
+			errors.New("auth: Invalid auth key"),
+		}
+		return
+	}
+
+	return
+}
+
+func init() {
+	for _, transport := range []string{
+		TransportTcp,
+		TransportUnix,
+		TransportPipe,
+	} {
+		Register(transport, NewValidator("client", ValidateClient))
+		Register(transport, NewValidator("credential", ValidateCredential))
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const confirmTtl = 2 * time.Minute
+
+var (
+	confirmTokens = map[string]*confirmToken{}
+	confirmLock   = sync.Mutex{}
+)
+
+type confirmToken struct {
+	Token     string
+	Timestamp time.Time
+}
+
+// Create a single use token required to perform a destructive action
+func NewConfirmToken(action string) (token string, err error) {
+	token, err = utils.RandStr(32)
+	if err != nil {
+		return
+	}
+
+	confirmLock.Lock()
+	confirmTokens[action] = &confirmToken{
+		Token:     token,
+		Timestamp: time.Now(),
+	}
+	confirmLock.Unlock()
+
+	return
+}
+
+func CheckConfirmToken(action, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	confirmLock.Lock()
+	defer confirmLock.Unlock()
+
+	cnfrm := confirmTokens[action]
+	if cnfrm == nil {
+		return false
+	}
+
+	if time.Since(cnfrm.Timestamp) > confirmTtl {
+		delete(confirmTokens, action)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare(
+		[]byte(cnfrm.Token), []byte(token)) != 1 {
+
+		return false
+	}
+
+	delete(confirmTokens, action)
+	return true
+}
@@ -11,3 +11,11 @@ type ReadError struct {
 type WriteError struct {
 	errors.DropboxError
 }
+
+type AuthenticationError struct {
+	errors.DropboxError
+}
+
+type ScopeError struct {
+	errors.DropboxError
+}
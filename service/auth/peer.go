@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type connKey struct{}
+
+// Named pipe connections provide the process of the client
+type pipeConn interface {
+	ClientPid() int
+}
+
+// Requester identifies the client of a local API request, peer credentials
+// are only available for unix socket and named pipe connections
+type Requester struct {
+	Transport string `json:"transport"`
+	Address   string `json:"address"`
+	UserAgent string `json:"user_agent"`
+	Uid       int    `json:"uid"`
+	Pid       int    `json:"pid"`
+}
+
+// Store the connection in the request context, set as the http server
+// ConnContext to allow peer credential lookups
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+func GetRequester(r *http.Request) (requester *Requester) {
+	requester = &Requester{
+		Transport: GetTransport(r),
+		Address:   r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		Uid:       -1,
+		Pid:       -1,
+	}
+
+	conn, ok := r.Context().Value(connKey{}).(net.Conn)
+	if !ok {
+		return
+	}
+
+	if pConn, ok := conn.(pipeConn); ok {
+		requester.Pid = pConn.ClientPid()
+		return
+	}
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+
+	uid, pid, ok := getPeerCred(unixConn)
+	if ok {
+		requester.Uid = uid
+		requester.Pid = pid
+	}
+
+	return
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func getPeerCred(conn *net.UnixConn) (uid, pid int, ok bool) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	_ = rawConn.Control(func(fd uintptr) {
+		cred, e := unix.GetsockoptXucred(
+			int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+		if e != nil {
+			return
+		}
+
+		peerPid, e := unix.GetsockoptInt(
+			int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERPID)
+		if e != nil {
+			peerPid = -1
+		}
+
+		uid = int(cred.Uid)
+		pid = peerPid
+		ok = true
+	})
+
+	return
+}
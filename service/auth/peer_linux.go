@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func getPeerCred(conn *net.UnixConn) (uid, pid int, ok bool) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	_ = rawConn.Control(func(fd uintptr) {
+		cred, e := unix.GetsockoptUcred(
+			int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+		if e != nil {
+			return
+		}
+
+		uid = int(cred.Uid)
+		pid = int(cred.Pid)
+		ok = true
+	})
+
+	return
+}
@@ -0,0 +1,10 @@
+package auth
+
+import (
+	"net"
+)
+
+// Service API is served over tcp on Windows
+func getPeerCred(conn *net.UnixConn) (uid, pid int, ok bool) {
+	return
+}
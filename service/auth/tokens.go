@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	ScopeRead    = "read"
+	ScopeConnect = "connect"
+	ScopeAdmin   = "admin"
+
+	apiTokenPrefix = "pta_"
+)
+
+var tokensLock = sync.Mutex{}
+
+// Route paths are matched exactly, path segments starting with a colon
+// match any single segment
+type scopeRoute struct {
+	Method string
+	Path   string
+}
+
+var (
+	readRoutes = []*scopeRoute{
+		{"GET", "/events"},
+		{"GET", "/ping"},
+		{"GET", "/health"},
+		{"GET", "/metrics"},
+		{"GET", "/history"},
+		{"GET", "/audit"},
+		{"GET", "/status"},
+		{"GET", "/summary"},
+		{"GET", "/completion"},
+		{"GET", "/state"},
+		{"GET", "/capabilities"},
+		{"GET", "/profile"},
+		{"GET", "/profile/:profile_id/split_dns"},
+		{"GET", "/profile/:profile_id/dns"},
+		{"GET", "/profile/:profile_id/pause"},
+		{"GET", "/profile/:profile_id/stats"},
+		{"GET", "/profile/:profile_id/breakers"},
+		{"GET", "/network/nat"},
+		{"GET", "/network/clean"},
+		{"GET", "/network/restore"},
+		{"GET", "/posture"},
+		{"GET", "/system/adapters"},
+		{"GET", "/system/inventory"},
+		{"GET", "/kill_switch"},
+		{"POST", "/pritunl.client.v1.Client/GetStatus"},
+		{"POST", "/pritunl.client.v1.Client/WatchStatus"},
+	}
+	connectRoutes = []*scopeRoute{
+		{"POST", "/profile"},
+		{"DELETE", "/profile"},
+		{"DELETE", "/profile/:profile_id"},
+		{"POST", "/pritunl.client.v1.Client/Connect"},
+		{"POST", "/pritunl.client.v1.Client/Disconnect"},
+	}
+)
+
+func (r *scopeRoute) Match(method, pth string) bool {
+	if r.Method != method {
+		return false
+	}
+
+	routeSegs := strings.Split(r.Path, "/")
+	pathSegs := strings.Split(pth, "/")
+	if len(routeSegs) != len(pathSegs) {
+		return false
+	}
+
+	for i, seg := range routeSegs {
+		if strings.HasPrefix(seg, ":") {
+			if pathSegs[i] == "" {
+				return false
+			}
+		} else if seg != pathSegs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchRoutes(routes []*scopeRoute, method, pth string) bool {
+	for _, route := range routes {
+		if route.Match(method, pth) {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeConnect, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Scopes are cumulative, connect includes read and admin permits all
+// requests available to the auth key
+func ScopeAllows(scope, method, pth string) bool {
+	switch scope {
+	case ScopeAdmin:
+		return true
+	case ScopeConnect:
+		return matchRoutes(connectRoutes, method, pth) ||
+			matchRoutes(readRoutes, method, pth)
+	case ScopeRead:
+		return matchRoutes(readRoutes, method, pth)
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func getApiToken(token string) (apiToken *config.ApiToken) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return
+	}
+
+	hash := hashToken(token)
+
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	for _, tokn := range config.Config.ApiTokens {
+		if tokn.Hash == hash {
+			apiToken = tokn
+			return
+		}
+	}
+
+	return
+}
+
+func GetApiTokens() (tokens []*config.ApiToken) {
+	tokens = []*config.ApiToken{}
+
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	for _, tokn := range config.Config.ApiTokens {
+		tokens = append(tokens, &config.ApiToken{
+			Id:        tokn.Id,
+			Name:      tokn.Name,
+			Scope:     tokn.Scope,
+			Timestamp: tokn.Timestamp,
+		})
+	}
+
+	return
+}
+
+// Create a named token, the token is only returned once and stored as
+// a hash
+func CreateApiToken(name, scope string) (apiToken *config.ApiToken,
+	token string, err error) {
+
+	if !ValidScope(scope) {
+		err = &errortypes.ParseError{
+			errors.Newf("auth: Invalid token scope '%s'", scope),
+		}
+		return
+	}
+
+	tokenId, err := utils.RandStr(16)
+	if err != nil {
+		return
+	}
+
+	secret, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+	token = apiTokenPrefix + secret
+
+	apiToken = &config.ApiToken{
+		Id:        strings.ToLower(tokenId),
+		Name:      name,
+		Scope:     scope,
+		Hash:      hashToken(token),
+		Timestamp: time.Now().Unix(),
+	}
+
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	config.Config.ApiTokens = append(config.Config.ApiTokens, apiToken)
+
+	err = config.Save()
+	if err != nil {
+		config.Config.ApiTokens = config.Config.ApiTokens[:len(
+			config.Config.ApiTokens)-1]
+		return
+	}
+
+	apiToken = &config.ApiToken{
+		Id:        apiToken.Id,
+		Name:      apiToken.Name,
+		Scope:     apiToken.Scope,
+		Timestamp: apiToken.Timestamp,
+	}
+
+	return
+}
+
+func RevokeApiToken(tokenId string) (found bool, err error) {
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	prevTokens := config.Config.ApiTokens
+	tokens := []*config.ApiToken{}
+
+	for _, tokn := range prevTokens {
+		if tokn.Id == tokenId {
+			found = true
+			continue
+		}
+		tokens = append(tokens, tokn)
+	}
+
+	if !found {
+		return
+	}
+
+	config.Config.ApiTokens = tokens
+
+	err = config.Save()
+	if err != nil {
+		config.Config.ApiTokens = prevTokens
+		return
+	}
+
+	return
+}
+
+// Scope of the api token used for the request, empty for the auth key
+// which is available to every local user
+func TokenScope(r *http.Request) string {
+	apiToken := getApiToken(GetToken(r))
+	if apiToken == nil {
+		return ""
+	}
+	return apiToken.Scope
+}
+
+// Accept the auth key or an api token with a scope permitting the request
+func ValidateCredential(r *http.Request) (err error) {
+	token := GetToken(r)
+
+	if ValidateKey(r) == nil {
+		return
+	}
+
+	apiToken := getApiToken(token)
+	if apiToken == nil {
+		err = &AuthenticationError{
+			errors.New("auth: Invalid auth key"),
+		}
+		return
+	}
+
+	if !ScopeAllows(apiToken.Scope, r.Method, r.URL.Path) {
+		err = &ScopeError{
+			errors.Newf("auth: Token '%s' scope '%s' does not permit request",
+				apiToken.Name, apiToken.Scope),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope  string
+		method string
+		path   string
+		allow  bool
+	}{
+		{ScopeRead, "GET", "/status", true},
+		{ScopeRead, "GET", "/completion", true},
+		{ScopeRead, "GET", "/profile", true},
+		{ScopeRead, "GET", "/profile/abc/stats", true},
+		{ScopeRead, "GET", "/profile/abc/dns", true},
+		{ScopeRead, "GET", "/profile/abc/log", false},
+		{ScopeRead, "GET", "/profile//stats", false},
+		{ScopeRead, "GET", "/profile/abc/stats/extra", false},
+		{ScopeRead, "GET", "/sprofile/abc/log", false},
+		{ScopeRead, "POST", "/pritunl.client.v1.Client/GetStatus", true},
+		{ScopeRead, "POST", "/pritunl.client.v1.Client/TailLog", false},
+		{ScopeRead, "POST", "/profile", false},
+		{ScopeConnect, "POST", "/profile", true},
+		{ScopeConnect, "DELETE", "/profile/abc", true},
+		{ScopeConnect, "DELETE", "/profile/abc/breakers", false},
+		{ScopeConnect, "GET", "/profile/abc/log", false},
+		{ScopeConnect, "GET", "/status", true},
+		{ScopeAdmin, "GET", "/profile/abc/log", true},
+		{"", "GET", "/status", false},
+	}
+
+	for _, test := range tests {
+		allow := ScopeAllows(test.scope, test.method, test.path)
+		if allow != test.allow {
+			t.Errorf("%s %s %s: expected %t",
+				test.scope, test.method, test.path, test.allow)
+		}
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/status?token=pta_query", nil)
+	if token := GetToken(r); token != "" {
+		t.Fatalf("token read from query %q", token)
+	}
+
+	r.Header.Set("Auth-Key", "key")
+	if token := GetToken(r); token != "key" {
+		t.Fatalf("expected auth key got %q", token)
+	}
+
+	r.Header.Set("Auth-Token", "pta_header")
+	if token := GetToken(r); token != "pta_header" {
+		t.Fatalf("expected auth token got %q", token)
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	ApiAddressDefault = "127.0.0.1"
+	ApiPortDefault    = 9770
+	ApiSocketDefault  = "/var/run/pritunl.sock"
+)
+
+// Address of the api tcp listener, the events websocket and metrics are
+// served on the same listener
+func (c *ConfigData) ApiListenAddr() string {
+	addr := c.ApiAddress
+	if addr == "" {
+		addr = ApiAddressDefault
+	}
+
+	port := c.ApiPort
+	if port == 0 {
+		port = ApiPortDefault
+	}
+
+	return net.JoinHostPort(addr, fmt.Sprintf("%d", port))
+}
+
+// Path of the api unix socket on Linux and macOS
+func (c *ConfigData) ApiSocketPath() string {
+	if c.ApiSocket != "" {
+		return c.ApiSocket
+	}
+	return ApiSocketDefault
+}
+
+// The api is only served on loopback addresses, remote access requires
+// a forwarding agent
+func (c *ConfigData) applyApi() (err error) {
+	if c.ApiAddress != "" {
+		ip := net.ParseIP(c.ApiAddress)
+		if ip == nil || !ip.IsLoopback() {
+			err = &errortypes.ParseError{
+				errors.Newf(
+					"config: Api address '%s' not a loopback address",
+					c.ApiAddress),
+			}
+			return
+		}
+	}
+
+	if c.ApiPort < 0 || c.ApiPort > 65535 {
+		err = &errortypes.ParseError{
+			errors.Newf("config: Api port '%d' invalid", c.ApiPort),
+		}
+		return
+	}
+
+	if c.ApiSocket != "" {
+		if !filepath.IsAbs(c.ApiSocket) {
+			err = &errortypes.ParseError{
+				errors.Newf(
+					"config: Api socket path '%s' not absolute", c.ApiSocket),
+			}
+			return
+		}
+
+		c.ApiSocket = filepath.Clean(c.ApiSocket)
+	}
+
+	return
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
@@ -18,16 +19,107 @@ var (
 )
 
 type ConfigData struct {
-	path              string `json:"-"`
-	loaded            bool   `json:"-"`
-	DisableDnsWatch   bool   `json:"disable_dns_watch"`
-	DisableDnsRefresh bool   `json:"disable_dns_refresh"`
-	DisableWakeWatch  bool   `json:"disable_wake_watch"`
-	DisableNetClean   bool   `json:"disable_net_clean"`
-	EnableWgDns       bool   `json:"enable_wg_dns"`
-	ForceLocalTpm     bool   `json:"force_local_tpm"`
-	InterfaceMetric   int    `json:"interface_metric"`
-	EnclavePrivateKey string `json:"enclave_private_key"`
+	path                 string            `json:"-"`
+	loaded               bool              `json:"-"`
+	DisableDnsWatch      bool              `json:"disable_dns_watch"`
+	DisableDnsRefresh    bool              `json:"disable_dns_refresh"`
+	DisableWakeWatch     bool              `json:"disable_wake_watch"`
+	DisableNetworkWatch  bool              `json:"disable_network_watch"`
+	DisableNetClean      bool              `json:"disable_net_clean,omitempty"`
+	DisableCleanAdapters bool              `json:"disable_clean_adapters"`
+	DisableCleanRoutes   bool              `json:"disable_clean_routes"`
+	DisableCleanDns      bool              `json:"disable_clean_dns"`
+	DisableCleanFirewall bool              `json:"disable_clean_firewall"`
+	DisableCleanIpv6     bool              `json:"disable_clean_ipv6"`
+	DisableRouteGuard    bool              `json:"disable_route_guard"`
+	RouteCommands        bool              `json:"route_commands"`
+	DisableDnsBenchmark  bool              `json:"disable_dns_benchmark"`
+	DisableKeychain      bool              `json:"disable_keychain"`
+	DisableCaptivePortal bool              `json:"disable_captive_portal"`
+	PortalProbeUrls      []string          `json:"portal_probe_urls"`
+	PortalMaxWait        int               `json:"portal_max_wait"`
+	EnableWgDns          bool              `json:"enable_wg_dns"`
+	DnsTimeout           int               `json:"dns_timeout"`
+	DnsCheckDomain       string            `json:"dns_check_domain"`
+	DnsCheckInterval     int               `json:"dns_check_interval"`
+	DnsFallbackServers   []string          `json:"dns_fallback_servers"`
+	DisableDegradedCheck bool              `json:"disable_degraded_check"`
+	DegradedTimeout      int               `json:"degraded_timeout"`
+	DegradedReconnect    bool              `json:"degraded_reconnect"`
+	WireguardMode        string            `json:"wireguard_mode"`
+	ForceLocalTpm        bool              `json:"force_local_tpm"`
+	InterfaceMetric      int               `json:"interface_metric"`
+	DeviceId             string            `json:"device_id"`
+	EnclavePrivateKey    string            `json:"enclave_private_key"`
+	EnvAllowlist         []string          `json:"env_allowlist"`
+	ApiAddress           string            `json:"api_address"`
+	ApiPort              int               `json:"api_port"`
+	ApiSocket            string            `json:"api_socket"`
+	DisableApiPort       bool              `json:"disable_api_port"`
+	Hooks                map[string]string `json:"hooks"`
+	StopDuplicateLogin   bool              `json:"stop_duplicate_login"`
+	TempDir              string            `json:"temp_dir"`
+	ProfilesDir          string            `json:"profiles_dir"`
+	LogDir               string            `json:"log_dir"`
+	LogFormat            string            `json:"log_format"`
+	LogLevel             string            `json:"log_level"`
+	LogMaxSize           int               `json:"log_max_size"`
+	LogMaxAge            int               `json:"log_max_age"`
+	LogRetention         int               `json:"log_retention"`
+	DiagnosticsSnapshots int               `json:"diagnostics_snapshots"`
+	AuditRetention       int               `json:"audit_retention"`
+	StartConcurrency     int               `json:"start_concurrency"`
+	DisableSupport       bool              `json:"disable_support"`
+	PostureOsVersion     bool              `json:"posture_os_version"`
+	PostureEncryption    bool              `json:"posture_disk_encryption"`
+	PostureFirewall      bool              `json:"posture_firewall"`
+	PostureScreenLock    bool              `json:"posture_screen_lock"`
+	SyslogSink           string            `json:"syslog_sink"`
+	HttpProxy            string            `json:"http_proxy"`
+	SocksProxy           string            `json:"socks_proxy"`
+	ProxyNegotiate       bool              `json:"proxy_negotiate"`
+	AutoUpdate           bool              `json:"auto_update"`
+	UpdateChannel        string            `json:"update_channel"`
+	UpdateUrl            string            `json:"update_url"`
+	UpdatePublicKey      string            `json:"update_public_key"`
+	ApiTokens            []*ApiToken       `json:"api_tokens"`
+	WatchRules           []*WatchRule      `json:"watch_rules"`
+	Workspaces           []*Workspace      `json:"workspaces"`
+}
+
+type ApiToken struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	Hash      string `json:"hash"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type WatchRule struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	Interface string `json:"interface"`
+	Ssid      string `json:"ssid"`
+	ProfileId string `json:"profile_id"`
+	Duration  int    `json:"duration"`
+	Action    string `json:"action"`
+	Target    string `json:"target"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Isolated context for the profiles and shared credentials of one
+// organization, the set policy values override the profile settings
+type Workspace struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	KillSwitch  *bool  `json:"kill_switch,omitempty"`
+	AlwaysOn    *bool  `json:"always_on,omitempty"`
+	ForceDns    *bool  `json:"force_dns,omitempty"`
+	DisableIpv6 *bool  `json:"disable_ipv6,omitempty"`
+	HttpProxy   string `json:"http_proxy,omitempty"`
+	SocksProxy  string `json:"socks_proxy,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
 }
 
 func (c *ConfigData) Save() (err error) {
@@ -40,7 +132,17 @@ func (c *ConfigData) Save() (err error) {
 
 	pth := GetPath()
 
-	data, err := json.MarshalIndent(c, "", "\t")
+	var data []byte
+	if len(policy) > 0 {
+		unenforced, e := c.unenforced()
+		if e != nil {
+			err = e
+			return
+		}
+		data, err = json.MarshalIndent(unenforced, "", "\t")
+	} else {
+		data, err = json.MarshalIndent(c, "", "\t")
+	}
 	if err != nil {
 		err = &errortypes.WriteError{
 			errors.Wrap(err, "config: File marshal error"),
@@ -61,6 +163,38 @@ func (c *ConfigData) Save() (err error) {
 		return
 	}
 
+	setPolicyBase(data)
+
+	return
+}
+
+func (c *ConfigData) applyDirs() (err error) {
+	dirs := []*string{
+		&c.TempDir,
+		&c.ProfilesDir,
+		&c.LogDir,
+	}
+
+	for _, dir := range dirs {
+		if *dir == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(*dir) {
+			err = &errortypes.ParseError{
+				errors.Newf(
+					"config: Directory path '%s' not absolute", *dir),
+			}
+			return
+		}
+
+		*dir = filepath.Clean(*dir)
+	}
+
+	constants.TempDir = c.TempDir
+	constants.ProfilesDir = c.ProfilesDir
+	constants.LogDir = c.LogDir
+
 	return
 }
 
@@ -74,6 +208,16 @@ func Load() (err error) {
 		if os.IsNotExist(err) {
 			err = nil
 			data.loaded = true
+			policyBase = map[string]json.RawMessage{}
+
+			if len(policy) > 0 {
+				data, err = data.enforce(map[string]json.RawMessage{})
+				if err != nil {
+					return
+				}
+			}
+
+			data.migrate()
 			Config = data
 		} else {
 			err = &errortypes.ReadError{
@@ -99,13 +243,51 @@ func Load() (err error) {
 		return
 	}
 
+	err = data.applyDirs()
+	if err != nil {
+		return
+	}
+
+	err = data.applyApi()
+	if err != nil {
+		return
+	}
+
 	data.loaded = true
 
+	setPolicyBase(file)
+
+	if len(policy) > 0 {
+		unenforced, e := data.unenforced()
+		if e != nil {
+			err = e
+			return
+		}
+
+		data, err = data.enforce(unenforced)
+		if err != nil {
+			return
+		}
+	}
+
+	data.migrate()
 	Config = data
 
 	return
 }
 
+// The legacy network clean option disables every clean category
+func (c *ConfigData) migrate() {
+	if c.DisableNetClean {
+		c.DisableCleanAdapters = true
+		c.DisableCleanRoutes = true
+		c.DisableCleanDns = true
+		c.DisableCleanFirewall = true
+		c.DisableCleanIpv6 = true
+		c.DisableNetClean = false
+	}
+}
+
 func Save() (err error) {
 	err = Config.Save()
 	if err != nil {
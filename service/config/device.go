@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// Identifier of the installation generated on the first start, sent to
+// servers to correlate the device with the server side view of the device
+func InitDeviceId() (err error) {
+	if Config.DeviceId != "" {
+		return
+	}
+
+	Config.DeviceId = utils.Uuid()
+
+	err = Save()
+	if err != nil {
+		return
+	}
+
+	return
+}
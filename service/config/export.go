@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const Masked = "********"
+
+var (
+	secretKeys = []string{
+		"enclave_private_key",
+	}
+	secretUrlKeys = []string{
+		"http_proxy",
+		"socks_proxy",
+	}
+	secretTokenKeys = []string{
+		"hash",
+	}
+)
+
+func maskValue(data map[string]interface{}, key string) {
+	val, ok := data[key].(string)
+	if ok && val != "" {
+		data[key] = Masked
+	}
+}
+
+// Only the password of a url is masked, a url that cannot be parsed is
+// masked entirely
+func maskUrl(data map[string]interface{}, key string) {
+	val, ok := data[key].(string)
+	if !ok || val == "" {
+		return
+	}
+
+	u, err := url.Parse(val)
+	if err != nil {
+		data[key] = Masked
+		return
+	}
+
+	data[key] = u.Redacted()
+}
+
+// Export the loaded configuration with secrets masked
+func (c *ConfigData) Export() (data map[string]interface{}, err error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to marshal config"),
+		}
+		return
+	}
+
+	data = map[string]interface{}{}
+	err = json.Unmarshal(raw, &data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to unmarshal config"),
+		}
+		return
+	}
+
+	for _, key := range secretKeys {
+		maskValue(data, key)
+	}
+
+	for _, key := range secretUrlKeys {
+		maskUrl(data, key)
+	}
+
+	workspaces, _ := data["workspaces"].([]interface{})
+	for _, wsInf := range workspaces {
+		ws, ok := wsInf.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range secretUrlKeys {
+			maskUrl(ws, key)
+		}
+	}
+
+	tokens, _ := data["api_tokens"].([]interface{})
+	for _, tokenInf := range tokens {
+		token, ok := tokenInf.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range secretTokenKeys {
+			maskValue(token, key)
+		}
+	}
+
+	return
+}
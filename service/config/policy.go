@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+var (
+	policy     = map[string]json.RawMessage{}
+	policyBase = map[string]json.RawMessage{}
+	policyDeny = map[string]bool{
+		"api_tokens": true,
+	}
+)
+
+func setPolicyBase(data []byte) {
+	base := map[string]json.RawMessage{}
+	_ = json.Unmarshal(data, &base)
+	policyBase = base
+}
+
+// Config values with the enforced settings replaced by the values from the
+// config file
+func (c *ConfigData) unenforced() (data map[string]json.RawMessage,
+	err error) {
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to marshal config"),
+		}
+		return
+	}
+
+	data = map[string]json.RawMessage{}
+	err = json.Unmarshal(raw, &data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to unmarshal config"),
+		}
+		return
+	}
+
+	for key := range policy {
+		val, ok := policyBase[key]
+		if ok {
+			data[key] = val
+		} else {
+			delete(data, key)
+		}
+	}
+
+	return
+}
+
+func (c *ConfigData) enforce(data map[string]json.RawMessage) (
+	conf *ConfigData, err error) {
+
+	for key, val := range policy {
+		data[key] = val
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to marshal config"),
+		}
+		return
+	}
+
+	conf = &ConfigData{
+		path:   c.path,
+		loaded: c.loaded,
+	}
+
+	err = json.Unmarshal(raw, conf)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "config: Failed to parse enforced settings"),
+		}
+		return
+	}
+
+	err = conf.applyDirs()
+	if err != nil {
+		return
+	}
+
+	err = conf.applyApi()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Apply settings enforced by an administrator over the config file, the
+// enforced settings are not written to the config file
+func SetPolicy(settings map[string]json.RawMessage) (err error) {
+	data, err := Config.unenforced()
+	if err != nil {
+		return
+	}
+
+	newPolicy := map[string]json.RawMessage{}
+	for key, val := range settings {
+		if policyDeny[key] {
+			continue
+		}
+		newPolicy[key] = val
+	}
+
+	prevPolicy := policy
+	policy = newPolicy
+
+	conf, err := Config.enforce(data)
+	if err != nil {
+		policy = prevPolicy
+		return
+	}
+
+	conf.migrate()
+	Config = conf
+
+	return
+}
+
+func GetEnforced() (keys []string) {
+	keys = []string{}
+	for key := range policy {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func IsEnforced(key string) bool {
+	_, ok := policy[key]
+	return ok
+}
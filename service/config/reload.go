@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Directories are in use by active profiles and the api listeners are
+// bound at startup, these only change on restart
+var restartKeys = map[string]bool{
+	"temp_dir":         true,
+	"profiles_dir":     true,
+	"log_dir":          true,
+	"api_address":      true,
+	"api_port":         true,
+	"api_socket":       true,
+	"disable_api_port": true,
+}
+
+type ReloadResult struct {
+	Changed         []string `json:"changed"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+func configMap(c *ConfigData) (data map[string]json.RawMessage) {
+	data = map[string]json.RawMessage{}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(raw, &data)
+	return
+}
+
+// Read the config file again and report the settings that changed, the
+// previous config is kept when the file cannot be loaded
+func Reload() (result *ReloadResult, err error) {
+	result = &ReloadResult{
+		Changed:         []string{},
+		RestartRequired: []string{},
+	}
+
+	prev := Config
+	prevData := configMap(prev)
+
+	err = Load()
+	if err != nil {
+		Config = prev
+		_ = prev.applyDirs()
+		return
+	}
+
+	// The device identifier is kept when removed from the configuration
+	if Config.DeviceId == "" {
+		Config.DeviceId = prev.DeviceId
+	}
+
+	curData := configMap(Config)
+
+	keys := map[string]bool{}
+	for key := range prevData {
+		keys[key] = true
+	}
+	for key := range curData {
+		keys[key] = true
+	}
+
+	restart := false
+	for key := range keys {
+		if string(prevData[key]) == string(curData[key]) {
+			continue
+		}
+
+		if restartKeys[key] {
+			restart = true
+			result.RestartRequired = append(result.RestartRequired, key)
+		} else {
+			result.Changed = append(result.Changed, key)
+		}
+	}
+
+	if restart {
+		Config.TempDir = prev.TempDir
+		Config.ProfilesDir = prev.ProfilesDir
+		Config.LogDir = prev.LogDir
+		Config.ApiAddress = prev.ApiAddress
+		Config.ApiPort = prev.ApiPort
+		Config.ApiSocket = prev.ApiSocket
+		Config.DisableApiPort = prev.DisableApiPort
+		err = Config.applyDirs()
+		if err != nil {
+			return
+		}
+	}
+
+	sort.Strings(result.Changed)
+	sort.Strings(result.RestartRequired)
+
+	return
+}
@@ -5,6 +5,10 @@ const (
 )
 
 var (
+	ApiVersions = []int{1}
 	Development = false
 	Macos10     = false
+	TempDir     = ""
+	ProfilesDir = ""
+	LogDir      = ""
 )
@@ -0,0 +1,42 @@
+// Detection of containerized environments, host specific operations are
+// skipped when the service runs inside a container.
+package container
+
+import (
+	"sync"
+)
+
+var (
+	detected   string
+	detectOnce = sync.Once{}
+)
+
+// Container runtime name, empty when not running in a container
+func Runtime() string {
+	detectOnce.Do(func() {
+		detected = detect()
+	})
+	return detected
+}
+
+func Detected() bool {
+	return Runtime() != ""
+}
+
+// Tunnel device required by OpenVPN and userspace WireGuard, returns
+// NotFoundError if the device is missing and PreconditionError if the
+// device cannot be opened
+func CheckTun() (err error) {
+	return checkTun()
+}
+
+// Network administration capability required to configure interfaces and
+// routes, returns PreconditionError if the capability is missing
+func CheckNetAdmin() (err error) {
+	return checkNetAdmin()
+}
+
+// Create the tunnel device if it was not passed to the container
+func PrepareTun() (err error) {
+	return prepareTun()
+}
@@ -0,0 +1,19 @@
+package container
+
+const TunPath = ""
+
+func detect() string {
+	return ""
+}
+
+func checkTun() (err error) {
+	return
+}
+
+func checkNetAdmin() (err error) {
+	return
+}
+
+func prepareTun() (err error) {
+	return
+}
@@ -0,0 +1,137 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	TunPath     = "/dev/net/tun"
+	capNetAdmin = 12
+)
+
+var cgroupRuntimes = []string{
+	"kubepods",
+	"docker",
+	"libpod",
+	"containerd",
+	"lxc",
+}
+
+func detect() string {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+
+	// Set by systemd-nspawn, lxc and podman
+	if env := os.Getenv("container"); env != "" {
+		return env
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+
+	data, err := ioutil.ReadFile("/proc/1/cgroup")
+	if err == nil {
+		cgroup := string(data)
+		for _, runtime := range cgroupRuntimes {
+			if strings.Contains(cgroup, "/"+runtime) {
+				return runtime
+			}
+		}
+	}
+
+	return ""
+}
+
+func checkTun() (err error) {
+	file, err := os.OpenFile(TunPath, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "container: Tunnel device not found"),
+			}
+		} else {
+			err = &errortypes.PreconditionError{
+				errors.Wrap(err, "container: Tunnel device not accessible"),
+			}
+		}
+		return
+	}
+	file.Close()
+
+	return
+}
+
+func checkNetAdmin() (err error) {
+	data, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "container: Failed to read process status"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+
+		caps, e := strconv.ParseUint(
+			strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "container: Failed to parse capabilities"),
+			}
+			return
+		}
+
+		if caps&(1<<capNetAdmin) == 0 {
+			err = &errortypes.PreconditionError{
+				errors.New("container: Missing CAP_NET_ADMIN capability"),
+			}
+		}
+		return
+	}
+
+	return
+}
+
+// Containers started without the device can create it when the device
+// cgroup permits the tun character device
+func prepareTun() (err error) {
+	_, err = os.Stat(TunPath)
+	if err == nil || !os.IsNotExist(err) {
+		err = nil
+		return
+	}
+
+	err = os.MkdirAll("/dev/net", 0755)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "container: Failed to create device directory"),
+		}
+		return
+	}
+
+	err = unix.Mknod(TunPath, unix.S_IFCHR|0666, int(unix.Mkdev(10, 200)))
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "container: Failed to create tunnel device"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,36 @@
+package container
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+const TunPath = ""
+
+func detect() string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Control`, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+
+	_, _, err = key.GetIntegerValue("ContainerType")
+	if err != nil {
+		return ""
+	}
+
+	return "windows"
+}
+
+// Adapters are provided by the host network of the container
+func checkTun() (err error) {
+	return
+}
+
+func checkNetAdmin() (err error) {
+	return
+}
+
+func prepareTun() (err error) {
+	return
+}
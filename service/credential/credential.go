@@ -0,0 +1,234 @@
+// Exec based external credential providers.
+package credential
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Version     = 1
+	execTimeout = 30 * time.Second
+	maxTtl      = 24 * time.Hour
+)
+
+var (
+	cache     = map[string]*Credentials{}
+	cacheLock = sync.Mutex{}
+)
+
+type Request struct {
+	Version   int      `json:"version"`
+	ProfileId string   `json:"profile_id"`
+	OrgId     string   `json:"org_id"`
+	UserId    string   `json:"user_id"`
+	ServerId  string   `json:"server_id"`
+	Username  string   `json:"username"`
+	Fields    []string `json:"fields"`
+}
+
+type Response struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Otp      string `json:"otp"`
+	Ttl      int    `json:"ttl"`
+	Error    string `json:"error"`
+}
+
+type Credentials struct {
+	Provider  string
+	Username  string
+	Password  string
+	Otp       string
+	Timestamp time.Time
+	Ttl       time.Duration
+}
+
+func (c *Credentials) Expired() bool {
+	return utils.SinceAbs(c.Timestamp) > c.Ttl
+}
+
+func run(pth string, req *Request) (resp *Response, err error) {
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Failed to marshal request"),
+		}
+		return
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := command.Command(pth)
+	cmd.Dir = filepath.Dir(pth)
+	cmd.Stdin = bytes.NewReader(reqData)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "credential: Failed to start provider"),
+		}
+		return
+	}
+
+	timer := time.AfterFunc(execTimeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "credential: Provider failed '%s'",
+				strings.TrimSpace(stderr.String())),
+		}
+		return
+	}
+
+	output := secret.FromBytes(stdout.Bytes())
+	defer output.Destroy()
+
+	resp = &Response{}
+	err = json.Unmarshal(output.Bytes(), resp)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Failed to parse provider output"),
+		}
+		return
+	}
+
+	if resp.Error != "" {
+		err = &errortypes.ExecError{
+			errors.Newf("credential: Provider error '%s'", resp.Error),
+		}
+		return
+	}
+
+	return
+}
+
+func Get(provider string, cacheTtl int, req *Request) (
+	creds *Credentials, err error) {
+
+	provider = utils.FilterStr(provider)
+	if provider == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Invalid provider name"),
+		}
+		return
+	}
+
+	cacheLock.Lock()
+	creds = cache[req.ProfileId]
+	cacheLock.Unlock()
+
+	if creds != nil && creds.Provider == provider && !creds.Expired() {
+		return
+	}
+	creds = nil
+
+	pth, err := GetProviderPath(provider)
+	if err != nil {
+		return
+	}
+
+	// Providers are run as root and must not be writable by other users
+	err = platform.CheckSecureFile(pth)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": req.ProfileId,
+			"provider":   provider,
+			"path":       pth,
+			"error":      err,
+		}).Error("credential: Refusing to run insecure provider")
+		return
+	}
+
+	req.Version = Version
+	if req.Fields == nil {
+		req.Fields = []string{"username", "password", "otp"}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": req.ProfileId,
+		"provider":   provider,
+	}).Info("credential: Requesting credentials from provider")
+
+	resp, err := run(pth, req)
+	if err != nil {
+		return
+	}
+
+	ttl := time.Duration(cacheTtl) * time.Second
+	if resp.Ttl > 0 && time.Duration(resp.Ttl)*time.Second < ttl {
+		ttl = time.Duration(resp.Ttl) * time.Second
+	}
+	if ttl > maxTtl {
+		ttl = maxTtl
+	}
+
+	creds = &Credentials{
+		Provider:  provider,
+		Username:  resp.Username,
+		Password:  resp.Password,
+		Otp:       resp.Otp,
+		Timestamp: time.Now(),
+		Ttl:       ttl,
+	}
+
+	if ttl > 0 && resp.Otp == "" {
+		cacheLock.Lock()
+		cache[req.ProfileId] = creds
+		cacheLock.Unlock()
+	}
+
+	return
+}
+
+func Clear(prflId string) {
+	cacheLock.Lock()
+	delete(cache, prflId)
+	cacheLock.Unlock()
+}
+
+func ClearAll() {
+	cacheLock.Lock()
+	cache = map[string]*Credentials{}
+	cacheLock.Unlock()
+}
+
+func GetProviderPath(provider string) (pth string, err error) {
+	pth = filepath.Join(GetProvidersPath(), provider)
+	if _, e := os.Stat(pth + ".exe"); e == nil {
+		pth += ".exe"
+	}
+
+	exists, err := utils.ExistsFile(pth)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("credential: Provider '%s' not found", provider),
+		}
+		return
+	}
+
+	return
+}
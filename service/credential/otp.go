@@ -0,0 +1,140 @@
+package credential
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/secrets"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	otpLock     = sync.Mutex{}
+	otpCounters = map[string]int64{}
+)
+
+func otpSeedKey(prflId string) string {
+	return "profile-" + prflId + "-otp"
+}
+
+// Profile OTP seeds are only stored in the credential store, the seed is
+// never written to the profile configuration
+func SetOtpSeed(prflId, seed string) (err error) {
+	prflId = utils.FilterStr(prflId)
+	if prflId == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Invalid profile ID"),
+		}
+		return
+	}
+
+	if seed == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Missing otp seed"),
+		}
+		return
+	}
+
+	_, err = decodeOtpSeed(seed)
+	if err != nil {
+		return
+	}
+
+	if !secrets.Available() {
+		err = &errortypes.PreconditionError{
+			errors.New("credential: Credential store not available"),
+		}
+		return
+	}
+
+	err = secrets.Set(otpSeedKey(prflId), seed)
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("credential: Stored profile otp seed")
+
+	return
+}
+
+// Returns a NotFoundError if the profile does not have an OTP seed
+func GetOtpSeed(prflId string) (seed string, err error) {
+	prflId = utils.FilterStr(prflId)
+	if prflId == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Invalid profile ID"),
+		}
+		return
+	}
+
+	seed, err = secrets.Get(otpSeedKey(prflId))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func HasOtpSeed(prflId string) bool {
+	_, err := GetOtpSeed(prflId)
+	return err == nil
+}
+
+func RemoveOtpSeed(prflId string) (err error) {
+	prflId = utils.FilterStr(prflId)
+	if prflId == "" {
+		return
+	}
+
+	otpLock.Lock()
+	delete(otpCounters, prflId)
+	otpLock.Unlock()
+
+	err = secrets.Remove(otpSeedKey(prflId))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Servers reject a code that has already been used, when a profile
+// reconnects within the same period wait for the next code
+func GenerateProfileTotp(prflId, seed string) (code string, err error) {
+	otpLock.Lock()
+	lastCounter := otpCounters[prflId]
+	otpLock.Unlock()
+
+	now := time.Now()
+	counter := now.Unix() / totpPeriod
+
+	if lastCounter >= counter {
+		wait := time.Unix((lastCounter+1)*totpPeriod, 0).Sub(now)
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"wait":       wait.String(),
+		}).Info("credential: Waiting for next otp code")
+
+		time.Sleep(wait)
+
+		now = time.Now()
+		counter = now.Unix() / totpPeriod
+	}
+
+	code, err = GenerateTotp(seed, now)
+	if err != nil {
+		return
+	}
+
+	otpLock.Lock()
+	otpCounters[prflId] = counter
+	otpLock.Unlock()
+
+	return
+}
@@ -0,0 +1,305 @@
+package credential
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secrets"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var sharedLock = sync.Mutex{}
+
+// Shared credentials are referenced by multiple profiles, updating the
+// credential applies to every profile on the next connection
+type Shared struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Workspace string `json:"workspace,omitempty"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	OtpSeed   string `json:"otp_seed"`
+	Keychain  bool   `json:"keychain,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Secret fields of a shared credential kept in the credential store
+type sharedSecret struct {
+	Password string `json:"password"`
+	OtpSeed  string `json:"otp_seed"`
+}
+
+type SharedInfo struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Workspace   string `json:"workspace"`
+	Username    string `json:"username"`
+	HasPassword bool   `json:"has_password"`
+	HasOtpSeed  bool   `json:"has_otp_seed"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+func (s *Shared) Info() *SharedInfo {
+	return &SharedInfo{
+		Id:          s.Id,
+		Name:        s.Name,
+		Workspace:   s.Workspace,
+		Username:    s.Username,
+		HasPassword: s.Password != "",
+		HasOtpSeed:  s.OtpSeed != "",
+		Timestamp:   s.Timestamp,
+	}
+}
+
+func (s *Shared) Credentials() (creds *Credentials, err error) {
+	creds = &Credentials{
+		Username:  s.Username,
+		Password:  s.Password,
+		Timestamp: time.Now(),
+	}
+
+	if s.OtpSeed != "" {
+		creds.Otp, err = GenerateTotp(s.OtpSeed, time.Now())
+		if err != nil {
+			creds = nil
+			return
+		}
+	}
+
+	return
+}
+
+func sharedFilePath(credId string) string {
+	return filepath.Join(GetSharedPath(), credId+".json")
+}
+
+func sharedSecretKey(credId string) string {
+	return "shared-" + credId
+}
+
+func (s *Shared) loadSecret() (err error) {
+	val, err := secrets.Get(sharedSecretKey(s.Id))
+	if err != nil {
+		return
+	}
+
+	secret := &sharedSecret{}
+	err = json.Unmarshal([]byte(val), secret)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Failed to parse shared secret"),
+		}
+		return
+	}
+
+	s.Password = secret.Password
+	s.OtpSeed = secret.OtpSeed
+
+	return
+}
+
+// Move the password and OTP seed to the credential store when available,
+// must be called with the shared lock held
+func (s *Shared) commit() (err error) {
+	err = platform.MkdirSecure(GetSharedPath())
+	if err != nil {
+		return
+	}
+
+	stored := *s
+	stored.Keychain = false
+
+	if (s.Password != "" || s.OtpSeed != "") && secrets.Available() {
+		val, e := json.Marshal(&sharedSecret{
+			Password: s.Password,
+			OtpSeed:  s.OtpSeed,
+		})
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "credential: Failed to marshal shared secret"),
+			}
+			return
+		}
+
+		e = secrets.Set(sharedSecretKey(s.Id), string(val))
+		if e == nil {
+			stored.Password = ""
+			stored.OtpSeed = ""
+			stored.Keychain = true
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"credential_id": s.Id,
+				"error":         e,
+			}).Error("credential: Failed to store shared secret")
+		}
+	}
+
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Failed to marshal shared credential"),
+		}
+		return
+	}
+
+	err = utils.CreateWrite(sharedFilePath(s.Id), string(data), 0600)
+	if err != nil {
+		return
+	}
+
+	if !stored.Keychain {
+		_ = secrets.Remove(sharedSecretKey(s.Id))
+	}
+	s.Keychain = stored.Keychain
+
+	return
+}
+
+func GetShared(credId string) (shared *Shared, err error) {
+	credId = utils.FilterStr(credId)
+	if credId == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Invalid shared credential ID"),
+		}
+		return
+	}
+
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	data, err := ioutil.ReadFile(sharedFilePath(credId))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = &errortypes.NotFoundError{
+				errors.Newf("credential: Shared credential '%s' not found",
+					credId),
+			}
+		} else {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "credential: Failed to read shared credential"),
+			}
+		}
+		return
+	}
+
+	shared = &Shared{}
+	err = json.Unmarshal(data, shared)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Failed to parse shared credential"),
+		}
+		return
+	}
+
+	if shared.Keychain {
+		err = shared.loadSecret()
+		if err != nil {
+			shared = nil
+			return
+		}
+	} else if (shared.Password != "" || shared.OtpSeed != "") &&
+		secrets.Available() {
+
+		e := shared.commit()
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"credential_id": credId,
+				"error":         e,
+			}).Error("credential: Failed to migrate shared secret")
+		}
+	}
+
+	return
+}
+
+// Shared credentials are only listed in the workspace they were created in
+func GetSharedAll(wsId string) (infos []*SharedInfo, err error) {
+	infos = []*SharedInfo{}
+
+	pths, err := filepath.Glob(filepath.Join(GetSharedPath(), "*.json"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "credential: Failed to list shared credentials"),
+		}
+		return
+	}
+
+	for _, pth := range pths {
+		credId := strings.TrimSuffix(filepath.Base(pth), ".json")
+
+		shared, e := GetShared(credId)
+		if e != nil || shared.Workspace != wsId {
+			continue
+		}
+
+		infos = append(infos, shared.Info())
+	}
+
+	return
+}
+
+func (s *Shared) Validate() (err error) {
+	s.Id = utils.FilterStr(s.Id)
+	if s.Id == "" {
+		err = &errortypes.ParseError{
+			errors.New("credential: Invalid shared credential ID"),
+		}
+		return
+	}
+
+	if s.OtpSeed != "" {
+		_, err = decodeOtpSeed(s.OtpSeed)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (s *Shared) Commit() (err error) {
+	err = s.Validate()
+	if err != nil {
+		return
+	}
+
+	s.Timestamp = time.Now().Unix()
+
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	err = s.commit()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveShared(credId string) (err error) {
+	credId = utils.FilterStr(credId)
+	if credId == "" {
+		return
+	}
+
+	sharedLock.Lock()
+	defer sharedLock.Unlock()
+
+	err = utils.Remove(sharedFilePath(credId))
+	if err != nil {
+		return
+	}
+
+	_ = secrets.Remove(sharedSecretKey(credId))
+
+	return
+}
@@ -0,0 +1,57 @@
+package credential
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+)
+
+func decodeOtpSeed(seed string) (key []byte, err error) {
+	seed = strings.ToUpper(strings.ReplaceAll(seed, " ", ""))
+	seed = strings.TrimRight(seed, "=")
+
+	key, err = base32.StdEncoding.WithPadding(
+		base32.NoPadding).DecodeString(seed)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "credential: Invalid otp seed"),
+		}
+		return
+	}
+
+	return
+}
+
+// RFC 6238 code using the default SHA-1, 30 second and 6 digit parameters
+func GenerateTotp(seed string, now time.Time) (code string, err error) {
+	key, err := decodeOtpSeed(seed)
+	if err != nil {
+		return
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(now.Unix()/totpPeriod))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	code = fmt.Sprintf("%06d", value%1000000)
+
+	return
+}
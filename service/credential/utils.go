@@ -0,0 +1,40 @@
+package credential
+
+import (
+	"path/filepath"
+	"runtime"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func GetProvidersPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "Providers")
+	case "darwin":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "providers")
+	case "linux":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "providers")
+	default:
+		panic("credential: Not implemented")
+	}
+}
+
+func GetSharedPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "Credentials")
+	case "darwin":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "credentials")
+	case "linux":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "credentials")
+	default:
+		panic("credential: Not implemented")
+	}
+}
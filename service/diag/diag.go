@@ -0,0 +1,374 @@
+// Diagnostics snapshots captured when a connection fails, the system
+// network state is recorded at the time of the error for intermittent
+// failures that cannot be reproduced.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxLines   = 200
+	maxErrors  = 20
+	errorsSpan = 30 * time.Minute
+)
+
+var (
+	lock = sync.Mutex{}
+
+	// Events emitted when a connection fails or is disconnected with
+	// an error
+	errorEvents = set(
+		"auth_error",
+		"clock_skew",
+		"configuration_error",
+		"connection_error",
+		"credential_error",
+		"duplicate_login",
+		"handshake_timeout",
+		"inactive",
+		"offline_error",
+		"precondition_error",
+		"timeout_error",
+	)
+)
+
+type Snapshot struct {
+	Id           string             `json:"id"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Event        string             `json:"event"`
+	ProfileId    string             `json:"profile_id"`
+	Name         string             `json:"name"`
+	Mode         string             `json:"mode"`
+	Status       string             `json:"status"`
+	ServerAddr   string             `json:"server_addr"`
+	ClientAddr   string             `json:"client_addr"`
+	HandshakeAge int64              `json:"handshake_age"`
+	Nat          *network.NatResult `json:"nat"`
+	Routes       []string           `json:"routes"`
+	Dns          []string           `json:"dns"`
+	Errors       []string           `json:"errors"`
+}
+
+func set(vals ...string) (s map[string]bool) {
+	s = map[string]bool{}
+	for _, val := range vals {
+		s[val] = true
+	}
+	return
+}
+
+func GetPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "diagnostics")
+}
+
+func limit(lines []string) []string {
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	return lines
+}
+
+func splitLines(output string) (lines []string) {
+	lines = []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+// Recent warnings and errors logged for the profile
+func getRoutes() (routes []string, err error) {
+	routes = []string{}
+
+	for _, ipv6 := range []bool{false, true} {
+		rts, e := netconf.Get().GetRoutes(ipv6)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, route := range rts {
+			line := route.Network.String()
+			if route.Gateway != nil {
+				line += " via " + route.Gateway.String()
+			}
+			if route.Iface != "" {
+				line += " dev " + route.Iface
+			}
+			line += fmt.Sprintf(" metric %d", route.Metric)
+			routes = append(routes, line)
+		}
+	}
+
+	return
+}
+
+func getErrors(prflId string) (errs []string) {
+	errs = []string{}
+
+	entries, err := logger.GetProfileEntries(
+		prflId, time.Now().Add(-errorsSpan), 0)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Level != "error" && entry.Level != "warning" {
+			continue
+		}
+
+		msg := entry.Time.Format(time.RFC3339) + " " + entry.Message
+		if entry.Error != "" {
+			msg += ": " + entry.Error
+		} else if e, ok := entry.Fields["error"].(string); ok {
+			msg += ": " + e
+		}
+		errs = append(errs, msg)
+	}
+
+	if len(errs) > maxErrors {
+		errs = errs[len(errs)-maxErrors:]
+	}
+
+	return
+}
+
+func capture(evtType, prflId string) (snapshot *Snapshot) {
+	snapshot = &Snapshot{
+		Timestamp: time.Now(),
+		Event:     evtType,
+		ProfileId: prflId,
+	}
+	snapshot.Id = fmt.Sprintf("%d-%s",
+		snapshot.Timestamp.UnixNano(), utils.Uuid()[:8])
+
+	prfl := profile.GetProfile(prflId)
+	if prfl != nil {
+		snapshot.Name = prfl.Name
+		snapshot.Mode = prfl.Mode
+		snapshot.Status = prfl.Status
+		snapshot.ServerAddr = prfl.ServerAddr
+		snapshot.ClientAddr = prfl.ClientAddr
+		snapshot.HandshakeAge = int64(prfl.GetHandshakeAge().Seconds())
+	}
+
+	snapshot.Nat = network.GetNat()
+
+	routes, err := getRoutes()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"error":      err,
+		}).Warn("diag: Failed to capture routes")
+	}
+	snapshot.Routes = limit(routes)
+
+	dns, err := getDns()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"error":      err,
+		}).Warn("diag: Failed to capture DNS servers")
+	}
+	snapshot.Dns = limit(dns)
+
+	snapshot.Errors = getErrors(prflId)
+
+	return
+}
+
+func save(snapshot *Snapshot) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	pth := GetPath()
+
+	err = platform.MkdirSecure(pth)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "diag: Failed to marshal snapshot"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(filepath.Join(pth, snapshot.Id+".json"),
+		data, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "diag: Failed to write snapshot"),
+		}
+		return
+	}
+
+	names, err := list()
+	if err != nil {
+		return
+	}
+
+	retain := config.Config.DiagnosticsSnapshots
+	for i := 0; i < len(names)-retain; i++ {
+		_ = os.Remove(filepath.Join(pth, names[i]))
+	}
+
+	return
+}
+
+// Snapshot file names sorted oldest first
+func list() (names []string, err error) {
+	names = []string{}
+
+	files, err := ioutil.ReadDir(GetPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "diag: Failed to read snapshots"),
+		}
+		return
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return
+}
+
+// Get the retained snapshots newest first
+func GetAll() (snapshots []*Snapshot, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	snapshots = []*Snapshot{}
+
+	names, err := list()
+	if err != nil {
+		return
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		data, e := ioutil.ReadFile(filepath.Join(GetPath(), names[i]))
+		if e != nil {
+			continue
+		}
+
+		snapshot := &Snapshot{}
+		e = json.Unmarshal(data, snapshot)
+		if e != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return
+}
+
+func Clear() (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	err = os.RemoveAll(GetPath())
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "diag: Failed to remove snapshots"),
+		}
+		return
+	}
+
+	return
+}
+
+func watch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("diag: Panic")
+			panic(panc)
+		}
+	}()
+
+	listener := event.NewListener()
+	stream := listener.Listen()
+	defer listener.Close()
+
+	for evt := range stream {
+		if config.Config.DiagnosticsSnapshots <= 0 ||
+			!errorEvents[evt.Type] {
+
+			continue
+		}
+
+		prflId := ""
+		switch data := evt.Data.(type) {
+		case *profile.Profile:
+			prflId = data.Id
+			break
+		case *profile.ClockSkew:
+			prflId = data.ProfileId
+			break
+		case *profile.PrecheckData:
+			prflId = data.Id
+			break
+		}
+		if prflId == "" {
+			continue
+		}
+
+		evtType := evt.Type
+		go func() {
+			snapshot := capture(evtType, prflId)
+
+			err := save(snapshot)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": snapshot.ProfileId,
+					"error":      err,
+				}).Error("diag: Failed to save diagnostics snapshot")
+			}
+		}()
+	}
+}
+
+func StartWatch() {
+	go watch()
+}
@@ -0,0 +1,30 @@
+package diag
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func getDns() (servers []string, err error) {
+	servers = []string{}
+
+	output, err := utils.ExecOutput("/usr/sbin/scutil", "--dns")
+	if err != nil {
+		return
+	}
+
+	for _, line := range splitLines(output) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "resolver #") ||
+			strings.HasPrefix(line, "nameserver[") ||
+			strings.HasPrefix(line, "search domain[") ||
+			strings.HasPrefix(line, "domain") ||
+			strings.HasPrefix(line, "if_index") {
+
+			servers = append(servers, line)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,38 @@
+package diag
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func getDns() (servers []string, err error) {
+	servers = []string{}
+
+	output, e := utils.ExecOutput("resolvectl", "dns")
+	if e == nil {
+		servers = splitLines(output)
+		return
+	}
+
+	data, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "diag: Failed to read resolv.conf"),
+		}
+		return
+	}
+
+	for _, line := range splitLines(string(data)) {
+		if strings.HasPrefix(line, "nameserver") ||
+			strings.HasPrefix(line, "search") {
+
+			servers = append(servers, line)
+		}
+	}
+
+	return
+}
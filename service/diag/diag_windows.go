@@ -0,0 +1,24 @@
+package diag
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func getDns() (servers []string, err error) {
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"Get-DnsClientServerAddress | "+
+			"Where-Object { $_.ServerAddresses } | "+
+			"ForEach-Object { \"$($_.InterfaceAlias) "+
+			"$($_.ServerAddresses -join ',')\" }",
+	)
+	if err != nil {
+		return
+	}
+
+	servers = splitLines(output)
+
+	return
+}
@@ -31,3 +31,11 @@ type ExecError struct {
 type RequestError struct {
 	errors.DropboxError
 }
+
+type PolicyError struct {
+	errors.DropboxError
+}
+
+type PreconditionError struct {
+	errors.DropboxError
+}
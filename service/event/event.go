@@ -3,6 +3,7 @@ package event
 
 import (
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-client-electron/service/message"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"sync"
 	"time"
@@ -20,13 +21,18 @@ var (
 )
 
 type Event struct {
-	Id   string      `json:"id"`
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Id        string           `json:"id"`
+	Type      string           `json:"type"`
+	Workspace string           `json:"workspace,omitempty"`
+	Data      interface{}      `json:"data"`
+	Message   *message.Message `json:"message,omitempty"`
 }
 
 func (e *Event) Init() {
 	e.Id = utils.Uuid()
+	if e.Message == nil {
+		e.Message = message.Event(e.Type, e.Data)
+	}
 
 	listeners.RLock()
 	defer listeners.RUnlock()
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type apiTokenData struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type apiTokenCreateData struct {
+	*config.ApiToken
+	Token string `json:"token"`
+}
+
+func apiTokenGet(c *gin.Context) {
+	c.JSON(200, auth.GetApiTokens())
+}
+
+func apiTokenPost(c *gin.Context) {
+	data := &apiTokenData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data.Name = utils.FilterStr(data.Name)
+	if data.Name == "" || !auth.ValidScope(data.Scope) {
+		err = &errortypes.ParseError{
+			errors.New("handler: Invalid token name or scope"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	apiToken, token, err := auth.CreateApiToken(data.Name, data.Scope)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"token_id": apiToken.Id,
+		"name":     apiToken.Name,
+		"scope":    apiToken.Scope,
+	}).Info("handlers: Created api token")
+
+	c.JSON(200, &apiTokenCreateData{
+		ApiToken: apiToken,
+		Token:    token,
+	})
+}
+
+func apiTokenDelete(c *gin.Context) {
+	tokenId := utils.FilterStr(c.Param("token_id"))
+	if tokenId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid token ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	found, err := auth.RevokeApiToken(tokenId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !found {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"token_id": tokenId,
+	}).Info("handlers: Revoked api token")
+
+	c.JSON(200, nil)
+}
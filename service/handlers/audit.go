@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/audit"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// Time query parameter as RFC 3339 or unix seconds
+func parseAuditTime(val string) (t time.Time, err error) {
+	if val == "" {
+		return
+	}
+
+	unix, e := strconv.ParseInt(val, 10, 64)
+	if e == nil {
+		t = time.Unix(unix, 0)
+		return
+	}
+
+	t, err = time.Parse(time.RFC3339, val)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "handler: Invalid audit time '%s'", val),
+		}
+		return
+	}
+
+	return
+}
+
+func auditGet(c *gin.Context) {
+	from, err := parseAuditTime(c.Query("from"))
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	to, err := parseAuditTime(c.Query("to"))
+	if err != nil {
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format != "" && format != FormatJson && format != FormatCsv {
+		err = &errortypes.ParseError{
+			errors.Newf("handler: Unknown format '%s'", format),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	entries, err := audit.Get(from, to)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if format == FormatCsv {
+		buf := &bytes.Buffer{}
+		err = audit.WriteCsv(buf, entries)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		c.Header("Content-Disposition",
+			"attachment; filename=\"pritunl-audit.csv\"")
+		c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	c.JSON(200, entries)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func breakersGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	c.JSON(200, profile.GetRemoteBreakers(prflId))
+}
+
+func breakersDelete(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	profile.ClearRemoteBreakers(prflId)
+
+	c.JSON(200, nil)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/container"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/pipe"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/posture"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/secrets"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/update"
+	"github.com/pritunl/pritunl-client-electron/service/upgrade"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type capabilitiesData struct {
+	Version     string            `json:"version"`
+	ApiVersions []int             `json:"api_versions"`
+	Arch        string            `json:"arch"`
+	NativeArch  string            `json:"native_arch"`
+	Emulated    bool              `json:"emulated"`
+	Features    map[string]bool   `json:"features"`
+	Container   string            `json:"container"`
+	Paths       map[string]string `json:"paths"`
+}
+
+type UiIncompatible struct {
+	UiVersion      string `json:"ui_version"`
+	UiApiVersion   string `json:"ui_api_version"`
+	ServiceVersion string `json:"service_version"`
+	Reason         string `json:"reason"`
+}
+
+func (u *UiIncompatible) MessageParams() message.Params {
+	return message.Params{
+		"ui_version":      u.UiVersion,
+		"ui_api_version":  u.UiApiVersion,
+		"service_version": u.ServiceVersion,
+		"reason":          u.Reason,
+	}
+}
+
+func GetFeatures() (features map[string]bool) {
+	// Fallback resolvers replace the tunnel DNS servers after connecting
+	dnsFallback := len(profile.GetDnsFallbackServers()) > 0 &&
+		netconf.Get().SupportsDnsServers()
+
+	features = map[string]bool{
+		"wg":                 profile.GetWgPath() != "",
+		"split_dns":          true,
+		"dns_benchmark":      !config.Config.DisableDnsBenchmark,
+		"dns_check":          true,
+		"dns_fallback":       dnsFallback,
+		"hooks":              true,
+		"keychain":           secrets.Available(),
+		"pause":              true,
+		"captive_portal":     !config.Config.DisableCaptivePortal,
+		"container":          container.Detected(),
+		"network_watch":      !config.Config.DisableNetworkWatch,
+		"network_restore":    true,
+		"diagnostics":        config.Config.DiagnosticsSnapshots > 0,
+		"route_guard":        !config.Config.DisableRouteGuard,
+		"native_routes":      !config.Config.RouteCommands,
+		"host_failover":      true,
+		"remote_breakers":    true,
+		"profile_expiry":     true,
+		"route_summarize":    true,
+		"restart_triggers":   true,
+		"watch_rules":        true,
+		"feature_flags":      true,
+		"shared_credentials": true,
+		"otp_autofill":       secrets.Available(),
+		"api_tokens":         true,
+		"metrics":            true,
+		"grpc":               true,
+		"audit_log":          true,
+		"kill_switch":        true,
+		"reload":             true,
+		"startup_queue":      true,
+		"upgrade":            upgrade.Supported,
+		"auto_update":        update.AutoEnabled(),
+		"syslog":             config.Config.SyslogSink != "",
+		"support_session":    !config.Config.DisableSupport,
+		"posture":            posture.Enabled(),
+		"named_pipe":         pipe.Supported,
+		"tuntap_reinstall":   runtime.GOOS == "windows",
+		"inventory":          true,
+		"mtu_probe":          true,
+		"degraded_check":     !config.Config.DisableDegradedCheck,
+		"workspaces":         true,
+		"aes_acceleration":   utils.HasAesAcceleration(),
+	}
+
+	return
+}
+
+// State written by the service, containers must persist these paths to
+// keep profiles and settings across restarts
+func GetPaths() (paths map[string]string) {
+	paths = map[string]string{
+		"config":     config.GetPath(),
+		"profiles":   sprofile.GetPath(),
+		"credential": credential.GetSharedPath(),
+		"killswitch": killswitch.GetStatePath(),
+		"temp":       utils.GetTempPath(),
+		"log":        utils.GetLogPath(),
+	}
+
+	return
+}
+
+func versionPrefix(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// UI and service are compatible when the major and minor versions match
+// and the UI API version is supported, clients that do not declare a
+// version are not checked
+func checkUiVersion(r *http.Request) (incompat *UiIncompatible) {
+	uiVersion := r.Header.Get("Client-Version")
+	uiApiVersion := r.Header.Get("Client-Api-Version")
+	if uiVersion == "" && uiApiVersion == "" {
+		return
+	}
+
+	reason := ""
+	if uiApiVersion != "" {
+		apiVersion, err := strconv.Atoi(uiApiVersion)
+		supported := false
+		if err == nil {
+			for _, ver := range constants.ApiVersions {
+				if ver == apiVersion {
+					supported = true
+					break
+				}
+			}
+		}
+		if !supported {
+			reason = "api_version"
+		}
+	}
+	if reason == "" && uiVersion != "" &&
+		versionPrefix(uiVersion) != versionPrefix(constants.Version) {
+
+		reason = "version"
+	}
+
+	if reason == "" {
+		return
+	}
+
+	incompat = &UiIncompatible{
+		UiVersion:      uiVersion,
+		UiApiVersion:   uiApiVersion,
+		ServiceVersion: constants.Version,
+		Reason:         reason,
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"ui_version":      incompat.UiVersion,
+		"ui_api_version":  incompat.UiApiVersion,
+		"service_version": incompat.ServiceVersion,
+		"reason":          incompat.Reason,
+	}).Warn("handlers: Connected UI version is incompatible with service")
+
+	evt := &event.Event{
+		Type: "ui_incompatible",
+		Data: incompat,
+	}
+	evt.Init()
+
+	return
+}
+
+func capabilitiesGet(c *gin.Context) {
+	checkUiVersion(c.Request)
+
+	data := &capabilitiesData{
+		Version:     constants.Version,
+		ApiVersions: constants.ApiVersions,
+		Arch:        runtime.GOARCH,
+		NativeArch:  platform.NativeArch(),
+		Emulated:    platform.Emulated(),
+		Features:    GetFeatures(),
+		Container:   container.Runtime(),
+		Paths:       GetPaths(),
+	}
+
+	c.JSON(200, data)
+}
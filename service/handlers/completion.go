@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type completionData struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+func completionGet(c *gin.Context) {
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	prfls := profile.GetProfiles()
+	data := []*completionData{}
+
+	for _, sprfl := range sprfls {
+		actions := []string{"logs", "remove"}
+
+		if prfls[sprfl.Id] != nil {
+			actions = append(actions, "stop")
+		} else {
+			actions = append(actions, "start")
+		}
+
+		if sprfl.Disabled {
+			actions = append(actions, "enable")
+		} else {
+			actions = append(actions, "disable")
+		}
+
+		sort.Strings(actions)
+
+		data = append(data, &completionData{
+			Id:      sprfl.Id,
+			Name:    sprfl.Name,
+			Actions: actions,
+		})
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Id < data[j].Id
+	})
+
+	c.JSON(200, data)
+}
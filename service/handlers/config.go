@@ -1,33 +1,115 @@
 package handlers
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/pipe"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/update"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
 type configData struct {
-	DisableDnsWatch   bool `json:"disable_dns_watch"`
-	DisableDnsRefresh bool `json:"disable_dns_refresh"`
-	DisableWakeWatch  bool `json:"disable_wake_watch"`
-	DisableNetClean   bool `json:"disable_net_clean"`
-	EnableWgDns       bool `json:"enable_wg_dns"`
-	InterfaceMetric   int  `json:"interface_metric"`
+	DisableDnsWatch      bool     `json:"disable_dns_watch"`
+	DisableDnsRefresh    bool     `json:"disable_dns_refresh"`
+	DisableWakeWatch     bool     `json:"disable_wake_watch"`
+	DisableCleanAdapters bool     `json:"disable_clean_adapters"`
+	DisableCleanRoutes   bool     `json:"disable_clean_routes"`
+	DisableCleanDns      bool     `json:"disable_clean_dns"`
+	DisableCleanFirewall bool     `json:"disable_clean_firewall"`
+	DisableCleanIpv6     bool     `json:"disable_clean_ipv6"`
+	EnableWgDns          bool     `json:"enable_wg_dns"`
+	InterfaceMetric      int      `json:"interface_metric"`
+	StopDuplicateLogin   bool     `json:"stop_duplicate_login"`
+	HttpProxy            string   `json:"http_proxy"`
+	SocksProxy           string   `json:"socks_proxy"`
+	ProxyNegotiate       bool     `json:"proxy_negotiate"`
+	AutoUpdate           bool     `json:"auto_update"`
+	UpdateChannel        string   `json:"update_channel"`
+	Enforced             []string `json:"enforced"`
 }
 
-func configGet(c *gin.Context) {
-	data := &configData{
-		DisableDnsWatch:   config.Config.DisableDnsWatch,
-		DisableDnsRefresh: config.Config.DisableDnsRefresh,
-		DisableWakeWatch:  config.Config.DisableWakeWatch,
-		DisableNetClean:   config.Config.DisableNetClean,
-		EnableWgDns:       config.Config.EnableWgDns,
-		InterfaceMetric:   config.Config.InterfaceMetric,
+func newConfigData() (data *configData) {
+	data = &configData{
+		DisableDnsWatch:      config.Config.DisableDnsWatch,
+		DisableDnsRefresh:    config.Config.DisableDnsRefresh,
+		DisableWakeWatch:     config.Config.DisableWakeWatch,
+		DisableCleanAdapters: config.Config.DisableCleanAdapters,
+		DisableCleanRoutes:   config.Config.DisableCleanRoutes,
+		DisableCleanDns:      config.Config.DisableCleanDns,
+		DisableCleanFirewall: config.Config.DisableCleanFirewall,
+		DisableCleanIpv6:     config.Config.DisableCleanIpv6,
+		EnableWgDns:          config.Config.EnableWgDns,
+		InterfaceMetric:      config.Config.InterfaceMetric,
+		StopDuplicateLogin:   config.Config.StopDuplicateLogin,
+		HttpProxy:            config.Config.HttpProxy,
+		SocksProxy:           config.Config.SocksProxy,
+		ProxyNegotiate:       config.Config.ProxyNegotiate,
+		AutoUpdate:           config.Config.AutoUpdate,
+		UpdateChannel:        config.Config.UpdateChannel,
+		Enforced:             config.GetEnforced(),
 	}
 
-	c.JSON(200, data)
+	return
+}
+
+// Settings enforced by an administrator can only be changed by the
+// managed configuration
+func checkEnforced(cur, data *configData) (key string, err error) {
+	enforced := config.GetEnforced()
+	if len(enforced) == 0 {
+		return
+	}
+
+	curRaw, _ := json.Marshal(cur)
+	dataRaw, _ := json.Marshal(data)
+
+	curMap := map[string]json.RawMessage{}
+	dataMap := map[string]json.RawMessage{}
+	_ = json.Unmarshal(curRaw, &curMap)
+	_ = json.Unmarshal(dataRaw, &dataMap)
+
+	for _, enforcedKey := range enforced {
+		curVal, ok := curMap[enforcedKey]
+		if !ok {
+			continue
+		}
+
+		if string(curVal) != string(dataMap[enforcedKey]) {
+			key = enforcedKey
+			err = &errortypes.PolicyError{
+				errors.Newf("handler: Setting '%s' enforced by policy", key),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// Proxy passwords are not returned to clients
+func newConfigDataClient() (data *configData) {
+	data = newConfigData()
+	data.HttpProxy = proxy.Redact(data.HttpProxy)
+	data.SocksProxy = proxy.Redact(data.SocksProxy)
+	return
+}
+
+func configGet(c *gin.Context) {
+	c.JSON(200, newConfigDataClient())
 }
 
 func configPut(c *gin.Context) {
@@ -42,12 +124,60 @@ func configPut(c *gin.Context) {
 		return
 	}
 
+	data.HttpProxy = proxy.Unredact(data.HttpProxy, config.Config.HttpProxy)
+	data.SocksProxy = proxy.Unredact(data.SocksProxy,
+		config.Config.SocksProxy)
+
+	key, err := checkEnforced(newConfigData(), data)
+	if err != nil {
+		utils.AbortWithErrorMessage(c, 403, err, message.New(
+			message.PolicyEnforced, message.Params{
+				"key": key,
+			},
+		))
+		return
+	}
+
+	if data.HttpProxy != "" {
+		_, err = proxy.Parse(data.HttpProxy, false)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+	if data.SocksProxy != "" {
+		_, err = proxy.Parse(data.SocksProxy, true)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+
+	if !update.ValidChannel(data.UpdateChannel) {
+		err = &errortypes.ParseError{
+			errors.Newf("handler: Invalid update channel '%s'",
+				data.UpdateChannel),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
 	config.Config.DisableDnsWatch = data.DisableDnsWatch
 	config.Config.DisableDnsRefresh = data.DisableDnsRefresh
 	config.Config.DisableWakeWatch = data.DisableWakeWatch
-	config.Config.DisableNetClean = data.DisableNetClean
+	config.Config.DisableCleanAdapters = data.DisableCleanAdapters
+	config.Config.DisableCleanRoutes = data.DisableCleanRoutes
+	config.Config.DisableCleanDns = data.DisableCleanDns
+	config.Config.DisableCleanFirewall = data.DisableCleanFirewall
+	config.Config.DisableCleanIpv6 = data.DisableCleanIpv6
 	config.Config.EnableWgDns = data.EnableWgDns
 	config.Config.InterfaceMetric = data.InterfaceMetric
+	config.Config.StopDuplicateLogin = data.StopDuplicateLogin
+	config.Config.HttpProxy = data.HttpProxy
+	config.Config.SocksProxy = data.SocksProxy
+	config.Config.ProxyNegotiate = data.ProxyNegotiate
+	config.Config.AutoUpdate = data.AutoUpdate
+	config.Config.UpdateChannel = data.UpdateChannel
 
 	err = config.Save()
 	if err != nil {
@@ -55,5 +185,89 @@ func configPut(c *gin.Context) {
 		return
 	}
 
+	c.JSON(200, newConfigDataClient())
+}
+
+type configExportData struct {
+	Version   string                 `json:"version"`
+	Path      string                 `json:"path"`
+	Exists    bool                   `json:"exists"`
+	Enforced  []string               `json:"enforced"`
+	Config    map[string]interface{} `json:"config"`
+	Effective map[string]interface{} `json:"effective"`
+	Features  map[string]bool        `json:"features"`
+}
+
+// Values used by the service after defaults and environment overrides are
+// applied to the configuration
+func getEffectiveConfig() (effective map[string]interface{}) {
+	tempDir, _ := utils.GetTempDir()
+
+	wgMode := config.Config.WireguardMode
+	if wgMode == "" {
+		wgMode = profile.WgModeKernel
+	}
+
+	logFormat := config.Config.LogFormat
+	if logFormat == "" {
+		logFormat = "plain"
+	}
+
+	effective = map[string]interface{}{
+		"development":       constants.Development,
+		"temp_dir":          tempDir,
+		"profiles_dir":      filepath.Dir(sprofile.GetPath()),
+		"log_path":          utils.GetLogPath(),
+		"log_format":        logFormat,
+		"log_max_size":      logger.GetProfileMaxSize(),
+		"log_max_age":       logger.GetProfileMaxAge().String(),
+		"log_retention":     logger.GetProfileRetention(),
+		"dns_timeout":       profile.GetDnsTimeout().String(),
+		"portal_probe_urls": network.GetPortalProbeUrls(),
+		"portal_max_wait":   profile.GetPortalMaxWait().String(),
+		"wireguard_mode":    wgMode,
+		"wireguard_path":    profile.GetWgPath(),
+		"wg_userspace_impl": os.Getenv("WG_QUICK_USERSPACE_IMPLEMENTATION"),
+		"http_proxy":        "",
+		"socks_proxy":       "",
+	}
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		effective["api_socket"] = config.Config.ApiSocketPath()
+	} else if !config.Config.DisableApiPort || !pipe.Supported {
+		effective["api_address"] = config.Config.ApiListenAddr()
+	}
+
+	prxy, err := proxy.Get("", "")
+	if err == nil && prxy != nil {
+		key := "http_proxy"
+		if prxy.Scheme == "socks5" {
+			key = "socks_proxy"
+		}
+		effective[key] = prxy.Redacted()
+	}
+
+	return
+}
+
+func configExportGet(c *gin.Context) {
+	conf, err := config.Config.Export()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	exists, _ := utils.ExistsFile(config.GetPath())
+
+	data := &configExportData{
+		Version:   constants.Version,
+		Path:      config.GetPath(),
+		Exists:    exists,
+		Enforced:  config.GetEnforced(),
+		Config:    conf,
+		Effective: getEffectiveConfig(),
+		Features:  GetFeatures(),
+	}
+
 	c.JSON(200, data)
 }
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func credentialDelete(c *gin.Context) {
+	credential.ClearAll()
+
+	c.JSON(200, nil)
+}
+
+func credentialDelete2(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	credential.Clear(prflId)
+
+	c.JSON(200, nil)
+}
+
+type sharedCredentialData struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	OtpSeed  string `json:"otp_seed"`
+}
+
+func sharedCredentialsGet(c *gin.Context) {
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
+	infos, err := credential.GetSharedAll(wsId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, infos)
+}
+
+func sharedCredentialPut(c *gin.Context) {
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
+	credId := utils.FilterStr(c.Param("credential_id"))
+	if credId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid credential ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data := &sharedCredentialData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	curShared, err := credential.GetShared(credId)
+	if err == nil && curShared.Workspace != wsId {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	shared := &credential.Shared{
+		Id:        credId,
+		Name:      data.Name,
+		Workspace: wsId,
+		Username:  data.Username,
+		Password:  data.Password,
+		OtpSeed:   data.OtpSeed,
+	}
+
+	err = shared.Commit()
+	if err != nil {
+		if _, ok := err.(*errortypes.ParseError); ok {
+			utils.AbortWithError(c, 400, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, shared.Info())
+}
+
+func sharedCredentialDelete(c *gin.Context) {
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
+	credId := utils.FilterStr(c.Param("credential_id"))
+	if credId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid credential ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	shared, err := credential.GetShared(credId)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			utils.AbortWithStatus(c, 404)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if shared.Workspace != wsId {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	err = credential.RemoveShared(credId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
+
+type otpSeedData struct {
+	OtpSeed string `json:"otp_seed"`
+}
+
+type otpSeedInfo struct {
+	Id         string `json:"id"`
+	HasOtpSeed bool   `json:"has_otp_seed"`
+}
+
+func otpSeedGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	c.JSON(200, &otpSeedInfo{
+		Id:         prflId,
+		HasOtpSeed: credential.HasOtpSeed(prflId),
+	})
+}
+
+func otpSeedPut(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	data := &otpSeedData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	err = credential.SetOtpSeed(prflId, data.OtpSeed)
+	if err != nil {
+		if _, ok := err.(*errortypes.ParseError); ok {
+			utils.AbortWithError(c, 400, err)
+		} else if _, ok := err.(*errortypes.PreconditionError); ok {
+			utils.AbortWithError(c, 412, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, &otpSeedInfo{
+		Id:         prflId,
+		HasOtpSeed: true,
+	})
+}
+
+func otpSeedDelete(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	err := credential.RemoveOtpSeed(prflId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
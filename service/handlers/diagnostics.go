@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/diag"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func diagnosticsGet(c *gin.Context) {
+	snapshots, err := diag.GetAll()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, snapshots)
+}
+
+func diagnosticsDel(c *gin.Context) {
+	err := diag.Clear()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type splitDnsData struct {
+	Domains []string `json:"domains"`
+	Active  []string `json:"active,omitempty"`
+}
+
+func splitDnsGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	data := &splitDnsData{
+		Domains: []string{},
+	}
+
+	prfl := profile.GetProfile(prflId)
+	if prfl != nil {
+		data.Domains, data.Active = prfl.GetSplitDns()
+	} else {
+		sprfl := sprofile.Get(prflId)
+		if sprfl == nil {
+			utils.AbortWithStatus(c, 404)
+			return
+		}
+		data.Domains = sprfl.SplitDnsDomains
+	}
+
+	if data.Domains == nil {
+		data.Domains = []string{}
+	}
+
+	c.JSON(200, data)
+}
+
+func splitDnsPut(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	data := &splitDnsData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data.Domains = utils.FilterDomains(data.Domains)
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	prfl := profile.GetProfile(prflId)
+	if sprfl == nil && prfl == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	if sprfl != nil {
+		err = sprofile.SetSplitDnsDomains(prflId, data.Domains)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	if prfl != nil {
+		prfl.UpdateSplitDns(data.Domains)
+	}
+
+	c.JSON(200, data)
+}
+
+func dnsGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	prfl := profile.GetProfile(prflId)
+	if prfl == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, prfl.GetDnsSnapshot())
+}
@@ -34,6 +34,18 @@ var (
 func eventsGet(c *gin.Context) {
 	event.LastPong = time.Now()
 
+	types := map[string]bool{}
+	for _, typ := range utils.ParseFields(c.Query("types")) {
+		types[typ] = true
+	}
+
+	// Events of other workspaces are filtered when a workspace is
+	// requested, events without a workspace are always sent
+	wsId := utils.FilterStr(c.Param("workspace_id"))
+	if wsId == "" {
+		wsId = utils.FilterStr(c.Query("workspace"))
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -49,6 +61,9 @@ func eventsGet(c *gin.Context) {
 
 	list := event.NewListener()
 
+	// Checked after listening so the connecting UI receives the warning
+	checkUiVersion(c.Request)
+
 	ticker := time.NewTicker(pingInterval)
 
 	defer func() {
@@ -96,6 +111,14 @@ func eventsGet(c *gin.Context) {
 				return
 			}
 
+			if len(types) > 0 && !types[evt.Type] {
+				continue
+			}
+
+			if wsId != "" && evt.Workspace != "" && evt.Workspace != wsId {
+				continue
+			}
+
 			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
 			err = conn.WriteJSON(evt)
 			if err != nil {
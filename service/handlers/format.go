@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	FormatJson       = "json"
+	FormatPrometheus = "prometheus"
+	FormatPlain      = "plain"
+	FormatCsv        = "csv"
+
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type formatTable struct {
+	buf *bytes.Buffer
+	tw  *tabwriter.Writer
+}
+
+func newFormatTable(headers ...string) (t *formatTable) {
+	t = &formatTable{
+		buf: &bytes.Buffer{},
+	}
+	t.tw = tabwriter.NewWriter(t.buf, 0, 0, 2, ' ', 0)
+	if len(headers) > 0 {
+		fmt.Fprintln(t.tw, strings.Join(headers, "\t"))
+	}
+	return
+}
+
+func (t *formatTable) Row(values ...interface{}) {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = fmt.Sprint(value)
+		if strs[i] == "" {
+			strs[i] = "-"
+		}
+	}
+	fmt.Fprintln(t.tw, strings.Join(strs, "\t"))
+}
+
+// Blank line separating tables with different columns
+func (t *formatTable) Section(headers ...string) {
+	_ = t.tw.Flush()
+	t.buf.WriteString("\n")
+	t.tw = tabwriter.NewWriter(t.buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(t.tw, strings.Join(headers, "\t"))
+}
+
+func (t *formatTable) Bytes() []byte {
+	_ = t.tw.Flush()
+	return t.buf.Bytes()
+}
+
+// Respond in the format requested with the format query parameter, json
+// when not set
+func writeFormat(c *gin.Context, data interface{},
+	prometheus func(w *metricsWriter), plain func() *formatTable) {
+
+	format := strings.ToLower(c.Query("format"))
+
+	switch format {
+	case "", FormatJson:
+		c.JSON(200, data)
+		break
+	case FormatPrometheus:
+		w := &metricsWriter{
+			buf: &bytes.Buffer{},
+		}
+		prometheus(w)
+		c.Data(200, metricsContentType, w.buf.Bytes())
+		break
+	case FormatPlain:
+		c.Data(200, "text/plain; charset=utf-8", plain().Bytes())
+		break
+	default:
+		err := &errortypes.ParseError{
+			errors.Newf("handler: Unknown format '%s'", format),
+		}
+		utils.AbortWithError(c, 400, err)
+	}
+}
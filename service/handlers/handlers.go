@@ -1,13 +1,13 @@
 package handlers
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"net/http"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,20 +34,18 @@ func Errors(c *gin.Context) {
 }
 
 func Auth(c *gin.Context) {
-	token := c.Request.Header.Get("Auth-Token")
-	if token == "" {
-		token = c.Request.Header.Get("Auth-Key")
-	}
-	if token == "" {
-		token = c.Query("token")
-	}
-
-	if c.Request.Header.Get("Origin") != "" ||
-		c.Request.Header.Get("Referer") != "" ||
-		c.Request.Header.Get("User-Agent") != "pritunl" ||
-		subtle.ConstantTimeCompare([]byte(token), []byte(auth.Key)) != 1 {
+	err := auth.Validate(c.Request)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  c.Request.URL.Path,
+			"error": err,
+		}).Debug("handlers: Request authentication failed")
 
-		c.AbortWithStatus(401)
+		if _, ok := err.(*auth.ScopeError); ok {
+			utils.AbortWithStatus(c, 403)
+		} else {
+			utils.AbortWithStatus(c, 401)
+		}
 		return
 	}
 	c.Next()
@@ -57,34 +55,139 @@ func Register(engine *gin.Engine) {
 	engine.Use(Auth)
 	engine.Use(Recovery)
 	engine.Use(Errors)
+	engine.Use(Startup)
 
 	engine.GET("/events", eventsGet)
 	engine.GET("/config", configGet)
 	engine.PUT("/config", configPut)
+	engine.GET("/config/export", configExportGet)
 	engine.POST("/network/reset_dns", networkDnsReset)
 	engine.POST("/network/reset_all", networkAllReset)
+	engine.POST("/network/dnscheck", networkDnsCheckPost)
+	engine.GET("/network/restore", networkRestoreGet)
+	engine.POST("/network/restore", networkRestorePost)
+	engine.GET("/network/nat", networkNatGet)
+	engine.GET("/posture", postureGet)
+	engine.GET("/network/clean", networkCleanGet)
+	engine.GET("/diagnostics", diagnosticsGet)
+	engine.DELETE("/diagnostics", diagnosticsDel)
+	engine.GET("/system/adapters", systemAdaptersGet)
+	engine.GET("/system/inventory", systemInventoryGet)
+	engine.POST("/system/adapters/repair", systemAdaptersRepairPost)
+	engine.POST("/system/tuntap/reinstall", systemTuntapReinstallPost)
 	engine.GET("/profile", profileGet)
 	engine.POST("/profile", profilePost)
 	engine.DELETE("/profile", profileDel)
 	engine.DELETE("/profile/:profile_id", profileDel2)
+	engine.POST("/profile/:profile_id/clone", sprofileClonePost)
+	engine.GET("/profile/:profile_id/split_dns", splitDnsGet)
+	engine.PUT("/profile/:profile_id/split_dns", splitDnsPut)
+	engine.GET("/profile/:profile_id/dns", dnsGet)
+	engine.PUT("/profile/:profile_id/verbosity", verbosityPut)
+	engine.GET("/profile/:profile_id/pause", pauseGet)
+	engine.POST("/profile/:profile_id/pause", pausePost)
+	engine.DELETE("/profile/:profile_id/pause", pauseDelete)
+	engine.GET("/profile/:profile_id/stats", profileStatsGet)
+	engine.GET("/profile/:profile_id/log", profileLogGet)
+	engine.GET("/profile/:profile_id/breakers", breakersGet)
+	engine.DELETE("/profile/:profile_id/breakers", breakersDelete)
+	engine.GET("/kill_switch", killSwitchGet)
+	engine.DELETE("/kill_switch/:profile_id", killSwitchDelete)
 	engine.GET("/sprofile", sprofilesGet)
 	engine.PUT("/sprofile", sprofilePut)
 	engine.DELETE("/sprofile", sprofileDel)
 	engine.DELETE("/sprofile/:profile_id", sprofileDel2)
+	engine.GET("/sprofile/quarantine", sprofileQuarantineGet)
+	engine.GET("/managed", managedGet)
 	// TODO classic client
 	engine.GET("/sprofile/:profile_id/log", sprofileLogGet)
 	// TODO classic client
 	engine.DELETE("/sprofile/:profile_id/log", sprofileLogDel)
 	engine.GET("/log/:log_id", logGet)
 	engine.DELETE("/log/:log_id", logDel)
+	engine.GET("/api_token", apiTokenGet)
+	engine.POST("/api_token", apiTokenPost)
+	engine.DELETE("/api_token/:token_id", apiTokenDelete)
+	engine.GET("/workspace", workspaceGet)
+	engine.POST("/workspace", workspacePost)
+	engine.DELETE("/workspace/:workspace_id", workspaceDelete)
+	engine.GET("/workspace/:workspace_id/events", eventsGet)
+	engine.GET("/workspace/:workspace_id/sprofile", sprofilesGet)
+	engine.PUT("/workspace/:workspace_id/sprofile", sprofilePut)
+	engine.DELETE("/workspace/:workspace_id/sprofile", sprofileDel)
+	engine.DELETE("/workspace/:workspace_id/sprofile/:profile_id",
+		sprofileDel2)
+	engine.GET("/workspace/:workspace_id/sprofile/:profile_id/log",
+		sprofileLogGet)
+	engine.DELETE("/workspace/:workspace_id/sprofile/:profile_id/log",
+		sprofileLogDel)
+	engine.GET("/workspace/:workspace_id/profile", profileGet)
+	engine.POST("/workspace/:workspace_id/profile", profilePost)
+	engine.DELETE("/workspace/:workspace_id/profile", profileDel)
+	engine.DELETE("/workspace/:workspace_id/profile/:profile_id",
+		profileDel2)
+	engine.POST("/workspace/:workspace_id/profile/:profile_id/clone",
+		sprofileClonePost)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/split_dns",
+		splitDnsGet)
+	engine.PUT("/workspace/:workspace_id/profile/:profile_id/split_dns",
+		splitDnsPut)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/dns", dnsGet)
+	engine.PUT("/workspace/:workspace_id/profile/:profile_id/verbosity",
+		verbosityPut)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/pause",
+		pauseGet)
+	engine.POST("/workspace/:workspace_id/profile/:profile_id/pause",
+		pausePost)
+	engine.DELETE("/workspace/:workspace_id/profile/:profile_id/pause",
+		pauseDelete)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/stats",
+		profileStatsGet)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/log",
+		profileLogGet)
+	engine.GET("/workspace/:workspace_id/profile/:profile_id/breakers",
+		breakersGet)
+	engine.DELETE("/workspace/:workspace_id/profile/:profile_id/breakers",
+		breakersDelete)
+	engine.GET("/workspace/:workspace_id/shared_credential",
+		sharedCredentialsGet)
+	engine.PUT("/workspace/:workspace_id/shared_credential/:credential_id",
+		sharedCredentialPut)
+	engine.DELETE(
+		"/workspace/:workspace_id/shared_credential/:credential_id",
+		sharedCredentialDelete)
+	engine.GET("/watch_rule", watchRuleGet)
+	engine.POST("/watch_rule", watchRulePost)
+	engine.DELETE("/watch_rule/:rule_id", watchRuleDelete)
 	engine.PUT("/token", tokenPut)
 	engine.DELETE("/token", tokenDelete)
 	engine.DELETE("/token/:profile_id", tokenDelete2)
+	engine.DELETE("/credential", credentialDelete)
+	engine.DELETE("/credential/:profile_id", credentialDelete2)
+	engine.GET("/shared_credential", sharedCredentialsGet)
+	engine.PUT("/shared_credential/:credential_id", sharedCredentialPut)
+	engine.DELETE("/shared_credential/:credential_id",
+		sharedCredentialDelete)
+	engine.GET("/otp_seed/:profile_id", otpSeedGet)
+	engine.PUT("/otp_seed/:profile_id", otpSeedPut)
+	engine.DELETE("/otp_seed/:profile_id", otpSeedDelete)
 	engine.POST("/tpm/callback", tpmCallbackPost)
 	engine.GET("/ping", pingGet)
+	engine.GET("/health", healthGet)
+	engine.GET("/metrics", metricsGet)
+	engine.GET("/history", historyGet)
+	engine.GET("/audit", auditGet)
+	engine.GET("/support_session", supportSessionGet)
+	engine.POST("/support_session", supportSessionPost)
+	engine.DELETE("/support_session", supportSessionDelete)
 	engine.POST("/stop", stopPost)
 	engine.POST("/restart", restartPost)
+	engine.POST("/reload", reloadPost)
+	engine.POST("/upgrade", upgradePost)
 	engine.GET("/status", statusGet)
+	engine.GET("/summary", summaryGet)
+	engine.GET("/completion", completionGet)
 	engine.GET("/state", stateGet)
+	engine.GET("/capabilities", capabilitiesGet)
 	engine.POST("/wakeup", wakeupPost)
 }
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/usage"
+)
+
+type healthData struct {
+	*usage.Usage
+	Routines map[string]map[string]int `json:"routines"`
+	Nat      *network.NatResult        `json:"nat"`
+}
+
+func healthGet(c *gin.Context) {
+	data := &healthData{
+		Usage:    usage.Get(),
+		Routines: profile.GetRoutines(),
+		Nat:      network.GetNat(),
+	}
+
+	c.JSON(200, data)
+}
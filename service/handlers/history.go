@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func historyGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Query("profile_id"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 500
+	}
+
+	transs, err := stats.GetHistory(prflId, limit)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, transs)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func killSwitchGet(c *gin.Context) {
+	c.JSON(200, killswitch.GetRules())
+}
+
+func killSwitchDelete(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	if !killswitch.Active(prflId) {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	err := killswitch.Disable(prflId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, nil)
+}
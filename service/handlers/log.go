@@ -48,6 +48,10 @@ func logDel(c *gin.Context) {
 		return
 	}
 
+	if logId != "service" && sprofileReadOnly(c, logId) {
+		return
+	}
+
 	if logId == "service" {
 		err := utils.ClearServiceLog()
 		if err != nil {
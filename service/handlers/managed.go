@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func managedGet(c *gin.Context) {
+	mngd, err := sprofile.LoadManaged()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if mngd == nil {
+		mngd = &sprofile.Managed{
+			Profiles: []*sprofile.Sprofile{},
+		}
+	}
+
+	c.JSON(200, mngd)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+)
+
+var metricsEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricsWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w *metricsWriter) Header(name, typ, help string) {
+	fmt.Fprintf(w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *metricsWriter) Value(name string, labels []string,
+	value interface{}) {
+
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteString(",")
+			}
+			fmt.Fprintf(w.buf, `%s="%s"`, labels[i],
+				metricsEscape.Replace(labels[i+1]))
+		}
+		w.buf.WriteString("}")
+	}
+	fmt.Fprintf(w.buf, " %v\n", value)
+}
+
+func metricsGet(c *gin.Context) {
+	w := &metricsWriter{
+		buf: &bytes.Buffer{},
+	}
+
+	w.Header("pritunl_client_info", "gauge", "Service information")
+	w.Value("pritunl_client_info", []string{
+		"version", constants.Version,
+		"os", runtime.GOOS,
+	}, 1)
+
+	dnsResets, netResets := netconf.GetResetCounts()
+	w.Header("pritunl_client_dns_resets_total", "counter",
+		"DNS resets performed")
+	w.Value("pritunl_client_dns_resets_total", nil, dnsResets)
+	w.Header("pritunl_client_network_resets_total", "counter",
+		"Networking resets performed")
+	w.Value("pritunl_client_network_resets_total", nil, netResets)
+
+	prfls := profile.GetProfiles()
+	prflIds := []string{}
+	for prflId := range prfls {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	w.Header("pritunl_client_profile_connected", "gauge",
+		"Profile connection state")
+	for _, prflId := range prflIds {
+		prfl := prfls[prflId]
+		connected := 0
+		if prfl.Status == "connected" {
+			connected = 1
+		}
+		w.Value("pritunl_client_profile_connected", []string{
+			"profile_id", prfl.Id,
+			"name", prfl.Name,
+			"mode", prfl.Mode,
+		}, connected)
+	}
+
+	w.Header("pritunl_client_profile_degraded", "gauge",
+		"Profile connected without traffic passing")
+	for _, prflId := range prflIds {
+		degraded := 0
+		if prfls[prflId].Degraded {
+			degraded = 1
+		}
+		w.Value("pritunl_client_profile_degraded",
+			[]string{"profile_id", prflId}, degraded)
+	}
+
+	rxs := map[string]int64{}
+	txs := map[string]int64{}
+	for _, prflId := range prflIds {
+		rxs[prflId], txs[prflId] = prfls[prflId].GetTransfer()
+	}
+
+	w.Header("pritunl_client_profile_received_bytes", "gauge",
+		"Bytes received on the current connection")
+	for _, prflId := range prflIds {
+		w.Value("pritunl_client_profile_received_bytes",
+			[]string{"profile_id", prflId}, rxs[prflId])
+	}
+
+	w.Header("pritunl_client_profile_sent_bytes", "gauge",
+		"Bytes sent on the current connection")
+	for _, prflId := range prflIds {
+		w.Value("pritunl_client_profile_sent_bytes",
+			[]string{"profile_id", prflId}, txs[prflId])
+	}
+
+	w.Header("pritunl_client_profile_uptime_seconds", "gauge",
+		"Seconds since the profile connected")
+	for _, prflId := range prflIds {
+		prfl := prfls[prflId]
+		uptime := int64(0)
+		if prfl.Status == "connected" && prfl.Timestamp != 0 {
+			uptime = time.Now().Unix() - prfl.Timestamp
+		}
+		w.Value("pritunl_client_profile_uptime_seconds",
+			[]string{"profile_id", prflId}, uptime)
+	}
+
+	w.Header("pritunl_client_profile_connect_latency_seconds", "gauge",
+		"Seconds taken to establish the current connection")
+	for _, prflId := range prflIds {
+		w.Value("pritunl_client_profile_connect_latency_seconds",
+			[]string{"profile_id", prflId},
+			prfls[prflId].GetConnectLatency().Seconds())
+	}
+
+	w.Header("pritunl_client_profile_handshake_age_seconds", "gauge",
+		"Seconds since the last WireGuard handshake")
+	for _, prflId := range prflIds {
+		prfl := prfls[prflId]
+		if prfl.Mode != profile.Wg {
+			continue
+		}
+		w.Value("pritunl_client_profile_handshake_age_seconds",
+			[]string{"profile_id", prflId},
+			int64(prfl.GetHandshakeAge().Seconds()))
+	}
+
+	cntrs := stats.GetCounters()
+	cntrIds := []string{}
+	for prflId := range cntrs {
+		cntrIds = append(cntrIds, prflId)
+	}
+	sort.Strings(cntrIds)
+
+	w.Header("pritunl_client_profile_connects_total", "counter",
+		"Connections established since the service started")
+	for _, prflId := range cntrIds {
+		w.Value("pritunl_client_profile_connects_total",
+			[]string{"profile_id", prflId}, cntrs[prflId].Connects)
+	}
+
+	w.Header("pritunl_client_profile_reconnects_total", "counter",
+		"Reconnects since the service started")
+	for _, prflId := range cntrIds {
+		w.Value("pritunl_client_profile_reconnects_total",
+			[]string{"profile_id", prflId}, cntrs[prflId].Reconnects)
+	}
+
+	c.Data(200, metricsContentType, w.buf.Bytes())
+}
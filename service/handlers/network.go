@@ -1,25 +1,219 @@
 package handlers
 
 import (
+	"sort"
+
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/netclean"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/netsnap"
+	"github.com/pritunl/pritunl-client-electron/service/network"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	resetDnsAction = "network_reset_dns"
+	resetAllAction = "network_reset_all"
+	restoreAction  = "network_restore"
 )
 
+type networkResetData struct {
+	DryRun       bool     `json:"dry_run"`
+	Actions      []string `json:"actions"`
+	Profiles     []string `json:"profiles"`
+	ConfirmToken string   `json:"confirm_token,omitempty"`
+}
+
+// Destructive requests are performed in two steps, a dry run reports the
+// changes and returns a token that must be provided to perform them
+func networkResetConfirm(c *gin.Context, action string,
+	data *networkResetData) (ok bool) {
+
+	requester := auth.GetRequester(c.Request)
+	fields := logrus.Fields{
+		"action":     action,
+		"transport":  requester.Transport,
+		"address":    requester.Address,
+		"user_agent": requester.UserAgent,
+		"uid":        requester.Uid,
+		"pid":        requester.Pid,
+	}
+
+	if c.Query("dry_run") == "true" {
+		token, err := auth.NewConfirmToken(action)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		data.DryRun = true
+		data.ConfirmToken = token
+
+		logrus.WithFields(fields).Info("handlers: Network reset dry run requested")
+
+		c.JSON(200, data)
+		return
+	}
+
+	if !auth.CheckConfirmToken(action, c.Query("confirm")) {
+		logrus.WithFields(fields).Warn("handlers: Network reset rejected without confirmation")
+
+		c.JSON(428, utils.NewErrorData(message.New(
+			message.ConfirmationRequired, message.Params{
+				"action": action,
+			},
+		)))
+		return
+	}
+
+	fields["actions"] = data.Actions
+	fields["profiles"] = data.Profiles
+	logrus.WithFields(fields).Warn("handlers: Network reset confirmed")
+
+	ok = true
+	return
+}
+
 func networkDnsReset(c *gin.Context) {
-	utils.ResetDns()
-	utils.ClearDNSCache()
+	data := &networkResetData{
+		Actions:  netconf.Get().DescribeReset(false),
+		Profiles: []string{},
+	}
+
+	if !networkResetConfirm(c, resetDnsAction, data) {
+		return
+	}
 
-	c.JSON(200, nil)
+	_, _ = netsnap.Capture(netsnap.ReasonResetDns)
+
+	netconf.Get().ResetDns()
+	netconf.Get().FlushDnsCache()
+
+	c.JSON(200, data)
 }
 
 func networkAllReset(c *gin.Context) {
-	utils.ResetDns()
-	utils.ClearDns()
-	utils.ResetNetworking()
-	utils.ClearDNSCache()
+	data := &networkResetData{
+		Actions:  netconf.Get().DescribeReset(true),
+		Profiles: []string{},
+	}
+
+	for prflId := range profile.GetProfiles() {
+		data.Profiles = append(data.Profiles, prflId)
+	}
+	sort.Strings(data.Profiles)
+
+	if !networkResetConfirm(c, resetAllAction, data) {
+		return
+	}
+
+	_, _ = netsnap.Capture(netsnap.ReasonResetAll)
+
+	netconf.Get().ResetDns()
+	netconf.Get().ClearDns()
+	netconf.Get().ResetNetworking()
+	netconf.Get().FlushDnsCache()
 
 	_ = profile.RestartProfiles(false)
 
-	c.JSON(200, nil)
+	c.JSON(200, data)
+}
+
+func networkRestoreGet(c *gin.Context) {
+	c.JSON(200, netsnap.GetAll())
+}
+
+// Stop the profiles and revert the network state to a snapshot, the
+// newest snapshot is used when the id parameter is not set
+func networkRestorePost(c *gin.Context) {
+	snapId := utils.FilterStr(c.Query("id"))
+
+	snapId, actions, err := netsnap.Describe(snapId)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.NotFoundError:
+			utils.AbortWithError(c, 404, err)
+			break
+		case *errortypes.PreconditionError:
+			utils.AbortWithError(c, 412, err)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	data := &networkResetData{
+		Actions:  actions,
+		Profiles: []string{},
+	}
+
+	prfls := profile.GetProfiles()
+	for prflId := range prfls {
+		data.Profiles = append(data.Profiles, prflId)
+	}
+	sort.Strings(data.Profiles)
+
+	if !networkResetConfirm(c, restoreAction, data) {
+		return
+	}
+
+	for _, prfl := range prfls {
+		prfl.StopBackground()
+	}
+	for _, prfl := range prfls {
+		prfl.Wait()
+		profile.ReleaseKillSwitch(prfl.Id)
+	}
+
+	result, err := netsnap.Restore(snapId)
+	if err != nil {
+		if _, ok := err.(*errortypes.PreconditionError); ok {
+			utils.AbortWithError(c, 412, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+// Verify DNS queries resolve through the tunnel servers of the connected
+// profiles, limited to one profile with the profile_id parameter
+func networkDnsCheckPost(c *gin.Context) {
+	prflId := utils.FilterStr(c.Query("profile_id"))
+
+	if prflId != "" && profile.GetProfile(prflId) == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, profile.CheckDns(prflId))
+}
+
+func networkNatGet(c *gin.Context) {
+	result, err := network.DetectNat()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+// Network configuration removed at service startup
+func networkCleanGet(c *gin.Context) {
+	rpt := netclean.GetReport()
+	if rpt == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, rpt)
 }
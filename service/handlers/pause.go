@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type pauseData struct {
+	Duration int `json:"duration"`
+}
+
+func pauseGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	info := profile.GetPause(prflId)
+	if info == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, info)
+}
+
+// Duration is in minutes, a zero duration pauses until resumed
+func pausePost(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	data := &pauseData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	info, err := profile.Pause(prflId,
+		time.Duration(data.Duration)*time.Minute)
+	if err != nil {
+		switch err.(type) {
+		case *errortypes.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		case *errortypes.ParseError:
+			utils.AbortWithError(c, 400, err)
+			break
+		case *errortypes.PolicyError:
+			utils.AbortWithErrorMessage(c, 403, err,
+				message.New(message.PolicyEnforced, message.Params{
+					"key": "always_on",
+				}))
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, info)
+}
+
+func pauseDelete(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	err := profile.Resume(prflId)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			utils.AbortWithStatus(c, 404)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.JSON(200, nil)
+}
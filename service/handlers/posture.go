@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/posture"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// Posture reported to the server, not found when no checks are enabled
+func postureGet(c *gin.Context) {
+	rpt := posture.Get()
+	if rpt == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	c.JSON(200, rpt)
+}
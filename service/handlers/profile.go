@@ -1,42 +1,112 @@
 package handlers
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/message"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
 
+type precheckErrorData struct {
+	Error          string                     `json:"error"`
+	Params         message.Params             `json:"params,omitempty"`
+	Message        string                     `json:"message"`
+	Failures       []*profile.PrecheckFailure `json:"failures"`
+	RemoteBreakers []*profile.RemoteBreaker   `json:"remote_breakers"`
+}
+
 type profileData struct {
-	Id                 string   `json:"id"`
-	Mode               string   `json:"mode"`
-	OrgId              string   `json:"org_id"`
-	UserId             string   `json:"user_id"`
-	ServerId           string   `json:"server_id"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncToken          string   `json:"sync_token"`
-	SyncSecret         string   `json:"sync_secret"`
-	Data               string   `json:"data"`
-	Username           string   `json:"username"`
-	Password           string   `json:"password"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DeviceAuth         bool     `json:"device_auth"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	DisableDns         bool     `json:"disable_dns"`
-	ForceDns           bool     `json:"force_dns"`
-	SsoAuth            bool     `json:"sso_auth"`
-	ServerPublicKey    string   `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	TokenTtl           int      `json:"token_ttl"`
-	Reconnect          bool     `json:"reconnect"`
-	Timeout            bool     `json:"timeout"`
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Mode               string            `json:"mode"`
+	OrgId              string            `json:"org_id"`
+	UserId             string            `json:"user_id"`
+	ServerId           string            `json:"server_id"`
+	SyncHosts          []string          `json:"sync_hosts"`
+	SyncToken          string            `json:"sync_token"`
+	SyncSecret         string            `json:"sync_secret"`
+	Data               string            `json:"data"`
+	Username           string            `json:"username"`
+	Password           string            `json:"password"`
+	DynamicFirewall    bool              `json:"dynamic_firewall"`
+	DeviceAuth         bool              `json:"device_auth"`
+	DisableGateway     bool              `json:"disable_gateway"`
+	DisableDns         bool              `json:"disable_dns"`
+	ForceDns           bool              `json:"force_dns"`
+	SplitDnsDomains    []string          `json:"split_dns_domains"`
+	Env                map[string]string `json:"env"`
+	Hooks              map[string]string `json:"hooks"`
+	CredentialProvider string            `json:"credential_provider"`
+	CredentialCacheTtl int               `json:"credential_cache_ttl"`
+	RoutePriority      int               `json:"route_priority"`
+	IncludeRoutes      []string          `json:"include_routes"`
+	ExcludeRoutes      []string          `json:"exclude_routes"`
+	PersistTun         bool              `json:"persist_tun"`
+	HttpProxy          string            `json:"http_proxy"`
+	SocksProxy         string            `json:"socks_proxy"`
+	AlwaysOn           bool              `json:"always_on"`
+	KillSwitch         bool              `json:"kill_switch"`
+	CredentialId       string            `json:"credential_id"`
+	HostFailover       bool              `json:"host_failover"`
+	Verbosity          int               `json:"verbosity"`
+	SplitDnsPushed     bool              `json:"split_dns_pushed"`
+	DnsHijackMode      string            `json:"dns_hijack_mode"`
+	DisableIpv6        bool              `json:"disable_ipv6"`
+	SyslogTag          string            `json:"syslog_tag"`
+	WgTcpFallback      bool              `json:"wg_tcp_fallback"`
+	Mtu                int               `json:"mtu"`
+	MssFix             int               `json:"mss_fix"`
+	MtuProbe           bool              `json:"mtu_probe"`
+	SummarizeRoutes    bool              `json:"summarize_routes"`
+	ExpiresAt          int64             `json:"expires_at"`
+	SsoAuth            bool              `json:"sso_auth"`
+	ServerPublicKey    string            `json:"server_public_key"`
+	ServerBoxPublicKey string            `json:"server_box_public_key"`
+	TokenTtl           int               `json:"token_ttl"`
+	Reconnect          bool              `json:"reconnect"`
+	Timeout            bool              `json:"timeout"`
 }
 
 func profileGet(c *gin.Context) {
-	c.JSON(200, profile.GetProfiles())
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
+	prfls := map[string]*profile.Profile{}
+	for prflId, prfl := range profile.GetProfiles() {
+		if prfl.Workspace == wsId {
+			prfls[prflId] = prfl
+		}
+	}
+
+	fields := utils.ParseFields(c.Query("fields"))
+	if len(fields) == 0 {
+		c.JSON(200, prfls)
+		return
+	}
+
+	data := map[string]map[string]interface{}{}
+	for prflId, prfl := range prfls {
+		prflData, err := utils.SelectFields(prfl, fields)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+		data[prflId] = prflData
+	}
+
+	c.JSON(200, data)
 }
 
 func profilePost(c *gin.Context) {
@@ -60,6 +130,13 @@ func profilePost(c *gin.Context) {
 		return
 	}
 
+	wsId, ok := profileWorkspace(c, data.Id)
+	if !ok {
+		return
+	}
+
+	profile.CancelPause(data.Id)
+
 	sprfl := sprofile.Get(data.Id)
 	if sprfl != nil {
 		err = sprofile.Activate(data.Id, data.Mode, data.Password)
@@ -72,6 +149,21 @@ func profilePost(c *gin.Context) {
 		return
 	}
 
+	if data.HttpProxy != "" {
+		_, err = proxy.Parse(data.HttpProxy, false)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+	if data.SocksProxy != "" {
+		_, err = proxy.Parse(data.SocksProxy, true)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+
 	prfl := profile.GetProfile(data.Id)
 	if prfl != nil {
 		prfl.Stop()
@@ -79,6 +171,8 @@ func profilePost(c *gin.Context) {
 
 	prfl = &profile.Profile{
 		Id:                 data.Id,
+		Name:               data.Name,
+		Workspace:          wsId,
 		Mode:               data.Mode,
 		OrgId:              data.OrgId,
 		UserId:             data.UserId,
@@ -94,12 +188,42 @@ func profilePost(c *gin.Context) {
 		DisableGateway:     data.DisableGateway,
 		DisableDns:         data.DisableDns,
 		ForceDns:           data.ForceDns,
+		SplitDnsDomains:    data.SplitDnsDomains,
+		Env:                data.Env,
+		Hooks:              profile.FilterHooks(data.Hooks),
+		CredentialProvider: data.CredentialProvider,
+		CredentialCacheTtl: data.CredentialCacheTtl,
+		RoutePriority:      data.RoutePriority,
+		IncludeRoutes:      utils.FilterSubnets(data.IncludeRoutes),
+		ExcludeRoutes:      utils.FilterSubnets(data.ExcludeRoutes),
+		PersistTun:         data.PersistTun,
+		HttpProxy:          data.HttpProxy,
+		SocksProxy:         data.SocksProxy,
+		AlwaysOn:           data.AlwaysOn,
+		KillSwitch:         data.KillSwitch,
+		CredentialId:       data.CredentialId,
+		HostFailover:       data.HostFailover,
+		Verbosity:          profile.FilterVerbosity(data.Verbosity),
+		SplitDnsPushed:     data.SplitDnsPushed,
+		DnsHijackMode:      sprofile.FilterDnsHijackMode(data.DnsHijackMode),
+		DisableIpv6:        data.DisableIpv6,
+		SyslogTag:          data.SyslogTag,
+		WgTcpFallback:      data.WgTcpFallback,
+		Mtu:                sprofile.FilterMtu(data.Mtu),
+		MssFix:             sprofile.FilterMtu(data.MssFix),
+		MtuProbe:           data.MtuProbe,
+		SummarizeRoutes:    data.SummarizeRoutes,
 		SsoAuth:            data.SsoAuth,
 		ServerPublicKey:    data.ServerPublicKey,
 		ServerBoxPublicKey: data.ServerBoxPublicKey,
 		TokenTtl:           data.TokenTtl,
 		Reconnect:          data.Reconnect,
 	}
+	// Expiry of profiles stored by the client is only accepted from an
+	// admin api token
+	if auth.TokenScope(c.Request) == auth.ScopeAdmin {
+		prfl.ExpiresAt = data.ExpiresAt
+	}
 	prfl.Init()
 
 	go func() {
@@ -114,6 +238,21 @@ func profilePost(c *gin.Context) {
 
 	err = prfl.StartWait()
 	if err != nil {
+		if _, ok := err.(*errortypes.PreconditionError); ok {
+			failures := prfl.GetPrecheckFailures()
+			msg := message.New(message.PreconditionFailed,
+				profile.PrecheckParams(prfl.Id, failures))
+
+			c.JSON(412, &precheckErrorData{
+				Error:          msg.Code,
+				Params:         msg.Params,
+				Message:        msg.Message,
+				Failures:       failures,
+				RemoteBreakers: profile.GetRemoteBreakers(prfl.Id),
+			})
+			return
+		}
+
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "handler: Start error"),
 		}
@@ -142,9 +281,17 @@ func profileDel(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, data.Id); !ok {
+		return
+	}
+
+	profile.CancelPause(data.Id)
+	profile.CancelPortalWait(data.Id)
+
 	sprfl := sprofile.Get(data.Id)
 	if sprfl != nil {
 		sprofile.Deactivate(data.Id)
+		profile.ReleaseKillSwitch(data.Id)
 		c.JSON(200, nil)
 		return
 	}
@@ -153,6 +300,7 @@ func profileDel(c *gin.Context) {
 	if prfl != nil {
 		prfl.Stop()
 	}
+	profile.ReleaseKillSwitch(data.Id)
 
 	c.JSON(200, nil)
 }
@@ -167,9 +315,17 @@ func profileDel2(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	profile.CancelPause(prflId)
+	profile.CancelPortalWait(prflId)
+
 	sprfl := sprofile.Get(prflId)
 	if sprfl != nil {
 		sprofile.Deactivate(prflId)
+		profile.ReleaseKillSwitch(prflId)
 		c.JSON(200, nil)
 		return
 	}
@@ -178,6 +334,107 @@ func profileDel2(c *gin.Context) {
 	if prfl != nil {
 		prfl.Stop()
 	}
+	profile.ReleaseKillSwitch(prflId)
 
 	c.JSON(200, nil)
 }
+
+func profileStatsGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if profile.GetProfile(prflId) == nil && sprofile.Get(prflId) == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	stats := profile.GetBandwidthStats(prflId)
+
+	writeFormat(c, stats, func(w *metricsWriter) {
+		labels := []string{"profile_id", stats.ProfileId}
+
+		w.Header("pritunl_client_profile_received_bytes", "gauge",
+			"Bytes received on the current connection")
+		w.Value("pritunl_client_profile_received_bytes",
+			labels, stats.RxBytes)
+		w.Header("pritunl_client_profile_sent_bytes", "gauge",
+			"Bytes sent on the current connection")
+		w.Value("pritunl_client_profile_sent_bytes",
+			labels, stats.TxBytes)
+		w.Header("pritunl_client_profile_receive_rate_bytes", "gauge",
+			"Bytes per second received at the last sample")
+		w.Value("pritunl_client_profile_receive_rate_bytes",
+			labels, stats.RxRate)
+		w.Header("pritunl_client_profile_send_rate_bytes", "gauge",
+			"Bytes per second sent at the last sample")
+		w.Value("pritunl_client_profile_send_rate_bytes",
+			labels, stats.TxRate)
+	}, func() (t *formatTable) {
+		t = newFormatTable("RX_BYTES", "TX_BYTES", "RX_RATE", "TX_RATE")
+		t.Row(stats.RxBytes, stats.TxBytes, stats.RxRate, stats.TxRate)
+
+		t.Section("RESOLUTION", "TIMESTAMP", "RX_BYTES", "TX_BYTES")
+		for _, res := range profile.GetBandwidthResolutions() {
+			for _, point := range stats.History[res] {
+				t.Row(res, point.Timestamp, point.RxBytes, point.TxBytes)
+			}
+		}
+		return
+	})
+}
+
+func profileLogGet(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	var since time.Time
+	sinceStr := c.Query("since")
+	if sinceStr != "" {
+		sinceUnix, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err == nil {
+			since = time.Unix(sinceUnix, 0)
+		} else {
+			since, err = time.Parse(time.RFC3339, sinceStr)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(err, "handler: Invalid since time"),
+				}
+				utils.AbortWithError(c, 400, err)
+				return
+			}
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	entries, err := logger.GetProfileEntries(prflId, since, limit)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, entries)
+}
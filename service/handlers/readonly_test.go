@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+)
+
+const (
+	readOnlyId  = "5f8a3c2e9b1d4e7f8a6c0b3d"
+	readOnlyKey = "9c1f7a2b4d6e8f0a"
+)
+
+func newReadOnlyEngine(t *testing.T) (engine *gin.Engine) {
+	prevDir := constants.ProfilesDir
+	constants.ProfilesDir = t.TempDir()
+	t.Cleanup(func() {
+		constants.ProfilesDir = prevDir
+		_ = sprofile.Reload(false)
+	})
+
+	err := ioutil.WriteFile(
+		filepath.Join(constants.ProfilesDir, readOnlyId+".conf"),
+		[]byte(`{"id": "`+readOnlyId+`", "name": "system", `+
+			`"read_only": true}`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sprofile.Reload(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sprofile.CheckReadOnly(readOnlyId) == nil {
+		t.Fatalf("test profile not loaded as read-only")
+	}
+
+	prevKey := auth.Key
+	auth.Key = readOnlyKey
+	t.Cleanup(func() {
+		auth.Key = prevKey
+	})
+
+	Ready()
+
+	gin.SetMode(gin.TestMode)
+	engine = gin.New()
+	Register(engine)
+
+	return
+}
+
+func TestReadOnlyRoutes(t *testing.T) {
+	engine := newReadOnlyEngine(t)
+
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"PUT", "/sprofile", `{"id": "` + readOnlyId + `"}`},
+		{"DELETE", "/sprofile", `{"id": "` + readOnlyId + `"}`},
+		{"DELETE", "/sprofile/" + readOnlyId, ""},
+		{"DELETE", "/sprofile/" + readOnlyId + "/log", ""},
+		{"DELETE", "/log/" + readOnlyId, ""},
+		{"POST", "/profile/" + readOnlyId + "/clone", `{}`},
+		{"PUT", "/profile/" + readOnlyId + "/split_dns",
+			`{"domains": ["example.com"]}`},
+		{"PUT", "/profile/" + readOnlyId + "/verbosity",
+			`{"verbosity": 4}`},
+		{"POST", "/profile/" + readOnlyId + "/pause", `{"duration": 5}`},
+		{"DELETE", "/profile/" + readOnlyId + "/pause", ""},
+		{"DELETE", "/profile/" + readOnlyId + "/breakers", ""},
+		{"DELETE", "/kill_switch/" + readOnlyId, ""},
+		{"PUT", "/otp_seed/" + readOnlyId,
+			`{"otp_seed": "JBSWY3DPEHPK3PXP"}`},
+		{"DELETE", "/otp_seed/" + readOnlyId, ""},
+	}
+
+	for _, route := range routes {
+		req := httptest.NewRequest(route.method, route.path,
+			strings.NewReader(route.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "pritunl")
+		req.Header.Set("Auth-Key", readOnlyKey)
+
+		resp := httptest.NewRecorder()
+		engine.ServeHTTP(resp, req)
+
+		if resp.Code != 403 {
+			t.Errorf("%s %s: expected 403 got %d %s", route.method,
+				route.path, resp.Code, resp.Body.String())
+		}
+	}
+
+	if sprofile.Get(readOnlyId) == nil {
+		t.Fatalf("read-only profile removed")
+	}
+}
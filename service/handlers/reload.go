@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/reload"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func reloadPost(c *gin.Context) {
+	result, err := reload.Reload()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, result)
+}
@@ -3,63 +3,163 @@ package handlers
 import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/message"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
 type sprofileData struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"state"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DeviceAuth         bool     `json:"device_auth"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	DisableDns         bool     `json:"disable_dns"`
-	ForceDns           bool     `json:"force_dns"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	RegistrationKey    string   `json:"registration_key"`
-	OvpnData           string   `json:"ovpn_data"`
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	State              bool              `json:"state"`
+	Wg                 bool              `json:"wg"`
+	LastMode           string            `json:"last_mode"`
+	OrganizationId     string            `json:"organization_id"`
+	Organization       string            `json:"organization"`
+	ServerId           string            `json:"server_id"`
+	Server             string            `json:"server"`
+	UserId             string            `json:"user_id"`
+	User               string            `json:"user"`
+	PreConnectMsg      string            `json:"pre_connect_msg"`
+	DynamicFirewall    bool              `json:"dynamic_firewall"`
+	DeviceAuth         bool              `json:"device_auth"`
+	DisableGateway     bool              `json:"disable_gateway"`
+	DisableDns         bool              `json:"disable_dns"`
+	ForceDns           bool              `json:"force_dns"`
+	SplitDnsDomains    []string          `json:"split_dns_domains"`
+	Env                map[string]string `json:"env"`
+	Hooks              map[string]string `json:"hooks"`
+	CredentialProvider string            `json:"credential_provider"`
+	CredentialCacheTtl int               `json:"credential_cache_ttl"`
+	RoutePriority      int               `json:"route_priority"`
+	IncludeRoutes      []string          `json:"include_routes"`
+	ExcludeRoutes      []string          `json:"exclude_routes"`
+	PersistTun         bool              `json:"persist_tun"`
+	HttpProxy          string            `json:"http_proxy"`
+	SocksProxy         string            `json:"socks_proxy"`
+	AlwaysOn           bool              `json:"always_on"`
+	KillSwitch         bool              `json:"kill_switch"`
+	CredentialId       string            `json:"credential_id"`
+	HostFailover       bool              `json:"host_failover"`
+	Verbosity          int               `json:"verbosity"`
+	SplitDnsPushed     bool              `json:"split_dns_pushed"`
+	DnsHijackMode      string            `json:"dns_hijack_mode"`
+	DisableIpv6        bool              `json:"disable_ipv6"`
+	SyslogTag          string            `json:"syslog_tag"`
+	WgTcpFallback      bool              `json:"wg_tcp_fallback"`
+	Mtu                int               `json:"mtu"`
+	MssFix             int               `json:"mss_fix"`
+	MtuProbe           bool              `json:"mtu_probe"`
+	SummarizeRoutes    bool              `json:"summarize_routes"`
+	ExpiresAt          int64             `json:"expires_at"`
+	ExpireDelete       bool              `json:"expire_delete"`
+	OnDemandSubnets    []string          `json:"on_demand_subnets"`
+	OnDemandIdle       int               `json:"on_demand_idle"`
+	RestartTriggers    []string          `json:"restart_triggers"`
+	StartDelay         int               `json:"start_delay"`
+	StartJitter        int               `json:"start_jitter"`
+	SsoAuth            bool              `json:"sso_auth"`
+	PasswordMode       string            `json:"password_mode"`
+	Token              bool              `json:"token"`
+	TokenTtl           int               `json:"token_ttl"`
+	Disabled           bool              `json:"disabled"`
+	SyncTime           int64             `json:"sync_time"`
+	SyncHosts          []string          `json:"sync_hosts"`
+	SyncHash           string            `json:"sync_hash"`
+	SyncSecret         string            `json:"sync_secret"`
+	SyncToken          string            `json:"sync_token"`
+	ServerPublicKey    []string          `json:"server_public_key"`
+	ServerBoxPublicKey string            `json:"server_box_public_key"`
+	RegistrationKey    string            `json:"registration_key"`
+	OvpnData           string            `json:"ovpn_data"`
+}
+
+// Every handler changing a profile, its settings or its stored state must
+// check the profile is not read-only
+func sprofileReadOnly(c *gin.Context, prflId string) bool {
+	err := sprofile.CheckReadOnly(prflId)
+	if err == nil {
+		return false
+	}
+
+	utils.AbortWithErrorMessage(c, 403, err, message.New(
+		message.ProfileReadOnly, message.Params{
+			"profile_id": prflId,
+		},
+	))
+
+	return true
 }
 
 func sprofilesGet(c *gin.Context) {
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
 	err := sprofile.Reload(false)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
-	prfls, err := sprofile.GetAllClient()
+	allPrfls, err := sprofile.GetAllClient()
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
 	}
 
-	c.JSON(200, prfls)
+	prfls := []*sprofile.SprofileClient{}
+	for _, prfl := range allPrfls {
+		if prfl.Workspace == wsId {
+			prfls = append(prfls, prfl)
+		}
+	}
+
+	for _, prfl := range prfls {
+		note := stats.GetNotes(prfl.Id)
+		if note != nil {
+			prfl.LastError = note.LastError
+			prfl.LastErrorTime = note.LastErrorTime
+			prfl.LastConnected = note.LastConnected
+		}
+	}
+
+	fields := utils.ParseFields(c.Query("fields"))
+	if len(fields) == 0 {
+		c.JSON(200, prfls)
+		return
+	}
+
+	data := []map[string]interface{}{}
+	for _, prfl := range prfls {
+		prflData, e := utils.SelectFields(prfl, fields)
+		if e != nil {
+			utils.AbortWithError(c, 400, e)
+			return
+		}
+		data = append(data, prflData)
+	}
+
+	c.JSON(200, data)
+}
+
+func sprofileQuarantineGet(c *gin.Context) {
+	c.JSON(200, sprofile.GetQuarantined())
 }
 
 func sprofilePut(c *gin.Context) {
+	wsId, ok := workspaceParam(c)
+	if !ok {
+		return
+	}
+
 	data := &sprofileData{}
 
 	err := c.Bind(data)
@@ -80,9 +180,46 @@ func sprofilePut(c *gin.Context) {
 		return
 	}
 
+	if sprofileReadOnly(c, data.Id) {
+		return
+	}
+
+	curPrfl := sprofile.Get(data.Id)
+	if curPrfl != nil && curPrfl.Workspace != wsId {
+		err = &errortypes.ParseError{
+			errors.New("handler: Profile belongs to another workspace"),
+		}
+		utils.AbortWithError(c, 409, err)
+		return
+	}
+
+	if curPrfl != nil {
+		data.HttpProxy = proxy.Unredact(data.HttpProxy, curPrfl.HttpProxy)
+		data.SocksProxy = proxy.Unredact(data.SocksProxy,
+			curPrfl.SocksProxy)
+	}
+
+	if data.HttpProxy != "" {
+		_, err = proxy.Parse(data.HttpProxy, false)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+	if data.SocksProxy != "" {
+		_, err = proxy.Parse(data.SocksProxy, true)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+
+	// Managed profiles are read-only and only created by the managed
+	// config reconcile, profiles set with the api are never managed
 	prfl := &sprofile.Sprofile{
 		Id:                 data.Id,
 		Name:               data.Name,
+		Workspace:          wsId,
 		Wg:                 data.Wg,
 		LastMode:           data.LastMode,
 		OrganizationId:     data.OrganizationId,
@@ -97,6 +234,36 @@ func sprofilePut(c *gin.Context) {
 		DisableGateway:     data.DisableGateway,
 		DisableDns:         data.DisableDns,
 		ForceDns:           data.ForceDns,
+		SplitDnsDomains:    utils.FilterDomains(data.SplitDnsDomains),
+		Env:                profile.FilterEnv(data.Env),
+		Hooks:              profile.FilterHooks(data.Hooks),
+		CredentialProvider: data.CredentialProvider,
+		CredentialCacheTtl: data.CredentialCacheTtl,
+		RoutePriority:      data.RoutePriority,
+		IncludeRoutes:      utils.FilterSubnets(data.IncludeRoutes),
+		ExcludeRoutes:      utils.FilterSubnets(data.ExcludeRoutes),
+		PersistTun:         data.PersistTun,
+		HttpProxy:          data.HttpProxy,
+		SocksProxy:         data.SocksProxy,
+		AlwaysOn:           data.AlwaysOn,
+		KillSwitch:         data.KillSwitch,
+		CredentialId:       data.CredentialId,
+		HostFailover:       data.HostFailover,
+		Verbosity:          profile.FilterVerbosity(data.Verbosity),
+		SplitDnsPushed:     data.SplitDnsPushed,
+		DnsHijackMode:      sprofile.FilterDnsHijackMode(data.DnsHijackMode),
+		DisableIpv6:        data.DisableIpv6,
+		SyslogTag:          data.SyslogTag,
+		WgTcpFallback:      data.WgTcpFallback,
+		Mtu:                sprofile.FilterMtu(data.Mtu),
+		MssFix:             sprofile.FilterMtu(data.MssFix),
+		MtuProbe:           data.MtuProbe,
+		SummarizeRoutes:    data.SummarizeRoutes,
+		OnDemandSubnets:    utils.FilterSubnets(data.OnDemandSubnets),
+		OnDemandIdle:       data.OnDemandIdle,
+		RestartTriggers:    sprofile.FilterTriggers(data.RestartTriggers),
+		StartDelay:         sprofile.FilterStartDelay(data.StartDelay),
+		StartJitter:        sprofile.FilterStartDelay(data.StartJitter),
 		SsoAuth:            data.SsoAuth,
 		PasswordMode:       data.PasswordMode,
 		Token:              data.Token,
@@ -113,6 +280,111 @@ func sprofilePut(c *gin.Context) {
 		OvpnData:           data.OvpnData,
 	}
 
+	// Expiry is only set by the server sync or an admin api token, other
+	// requests keep the current expiry
+	if auth.TokenScope(c.Request) == auth.ScopeAdmin {
+		prfl.ExpiresAt = data.ExpiresAt
+		prfl.ExpireDelete = data.ExpireDelete
+	} else if curPrfl != nil {
+		prfl.ExpiresAt = curPrfl.ExpiresAt
+		prfl.ExpireDelete = curPrfl.ExpireDelete
+	}
+
+	// Feature flags are only set by the server sync, the flags from the
+	// last sync override the provided settings
+	if curPrfl != nil {
+		prfl.Features = curPrfl.Features.Copy()
+	}
+	prfl.ApplyWorkspace()
+	prfl.ApplyFeatures()
+
+	err = prfl.Commit()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, prfl.Client())
+}
+
+type sprofileCloneData struct {
+	Name            string   `json:"name"`
+	Remotes         []string `json:"remotes"`
+	DisableDns      *bool    `json:"disable_dns"`
+	ForceDns        *bool    `json:"force_dns"`
+	SplitDnsDomains []string `json:"split_dns_domains"`
+}
+
+func sprofileClonePost(c *gin.Context) {
+	data := &sprofileCloneData{}
+
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	_, err = sprofile.GetAll()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	if sprfl == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("handler: Profile not found"),
+		}
+		utils.AbortWithError(c, 404, err)
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	prfl := sprfl.Clone()
+
+	if data.Name != "" {
+		prfl.Name = data.Name
+	}
+
+	if data.Remotes != nil {
+		err = prfl.SetRemotes(data.Remotes)
+		if err != nil {
+			utils.AbortWithError(c, 400, err)
+			return
+		}
+	}
+
+	if data.DisableDns != nil {
+		prfl.DisableDns = *data.DisableDns
+	}
+
+	if data.ForceDns != nil {
+		prfl.ForceDns = *data.ForceDns
+	}
+
+	if data.SplitDnsDomains != nil {
+		prfl.SplitDnsDomains = utils.FilterDomains(data.SplitDnsDomains)
+	}
+
 	err = prfl.Commit()
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -143,12 +415,24 @@ func sprofileDel(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, data.Id); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, data.Id) {
+		return
+	}
+
 	prfl := profile.GetProfile(data.Id)
 	if prfl != nil {
 		prfl.Stop()
 	}
 
 	sprofile.Remove(data.Id)
+	profile.ClearBandwidthStats(data.Id)
+	profile.ClearRemoteBreakers(data.Id)
+	profile.ReleaseKillSwitch(data.Id)
+	logger.ClearProfileEntries(data.Id)
 
 	c.JSON(200, nil)
 }
@@ -163,12 +447,24 @@ func sprofileDel2(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
 	prfl := profile.GetProfile(prflId)
 	if prfl != nil {
 		prfl.Stop()
 	}
 
 	sprofile.Remove(prflId)
+	profile.ClearBandwidthStats(prflId)
+	profile.ClearRemoteBreakers(prflId)
+	profile.ReleaseKillSwitch(prflId)
+	logger.ClearProfileEntries(prflId)
 
 	c.JSON(200, nil)
 }
@@ -183,6 +479,10 @@ func sprofileLogGet(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
 	sprfl := sprofile.Get(prflId)
 	if sprfl == nil {
 		utils.AbortWithStatus(c, 404)
@@ -208,6 +508,14 @@ func sprofileLogDel(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
 	err := sprofile.ClearLog(prflId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
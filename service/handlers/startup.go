@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	startupQueueSize  = 32
+	startupWait       = 15 * time.Second
+	startupRetryAfter = 3
+)
+
+var (
+	startupReady = make(chan struct{})
+	startupOnce  = sync.Once{}
+	startupQueue = make(chan struct{}, startupQueueSize)
+)
+
+// Release the requests queued during startup, called once the subsystems
+// are initialized
+func Ready() {
+	startupOnce.Do(func() {
+		close(startupReady)
+	})
+}
+
+func IsReady() bool {
+	select {
+	case <-startupReady:
+		return true
+	default:
+		return false
+	}
+}
+
+func abortStarting(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(startupRetryAfter))
+	utils.AbortWithMessage(c, 503, message.New(
+		message.ServiceStarting, nil))
+}
+
+// Hold mutating requests until startup completes, requests beyond the
+// queue size or wait limit are rejected with a retry after
+func Startup(c *gin.Context) {
+	switch c.Request.Method {
+	case "GET", "HEAD", "OPTIONS":
+		c.Next()
+		return
+	}
+
+	if IsReady() {
+		c.Next()
+		return
+	}
+
+	select {
+	case startupQueue <- struct{}{}:
+		break
+	default:
+		abortStarting(c)
+		return
+	}
+
+	timer := time.NewTimer(startupWait)
+	defer timer.Stop()
+
+	select {
+	case <-startupReady:
+		<-startupQueue
+		c.Next()
+		break
+	case <-timer.C:
+		<-startupQueue
+		abortStarting(c)
+		break
+	case <-c.Request.Context().Done():
+		<-startupQueue
+		c.Abort()
+		break
+	}
+}
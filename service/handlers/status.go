@@ -6,7 +6,9 @@ import (
 )
 
 type statusData struct {
-	Status bool `json:"status"`
+	Status           bool   `json:"status"`
+	CaptivePortal    bool   `json:"captive_portal"`
+	CaptivePortalUrl string `json:"captive_portal_url,omitempty"`
 }
 
 func statusGet(c *gin.Context) {
@@ -14,5 +16,38 @@ func statusGet(c *gin.Context) {
 		Status: profile.GetStatus(),
 	}
 
-	c.JSON(200, data)
+	for _, wait := range profile.GetPortalWaits() {
+		data.CaptivePortal = true
+		if wait.Redirect != "" {
+			data.CaptivePortalUrl = wait.Redirect
+		}
+	}
+
+	writeFormat(c, data, func(w *metricsWriter) {
+		connected := 0
+		if data.Status {
+			connected = 1
+		}
+		w.Header("pritunl_client_connected", "gauge",
+			"Any profile connected")
+		w.Value("pritunl_client_connected", nil, connected)
+
+		portal := 0
+		if data.CaptivePortal {
+			portal = 1
+		}
+		w.Header("pritunl_client_captive_portal", "gauge",
+			"Reconnect deferred by captive portal")
+		w.Value("pritunl_client_captive_portal", nil, portal)
+	}, func() (t *formatTable) {
+		t = newFormatTable("STATUS")
+		if data.Status {
+			t.Row("connected")
+		} else if data.CaptivePortal {
+			t.Row("captive_portal")
+		} else {
+			t.Row("disconnected")
+		}
+		return
+	})
 }
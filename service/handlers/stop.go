@@ -14,6 +14,7 @@ func stopPost(c *gin.Context) {
 
 	for _, prfl := range prfls {
 		prfl.Wait()
+		profile.ReleaseKillSwitch(prfl.Id)
 	}
 
 	autoclean.CheckAndCleanWatch()
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+)
+
+type summaryProfileData struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Mode   string `json:"mode"`
+	Status string `json:"status"`
+}
+
+type summaryData struct {
+	Connected bool                  `json:"connected"`
+	Counts    map[string]int        `json:"counts"`
+	Profiles  []*summaryProfileData `json:"profiles"`
+	RxBytes   int64                 `json:"rx_bytes"`
+	TxBytes   int64                 `json:"tx_bytes"`
+}
+
+func summaryGet(c *gin.Context) {
+	data := &summaryData{
+		Counts:   map[string]int{},
+		Profiles: []*summaryProfileData{},
+	}
+
+	for _, prfl := range profile.GetProfiles() {
+		status := prfl.Status
+		if status == "" {
+			status = "disconnected"
+		}
+
+		if status == "connected" {
+			data.Connected = true
+		}
+		data.Counts[status] += 1
+
+		rx, tx := prfl.GetTransfer()
+		data.RxBytes += rx
+		data.TxBytes += tx
+
+		data.Profiles = append(data.Profiles, &summaryProfileData{
+			Id:     prfl.Id,
+			Name:   prfl.Name,
+			Mode:   prfl.Mode,
+			Status: status,
+		})
+	}
+
+	sort.Slice(data.Profiles, func(i, j int) bool {
+		return data.Profiles[i].Id < data.Profiles[j].Id
+	})
+
+	writeFormat(c, data, func(w *metricsWriter) {
+		w.Header("pritunl_client_profiles", "gauge",
+			"Profiles by connection status")
+		statuses := []string{}
+		for status := range data.Counts {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			w.Value("pritunl_client_profiles",
+				[]string{"status", status}, data.Counts[status])
+		}
+
+		w.Header("pritunl_client_received_bytes", "gauge",
+			"Bytes received on all current connections")
+		w.Value("pritunl_client_received_bytes", nil, data.RxBytes)
+		w.Header("pritunl_client_sent_bytes", "gauge",
+			"Bytes sent on all current connections")
+		w.Value("pritunl_client_sent_bytes", nil, data.TxBytes)
+	}, func() (t *formatTable) {
+		t = newFormatTable("ID", "NAME", "MODE", "STATUS")
+		for _, prfl := range data.Profiles {
+			t.Row(prfl.Id, prfl.Name, prfl.Mode, prfl.Status)
+		}
+		t.Section("CONNECTED", "RX_BYTES", "TX_BYTES")
+		t.Row(data.Connected, data.RxBytes, data.TxBytes)
+		return
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/support"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type supportSessionData struct {
+	Url      string `json:"url"`
+	Token    string `json:"token"`
+	Duration int    `json:"duration"`
+}
+
+func supportSessionGet(c *gin.Context) {
+	c.JSON(200, support.Get())
+}
+
+func supportSessionPost(c *gin.Context) {
+	data := &supportSessionData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	sess, err := support.Start(data.Url, data.Token,
+		time.Duration(data.Duration)*time.Second)
+	if err != nil {
+		if _, ok := err.(*errortypes.PolicyError); ok {
+			utils.AbortWithError(c, 403, err)
+		} else {
+			utils.AbortWithError(c, 400, err)
+		}
+		return
+	}
+
+	c.JSON(200, sess)
+}
+
+func supportSessionDelete(c *gin.Context) {
+	support.Stop()
+
+	c.JSON(200, nil)
+}
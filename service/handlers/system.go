@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/posture"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/tuntap"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const tuntapReinstallAction = "tuntap_reinstall"
+
+type adapterData struct {
+	*tuntap.Adapter
+	ProfileId string `json:"profile_id"`
+	Orphaned  bool   `json:"orphaned"`
+}
+
+type adaptersRepairData struct {
+	Removed []*tuntap.Adapter `json:"removed"`
+}
+
+type inventoryOrgData struct {
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	Servers  []string `json:"servers"`
+	Profiles int      `json:"profiles"`
+	servers  map[string]bool
+}
+
+type inventoryData struct {
+	DeviceId      string              `json:"device_id"`
+	Hostname      string              `json:"hostname"`
+	Platform      string              `json:"platform"`
+	Arch          string              `json:"arch"`
+	OsVersion     string              `json:"os_version"`
+	ClientVersion string              `json:"client_version"`
+	Organizations []*inventoryOrgData `json:"organizations"`
+}
+
+func systemInventoryGet(c *gin.Context) {
+	data := &inventoryData{
+		DeviceId:      config.Config.DeviceId,
+		Platform:      runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		ClientVersion: constants.Version,
+		Organizations: []*inventoryOrgData{},
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("handlers: Failed to get hostname")
+		err = nil
+	}
+	data.Hostname = hostname
+
+	osVersion, err := posture.OsVersion()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("handlers: Failed to get os version")
+		err = nil
+	}
+	data.OsVersion = osVersion
+
+	prfls, err := sprofile.GetAll()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	orgs := map[string]*inventoryOrgData{}
+	for _, prfl := range prfls {
+		orgId := prfl.OrganizationId
+		if orgId == "" {
+			orgId = prfl.Organization
+		}
+
+		org := orgs[orgId]
+		if org == nil {
+			org = &inventoryOrgData{
+				Id:      prfl.OrganizationId,
+				Name:    prfl.Organization,
+				Servers: []string{},
+				servers: map[string]bool{},
+			}
+			orgs[orgId] = org
+			data.Organizations = append(data.Organizations, org)
+		}
+
+		org.Profiles += 1
+		if prfl.Server != "" && !org.servers[prfl.Server] {
+			org.servers[prfl.Server] = true
+			org.Servers = append(org.Servers, prfl.Server)
+		}
+	}
+
+	sort.Slice(data.Organizations, func(i, j int) bool {
+		return data.Organizations[i].Name < data.Organizations[j].Name
+	})
+
+	c.JSON(200, data)
+}
+
+func systemAdaptersGet(c *gin.Context) {
+	adapters, err := tuntap.List()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	owners := profile.GetTapOwners()
+
+	data := []*adapterData{}
+	for _, adapter := range adapters {
+		prflId := owners[adapter.Name]
+
+		data = append(data, &adapterData{
+			Adapter:   adapter,
+			ProfileId: prflId,
+			Orphaned:  !adapter.Managed && prflId == "",
+		})
+	}
+
+	c.JSON(200, data)
+}
+
+func systemAdaptersRepairPost(c *gin.Context) {
+	removed, err := tuntap.Repair()
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if len(removed) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"count": len(removed),
+		}).Info("handlers: Removed orphaned adapters")
+	}
+
+	c.JSON(200, &adaptersRepairData{
+		Removed: removed,
+	})
+}
+
+// Reinstall the tap driver in the background after stopping the profiles
+// using the adapters, progress is sent with tuntap_reinstall events
+func systemTuntapReinstallPost(c *gin.Context) {
+	err := tuntap.CheckReinstall()
+	if err != nil {
+		utils.AbortWithErrorMessage(c, 409, err,
+			message.New(message.ReinstallUnavailable, nil))
+		return
+	}
+
+	data := &networkResetData{
+		Actions:  tuntap.DescribeReinstall(),
+		Profiles: []string{},
+	}
+
+	for _, prflId := range profile.GetTapOwners() {
+		data.Profiles = append(data.Profiles, prflId)
+	}
+	sort.Strings(data.Profiles)
+
+	if !networkResetConfirm(c, tuntapReinstallAction, data) {
+		return
+	}
+
+	prfls := profile.GetProfiles()
+	for _, prflId := range data.Profiles {
+		if prfl := prfls[prflId]; prfl != nil {
+			prfl.StopBackground()
+		}
+	}
+	for _, prflId := range data.Profiles {
+		if prfl := prfls[prflId]; prfl != nil {
+			prfl.Wait()
+			profile.ReleaseKillSwitch(prfl.Id)
+		}
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("handlers: Panic")
+				panic(panc)
+			}
+		}()
+
+		_ = tuntap.Reinstall()
+	}()
+
+	c.JSON(200, data)
+}
@@ -27,6 +27,10 @@ func tokenPut(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, data.Profile); !ok {
+		return
+	}
+
 	tokn, err := token.Update(
 		data.Profile,
 		data.ServerPublicKey,
@@ -53,6 +57,10 @@ func tokenDelete(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, data.Profile); !ok {
+		return
+	}
+
 	token.Clear(data.Profile)
 
 	c.JSON(200, nil)
@@ -68,6 +76,10 @@ func tokenDelete2(c *gin.Context) {
 		return
 	}
 
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
 	token.Clear(prflId)
 
 	c.JSON(200, nil)
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/upgrade"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Exec the installed service binary after the response is sent, connected
+// profiles are kept running
+func upgradePost(c *gin.Context) {
+	err := upgrade.Check()
+	if err != nil {
+		if _, ok := err.(*errortypes.PreconditionError); ok {
+			utils.AbortWithErrorMessage(c, 409, err,
+				message.New(message.UpgradeUnavailable, nil))
+			return
+		}
+
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	logrus.Warn("handlers: Upgrading service...")
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("handlers: Panic")
+				panic(panc)
+			}
+		}()
+
+		time.Sleep(500 * time.Millisecond)
+
+		err := upgrade.Upgrade()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("handlers: Failed to upgrade service")
+		}
+	}()
+
+	c.JSON(200, nil)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type verbosityData struct {
+	Verbosity int `json:"verbosity"`
+}
+
+func verbosityPut(c *gin.Context) {
+	prflId := utils.FilterStr(c.Param("profile_id"))
+	if prflId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid profile ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	if _, ok := profileWorkspace(c, prflId); !ok {
+		return
+	}
+
+	data := &verbosityData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	data.Verbosity = profile.FilterVerbosity(data.Verbosity)
+
+	if sprofileReadOnly(c, prflId) {
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	prfl := profile.GetProfile(prflId)
+	if sprfl == nil && prfl == nil {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	if sprfl != nil {
+		err = sprofile.SetVerbosity(prflId, data.Verbosity)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	if prfl != nil {
+		err = prfl.UpdateVerbosity(data.Verbosity)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	c.JSON(200, data)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/pritunl/pritunl-client-electron/service/watch"
+	"github.com/sirupsen/logrus"
+)
+
+type watchRuleData struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	Interface string `json:"interface"`
+	Ssid      string `json:"ssid"`
+	ProfileId string `json:"profile_id"`
+	Duration  int    `json:"duration"`
+	Action    string `json:"action"`
+	Target    string `json:"target"`
+}
+
+func watchRuleGet(c *gin.Context) {
+	c.JSON(200, watch.GetRules())
+}
+
+func watchRulePost(c *gin.Context) {
+	data := &watchRuleData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	rule := &config.WatchRule{
+		Name:      data.Name,
+		Condition: data.Condition,
+		Interface: data.Interface,
+		Ssid:      data.Ssid,
+		ProfileId: data.ProfileId,
+		Duration:  data.Duration,
+		Action:    data.Action,
+		Target:    data.Target,
+	}
+
+	err = watch.CreateRule(rule)
+	if err != nil {
+		if _, ok := err.(*errortypes.ParseError); ok {
+			utils.AbortWithError(c, 400, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"rule_id":   rule.Id,
+		"condition": rule.Condition,
+		"action":    rule.Action,
+	}).Info("handlers: Created watch rule")
+
+	c.JSON(200, rule)
+}
+
+func watchRuleDelete(c *gin.Context) {
+	ruleId := utils.FilterStr(c.Param("rule_id"))
+	if ruleId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid rule ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	found, err := watch.DeleteRule(ruleId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !found {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"rule_id": ruleId,
+	}).Info("handlers: Deleted watch rule")
+
+	c.JSON(200, nil)
+}
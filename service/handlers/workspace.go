@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type workspaceData struct {
+	Name        string `json:"name"`
+	KillSwitch  *bool  `json:"kill_switch"`
+	AlwaysOn    *bool  `json:"always_on"`
+	ForceDns    *bool  `json:"force_dns"`
+	DisableIpv6 *bool  `json:"disable_ipv6"`
+	HttpProxy   string `json:"http_proxy"`
+	SocksProxy  string `json:"socks_proxy"`
+}
+
+// Workspace from the request path, requests without a workspace use the
+// default workspace
+func workspaceParam(c *gin.Context) (wsId string, ok bool) {
+	wsId = utils.FilterStr(c.Param("workspace_id"))
+	if wsId == "" {
+		ok = true
+		return
+	}
+
+	if sprofile.GetWorkspace(wsId) == nil {
+		err := &errortypes.NotFoundError{
+			errors.New("handler: Workspace not found"),
+		}
+		utils.AbortWithError(c, 404, err)
+		return
+	}
+
+	ok = true
+	return
+}
+
+// Profiles are only accessible from the workspace of the profile, unknown
+// profiles are left to the handler
+func profileWorkspace(c *gin.Context, prflId string) (wsId string, ok bool) {
+	wsId, ok = workspaceParam(c)
+	if !ok {
+		return
+	}
+
+	found := false
+	prflWsId := ""
+	if sprfl := sprofile.Get(prflId); sprfl != nil {
+		found = true
+		prflWsId = sprfl.Workspace
+	} else if prfl := profile.GetProfile(prflId); prfl != nil {
+		found = true
+		prflWsId = prfl.Workspace
+	}
+
+	if found && prflWsId != wsId {
+		err := &errortypes.NotFoundError{
+			errors.New("handler: Profile not found in workspace"),
+		}
+		utils.AbortWithError(c, 404, err)
+		ok = false
+		return
+	}
+
+	return
+}
+
+func workspaceGet(c *gin.Context) {
+	wss := sprofile.GetWorkspaces()
+	for _, ws := range wss {
+		ws.HttpProxy = proxy.Redact(ws.HttpProxy)
+		ws.SocksProxy = proxy.Redact(ws.SocksProxy)
+	}
+
+	c.JSON(200, wss)
+}
+
+func workspacePost(c *gin.Context) {
+	data := &workspaceData{}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	ws := &config.Workspace{
+		Name:        data.Name,
+		KillSwitch:  data.KillSwitch,
+		AlwaysOn:    data.AlwaysOn,
+		ForceDns:    data.ForceDns,
+		DisableIpv6: data.DisableIpv6,
+		HttpProxy:   data.HttpProxy,
+		SocksProxy:  data.SocksProxy,
+	}
+
+	err = sprofile.CreateWorkspace(ws)
+	if err != nil {
+		if _, ok := err.(*errortypes.ParseError); ok {
+			utils.AbortWithError(c, 400, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workspace_id": ws.Id,
+		"name":         ws.Name,
+	}).Info("handlers: Created workspace")
+
+	wsCopy := *ws
+	wsCopy.HttpProxy = proxy.Redact(wsCopy.HttpProxy)
+	wsCopy.SocksProxy = proxy.Redact(wsCopy.SocksProxy)
+
+	c.JSON(200, &wsCopy)
+}
+
+func workspaceDelete(c *gin.Context) {
+	wsId := utils.FilterStr(c.Param("workspace_id"))
+	if wsId == "" {
+		err := &errortypes.ParseError{
+			errors.New("handler: Invalid workspace ID"),
+		}
+		utils.AbortWithError(c, 400, err)
+		return
+	}
+
+	found, err := sprofile.DeleteWorkspace(wsId)
+	if err != nil {
+		if _, ok := err.(*errortypes.ParseError); ok {
+			utils.AbortWithError(c, 409, err)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	if !found {
+		utils.AbortWithStatus(c, 404)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workspace_id": wsId,
+	}).Info("handlers: Deleted workspace")
+
+	c.JSON(200, nil)
+}
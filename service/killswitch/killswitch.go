@@ -0,0 +1,347 @@
+// Firewall rules blocking traffic outside of the tunnel for profiles with
+// the kill switch enabled.
+package killswitch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	state     = &State{}
+	stateLock = sync.Mutex{}
+)
+
+type Rule struct {
+	ProfileId string   `json:"profile_id"`
+	Iface     string   `json:"iface"`
+	Addresses []string `json:"addresses"`
+	Endpoints []string `json:"endpoints"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// Rules are persisted so blocking continues across service restarts until
+// the profile is explicitly disconnected
+type State struct {
+	Rules map[string]*Rule  `json:"rules"`
+	Saved map[string]string `json:"saved"`
+}
+
+func (s *State) init() {
+	if s.Rules == nil {
+		s.Rules = map[string]*Rule{}
+	}
+	if s.Saved == nil {
+		s.Saved = map[string]string{}
+	}
+}
+
+func (s *State) sortedRules() (rules []*Rule) {
+	prflIds := []string{}
+	for prflId := range s.Rules {
+		prflIds = append(prflIds, prflId)
+	}
+	sort.Strings(prflIds)
+
+	for _, prflId := range prflIds {
+		rules = append(rules, s.Rules[prflId])
+	}
+
+	return
+}
+
+func (s *State) ifaces() (ifaces []string) {
+	seen := map[string]bool{}
+	for _, rule := range s.sortedRules() {
+		if rule.Iface != "" && !seen[rule.Iface] {
+			seen[rule.Iface] = true
+			ifaces = append(ifaces, rule.Iface)
+		}
+	}
+	return
+}
+
+func (s *State) addresses() (addrs []string) {
+	seen := map[string]bool{}
+	for _, rule := range s.sortedRules() {
+		for _, addr := range rule.Addresses {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return
+}
+
+func (s *State) endpoints() (endpoints []string) {
+	seen := map[string]bool{}
+	for _, rule := range s.sortedRules() {
+		for _, endpoint := range rule.Endpoints {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return
+}
+
+func save() (err error) {
+	pth := GetStatePath()
+
+	if len(state.Rules) == 0 && len(state.Saved) == 0 {
+		err = utils.Remove(pth)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "killswitch: Failed to marshal state"),
+		}
+		return
+	}
+
+	err = utils.ExistsMkdir(filepath.Dir(pth), 0755)
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(pth, data, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "killswitch: Failed to write state"),
+		}
+		return
+	}
+
+	return
+}
+
+func load() (err error) {
+	data, err := ioutil.ReadFile(GetStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "killswitch: Failed to read state"),
+		}
+		return
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "killswitch: Failed to parse state"),
+		}
+		return
+	}
+
+	return
+}
+
+// Rules are checked after removal and the removal retried once
+func remove() (err error) {
+	err = clearRules(state)
+	if err != nil {
+		return
+	}
+
+	if !rulesPresent() {
+		return
+	}
+
+	logrus.Warn("killswitch: Firewall rules present after removal, retrying")
+
+	err = clearRules(state)
+	if err != nil {
+		return
+	}
+
+	if rulesPresent() {
+		err = &errortypes.WriteError{
+			errors.New("killswitch: Firewall rules present after removal"),
+		}
+		return
+	}
+
+	return
+}
+
+func update() (err error) {
+	if len(state.Rules) == 0 {
+		err = remove()
+	} else {
+		err = applyRules(state)
+	}
+
+	e := save()
+	if err == nil {
+		err = e
+	}
+
+	return
+}
+
+// Install or update the rule for a profile, endpoints from a previous
+// connection are kept to allow reconnecting to any known server address
+func Enable(rule *Rule) (err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	state.init()
+
+	prev := state.Rules[rule.ProfileId]
+	if prev != nil {
+		seen := map[string]bool{}
+		for _, endpoint := range rule.Endpoints {
+			seen[endpoint] = true
+		}
+		for _, endpoint := range prev.Endpoints {
+			if !seen[endpoint] {
+				rule.Endpoints = append(rule.Endpoints, endpoint)
+			}
+		}
+	}
+	rule.Timestamp = time.Now().Unix()
+
+	state.Rules[rule.ProfileId] = rule
+
+	err = update()
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": rule.ProfileId,
+		"iface":      rule.Iface,
+		"endpoints":  rule.Endpoints,
+	}).Info("killswitch: Kill switch enabled")
+
+	return
+}
+
+func Disable(prflId string) (err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	if state.Rules[prflId] == nil {
+		return
+	}
+	delete(state.Rules, prflId)
+
+	err = update()
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("killswitch: Kill switch released")
+
+	return
+}
+
+func Active(prflId string) (active bool) {
+	stateLock.Lock()
+	active = state.Rules[prflId] != nil
+	stateLock.Unlock()
+	return
+}
+
+func GetRules() (rules []*Rule) {
+	rules = []*Rule{}
+
+	stateLock.Lock()
+	for _, rule := range state.sortedRules() {
+		rules = append(rules, &Rule{
+			ProfileId: rule.ProfileId,
+			Iface:     rule.Iface,
+			Addresses: append([]string{}, rule.Addresses...),
+			Endpoints: append([]string{}, rule.Endpoints...),
+			Timestamp: rule.Timestamp,
+		})
+	}
+	stateLock.Unlock()
+
+	return
+}
+
+// Restore rules left active before the service was restarted
+func Init() (err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	err = load()
+	if err != nil {
+		return
+	}
+	state.init()
+
+	if len(state.Rules) == 0 {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profiles": len(state.Rules),
+	}).Warn("killswitch: Restoring active kill switch rules")
+
+	err = applyRules(state)
+	if err != nil {
+		return
+	}
+
+	err = save()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Remove firewall rules left without an active kill switch, rules of
+// active kill switches are restored by Init
+func Clean() (cleaned bool, err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	if len(state.Rules) != 0 {
+		return
+	}
+
+	if !rulesPresent() && len(state.Saved) == 0 {
+		return
+	}
+
+	logrus.Warn("killswitch: Removing orphaned firewall rules")
+
+	err = remove()
+	if err != nil {
+		return
+	}
+	cleaned = true
+
+	err = save()
+	if err != nil {
+		return
+	}
+
+	return
+}
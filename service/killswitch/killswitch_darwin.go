@@ -0,0 +1,130 @@
+package killswitch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// The default pf.conf evaluates anchors under com.apple
+const pfAnchor = "com.apple/pritunl.killswitch"
+
+func pfRules(s *State) string {
+	addrs4, addrs6 := splitFamilies(s.endpoints())
+
+	rules := &strings.Builder{}
+
+	rules.WriteString("pass quick on lo0 all\n")
+	for _, iface := range s.ifaces() {
+		fmt.Fprintf(rules, "pass quick on %s all\n", iface)
+	}
+	for _, addr := range addrs4 {
+		fmt.Fprintf(rules, "pass out quick inet to %s\n", addr)
+	}
+	for _, addr := range addrs6 {
+		fmt.Fprintf(rules, "pass out quick inet6 to %s\n", addr)
+	}
+	for _, addr := range dhcpServers() {
+		fmt.Fprintf(rules, "pass out quick inet proto udp "+
+			"from any port 68 to %s port 67\n", addr)
+	}
+	rules.WriteString("pass in quick inet proto udp " +
+		"from any port 67 to any port 68\n")
+	rules.WriteString("pass quick inet6 proto icmp6 all icmp6-type " +
+		"{ neighbrsol, neighbradv, routersol, routeradv }\n")
+	rules.WriteString("block drop quick all\n")
+
+	return rules.String()
+}
+
+func pfEnable(s *State) (err error) {
+	if s.Saved["pf_token"] != "" {
+		info, e := utils.ExecCombinedOutput("/sbin/pfctl", "-s", "info")
+		if e == nil && strings.Contains(info, "Status: Enabled") {
+			return
+		}
+	}
+
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"/sbin/pfctl", "-E",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "Token") {
+			continue
+		}
+
+		lineSpl := strings.SplitN(line, ":", 2)
+		if len(lineSpl) == 2 {
+			s.Saved["pf_token"] = strings.TrimSpace(lineSpl[1])
+		}
+	}
+
+	return
+}
+
+func applyRules(s *State) (err error) {
+	_, err = utils.ExecInputOutputCombindLogged(
+		pfRules(s), "/sbin/pfctl", "-a", pfAnchor, "-f", "-")
+	if err != nil {
+		return
+	}
+
+	err = pfEnable(s)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearRules(s *State) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"/sbin/pfctl", "-a", pfAnchor, "-F", "all",
+	)
+	if err != nil {
+		return
+	}
+
+	token := s.Saved["pf_token"]
+	if token != "" {
+		delete(s.Saved, "pf_token")
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"Token not found",
+			},
+			"/sbin/pfctl", "-X", token,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func rulesPresent() bool {
+	output, err := utils.ExecCombinedOutput(
+		"/sbin/pfctl", "-a", pfAnchor, "-s", "rules")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "No ALTQ") &&
+			!strings.HasPrefix(line, "ALTQ") {
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,217 @@
+package killswitch
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	nftTable    = "pritunl_killswitch"
+	iptChainOut = "PRITUNL-KS-OUT"
+	iptChainIn  = "PRITUNL-KS-IN"
+	nftNdpTypes = "nd-neighbor-solicit, nd-neighbor-advert, " +
+		"nd-router-solicit, nd-router-advert"
+)
+
+func hasNft() bool {
+	_, err := exec.LookPath("nft")
+	return err == nil
+}
+
+func nftRules(s *State) string {
+	addrs4, addrs6 := splitFamilies(s.endpoints())
+	ifaces := s.ifaces()
+
+	out := &strings.Builder{}
+	in := &strings.Builder{}
+
+	out.WriteString("\t\toifname \"lo\" accept\n")
+	in.WriteString("\t\tiifname \"lo\" accept\n")
+	for _, iface := range ifaces {
+		fmt.Fprintf(out, "\t\toifname \"%s\" accept\n", iface)
+		fmt.Fprintf(in, "\t\tiifname \"%s\" accept\n", iface)
+	}
+	if len(addrs4) > 0 {
+		fmt.Fprintf(out, "\t\tip daddr { %s } accept\n",
+			strings.Join(addrs4, ", "))
+	}
+	if len(addrs6) > 0 {
+		fmt.Fprintf(out, "\t\tip6 daddr { %s } accept\n",
+			strings.Join(addrs6, ", "))
+	}
+	fmt.Fprintf(out, "\t\tip daddr { %s } udp sport 68 udp dport 67 accept\n",
+		strings.Join(dhcpServers(), ", "))
+	in.WriteString("\t\tudp sport 67 udp dport 68 accept\n")
+	fmt.Fprintf(out, "\t\ticmpv6 type { %s } accept\n", nftNdpTypes)
+	fmt.Fprintf(in, "\t\ticmpv6 type { %s } accept\n", nftNdpTypes)
+	in.WriteString("\t\tct state established,related accept\n")
+
+	return fmt.Sprintf(`table inet %s
+delete table inet %s
+table inet %s {
+	chain output {
+		type filter hook output priority 0; policy drop;
+%s	}
+	chain input {
+		type filter hook input priority 0; policy drop;
+%s	}
+}
+`, nftTable, nftTable, nftTable, out.String(), in.String())
+}
+
+func iptRules(s *State, ipv6 bool) (outRules, inRules [][]string) {
+	addrs4, addrs6 := splitFamilies(s.endpoints())
+	addrs := addrs4
+	if ipv6 {
+		addrs = addrs6
+	}
+
+	outRules = append(outRules, []string{"-o", "lo", "-j", "ACCEPT"})
+	inRules = append(inRules, []string{"-i", "lo", "-j", "ACCEPT"})
+	for _, iface := range s.ifaces() {
+		outRules = append(outRules, []string{"-o", iface, "-j", "ACCEPT"})
+		inRules = append(inRules, []string{"-i", iface, "-j", "ACCEPT"})
+	}
+	for _, addr := range addrs {
+		outRules = append(outRules, []string{"-d", addr, "-j", "ACCEPT"})
+	}
+
+	if ipv6 {
+		for _, typ := range []string{"133", "134", "135", "136"} {
+			outRules = append(outRules, []string{
+				"-p", "ipv6-icmp", "--icmpv6-type", typ, "-j", "ACCEPT"})
+			inRules = append(inRules, []string{
+				"-p", "ipv6-icmp", "--icmpv6-type", typ, "-j", "ACCEPT"})
+		}
+	} else {
+		for _, addr := range dhcpServers() {
+			outRules = append(outRules, []string{"-d", addr, "-p", "udp",
+				"--sport", "68", "--dport", "67", "-j", "ACCEPT"})
+		}
+		inRules = append(inRules, []string{
+			"-p", "udp", "--sport", "67", "--dport", "68", "-j", "ACCEPT"})
+	}
+
+	inRules = append(inRules, []string{
+		"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED",
+		"-j", "ACCEPT",
+	})
+	outRules = append(outRules, []string{"-j", "DROP"})
+	inRules = append(inRules, []string{"-j", "DROP"})
+
+	return
+}
+
+func iptChain(cmd, parent, chain string, rules [][]string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"Chain already exists",
+		},
+		cmd, "-w", "-N", chain,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, cmd, "-w", "-F", chain)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		_, err = utils.ExecCombinedOutputLogged(nil, cmd,
+			append([]string{"-w", "-A", chain}, rule...)...)
+		if err != nil {
+			return
+		}
+	}
+
+	_, e := utils.ExecCombinedOutput(cmd, "-w", "-C", parent, "-j", chain)
+	if e != nil {
+		_, err = utils.ExecCombinedOutputLogged(nil, cmd,
+			"-w", "-I", parent, "1", "-j", chain)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func iptClear(cmd, parent, chain string) (err error) {
+	_, _ = utils.ExecCombinedOutput(cmd, "-w", "-D", parent, "-j", chain)
+	_, _ = utils.ExecCombinedOutput(cmd, "-w", "-F", chain)
+	_, _ = utils.ExecCombinedOutput(cmd, "-w", "-X", chain)
+	return
+}
+
+func applyRules(s *State) (err error) {
+	if hasNft() {
+		_, err = utils.ExecInputOutputCombindLogged(
+			nftRules(s), "nft", "-f", "-")
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		outRules, inRules := iptRules(s, cmd == "ip6tables")
+
+		err = iptChain(cmd, "OUTPUT", iptChainOut, outRules)
+		if err != nil {
+			return
+		}
+
+		err = iptChain(cmd, "INPUT", iptChainIn, inRules)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func clearRules(s *State) (err error) {
+	if hasNft() {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"No such file or directory",
+			},
+			"nft", "delete", "table", "inet", nftTable,
+		)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		_ = iptClear(cmd, "OUTPUT", iptChainOut)
+		_ = iptClear(cmd, "INPUT", iptChainIn)
+	}
+
+	return
+}
+
+func rulesPresent() bool {
+	if hasNft() {
+		_, err := utils.ExecCombinedOutput(
+			"nft", "list", "table", "inet", nftTable)
+		return err == nil
+	}
+
+	for _, cmd := range []string{"iptables", "ip6tables"} {
+		for _, chain := range []string{iptChainOut, iptChainIn} {
+			_, err := utils.ExecCombinedOutput(cmd, "-w", "-S", chain)
+			if err == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
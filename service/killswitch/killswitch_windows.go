@@ -0,0 +1,245 @@
+package killswitch
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fwRuleName = "Pritunl Kill Switch"
+
+	wfpWeightPermit = 12
+	wfpWeightBlock  = 0
+
+	ipProtoUdp    = 17
+	ipProtoIcmpV6 = 58
+)
+
+type wfpFilter struct {
+	action uint32
+	conds  *wfpConditions
+}
+
+// Filters are added to each connect and receive layer of a family, the
+// kill switch sublayer has the highest weight so permit rules of other
+// sublayers do not bypass the block
+func wfpFilters(s *State, ipv6 bool) (filters []*wfpFilter) {
+	addrs4, addrs6 := splitFamilies(s.addresses())
+	endpoints4, endpoints6 := splitFamilies(s.endpoints())
+	addrs := addrs4
+	endpoints := endpoints4
+	if ipv6 {
+		addrs = addrs6
+		endpoints = endpoints6
+	}
+
+	loopback := &wfpConditions{}
+	loopback.add(wfpConditionFlags, fwpMatchFlagsAllSet, fwpUint32,
+		fwpConditionFlagIsLoopback)
+	filters = append(filters, &wfpFilter{
+		action: fwpActionPermit,
+		conds:  loopback,
+	})
+
+	// Conditions with the same field are matched if any value matches
+	if len(addrs) > 0 {
+		local := &wfpConditions{}
+		for _, addr := range addrs {
+			local.addAddress(wfpConditionLocalAddress, net.ParseIP(addr))
+		}
+		filters = append(filters, &wfpFilter{
+			action: fwpActionPermit,
+			conds:  local,
+		})
+	}
+
+	if len(endpoints) > 0 {
+		remote := &wfpConditions{}
+		for _, addr := range endpoints {
+			remote.addAddress(wfpConditionRemoteAddress, net.ParseIP(addr))
+		}
+		filters = append(filters, &wfpFilter{
+			action: fwpActionPermit,
+			conds:  remote,
+		})
+	}
+
+	if ipv6 {
+		// ICMPv6 types are matched as the local port
+		for _, typ := range []uint16{133, 134, 135, 136} {
+			ndp := &wfpConditions{}
+			ndp.addUint8(wfpConditionProtocol, ipProtoIcmpV6)
+			ndp.addUint16(wfpConditionLocalPort, typ)
+			filters = append(filters, &wfpFilter{
+				action: fwpActionPermit,
+				conds:  ndp,
+			})
+		}
+	} else {
+		dhcp := &wfpConditions{}
+		dhcp.addUint8(wfpConditionProtocol, ipProtoUdp)
+		dhcp.addUint16(wfpConditionLocalPort, 68)
+		dhcp.addUint16(wfpConditionRemotePort, 67)
+		for _, addr := range dhcpServers() {
+			dhcp.addAddress(wfpConditionRemoteAddress, net.ParseIP(addr))
+		}
+		filters = append(filters, &wfpFilter{
+			action: fwpActionPermit,
+			conds:  dhcp,
+		})
+	}
+
+	filters = append(filters, &wfpFilter{
+		action: fwpActionBlock,
+	})
+
+	return
+}
+
+// Policies and rules from the netsh firewall implementation are removed
+// when found in the saved state
+func fwClearLegacy(s *State) (err error) {
+	for key, policy := range s.Saved {
+		if !strings.HasPrefix(key, "policy_") {
+			continue
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"netsh", "advfirewall", "set", strings.TrimPrefix(key, "policy_"),
+			"firewallpolicy", policy,
+		)
+		if err != nil {
+			return
+		}
+		delete(s.Saved, key)
+	}
+
+	_, _ = utils.ExecCombinedOutputLogged(
+		[]string{
+			"No rules match",
+		},
+		"netsh", "advfirewall", "firewall", "delete", "rule",
+		"name="+fwRuleName,
+	)
+
+	return
+}
+
+func applyRules(s *State) (err error) {
+	err = fwClearLegacy(s)
+	if err != nil {
+		return
+	}
+
+	engine, err := wfpOpen()
+	if err != nil {
+		return
+	}
+	defer engine.Close()
+
+	err = engine.Begin()
+	if err != nil {
+		return
+	}
+
+	err = engine.AddSublayer()
+	if err != nil {
+		engine.Abort()
+		return
+	}
+
+	err = engine.DeleteFilters()
+	if err != nil {
+		engine.Abort()
+		return
+	}
+
+	layers := []windows.GUID{
+		wfpLayerConnectV4,
+		wfpLayerAcceptV4,
+		wfpLayerConnectV6,
+		wfpLayerAcceptV6,
+	}
+
+	for _, layer := range layers {
+		ipv6 := layer == wfpLayerConnectV6 || layer == wfpLayerAcceptV6
+		for _, filter := range wfpFilters(s, ipv6) {
+			weight := uint8(wfpWeightPermit)
+			if filter.action == fwpActionBlock {
+				weight = wfpWeightBlock
+			}
+
+			err = engine.AddFilter(layer, weight, filter.action, filter.conds)
+			if err != nil {
+				engine.Abort()
+				return
+			}
+		}
+	}
+
+	err = engine.Commit()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearRules(s *State) (err error) {
+	err = fwClearLegacy(s)
+	if err != nil {
+		return
+	}
+
+	engine, err := wfpOpen()
+	if err != nil {
+		return
+	}
+	defer engine.Close()
+
+	err = engine.Begin()
+	if err != nil {
+		return
+	}
+
+	err = engine.DeleteFilters()
+	if err != nil {
+		engine.Abort()
+		return
+	}
+
+	err = engine.DeleteSublayer()
+	if err != nil {
+		engine.Abort()
+		return
+	}
+
+	err = engine.Commit()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func rulesPresent() bool {
+	_, err := utils.ExecCombinedOutput(
+		"netsh", "advfirewall", "firewall", "show", "rule",
+		"name="+fwRuleName,
+	)
+	if err == nil {
+		return true
+	}
+
+	engine, err := wfpOpen()
+	if err != nil {
+		return false
+	}
+	defer engine.Close()
+
+	return engine.SublayerExists()
+}
@@ -0,0 +1,56 @@
+package killswitch
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func GetStatePath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "killswitch.json")
+	case "darwin":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "killswitch.json")
+	case "linux":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "killswitch.json")
+	default:
+		panic("killswitch: Not implemented")
+	}
+}
+
+func splitFamilies(addrs []string) (addrs4, addrs6 []string) {
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+
+		if ip.To4() != nil {
+			addrs4 = append(addrs4, ip.String())
+		} else {
+			addrs6 = append(addrs6, ip.String())
+		}
+	}
+	return
+}
+
+// DHCP requests are only allowed to the broadcast address and the LAN
+// gateway, renewals are sent directly to the DHCP server which is commonly
+// the gateway
+func dhcpServers() (addrs []string) {
+	addrs = []string{"255.255.255.255"}
+
+	gateway, _, err := netconf.Get().GetDefaultGateway(false)
+	if err == nil && gateway.To4() != nil {
+		addrs = append(addrs, gateway.To4().String())
+	}
+
+	return
+}
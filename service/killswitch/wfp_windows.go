@@ -0,0 +1,441 @@
+package killswitch
+
+import (
+	"encoding/binary"
+	"net"
+	"runtime"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	rpcCAuthnDefault = 0xffffffff
+
+	fwpUint8           = 1
+	fwpUint16          = 2
+	fwpUint32          = 3
+	fwpByteArray16Type = 11
+
+	fwpMatchEqual       = 0
+	fwpMatchFlagsAllSet = 6
+
+	fwpActionBlock  = 0x1001
+	fwpActionPermit = 0x1002
+
+	fwpConditionFlagIsLoopback = 0x1
+
+	fwpmFilterFlagPersistent   = 0x1
+	fwpmSublayerFlagPersistent = 0x1
+
+	fwpErrFilterNotFound   = 0x80320003
+	fwpErrSublayerNotFound = 0x80320007
+	fwpErrAlreadyExists    = 0x80320009
+
+	wfpEnumEntries = 256
+)
+
+var (
+	fwpuclnt                         = windows.NewLazySystemDLL("fwpuclnt.dll")
+	procFwpmEngineOpen0              = fwpuclnt.NewProc("FwpmEngineOpen0")
+	procFwpmEngineClose0             = fwpuclnt.NewProc("FwpmEngineClose0")
+	procFwpmTransactionBegin0        = fwpuclnt.NewProc("FwpmTransactionBegin0")
+	procFwpmTransactionCommit0       = fwpuclnt.NewProc("FwpmTransactionCommit0")
+	procFwpmTransactionAbort0        = fwpuclnt.NewProc("FwpmTransactionAbort0")
+	procFwpmSubLayerAdd0             = fwpuclnt.NewProc("FwpmSubLayerAdd0")
+	procFwpmSubLayerGetByKey0        = fwpuclnt.NewProc("FwpmSubLayerGetByKey0")
+	procFwpmSubLayerDeleteByKey0     = fwpuclnt.NewProc("FwpmSubLayerDeleteByKey0")
+	procFwpmFilterAdd0               = fwpuclnt.NewProc("FwpmFilterAdd0")
+	procFwpmFilterDeleteByKey0       = fwpuclnt.NewProc("FwpmFilterDeleteByKey0")
+	procFwpmFilterCreateEnumHandle0  = fwpuclnt.NewProc("FwpmFilterCreateEnumHandle0")
+	procFwpmFilterEnum0              = fwpuclnt.NewProc("FwpmFilterEnum0")
+	procFwpmFilterDestroyEnumHandle0 = fwpuclnt.NewProc("FwpmFilterDestroyEnumHandle0")
+	procFwpmFreeMemory0              = fwpuclnt.NewProc("FwpmFreeMemory0")
+)
+
+var (
+	// Sublayer of the kill switch filters, the sublayer weight places the
+	// filters before the Windows Firewall sublayers
+	wfpSublayerKey = windows.GUID{0x7b3c8f52, 0x1d4e, 0x4c6a,
+		[8]byte{0x9a, 0x3e, 0x52, 0x8b, 0x61, 0xf0, 0x4d, 0x27}}
+
+	wfpLayerConnectV4 = windows.GUID{0xc38d57d1, 0x05a7, 0x4c33,
+		[8]byte{0x90, 0x4f, 0x7f, 0xbc, 0xee, 0xe6, 0x0e, 0x82}}
+	wfpLayerConnectV6 = windows.GUID{0x4a72393b, 0x319f, 0x44bc,
+		[8]byte{0x84, 0xc3, 0xba, 0x54, 0xdc, 0xb3, 0xb6, 0xb4}}
+	wfpLayerAcceptV4 = windows.GUID{0xe1cd9fe7, 0xf4b5, 0x4273,
+		[8]byte{0x96, 0xc0, 0x59, 0x2e, 0x48, 0x7b, 0x86, 0x50}}
+	wfpLayerAcceptV6 = windows.GUID{0xa3b42c97, 0x9f04, 0x4672,
+		[8]byte{0xb8, 0x7e, 0xce, 0xe9, 0xc4, 0x83, 0x25, 0x7f}}
+
+	wfpConditionFlags = windows.GUID{0x632ce23b, 0x5167, 0x435c,
+		[8]byte{0x86, 0xd7, 0xe9, 0x03, 0x68, 0x4a, 0xa8, 0x0c}}
+	wfpConditionLocalAddress = windows.GUID{0xd9ee00de, 0xc1ef, 0x4617,
+		[8]byte{0xbf, 0xe3, 0xff, 0xd8, 0xf5, 0xa0, 0x89, 0x57}}
+	wfpConditionRemoteAddress = windows.GUID{0xb235ae9a, 0x1d64, 0x49b8,
+		[8]byte{0xa4, 0x4c, 0x5f, 0xf3, 0xd9, 0x09, 0x50, 0x45}}
+	wfpConditionProtocol = windows.GUID{0x3971ef2b, 0x623e, 0x4f9a,
+		[8]byte{0x8c, 0xb1, 0x6e, 0x79, 0xb8, 0x06, 0xb9, 0xa7}}
+	wfpConditionLocalPort = windows.GUID{0x0c1ba1af, 0x5765, 0x453f,
+		[8]byte{0xaf, 0x22, 0xa8, 0xf7, 0x91, 0xac, 0x77, 0x5b}}
+	wfpConditionRemotePort = windows.GUID{0xc35a604d, 0xd22b, 0x4e1a,
+		[8]byte{0x91, 0xb4, 0x68, 0xf6, 0x74, 0xee, 0x67, 0x4b}}
+)
+
+// FWPM_DISPLAY_DATA0
+type fwpmDisplayData0 struct {
+	Name        *uint16
+	Description *uint16
+}
+
+// FWP_BYTE_BLOB
+type fwpByteBlob struct {
+	Size uint32
+	Data *uint8
+}
+
+// FWP_VALUE0 and FWP_CONDITION_VALUE0, the union holds the value of the
+// small types or a pointer
+type fwpValue0 struct {
+	Type  uint32
+	Value uintptr
+}
+
+// FWPM_SESSION0
+type fwpmSession0 struct {
+	SessionKey           windows.GUID
+	DisplayData          fwpmDisplayData0
+	Flags                uint32
+	TxnWaitTimeoutInMSec uint32
+	ProcessId            uint32
+	Sid                  *windows.SID
+	Username             *uint16
+	KernelMode           int32
+}
+
+// FWPM_SUBLAYER0
+type fwpmSublayer0 struct {
+	SubLayerKey  windows.GUID
+	DisplayData  fwpmDisplayData0
+	Flags        uint32
+	ProviderKey  *windows.GUID
+	ProviderData fwpByteBlob
+	Weight       uint16
+}
+
+// FWPM_FILTER_CONDITION0
+type fwpmFilterCondition0 struct {
+	FieldKey       windows.GUID
+	MatchType      uint32
+	ConditionValue fwpValue0
+}
+
+// FWPM_ACTION0
+type fwpmAction0 struct {
+	Type       uint32
+	FilterType windows.GUID
+}
+
+// FWPM_FILTER0, the provider context union is aligned to the raw context
+type fwpmFilter0 struct {
+	FilterKey           windows.GUID
+	DisplayData         fwpmDisplayData0
+	Flags               uint32
+	ProviderKey         *windows.GUID
+	ProviderData        fwpByteBlob
+	LayerKey            windows.GUID
+	SubLayerKey         windows.GUID
+	Weight              fwpValue0
+	NumFilterConditions uint32
+	FilterCondition     *fwpmFilterCondition0
+	Action              fwpmAction0
+	ProviderContextKey  [2]uint64
+	Reserved            *windows.GUID
+	FilterId            uint64
+	EffectiveWeight     fwpValue0
+}
+
+// Conditions of a filter, values referenced by the conditions are kept
+// until the filter is added
+type wfpConditions struct {
+	conds  []fwpmFilterCondition0
+	values []*[16]byte
+}
+
+func (c *wfpConditions) add(field windows.GUID, match uint32, typ uint32,
+	val uintptr) {
+
+	c.conds = append(c.conds, fwpmFilterCondition0{
+		FieldKey:  field,
+		MatchType: match,
+		ConditionValue: fwpValue0{
+			Type:  typ,
+			Value: val,
+		},
+	})
+}
+
+func (c *wfpConditions) addUint8(field windows.GUID, val uint8) {
+	c.add(field, fwpMatchEqual, fwpUint8, uintptr(val))
+}
+
+func (c *wfpConditions) addUint16(field windows.GUID, val uint16) {
+	c.add(field, fwpMatchEqual, fwpUint16, uintptr(val))
+}
+
+// IPv4 addresses are compared in host byte order
+func (c *wfpConditions) addAddress(field windows.GUID, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		c.add(field, fwpMatchEqual, fwpUint32,
+			uintptr(binary.BigEndian.Uint32(ip4)))
+		return
+	}
+
+	val := &[16]byte{}
+	copy(val[:], ip.To16())
+	c.values = append(c.values, val)
+	c.add(field, fwpMatchEqual, fwpByteArray16Type,
+		uintptr(unsafe.Pointer(val)))
+}
+
+type wfpEngine struct {
+	handle windows.Handle
+}
+
+func wfpError(r uintptr, msg string) error {
+	return &errortypes.ExecError{
+		errors.Wrap(windows.Errno(r), msg),
+	}
+}
+
+func wfpOpen() (engine *wfpEngine, err error) {
+	session := &fwpmSession0{
+		TxnWaitTimeoutInMSec: windows.INFINITE,
+	}
+
+	var handle windows.Handle
+	r, _, _ := procFwpmEngineOpen0.Call(
+		0,
+		rpcCAuthnDefault,
+		0,
+		uintptr(unsafe.Pointer(session)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if r != 0 {
+		err = wfpError(r, "killswitch: Failed to open filtering engine")
+		return
+	}
+
+	engine = &wfpEngine{
+		handle: handle,
+	}
+
+	return
+}
+
+func (e *wfpEngine) Close() {
+	_, _, _ = procFwpmEngineClose0.Call(uintptr(e.handle))
+}
+
+func (e *wfpEngine) Begin() (err error) {
+	r, _, _ := procFwpmTransactionBegin0.Call(uintptr(e.handle), 0)
+	if r != 0 {
+		err = wfpError(r, "killswitch: Failed to begin filter transaction")
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) Commit() (err error) {
+	r, _, _ := procFwpmTransactionCommit0.Call(uintptr(e.handle))
+	if r != 0 {
+		err = wfpError(r, "killswitch: Failed to commit filter transaction")
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) Abort() {
+	_, _, _ = procFwpmTransactionAbort0.Call(uintptr(e.handle))
+}
+
+func (e *wfpEngine) SublayerExists() bool {
+	var sublayer *fwpmSublayer0
+	r, _, _ := procFwpmSubLayerGetByKey0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(&wfpSublayerKey)),
+		uintptr(unsafe.Pointer(&sublayer)),
+	)
+	if r != 0 {
+		return false
+	}
+
+	_, _, _ = procFwpmFreeMemory0.Call(uintptr(unsafe.Pointer(&sublayer)))
+
+	return true
+}
+
+func (e *wfpEngine) AddSublayer() (err error) {
+	name, _ := windows.UTF16PtrFromString(fwRuleName)
+
+	sublayer := &fwpmSublayer0{
+		SubLayerKey: wfpSublayerKey,
+		DisplayData: fwpmDisplayData0{
+			Name: name,
+		},
+		Flags:  fwpmSublayerFlagPersistent,
+		Weight: 0xffff,
+	}
+
+	r, _, _ := procFwpmSubLayerAdd0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(sublayer)),
+		0,
+	)
+	if r != 0 && r != fwpErrAlreadyExists {
+		err = wfpError(r, "killswitch: Failed to add filter sublayer")
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) DeleteSublayer() (err error) {
+	r, _, _ := procFwpmSubLayerDeleteByKey0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(&wfpSublayerKey)),
+	)
+	if r != 0 && r != fwpErrSublayerNotFound {
+		err = wfpError(r, "killswitch: Failed to delete filter sublayer")
+		return
+	}
+
+	return
+}
+
+func (e *wfpEngine) AddFilter(layer windows.GUID, weight uint8,
+	action uint32, conds *wfpConditions) (err error) {
+
+	name, _ := windows.UTF16PtrFromString(fwRuleName)
+
+	key, err := windows.GenerateGUID()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "killswitch: Failed to generate filter key"),
+		}
+		return
+	}
+
+	filter := &fwpmFilter0{
+		FilterKey: key,
+		DisplayData: fwpmDisplayData0{
+			Name: name,
+		},
+		Flags:       fwpmFilterFlagPersistent,
+		LayerKey:    layer,
+		SubLayerKey: wfpSublayerKey,
+		Weight: fwpValue0{
+			Type:  fwpUint8,
+			Value: uintptr(weight),
+		},
+		Action: fwpmAction0{
+			Type: action,
+		},
+	}
+
+	if conds != nil && len(conds.conds) > 0 {
+		filter.NumFilterConditions = uint32(len(conds.conds))
+		filter.FilterCondition = &conds.conds[0]
+	}
+
+	var filterId uint64
+	r, _, _ := procFwpmFilterAdd0.Call(
+		uintptr(e.handle),
+		uintptr(unsafe.Pointer(filter)),
+		0,
+		uintptr(unsafe.Pointer(&filterId)),
+	)
+	runtime.KeepAlive(conds)
+	if r != 0 {
+		err = wfpError(r, "killswitch: Failed to add filter")
+		return
+	}
+
+	return
+}
+
+// Remove the filters of the kill switch sublayer, filters are found by
+// the sublayer so filters left without the saved state are removed
+func (e *wfpEngine) DeleteFilters() (err error) {
+	var enumHandle windows.Handle
+	r, _, _ := procFwpmFilterCreateEnumHandle0.Call(
+		uintptr(e.handle),
+		0,
+		uintptr(unsafe.Pointer(&enumHandle)),
+	)
+	if r != 0 {
+		err = wfpError(r, "killswitch: Failed to enumerate filters")
+		return
+	}
+
+	keys := []windows.GUID{}
+	for {
+		var entries **fwpmFilter0
+		var count uint32
+		r, _, _ = procFwpmFilterEnum0.Call(
+			uintptr(e.handle),
+			uintptr(enumHandle),
+			wfpEnumEntries,
+			uintptr(unsafe.Pointer(&entries)),
+			uintptr(unsafe.Pointer(&count)),
+		)
+		if r != 0 {
+			err = wfpError(r, "killswitch: Failed to enumerate filters")
+			break
+		}
+
+		if count > 0 {
+			for _, filter := range unsafe.Slice(entries, count) {
+				if filter.SubLayerKey == wfpSublayerKey {
+					keys = append(keys, filter.FilterKey)
+				}
+			}
+		}
+
+		if entries != nil {
+			_, _, _ = procFwpmFreeMemory0.Call(
+				uintptr(unsafe.Pointer(&entries)))
+		}
+
+		if count < wfpEnumEntries {
+			break
+		}
+	}
+
+	_, _, _ = procFwpmFilterDestroyEnumHandle0.Call(
+		uintptr(e.handle),
+		uintptr(enumHandle),
+	)
+
+	if err != nil {
+		return
+	}
+
+	for _, key := range keys {
+		key := key
+		r, _, _ = procFwpmFilterDeleteByKey0.Call(
+			uintptr(e.handle),
+			uintptr(unsafe.Pointer(&key)),
+		)
+		if r != 0 && r != fwpErrFilterNotFound {
+			err = wfpError(r, "killswitch: Failed to delete filter")
+			return
+		}
+	}
+
+	return
+}
@@ -7,11 +7,16 @@ import (
 	"runtime"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
 func getPath() string {
+	if constants.ProfilesDir != "" {
+		return constants.ProfilesDir
+	}
+
 	switch runtime.GOOS {
 	case "windows":
 		return filepath.Join(utils.GetWinDrive(), "ProgramData",
@@ -28,6 +33,8 @@ func getPath() string {
 }
 
 func ProfilePushLog(prflId string, output string) (err error) {
+	pushTails(prflId, output)
+
 	prflsPath := getPath()
 	logPth1 := filepath.Join(prflsPath, prflId+".log")
 	logPth2 := logPth1 + ".1"
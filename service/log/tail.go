@@ -0,0 +1,58 @@
+package log
+
+import (
+	"sync"
+)
+
+const tailBuffer = 256
+
+var (
+	tails     = map[*Tail]bool{}
+	tailsLock = sync.RWMutex{}
+)
+
+// Tail receives the output pushed to the log of a profile, output is
+// dropped when the receiver falls behind
+type Tail struct {
+	prflId string
+	stream chan string
+}
+
+func (t *Tail) Listen() <-chan string {
+	return t.stream
+}
+
+func (t *Tail) Close() {
+	tailsLock.Lock()
+	delete(tails, t)
+	tailsLock.Unlock()
+}
+
+func NewTail(prflId string) (t *Tail) {
+	t = &Tail{
+		prflId: prflId,
+		stream: make(chan string, tailBuffer),
+	}
+
+	tailsLock.Lock()
+	tails[t] = true
+	tailsLock.Unlock()
+
+	return
+}
+
+func pushTails(prflId, output string) {
+	tailsLock.RLock()
+	defer tailsLock.RUnlock()
+
+	for t := range tails {
+		if t.prflId != prflId {
+			continue
+		}
+
+		select {
+		case t.stream <- output:
+		default:
+		}
+	}
+}
@@ -23,7 +23,12 @@ func (s *fileSender) Parse(entry *logrus.Entry) {
 }
 
 func (s *fileSender) send(entry *logrus.Entry) (err error) {
-	msg := formatPlain(entry)
+	var msg []byte
+	if jsonEnabled() {
+		msg = formatJson(entry)
+	} else {
+		msg = formatPlain(entry)
+	}
 
 	file, err := os.OpenFile(utils.GetLogPath(),
 		os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
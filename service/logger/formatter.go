@@ -108,6 +108,9 @@ func formatLevel(lvl logrus.Level) (str string) {
 	var colorBg colorize.Color
 
 	switch lvl {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		colorBg = colorize.BlackBg
+		str = "[DEBG]"
 	case logrus.InfoLevel:
 		colorBg = colorize.CyanBg
 		str = "[INFO]"
@@ -134,6 +137,8 @@ func formatLevel(lvl logrus.Level) (str string) {
 
 func formatLevelPlain(lvl logrus.Level) string {
 	switch lvl {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return "[DEBG]"
 	case logrus.InfoLevel:
 		return "[INFO]"
 	case logrus.WarnLevel:
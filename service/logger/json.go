@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/sirupsen/logrus"
+)
+
+const FormatJson = "json"
+
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+func jsonEnabled() bool {
+	return config.Config.LogFormat == FormatJson
+}
+
+func jsonValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string, bool, int, int32, int64, uint, uint32, uint64,
+		float32, float64, []string:
+
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	_, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%#v", val)
+	}
+
+	return val
+}
+
+func newEntry(entry *logrus.Entry) (ent *Entry) {
+	ent = &Entry{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+	}
+
+	for key, val := range entry.Data {
+		if key == "error" {
+			ent.Error = fmt.Sprintf("%s", val)
+			continue
+		}
+
+		if ent.Fields == nil {
+			ent.Fields = map[string]interface{}{}
+		}
+		ent.Fields[key] = jsonValue(val)
+	}
+
+	return
+}
+
+func formatJson(entry *logrus.Entry) (output []byte) {
+	output, err := json.Marshal(newEntry(entry))
+	if err != nil {
+		output = []byte(fmt.Sprintf(
+			`{"time":%q,"level":%q,"message":%q}`,
+			entry.Time.Format(time.RFC3339Nano),
+			entry.Level.String(),
+			entry.Message,
+		))
+	}
+
+	output = append(output, '\n')
+
+	return
+}
@@ -4,6 +4,7 @@ package logger
 import (
 	"strings"
 
+	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/sirupsen/logrus"
 )
 
@@ -38,5 +39,25 @@ func Init() {
 	logrus.SetFormatter(&formatter{})
 	logrus.AddHook(&logHook{})
 	logrus.SetOutput(&Writer{})
-	logrus.SetLevel(logrus.InfoLevel)
+	SetLevel()
+	SetSyslog()
+}
+
+// Set the log level from the config, info is used when the level is not
+// set or invalid
+func SetLevel() {
+	level := logrus.InfoLevel
+
+	if config.Config.LogLevel != "" {
+		lvl, err := logrus.ParseLevel(config.Config.LogLevel)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"log_level": config.Config.LogLevel,
+			}).Warn("logger: Invalid log level")
+		} else if lvl >= logrus.ErrorLevel {
+			level = lvl
+		}
+	}
+
+	logrus.SetLevel(level)
 }
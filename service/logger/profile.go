@@ -0,0 +1,275 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	profileMaxSize   = 200000
+	profileMaxAge    = 7 * 24 * time.Hour
+	profileRetention = 3
+)
+
+var (
+	profileStarts = map[string]time.Time{}
+	profileLock   = sync.Mutex{}
+)
+
+func GetProfileMaxSize() int64 {
+	if config.Config.LogMaxSize > 0 {
+		return int64(config.Config.LogMaxSize)
+	}
+	return profileMaxSize
+}
+
+func GetProfileMaxAge() time.Duration {
+	if config.Config.LogMaxAge > 0 {
+		return time.Duration(config.Config.LogMaxAge) * 24 * time.Hour
+	}
+	return profileMaxAge
+}
+
+func GetProfileRetention() int {
+	if config.Config.LogRetention > 0 {
+		return config.Config.LogRetention
+	}
+	return profileRetention
+}
+
+func getProfileDir() string {
+	return filepath.Join(filepath.Dir(utils.GetLogPath()), "pritunl-client")
+}
+
+func getProfilePath(prflId string, index int) string {
+	pth := filepath.Join(getProfileDir(), prflId+".log")
+	if index > 0 {
+		pth += fmt.Sprintf(".%d", index)
+	}
+	return pth
+}
+
+// Time of the first entry in the current log file, used to rotate the
+// file by age
+func getProfileStart(prflId string) (start time.Time) {
+	start, ok := profileStarts[prflId]
+	if ok {
+		return
+	}
+
+	file, err := os.Open(getProfilePath(prflId, 0))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	ent := &Entry{}
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadBytes('\n')
+	if err == nil && json.Unmarshal(line, ent) == nil {
+		start = ent.Time
+		profileStarts[prflId] = start
+	}
+
+	return
+}
+
+func rotateProfile(prflId string) (err error) {
+	retention := GetProfileRetention()
+
+	for i := retention; i < retention+16; i++ {
+		pth := getProfilePath(prflId, i)
+		e := os.Remove(pth)
+		if e != nil && os.IsNotExist(e) {
+			break
+		}
+	}
+
+	for i := retention - 1; i >= 0; i-- {
+		err = os.Rename(
+			getProfilePath(prflId, i),
+			getProfilePath(prflId, i+1),
+		)
+		if err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "logger: Failed to rotate profile log"),
+			}
+			return
+		}
+	}
+
+	delete(profileStarts, prflId)
+
+	return
+}
+
+type profileSender struct{}
+
+func (s *profileSender) Init() {}
+
+func (s *profileSender) Parse(entry *logrus.Entry) {
+	prflId, _ := entry.Data["profile_id"].(string)
+	prflId = utils.FilterStr(prflId)
+	if prflId == "" {
+		return
+	}
+
+	err := s.send(prflId, entry)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("logger: Profile log send error")
+	}
+}
+
+func (s *profileSender) send(prflId string, entry *logrus.Entry) (
+	err error) {
+
+	profileLock.Lock()
+	defer profileLock.Unlock()
+
+	err = os.MkdirAll(getProfileDir(), 0700)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "logger: Failed to create profile log dir"),
+		}
+		return
+	}
+
+	pth := getProfilePath(prflId, 0)
+
+	stat, e := os.Stat(pth)
+	if e == nil {
+		start := getProfileStart(prflId)
+		if stat.Size() >= GetProfileMaxSize() || (!start.IsZero() &&
+			time.Since(start) >= GetProfileMaxAge()) {
+
+			err = rotateProfile(prflId)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	file, err := os.OpenFile(pth, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "logger: Failed to open profile log"),
+		}
+		return
+	}
+	defer file.Close()
+
+	if _, ok := profileStarts[prflId]; !ok {
+		profileStarts[prflId] = entry.Time
+	}
+
+	_, err = file.Write(formatJson(entry))
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "logger: Failed to write profile log"),
+		}
+		return
+	}
+
+	return
+}
+
+func readProfileFile(pth string, since time.Time) (
+	entries []*Entry, err error) {
+
+	file, err := os.Open(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "logger: Failed to open profile log"),
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		ent := &Entry{}
+		e := json.Unmarshal(scanner.Bytes(), ent)
+		if e != nil {
+			continue
+		}
+
+		if ent.Time.Before(since) {
+			continue
+		}
+
+		entries = append(entries, ent)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "logger: Failed to read profile log"),
+		}
+		return
+	}
+
+	return
+}
+
+// Get the entries logged for a profile at or after since in order, rotated
+// files are included and at most limit of the newest entries are returned
+func GetProfileEntries(prflId string, since time.Time, limit int) (
+	entries []*Entry, err error) {
+
+	profileLock.Lock()
+	defer profileLock.Unlock()
+
+	entries = []*Entry{}
+
+	for i := GetProfileRetention(); i >= 0; i-- {
+		ents, e := readProfileFile(getProfilePath(prflId, i), since)
+		if e != nil {
+			err = e
+			return
+		}
+
+		entries = append(entries, ents...)
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	return
+}
+
+func ClearProfileEntries(prflId string) {
+	profileLock.Lock()
+	defer profileLock.Unlock()
+
+	for i := 0; i <= GetProfileRetention(); i++ {
+		_ = os.Remove(getProfilePath(prflId, i))
+	}
+	delete(profileStarts, prflId)
+}
+
+func init() {
+	senders = append(senders, &profileSender{})
+}
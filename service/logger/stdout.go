@@ -22,7 +22,9 @@ func (s *stdoutSender) Parse(entry *logrus.Entry) {
 
 func (s *stdoutSender) send(entry *logrus.Entry) (err error) {
 	var msg []byte
-	if runtime.GOOS == "windows" {
+	if jsonEnabled() {
+		msg = formatJson(entry)
+	} else if runtime.GOOS == "windows" {
 		msg = formatPlain(entry)
 	} else {
 		msg = format(entry)
@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/support"
+	"github.com/sirupsen/logrus"
+)
+
+type supportSender struct{}
+
+func (s *supportSender) Init() {}
+
+// Support session errors are not streamed to avoid a feedback loop when
+// the support endpoint fails
+func (s *supportSender) Parse(entry *logrus.Entry) {
+	if strings.HasPrefix(entry.Message, "support:") || !support.Active() {
+		return
+	}
+
+	support.PushLog(entry.Time, newEntry(entry))
+}
+
+func init() {
+	senders = append(senders, &supportSender{})
+}
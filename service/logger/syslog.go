@@ -0,0 +1,313 @@
+package logger
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+// Process output is sent as RFC 5424 messages with facility local0 and
+// severity info, TCP and TLS sinks use octet counting framing.
+const (
+	syslogPriority   = 16*8 + 6
+	syslogAppName    = "pritunl-client"
+	syslogQueueSize  = 1024
+	syslogTimeout    = 10 * time.Second
+	syslogRetryDelay = 10 * time.Second
+)
+
+var (
+	syslogQueue     = make(chan *syslogMessage, syslogQueueSize)
+	syslogUpdate    = make(chan *syslogSink, 1)
+	syslogLock      = sync.Mutex{}
+	syslogCurrent   = ""
+	syslogStarted   = false
+	syslogDropped   = 0
+	syslogDropLock  = sync.Mutex{}
+	syslogHostname  = "-"
+	syslogSdReplace = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+)
+
+type syslogMessage struct {
+	Timestamp time.Time
+	ProfileId string
+	Tag       string
+	Output    string
+}
+
+func (m *syslogMessage) Format() string {
+	msgId := "-"
+	if m.Tag != "" {
+		msgId = strings.Map(func(r rune) rune {
+			if r <= 32 || r >= 127 {
+				return '_'
+			}
+			return r
+		}, m.Tag)
+		if len(msgId) > 32 {
+			msgId = msgId[:32]
+		}
+	}
+
+	return fmt.Sprintf(
+		"<%d>1 %s %s %s %d %s [pritunl profile_id=\"%s\" tag=\"%s\"] %s",
+		syslogPriority,
+		m.Timestamp.UTC().Format(time.RFC3339Nano),
+		syslogHostname,
+		syslogAppName,
+		os.Getpid(),
+		msgId,
+		syslogSdReplace.Replace(m.ProfileId),
+		syslogSdReplace.Replace(m.Tag),
+		m.Output,
+	)
+}
+
+type syslogSink struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func parseSyslogSink(sink string) (snk *syslogSink, err error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "logger: Failed to parse syslog sink"),
+		}
+		return
+	}
+
+	snk = &syslogSink{
+		network: u.Scheme,
+		addr:    u.Host,
+	}
+
+	switch snk.network {
+	case "udp", "tcp", "tls":
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("logger: Unknown syslog sink protocol '%s'",
+				u.Scheme),
+		}
+		return
+	}
+
+	if u.Port() == "" {
+		port := "514"
+		if snk.network == "tls" {
+			port = "6514"
+		}
+		snk.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return
+}
+
+func (s *syslogSink) Connect() (err error) {
+	dialer := &net.Dialer{
+		Timeout: syslogTimeout,
+	}
+
+	switch s.network {
+	case "tls":
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		})
+		break
+	default:
+		s.conn, err = dialer.Dial(s.network, s.addr)
+		break
+	}
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "logger: Failed to connect to syslog sink"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *syslogSink) Send(msg *syslogMessage) (err error) {
+	if s.conn == nil {
+		err = s.Connect()
+		if err != nil {
+			return
+		}
+	}
+
+	data := msg.Format()
+	if s.network != "udp" {
+		data = fmt.Sprintf("%d %s", len(data), data)
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+
+	_, err = s.conn.Write([]byte(data))
+	if err != nil {
+		s.Close()
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "logger: Failed to write to syslog sink"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *syslogSink) Close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func syslogSender(snk *syslogSink) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("logger: Panic")
+			panic(panc)
+		}
+	}()
+
+	var retry time.Time
+
+	for msg := range syslogQueue {
+		select {
+		case newSnk := <-syslogUpdate:
+			snk.Close()
+			snk = newSnk
+			retry = time.Time{}
+		default:
+		}
+
+		if !retry.IsZero() && time.Now().Before(retry) {
+			syslogDrop()
+			continue
+		}
+
+		err := snk.Send(msg)
+		if err != nil {
+			// Output lost while the sink is unavailable is counted and
+			// reported on the next successful send
+			retry = time.Now().Add(syslogRetryDelay)
+			syslogDrop()
+
+			logrus.WithFields(logrus.Fields{
+				"sink":  snk.addr,
+				"error": err,
+			}).Error("logger: Failed to send process output to syslog")
+			continue
+		}
+		retry = time.Time{}
+
+		syslogDropLock.Lock()
+		dropped := syslogDropped
+		syslogDropped = 0
+		syslogDropLock.Unlock()
+
+		if dropped > 0 {
+			logrus.WithFields(logrus.Fields{
+				"sink":    snk.addr,
+				"dropped": dropped,
+			}).Warn("logger: Dropped process output for syslog")
+		}
+	}
+}
+
+func syslogDrop() {
+	syslogDropLock.Lock()
+	syslogDropped += 1
+	syslogDropLock.Unlock()
+}
+
+// Switch the sender to the configured sink, the sender is started on the
+// first sink set
+func setSyslogSink() {
+	syslogLock.Lock()
+	defer syslogLock.Unlock()
+
+	sink := config.Config.SyslogSink
+	if sink == syslogCurrent {
+		return
+	}
+
+	if sink == "" {
+		syslogCurrent = ""
+		logrus.Info("logger: Syslog forwarding disabled")
+		return
+	}
+
+	snk, err := parseSyslogSink(sink)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"sink":  sink,
+			"error": err,
+		}).Error("logger: Invalid syslog sink, process output not forwarded")
+		return
+	}
+	syslogCurrent = sink
+
+	logrus.WithFields(logrus.Fields{
+		"sink": snk.addr,
+	}).Info("logger: Forwarding process output to syslog")
+
+	if !syslogStarted {
+		syslogStarted = true
+
+		hostname, err := os.Hostname()
+		if err == nil && hostname != "" {
+			syslogHostname = strings.ReplaceAll(hostname, " ", "_")
+		}
+
+		go syslogSender(snk)
+		return
+	}
+
+	select {
+	case <-syslogUpdate:
+	default:
+	}
+	syslogUpdate <- snk
+}
+
+// Set the syslog sink from the config, called on init and config reload
+func SetSyslog() {
+	setSyslogSink()
+}
+
+func SyslogPush(prflId, tag, output string) {
+	if config.Config.SyslogSink == "" {
+		return
+	}
+
+	msg := &syslogMessage{
+		Timestamp: time.Now(),
+		ProfileId: prflId,
+		Tag:       tag,
+		Output:    output,
+	}
+
+	select {
+	case syslogQueue <- msg:
+	default:
+		syslogDrop()
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"flag"
-	"net"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -20,13 +20,23 @@ import (
 	"github.com/pritunl/pritunl-client-electron/service/autoclean"
 	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/container"
+	"github.com/pritunl/pritunl-client-electron/service/diag"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/handlers"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
 	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/netclean"
+	"github.com/pritunl/pritunl-client-electron/service/pipe"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/reload"
+	"github.com/pritunl/pritunl-client-electron/service/rpc"
 	"github.com/pritunl/pritunl-client-electron/service/setup"
-	"github.com/pritunl/pritunl-client-electron/service/tuntap"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
 	"github.com/pritunl/pritunl-client-electron/service/update"
+	"github.com/pritunl/pritunl-client-electron/service/upgrade"
+	"github.com/pritunl/pritunl-client-electron/service/usage"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/pritunl/pritunl-client-electron/service/watch"
 	"github.com/pritunl/pritunl-client-electron/service/winsvc"
@@ -39,6 +49,12 @@ func main() {
 	devPtr := flag.Bool("dev", false, "development mode")
 	flag.Parse()
 
+	if (*install || *uninstall) && container.Detected() {
+		fmt.Printf("Skipping service setup in %s container\n",
+			container.Runtime())
+		return
+	}
+
 	if *install {
 		setup.Install()
 		return
@@ -58,17 +74,21 @@ func main() {
 		panic(err)
 	}
 
-	err = utils.PidInit()
-	if err != nil {
-		panic(err)
-	}
+	// After an upgrade the pid is unchanged and the temp dir holds the
+	// state of the adopted profiles
+	if !upgrade.Upgrading() {
+		err = utils.PidInit()
+		if err != nil {
+			panic(err)
+		}
 
-	err = utils.InitTempDir()
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"error": err,
-		}).Error("main: Failed to init temp dir")
-		panic(err)
+		err = utils.InitTempDir()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to init temp dir")
+			panic(err)
+		}
 	}
 
 	if runtime.GOOS == "darwin" {
@@ -87,7 +107,30 @@ func main() {
 		"version": constants.Version,
 	}).Info("main: Service starting")
 
+	err = config.InitDeviceId()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to save device ID")
+		err = nil
+	}
+
+	if container.Detected() {
+		logrus.WithFields(logrus.Fields{
+			"runtime": container.Runtime(),
+		}).Info("main: Running in container")
+
+		err = container.PrepareTun()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("main: Failed to create tunnel device")
+			err = nil
+		}
+	}
+
 	go update.Check()
+	upgrade.StartAutoUpdate()
 
 	defer func() {
 		panc := recover()
@@ -108,83 +151,81 @@ func main() {
 		panic(err)
 	}
 
-	err = autoclean.CheckAndClean()
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"error": err,
-		}).Error("main: Failed to run check and clean")
-		panic(err)
-	}
-
-	if runtime.GOOS == "windows" {
-		if config.Config.DisableNetClean {
-			logrus.Info("main: Network clean disabled")
-		} else {
-			err = tuntap.Clean()
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("main: Failed to clear interfaces")
-				err = nil
-			}
-		}
-	}
-
+	// Accept connections before the subsystems are initialized, mutating
+	// requests are queued until startup completes
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
 	handlers.Register(router)
 
-	watch.StartWatch()
-
+	// Every api surface including the events websocket, metrics and the
+	// grpc api is routed on a single listener
 	server := &http.Server{
-		Addr:           "127.0.0.1:9770",
-		Handler:        router,
+		Addr:           config.Config.ApiListenAddr(),
+		Handler:        rpc.Handler(router),
 		ReadTimeout:    300 * time.Second,
 		WriteTimeout:   300 * time.Second,
 		MaxHeaderBytes: 4096,
+		ConnContext:    auth.ConnContext,
 	}
 
-	err = profile.Clean()
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"error": err,
-		}).Error("main: Failed to clean profiles")
-		panic(err)
+	// The tcp port can only be disabled once the named pipe is available
+	tcpEnabled := true
+	if pipe.Supported {
+		pipeListener, err := pipe.Listen()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("main: Failed to listen on named pipe")
+		} else {
+			tcpEnabled = !config.Config.DisableApiPort
+
+			go func() {
+				err := server.Serve(pipeListener)
+				if err != nil {
+					err = &errortypes.WriteError{
+						errors.Wrap(err, "main: Named pipe server error"),
+					}
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("main: Server error")
+				}
+			}()
+		}
 	}
 
 	go func() {
 		if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
-			err = server.ListenAndServe()
-			if err != nil {
-				err = &errortypes.WriteError{
-					errors.Wrap(err, "main: Server listen error"),
-				}
+			if !tcpEnabled {
 				logrus.WithFields(logrus.Fields{
-					"error": err,
-				}).Error("main: Server error")
+					"pipe": pipe.Path,
+				}).Info("main: Api port disabled, serving on named pipe")
+				return
 			}
-		} else {
-			_ = os.Remove("/var/run/pritunl.sock")
 
-			listener, err := net.Listen("unix", "/var/run/pritunl.sock")
+			logrus.WithFields(logrus.Fields{
+				"address": server.Addr,
+			}).Info("main: Serving api on tcp port")
+
+			err := server.ListenAndServe()
 			if err != nil {
 				err = &errortypes.WriteError{
-					errors.Wrap(err, "main: Failed to create unix socket"),
+					errors.Wrap(err, "main: Server listen error"),
 				}
 				logrus.WithFields(logrus.Fields{
 					"error": err,
 				}).Error("main: Server error")
 			}
+		} else {
+			sockPath := config.Config.ApiSocketPath()
 
-			err = os.Chmod("/var/run/pritunl.sock", 0777)
+			listener, err := upgrade.Listen(sockPath)
 			if err != nil {
-				err = &errortypes.WriteError{
-					errors.Wrap(err, "main: Failed to chmod unix socket"),
-				}
 				logrus.WithFields(logrus.Fields{
+					"path":  sockPath,
 					"error": err,
 				}).Error("main: Server error")
+				return
 			}
 
 			err = server.Serve(listener)
@@ -199,6 +240,74 @@ func main() {
 		}
 	}()
 
+	err = autoclean.CheckAndClean()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to run check and clean")
+		panic(err)
+	}
+
+	err = killswitch.Init()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to restore kill switch")
+		err = nil
+	}
+
+	err = netclean.Init()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to load network state")
+		err = nil
+	}
+
+	// Adopted profiles are still connected after an upgrade
+	if !upgrade.Upgrading() {
+		netclean.Clean()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"version":      constants.Version,
+		"api_versions": constants.ApiVersions,
+		"features":     handlers.GetFeatures(),
+		"arch":         runtime.GOARCH,
+		"native_arch":  platform.NativeArch(),
+	}).Info("main: Service capabilities")
+
+	if platform.Emulated() {
+		logrus.WithFields(logrus.Fields{
+			"arch":        runtime.GOARCH,
+			"native_arch": platform.NativeArch(),
+		}).Warn("main: Service running under emulation, " +
+			"install the native build for full tunnel performance")
+	}
+
+	watch.StartWatch()
+	usage.StartWatch()
+	stats.StartWriter()
+	diag.StartWatch()
+
+	err = profile.Clean()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to clean profiles")
+		panic(err)
+	}
+
+	err = upgrade.Restore()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("main: Failed to restore profiles from upgrade")
+		err = nil
+	}
+
+	handlers.Ready()
+
 	profile.WatchSystemProfiles()
 
 	if winsvc.IsWindowsService() {
@@ -213,8 +322,34 @@ func main() {
 		}
 	} else {
 		sig := make(chan os.Signal, 100)
-		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-		<-sig
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+		if upgrade.Supported {
+			signal.Notify(sig, upgrade.Signal)
+		}
+
+		for {
+			s := <-sig
+			if s == syscall.SIGHUP {
+				logrus.Info("main: Received SIGHUP, reloading config")
+
+				_, _ = reload.Reload()
+				continue
+			}
+
+			if upgrade.Supported && s == upgrade.Signal {
+				logrus.Info("main: Received SIGUSR2, upgrading service")
+
+				err = upgrade.Upgrade()
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("main: Failed to upgrade service")
+				}
+				continue
+			}
+
+			break
+		}
 	}
 
 	webCtx, webCancel := context.WithTimeout(
@@ -227,6 +362,7 @@ func main() {
 		defer func() {
 			recover()
 		}()
+		rpc.Stop()
 		server.Shutdown(webCtx)
 		server.Close()
 	}()
@@ -234,6 +370,7 @@ func main() {
 	time.Sleep(250 * time.Millisecond)
 
 	profile.Shutdown()
+	watch.StopOnDemand()
 
 	prfls := profile.GetProfiles()
 	for _, prfl := range prfls {
@@ -244,6 +381,8 @@ func main() {
 		prfl.Wait()
 	}
 
+	stats.Flush()
+
 	if runtime.GOOS == "darwin" {
 		_ = utils.ClearScutilConnKeys()
 		_ = utils.RestoreScutilDns(true)
@@ -0,0 +1,216 @@
+// User-facing messages identified by a code with parameters, the English
+// text is only included as a fallback for clients without a translation.
+package message
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	AuthError            = "auth_error"
+	CaptivePortal        = "captive_portal"
+	CaptivePortalCleared = "captive_portal_cleared"
+	NetworkChanged       = "network_changed"
+	ClockSkew            = "clock_skew"
+	ConfigurationError   = "configuration_error"
+	ConnectionError      = "connection_error"
+	CredentialError      = "credential_error"
+	DirtyTeardown        = "dirty_teardown"
+	DnsFallback          = "dns_fallback"
+	DnsFallbackCleared   = "dns_fallback_cleared"
+	DnsHijack            = "dns_hijack"
+	DnsLeak              = "dns_leak"
+	DuplicateLogin       = "duplicate_login"
+	HandshakeTimeout     = "handshake_timeout"
+	HostFailover         = "host_failover"
+	Inactive             = "inactive"
+	KillSwitchError      = "kill_switch_error"
+	OfflineError         = "offline_error"
+	PreconditionError    = "precondition_error"
+	ProfileDegraded      = "profile_degraded"
+	ProfileExpired       = "profile_expired"
+	ProfileExpiring      = "profile_expiring"
+	ProfilePaused        = "profile_paused"
+	ProfileResumed       = "profile_resumed"
+	ProfileResync        = "profile_resync"
+	RegistrationPass     = "registration_pass"
+	RemoteBreaker        = "remote_breaker"
+	RegistrationRequired = "registration_required"
+	RouteOverlap         = "route_overlap"
+	SsoAuth              = "sso_auth"
+	TimeoutError         = "timeout_error"
+	TuntapReinstall      = "tuntap_reinstall"
+	TuntapReinstalled    = "tuntap_reinstalled"
+	TuntapReinstallError = "tuntap_reinstall_error"
+	UiIncompatible       = "ui_incompatible"
+	WgTcpFallback        = "wg_tcp_fallback"
+
+	ConfirmationRequired = "confirmation_required"
+	Forbidden            = "forbidden"
+	InternalError        = "internal_error"
+	InvalidRequest       = "invalid_request"
+	NotFound             = "not_found"
+	PolicyEnforced       = "policy_enforced"
+	PreconditionFailed   = "precondition_failed"
+	ProfileReadOnly      = "profile_read_only"
+	ReinstallUnavailable = "reinstall_unavailable"
+	RequestError         = "request_error"
+	ServiceStarting      = "service_starting"
+	Unauthorized         = "unauthorized"
+	UpgradeUnavailable   = "upgrade_unavailable"
+)
+
+var templates = map[string]string{
+	AuthError:            "Failed to authenticate to {name}",
+	CaptivePortal:        "Network requires captive portal sign in, {name} will reconnect once internet access is available",
+	CaptivePortalCleared: "Internet access restored, reconnecting {name}",
+	NetworkChanged:       "Network changed, reconnecting",
+	ClockSkew:            "System clock is wrong by {minutes} minutes ({direction}), correct the system time to connect",
+	ConfigurationError:   "Invalid configuration for {name}",
+	ConnectionError:      "Failed to connect to {name}",
+	CredentialError:      "Failed to load credentials for {name}",
+	DirtyTeardown:        "Network configuration was not fully removed on disconnect",
+	DnsFallback:          "DNS servers of {name} not responding, using fallback resolvers {servers}",
+	DnsFallbackCleared:   "DNS servers of {name} responding again, fallback resolvers removed",
+	DnsHijack:            "Network intercepts DNS traffic from {responder}",
+	DnsLeak:              "DNS queries for {name} are resolved outside the VPN by {resolver}",
+	DuplicateLogin:       "Disconnected from {name}, user connected from another device",
+	HandshakeTimeout:     "Handshake timeout on {name}",
+	HostFailover:         "Connection to {previous} failed, connecting to {host}",
+	Inactive:             "Disconnected due to inactivity on {name}",
+	KillSwitchError:      "Failed to enable kill switch",
+	OfflineError:         "Server is offline on {name}",
+	PreconditionError:    "Connection precondition failed: {checks}",
+	ProfileDegraded:      "Connected to {name} but no traffic is passing",
+	ProfileExpired:       "Access to {name} has expired",
+	ProfileExpiring:      "Access to {name} expires in {minutes} minutes",
+	ProfilePaused:        "Paused {name}",
+	ProfileResumed:       "Resuming {name}",
+	ProfileResync:        "Connection state lost after resume, reconnecting",
+	RegistrationPass:     "Device registration approved for {name}",
+	RegistrationRequired: "Device registration required for {name}",
+	RemoteBreaker:        "Circuit breaker for {remote} on {name} is {state}",
+	RouteOverlap:         "Connected profiles have overlapping networks: {networks}",
+	SsoAuth:              "Connection requires single sign-on authentication, complete authentication in web browser",
+	TimeoutError:         "Connection timed out on {name}",
+	TuntapReinstall:      "Reinstalling TAP driver, step {step} of {total}",
+	TuntapReinstalled:    "TAP driver reinstalled",
+	TuntapReinstallError: "Failed to reinstall TAP driver",
+	UiIncompatible:       "Client version {ui_version} is incompatible with service version {service_version}",
+	WgTcpFallback:        "UDP traffic blocked, connected to {name} over TCP",
+
+	ConfirmationRequired: "Request a dry run and provide the confirm token",
+	Forbidden:            "Permission denied",
+	InternalError:        "Internal service error",
+	InvalidRequest:       "Invalid request",
+	NotFound:             "Not found",
+	PolicyEnforced:       "Setting '{key}' enforced by policy",
+	PreconditionFailed:   "Connection precondition failed: {checks}",
+	ProfileReadOnly:      "System profile is read-only",
+	ReinstallUnavailable: "TAP driver reinstall unavailable",
+	RequestError:         "Request failed with status {status}",
+	ServiceStarting:      "Service is starting, retry shortly",
+	Unauthorized:         "Authentication required",
+	UpgradeUnavailable:   "Service upgrade unavailable, retry once all profiles are connected",
+}
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          InvalidRequest,
+	http.StatusUnauthorized:        Unauthorized,
+	http.StatusForbidden:           Forbidden,
+	http.StatusNotFound:            NotFound,
+	http.StatusInternalServerError: InternalError,
+}
+
+type Params map[string]interface{}
+
+// Event data that provides the parameters of the event message
+type Parameterized interface {
+	MessageParams() Params
+}
+
+type Message struct {
+	Code    string `json:"code"`
+	Params  Params `json:"params,omitempty"`
+	Message string `json:"message"`
+}
+
+func formatParam(val interface{}) string {
+	switch v := val.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprintf("%v", val)
+}
+
+func render(tmpl string, params Params) string {
+	if len(params) == 0 {
+		return tmpl
+	}
+
+	keys := []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	replacements := []string{}
+	for _, key := range keys {
+		replacements = append(replacements,
+			"{"+key+"}", formatParam(params[key]))
+	}
+
+	return strings.NewReplacer(replacements...).Replace(tmpl)
+}
+
+func Has(code string) bool {
+	_, ok := templates[code]
+	return ok
+}
+
+func New(code string, params Params) (msg *Message) {
+	msg = &Message{
+		Code:    code,
+		Params:  params,
+		Message: render(templates[code], params),
+	}
+
+	return
+}
+
+// Message for an event type, nil if the event is not user-facing
+func Event(typ string, data interface{}) (msg *Message) {
+	if !Has(typ) {
+		return
+	}
+
+	var params Params
+	if dataParams, ok := data.(Parameterized); ok {
+		params = dataParams.MessageParams()
+	}
+
+	msg = New(typ, params)
+
+	return
+}
+
+// Message for an error response without a specific message
+func Status(status int) (msg *Message) {
+	code := statusCodes[status]
+	if code == "" {
+		msg = New(RequestError, Params{
+			"status": status,
+		})
+		return
+	}
+
+	msg = New(code, nil)
+
+	return
+}
@@ -0,0 +1,174 @@
+// Removal of network configuration left by a previous service instance
+// that exited without disconnecting. Each category can be disabled in the
+// config and the result of the startup clean is kept for the api.
+package netclean
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Adapters = "adapters"
+	Routes   = "routes"
+	Dns      = "dns"
+	Firewall = "firewall"
+	Ipv6     = "ipv6"
+)
+
+var (
+	report     *Report
+	reportLock = sync.Mutex{}
+)
+
+type Result struct {
+	Category string   `json:"category"`
+	Enabled  bool     `json:"enabled"`
+	Cleaned  []string `json:"cleaned"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type Report struct {
+	Timestamp time.Time `json:"timestamp"`
+	Results   []*Result `json:"results"`
+}
+
+// Routes recorded on physical interfaces remain after the service exits
+// on every platform, routes on the tunnel interfaces are platform specific
+func cleanRoutes(leftover *State) (cleaned []string, err error) {
+	cleaned = []string{}
+
+	for _, route := range leftover.Routes {
+		_, network, e := net.ParseCIDR(route.Network)
+		if e != nil {
+			continue
+		}
+
+		err = netconf.Get().DeleteRoute(network, route.Iface)
+		if err != nil {
+			return
+		}
+		cleaned = append(cleaned, route.Network+" "+route.Iface)
+	}
+
+	ifaceRoutes, err := cleanIfaceRoutes()
+	if err != nil {
+		return
+	}
+	cleaned = append(cleaned, ifaceRoutes...)
+
+	return
+}
+
+// IPv6 disabled on the physical interfaces remains disabled after the
+// service exits
+func cleanIpv6(leftover *State) (cleaned []string, err error) {
+	cleaned = []string{}
+
+	if len(leftover.Ipv6) == 0 {
+		return
+	}
+
+	err = netconf.Get().RestoreIpv6(leftover.Ipv6)
+	if err != nil {
+		return
+	}
+
+	for _, state := range leftover.Ipv6 {
+		cleaned = append(cleaned, state.Iface)
+	}
+
+	return
+}
+
+func cleanFirewall(leftover *State) (cleaned []string, err error) {
+	ok, err := killswitch.Clean()
+	if err != nil {
+		return
+	}
+
+	if ok {
+		cleaned = []string{"killswitch"}
+	}
+
+	return
+}
+
+func run(category string, disabled bool, leftover *State,
+	clean func(*State) ([]string, error)) (result *Result) {
+
+	result = &Result{
+		Category: category,
+		Enabled:  !disabled,
+		Cleaned:  []string{},
+	}
+
+	if disabled {
+		logrus.WithFields(logrus.Fields{
+			"category": category,
+		}).Info("netclean: Network clean disabled")
+		return
+	}
+
+	cleaned, err := clean(leftover)
+	if cleaned != nil {
+		result.Cleaned = cleaned
+	}
+	if err != nil {
+		result.Error = err.Error()
+
+		logrus.WithFields(logrus.Fields{
+			"category": category,
+			"error":    err,
+		}).Error("netclean: Failed to clean network")
+	}
+
+	if len(result.Cleaned) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"category": category,
+			"cleaned":  result.Cleaned,
+		}).Warn("netclean: Removed leftover network configuration")
+	}
+
+	return
+}
+
+// Clean the enabled categories, must run after the kill switch rules are
+// restored and before any profile is started
+func Clean() (rpt *Report) {
+	leftover := takeLeftover()
+
+	rpt = &Report{
+		Timestamp: time.Now(),
+		Results: []*Result{
+			run(Adapters, config.Config.DisableCleanAdapters, leftover,
+				cleanAdapters),
+			run(Routes, config.Config.DisableCleanRoutes, leftover,
+				cleanRoutes),
+			run(Dns, config.Config.DisableCleanDns, leftover, cleanDns),
+			run(Firewall, config.Config.DisableCleanFirewall, leftover,
+				cleanFirewall),
+			run(Ipv6, config.Config.DisableCleanIpv6, leftover, cleanIpv6),
+		},
+	}
+
+	reportLock.Lock()
+	report = rpt
+	reportLock.Unlock()
+
+	return
+}
+
+func GetReport() (rpt *Report) {
+	reportLock.Lock()
+	rpt = report
+	reportLock.Unlock()
+
+	return
+}
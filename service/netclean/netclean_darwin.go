@@ -0,0 +1,78 @@
+package netclean
+
+import (
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	scutilConnPrefix  = "State:/Network/Pritunl/Connection/"
+	scutilSplitPrefix = "State:/Network/Service/Pritunl-Split-"
+)
+
+// Tunnel interfaces are removed by the system when the process exits
+func cleanAdapters(leftover *State) (cleaned []string, err error) {
+	return
+}
+
+// Routes are bound to the tunnel interfaces and removed with them
+func cleanIfaceRoutes() (cleaned []string, err error) {
+	return
+}
+
+func scutilKeys() (connIds, splitIds []string, err error) {
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader("open\nlist\nquit\n")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netclean: Failed to exec scutil"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if spl := strings.Split(line, scutilConnPrefix); len(spl) == 2 {
+			connIds = append(connIds, strings.TrimSpace(spl[1]))
+		} else if spl := strings.Split(
+			line, scutilSplitPrefix); len(spl) == 2 {
+
+			splitIds = append(splitIds,
+				strings.TrimSuffix(strings.TrimSpace(spl[1]), "/DNS"))
+		}
+	}
+
+	return
+}
+
+func cleanDns(leftover *State) (cleaned []string, err error) {
+	connIds, splitIds, err := scutilKeys()
+	if err != nil {
+		return
+	}
+
+	cleaned = []string{}
+
+	for _, connId := range connIds {
+		err = utils.ClearScutilDns(connId)
+		if err != nil {
+			return
+		}
+		cleaned = append(cleaned, scutilConnPrefix+connId)
+	}
+
+	for _, connId := range splitIds {
+		err = utils.ClearScutilSplitDns(connId)
+		if err != nil {
+			return
+		}
+		cleaned = append(cleaned, scutilSplitPrefix+connId+"/DNS")
+	}
+
+	return
+}
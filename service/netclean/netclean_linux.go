@@ -0,0 +1,109 @@
+package netclean
+
+import (
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// Records of the resolvconf and openresolv implementations, the openvpn
+// script adds the records with a .vpn suffix and wg-quick uses the
+// interface name with an optional tun. prefix
+var resolvconfDirs = []string{
+	"/run/resolvconf/interface",
+	"/run/resolvconf/interfaces",
+}
+
+func ifaceExists(iface string) bool {
+	_, err := net.InterfaceByName(iface)
+	return err == nil
+}
+
+// WireGuard interfaces remain after the service exits, the interface
+// names are shared with other WireGuard configurations and only the
+// interfaces recorded by the service are removed
+func cleanAdapters(leftover *State) (cleaned []string, err error) {
+	cleaned = []string{}
+
+	for _, iface := range leftover.Ifaces {
+		if !ifaceExists(iface) {
+			continue
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"Cannot find device",
+			},
+			"ip", "link", "delete", iface,
+		)
+		if err != nil {
+			return
+		}
+		cleaned = append(cleaned, iface)
+	}
+
+	return
+}
+
+// Routes are bound to the tunnel interfaces and removed with them
+func cleanIfaceRoutes() (cleaned []string, err error) {
+	return
+}
+
+// Per link DNS configuration of systemd-resolved is removed with the
+// tunnel interface, resolvconf records remain for removed interfaces
+func cleanDns(leftover *State) (cleaned []string, err error) {
+	cleaned = []string{}
+
+	resolvconf, e := exec.LookPath("resolvconf")
+	if e != nil {
+		return
+	}
+
+	wgIfaces := map[string]bool{}
+	for _, iface := range leftover.Ifaces {
+		wgIfaces[iface] = true
+	}
+
+	for _, dir := range resolvconfDirs {
+		entries, e := ioutil.ReadDir(dir)
+		if e != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+
+			iface := ""
+			if strings.HasSuffix(name, ".vpn") {
+				iface = strings.TrimSuffix(name, ".vpn")
+			} else if wgIfaces[strings.TrimPrefix(name, "tun.")] {
+				iface = strings.TrimPrefix(name, "tun.")
+			} else {
+				continue
+			}
+
+			if ifaceExists(iface) {
+				continue
+			}
+
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				resolvconf, "-d", name,
+			)
+			if err != nil {
+				return
+			}
+			cleaned = append(cleaned, name)
+		}
+	}
+
+	if len(cleaned) > 0 {
+		_, _ = utils.ExecCombinedOutput(resolvconf, "-u")
+	}
+
+	return
+}
@@ -0,0 +1,89 @@
+package netclean
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/tuntap"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+var (
+	_, linkScope, _      = net.ParseCIDR("fe80::/10")
+	_, multicastScope, _ = net.ParseCIDR("ff00::/8")
+)
+
+func cleanAdapters(leftover *State) (cleaned []string, err error) {
+	adapters, _, err := tuntap.Get()
+	if err != nil {
+		return
+	}
+
+	err = tuntap.Clean()
+	if err != nil {
+		return
+	}
+
+	cleaned = adapters
+
+	return
+}
+
+// Routes are added to the active store and are not persistent, routes of
+// the tunnel adapters remain until reboot when the service exits
+func cleanIfaceRoutes() (cleaned []string, err error) {
+	cleaned = []string{}
+
+	for _, ipv6 := range []bool{false, true} {
+		routes, e := netconf.Get().GetRoutes(ipv6)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, route := range routes {
+			if route.System ||
+				!strings.HasPrefix(strings.ToLower(route.Iface), "pritunl") ||
+				linkScope.Contains(route.Network.IP) ||
+				multicastScope.Contains(route.Network.IP) {
+
+				continue
+			}
+
+			err = netconf.Get().DeleteRoute(route.Network, route.Iface)
+			if err != nil {
+				return
+			}
+			cleaned = append(cleaned, route.Network.String()+" "+route.Iface)
+		}
+	}
+
+	return
+}
+
+// Split DNS rules are identified by the pritunl comment prefix
+func cleanDns(leftover *State) (cleaned []string, err error) {
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"Get-DnsClientNrptRule | "+
+			"Where-Object { $_.Comment -like 'pritunl-*' } | "+
+			"ForEach-Object { $_.Comment; "+
+			"$_ | Remove-DnsClientNrptRule -Force }",
+	)
+	if err != nil {
+		return
+	}
+
+	cleaned = []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			cleaned = append(cleaned, line)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,222 @@
+package netclean
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	state     = &State{}
+	stateLock = sync.Mutex{}
+)
+
+type Route struct {
+	Network string `json:"network"`
+	Iface   string `json:"iface"`
+}
+
+// Interfaces, routes and disabled IPv6 configurations of the service that
+// are not restored by the system when the service exits, everything
+// recorded at startup is left from a previous service instance
+type State struct {
+	Ifaces []string             `json:"ifaces"`
+	Routes []*Route             `json:"routes"`
+	Ipv6   []*netconf.Ipv6State `json:"ipv6"`
+}
+
+func GetStatePath() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(utils.GetWinDrive(), "ProgramData",
+			"Pritunl", "netclean.json")
+	case "darwin":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "netclean.json")
+	case "linux":
+		return filepath.Join("/", "var",
+			"lib", "pritunl-client", "netclean.json")
+	default:
+		panic("netclean: Not implemented")
+	}
+}
+
+func save() (err error) {
+	pth := GetStatePath()
+
+	if len(state.Ifaces) == 0 && len(state.Routes) == 0 &&
+		len(state.Ipv6) == 0 {
+
+		err = utils.Remove(pth)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "netclean: Failed to marshal state"),
+		}
+		return
+	}
+
+	err = utils.ExistsMkdir(filepath.Dir(pth), 0755)
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(pth, data, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "netclean: Failed to write state"),
+		}
+		return
+	}
+
+	return
+}
+
+func load() (err error) {
+	data, err := ioutil.ReadFile(GetStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netclean: Failed to read state"),
+		}
+		return
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netclean: Failed to parse state"),
+		}
+		return
+	}
+
+	return
+}
+
+// Load the recorded state, after an upgrade the state belongs to the
+// adopted profiles
+func Init() (err error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	err = load()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Take the recorded state for cleaning, the state is only left from a
+// previous instance when no profile has been started
+func takeLeftover() (leftover *State) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	leftover = state
+	state = &State{}
+
+	err := save()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("netclean: Failed to save network state")
+	}
+
+	return
+}
+
+func update(modify func()) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+
+	modify()
+
+	err := save()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("netclean: Failed to save network state")
+	}
+}
+
+func TrackIface(iface string) {
+	update(func() {
+		for _, ifc := range state.Ifaces {
+			if ifc == iface {
+				return
+			}
+		}
+		state.Ifaces = append(state.Ifaces, iface)
+	})
+}
+
+func ReleaseIface(iface string) {
+	update(func() {
+		ifaces := []string{}
+		for _, ifc := range state.Ifaces {
+			if ifc != iface {
+				ifaces = append(ifaces, ifc)
+			}
+		}
+		state.Ifaces = ifaces
+	})
+}
+
+func TrackRoute(network *net.IPNet, iface string) {
+	update(func() {
+		for _, route := range state.Routes {
+			if route.Network == network.String() && route.Iface == iface {
+				return
+			}
+		}
+		state.Routes = append(state.Routes, &Route{
+			Network: network.String(),
+			Iface:   iface,
+		})
+	})
+}
+
+func ReleaseRoute(network *net.IPNet, iface string) {
+	update(func() {
+		routes := []*Route{}
+		for _, route := range state.Routes {
+			if route.Network != network.String() || route.Iface != iface {
+				routes = append(routes, route)
+			}
+		}
+		state.Routes = routes
+	})
+}
+
+func TrackIpv6(states []*netconf.Ipv6State) {
+	update(func() {
+		state.Ipv6 = states
+	})
+}
+
+func ReleaseIpv6() {
+	update(func() {
+		state.Ipv6 = nil
+	})
+}
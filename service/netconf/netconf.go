@@ -0,0 +1,136 @@
+// Platform network configuration for DNS, routes and interfaces.
+package netconf
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+)
+
+var (
+	current     NetworkConfigurer
+	currentLock = sync.RWMutex{}
+	resetLock   = sync.Mutex{}
+	dnsResets   int64
+	netResets   int64
+)
+
+// Route of the main routing table, system routes are created for the
+// interface addresses and follow the interfaces
+type Route struct {
+	Network *net.IPNet
+	Gateway net.IP
+	Iface   string
+	Metric  int
+	System  bool
+}
+
+// IPv6 configuration of an interface before IPv6 was disabled, the method
+// and manual configuration are only used on darwin where IPv6 is
+// configured per network service
+type Ipv6State struct {
+	Iface        string `json:"iface"`
+	Method       string `json:"method,omitempty"`
+	Address      string `json:"address,omitempty"`
+	PrefixLength string `json:"prefix_length,omitempty"`
+	Router       string `json:"router,omitempty"`
+}
+
+type NetworkConfigurer interface {
+	SetSplitDns(connId, iface string, servers, domains []string) error
+	ClearSplitDns(connId, iface string) error
+	SupportsDnsServers() bool
+	SetDnsServers(connId, iface string, servers, domains []string) error
+	ClearDnsServers(connId, iface string) error
+	PinRoute(network *net.IPNet, iface string) error
+	GetRouteIfaces(network *net.IPNet) ([]string, error)
+	GetDefaultGateway(ipv6 bool) (net.IP, string, error)
+	GetRoutes(ipv6 bool) ([]*Route, error)
+	AddRoute(network *net.IPNet, iface string) error
+	AddGatewayRoute(network *net.IPNet, gateway net.IP, metric int,
+		iface string) error
+	DeleteRoute(network *net.IPNet, iface string) error
+	DisableIpv6(excludes []string) ([]*Ipv6State, error)
+	RestoreIpv6(states []*Ipv6State) error
+	ClearDns()
+	ResetDns()
+	ResetNetworking()
+	FlushDnsCache()
+	FlushDnsCacheFast()
+	DescribeReset(networking bool) []string
+}
+
+func Get() (cnf NetworkConfigurer) {
+	currentLock.RLock()
+	cnf = current
+	currentLock.RUnlock()
+
+	return
+}
+
+// Replace the active configurer, used to substitute a Recorder
+func Set(cnf NetworkConfigurer) (prev NetworkConfigurer) {
+	currentLock.Lock()
+	prev = current
+	current = cnf
+	currentLock.Unlock()
+
+	return
+}
+
+// Number of DNS and networking resets performed since the service started
+func GetResetCounts() (dns, networking int64) {
+	dns = atomic.LoadInt64(&dnsResets)
+	networking = atomic.LoadInt64(&netResets)
+	return
+}
+
+func init() {
+	current = newConfigurer()
+}
+
+// Routes are managed with the native route APIs of the platform unless the
+// route commands are enabled in the configuration
+func nativeRoutes() bool {
+	return !config.Config.RouteCommands
+}
+
+// Replace the routes for the network on other interfaces, used on
+// platforms without an atomic route replace
+func pinRoute(c NetworkConfigurer, network *net.IPNet, iface string) (
+	err error) {
+
+	ifaces, err := c.GetRouteIfaces(network)
+	if err != nil {
+		return
+	}
+
+	for _, routeIface := range ifaces {
+		if routeIface == iface {
+			continue
+		}
+
+		err = c.DeleteRoute(network, routeIface)
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.AddRoute(network, iface)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func contains(items []string, item string) bool {
+	for _, itm := range items {
+		if itm == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,451 @@
+package netconf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type darwinConfigurer struct{}
+
+func newConfigurer() NetworkConfigurer {
+	return &darwinConfigurer{}
+}
+
+func (c *darwinConfigurer) SetSplitDns(connId, iface string,
+	servers, domains []string) (err error) {
+
+	if len(servers) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"conn_id": connId,
+		}).Warn("netconf: No tunnel DNS servers for split DNS")
+		return
+	}
+
+	err = utils.SetScutilSplitDns(connId, servers, domains)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) ClearSplitDns(connId, iface string) (
+	err error) {
+
+	err = utils.ClearScutilSplitDns(connId)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// The service DNS configuration is replaced for every tunnel mode, the
+// connection key is removed on disconnect
+func (c *darwinConfigurer) SupportsDnsServers() bool {
+	return true
+}
+
+func (c *darwinConfigurer) SetDnsServers(connId, iface string,
+	servers, domains []string) (err error) {
+
+	err = utils.SetScutilDns(connId, servers, domains)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) ClearDnsServers(connId, iface string) (
+	err error) {
+
+	err = utils.ClearScutilDns(connId)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) PinRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	err = pinRoute(c, network, iface)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Route table is read from the routing socket, route get output is not
+// parsed when the route commands are configured
+func (c *darwinConfigurer) GetRouteIfaces(network *net.IPNet) (
+	ifaces []string, err error) {
+
+	ifaces, err = nativeGetRouteIfaces(network)
+	return
+}
+
+func (c *darwinConfigurer) GetDefaultGateway(ipv6 bool) (gateway net.IP,
+	iface string, err error) {
+
+	gateway, iface, err = nativeGetDefaultGateway(ipv6)
+	return
+}
+
+func (c *darwinConfigurer) GetRoutes(ipv6 bool) (routes []*Route,
+	err error) {
+
+	routes, err = nativeGetRoutes(ipv6)
+	return
+}
+
+func (c *darwinConfigurer) AddRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, nil, 0, iface)
+		return
+	}
+
+	family := "-inet"
+	if network.IP.To4() == nil {
+		family = "-inet6"
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"File exists",
+		},
+		"/sbin/route", "-n", "add", family, "-net", network.String(),
+		"-interface", iface,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) AddGatewayRoute(network *net.IPNet,
+	gateway net.IP, metric int, iface string) (err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, gateway, metric, iface)
+		return
+	}
+
+	family := "-inet"
+	if network.IP.To4() == nil {
+		family = "-inet6"
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"File exists",
+		},
+		"/sbin/route", "-n", "add", family, "-net", network.String(),
+		gateway.String(),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) DeleteRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeDeleteRoute(network, iface)
+		return
+	}
+
+	family := "-inet"
+	if network.IP.To4() == nil {
+		family = "-inet6"
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"not in table",
+		},
+		"/sbin/route", "-n", "delete", family, "-net", network.String(),
+		"-interface", iface,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) DisableIpv6(excludes []string) (
+	states []*Ipv6State, err error) {
+
+	states = []*Ipv6State{}
+
+	netServices, err := platform.GetNetworkServices()
+	if err != nil {
+		return
+	}
+
+	for _, netService := range netServices {
+		if contains(excludes, netService) {
+			continue
+		}
+
+		conf, e := platform.GetNetworkServiceIpv6(netService)
+		if e != nil || conf.Method == "" {
+			continue
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"/usr/sbin/networksetup",
+			"-setv6off",
+			netService,
+		)
+		if err != nil {
+			_ = c.RestoreIpv6(states)
+			states = []*Ipv6State{}
+			return
+		}
+
+		states = append(states, &Ipv6State{
+			Iface:        netService,
+			Method:       conf.Method,
+			Address:      conf.Address,
+			PrefixLength: conf.PrefixLength,
+			Router:       conf.Router,
+		})
+	}
+
+	return
+}
+
+// Restore the configuration method of each network service, a manual
+// configuration without an address is restored as automatic
+func (c *darwinConfigurer) RestoreIpv6(states []*Ipv6State) (err error) {
+	for _, state := range states {
+		args := []string{"-setv6automatic", state.Iface}
+
+		switch state.Method {
+		case "LinkLocal":
+			args = []string{"-setv6LinkLocal", state.Iface}
+			break
+		case "Manual":
+			if state.Address != "" && state.PrefixLength != "" {
+				args = []string{"-setv6manual", state.Iface,
+					state.Address, state.PrefixLength}
+				if state.Router != "" {
+					args = append(args, state.Router)
+				}
+			}
+			break
+		}
+
+		_, e := utils.ExecCombinedOutputLogged(
+			nil,
+			"/usr/sbin/networksetup",
+			args...,
+		)
+		if e != nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+func (c *darwinConfigurer) ClearDns() {
+	utils.MacDnsLock.Lock()
+	defer utils.MacDnsLock.Unlock()
+
+	logrus.Info("netconf: Clearing DNS")
+
+	netServices, err := platform.GetNetworkServices()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Failed to get network services")
+		return
+	}
+
+	for _, netService := range netServices {
+		_, _ = utils.ExecCombinedOutputLogged(
+			nil,
+			"/usr/sbin/networksetup",
+			"-setdnsservers",
+			netService,
+			"Empty",
+		)
+	}
+
+	command.Command("dscacheutil", "-flushcache").Run()
+	command.Command("killall", "-HUP", "mDNSResponder").Run()
+}
+
+func (c *darwinConfigurer) ResetDns() {
+	atomic.AddInt64(&dnsResets, 1)
+
+	logrus.Info("netconf: Reseting DNS")
+
+	resetLock.Lock()
+	defer resetLock.Unlock()
+
+	_ = utils.RefreshScutilDns()
+}
+
+func (c *darwinConfigurer) ResetNetworking() {
+	atomic.AddInt64(&netResets, 1)
+
+	logrus.Info("netconf: Reseting networking")
+
+	resetLock.Lock()
+	defer resetLock.Unlock()
+
+	cmd := command.Command("/usr/sbin/networksetup", "-getcurrentlocation")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netconf: Failed to get network location"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Reset networking error")
+		return
+	}
+
+	location := strings.TrimSpace(string(output))
+
+	if location == "pritunl-reset" {
+		return
+	}
+
+	err = command.Command(
+		"/usr/sbin/networksetup",
+		"-createlocation",
+		"pritunl-reset",
+	).Run()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netconf: Failed to create network location"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Reset networking error")
+	}
+
+	command.Command("route", "-n", "flush").Run()
+
+	err = command.Command(
+		"/usr/sbin/networksetup",
+		"-switchtolocation",
+		"pritunl-reset",
+	).Run()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netconf: Failed to set network location"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Reset networking error")
+	}
+
+	command.Command("route", "-n", "flush").Run()
+
+	err = command.Command(
+		"/usr/sbin/networksetup",
+		"-switchtolocation",
+		location,
+	).Run()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netconf: Failed to set network location"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Reset networking error")
+	}
+
+	command.Command("route", "-n", "flush").Run()
+
+	err = command.Command(
+		"/usr/sbin/networksetup",
+		"-deletelocation",
+		"pritunl-reset",
+	).Run()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "netconf: Failed to delete network location"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netconf: Reset networking error")
+	}
+}
+
+func (c *darwinConfigurer) FlushDnsCache() {
+	utils.FlushMacDnsCache()
+}
+
+func (c *darwinConfigurer) DescribeReset(networking bool) (
+	actions []string) {
+
+	actions = []string{
+		"Restore system DNS configuration",
+	}
+
+	if networking {
+		netServices, err := platform.GetNetworkServices()
+		if err == nil {
+			for _, netService := range netServices {
+				actions = append(actions, fmt.Sprintf(
+					"Clear DNS servers on network service '%s'", netService))
+			}
+		}
+
+		actions = append(actions,
+			"Switch to temporary network location and back",
+			"Flush routing table",
+		)
+	}
+
+	actions = append(actions, "Flush DNS cache")
+
+	return
+}
+
+func (c *darwinConfigurer) FlushDnsCacheFast() {
+	utils.FlushMacDnsCacheFast()
+}
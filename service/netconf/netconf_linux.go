@@ -0,0 +1,392 @@
+package netconf
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/container"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type linuxConfigurer struct{}
+
+func newConfigurer() NetworkConfigurer {
+	return &linuxConfigurer{}
+}
+
+func (c *linuxConfigurer) SetSplitDns(connId, iface string,
+	servers, domains []string) (err error) {
+
+	if iface == "" {
+		return
+	}
+
+	args := []string{"domain", iface}
+	for _, domain := range domains {
+		args = append(args, "~"+domain)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "resolvectl", args...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) ClearSplitDns(connId, iface string) (err error) {
+	if iface == "" {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"does not exist",
+			"Failed to resolve interface",
+		},
+		"resolvectl", "domain", iface, "",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Link DNS servers are replaced with systemd-resolved, the servers added
+// to resolvconf by OpenVPN and wg-quick cannot be replaced
+func (c *linuxConfigurer) SupportsDnsServers() bool {
+	_, err := exec.LookPath("resolvectl")
+	return err == nil
+}
+
+func (c *linuxConfigurer) SetDnsServers(connId, iface string,
+	servers, domains []string) (err error) {
+
+	if iface == "" || !c.SupportsDnsServers() {
+		err = &errortypes.PreconditionError{
+			errors.New("netconf: Replacing DNS servers requires " +
+				"systemd-resolved"),
+		}
+		return
+	}
+
+	args := []string{"dns", iface}
+	for _, server := range servers {
+		ip := net.ParseIP(server)
+		if ip != nil {
+			args = append(args, ip.String())
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "resolvectl", args...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) ClearDnsServers(connId, iface string) (
+	err error) {
+
+	if iface == "" || !c.SupportsDnsServers() {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"does not exist",
+			"Failed to resolve interface",
+		},
+		"resolvectl", "dns", iface, "",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) PinRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, nil, 0, iface, true)
+		return
+	}
+
+	args := []string{"route", "replace", network.String(), "dev", iface}
+	if network.IP.To4() == nil {
+		args = append([]string{"-6"}, args...)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(nil, "ip", args...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) GetRouteIfaces(network *net.IPNet) (
+	ifaces []string, err error) {
+
+	if nativeRoutes() {
+		ifaces, err = nativeGetRouteIfaces(network)
+		return
+	}
+
+	ifaces = []string{}
+
+	args := []string{"route", "show", "exact", network.String()}
+	if network.IP.To4() == nil {
+		args = append([]string{"-6"}, args...)
+	}
+
+	output, err := utils.ExecOutput("ip", args...)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == "dev" {
+				ifaces = append(ifaces, fields[i+1])
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) GetDefaultGateway(ipv6 bool) (gateway net.IP,
+	iface string, err error) {
+
+	gateway, iface, err = nativeGetDefaultGateway(ipv6)
+	return
+}
+
+func (c *linuxConfigurer) GetRoutes(ipv6 bool) (routes []*Route,
+	err error) {
+
+	routes, err = nativeGetRoutes(ipv6)
+	return
+}
+
+func (c *linuxConfigurer) AddRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	err = c.PinRoute(network, iface)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) AddGatewayRoute(network *net.IPNet,
+	gateway net.IP, metric int, iface string) (err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, gateway, metric, iface, false)
+		return
+	}
+
+	args := []string{"route", "add", network.String(),
+		"via", gateway.String()}
+	if metric != 0 {
+		args = append(args, "metric", strconv.Itoa(metric))
+	}
+	args = append(args, "dev", iface)
+	if network.IP.To4() == nil {
+		args = append([]string{"-6"}, args...)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"File exists",
+		},
+		"ip", args...,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) DeleteRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeDeleteRoute(network, iface)
+		return
+	}
+
+	args := []string{"route", "del", network.String(), "dev", iface}
+	if network.IP.To4() == nil {
+		args = append([]string{"-6"}, args...)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such process",
+			"Cannot find device",
+		},
+		"ip", args...,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) DisableIpv6(excludes []string) (
+	states []*Ipv6State, err error) {
+
+	states = []*Ipv6State{}
+
+	adapters, err := platform.GetAdapters()
+	if err != nil {
+		return
+	}
+
+	for _, adapter := range adapters {
+		if !adapter.Up || adapter.Name == "lo" ||
+			contains(excludes, adapter.Name) {
+
+			continue
+		}
+
+		pth := filepath.Join("/proc/sys/net/ipv6/conf", adapter.Name,
+			"disable_ipv6")
+
+		data, e := ioutil.ReadFile(pth)
+		if e != nil || strings.TrimSpace(string(data)) != "0" {
+			continue
+		}
+
+		err = ioutil.WriteFile(pth, []byte("1"), 0644)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "netconf: Failed to disable ipv6"),
+			}
+			_ = c.RestoreIpv6(states)
+			states = []*Ipv6State{}
+			return
+		}
+
+		states = append(states, &Ipv6State{
+			Iface: adapter.Name,
+		})
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) RestoreIpv6(states []*Ipv6State) (err error) {
+	for _, state := range states {
+		pth := filepath.Join("/proc/sys/net/ipv6/conf", state.Iface,
+			"disable_ipv6")
+
+		e := ioutil.WriteFile(pth, []byte("0"), 0644)
+		if e != nil && !os.IsNotExist(e) {
+			err = &errortypes.WriteError{
+				errors.Wrap(e, "netconf: Failed to restore ipv6"),
+			}
+		}
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) ClearDns() {
+}
+
+func (c *linuxConfigurer) ResetDns() {
+	atomic.AddInt64(&dnsResets, 1)
+}
+
+func (c *linuxConfigurer) ResetNetworking() {
+	atomic.AddInt64(&netResets, 1)
+
+	// NetworkManager belongs to the host when running in a container
+	if container.Detected() {
+		logrus.Info("netconf: Skipping network reset in container")
+		return
+	}
+
+	logrus.Info("netconf: Reseting networking")
+
+	resetLock.Lock()
+	defer resetLock.Unlock()
+
+	cmd := command.Command("/usr/bin/nmcli", "networking")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	output, _ := cmd.Output()
+	if strings.Contains(string(output), "enabled") {
+		command.Command("/usr/bin/nmcli", "connection", "reload").Run()
+		command.Command("/usr/bin/nmcli", "networking", "off").Run()
+		command.Command("/usr/bin/nmcli", "networking", "on").Run()
+	}
+}
+
+// Repeated cache flushing is only required on macOS
+func (c *linuxConfigurer) FlushDnsCache() {
+}
+
+func (c *linuxConfigurer) DescribeReset(networking bool) (
+	actions []string) {
+
+	actions = []string{}
+
+	if !networking || container.Detected() {
+		return
+	}
+
+	cmd := command.Command("/usr/bin/nmcli", "networking")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	output, _ := cmd.Output()
+	if strings.Contains(string(output), "enabled") {
+		actions = append(actions,
+			"Reload NetworkManager connections",
+			"Restart NetworkManager networking",
+		)
+	}
+
+	return
+}
+
+func (c *linuxConfigurer) FlushDnsCacheFast() {
+	command.Command("systemd-resolve", "--flush-caches").Run()
+	command.Command("resolvectl", "--flush-caches").Run()
+}
@@ -0,0 +1,474 @@
+package netconf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type windowsConfigurer struct{}
+
+// Single quoted PowerShell string, quotes are escaped by doubling
+func psQuote(val string) string {
+	return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+}
+
+func newConfigurer() NetworkConfigurer {
+	return &windowsConfigurer{}
+}
+
+func (c *windowsConfigurer) SetSplitDns(connId, iface string,
+	servers, domains []string) (err error) {
+
+	if len(servers) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"conn_id": connId,
+		}).Warn("netconf: No tunnel DNS servers for split DNS")
+		return
+	}
+
+	// Only addresses and hostnames are passed to the command, the quoted
+	// values are escaped as well
+	namespaces := []string{}
+	for _, domain := range domains {
+		if !utils.ValidDomain(domain) {
+			continue
+		}
+		namespaces = append(namespaces, psQuote("."+domain))
+	}
+
+	serversArg := []string{}
+	for _, server := range servers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			continue
+		}
+		serversArg = append(serversArg, psQuote(ip.String()))
+	}
+
+	if len(namespaces) == 0 || len(serversArg) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"conn_id": connId,
+			"servers": servers,
+			"domains": domains,
+		}).Warn("netconf: No valid split DNS servers or domains")
+		return
+	}
+
+	_ = c.ClearSplitDns(connId, iface)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Add-DnsClientNrptRule -Namespace %s -NameServers %s "+
+				"-Comment %s",
+			strings.Join(namespaces, ","),
+			strings.Join(serversArg, ","),
+			psQuote("pritunl-"+connId),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) ClearSplitDns(connId, iface string) (
+	err error) {
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Get-DnsClientNrptRule | Where-Object "+
+				"{ $_.Comment -eq %s } | "+
+				"Remove-DnsClientNrptRule -Force",
+			psQuote("pritunl-"+connId),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) SupportsDnsServers() bool {
+	return true
+}
+
+func (c *windowsConfigurer) SetDnsServers(connId, iface string,
+	servers, domains []string) (err error) {
+
+	if iface == "" {
+		err = &errortypes.PreconditionError{
+			errors.New("netconf: Replacing DNS servers requires the " +
+				"tunnel interface"),
+		}
+		return
+	}
+
+	serversArg := []string{}
+	for _, server := range servers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			continue
+		}
+		serversArg = append(serversArg, psQuote(ip.String()))
+	}
+
+	if len(serversArg) == 0 {
+		err = c.ClearDnsServers(connId, iface)
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Set-DnsClientServerAddress -InterfaceAlias %s "+
+				"-ServerAddresses (%s)",
+			psQuote(iface),
+			strings.Join(serversArg, ","),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) ClearDnsServers(connId, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"No MSFT_DNSClientServerAddress objects found",
+		},
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Set-DnsClientServerAddress -InterfaceAlias %s "+
+				"-ResetServerAddresses",
+			psQuote(iface),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) PinRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	err = pinRoute(c, network, iface)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) GetRouteIfaces(network *net.IPNet) (
+	ifaces []string, err error) {
+
+	if nativeRoutes() {
+		ifaces, err = nativeGetRouteIfaces(network)
+		return
+	}
+
+	ifaces = []string{}
+
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Get-NetRoute -DestinationPrefix '%s' "+
+				"-ErrorAction SilentlyContinue | "+
+				"Select-Object -ExpandProperty InterfaceAlias",
+			network.String(),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			ifaces = append(ifaces, line)
+		}
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) GetDefaultGateway(ipv6 bool) (gateway net.IP,
+	iface string, err error) {
+
+	gateway, iface, err = nativeGetDefaultGateway(ipv6)
+	return
+}
+
+func (c *windowsConfigurer) GetRoutes(ipv6 bool) (routes []*Route,
+	err error) {
+
+	routes, err = nativeGetRoutes(ipv6)
+	return
+}
+
+func (c *windowsConfigurer) AddRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, nil, 0, iface)
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"already exists",
+		},
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"New-NetRoute -DestinationPrefix '%s' -InterfaceAlias '%s' "+
+				"-PolicyStore ActiveStore",
+			network.String(),
+			strings.ReplaceAll(iface, "'", "''"),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) AddGatewayRoute(network *net.IPNet,
+	gateway net.IP, metric int, iface string) (err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeAddRoute(network, gateway, metric, iface)
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"already exists",
+		},
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"New-NetRoute -DestinationPrefix '%s' -InterfaceAlias '%s' "+
+				"-NextHop '%s' -RouteMetric %d -PolicyStore ActiveStore",
+			network.String(),
+			strings.ReplaceAll(iface, "'", "''"),
+			gateway.String(),
+			metric,
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) DeleteRoute(network *net.IPNet, iface string) (
+	err error) {
+
+	if iface == "" {
+		return
+	}
+
+	if nativeRoutes() {
+		err = nativeDeleteRoute(network, iface)
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"No matching MSFT_NetRoute",
+		},
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		fmt.Sprintf(
+			"Remove-NetRoute -DestinationPrefix '%s' -InterfaceAlias '%s' "+
+				"-Confirm:$false",
+			network.String(),
+			strings.ReplaceAll(iface, "'", "''"),
+		),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) DisableIpv6(excludes []string) (
+	states []*Ipv6State, err error) {
+
+	states = []*Ipv6State{}
+
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"Get-NetAdapterBinding -ComponentID ms_tcpip6 | "+
+			"Where-Object { $_.Enabled } | "+
+			"Select-Object -ExpandProperty Name",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, name := range strings.Split(output, "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" || contains(excludes, name) {
+			continue
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"powershell.exe",
+			"-NoProfile",
+			"-Command",
+			fmt.Sprintf(
+				"Disable-NetAdapterBinding -Name '%s' -ComponentID ms_tcpip6",
+				strings.ReplaceAll(name, "'", "''"),
+			),
+		)
+		if err != nil {
+			_ = c.RestoreIpv6(states)
+			states = []*Ipv6State{}
+			return
+		}
+
+		states = append(states, &Ipv6State{
+			Iface: name,
+		})
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) RestoreIpv6(states []*Ipv6State) (err error) {
+	for _, state := range states {
+		_, e := utils.ExecCombinedOutputLogged(
+			nil,
+			"powershell.exe",
+			"-NoProfile",
+			"-Command",
+			fmt.Sprintf(
+				"Enable-NetAdapterBinding -Name '%s' -ComponentID ms_tcpip6",
+				strings.ReplaceAll(state.Iface, "'", "''"),
+			),
+		)
+		if e != nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+func (c *windowsConfigurer) ClearDns() {
+}
+
+func (c *windowsConfigurer) ResetDns() {
+	atomic.AddInt64(&dnsResets, 1)
+}
+
+func (c *windowsConfigurer) ResetNetworking() {
+	atomic.AddInt64(&netResets, 1)
+
+	logrus.Info("netconf: Reseting networking")
+
+	resetLock.Lock()
+	defer resetLock.Unlock()
+
+	command.Command("netsh", "interface", "ip", "delete",
+		"destinationcache").Run()
+	command.Command("netsh", "interface", "ipv6", "delete",
+		"destinationcache").Run()
+	command.Command("ipconfig", "/release").Run()
+	command.Command("ipconfig", "/renew").Run()
+	command.Command("ipconfig", "/release6").Run()
+	command.Command("ipconfig", "/renew6").Run()
+	command.Command("arp", "-d", "*").Run()
+	command.Command("nbtstat", "-R").Run()
+	command.Command("nbtstat", "-RR").Run()
+	command.Command("ipconfig", "/flushdns").Run()
+	command.Command("nbtstat", "/registerdns").Run()
+}
+
+// Repeated cache flushing is only required on macOS
+func (c *windowsConfigurer) FlushDnsCache() {
+}
+
+func (c *windowsConfigurer) DescribeReset(networking bool) (
+	actions []string) {
+
+	actions = []string{}
+
+	if !networking {
+		return
+	}
+
+	actions = append(actions,
+		"Clear destination cache",
+		"Release and renew DHCP leases on all adapters",
+		"Release and renew DHCPv6 leases on all adapters",
+		"Clear ARP cache",
+		"Reload NetBIOS name cache",
+		"Flush DNS cache",
+		"Register DNS names",
+	)
+
+	return
+}
+
+func (c *windowsConfigurer) FlushDnsCacheFast() {
+	command.Command("ipconfig", "/flushdns").Run()
+}
@@ -0,0 +1,148 @@
+package netconf
+
+import (
+	"net"
+	"sync"
+)
+
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Records calls without modifying the system, errors can be set per method
+// to simulate command failures.
+type Recorder struct {
+	Calls  []*Call
+	Errors map[string]error
+	lock   sync.Mutex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		Calls:  []*Call{},
+		Errors: map[string]error{},
+	}
+}
+
+func (r *Recorder) record(method string, args ...interface{}) (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.Calls = append(r.Calls, &Call{
+		Method: method,
+		Args:   args,
+	})
+	err = r.Errors[method]
+
+	return
+}
+
+func (r *Recorder) GetCalls() (calls []*Call) {
+	r.lock.Lock()
+	calls = make([]*Call, len(r.Calls))
+	copy(calls, r.Calls)
+	r.lock.Unlock()
+
+	return
+}
+
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.Calls = []*Call{}
+	r.lock.Unlock()
+}
+
+func (r *Recorder) SetSplitDns(connId, iface string,
+	servers, domains []string) error {
+
+	return r.record("SetSplitDns", connId, iface, servers, domains)
+}
+
+func (r *Recorder) ClearSplitDns(connId, iface string) error {
+	return r.record("ClearSplitDns", connId, iface)
+}
+
+func (r *Recorder) SupportsDnsServers() bool {
+	_ = r.record("SupportsDnsServers")
+	return true
+}
+
+func (r *Recorder) SetDnsServers(connId, iface string,
+	servers, domains []string) error {
+
+	return r.record("SetDnsServers", connId, iface, servers, domains)
+}
+
+func (r *Recorder) ClearDnsServers(connId, iface string) error {
+	return r.record("ClearDnsServers", connId, iface)
+}
+
+func (r *Recorder) PinRoute(network *net.IPNet, iface string) error {
+	return r.record("PinRoute", network.String(), iface)
+}
+
+func (r *Recorder) GetRouteIfaces(network *net.IPNet) ([]string, error) {
+	return []string{}, r.record("GetRouteIfaces", network.String())
+}
+
+func (r *Recorder) GetDefaultGateway(ipv6 bool) (net.IP, string, error) {
+	return nil, "", r.record("GetDefaultGateway", ipv6)
+}
+
+func (r *Recorder) GetRoutes(ipv6 bool) ([]*Route, error) {
+	return []*Route{}, r.record("GetRoutes", ipv6)
+}
+
+func (r *Recorder) AddRoute(network *net.IPNet, iface string) error {
+	return r.record("AddRoute", network.String(), iface)
+}
+
+func (r *Recorder) AddGatewayRoute(network *net.IPNet, gateway net.IP,
+	metric int, iface string) error {
+
+	return r.record("AddGatewayRoute", network.String(), gateway.String(),
+		metric, iface)
+}
+
+func (r *Recorder) DeleteRoute(network *net.IPNet, iface string) error {
+	return r.record("DeleteRoute", network.String(), iface)
+}
+
+func (r *Recorder) DisableIpv6(excludes []string) ([]*Ipv6State, error) {
+	return []*Ipv6State{}, r.record("DisableIpv6", excludes)
+}
+
+func (r *Recorder) RestoreIpv6(states []*Ipv6State) error {
+	ifaces := []string{}
+	for _, state := range states {
+		ifaces = append(ifaces, state.Iface)
+	}
+
+	return r.record("RestoreIpv6", ifaces)
+}
+
+func (r *Recorder) ClearDns() {
+	_ = r.record("ClearDns")
+}
+
+func (r *Recorder) ResetDns() {
+	_ = r.record("ResetDns")
+}
+
+func (r *Recorder) ResetNetworking() {
+	_ = r.record("ResetNetworking")
+}
+
+func (r *Recorder) FlushDnsCache() {
+	_ = r.record("FlushDnsCache")
+}
+
+func (r *Recorder) FlushDnsCacheFast() {
+	_ = r.record("FlushDnsCacheFast")
+}
+
+func (r *Recorder) DescribeReset(networking bool) []string {
+	_ = r.record("DescribeReset", networking)
+	return []string{}
+}
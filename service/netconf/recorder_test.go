@@ -0,0 +1,104 @@
+package netconf
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+var _ NetworkConfigurer = (*Recorder)(nil)
+
+func TestRecorderCalls(t *testing.T) {
+	rec := NewRecorder()
+
+	_, network, _ := net.ParseCIDR("10.100.0.0/16")
+	gateway := net.ParseIP("192.168.1.1")
+
+	err := rec.SetSplitDns("conn", "tun0", []string{"10.0.0.1"},
+		[]string{"example.com"})
+	if err != nil {
+		t.Fatalf("SetSplitDns: %s", err)
+	}
+
+	err = rec.AddGatewayRoute(network, gateway, 10, "eth0")
+	if err != nil {
+		t.Fatalf("AddGatewayRoute: %s", err)
+	}
+
+	rec.FlushDnsCache()
+
+	expected := []*Call{
+		{
+			Method: "SetSplitDns",
+			Args: []interface{}{"conn", "tun0", []string{"10.0.0.1"},
+				[]string{"example.com"}},
+		},
+		{
+			Method: "AddGatewayRoute",
+			Args: []interface{}{"10.100.0.0/16", "192.168.1.1",
+				10, "eth0"},
+		},
+		{
+			Method: "FlushDnsCache",
+			Args:   nil,
+		},
+	}
+
+	calls := rec.GetCalls()
+	if !reflect.DeepEqual(calls, expected) {
+		for _, call := range calls {
+			t.Logf("call %s %v", call.Method, call.Args)
+		}
+		t.Fatalf("unexpected calls")
+	}
+
+	rec.Reset()
+	if len(rec.GetCalls()) != 0 {
+		t.Fatalf("calls not cleared by Reset")
+	}
+}
+
+func TestRecorderErrors(t *testing.T) {
+	rec := NewRecorder()
+	addErr := errors.New("add failed")
+	rec.Errors["AddRoute"] = addErr
+
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+
+	err := rec.AddRoute(network, "tun0")
+	if err != addErr {
+		t.Fatalf("AddRoute error %v", err)
+	}
+
+	err = rec.DeleteRoute(network, "tun0")
+	if err != nil {
+		t.Fatalf("DeleteRoute error %v", err)
+	}
+
+	calls := rec.GetCalls()
+	if len(calls) != 2 || calls[0].Method != "AddRoute" ||
+		calls[1].Method != "DeleteRoute" {
+
+		t.Fatalf("failed calls not recorded")
+	}
+}
+
+func TestRecorderSet(t *testing.T) {
+	rec := NewRecorder()
+
+	prev := Set(rec)
+	defer Set(prev)
+
+	if Get() != rec {
+		t.Fatalf("recorder not active after Set")
+	}
+
+	Get().ResetDns()
+
+	calls := rec.GetCalls()
+	if len(calls) != 1 || calls[0].Method != "ResetDns" {
+		t.Fatalf("call through Get not recorded")
+	}
+}
@@ -0,0 +1,346 @@
+package netconf
+
+import (
+	"net"
+	"os"
+	"sync/atomic"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/net/route"
+	"golang.org/x/sys/unix"
+)
+
+var routeSeq int32
+
+func routeAddr(ip net.IP) route.Addr {
+	if ip4 := ip.To4(); ip4 != nil {
+		addr := &route.Inet4Addr{}
+		copy(addr.IP[:], ip4)
+		return addr
+	}
+
+	addr := &route.Inet6Addr{}
+	copy(addr.IP[:], ip.To16())
+	return addr
+}
+
+func addrIp(addr route.Addr) net.IP {
+	switch a := addr.(type) {
+	case *route.Inet4Addr:
+		return net.IP(a.IP[:])
+	case *route.Inet6Addr:
+		return net.IP(a.IP[:])
+	}
+	return nil
+}
+
+// Write a message to the routing socket, the kernel reports failures as
+// the errno of the write
+func routeRequest(typ int, network *net.IPNet, gateway net.IP,
+	ifc *net.Interface) (err error) {
+
+	flags := unix.RTF_UP | unix.RTF_STATIC
+	var gatewayAddr route.Addr
+	if gateway != nil {
+		flags |= unix.RTF_GATEWAY
+		gatewayAddr = routeAddr(gateway)
+	} else {
+		gatewayAddr = &route.LinkAddr{
+			Index: ifc.Index,
+			Name:  ifc.Name,
+		}
+	}
+
+	msg := &route.RouteMessage{
+		Version: unix.RTM_VERSION,
+		Type:    typ,
+		Flags:   flags,
+		Index:   ifc.Index,
+		ID:      uintptr(os.Getpid()),
+		Seq:     int(atomic.AddInt32(&routeSeq, 1)),
+		Addrs: []route.Addr{
+			unix.RTAX_DST:     routeAddr(network.IP),
+			unix.RTAX_GATEWAY: gatewayAddr,
+			unix.RTAX_NETMASK: routeAddr(net.IP(network.Mask)),
+		},
+	}
+
+	buf, err := msg.Marshal()
+	if err != nil {
+		return
+	}
+
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return
+	}
+	defer unix.Close(fd)
+
+	_, err = unix.Write(fd, buf)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func nativeAddRoute(network *net.IPNet, gateway net.IP, metric int,
+	iface string) (err error) {
+
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to find route interface"),
+		}
+		return
+	}
+
+	err = routeRequest(unix.RTM_ADD, network, gateway, ifc)
+	if err != nil {
+		if err == unix.EEXIST {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "netconf: Failed to add route %s",
+				network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeDeleteRoute(network *net.IPNet, iface string) (err error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	err = routeRequest(unix.RTM_DELETE, network, nil, ifc)
+	if err != nil {
+		if err == unix.ESRCH {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "netconf: Failed to delete route %s",
+				network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeGetRouteIfaces(network *net.IPNet) (ifaces []string, err error) {
+	ifaces = []string{}
+
+	family := unix.AF_INET
+	if network.IP.To4() == nil {
+		family = unix.AF_INET6
+	}
+	ones, bits := network.Mask.Size()
+
+	rib, err := route.FetchRIB(family, route.RIBTypeRoute, 0)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netconf: Failed to parse routes"),
+		}
+		return
+	}
+
+	for _, m := range msgs {
+		msg, ok := m.(*route.RouteMessage)
+		if !ok || len(msg.Addrs) <= unix.RTAX_NETMASK {
+			continue
+		}
+
+		dst := addrIp(msg.Addrs[unix.RTAX_DST])
+		if dst == nil || !dst.Equal(network.IP) {
+			continue
+		}
+
+		// Host routes are stored without a netmask
+		maskOnes := 0
+		if mask := addrIp(msg.Addrs[unix.RTAX_NETMASK]); mask != nil {
+			if len(mask) == net.IPv4len {
+				maskOnes, _ = net.IPMask(mask).Size()
+			} else {
+				maskOnes, _ = net.IPMask(mask.To16()).Size()
+			}
+		} else if msg.Flags&unix.RTF_HOST != 0 {
+			maskOnes = bits
+		}
+		if maskOnes != ones {
+			continue
+		}
+
+		ifc, e := net.InterfaceByIndex(msg.Index)
+		if e != nil {
+			continue
+		}
+		ifaces = append(ifaces, ifc.Name)
+	}
+
+	return
+}
+
+func nativeGetDefaultGateway(ipv6 bool) (gateway net.IP, iface string,
+	err error) {
+
+	family := unix.AF_INET
+	if ipv6 {
+		family = unix.AF_INET6
+	}
+
+	rib, err := route.FetchRIB(family, route.RIBTypeRoute, 0)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netconf: Failed to parse routes"),
+		}
+		return
+	}
+
+	for _, m := range msgs {
+		msg, ok := m.(*route.RouteMessage)
+		if !ok || len(msg.Addrs) <= unix.RTAX_NETMASK {
+			continue
+		}
+
+		// Interface scoped default routes are only used by sockets bound
+		// to the interface
+		if msg.Flags&unix.RTF_GATEWAY == 0 ||
+			msg.Flags&unix.RTF_IFSCOPE != 0 {
+
+			continue
+		}
+
+		dst := addrIp(msg.Addrs[unix.RTAX_DST])
+		if dst == nil || !dst.IsUnspecified() {
+			continue
+		}
+
+		if mask := addrIp(msg.Addrs[unix.RTAX_NETMASK]); mask != nil &&
+			!mask.IsUnspecified() {
+
+			continue
+		}
+
+		gw := addrIp(msg.Addrs[unix.RTAX_GATEWAY])
+		if gw == nil {
+			continue
+		}
+
+		ifc, e := net.InterfaceByIndex(msg.Index)
+		if e != nil {
+			continue
+		}
+
+		gateway = gw
+		iface = ifc.Name
+		return
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("netconf: No default gateway"),
+	}
+	return
+}
+
+// Static routes are added by the administrator or the service, the other
+// routes are created by the system for the interfaces
+func nativeGetRoutes(ipv6 bool) (routes []*Route, err error) {
+	routes = []*Route{}
+
+	family := unix.AF_INET
+	bits := 32
+	if ipv6 {
+		family = unix.AF_INET6
+		bits = 128
+	}
+
+	rib, err := route.FetchRIB(family, route.RIBTypeRoute, 0)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netconf: Failed to parse routes"),
+		}
+		return
+	}
+
+	for _, m := range msgs {
+		msg, ok := m.(*route.RouteMessage)
+		if !ok || len(msg.Addrs) <= unix.RTAX_NETMASK {
+			continue
+		}
+
+		dst := addrIp(msg.Addrs[unix.RTAX_DST])
+		if dst == nil {
+			continue
+		}
+		if !ipv6 {
+			dst = dst.To4()
+		}
+
+		ones := 0
+		if mask := addrIp(msg.Addrs[unix.RTAX_NETMASK]); mask != nil {
+			if len(mask) == net.IPv4len {
+				ones, _ = net.IPMask(mask).Size()
+			} else {
+				ones, _ = net.IPMask(mask.To16()).Size()
+			}
+		} else if msg.Flags&unix.RTF_HOST != 0 {
+			ones = bits
+		}
+
+		rt := &Route{
+			Network: &net.IPNet{
+				IP:   dst.Mask(net.CIDRMask(ones, bits)),
+				Mask: net.CIDRMask(ones, bits),
+			},
+			System: msg.Flags&unix.RTF_STATIC == 0 ||
+				msg.Flags&(unix.RTF_WASCLONED|unix.RTF_LLINFO) != 0,
+		}
+
+		if msg.Flags&unix.RTF_GATEWAY != 0 {
+			rt.Gateway = addrIp(msg.Addrs[unix.RTAX_GATEWAY])
+		}
+
+		ifc, e := net.InterfaceByIndex(msg.Index)
+		if e == nil {
+			rt.Iface = ifc.Name
+		}
+
+		routes = append(routes, rt)
+	}
+
+	return
+}
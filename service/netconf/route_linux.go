@@ -0,0 +1,498 @@
+package netconf
+
+import (
+	"net"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+var netlinkSeq uint32
+
+func netlinkAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) & ^(unix.NLMSG_ALIGNTO - 1)
+}
+
+func rtaAlign(n int) int {
+	return (n + unix.RTA_ALIGNTO - 1) & ^(unix.RTA_ALIGNTO - 1)
+}
+
+func netlinkAttr(typ uint16, data []byte) (b []byte) {
+	l := unix.SizeofRtAttr + len(data)
+	b = make([]byte, rtaAlign(l))
+
+	attr := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+	attr.Len = uint16(l)
+	attr.Type = typ
+	copy(b[unix.SizeofRtAttr:], data)
+
+	return
+}
+
+func netlinkUint32(val uint32) (b []byte) {
+	b = make([]byte, 4)
+	*(*uint32)(unsafe.Pointer(&b[0])) = val
+	return
+}
+
+func parseNetlinkAttrs(b []byte) (attrs map[uint16][]byte) {
+	attrs = map[uint16][]byte{}
+
+	for len(b) >= unix.SizeofRtAttr {
+		attr := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+		l := int(attr.Len)
+		if l < unix.SizeofRtAttr || l > len(b) {
+			break
+		}
+
+		attrs[attr.Type] = b[unix.SizeofRtAttr:l]
+
+		l = rtaAlign(l)
+		if l > len(b) {
+			break
+		}
+		b = b[l:]
+	}
+
+	return
+}
+
+// Send a route request and return the route messages of the response, the
+// errno of a failed request is returned unwrapped
+func netlinkRequest(typ, flags uint16, body []byte) (
+	msgs [][]byte, err error) {
+
+	fd, err := unix.Socket(unix.AF_NETLINK,
+		unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return
+	}
+	defer unix.Close(fd)
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+	})
+	if err != nil {
+		return
+	}
+
+	seq := atomic.AddUint32(&netlinkSeq, 1)
+	req := make([]byte, netlinkAlign(unix.SizeofNlMsghdr+len(body)))
+
+	hdr := (*unix.NlMsghdr)(unsafe.Pointer(&req[0]))
+	hdr.Len = uint32(unix.SizeofNlMsghdr + len(body))
+	hdr.Type = typ
+	hdr.Flags = flags | unix.NLM_F_REQUEST | unix.NLM_F_ACK
+	hdr.Seq = seq
+	copy(req[unix.SizeofNlMsghdr:], body)
+
+	err = unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+	})
+	if err != nil {
+		return
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, e := unix.Recvfrom(fd, buf, 0)
+		if e != nil {
+			err = e
+			return
+		}
+
+		b := buf[:n]
+		for len(b) >= unix.SizeofNlMsghdr {
+			hdr := (*unix.NlMsghdr)(unsafe.Pointer(&b[0]))
+			l := int(hdr.Len)
+			if l < unix.SizeofNlMsghdr || l > len(b) {
+				break
+			}
+
+			data := b[unix.SizeofNlMsghdr:l]
+			if hdr.Seq == seq {
+				switch hdr.Type {
+				case unix.NLMSG_DONE:
+					return
+				case unix.NLMSG_ERROR:
+					if len(data) >= unix.SizeofNlMsgerr {
+						nlErr := (*unix.NlMsgerr)(unsafe.Pointer(&data[0]))
+						if nlErr.Error != 0 {
+							err = unix.Errno(-nlErr.Error)
+						}
+					}
+					return
+				case unix.RTM_NEWROUTE:
+					msg := make([]byte, len(data))
+					copy(msg, data)
+					msgs = append(msgs, msg)
+				}
+			}
+
+			l = netlinkAlign(l)
+			if l > len(b) {
+				break
+			}
+			b = b[l:]
+		}
+	}
+}
+
+func routeFamily(network *net.IPNet) (family uint8, ip net.IP) {
+	if ip = network.IP.To4(); ip != nil {
+		family = unix.AF_INET
+	} else {
+		family = unix.AF_INET6
+		ip = network.IP.To16()
+	}
+	return
+}
+
+func routeBody(network *net.IPNet, gateway net.IP, metric int,
+	iface string, del bool) (body []byte, err error) {
+
+	family, dst := routeFamily(network)
+	ones, _ := network.Mask.Size()
+
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return
+	}
+
+	body = make([]byte, unix.SizeofRtMsg)
+	msg := (*unix.RtMsg)(unsafe.Pointer(&body[0]))
+	msg.Family = family
+	msg.Dst_len = uint8(ones)
+	msg.Table = unix.RT_TABLE_MAIN
+
+	if del {
+		msg.Scope = unix.RT_SCOPE_NOWHERE
+	} else {
+		msg.Protocol = unix.RTPROT_BOOT
+		msg.Type = unix.RTN_UNICAST
+		if gateway == nil && family == unix.AF_INET {
+			msg.Scope = unix.RT_SCOPE_LINK
+		} else {
+			msg.Scope = unix.RT_SCOPE_UNIVERSE
+		}
+	}
+
+	body = append(body, netlinkAttr(unix.RTA_DST, dst)...)
+	body = append(body, netlinkAttr(unix.RTA_OIF,
+		netlinkUint32(uint32(ifc.Index)))...)
+
+	if gateway != nil {
+		if family == unix.AF_INET {
+			gateway = gateway.To4()
+		} else {
+			gateway = gateway.To16()
+		}
+		body = append(body, netlinkAttr(unix.RTA_GATEWAY, gateway)...)
+	}
+	if metric > 0 {
+		body = append(body, netlinkAttr(unix.RTA_PRIORITY,
+			netlinkUint32(uint32(metric)))...)
+	}
+
+	return
+}
+
+func nativeAddRoute(network *net.IPNet, gateway net.IP, metric int,
+	iface string, replace bool) (err error) {
+
+	body, err := routeBody(network, gateway, metric, iface, false)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to find route interface"),
+		}
+		return
+	}
+
+	flags := uint16(unix.NLM_F_CREATE)
+	if replace {
+		flags |= unix.NLM_F_REPLACE
+	} else {
+		flags |= unix.NLM_F_EXCL
+	}
+
+	_, err = netlinkRequest(unix.RTM_NEWROUTE, flags, body)
+	if err != nil {
+		if !replace && err == unix.EEXIST {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "netconf: Failed to add route %s",
+				network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeDeleteRoute(network *net.IPNet, iface string) (err error) {
+	body, err := routeBody(network, nil, 0, iface, true)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	_, err = netlinkRequest(unix.RTM_DELROUTE, 0, body)
+	if err != nil {
+		if err == unix.ESRCH || err == unix.ENODEV {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "netconf: Failed to delete route %s",
+				network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeGetRouteIfaces(network *net.IPNet) (ifaces []string, err error) {
+	ifaces = []string{}
+
+	family, dst := routeFamily(network)
+	ones, _ := network.Mask.Size()
+
+	body := make([]byte, unix.SizeofRtMsg)
+	(*unix.RtMsg)(unsafe.Pointer(&body[0])).Family = family
+
+	msgs, err := netlinkRequest(unix.RTM_GETROUTE, unix.NLM_F_DUMP, body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	indexes := []int{}
+	for _, msg := range msgs {
+		if len(msg) < unix.SizeofRtMsg {
+			continue
+		}
+
+		rtMsg := (*unix.RtMsg)(unsafe.Pointer(&msg[0]))
+		attrs := parseNetlinkAttrs(msg[unix.SizeofRtMsg:])
+
+		table := uint32(rtMsg.Table)
+		if tableAttr, ok := attrs[unix.RTA_TABLE]; ok && len(tableAttr) >= 4 {
+			table = *(*uint32)(unsafe.Pointer(&tableAttr[0]))
+		}
+
+		if rtMsg.Family != family || int(rtMsg.Dst_len) != ones ||
+			table != unix.RT_TABLE_MAIN {
+
+			continue
+		}
+
+		routeDst := attrs[unix.RTA_DST]
+		if ones == 0 && routeDst == nil {
+			routeDst = make([]byte, len(dst))
+		}
+		if !net.IP(routeDst).Equal(dst) {
+			continue
+		}
+
+		if oif, ok := attrs[unix.RTA_OIF]; ok && len(oif) >= 4 {
+			indexes = append(indexes,
+				int(*(*uint32)(unsafe.Pointer(&oif[0]))))
+		}
+
+		// Multipath routes list an interface for each next hop
+		multipath := attrs[unix.RTA_MULTIPATH]
+		for len(multipath) >= unix.SizeofRtNexthop {
+			hop := (*unix.RtNexthop)(unsafe.Pointer(&multipath[0]))
+			if int(hop.Len) < unix.SizeofRtNexthop ||
+				int(hop.Len) > len(multipath) {
+
+				break
+			}
+
+			indexes = append(indexes, int(hop.Ifindex))
+
+			l := rtaAlign(int(hop.Len))
+			if l > len(multipath) {
+				break
+			}
+			multipath = multipath[l:]
+		}
+	}
+
+	for _, index := range indexes {
+		ifc, e := net.InterfaceByIndex(index)
+		if e != nil {
+			continue
+		}
+		ifaces = append(ifaces, ifc.Name)
+	}
+
+	return
+}
+
+func nativeGetDefaultGateway(ipv6 bool) (gateway net.IP, iface string,
+	err error) {
+
+	family := uint8(unix.AF_INET)
+	if ipv6 {
+		family = unix.AF_INET6
+	}
+
+	body := make([]byte, unix.SizeofRtMsg)
+	(*unix.RtMsg)(unsafe.Pointer(&body[0])).Family = family
+
+	msgs, err := netlinkRequest(unix.RTM_GETROUTE, unix.NLM_F_DUMP, body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	index := 0
+	priority := uint32(0)
+	for _, msg := range msgs {
+		if len(msg) < unix.SizeofRtMsg {
+			continue
+		}
+
+		rtMsg := (*unix.RtMsg)(unsafe.Pointer(&msg[0]))
+		attrs := parseNetlinkAttrs(msg[unix.SizeofRtMsg:])
+
+		table := uint32(rtMsg.Table)
+		if tableAttr, ok := attrs[unix.RTA_TABLE]; ok && len(tableAttr) >= 4 {
+			table = *(*uint32)(unsafe.Pointer(&tableAttr[0]))
+		}
+
+		if rtMsg.Family != family || rtMsg.Dst_len != 0 ||
+			table != unix.RT_TABLE_MAIN {
+
+			continue
+		}
+
+		// Default routes without a gateway are point to point tunnels
+		gatewayAttr := attrs[unix.RTA_GATEWAY]
+		oif, ok := attrs[unix.RTA_OIF]
+		if gatewayAttr == nil || !ok || len(oif) < 4 {
+			continue
+		}
+
+		prio := uint32(0)
+		if prioAttr, ok := attrs[unix.RTA_PRIORITY]; ok && len(prioAttr) >= 4 {
+			prio = *(*uint32)(unsafe.Pointer(&prioAttr[0]))
+		}
+
+		if gateway == nil || prio < priority {
+			gateway = net.IP(append([]byte{}, gatewayAttr...))
+			index = int(*(*uint32)(unsafe.Pointer(&oif[0])))
+			priority = prio
+		}
+	}
+
+	if gateway == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("netconf: No default gateway"),
+		}
+		return
+	}
+
+	ifc, err := net.InterfaceByIndex(index)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to find gateway interface"),
+		}
+		return
+	}
+	iface = ifc.Name
+
+	return
+}
+
+func nativeGetRoutes(ipv6 bool) (routes []*Route, err error) {
+	routes = []*Route{}
+
+	family := uint8(unix.AF_INET)
+	ipLen := net.IPv4len
+	if ipv6 {
+		family = unix.AF_INET6
+		ipLen = net.IPv6len
+	}
+
+	body := make([]byte, unix.SizeofRtMsg)
+	(*unix.RtMsg)(unsafe.Pointer(&body[0])).Family = family
+
+	msgs, err := netlinkRequest(unix.RTM_GETROUTE, unix.NLM_F_DUMP, body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to get routes"),
+		}
+		return
+	}
+
+	for _, msg := range msgs {
+		if len(msg) < unix.SizeofRtMsg {
+			continue
+		}
+
+		rtMsg := (*unix.RtMsg)(unsafe.Pointer(&msg[0]))
+		attrs := parseNetlinkAttrs(msg[unix.SizeofRtMsg:])
+
+		table := uint32(rtMsg.Table)
+		if tableAttr, ok := attrs[unix.RTA_TABLE]; ok && len(tableAttr) >= 4 {
+			table = *(*uint32)(unsafe.Pointer(&tableAttr[0]))
+		}
+
+		// Local, broadcast and multicast routes are not in the main table
+		// or are not unicast routes
+		if rtMsg.Family != family || table != unix.RT_TABLE_MAIN ||
+			rtMsg.Type != unix.RTN_UNICAST {
+
+			continue
+		}
+
+		dst := make([]byte, ipLen)
+		if dstAttr, ok := attrs[unix.RTA_DST]; ok && len(dstAttr) == ipLen {
+			copy(dst, dstAttr)
+		}
+
+		rt := &Route{
+			Network: &net.IPNet{
+				IP:   net.IP(dst),
+				Mask: net.CIDRMask(int(rtMsg.Dst_len), ipLen*8),
+			},
+		}
+
+		if gatewayAttr, ok := attrs[unix.RTA_GATEWAY]; ok {
+			rt.Gateway = net.IP(append([]byte{}, gatewayAttr...))
+		}
+
+		if oif, ok := attrs[unix.RTA_OIF]; ok && len(oif) >= 4 {
+			ifc, e := net.InterfaceByIndex(
+				int(*(*uint32)(unsafe.Pointer(&oif[0]))))
+			if e == nil {
+				rt.Iface = ifc.Name
+			}
+		}
+
+		if prioAttr, ok := attrs[unix.RTA_PRIORITY]; ok && len(prioAttr) >= 4 {
+			rt.Metric = int(*(*uint32)(unsafe.Pointer(&prioAttr[0])))
+		}
+
+		rt.System = rtMsg.Protocol == unix.RTPROT_KERNEL ||
+			rt.Network.IP.IsLinkLocalUnicast()
+
+		routes = append(routes, rt)
+	}
+
+	return
+}
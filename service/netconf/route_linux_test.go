@@ -0,0 +1,60 @@
+package netconf
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseNetlinkAttrs(t *testing.T) {
+	dst := []byte{10, 1, 0, 0}
+	oif := netlinkUint32(3)
+	// Unaligned length followed by padding
+	name := []byte("tun0\x00")
+
+	b := []byte{}
+	b = append(b, netlinkAttr(unix.RTA_DST, dst)...)
+	b = append(b, netlinkAttr(unix.RTA_OIF, oif)...)
+	b = append(b, netlinkAttr(unix.RTA_TABLE, name)...)
+
+	attrs := parseNetlinkAttrs(b)
+	if len(attrs) != 3 {
+		t.Fatalf("expected 3 attributes got %d", len(attrs))
+	}
+	if !bytes.Equal(attrs[unix.RTA_DST], dst) {
+		t.Fatalf("RTA_DST %v", attrs[unix.RTA_DST])
+	}
+	if !bytes.Equal(attrs[unix.RTA_OIF], oif) {
+		t.Fatalf("RTA_OIF %v", attrs[unix.RTA_OIF])
+	}
+	if !bytes.Equal(attrs[unix.RTA_TABLE], name) {
+		t.Fatalf("RTA_TABLE %v", attrs[unix.RTA_TABLE])
+	}
+}
+
+func TestParseNetlinkAttrsMalformed(t *testing.T) {
+	valid := netlinkAttr(unix.RTA_DST, []byte{10, 1, 0, 0})
+
+	tests := []struct {
+		name  string
+		data  []byte
+		count int
+	}{
+		{"empty", []byte{}, 0},
+		{"short header", []byte{8, 0}, 0},
+		{"length below header", []byte{2, 0, 1, 0}, 0},
+		{"length past end", []byte{16, 0, 1, 0, 10, 1, 0, 0}, 0},
+		{"trailing truncated", append(append([]byte{}, valid...),
+			16, 0, 4, 0, 1, 2), 1},
+		{"missing padding", []byte{5, 0, 1, 0, 10}, 1},
+	}
+
+	for _, test := range tests {
+		attrs := parseNetlinkAttrs(test.data)
+		if len(attrs) != test.count {
+			t.Errorf("%s: expected %d attributes got %d",
+				test.name, test.count, len(attrs))
+		}
+	}
+}
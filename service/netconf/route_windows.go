@@ -0,0 +1,353 @@
+package netconf
+
+import (
+	"net"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	routeProtocolLocal   = 2
+	routeProtocolNetMgmt = 3
+	interfaceAliasSize   = 257
+)
+
+var (
+	iphlpapi                        = windows.NewLazySystemDLL("iphlpapi.dll")
+	procInitializeIpForwardEntry    = iphlpapi.NewProc("InitializeIpForwardEntry")
+	procCreateIpForwardEntry2       = iphlpapi.NewProc("CreateIpForwardEntry2")
+	procDeleteIpForwardEntry2       = iphlpapi.NewProc("DeleteIpForwardEntry2")
+	procGetIpForwardTable2          = iphlpapi.NewProc("GetIpForwardTable2")
+	procFreeMibTable                = iphlpapi.NewProc("FreeMibTable")
+	procConvertInterfaceAliasToLuid = iphlpapi.NewProc(
+		"ConvertInterfaceAliasToLuid")
+	procConvertInterfaceLuidToAlias = iphlpapi.NewProc(
+		"ConvertInterfaceLuidToAlias")
+)
+
+// SOCKADDR_INET, the address is stored after the port and IPv6 flow info
+type sockaddrInet struct {
+	Family uint16
+	Data   [26]byte
+}
+
+func (s *sockaddrInet) setIp(ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		s.Family = windows.AF_INET
+		copy(s.Data[2:6], ip4)
+	} else {
+		s.Family = windows.AF_INET6
+		copy(s.Data[6:22], ip.To16())
+	}
+}
+
+func (s *sockaddrInet) ip() net.IP {
+	switch s.Family {
+	case windows.AF_INET:
+		return net.IP(append([]byte{}, s.Data[2:6]...))
+	case windows.AF_INET6:
+		return net.IP(append([]byte{}, s.Data[6:22]...))
+	}
+	return nil
+}
+
+// MIB_IPFORWARD_ROW2
+type ipForwardRow struct {
+	InterfaceLuid        uint64
+	InterfaceIndex       uint32
+	DestinationPrefix    sockaddrInet
+	PrefixLength         uint8
+	_                    [3]byte
+	NextHop              sockaddrInet
+	SitePrefixLength     uint8
+	ValidLifetime        uint32
+	PreferredLifetime    uint32
+	Metric               uint32
+	Protocol             uint32
+	Loopback             bool
+	AutoconfigureAddress bool
+	Publish              bool
+	Immortal             bool
+	Age                  uint32
+	Origin               uint32
+}
+
+func ifaceLuid(iface string) (luid uint64, err error) {
+	alias, err := windows.UTF16PtrFromString(iface)
+	if err != nil {
+		return
+	}
+
+	ret, _, _ := procConvertInterfaceAliasToLuid.Call(
+		uintptr(unsafe.Pointer(alias)),
+		uintptr(unsafe.Pointer(&luid)),
+	)
+	if ret != 0 {
+		err = windows.Errno(ret)
+		return
+	}
+
+	return
+}
+
+func luidIface(luid uint64) (iface string, err error) {
+	alias := make([]uint16, interfaceAliasSize)
+
+	ret, _, _ := procConvertInterfaceLuidToAlias.Call(
+		uintptr(unsafe.Pointer(&luid)),
+		uintptr(unsafe.Pointer(&alias[0])),
+		uintptr(len(alias)),
+	)
+	if ret != 0 {
+		err = windows.Errno(ret)
+		return
+	}
+
+	iface = windows.UTF16ToString(alias)
+	return
+}
+
+func newForwardRow(network *net.IPNet, gateway net.IP, metric int,
+	luid uint64) (row *ipForwardRow) {
+
+	row = &ipForwardRow{}
+	procInitializeIpForwardEntry.Call(uintptr(unsafe.Pointer(row)))
+
+	ones, _ := network.Mask.Size()
+
+	row.InterfaceLuid = luid
+	row.DestinationPrefix.setIp(network.IP)
+	row.PrefixLength = uint8(ones)
+	row.Metric = uint32(metric)
+	row.Protocol = routeProtocolNetMgmt
+
+	// On link routes use the unspecified address of the family
+	if gateway != nil {
+		row.NextHop.setIp(gateway)
+	} else if network.IP.To4() != nil {
+		row.NextHop.setIp(net.IPv4zero)
+	} else {
+		row.NextHop.setIp(net.IPv6zero)
+	}
+
+	return
+}
+
+func nativeAddRoute(network *net.IPNet, gateway net.IP, metric int,
+	iface string) (err error) {
+
+	luid, err := ifaceLuid(iface)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to find route interface"),
+		}
+		return
+	}
+
+	row := newForwardRow(network, gateway, metric, luid)
+
+	ret, _, _ := procCreateIpForwardEntry2.Call(uintptr(unsafe.Pointer(row)))
+	if ret != 0 && windows.Errno(ret) != windows.ERROR_OBJECT_ALREADY_EXISTS {
+		err = &errortypes.WriteError{
+			errors.Wrapf(windows.Errno(ret),
+				"netconf: Failed to add route %s", network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeDeleteRoute(network *net.IPNet, iface string) (err error) {
+	luid, err := ifaceLuid(iface)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	row := newForwardRow(network, nil, 0, luid)
+
+	ret, _, _ := procDeleteIpForwardEntry2.Call(uintptr(unsafe.Pointer(row)))
+	if ret != 0 && windows.Errno(ret) != windows.ERROR_NOT_FOUND {
+		err = &errortypes.WriteError{
+			errors.Wrapf(windows.Errno(ret),
+				"netconf: Failed to delete route %s", network.String()),
+		}
+		return
+	}
+
+	return
+}
+
+func nativeGetRouteIfaces(network *net.IPNet) (ifaces []string, err error) {
+	ifaces = []string{}
+
+	family := windows.AF_INET
+	if network.IP.To4() == nil {
+		family = windows.AF_INET6
+	}
+	ones, _ := network.Mask.Size()
+
+	var table unsafe.Pointer
+	ret, _, _ := procGetIpForwardTable2.Call(
+		uintptr(family),
+		uintptr(unsafe.Pointer(&table)),
+	)
+	if ret != 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(windows.Errno(ret), "netconf: Failed to get routes"),
+		}
+		return
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	// MIB_IPFORWARD_TABLE2, the rows are aligned after the entry count
+	count := *(*uint32)(table)
+	rowsPtr := unsafe.Pointer(uintptr(table) + unsafe.Sizeof(uint64(0)))
+	rowSize := unsafe.Sizeof(ipForwardRow{})
+
+	for i := uintptr(0); i < uintptr(count); i++ {
+		row := (*ipForwardRow)(unsafe.Pointer(uintptr(rowsPtr) + i*rowSize))
+
+		if int(row.PrefixLength) != ones ||
+			!row.DestinationPrefix.ip().Equal(network.IP) {
+
+			continue
+		}
+
+		iface, e := luidIface(row.InterfaceLuid)
+		if e != nil {
+			continue
+		}
+		ifaces = append(ifaces, iface)
+	}
+
+	return
+}
+
+func nativeGetDefaultGateway(ipv6 bool) (gateway net.IP, iface string,
+	err error) {
+
+	family := windows.AF_INET
+	if ipv6 {
+		family = windows.AF_INET6
+	}
+
+	var table unsafe.Pointer
+	ret, _, _ := procGetIpForwardTable2.Call(
+		uintptr(family),
+		uintptr(unsafe.Pointer(&table)),
+	)
+	if ret != 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(windows.Errno(ret), "netconf: Failed to get routes"),
+		}
+		return
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	count := *(*uint32)(table)
+	rowsPtr := unsafe.Pointer(uintptr(table) + unsafe.Sizeof(uint64(0)))
+	rowSize := unsafe.Sizeof(ipForwardRow{})
+
+	var luid uint64
+	metric := uint32(0)
+	for i := uintptr(0); i < uintptr(count); i++ {
+		row := (*ipForwardRow)(unsafe.Pointer(uintptr(rowsPtr) + i*rowSize))
+
+		// Default routes without a next hop are point to point tunnels
+		nextHop := row.NextHop.ip()
+		if row.PrefixLength != 0 || nextHop == nil ||
+			nextHop.IsUnspecified() {
+
+			continue
+		}
+
+		if gateway == nil || row.Metric < metric {
+			gateway = nextHop
+			luid = row.InterfaceLuid
+			metric = row.Metric
+		}
+	}
+
+	if gateway == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("netconf: No default gateway"),
+		}
+		return
+	}
+
+	iface, err = luidIface(luid)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netconf: Failed to find gateway interface"),
+		}
+		return
+	}
+
+	return
+}
+
+// Local routes are created by the system for the interface addresses
+func nativeGetRoutes(ipv6 bool) (routes []*Route, err error) {
+	routes = []*Route{}
+
+	family := windows.AF_INET
+	bits := 32
+	if ipv6 {
+		family = windows.AF_INET6
+		bits = 128
+	}
+
+	var table unsafe.Pointer
+	ret, _, _ := procGetIpForwardTable2.Call(
+		uintptr(family),
+		uintptr(unsafe.Pointer(&table)),
+	)
+	if ret != 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(windows.Errno(ret), "netconf: Failed to get routes"),
+		}
+		return
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	count := *(*uint32)(table)
+	rowsPtr := unsafe.Pointer(uintptr(table) + unsafe.Sizeof(uint64(0)))
+	rowSize := unsafe.Sizeof(ipForwardRow{})
+
+	for i := uintptr(0); i < uintptr(count); i++ {
+		row := (*ipForwardRow)(unsafe.Pointer(uintptr(rowsPtr) + i*rowSize))
+
+		dst := row.DestinationPrefix.ip()
+		if dst == nil {
+			continue
+		}
+
+		rt := &Route{
+			Network: &net.IPNet{
+				IP:   dst,
+				Mask: net.CIDRMask(int(row.PrefixLength), bits),
+			},
+			Metric: int(row.Metric),
+			System: row.Protocol == routeProtocolLocal,
+		}
+
+		nextHop := row.NextHop.ip()
+		if nextHop != nil && !nextHop.IsUnspecified() {
+			rt.Gateway = nextHop
+		}
+
+		iface, e := luidIface(row.InterfaceLuid)
+		if e == nil {
+			rt.Iface = iface
+		}
+
+		routes = append(routes, rt)
+	}
+
+	return
+}
@@ -0,0 +1,51 @@
+// Notification of system network changes, changes to interfaces, addresses
+// and routes are reported without waiting for a poll interval.
+package netmon
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const retryWait = 30 * time.Second
+
+// Listen for network changes and send on the channel, notifications are
+// dropped while a previous notification is pending
+func Start() (changes chan bool) {
+	changes = make(chan bool, 1)
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("netmon: Panic")
+				panic(panc)
+			}
+		}()
+
+		notify := func() {
+			select {
+			case changes <- true:
+			default:
+			}
+		}
+
+		for {
+			err := listen(notify)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("netmon: Network monitor failed, restarting")
+			}
+
+			time.Sleep(retryWait)
+		}
+	}()
+
+	return
+}
@@ -0,0 +1,47 @@
+package netmon
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+func listen(notify func()) (err error) {
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netmon: Failed to open route socket"),
+		}
+		return
+	}
+	defer unix.Close(fd)
+
+	buf := make([]byte, 8192)
+	for {
+		n, e := unix.Read(fd, buf)
+		if e != nil {
+			if e == unix.EINTR {
+				continue
+			}
+
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "netmon: Failed to read route socket"),
+			}
+			return
+		}
+
+		// Message type follows the length and version of the header,
+		// route lookups and misses are not changes
+		if n < 4 {
+			continue
+		}
+
+		switch buf[3] {
+		case unix.RTM_ADD, unix.RTM_DELETE, unix.RTM_CHANGE,
+			unix.RTM_NEWADDR, unix.RTM_DELADDR, unix.RTM_IFINFO:
+
+			notify()
+			break
+		}
+	}
+}
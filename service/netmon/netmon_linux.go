@@ -0,0 +1,56 @@
+package netmon
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+func listen(notify func()) (err error) {
+	fd, err := unix.Socket(unix.AF_NETLINK,
+		unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netmon: Failed to open netlink socket"),
+		}
+		return
+	}
+	defer unix.Close(fd)
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK |
+			unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR |
+			unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV6_ROUTE,
+	})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netmon: Failed to bind netlink socket"),
+		}
+		return
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, e := unix.Recvfrom(fd, buf, 0)
+		if e != nil {
+			// Overrun receive buffer indicates dropped changes
+			if e == unix.ENOBUFS {
+				notify()
+				continue
+			}
+			if e == unix.EINTR {
+				continue
+			}
+
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "netmon: Failed to read netlink socket"),
+			}
+			return
+		}
+
+		if n > 0 {
+			notify()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package netmon
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	iphlpapi              = windows.NewLazySystemDLL("iphlpapi.dll")
+	procNotifyAddrChange  = iphlpapi.NewProc("NotifyAddrChange")
+	procNotifyRouteChange = iphlpapi.NewProc("NotifyRouteChange")
+)
+
+// Without a handle and overlapped structure the call blocks until the
+// next change
+func wait(proc *windows.LazyProc, notify func()) (err error) {
+	for {
+		ret, _, _ := proc.Call(0, 0)
+		if ret != 0 {
+			err = &errortypes.ReadError{
+				errors.Wrapf(windows.Errno(ret),
+					"netmon: %s failed", proc.Name),
+			}
+			return
+		}
+
+		notify()
+	}
+}
+
+func listen(notify func()) (err error) {
+	err = iphlpapi.Load()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netmon: Failed to load iphlpapi"),
+		}
+		return
+	}
+
+	errs := make(chan error, 2)
+
+	go func() {
+		errs <- wait(procNotifyAddrChange, notify)
+	}()
+	go func() {
+		errs <- wait(procNotifyRouteChange, notify)
+	}()
+
+	// Continue with the remaining notification if one fails
+	err = <-errs
+	<-errs
+
+	return
+}
@@ -0,0 +1,585 @@
+// Restore points of the system routes and DNS servers taken before
+// operations that change the network configuration. Restoring removes
+// routes added since the snapshot, adds back removed routes and restores
+// the DNS servers. A snapshot is only restored on the network it was
+// taken on.
+package netsnap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ReasonResetDns = "reset_dns"
+	ReasonResetAll = "reset_all"
+	ReasonConnect  = "connect"
+
+	maxSnapshots   = 5
+	maxSnapshotAge = 24 * time.Hour
+)
+
+var (
+	snapshots []*Snapshot
+	loaded    bool
+	lock      = sync.Mutex{}
+)
+
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+	Iface       string `json:"iface"`
+	Metric      int    `json:"metric"`
+	Ipv6        bool   `json:"ipv6"`
+}
+
+func (r *Route) key() string {
+	return fmt.Sprintf("%s %s %s %d %t",
+		r.Destination, r.Gateway, r.Iface, r.Metric, r.Ipv6)
+}
+
+func (r *Route) String() string {
+	str := r.Destination
+	if r.Gateway != "" {
+		str += " via " + r.Gateway
+	}
+	if r.Iface != "" {
+		str += " dev " + r.Iface
+	}
+	return str
+}
+
+// DNS servers of an interface, the interface is empty for the system
+// DNS configuration
+type Dns struct {
+	Iface   string   `json:"iface"`
+	Servers []string `json:"servers"`
+}
+
+func (d *Dns) String() string {
+	name := d.Iface
+	if name == "" {
+		name = "system"
+	}
+	return fmt.Sprintf("%s: %s", name, strings.Join(d.Servers, ", "))
+}
+
+type Snapshot struct {
+	Id           string    `json:"id"`
+	Reason       string    `json:"reason"`
+	Timestamp    time.Time `json:"timestamp"`
+	Gateway      string    `json:"gateway"`
+	GatewayIface string    `json:"gateway_iface"`
+	Ifaces       []string  `json:"ifaces"`
+	Routes       []*Route  `json:"routes"`
+	Dns          []*Dns    `json:"dns"`
+	Data         string    `json:"data,omitempty"`
+}
+
+type Result struct {
+	SnapshotId string   `json:"snapshot_id"`
+	Actions    []string `json:"actions"`
+	Errors     []string `json:"errors"`
+}
+
+type change struct {
+	action string
+	apply  func() error
+}
+
+func GetPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "netsnap.json")
+}
+
+func load() {
+	if loaded {
+		return
+	}
+	loaded = true
+	snapshots = []*Snapshot{}
+
+	data, err := ioutil.ReadFile(GetPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("netsnap: Failed to read snapshots")
+		}
+		return
+	}
+
+	err = json.Unmarshal(data, &snapshots)
+	if err != nil {
+		snapshots = []*Snapshot{}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netsnap: Failed to parse snapshots")
+	}
+}
+
+func save() (err error) {
+	pth := GetPath()
+
+	err = platform.MkdirReadSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(snapshots)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "netsnap: Failed to marshal snapshots"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(pth, data, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "netsnap: Failed to write snapshots"),
+		}
+		return
+	}
+
+	return
+}
+
+// Physical interfaces identify the network of a snapshot, tunnel
+// interfaces have no hardware address and are removed with the profiles
+func getIfaces() (ifaces []string, err error) {
+	ifaces = []string{}
+
+	ifcs, err := net.Interfaces()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netsnap: Failed to get interfaces"),
+		}
+		return
+	}
+
+	for _, ifc := range ifcs {
+		if ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0 ||
+			len(ifc.HardwareAddr) == 0 {
+
+			continue
+		}
+		ifaces = append(ifaces, ifc.Name)
+	}
+	sort.Strings(ifaces)
+
+	return
+}
+
+// Routes created by the system for the interface addresses follow the
+// interfaces and are not captured
+func getRoutes() (routes []*Route, err error) {
+	routes = []*Route{}
+
+	for _, ipv6 := range []bool{false, true} {
+		rts, e := netconf.Get().GetRoutes(ipv6)
+		if e != nil {
+			if ipv6 {
+				continue
+			}
+			err = e
+			return
+		}
+
+		for _, rt := range rts {
+			if rt.System {
+				continue
+			}
+
+			gateway := ""
+			if rt.Gateway != nil {
+				gateway = rt.Gateway.String()
+			}
+
+			routes = append(routes, &Route{
+				Destination: rt.Network.String(),
+				Gateway:     gateway,
+				Iface:       rt.Iface,
+				Metric:      rt.Metric,
+				Ipv6:        ipv6,
+			})
+		}
+	}
+
+	return
+}
+
+func addRoute(rt *Route) (err error) {
+	_, network, err := net.ParseCIDR(rt.Destination)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "netsnap: Invalid route '%s'", rt.Destination),
+		}
+		return
+	}
+
+	gateway := net.ParseIP(rt.Gateway)
+	if gateway == nil {
+		err = netconf.Get().AddRoute(network, rt.Iface)
+	} else {
+		err = netconf.Get().AddGatewayRoute(network, gateway, rt.Metric,
+			rt.Iface)
+	}
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func deleteRoute(rt *Route) (err error) {
+	_, network, err := net.ParseCIDR(rt.Destination)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "netsnap: Invalid route '%s'", rt.Destination),
+		}
+		return
+	}
+
+	err = netconf.Get().DeleteRoute(network, rt.Iface)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func capture() (snap *Snapshot, err error) {
+	snap = &Snapshot{}
+
+	gateway, gatewayIface, e := netconf.Get().GetDefaultGateway(false)
+	if e == nil {
+		snap.Gateway = gateway.String()
+		snap.GatewayIface = gatewayIface
+	}
+
+	snap.Ifaces, err = getIfaces()
+	if err != nil {
+		return
+	}
+
+	snap.Routes, err = getRoutes()
+	if err != nil {
+		return
+	}
+
+	snap.Dns, snap.Data, err = getDns()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Snapshot the current network state, only the most recent snapshots
+// are kept
+func Capture(reason string) (snap *Snapshot, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	load()
+
+	snap, err = capture()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"reason": reason,
+			"error":  err,
+		}).Error("netsnap: Failed to capture network snapshot")
+		return
+	}
+
+	snap.Id = utils.Uuid()
+	snap.Reason = reason
+	snap.Timestamp = time.Now()
+
+	snapshots = append(snapshots, snap)
+	if len(snapshots) > maxSnapshots {
+		snapshots = snapshots[len(snapshots)-maxSnapshots:]
+	}
+
+	err = save()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("netsnap: Failed to save network snapshot")
+		err = nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"snapshot_id": snap.Id,
+		"reason":      reason,
+		"routes":      len(snap.Routes),
+	}).Info("netsnap: Captured network snapshot")
+
+	return
+}
+
+// Snapshots ordered from oldest to newest
+func GetAll() (snaps []*Snapshot) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	load()
+
+	snaps = make([]*Snapshot, len(snapshots))
+	copy(snaps, snapshots)
+
+	return
+}
+
+// Snapshot with the id or the newest snapshot when the id is empty
+func get(id string) (snap *Snapshot, err error) {
+	load()
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if id == "" || snapshots[i].Id == id {
+			snap = snapshots[i]
+			return
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("netsnap: Network snapshot not found"),
+	}
+	return
+}
+
+// Snapshots are only restored on the network they were taken on, the
+// network is not checked while profiles are connected
+func checkSnapshot(snap, current *Snapshot, network bool) (err error) {
+	if time.Since(snap.Timestamp) > maxSnapshotAge {
+		err = &errortypes.PreconditionError{
+			errors.Newf("netsnap: Network snapshot older than %s",
+				maxSnapshotAge),
+		}
+		return
+	}
+
+	if snap.Ifaces == nil {
+		err = &errortypes.PreconditionError{
+			errors.New("netsnap: Network snapshot missing interfaces"),
+		}
+		return
+	}
+
+	// Snapshots taken with the route commands store other route formats
+	for _, rt := range snap.Routes {
+		if _, _, e := net.ParseCIDR(rt.Destination); e != nil {
+			err = &errortypes.PreconditionError{
+				errors.Newf("netsnap: Network snapshot route '%s' invalid",
+					rt.Destination),
+			}
+			return
+		}
+	}
+
+	if !network {
+		return
+	}
+
+	if snap.Gateway != current.Gateway ||
+		snap.GatewayIface != current.GatewayIface {
+
+		err = &errortypes.PreconditionError{
+			errors.Newf("netsnap: Default gateway changed from '%s %s' "+
+				"to '%s %s'", snap.Gateway, snap.GatewayIface,
+				current.Gateway, current.GatewayIface),
+		}
+		return
+	}
+
+	if strings.Join(snap.Ifaces, ",") != strings.Join(current.Ifaces, ",") {
+		err = &errortypes.PreconditionError{
+			errors.Newf("netsnap: Network interfaces changed from '%s' "+
+				"to '%s'", strings.Join(snap.Ifaces, ", "),
+				strings.Join(current.Ifaces, ", ")),
+		}
+		return
+	}
+
+	return
+}
+
+func plan(snap *Snapshot, network bool) (changes []*change, err error) {
+	changes = []*change{}
+
+	current, err := capture()
+	if err != nil {
+		return
+	}
+
+	err = checkSnapshot(snap, current, network)
+	if err != nil {
+		return
+	}
+
+	// Routes of removed interfaces are not restored and routes without an
+	// interface are not changed
+	ifaces := map[string]bool{}
+	ifcs, err := net.Interfaces()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netsnap: Failed to get interfaces"),
+		}
+		return
+	}
+	for _, ifc := range ifcs {
+		ifaces[ifc.Name] = true
+	}
+
+	snapRoutes := map[string]bool{}
+	for _, rt := range snap.Routes {
+		snapRoutes[rt.key()] = true
+	}
+	curRoutes := map[string]bool{}
+	for _, rt := range current.Routes {
+		curRoutes[rt.key()] = true
+	}
+
+	for _, rt := range current.Routes {
+		if snapRoutes[rt.key()] || !ifaces[rt.Iface] {
+			continue
+		}
+
+		route := rt
+		changes = append(changes, &change{
+			action: "Remove route " + route.String(),
+			apply: func() error {
+				return deleteRoute(route)
+			},
+		})
+	}
+
+	for _, rt := range snap.Routes {
+		if curRoutes[rt.key()] || !ifaces[rt.Iface] {
+			continue
+		}
+
+		route := rt
+		changes = append(changes, &change{
+			action: "Add route " + route.String(),
+			apply: func() error {
+				return addRoute(route)
+			},
+		})
+	}
+
+	curDns := map[string]*Dns{}
+	for _, dns := range current.Dns {
+		curDns[dns.Iface] = dns
+	}
+
+	for _, d := range snap.Dns {
+		cur := curDns[d.Iface]
+		if cur == nil || (strings.Join(cur.Servers, ",") ==
+			strings.Join(d.Servers, ",") && current.Data == snap.Data) {
+
+			continue
+		}
+
+		dns := d
+		changes = append(changes, &change{
+			action: "Restore DNS servers " + dns.String(),
+			apply: func() error {
+				return setDns(snap, dns)
+			},
+		})
+	}
+
+	return
+}
+
+// Changes that restoring the snapshot would make
+func Describe(id string) (snapId string, actions []string, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	snap, err := get(id)
+	if err != nil {
+		return
+	}
+	snapId = snap.Id
+
+	changes, err := plan(snap, false)
+	if err != nil {
+		return
+	}
+
+	actions = []string{}
+	for _, chng := range changes {
+		actions = append(actions, chng.action)
+	}
+
+	return
+}
+
+// Revert the network state to the snapshot, profiles must be stopped
+// before restoring. Snapshots older than the max age or taken on another
+// network are not restored.
+func Restore(id string) (result *Result, err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	snap, err := get(id)
+	if err != nil {
+		return
+	}
+
+	changes, err := plan(snap, true)
+	if err != nil {
+		return
+	}
+
+	result = &Result{
+		SnapshotId: snap.Id,
+		Actions:    []string{},
+		Errors:     []string{},
+	}
+
+	for _, chng := range changes {
+		e := chng.apply()
+		if e != nil {
+			result.Errors = append(result.Errors,
+				fmt.Sprintf("%s: %s", chng.action, e.Error()))
+
+			logrus.WithFields(logrus.Fields{
+				"snapshot_id": snap.Id,
+				"action":      chng.action,
+				"error":       e,
+			}).Error("netsnap: Failed to restore network state")
+			continue
+		}
+
+		result.Actions = append(result.Actions, chng.action)
+	}
+
+	netconf.Get().FlushDnsCache()
+
+	logrus.WithFields(logrus.Fields{
+		"snapshot_id": snap.Id,
+		"actions":     len(result.Actions),
+		"errors":      len(result.Errors),
+	}).Warn("netsnap: Restored network snapshot")
+
+	return
+}
@@ -0,0 +1,60 @@
+package netsnap
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+// The service DNS configuration is copied to the restore key used when
+// disconnecting, the global state only describes the servers
+func getDns() (dns []*Dns, data string, err error) {
+	dns = []*Dns{}
+
+	err = utils.BackupScutilDns()
+	if err != nil {
+		return
+	}
+
+	global, err := utils.GetScutilKey("State", "/Network/Global/DNS")
+	if err != nil {
+		return
+	}
+
+	servers := []string{}
+	inServers := false
+	for _, line := range strings.Split(global, "\n") {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "ServerAddresses") {
+			inServers = true
+			continue
+		}
+		if !inServers {
+			continue
+		}
+		if strings.HasPrefix(line, "}") {
+			break
+		}
+
+		lineSpl := strings.SplitN(line, ":", 2)
+		if len(lineSpl) == 2 {
+			servers = append(servers, strings.TrimSpace(lineSpl[1]))
+		}
+	}
+
+	dns = append(dns, &Dns{
+		Servers: servers,
+	})
+
+	return
+}
+
+func setDns(snap *Snapshot, dns *Dns) (err error) {
+	err = utils.RestoreScutilDns(true)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,126 @@
+package netsnap
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const resolvPath = "/etc/resolv.conf"
+
+func getDns() (dns []*Dns, data string, err error) {
+	dns = []*Dns{}
+
+	dataByt, err := ioutil.ReadFile(resolvPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netsnap: Failed to read resolv.conf"),
+		}
+		return
+	}
+	data = string(dataByt)
+
+	servers := []string{}
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	dns = append(dns, &Dns{
+		Servers: servers,
+	})
+
+	return
+}
+
+// A resolv.conf generated by a network manager is written again by the
+// manager, the header comment names the manager
+func managedResolv(data string) bool {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.ToLower(line)
+		if strings.Contains(line, "generated by") ||
+			strings.Contains(line, "managed by") {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// A linked resolv.conf is managed by systemd-resolved which removes the
+// per link DNS configuration with the tunnel interface. Only the name
+// servers of an unmanaged resolv.conf are replaced, the other options are
+// kept.
+func setDns(snap *Snapshot, dns *Dns) (err error) {
+	info, err := os.Lstat(resolvPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netsnap: Failed to stat resolv.conf"),
+		}
+		return
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+
+	dataByt, err := ioutil.ReadFile(resolvPath)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "netsnap: Failed to read resolv.conf"),
+		}
+		return
+	}
+	data := string(dataByt)
+
+	if managedResolv(data) {
+		return
+	}
+
+	lines := []string{}
+	inserted := false
+	for _, line := range strings.Split(strings.TrimRight(data, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "nameserver" {
+			if !inserted {
+				for _, server := range dns.Servers {
+					lines = append(lines, "nameserver "+server)
+				}
+				inserted = true
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if !inserted {
+		for _, server := range dns.Servers {
+			lines = append(lines, "nameserver "+server)
+		}
+	}
+
+	err = ioutil.WriteFile(resolvPath,
+		[]byte(strings.Join(lines, "\n")+"\n"), info.Mode().Perm())
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "netsnap: Failed to write resolv.conf"),
+		}
+		return
+	}
+
+	return
+}
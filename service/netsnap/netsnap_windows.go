@@ -0,0 +1,85 @@
+package netsnap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func quote(val string) string {
+	return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+}
+
+func getDns() (dns []*Dns, data string, err error) {
+	dns = []*Dns{}
+
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"Get-DnsClientServerAddress -AddressFamily IPv4 | "+
+			"ForEach-Object { $_.InterfaceAlias + '|' + "+
+			"($_.ServerAddresses -join ',') }",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		lineSpl := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(lineSpl) != 2 || lineSpl[0] == "" {
+			continue
+		}
+
+		servers := []string{}
+		for _, server := range strings.Split(lineSpl[1], ",") {
+			if server != "" {
+				servers = append(servers, server)
+			}
+		}
+
+		dns = append(dns, &Dns{
+			Iface:   lineSpl[0],
+			Servers: servers,
+		})
+	}
+
+	return
+}
+
+func setDns(snap *Snapshot, dns *Dns) (err error) {
+	cmd := ""
+	if len(dns.Servers) == 0 {
+		cmd = fmt.Sprintf(
+			"Set-DnsClientServerAddress -InterfaceAlias %s "+
+				"-ResetServerAddresses",
+			quote(dns.Iface),
+		)
+	} else {
+		servers := []string{}
+		for _, server := range dns.Servers {
+			servers = append(servers, quote(server))
+		}
+
+		cmd = fmt.Sprintf(
+			"Set-DnsClientServerAddress -InterfaceAlias %s "+
+				"-ServerAddresses (%s)",
+			quote(dns.Iface),
+			strings.Join(servers, ","),
+		)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		cmd,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
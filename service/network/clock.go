@@ -0,0 +1,197 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ntpEpochOffset = 2208988800
+	ntpTimeout     = 3 * time.Second
+	httpTimeout    = 5 * time.Second
+)
+
+var (
+	NtpServers = []string{
+		"time.google.com:123",
+		"time.cloudflare.com:123",
+		"pool.ntp.org:123",
+	}
+	// Plain HTTP is used, a TLS time source cannot be trusted when the
+	// clock is wrong
+	HttpTimeServers = []string{
+		"http://www.google.com",
+		"http://www.msftconnecttest.com/connecttest.txt",
+	}
+	clockResult *ClockResult
+	clockLock   = sync.Mutex{}
+)
+
+type ClockResult struct {
+	Offset    time.Duration `json:"offset"`
+	Source    string        `json:"source"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+func ntpToTime(data []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(data[0:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(data[4:8]))
+
+	return time.Unix(secs, (frac*1e9)>>32)
+}
+
+func ntpOffset(server string) (offset time.Duration, err error) {
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to connect to ntp server"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	// Version 4 client request
+	req := make([]byte, 48)
+	req[0] = 0x23
+
+	sent := time.Now()
+
+	_, err = conn.Write(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to send ntp request"),
+		}
+		return
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to read ntp response"),
+		}
+		return
+	}
+	received := time.Now()
+
+	if n < 48 || resp[0]&0x07 != 4 || resp[1] == 0 {
+		err = &errortypes.ParseError{
+			errors.New("network: Invalid ntp response"),
+		}
+		return
+	}
+
+	recvTime := ntpToTime(resp[32:40])
+	transmitTime := ntpToTime(resp[40:48])
+
+	offset = (recvTime.Sub(sent) + transmitTime.Sub(received)) / 2
+
+	return
+}
+
+func httpOffset(server string) (offset time.Duration, err error) {
+	client := &http.Client{
+		Timeout: httpTimeout,
+	}
+
+	sent := time.Now()
+
+	res, err := client.Head(server)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to request http time"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	received := time.Now()
+
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "network: Failed to parse http date"),
+		}
+		return
+	}
+
+	// Date header has one second precision, use the middle of the request
+	offset = date.Add(500 * time.Millisecond).Sub(
+		sent.Add(received.Sub(sent) / 2))
+
+	return
+}
+
+func CheckClock() (result *ClockResult, err error) {
+	result = &ClockResult{
+		Timestamp: time.Now(),
+	}
+
+	for _, server := range NtpServers {
+		offset, e := ntpOffset(server)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"server": server,
+				"error":  e,
+			}).Warn("network: Ntp time request failed")
+			continue
+		}
+
+		result.Offset = offset
+		result.Source = server
+		break
+	}
+
+	if result.Source == "" {
+		for _, server := range HttpTimeServers {
+			offset, e := httpOffset(server)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"server": server,
+					"error":  e,
+				}).Warn("network: Http time request failed")
+				continue
+			}
+
+			result.Offset = offset
+			result.Source = server
+			break
+		}
+	}
+
+	if result.Source == "" {
+		result = nil
+		err = &errortypes.RequestError{
+			errors.New("network: No time source available"),
+		}
+		return
+	}
+
+	clockLock.Lock()
+	clockResult = result
+	clockLock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"offset": result.Offset.String(),
+		"source": result.Source,
+	}).Info("network: Checked system clock")
+
+	return
+}
+
+func GetClock() (result *ClockResult) {
+	clockLock.Lock()
+	result = clockResult
+	clockLock.Unlock()
+
+	return
+}
@@ -0,0 +1,69 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const dnsBenchQuery = "example.com"
+
+// Response time of a dns server, any response to the query including an
+// error response shows the server is reachable
+func MeasureDns(server string, timeout time.Duration) (
+	latency time.Duration, err error) {
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "53"),
+		timeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to open dns socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	idByt := make([]byte, 2)
+	_, err = rand.Read(idByt)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "network: Failed to generate dns query id"),
+		}
+		return
+	}
+	id := binary.BigEndian.Uint16(idByt)
+
+	start := time.Now()
+
+	_, err = conn.Write(dnsQuery(id, dnsBenchQuery))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to send dns query"),
+		}
+		return
+	}
+
+	_ = conn.SetReadDeadline(start.Add(timeout))
+
+	buf := make([]byte, 512)
+	for {
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "network: No response from dns server"),
+			}
+			return
+		}
+
+		if n >= 12 && binary.BigEndian.Uint16(buf[0:2]) == id &&
+			buf[2]&0x80 != 0 {
+
+			latency = time.Since(start)
+			return
+		}
+	}
+}
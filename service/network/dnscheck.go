@@ -0,0 +1,231 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const (
+	dnsCheckTimeout = 3 * time.Second
+
+	// Answers with the address of the resolver that queried the
+	// authoritative server
+	DefaultDnsCheckDomain = "whoami.akamai.net"
+)
+
+type DnsCheck struct {
+	Passed    bool      `json:"passed"`
+	Domain    string    `json:"domain"`
+	Resolver  string    `json:"resolver"`
+	Expected  []string  `json:"expected"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func GetDnsCheckDomain() string {
+	if config.Config.DnsCheckDomain != "" {
+		return config.Config.DnsCheckDomain
+	}
+	return DefaultDnsCheckDomain
+}
+
+func skipDnsName(buf []byte, off int) (next int, ok bool) {
+	for off < len(buf) {
+		size := int(buf[off])
+		if size == 0 {
+			return off + 1, true
+		}
+		if size&0xc0 == 0xc0 {
+			return off + 2, off+2 <= len(buf)
+		}
+		off += 1 + size
+	}
+
+	return
+}
+
+// IPv4 addresses in the answer section of a response to an A query
+func parseDnsAnswers(buf []byte) (addrs []string) {
+	addrs = []string{}
+
+	if len(buf) < 12 {
+		return
+	}
+
+	questions := int(binary.BigEndian.Uint16(buf[4:6]))
+	answers := int(binary.BigEndian.Uint16(buf[6:8]))
+	off := 12
+	ok := false
+
+	for i := 0; i < questions; i++ {
+		off, ok = skipDnsName(buf, off)
+		if !ok {
+			return
+		}
+		off += 4
+	}
+
+	for i := 0; i < answers; i++ {
+		off, ok = skipDnsName(buf, off)
+		if !ok || off+10 > len(buf) {
+			return
+		}
+
+		typ := binary.BigEndian.Uint16(buf[off : off+2])
+		size := int(binary.BigEndian.Uint16(buf[off+8 : off+10]))
+		off += 10
+		if off+size > len(buf) {
+			return
+		}
+
+		if typ == 1 && size == 4 {
+			addrs = append(addrs, net.IP(buf[off:off+4]).String())
+		}
+		off += size
+	}
+
+	return
+}
+
+// Query a dns server directly bypassing the system resolver
+func queryDns(server, name string, timeout time.Duration) (
+	addrs []string, err error) {
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "53"),
+		timeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to open dns socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	idByt := make([]byte, 2)
+	_, err = rand.Read(idByt)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "network: Failed to generate dns query id"),
+		}
+		return
+	}
+	id := binary.BigEndian.Uint16(idByt)
+
+	_, err = conn.Write(dnsQuery(id, name))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to send dns query"),
+		}
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 512)
+	for {
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "network: No response from dns server"),
+			}
+			return
+		}
+
+		if n < 12 || binary.BigEndian.Uint16(buf[0:2]) != id ||
+			buf[2]&0x80 == 0 {
+
+			continue
+		}
+
+		if buf[3]&0x0f != 0 {
+			err = &errortypes.RequestError{
+				errors.Newf("network: Dns server returned error code %d",
+					buf[3]&0x0f),
+			}
+			return
+		}
+
+		addrs = parseDnsAnswers(buf[:n])
+		return
+	}
+}
+
+// Large resolvers send queries from a pool of addresses, the resolver is
+// the same when the addresses share a network
+func sameResolver(a, b string) bool {
+	ipA := net.ParseIP(a).To4()
+	ipB := net.ParseIP(b).To4()
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+
+	mask := net.CIDRMask(24, 32)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// Resolve the check domain with the system resolver and with each of the
+// servers, queries that resolve through the servers reach the
+// authoritative server from the same resolver
+func CheckDns(servers []string) (result *DnsCheck, err error) {
+	domain := GetDnsCheckDomain()
+	result = &DnsCheck{
+		Domain:    domain,
+		Expected:  []string{},
+		Timestamp: time.Now(),
+	}
+
+	for _, server := range servers {
+		addrs, e := queryDns(server, domain, dnsCheckTimeout)
+		if e != nil {
+			err = e
+			continue
+		}
+		result.Expected = append(result.Expected, addrs...)
+	}
+
+	if len(result.Expected) == 0 {
+		if err == nil {
+			err = &errortypes.RequestError{
+				errors.New("network: No dns check response from servers"),
+			}
+		}
+		result = nil
+		return
+	}
+	err = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCheckTimeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", domain)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to resolve dns check domain"),
+		}
+		result = nil
+		return
+	}
+
+	for _, ip := range ips {
+		resolver := ip.String()
+		if result.Resolver == "" {
+			result.Resolver = resolver
+		}
+
+		for _, expected := range result.Expected {
+			if sameResolver(resolver, expected) {
+				result.Resolver = resolver
+				result.Passed = true
+				return
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	hijackTimeout  = 2 * time.Second
+	hijackCacheTtl = 5 * time.Minute
+	hijackQuery    = "example.com"
+)
+
+var (
+	// Documentation addresses have no resolvers, a response to a query
+	// sent to them can only come from a device intercepting port 53
+	HijackProbeAddrs = []string{
+		"192.0.2.53:53",
+		"198.51.100.53:53",
+	}
+	hijackResult *HijackResult
+	hijackProbe  bool
+	hijackLock   = sync.Mutex{}
+)
+
+type HijackResult struct {
+	Hijacked  bool      `json:"hijacked"`
+	Responder string    `json:"responder"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func dnsQuery(id uint16, name string) (query []byte) {
+	query = make([]byte, 12)
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], 0x0100)
+	binary.BigEndian.PutUint16(query[4:6], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, 0, 1, 0, 1)
+
+	return
+}
+
+func probeHijack(addr string) (hijacked bool, err error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to open dns probe socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	idByt := make([]byte, 2)
+	_, err = rand.Read(idByt)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "network: Failed to generate dns query id"),
+		}
+		return
+	}
+	id := binary.BigEndian.Uint16(idByt)
+
+	_, err = conn.Write(dnsQuery(id, hijackQuery))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to send dns probe"),
+		}
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(hijackTimeout))
+
+	buf := make([]byte, 512)
+	for {
+		n, e := conn.Read(buf)
+		if e != nil {
+			return
+		}
+
+		if n >= 12 && binary.BigEndian.Uint16(buf[0:2]) == id &&
+			buf[2]&0x80 != 0 {
+
+			hijacked = true
+			return
+		}
+	}
+}
+
+func DetectDnsHijack() (result *HijackResult, err error) {
+	result = &HijackResult{
+		Timestamp: time.Now(),
+	}
+
+	for _, addr := range HijackProbeAddrs {
+		hijacked, e := probeHijack(addr)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		if hijacked {
+			result.Hijacked = true
+			result.Responder = addr
+			break
+		}
+	}
+
+	if err != nil && !result.Hijacked {
+		result = nil
+		return
+	}
+	err = nil
+
+	hijackLock.Lock()
+	hijackResult = result
+	hijackLock.Unlock()
+
+	if result.Hijacked {
+		logrus.WithFields(logrus.Fields{
+			"responder": result.Responder,
+		}).Warn("network: Network intercepts DNS traffic")
+	}
+
+	return
+}
+
+func GetDnsHijack() (result *HijackResult) {
+	hijackLock.Lock()
+	result = hijackResult
+	hijackLock.Unlock()
+
+	return
+}
+
+// Start a background probe unless a recent result is available, used
+// before connecting while dns traffic still uses the physical network
+func ProbeDnsHijack() {
+	hijackLock.Lock()
+	if hijackProbe || (hijackResult != nil &&
+		time.Since(hijackResult.Timestamp) < hijackCacheTtl) {
+
+		hijackLock.Unlock()
+		return
+	}
+	hijackProbe = true
+	hijackLock.Unlock()
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("network: Panic")
+				panic(panc)
+			}
+		}()
+
+		_, err := DetectDnsHijack()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("network: Failed to probe DNS interception")
+		}
+
+		hijackLock.Lock()
+		hijackProbe = false
+		hijackLock.Unlock()
+	}()
+}
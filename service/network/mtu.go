@@ -0,0 +1,96 @@
+package network
+
+import (
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	MinMtu = 576
+	MaxMtu = 1500
+
+	icmpOverhead = 28
+)
+
+// Send a ping with the don't fragment bit set, fails when the packet is
+// larger than the path allows
+func pingDf(addr string, size int) bool {
+	sizeStr := strconv.Itoa(size)
+
+	switch runtime.GOOS {
+	case "linux":
+		_, err := utils.ExecOutput("ping", "-c", "1", "-W", "1",
+			"-M", "do", "-s", sizeStr, addr)
+		return err == nil
+	case "darwin":
+		_, err := utils.ExecOutput("/sbin/ping", "-c", "1", "-t", "1",
+			"-D", "-s", sizeStr, addr)
+		return err == nil
+	case "windows":
+		output, err := utils.ExecOutput("ping", "-n", "1", "-w", "1000",
+			"-f", "-l", sizeStr, addr)
+		return err == nil && strings.Contains(output, "TTL=")
+	}
+
+	return false
+}
+
+// Find the largest packet that reaches the host without fragmentation,
+// the host must respond to ping. Only IPv4 hosts are probed.
+func ProbeMtu(host string) (mtu int, err error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to resolve mtu probe host"),
+		}
+		return
+	}
+
+	addr := ""
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			addr = ip.String()
+			break
+		}
+	}
+	if addr == "" {
+		err = &errortypes.RequestError{
+			errors.Newf("network: No IPv4 address for host '%s'", host),
+		}
+		return
+	}
+
+	low := MinMtu - icmpOverhead
+	high := MaxMtu - icmpOverhead
+
+	if pingDf(addr, high) {
+		mtu = MaxMtu
+		return
+	}
+
+	if !pingDf(addr, low) {
+		err = &errortypes.RequestError{
+			errors.Newf("network: Host '%s' does not respond to ping", host),
+		}
+		return
+	}
+
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if pingDf(addr, mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	mtu = low + icmpOverhead
+
+	return
+}
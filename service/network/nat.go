@@ -0,0 +1,258 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	NatUnknown    = "unknown"
+	NatOpen       = "open"
+	NatCone       = "cone"
+	NatSymmetric  = "symmetric"
+	NatUdpBlocked = "udp_blocked"
+
+	stunMagicCookie     = 0x2112a442
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMappedAddr      = 0x0001
+	stunXorMappedAddr   = 0x0020
+	stunAttempts        = 3
+	stunTimeout         = 1 * time.Second
+)
+
+var (
+	StunServers = []string{
+		"stun.l.google.com:19302",
+		"stun.cloudflare.com:3478",
+	}
+	natResult *NatResult
+	natLock   = sync.Mutex{}
+)
+
+type NatResult struct {
+	Type        string    `json:"type"`
+	LocalPort   int       `json:"local_port"`
+	MappedAddrs []string  `json:"mapped_addrs"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+func stunRequest(conn *net.UDPConn, addr *net.UDPAddr) (
+	mapped *net.UDPAddr, err error) {
+
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(req[2:4], 0)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+
+	_, err = rand.Read(req[8:20])
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "network: Failed to generate stun transaction"),
+		}
+		return
+	}
+
+	buf := make([]byte, 1500)
+
+	for i := 0; i < stunAttempts; i++ {
+		_, err = conn.WriteToUDP(req, addr)
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "network: Failed to send stun request"),
+			}
+			return
+		}
+
+		_ = conn.SetReadDeadline(time.Now().Add(stunTimeout))
+
+		for {
+			n, from, e := conn.ReadFromUDP(buf)
+			if e != nil {
+				break
+			}
+
+			if !from.IP.Equal(addr.IP) || n < 20 {
+				continue
+			}
+
+			mapped = parseStunResponse(buf[:n], req[8:20])
+			if mapped != nil {
+				err = nil
+				return
+			}
+		}
+	}
+
+	err = &errortypes.RequestError{
+		errors.Newf("network: No stun response from %s", addr),
+	}
+	return
+}
+
+func parseStunResponse(data, txId []byte) (mapped *net.UDPAddr) {
+	if binary.BigEndian.Uint16(data[0:2]) != stunBindingResponse ||
+		binary.BigEndian.Uint32(data[4:8]) != stunMagicCookie ||
+		string(data[8:20]) != string(txId) {
+
+		return
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if 20+length > len(data) {
+		return
+	}
+	attrs := data[20 : 20+length]
+
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			return
+		}
+		value := attrs[4 : 4+attrLen]
+
+		if (attrType == stunXorMappedAddr || attrType == stunMappedAddr) &&
+			attrLen >= 8 && value[1] == 0x01 {
+
+			port := binary.BigEndian.Uint16(value[2:4])
+			ip := make(net.IP, 4)
+			copy(ip, value[4:8])
+
+			if attrType == stunXorMappedAddr {
+				port ^= stunMagicCookie >> 16
+				cookie := make([]byte, 4)
+				binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+				for i := range ip {
+					ip[i] ^= cookie[i]
+				}
+			}
+
+			mapped = &net.UDPAddr{
+				IP:   ip,
+				Port: int(port),
+			}
+
+			if attrType == stunXorMappedAddr {
+				return
+			}
+		}
+
+		next := 4 + (attrLen+3)&^3
+		if next > len(attrs) {
+			return
+		}
+		attrs = attrs[next:]
+	}
+
+	return
+}
+
+func isLocalIp(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func DetectNat() (result *NatResult, err error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "network: Failed to open stun socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	result = &NatResult{
+		Type:        NatUnknown,
+		LocalPort:   conn.LocalAddr().(*net.UDPAddr).Port,
+		MappedAddrs: []string{},
+		Timestamp:   time.Now(),
+	}
+
+	resolved := 0
+	mappedAddrs := []*net.UDPAddr{}
+	for _, server := range StunServers {
+		addr, e := net.ResolveUDPAddr("udp4", server)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"server": server,
+				"error":  e,
+			}).Warn("network: Failed to resolve stun server")
+			continue
+		}
+		resolved += 1
+
+		mapped, e := stunRequest(conn, addr)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"server": server,
+				"error":  e,
+			}).Warn("network: Stun request failed")
+			continue
+		}
+
+		mappedAddrs = append(mappedAddrs, mapped)
+		result.MappedAddrs = append(result.MappedAddrs, mapped.String())
+	}
+
+	if resolved == 0 {
+		result.Type = NatUnknown
+	} else if len(mappedAddrs) == 0 {
+		result.Type = NatUdpBlocked
+	} else if isLocalIp(mappedAddrs[0].IP) &&
+		mappedAddrs[0].Port == result.LocalPort {
+
+		result.Type = NatOpen
+	} else if len(mappedAddrs) > 1 {
+		// Filtering is not tested, a consistent mapping across servers
+		// only identifies an endpoint independent mapping
+		result.Type = NatCone
+		for _, mapped := range mappedAddrs[1:] {
+			if !mapped.IP.Equal(mappedAddrs[0].IP) ||
+				mapped.Port != mappedAddrs[0].Port {
+
+				result.Type = NatSymmetric
+				break
+			}
+		}
+	}
+
+	natLock.Lock()
+	natResult = result
+	natLock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"type":         result.Type,
+		"local_port":   result.LocalPort,
+		"mapped_addrs": result.MappedAddrs,
+	}).Info("network: Detected nat type")
+
+	return
+}
+
+func GetNat() (result *NatResult) {
+	natLock.Lock()
+	result = natResult
+	natLock.Unlock()
+
+	return
+}
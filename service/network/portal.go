@@ -0,0 +1,148 @@
+package network
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	portalTimeout = 5 * time.Second
+	portalMaxBody = 4096
+)
+
+var (
+	// Endpoints return an empty 204 response, a redirect or a page is
+	// injected by a captive portal
+	DefaultPortalProbeUrls = []string{
+		"http://connectivitycheck.gstatic.com/generate_204",
+		"http://clients3.google.com/generate_204",
+	}
+	portalResult *PortalResult
+	portalLock   = sync.Mutex{}
+)
+
+// Probe endpoints must return an empty 204 or 200 response
+func GetPortalProbeUrls() []string {
+	if len(config.Config.PortalProbeUrls) > 0 {
+		return config.Config.PortalProbeUrls
+	}
+	return DefaultPortalProbeUrls
+}
+
+type PortalResult struct {
+	Portal    bool      `json:"portal"`
+	Online    bool      `json:"online"`
+	Redirect  string    `json:"redirect"`
+	Probe     string    `json:"probe"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func probePortal(probeUrl string) (result *PortalResult, err error) {
+	// Proxies are bypassed and redirects are not followed, the portal
+	// redirect is reported for the user to open
+	client := &http.Client{
+		Timeout: portalTimeout,
+		Transport: &http.Transport{
+			Proxy:             nil,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(probeUrl)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "network: Failed to request portal probe"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, portalMaxBody))
+
+	result = &PortalResult{
+		Online:    true,
+		Probe:     probeUrl,
+		Timestamp: time.Now(),
+	}
+
+	if res.StatusCode == 204 ||
+		(res.StatusCode == 200 && len(body) == 0) {
+
+		return
+	}
+
+	// Errors from the probe endpoint or a filtering proxy are not a portal,
+	// portals respond with a page, a redirect or network authentication
+	// required
+	if res.StatusCode != 200 && res.StatusCode != 511 &&
+		(res.StatusCode < 300 || res.StatusCode >= 400) {
+
+		result = nil
+		err = &errortypes.RequestError{
+			errors.Newf("network: Portal probe returned status %d",
+				res.StatusCode),
+		}
+		return
+	}
+
+	result.Portal = true
+	result.Online = false
+	result.Redirect = res.Header.Get("Location")
+
+	return
+}
+
+// Probe for a captive portal, a network without internet access returns an
+// error and a network behind a portal returns a result with portal set
+func DetectPortal() (result *PortalResult, err error) {
+	for _, probeUrl := range GetPortalProbeUrls() {
+		res, e := probePortal(probeUrl)
+		if e != nil {
+			err = e
+			continue
+		}
+
+		result = res
+		err = nil
+		break
+	}
+
+	if result == nil {
+		return
+	}
+
+	portalLock.Lock()
+	prevResult := portalResult
+	portalResult = result
+	portalLock.Unlock()
+
+	if result.Portal && (prevResult == nil || !prevResult.Portal) {
+		logrus.WithFields(logrus.Fields{
+			"probe":    result.Probe,
+			"redirect": result.Redirect,
+		}).Warn("network: Captive portal detected")
+	} else if !result.Portal && prevResult != nil && prevResult.Portal {
+		logrus.Info("network: Captive portal cleared")
+	}
+
+	return
+}
+
+func GetPortal() (result *PortalResult) {
+	portalLock.Lock()
+	result = portalResult
+	portalLock.Unlock()
+
+	return
+}
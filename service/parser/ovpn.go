@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
@@ -34,8 +35,11 @@ type Ovpn struct {
 	ServerPollTimeout int
 	RenegSec          int
 	RedirectGateway   string
+	Routes6           []string
 	SndBuf            int
 	RcvBuf            int
+	TunMtu            int
+	MssFix            int
 	RemoteCertTls     string
 	Compress          string
 	CompLzo           string
@@ -49,6 +53,30 @@ type Ovpn struct {
 
 	DisableGateway bool
 	DisableDns     bool
+	PreferChacha   bool
+}
+
+// Move remotes for the host first and disable remote random to connect
+// to the host first
+func (o *Ovpn) PreferRemote(host string) {
+	remotes := []Remote{}
+	for _, remote := range o.Remotes {
+		if remote.Host == host {
+			remotes = append(remotes, remote)
+		}
+	}
+	if len(remotes) == 0 {
+		return
+	}
+
+	for _, remote := range o.Remotes {
+		if remote.Host != host {
+			remotes = append(remotes, remote)
+		}
+	}
+
+	o.Remotes = remotes
+	o.RemoteRandom = false
 }
 
 func (o *Ovpn) Export() string {
@@ -114,12 +142,21 @@ func (o *Ovpn) Export() string {
 	if o.RedirectGateway != "" {
 		output += fmt.Sprintf("redirect-gateway %s\n", o.RedirectGateway)
 	}
+	for _, route := range o.Routes6 {
+		output += fmt.Sprintf("route-ipv6 %s\n", route)
+	}
 	if o.SndBuf > 0 {
 		output += fmt.Sprintf("sndbuf %d\n", o.SndBuf)
 	}
 	if o.RcvBuf > 0 {
 		output += fmt.Sprintf("rcvbuf %d\n", o.RcvBuf)
 	}
+	if o.TunMtu > 0 {
+		output += fmt.Sprintf("tun-mtu %d\n", o.TunMtu)
+	}
+	if o.MssFix > 0 {
+		output += fmt.Sprintf("mssfix %d\n", o.MssFix)
+	}
 	if o.RemoteCertTls != "" {
 		output += fmt.Sprintf("remote-cert-tls %s\n", o.RemoteCertTls)
 	}
@@ -142,6 +179,10 @@ func (o *Ovpn) Export() string {
 
 	if o.DisableGateway {
 		output += "pull-filter ignore \"redirect-gateway\"\n"
+		output += "pull-filter ignore \"route-ipv6 ::/0\"\n"
+		output += "pull-filter ignore \"route-ipv6 ::/1\"\n"
+		output += "pull-filter ignore \"route-ipv6 8000::/1\"\n"
+		output += "pull-filter ignore \"route-ipv6 2000::/3\"\n"
 	}
 
 	if o.DisableDns {
@@ -151,8 +192,13 @@ func (o *Ovpn) Export() string {
 	output += "pull-filter ignore \"ping-restart\"\n"
 
 	output += "ignore-unknown-option data-ciphers\n"
-	output += "data-ciphers \"AES-256-GCM:AES-128-GCM:" +
-		"CHACHA20-POLY1305:AES-256-CBC:AES-128-CBC\"\n"
+	if o.PreferChacha {
+		output += "data-ciphers \"CHACHA20-POLY1305:AES-256-GCM:" +
+			"AES-128-GCM:AES-256-CBC:AES-128-CBC\"\n"
+	} else {
+		output += "data-ciphers \"AES-256-GCM:AES-128-GCM:" +
+			"CHACHA20-POLY1305:AES-256-CBC:AES-128-CBC\"\n"
+	}
 
 	if o.CaCert != "" {
 		output += fmt.Sprintf("<ca>\n%s</ca>\n", o.CaCert)
@@ -516,45 +562,78 @@ func Import(data, fixedRemote, fixedRemote6 string,
 			o.RenegSec = renegSec
 			break
 		case "redirect-gateway":
-			if len(lines) != 2 {
+			if len(lines) < 2 {
 				logrus.WithFields(logrus.Fields{
 					"line": line,
 				}).Warn("parser: Configuration line ignored [35]")
 				continue
 			}
 
-			switch strings.ToLower(lines[1]) {
-			case "local":
-				o.RedirectGateway = "local"
-				break
-			case "autolocal":
-				o.RedirectGateway = "autolocal"
-				break
-			case "def1":
-				o.RedirectGateway = "def1"
-				break
-			case "bypass-dhcp":
-				o.RedirectGateway = "bypass-dhcp"
-				break
-			case "bypass-dns":
-				o.RedirectGateway = "bypass-dns"
-				break
-			case "block-local":
-				o.RedirectGateway = "block-local"
-				break
-			case "ipv6":
-				o.RedirectGateway = "ipv6"
-				break
-			case "!ipv4":
-				o.RedirectGateway = "!ipv4"
-				break
-			default:
+			flags := []string{}
+			for _, flag := range lines[1:] {
+				flag = strings.ToLower(flag)
+				switch flag {
+				case "local", "autolocal", "def1", "bypass-dhcp",
+					"bypass-dns", "block-local", "ipv6", "!ipv4":
+
+					flags = append(flags, flag)
+					break
+				default:
+					logrus.WithFields(logrus.Fields{
+						"line": line,
+						"flag": flag,
+					}).Warn("parser: Configuration flag ignored [36]")
+				}
+			}
+
+			if len(flags) == 0 {
+				continue
+			}
+
+			o.RedirectGateway = strings.Join(flags, " ")
+			break
+		case "route-ipv6":
+			if len(lines) < 2 || len(lines) > 4 {
 				logrus.WithFields(logrus.Fields{
 					"line": line,
-				}).Warn("parser: Configuration line ignored [36]")
+				}).Warn("parser: Configuration line ignored [37]")
 				continue
 			}
 
+			_, network, e := net.ParseCIDR(lines[1])
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"line": line,
+				}).Warn("parser: Configuration line ignored [38]")
+				continue
+			}
+
+			if o.DisableGateway && network.String() == "::/0" {
+				continue
+			}
+
+			route := network.String()
+			if len(lines) > 2 {
+				if net.ParseIP(lines[2]) == nil && lines[2] != "default" {
+					logrus.WithFields(logrus.Fields{
+						"line": line,
+					}).Warn("parser: Configuration line ignored [39]")
+					continue
+				}
+				route += " " + lines[2]
+			}
+			if len(lines) > 3 {
+				metric, e := strconv.Atoi(lines[3])
+				if e != nil {
+					logrus.WithFields(logrus.Fields{
+						"line": line,
+					}).Warn("parser: Configuration line ignored [40]")
+					continue
+				}
+				route += " " + strconv.Itoa(metric)
+			}
+
+			o.Routes6 = append(o.Routes6, route)
 			break
 		case "sndbuf":
 			if len(lines) != 2 {
@@ -592,6 +671,42 @@ func Import(data, fixedRemote, fixedRemote6 string,
 
 			o.RcvBuf = rcvbuf
 			break
+		case "tun-mtu":
+			if len(lines) != 2 {
+				logrus.WithFields(logrus.Fields{
+					"line": line,
+				}).Warn("parser: Configuration line ignored [41]")
+				continue
+			}
+
+			tunMtu, e := strconv.Atoi(lines[1])
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"line": line,
+				}).Warn("parser: Configuration line ignored [42]")
+				continue
+			}
+
+			o.TunMtu = tunMtu
+			break
+		case "mssfix":
+			if len(lines) != 2 {
+				logrus.WithFields(logrus.Fields{
+					"line": line,
+				}).Warn("parser: Configuration line ignored [43]")
+				continue
+			}
+
+			mssFix, e := strconv.Atoi(lines[1])
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"line": line,
+				}).Warn("parser: Configuration line ignored [44]")
+				continue
+			}
+
+			o.MssFix = mssFix
+			break
 		case "remote-cert-tls":
 			if len(lines) != 2 {
 				logrus.WithFields(logrus.Fields{
@@ -0,0 +1,303 @@
+package pipe
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Run an overlapped operation and wait for the result, the event is
+// signaled when the operation completes or is cancelled
+func overlapped(handle windows.Handle,
+	op func(o *windows.Overlapped, done *uint32) error,
+	pending func(o *windows.Overlapped)) (n int, err error) {
+
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(event)
+
+	o := &windows.Overlapped{
+		HEvent: event,
+	}
+	var done uint32
+
+	err = op(o, &done)
+	if err == windows.ERROR_IO_PENDING {
+		if pending != nil {
+			pending(o)
+		}
+		err = windows.GetOverlappedResult(handle, o, &done, true)
+	} else if err == nil {
+		err = windows.GetOverlappedResult(handle, o, &done, true)
+	}
+	n = int(done)
+
+	return
+}
+
+// Wait for a client to connect to the pipe instance
+func connect(handle windows.Handle) (err error) {
+	_, err = overlapped(handle, func(o *windows.Overlapped,
+		done *uint32) error {
+
+		return windows.ConnectNamedPipe(handle, o)
+	}, nil)
+	if err == windows.ERROR_PIPE_CONNECTED {
+		err = nil
+	}
+
+	return
+}
+
+// Deadline of one direction, the pending operation is cancelled when the
+// deadline expires. Setting a deadline in the past cancels the pending
+// operation, which net/http uses to stop the background read.
+type deadline struct {
+	handle  windows.Handle
+	timer   *time.Timer
+	pending *windows.Overlapped
+	expired bool
+	lock    sync.Mutex
+}
+
+// Must hold lock
+func (d *deadline) expire() {
+	d.expired = true
+	if d.pending != nil {
+		_ = windows.CancelIoEx(d.handle, d.pending)
+	}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.expired = false
+
+	if t.IsZero() {
+		return
+	}
+
+	dur := time.Until(t)
+	if dur <= 0 {
+		d.expire()
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(dur, func() {
+		d.lock.Lock()
+		if d.timer == timer {
+			d.expire()
+		}
+		d.lock.Unlock()
+	})
+	d.timer = timer
+}
+
+func (d *deadline) isExpired() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.expired
+}
+
+// Track the pending operation, an operation started after the deadline
+// expired is cancelled immediately
+func (d *deadline) begin(o *windows.Overlapped) {
+	d.lock.Lock()
+	d.pending = o
+	if d.expired {
+		_ = windows.CancelIoEx(d.handle, o)
+	}
+	d.lock.Unlock()
+}
+
+func (d *deadline) end() (expired bool) {
+	d.lock.Lock()
+	d.pending = nil
+	expired = d.expired
+	d.lock.Unlock()
+	return
+}
+
+func (d *deadline) stop() {
+	d.lock.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.lock.Unlock()
+}
+
+// Connected pipe instance opened for overlapped io, reads and writes can
+// be pending at the same time and are cancelled by deadlines and close
+type pipeConn struct {
+	handle        windows.Handle
+	readDeadline  *deadline
+	writeDeadline *deadline
+	ops           sync.WaitGroup
+	closed        bool
+	lock          sync.Mutex
+}
+
+func newPipeConn(handle windows.Handle) *pipeConn {
+	return &pipeConn{
+		handle: handle,
+		readDeadline: &deadline{
+			handle: handle,
+		},
+		writeDeadline: &deadline{
+			handle: handle,
+		},
+	}
+}
+
+func (c *pipeConn) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+func (c *pipeConn) do(dl *deadline,
+	op func(o *windows.Overlapped, done *uint32) error) (n int, err error) {
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		err = net.ErrClosed
+		return
+	}
+	c.ops.Add(1)
+	c.lock.Unlock()
+	defer c.ops.Done()
+
+	if dl.isExpired() {
+		err = os.ErrDeadlineExceeded
+		return
+	}
+
+	n, err = overlapped(c.handle, op, func(o *windows.Overlapped) {
+		dl.begin(o)
+		if c.isClosed() {
+			_ = windows.CancelIoEx(c.handle, o)
+		}
+	})
+	expired := dl.end()
+
+	if err == windows.ERROR_OPERATION_ABORTED {
+		if c.isClosed() {
+			err = net.ErrClosed
+		} else if expired {
+			err = os.ErrDeadlineExceeded
+		}
+	}
+
+	return
+}
+
+func (c *pipeConn) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+
+	n, err = c.do(c.readDeadline, func(o *windows.Overlapped,
+		done *uint32) error {
+
+		return windows.ReadFile(c.handle, b, done, o)
+	})
+	switch err {
+	case windows.ERROR_BROKEN_PIPE, windows.ERROR_PIPE_NOT_CONNECTED,
+		windows.ERROR_HANDLE_EOF:
+
+		err = io.EOF
+	}
+
+	return
+}
+
+func (c *pipeConn) Write(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+
+	n, err = c.do(c.writeDeadline, func(o *windows.Overlapped,
+		done *uint32) error {
+
+		return windows.WriteFile(c.handle, b, done, o)
+	})
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+
+	return
+}
+
+// Cancel pending operations and wait for them to return before closing
+// the handle
+func (c *pipeConn) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.lock.Unlock()
+
+	_ = windows.CancelIoEx(c.handle, nil)
+	c.ops.Wait()
+
+	c.readDeadline.stop()
+	c.writeDeadline.stop()
+
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return Addr{}
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return Addr{}
+}
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// Process id of the client, -1 if unavailable
+func (c *pipeConn) ClientPid() int {
+	var pid uint32
+
+	ret, _, _ := procGetNamedPipeClientProcessId.Call(
+		uintptr(c.handle),
+		uintptr(unsafe.Pointer(&pid)),
+	)
+	if ret == 0 {
+		return -1
+	}
+
+	return int(pid)
+}
@@ -0,0 +1,22 @@
+// Named pipe listener for the service API on Windows. Access to the pipe
+// is limited by its security descriptor to the system, administrators and
+// the user logged on to the console, the tcp port is open to every local
+// process.
+package pipe
+
+const (
+	Path    = `\\.\pipe\pritunl`
+	Network = "pipe"
+
+	bufferSize = 65536
+)
+
+type Addr struct{}
+
+func (a Addr) Network() string {
+	return Network
+}
+
+func (a Addr) String() string {
+	return Path
+}
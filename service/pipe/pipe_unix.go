@@ -0,0 +1,20 @@
+//go:build !windows
+
+package pipe
+
+import (
+	"net"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+// Service API is served over a unix socket
+const Supported = false
+
+func Listen() (lstnr net.Listener, err error) {
+	err = &errortypes.ReadError{
+		errors.New("pipe: Named pipes not supported on this platform"),
+	}
+	return
+}
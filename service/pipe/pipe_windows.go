@@ -0,0 +1,323 @@
+package pipe
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+)
+
+const Supported = true
+
+const (
+	// Network logons denied, system and administrators full access
+	securityDescriptor = "D:P(D;;GA;;;NU)(A;;GA;;;SY)(A;;GA;;;BA)"
+
+	// Read and write without FILE_CREATE_PIPE_INSTANCE, which is included
+	// in the generic write right and would allow the user to create a
+	// server instance of the pipe
+	userAccess = windows.FILE_GENERIC_READ | windows.FILE_WRITE_DATA
+
+	securityRefresh = 2 * time.Second
+)
+
+var (
+	kernel32                        = windows.NewLazySystemDLL("kernel32.dll")
+	procDisconnectNamedPipe         = kernel32.NewProc("DisconnectNamedPipe")
+	procGetNamedPipeClientProcessId = kernel32.NewProc(
+		"GetNamedPipeClientProcessId")
+)
+
+type listener struct {
+	path      *uint16
+	sddl      string
+	handle    windows.Handle
+	accepting bool
+	closed    bool
+	lock      sync.Mutex
+}
+
+// Sid of the user logged on to the console, empty when no user is logged
+// on. Users of other terminal server sessions are not granted access.
+func consoleUserSid() string {
+	session := windows.WTSGetActiveConsoleSessionId()
+	if session == 0xffffffff {
+		return ""
+	}
+
+	var token windows.Token
+	err := windows.WTSQueryUserToken(session, &token)
+	if err != nil {
+		return ""
+	}
+	defer token.Close()
+
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+
+	return user.User.Sid.String()
+}
+
+func getSecurityDescriptor() (sddl string) {
+	sddl = securityDescriptor
+
+	userSid := consoleUserSid()
+	if userSid != "" {
+		sddl += fmt.Sprintf("(A;;0x%x;;;%s)", userAccess, userSid)
+	}
+
+	return
+}
+
+func parseSecurityDescriptor(sddl string) (
+	sd *windows.SECURITY_DESCRIPTOR, err error) {
+
+	sd, err = windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pipe: Failed to parse security descriptor"),
+		}
+		return
+	}
+
+	return
+}
+
+// Instances share the security descriptor of the pipe, the descriptor is
+// updated when the console user changes
+func (l *listener) updateSecurity(handle windows.Handle) (err error) {
+	sddl := getSecurityDescriptor()
+	if sddl == l.sddl {
+		return
+	}
+
+	sd, err := parseSecurityDescriptor(sddl)
+	if err != nil {
+		return
+	}
+
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pipe: Failed to read security descriptor"),
+		}
+		return
+	}
+
+	err = windows.SetSecurityInfo(
+		handle,
+		windows.SE_KERNEL_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|
+			windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil,
+		nil,
+		dacl,
+		nil,
+	)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "pipe: Failed to set pipe security"),
+		}
+		return
+	}
+
+	l.sddl = sddl
+
+	return
+}
+
+// Refresh the security descriptor while waiting for clients, the console
+// user can log on while no connection is accepted
+func (l *listener) watchSecurity() {
+	for {
+		time.Sleep(securityRefresh)
+
+		l.lock.Lock()
+		if l.closed {
+			l.lock.Unlock()
+			return
+		}
+
+		err := l.updateSecurity(l.handle)
+		l.lock.Unlock()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("pipe: Failed to update pipe security")
+		}
+	}
+}
+
+// Create a pipe instance, the first instance fails if the pipe was
+// already created by another process
+func (l *listener) create(first bool) (handle windows.Handle, err error) {
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+
+	sddl := getSecurityDescriptor()
+	sd, err := parseSecurityDescriptor(sddl)
+	if err != nil {
+		return
+	}
+
+	sa := &windows.SecurityAttributes{
+		SecurityDescriptor: sd,
+		InheritHandle:      0,
+	}
+	sa.Length = uint32(unsafe.Sizeof(*sa))
+
+	handle, err = windows.CreateNamedPipe(
+		l.path,
+		flags,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|
+			windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES,
+		bufferSize,
+		bufferSize,
+		0,
+		sa,
+	)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "pipe: Failed to create named pipe"),
+		}
+		return
+	}
+
+	if first {
+		l.sddl = sddl
+		return
+	}
+
+	err = l.updateSecurity(handle)
+	if err != nil {
+		_ = windows.CloseHandle(handle)
+		return
+	}
+
+	return
+}
+
+// Reset a pipe instance to accept another client
+func disconnect(handle windows.Handle) {
+	_, _, _ = procDisconnectNamedPipe.Call(uintptr(handle))
+}
+
+// Wait for a client on the listening instance, a new instance is created
+// before returning so the pipe always exists while the listener is open.
+// Clients that disconnect before the connection completes are skipped,
+// errors other than a closed listener stop the http server.
+func (l *listener) Accept() (conn net.Conn, err error) {
+	var handle windows.Handle
+
+	for {
+		l.lock.Lock()
+		if l.closed {
+			l.lock.Unlock()
+			err = net.ErrClosed
+			return
+		}
+		l.accepting = true
+		handle = l.handle
+		l.lock.Unlock()
+
+		err = connect(handle)
+
+		l.lock.Lock()
+		l.accepting = false
+
+		if l.closed {
+			l.lock.Unlock()
+			_ = windows.CloseHandle(handle)
+			err = net.ErrClosed
+			return
+		}
+
+		if err == nil {
+			break
+		}
+		l.lock.Unlock()
+
+		disconnect(handle)
+	}
+	defer l.lock.Unlock()
+
+	next, err := l.create(false)
+	if err != nil {
+		disconnect(handle)
+		return
+	}
+	l.handle = next
+
+	conn = newPipeConn(handle)
+
+	return
+}
+
+// Close the listening instance, a pending accept is cancelled and closes
+// the instance itself
+func (l *listener) Close() (err error) {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return
+	}
+	l.closed = true
+	handle := l.handle
+	accepting := l.accepting
+	l.lock.Unlock()
+
+	if !accepting {
+		_ = windows.CloseHandle(handle)
+		return
+	}
+
+	_ = windows.CancelIoEx(handle, nil)
+
+	return
+}
+
+func (l *listener) Addr() net.Addr {
+	return Addr{}
+}
+
+func Listen() (lstnr net.Listener, err error) {
+	lstnr, err = listen(Path)
+	return
+}
+
+func listen(pth string) (lstnr net.Listener, err error) {
+	path, err := windows.UTF16PtrFromString(pth)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pipe: Failed to parse pipe path"),
+		}
+		return
+	}
+
+	lstn := &listener{
+		path: path,
+	}
+
+	handle, err := lstn.create(true)
+	if err != nil {
+		return
+	}
+	lstn.handle = handle
+
+	go lstn.watchSecurity()
+
+	lstnr = lstn
+
+	return
+}
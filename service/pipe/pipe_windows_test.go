@@ -0,0 +1,203 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func testPath() string {
+	return fmt.Sprintf(`\\.\pipe\pritunl-test-%d-%d`,
+		os.Getpid(), time.Now().UnixNano())
+}
+
+func dial(pth string) (conn net.Conn, err error) {
+	path, err := windows.UTF16PtrFromString(pth)
+	if err != nil {
+		return
+	}
+
+	handle, err := windows.CreateFile(
+		path,
+		windows.GENERIC_READ|windows.GENERIC_WRITE,
+		0,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OVERLAPPED,
+		0,
+	)
+	if err != nil {
+		return
+	}
+
+	conn = newPipeConn(handle)
+
+	return
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	pth := testPath()
+
+	lstnr, err := listen(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter,
+			r *http.Request) {
+
+			body, _ := io.ReadAll(r.Body)
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write(body)
+		}),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	go server.Serve(lstnr)
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (
+				net.Conn, error) {
+
+				return dial(pth)
+			},
+			MaxIdleConnsPerHost: 4,
+		},
+		Timeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 64)
+	waiter := sync.WaitGroup{}
+
+	for i := 0; i < 16; i++ {
+		waiter.Add(1)
+		go func(i int) {
+			defer waiter.Done()
+
+			for j := 0; j < 8; j++ {
+				data := fmt.Sprintf("request-%d-%d", i, j)
+
+				resp, e := client.Post("http://pipe/echo", "text/plain",
+					strings.NewReader(data))
+				if e != nil {
+					errs <- e
+					return
+				}
+
+				body, e := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if e != nil {
+					errs <- e
+					return
+				}
+
+				if string(body) != data {
+					errs <- fmt.Errorf("bad response %q", body)
+					return
+				}
+			}
+		}(i)
+	}
+
+	waiter.Wait()
+	close(errs)
+
+	for e := range errs {
+		t.Error(e)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	pth := testPath()
+
+	lstnr, err := listen(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lstnr.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, e := lstnr.Accept()
+		if e == nil {
+			accepted <- conn
+		}
+		close(accepted)
+	}()
+
+	client, err := dial(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 16)
+
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	start := time.Now()
+	_, err = conn.Read(buf)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("read deadline not enforced")
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	done := make(chan error, 1)
+	go func() {
+		_, e := conn.Read(buf)
+		done <- e
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	conn.SetReadDeadline(time.Unix(1, 0))
+
+	select {
+	case err = <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending read not cancelled")
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+	}()
+
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("read after deadline reset: %q %v", buf[:n], err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		conn.Close()
+	}()
+
+	_, err = conn.Read(buf)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected closed error, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+package platform
+
+type Adapter struct {
+	Index        int
+	Name         string
+	Description  string
+	HardwareAddr string
+	Up           bool
+}
@@ -0,0 +1,231 @@
+package platform
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func GetAdapters() (adapters []*Adapter, err error) {
+	adapters = []*Adapter{}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "platform: Failed to get interfaces"),
+		}
+		return
+	}
+
+	for _, iface := range ifaces {
+		adapters = append(adapters, &Adapter{
+			Index:        iface.Index,
+			Name:         iface.Name,
+			HardwareAddr: iface.HardwareAddr.String(),
+			Up:           iface.Flags&net.FlagUp != 0,
+		})
+	}
+
+	return
+}
+
+func scutilExec(input string) (output string, err error) {
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader(input)
+
+	outputByt, err := cmd.CombinedOutput()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "platform: Failed to exec scutil"),
+		}
+		return
+	}
+	output = string(outputByt)
+
+	return
+}
+
+// Keys of a dictionary in the dynamic store, arrays are included with the
+// array values and nested dictionaries are not included
+func scutilDict(key string) (vals map[string]string,
+	arrays map[string][]string, err error) {
+
+	vals = map[string]string{}
+	arrays = map[string][]string{}
+
+	output, err := scutilExec(fmt.Sprintf("open\nshow %s\nquit\n", key))
+	if err != nil {
+		return
+	}
+
+	array := ""
+	nested := 0
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if nested > 0 {
+			if line == "}" {
+				nested -= 1
+			} else if strings.HasSuffix(line, "{") {
+				nested += 1
+			}
+			continue
+		}
+		if array != "" && line == "}" {
+			array = ""
+			continue
+		}
+
+		lineSpl := strings.SplitN(line, " : ", 2)
+		if len(lineSpl) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(lineSpl[0])
+		val := strings.TrimSpace(lineSpl[1])
+
+		if array != "" {
+			arrays[array] = append(arrays[array], val)
+		} else if val == "<dictionary> {" {
+			nested = 1
+		} else if val == "<array> {" {
+			array = name
+			arrays[array] = []string{}
+		} else {
+			vals[name] = val
+		}
+	}
+
+	return
+}
+
+// Identifiers of the active network services mapped to the service name
+func getNetworkServiceIds() (serviceIds map[string]string, err error) {
+	serviceIds = map[string]string{}
+
+	output, err := scutilExec(
+		"open\nlist Setup:/Network/Service/[^/]+$\nquit\n")
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		spl := strings.SplitN(line, "= Setup:/Network/Service/", 2)
+		if len(spl) != 2 {
+			continue
+		}
+		serviceId := strings.TrimSpace(spl[1])
+
+		vals, _, e := scutilDict("Setup:/Network/Service/" + serviceId)
+		if e != nil {
+			err = e
+			return
+		}
+
+		name := vals["UserDefinedName"]
+		if name == "" {
+			continue
+		}
+		if _, ok := vals["__INACTIVE__"]; ok {
+			continue
+		}
+
+		serviceIds[serviceId] = name
+	}
+
+	return
+}
+
+// Network services are read from the SystemConfiguration dynamic store
+// which uses fixed key names, networksetup output is localized.
+func GetNetworkServices() (services []string, err error) {
+	services = []string{}
+
+	serviceIds, err := getNetworkServiceIds()
+	if err != nil {
+		return
+	}
+
+	for _, name := range serviceIds {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	return
+}
+
+// IPv6 configuration of a network service, the method is empty when IPv6
+// is off
+type Ipv6Config struct {
+	Method       string
+	Address      string
+	PrefixLength string
+	Router       string
+}
+
+func GetNetworkServiceIpv6(service string) (conf *Ipv6Config, err error) {
+	serviceIds, err := getNetworkServiceIds()
+	if err != nil {
+		return
+	}
+
+	for serviceId, name := range serviceIds {
+		if name != service {
+			continue
+		}
+
+		vals, arrays, e := scutilDict(
+			"Setup:/Network/Service/" + serviceId + "/IPv6")
+		if e != nil {
+			err = e
+			return
+		}
+
+		conf = &Ipv6Config{
+			Method: vals["ConfigMethod"],
+			Router: vals["Router"],
+		}
+		if len(arrays["Addresses"]) > 0 {
+			conf.Address = arrays["Addresses"][0]
+		}
+		if len(arrays["PrefixLength"]) > 0 {
+			conf.PrefixLength = arrays["PrefixLength"][0]
+		}
+
+		return
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.Newf("platform: Network service '%s' not found", service),
+	}
+	return
+}
+
+// Device names of the Wi-Fi network services
+func GetWirelessIfaces() (ifaces []string, err error) {
+	ifaces = []string{}
+
+	serviceIds, err := getNetworkServiceIds()
+	if err != nil {
+		return
+	}
+
+	for serviceId := range serviceIds {
+		vals, _, e := scutilDict(
+			"Setup:/Network/Service/" + serviceId + "/Interface")
+		if e != nil {
+			err = e
+			return
+		}
+
+		if vals["Hardware"] == "AirPort" && vals["DeviceName"] != "" {
+			ifaces = append(ifaces, vals["DeviceName"])
+		}
+	}
+	sort.Strings(ifaces)
+
+	return
+}
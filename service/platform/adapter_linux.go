@@ -0,0 +1,36 @@
+package platform
+
+import (
+	"net"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func GetAdapters() (adapters []*Adapter, err error) {
+	adapters = []*Adapter{}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "platform: Failed to get interfaces"),
+		}
+		return
+	}
+
+	for _, iface := range ifaces {
+		adapters = append(adapters, &Adapter{
+			Index:        iface.Index,
+			Name:         iface.Name,
+			HardwareAddr: iface.HardwareAddr.String(),
+			Up:           iface.Flags&net.FlagUp != 0,
+		})
+	}
+
+	return
+}
+
+func GetNetworkServices() (services []string, err error) {
+	services = []string{}
+	return
+}
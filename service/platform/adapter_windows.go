@@ -0,0 +1,62 @@
+package platform
+
+import (
+	"net"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+func GetAdapters() (adapters []*Adapter, err error) {
+	adapters = []*Adapter{}
+
+	size := uint32(15000)
+	var buf []byte
+
+	for i := 0; i < 3; i++ {
+		buf = make([]byte, size)
+
+		err = windows.GetAdaptersAddresses(
+			windows.AF_UNSPEC,
+			windows.GAA_FLAG_INCLUDE_PREFIX,
+			0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])),
+			&size,
+		)
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			break
+		}
+	}
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "platform: Failed to get adapter addresses"),
+		}
+		return
+	}
+
+	addr := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+	for ; addr != nil; addr = addr.Next {
+		adapter := &Adapter{
+			Index:       int(addr.IfIndex),
+			Name:        windows.UTF16PtrToString(addr.FriendlyName),
+			Description: windows.UTF16PtrToString(addr.Description),
+			Up:          addr.OperStatus == windows.IfOperStatusUp,
+		}
+
+		if addr.PhysicalAddressLength > 0 {
+			adapter.HardwareAddr = net.HardwareAddr(
+				addr.PhysicalAddress[:addr.PhysicalAddressLength]).String()
+		}
+
+		adapters = append(adapters, adapter)
+	}
+
+	return
+}
+
+func GetNetworkServices() (services []string, err error) {
+	services = []string{}
+	return
+}
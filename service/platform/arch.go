@@ -0,0 +1,10 @@
+package platform
+
+import (
+	"runtime"
+)
+
+// Service binary running under x86 emulation on an arm64 system
+func Emulated() bool {
+	return NativeArch() != runtime.GOARCH
+}
@@ -0,0 +1,18 @@
+package platform
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// Rosetta reports the architecture of the translated binary, translated
+// processes are identified by the proc translated sysctl
+func NativeArch() string {
+	translated, err := unix.SysctlUint32("sysctl.proc_translated")
+	if err == nil && translated == 1 {
+		return "arm64"
+	}
+
+	return runtime.GOARCH
+}
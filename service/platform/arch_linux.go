@@ -0,0 +1,27 @@
+package platform
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+func NativeArch() string {
+	uname := &unix.Utsname{}
+
+	err := unix.Uname(uname)
+	if err != nil {
+		return runtime.GOARCH
+	}
+
+	switch unix.ByteSliceToString(uname.Machine[:]) {
+	case "x86_64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i686":
+		return "386"
+	default:
+		return runtime.GOARCH
+	}
+}
@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	machineI386  = 0x014c
+	machineAmd64 = 0x8664
+	machineArm64 = 0xaa64
+)
+
+// Windows on arm64 runs x64 binaries under emulation, the native machine
+// is only available from IsWow64Process2 which is missing on older
+// versions of Windows
+func NativeArch() string {
+	var processMachine, nativeMachine uint16
+
+	err := windows.IsWow64Process2(windows.CurrentProcess(),
+		&processMachine, &nativeMachine)
+	if err != nil {
+		return runtime.GOARCH
+	}
+
+	switch nativeMachine {
+	case machineAmd64:
+		return "amd64"
+	case machineArm64:
+		return "arm64"
+	case machineI386:
+		return "386"
+	default:
+		return runtime.GOARCH
+	}
+}
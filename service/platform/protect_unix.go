@@ -0,0 +1,16 @@
+//go:build !windows
+
+package platform
+
+// Data at rest is protected by file permissions on this platform
+const ProtectSupported = false
+
+func Protect(data []byte) (protected []byte, err error) {
+	protected = data
+	return
+}
+
+func Unprotect(protected []byte) (data []byte, err error) {
+	data = protected
+	return
+}
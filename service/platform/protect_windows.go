@@ -0,0 +1,76 @@
+package platform
+
+import (
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+// Data is encrypted with DPAPI under the service account, only processes
+// running as the same account can decrypt it
+const ProtectSupported = true
+
+func blobBytes(blob *windows.DataBlob) (data []byte) {
+	if blob.Size == 0 || blob.Data == nil {
+		return
+	}
+
+	data = make([]byte, blob.Size)
+	copy(data, unsafe.Slice(blob.Data, blob.Size))
+
+	return
+}
+
+func Protect(data []byte) (protected []byte, err error) {
+	if len(data) == 0 {
+		return
+	}
+
+	in := &windows.DataBlob{
+		Size: uint32(len(data)),
+		Data: &data[0],
+	}
+	out := &windows.DataBlob{}
+
+	err = windows.CryptProtectData(in, nil, nil, 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "platform: Failed to protect data"),
+		}
+		return
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	protected = blobBytes(out)
+
+	return
+}
+
+func Unprotect(protected []byte) (data []byte, err error) {
+	if len(protected) == 0 {
+		return
+	}
+
+	in := &windows.DataBlob{
+		Size: uint32(len(protected)),
+		Data: &protected[0],
+	}
+	out := &windows.DataBlob{}
+
+	err = windows.CryptUnprotectData(in, nil, nil, 0, nil,
+		windows.CRYPTPROTECT_UI_FORBIDDEN, out)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "platform: Failed to unprotect data"),
+		}
+		return
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	data = blobBytes(out)
+
+	return
+}
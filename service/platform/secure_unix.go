@@ -0,0 +1,63 @@
+//go:build !windows
+
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func checkSecure(pth string) (info os.FileInfo, err error) {
+	info, err = os.Stat(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "platform: Failed to stat '%s'", pth),
+		}
+		return
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid != 0 {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' must be owned by root", pth),
+		}
+		return
+	}
+
+	if info.Mode().Perm()&0022 != 0 {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' must not be writable by "+
+				"group or others", pth),
+		}
+		return
+	}
+
+	return
+}
+
+// File executed by the service must be owned by root and only writable by
+// root, including the directory containing it
+func CheckSecureFile(pth string) (err error) {
+	info, err := checkSecure(pth)
+	if err != nil {
+		return
+	}
+
+	if !info.Mode().IsRegular() {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' is not a regular file", pth),
+		}
+		return
+	}
+
+	_, err = checkSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	return
+}
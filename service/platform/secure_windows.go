@@ -0,0 +1,172 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	accessAllowedAceType = 0x0
+	fileDeleteChild      = 0x40
+	trustedInstallerSid  = "S-1-5-80-956008885-3418522649-1831038044-" +
+		"1853292631-2271478464"
+
+	// Rights allowing the file to be modified or replaced, on a directory
+	// the data rights allow adding and deleting entries
+	writeAccess = windows.FILE_WRITE_DATA | windows.FILE_APPEND_DATA |
+		windows.FILE_WRITE_EA | windows.FILE_WRITE_ATTRIBUTES |
+		fileDeleteChild | windows.DELETE | windows.WRITE_DAC |
+		windows.WRITE_OWNER | windows.GENERIC_WRITE | windows.GENERIC_ALL
+)
+
+// ACL
+type aclHeader struct {
+	AclRevision byte
+	Sbz1        byte
+	AclSize     uint16
+	AceCount    uint16
+	Sbz2        uint16
+}
+
+// ACE_HEADER followed by the mask and sid of ACCESS_ALLOWED_ACE
+type accessAllowedAce struct {
+	AceType  byte
+	AceFlags byte
+	AceSize  uint16
+	Mask     uint32
+	SidStart uint32
+}
+
+func trustedSid(sid *windows.SID) bool {
+	if sid.IsWellKnown(windows.WinBuiltinAdministratorsSid) ||
+		sid.IsWellKnown(windows.WinLocalSystemSid) {
+
+		return true
+	}
+
+	installer, err := windows.StringToSid(trustedInstallerSid)
+	if err == nil && sid.Equals(installer) {
+		return true
+	}
+
+	return false
+}
+
+// Owner and DACL must only allow administrators, system and the trusted
+// installer to modify the path, a missing DACL allows everyone access
+func checkSecure(pth string) (err error) {
+	sd, err := windows.GetNamedSecurityInfo(
+		pth,
+		windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|
+			windows.DACL_SECURITY_INFORMATION,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "platform: Failed to get security of '%s'",
+				pth),
+		}
+		return
+	}
+
+	owner, _, err := sd.Owner()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "platform: Failed to get owner of '%s'", pth),
+		}
+		return
+	}
+
+	if owner == nil || !trustedSid(owner) {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' must be owned by administrators",
+				pth),
+		}
+		return
+	}
+
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "platform: Failed to get DACL of '%s'", pth),
+		}
+		return
+	}
+
+	if dacl == nil {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' has no access control list", pth),
+		}
+		return
+	}
+
+	header := (*aclHeader)(unsafe.Pointer(dacl))
+	aceLen := int(unsafe.Sizeof(accessAllowedAce{}))
+	offset := int(unsafe.Sizeof(*header))
+	for i := 0; i < int(header.AceCount); i++ {
+		if offset+aceLen > int(header.AclSize) {
+			break
+		}
+
+		ace := (*accessAllowedAce)(unsafe.Add(unsafe.Pointer(dacl), offset))
+		if ace.AceSize == 0 {
+			break
+		}
+		offset += int(ace.AceSize)
+
+		// Inherit only entries do not apply to the path itself
+		if ace.AceType != accessAllowedAceType ||
+			ace.AceFlags&windows.INHERIT_ONLY_ACE != 0 ||
+			ace.Mask&writeAccess == 0 {
+
+			continue
+		}
+
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		if !trustedSid(sid) {
+			err = &errortypes.PolicyError{
+				errors.Newf("platform: '%s' must not be writable by '%s'",
+					pth, sid.String()),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// File executed by the service must be owned by administrators and only
+// writable by administrators, including the directory containing it
+func CheckSecureFile(pth string) (err error) {
+	info, err := os.Stat(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrapf(err, "platform: Failed to stat '%s'", pth),
+		}
+		return
+	}
+
+	if !info.Mode().IsRegular() {
+		err = &errortypes.PolicyError{
+			errors.Newf("platform: '%s' is not a regular file", pth),
+		}
+		return
+	}
+
+	err = checkSecure(pth)
+	if err != nil {
+		return
+	}
+
+	err = checkSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package platform
+
+import (
+	"time"
+)
+
+// Resume is handled by the wake watch on this platform
+const SuspendSupported = false
+
+func GetSuspendTime() (suspended time.Duration, err error) {
+	return
+}
@@ -0,0 +1,46 @@
+package platform
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+// Fast startup hibernates the kernel session with the service running,
+// time spent suspended is the difference between the tick count and the
+// unbiased interrupt time
+const SuspendSupported = true
+
+var (
+	kernel32                       = windows.NewLazySystemDLL("kernel32.dll")
+	procGetTickCount64             = kernel32.NewProc("GetTickCount64")
+	procQueryUnbiasedInterruptTime = kernel32.NewProc(
+		"QueryUnbiasedInterruptTime")
+)
+
+func GetSuspendTime() (suspended time.Duration, err error) {
+	var unbiased uint64
+
+	ret, _, e := procQueryUnbiasedInterruptTime.Call(
+		uintptr(unsafe.Pointer(&unbiased)),
+	)
+	if ret == 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(e, "platform: Failed to query interrupt time"),
+		}
+		return
+	}
+
+	ticks, _, _ := procGetTickCount64.Call()
+
+	suspended = time.Duration(ticks)*time.Millisecond -
+		time.Duration(unbiased*100)
+	if suspended < 0 {
+		suspended = 0
+	}
+
+	return
+}
@@ -0,0 +1,35 @@
+package platform
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/command"
+)
+
+// Wireless network of the system, empty when not connected to a wireless
+// network. Each Wi-Fi device is checked as the device name depends on the
+// hardware model.
+func GetSsid() (ssid string, err error) {
+	ifaces, err := GetWirelessIfaces()
+	if err != nil {
+		return
+	}
+
+	for _, iface := range ifaces {
+		output, e := command.Command("/usr/sbin/ipconfig",
+			"getsummary", iface).Output()
+		if e != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(output), "\n") {
+			lineSpl := strings.SplitN(strings.TrimSpace(line), " : ", 2)
+			if len(lineSpl) == 2 && lineSpl[0] == "SSID" {
+				ssid = strings.TrimSpace(lineSpl[1])
+				return
+			}
+		}
+	}
+
+	return
+}
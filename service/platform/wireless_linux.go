@@ -0,0 +1,35 @@
+package platform
+
+import (
+	"os"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+// Wireless network of the system, empty when not connected to a wireless
+// network. The active field is translated unless the C locale is set.
+func GetSsid() (ssid string, err error) {
+	cmd := command.Command("nmcli", "-t", "-f", "active,ssid", "dev", "wifi")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	outputByt, err := cmd.Output()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "platform: Failed to exec nmcli"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(outputByt), "\n") {
+		lineSpl := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(lineSpl) == 2 && lineSpl[0] == "yes" {
+			ssid = strings.ReplaceAll(lineSpl[1], "\\:", ":")
+			break
+		}
+	}
+
+	return
+}
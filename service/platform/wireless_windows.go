@@ -0,0 +1,125 @@
+package platform
+
+import (
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	wlanClientVersion           = 2
+	wlanInterfaceConnected      = 1
+	wlanOpcodeCurrentConnection = 7
+	wlanMaxNameLength           = 256
+	dot11SsidMaxLength          = 32
+)
+
+var (
+	wlanapi                = windows.NewLazySystemDLL("wlanapi.dll")
+	procWlanOpenHandle     = wlanapi.NewProc("WlanOpenHandle")
+	procWlanCloseHandle    = wlanapi.NewProc("WlanCloseHandle")
+	procWlanEnumInterfaces = wlanapi.NewProc("WlanEnumInterfaces")
+	procWlanQueryInterface = wlanapi.NewProc("WlanQueryInterface")
+	procWlanFreeMemory     = wlanapi.NewProc("WlanFreeMemory")
+)
+
+// WLAN_INTERFACE_INFO
+type wlanInterfaceInfo struct {
+	InterfaceGuid windows.GUID
+	Description   [wlanMaxNameLength]uint16
+	State         uint32
+}
+
+// WLAN_CONNECTION_ATTRIBUTES up to the SSID of the association attributes
+type wlanConnectionAttributes struct {
+	State       uint32
+	Mode        uint32
+	ProfileName [wlanMaxNameLength]uint16
+	SsidLength  uint32
+	Ssid        [dot11SsidMaxLength]byte
+}
+
+// Wireless network of the system, empty when not connected to a wireless
+// network or when the WLAN service is not running
+func GetSsid() (ssid string, err error) {
+	if wlanapi.Load() != nil {
+		return
+	}
+
+	var version uint32
+	var handle windows.Handle
+	ret, _, _ := procWlanOpenHandle.Call(
+		wlanClientVersion,
+		0,
+		uintptr(unsafe.Pointer(&version)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if ret == uintptr(windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return
+	}
+	if ret != 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(windows.Errno(ret),
+				"platform: Failed to open wlan handle"),
+		}
+		return
+	}
+	defer procWlanCloseHandle.Call(uintptr(handle), 0)
+
+	var list unsafe.Pointer
+	ret, _, _ = procWlanEnumInterfaces.Call(
+		uintptr(handle),
+		0,
+		uintptr(unsafe.Pointer(&list)),
+	)
+	if ret != 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(windows.Errno(ret),
+				"platform: Failed to get wlan interfaces"),
+		}
+		return
+	}
+	defer procWlanFreeMemory.Call(uintptr(list))
+
+	// WLAN_INTERFACE_INFO_LIST count and index followed by the interfaces
+	count := *(*uint32)(list)
+	infos := unsafe.Slice(
+		(*wlanInterfaceInfo)(unsafe.Add(list, 8)), count)
+
+	for i := range infos {
+		if infos[i].State != wlanInterfaceConnected {
+			continue
+		}
+
+		var size uint32
+		var data unsafe.Pointer
+		ret, _, _ = procWlanQueryInterface.Call(
+			uintptr(handle),
+			uintptr(unsafe.Pointer(&infos[i].InterfaceGuid)),
+			wlanOpcodeCurrentConnection,
+			0,
+			uintptr(unsafe.Pointer(&size)),
+			uintptr(unsafe.Pointer(&data)),
+			0,
+		)
+		if ret != 0 {
+			continue
+		}
+
+		attrs := (*wlanConnectionAttributes)(data)
+		length := attrs.SsidLength
+		if length > dot11SsidMaxLength {
+			length = dot11SsidMaxLength
+		}
+		ssid = string(attrs.Ssid[:length])
+		procWlanFreeMemory.Call(uintptr(data))
+
+		if ssid != "" {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,138 @@
+// Device posture reported to the server with connection and sync requests
+// for zero trust policies, each check is only collected when enabled in
+// the config.
+package posture
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	StatusEnabled  = "enabled"
+	StatusDisabled = "disabled"
+	StatusUnknown  = "unknown"
+
+	cacheTtl = 1 * time.Minute
+)
+
+var (
+	cached     *Report
+	cachedTime time.Time
+	cacheLock  = sync.Mutex{}
+)
+
+type Report struct {
+	Platform       string `json:"platform"`
+	OsVersion      string `json:"os_version,omitempty"`
+	DiskEncryption string `json:"disk_encryption,omitempty"`
+	Firewall       string `json:"firewall,omitempty"`
+	ScreenLock     string `json:"screen_lock,omitempty"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+func Enabled() bool {
+	return config.Config.PostureOsVersion ||
+		config.Config.PostureEncryption ||
+		config.Config.PostureFirewall ||
+		config.Config.PostureScreenLock
+}
+
+func check(name string, enabled bool, fn func() (string, error)) string {
+	if !enabled {
+		return ""
+	}
+
+	val, err := fn()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"check": name,
+			"error": err,
+		}).Warn("posture: Failed to collect posture check")
+		return StatusUnknown
+	}
+
+	return val
+}
+
+func collect() (rpt *Report) {
+	rpt = &Report{
+		Platform:  runtime.GOOS,
+		Timestamp: time.Now().Unix(),
+	}
+
+	rpt.OsVersion = check("os_version",
+		config.Config.PostureOsVersion, osVersion)
+	rpt.DiskEncryption = check("disk_encryption",
+		config.Config.PostureEncryption, diskEncryption)
+	rpt.Firewall = check("firewall",
+		config.Config.PostureFirewall, firewall)
+	rpt.ScreenLock = check("screen_lock",
+		config.Config.PostureScreenLock, screenLock)
+
+	return
+}
+
+// Posture of the device, nil when no checks are enabled. Reports are
+// cached to avoid running the checks for every request of a sync.
+func OsVersion() (version string, err error) {
+	return osVersion()
+}
+
+func Get() (rpt *Report) {
+	if !Enabled() {
+		return
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if cached == nil || time.Since(cachedTime) > cacheTtl {
+		cached = collect()
+		cachedTime = time.Now()
+	}
+
+	rpt = cached
+
+	return
+}
+
+// Add the posture to a request authenticated with the sync secret, the
+// posture is signed separately from the request to remain compatible
+// with servers that do not support posture reports
+func Sign(req *http.Request, token, secret, timestamp, nonce string) {
+	rpt := Get()
+	if rpt == nil {
+		return
+	}
+
+	data, err := json.Marshal(rpt)
+	if err != nil {
+		return
+	}
+	data64 := base64.StdEncoding.EncodeToString(data)
+
+	authStr := strings.Join([]string{
+		token,
+		timestamp,
+		nonce,
+		data64,
+	}, "&")
+
+	hashFunc := hmac.New(sha512.New, []byte(secret))
+	hashFunc.Write([]byte(authStr))
+	sig := base64.StdEncoding.EncodeToString(hashFunc.Sum(nil))
+
+	req.Header.Set("Device-Posture", data64)
+	req.Header.Set("Device-Posture-Signature", sig)
+}
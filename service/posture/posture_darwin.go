@@ -0,0 +1,75 @@
+package posture
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func osVersion() (version string, err error) {
+	product, err := utils.ExecOutput("/usr/bin/sw_vers", "-productVersion")
+	if err != nil {
+		return
+	}
+
+	build, err := utils.ExecOutput("/usr/bin/sw_vers", "-buildVersion")
+	if err != nil {
+		return
+	}
+
+	version = "macOS " + strings.TrimSpace(product) +
+		" (" + strings.TrimSpace(build) + ")"
+
+	return
+}
+
+func diskEncryption() (status string, err error) {
+	output, err := utils.ExecOutput("/usr/bin/fdesetup", "status")
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(output, "FileVault is On") {
+		status = StatusEnabled
+	} else {
+		status = StatusDisabled
+	}
+
+	return
+}
+
+func firewall() (status string, err error) {
+	output, err := utils.ExecOutput(
+		"/usr/libexec/ApplicationFirewall/socketfilterfw",
+		"--getglobalstate",
+	)
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(output, "enabled") {
+		status = StatusEnabled
+	} else {
+		status = StatusDisabled
+	}
+
+	return
+}
+
+func screenLock() (status string, err error) {
+	output, err := utils.ExecCombinedOutput(
+		"/usr/sbin/sysadminctl", "-screenLock", "status")
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(output, "screenLock is off") {
+		status = StatusDisabled
+	} else if strings.Contains(output, "screenLock delay") {
+		status = StatusEnabled
+	} else {
+		status = StatusUnknown
+	}
+
+	return
+}
@@ -0,0 +1,97 @@
+package posture
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func osVersion() (version string, err error) {
+	data, err := ioutil.ReadFile("/etc/os-release")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "posture: Failed to read os-release"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "PRETTY_NAME=") {
+			version = strings.Trim(
+				strings.TrimPrefix(line, "PRETTY_NAME="), `"'`)
+			break
+		}
+	}
+
+	kernel, e := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if e == nil {
+		version = strings.TrimSpace(
+			version + " " + strings.TrimSpace(string(kernel)))
+	}
+
+	return
+}
+
+// Root filesystem is encrypted when a crypt device is below its source
+func diskEncryption() (status string, err error) {
+	source, err := utils.ExecOutput("findmnt", "-n", "-o", "SOURCE", "/")
+	if err != nil {
+		return
+	}
+
+	output, err := utils.ExecOutput("lsblk", "-s", "-n", "-o", "TYPE",
+		strings.TrimSpace(source))
+	if err != nil {
+		return
+	}
+
+	status = StatusDisabled
+	for _, typ := range strings.Fields(output) {
+		if typ == "crypt" {
+			status = StatusEnabled
+			break
+		}
+	}
+
+	return
+}
+
+func firewall() (status string, err error) {
+	output, e := utils.ExecOutput("ufw", "status")
+	if e == nil && strings.Contains(output, "Status: active") {
+		status = StatusEnabled
+		return
+	}
+
+	output, e = utils.ExecOutput("firewall-cmd", "--state")
+	if e == nil && strings.TrimSpace(output) == "running" {
+		status = StatusEnabled
+		return
+	}
+
+	output, err = utils.ExecOutput("iptables", "-S", "INPUT")
+	if err != nil {
+		return
+	}
+
+	if strings.Contains(output, "-P INPUT DROP") ||
+		strings.Contains(output, "-j DROP") ||
+		strings.Contains(output, "-j REJECT") {
+
+		status = StatusEnabled
+	} else {
+		status = StatusDisabled
+	}
+
+	return
+}
+
+// Screen lock settings belong to the desktop session of each user and
+// are not available to the service
+func screenLock() (status string, err error) {
+	status = StatusUnknown
+	return
+}
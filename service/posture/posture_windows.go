@@ -0,0 +1,108 @@
+package posture
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"golang.org/x/sys/windows/registry"
+)
+
+func osVersion() (version string, err error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+
+	product, _, err := key.GetStringValue("ProductName")
+	if err != nil {
+		return
+	}
+
+	display, _, _ := key.GetStringValue("DisplayVersion")
+	build, _, _ := key.GetStringValue("CurrentBuild")
+	ubr, _, _ := key.GetIntegerValue("UBR")
+
+	version = strings.TrimSpace(product + " " + display)
+	if build != "" {
+		version += fmt.Sprintf(" (%s.%d)", build, ubr)
+	}
+
+	return
+}
+
+func diskEncryption() (status string, err error) {
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"(Get-BitLockerVolume -MountPoint $env:SystemDrive "+
+			"-ErrorAction Stop).ProtectionStatus",
+	)
+	if err != nil {
+		return
+	}
+
+	if strings.TrimSpace(output) == "On" {
+		status = StatusEnabled
+	} else {
+		status = StatusDisabled
+	}
+
+	return
+}
+
+// Enabled when the firewall is enabled for every network profile
+func firewall() (status string, err error) {
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"Get-NetFirewallProfile | "+
+			"ForEach-Object { $_.Enabled.ToString() }",
+	)
+	if err != nil {
+		return
+	}
+
+	status = StatusUnknown
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if line != "True" {
+			status = StatusDisabled
+			return
+		}
+		status = StatusEnabled
+	}
+
+	return
+}
+
+// Machine inactivity lock policy, the screen saver settings of each user
+// are not available to the service
+func screenLock() (status string, err error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`,
+		registry.QUERY_VALUE)
+	if err != nil {
+		err = nil
+		status = StatusDisabled
+		return
+	}
+	defer key.Close()
+
+	timeout, _, e := key.GetIntegerValue("InactivityTimeoutSecs")
+	if e == nil && timeout > 0 {
+		status = StatusEnabled
+	} else {
+		status = StatusDisabled
+	}
+
+	return
+}
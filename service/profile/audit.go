@@ -0,0 +1,42 @@
+package profile
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/audit"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+)
+
+const (
+	AuditStopped  = "stopped"
+	AuditShutdown = "shutdown"
+)
+
+// Disconnect reason from the last error of the connection, the error
+// codes are the same as the profile notes
+func (p *Profile) auditReason() string {
+	if shutdown {
+		return AuditShutdown
+	}
+
+	note := stats.GetNotes(p.Id)
+	if note != nil && note.LastError != "" &&
+		note.LastErrorTime >= p.startTime.Unix() {
+
+		return note.LastError
+	}
+
+	return AuditStopped
+}
+
+func (p *Profile) recordAudit(action, reason string) {
+	audit.Record(&audit.Entry{
+		Action:      action,
+		ProfileId:   p.Id,
+		ProfileName: p.Name,
+		Mode:        p.Mode,
+		ServerHost:  p.ServerAddr,
+		ClientAddr:  p.ClientAddr,
+		UserId:      p.UserId,
+		Username:    p.Username,
+		Reason:      reason,
+	})
+}
@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+var autostarts = struct {
+	sync.Mutex
+	m      map[string]time.Time
+	synced bool
+}{
+	m: map[string]time.Time{},
+}
+
+// First sync after the service started, the sync that starts the
+// autostart profiles
+func autostartBoot() (boot bool) {
+	autostarts.Lock()
+	boot = !autostarts.synced
+	autostarts.synced = true
+	autostarts.Unlock()
+	return
+}
+
+// Hold the start of a system profile for the configured delay when the
+// service starts, the profile is started by the first sync after the delay
+func delayAutostart(sPrfl *sprofile.Sprofile, boot bool) bool {
+	autostarts.Lock()
+	defer autostarts.Unlock()
+
+	if _, ok := autostarts.m[sPrfl.Id]; ok {
+		return true
+	}
+	if !boot {
+		return false
+	}
+
+	delay := sPrfl.GetStartDelay()
+	if delay <= 0 {
+		return false
+	}
+
+	autostarts.m[sPrfl.Id] = time.Now().Add(delay)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": sPrfl.Id,
+		"delay":      delay.String(),
+	}).Info("profile: Delaying profile autostart")
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		time.Sleep(delay)
+
+		autostarts.Lock()
+		delete(autostarts.m, sPrfl.Id)
+		autostarts.Unlock()
+	}()
+
+	return true
+}
@@ -0,0 +1,239 @@
+package profile
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const bandwidthInterval = 5 * time.Second
+
+var (
+	bandwidths     = map[string]*bandwidth{}
+	bandwidthsLock = sync.Mutex{}
+)
+
+type bandwidthResolution struct {
+	Name     string
+	Interval time.Duration
+	Size     int
+}
+
+var bandwidthResolutions = []*bandwidthResolution{
+	{"1m", 1 * time.Minute, 60},
+	{"5m", 5 * time.Minute, 72},
+	{"1h", 1 * time.Hour, 48},
+}
+
+type BandwidthPoint struct {
+	Timestamp int64 `json:"timestamp"`
+	RxBytes   int64 `json:"rx_bytes"`
+	TxBytes   int64 `json:"tx_bytes"`
+}
+
+type BandwidthStats struct {
+	ProfileId string                       `json:"profile_id"`
+	RxBytes   int64                        `json:"rx_bytes"`
+	TxBytes   int64                        `json:"tx_bytes"`
+	RxRate    int64                        `json:"rx_rate"`
+	TxRate    int64                        `json:"tx_rate"`
+	History   map[string][]*BandwidthPoint `json:"history"`
+}
+
+type bandwidthRing struct {
+	res    *bandwidthResolution
+	points []*BandwidthPoint
+}
+
+func (r *bandwidthRing) add(timestamp time.Time, rx, tx int64) {
+	start := timestamp.Truncate(r.res.Interval).Unix()
+
+	if len(r.points) > 0 {
+		last := r.points[len(r.points)-1]
+		if last.Timestamp == start {
+			last.RxBytes += rx
+			last.TxBytes += tx
+			return
+		}
+	}
+
+	r.points = append(r.points, &BandwidthPoint{
+		Timestamp: start,
+		RxBytes:   rx,
+		TxBytes:   tx,
+	})
+	if len(r.points) > r.res.Size {
+		r.points = r.points[len(r.points)-r.res.Size:]
+	}
+}
+
+func (r *bandwidthRing) copy() (points []*BandwidthPoint) {
+	points = make([]*BandwidthPoint, len(r.points))
+	for i, point := range r.points {
+		points[i] = &BandwidthPoint{
+			Timestamp: point.Timestamp,
+			RxBytes:   point.RxBytes,
+			TxBytes:   point.TxBytes,
+		}
+	}
+	return
+}
+
+type bandwidth struct {
+	lastTime time.Time
+	lastRx   int64
+	lastTx   int64
+	rxRate   int64
+	txRate   int64
+	rings    []*bandwidthRing
+}
+
+func getBandwidth(prflId string) (bw *bandwidth) {
+	bw = bandwidths[prflId]
+	if bw == nil {
+		bw = &bandwidth{}
+		for _, res := range bandwidthResolutions {
+			bw.rings = append(bw.rings, &bandwidthRing{
+				res: res,
+			})
+		}
+		bandwidths[prflId] = bw
+	}
+	return
+}
+
+func (p *Profile) sampleBandwidth() (rxDelta, txDelta int64) {
+	if p.Mode == Wg {
+		_ = p.updateWgTransfer()
+	}
+	rx, tx := p.GetTransfer()
+	now := time.Now()
+
+	bandwidthsLock.Lock()
+	defer bandwidthsLock.Unlock()
+
+	bw := getBandwidth(p.Id)
+
+	// Counters restart from zero on a new connection
+	rxDelta = rx - bw.lastRx
+	txDelta = tx - bw.lastTx
+	if rxDelta < 0 || txDelta < 0 || bw.lastTime.IsZero() {
+		rxDelta = rx
+		txDelta = tx
+	}
+
+	if !bw.lastTime.IsZero() {
+		secs := int64(now.Sub(bw.lastTime).Seconds())
+		if secs > 0 {
+			bw.rxRate = rxDelta / secs
+			bw.txRate = txDelta / secs
+		}
+	}
+
+	bw.lastTime = now
+	bw.lastRx = rx
+	bw.lastTx = tx
+
+	for _, ring := range bw.rings {
+		ring.add(now, rxDelta, txDelta)
+	}
+
+	return
+}
+
+func (p *Profile) resetBandwidthRate() {
+	bandwidthsLock.Lock()
+	bw := bandwidths[p.Id]
+	if bw != nil {
+		bw.lastTime = time.Time{}
+		bw.lastRx = 0
+		bw.lastTx = 0
+		bw.rxRate = 0
+		bw.txRate = 0
+	}
+	bandwidthsLock.Unlock()
+}
+
+func (p *Profile) watchBandwidth() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.trackRoutine("bandwidth")()
+	defer p.resetBandwidthRate()
+
+	for {
+		time.Sleep(bandwidthInterval)
+
+		if p.stop {
+			return
+		}
+
+		if p.Status != "connected" {
+			continue
+		}
+
+		rxDelta, txDelta := p.sampleBandwidth()
+		p.checkTraffic(rxDelta, txDelta)
+	}
+}
+
+func (p *Profile) startBandwidth() {
+	if p.bandwidth {
+		return
+	}
+	p.bandwidth = true
+
+	go p.watchBandwidth()
+}
+
+// Bandwidth history is kept by profile ID and survives reconnects
+func GetBandwidthStats(prflId string) (stats *BandwidthStats) {
+	stats = &BandwidthStats{
+		ProfileId: prflId,
+		History:   map[string][]*BandwidthPoint{},
+	}
+
+	bandwidthsLock.Lock()
+	defer bandwidthsLock.Unlock()
+
+	bw := bandwidths[prflId]
+	if bw == nil {
+		for _, res := range bandwidthResolutions {
+			stats.History[res.Name] = []*BandwidthPoint{}
+		}
+		return
+	}
+
+	stats.RxBytes = bw.lastRx
+	stats.TxBytes = bw.lastTx
+	stats.RxRate = bw.rxRate
+	stats.TxRate = bw.txRate
+	for _, ring := range bw.rings {
+		stats.History[ring.res.Name] = ring.copy()
+	}
+
+	return
+}
+
+func GetBandwidthResolutions() (names []string) {
+	for _, res := range bandwidthResolutions {
+		names = append(names, res.Name)
+	}
+	return
+}
+
+func ClearBandwidthStats(prflId string) {
+	bandwidthsLock.Lock()
+	delete(bandwidths, prflId)
+	bandwidthsLock.Unlock()
+}
@@ -0,0 +1,238 @@
+package profile
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+
+	// Consecutive network failures before a remote is skipped
+	breakerFailures   = 3
+	breakerBackoff    = 30 * time.Second
+	breakerBackoffMax = 10 * time.Minute
+)
+
+var (
+	breakers     = map[string]map[string]*RemoteBreaker{}
+	breakersLock = sync.Mutex{}
+)
+
+// Failure state of a remote for a profile. Network failures open the
+// breaker, authentication failures are counted but do not open the breaker
+// as the credentials would fail on every remote.
+type RemoteBreaker struct {
+	Remote       string `json:"remote"`
+	State        string `json:"state"`
+	Failures     int    `json:"failures"`
+	AuthFailures int    `json:"auth_failures"`
+	Opens        int    `json:"opens"`
+	LastError    string `json:"last_error"`
+	Until        int64  `json:"until"`
+	Timestamp    int64  `json:"timestamp"`
+	until        time.Time
+}
+
+type RemoteBreakerEvent struct {
+	ProfileId string         `json:"profile_id"`
+	Name      string         `json:"name"`
+	Breaker   *RemoteBreaker `json:"breaker"`
+}
+
+func (b *RemoteBreaker) state() string {
+	if b.State == BreakerOpen && !time.Now().Before(b.until) {
+		return BreakerHalfOpen
+	}
+	return b.State
+}
+
+func breakerBackoffFor(opens int) (backoff time.Duration) {
+	backoff = breakerBackoff
+	for i := 1; i < opens && backoff < breakerBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > breakerBackoffMax {
+		backoff = breakerBackoffMax
+	}
+	return
+}
+
+func getBreaker(prflId, remote string) (brkr *RemoteBreaker) {
+	prflBreakers := breakers[prflId]
+	if prflBreakers == nil {
+		prflBreakers = map[string]*RemoteBreaker{}
+		breakers[prflId] = prflBreakers
+	}
+
+	brkr = prflBreakers[remote]
+	if brkr == nil {
+		brkr = &RemoteBreaker{
+			Remote: remote,
+			State:  BreakerClosed,
+		}
+		prflBreakers[remote] = brkr
+	}
+
+	return
+}
+
+func (p *Profile) breakerEvent(brkr *RemoteBreaker) {
+	evt := &event.Event{
+		Type:      "remote_breaker",
+		Workspace: p.Workspace,
+		Data: &RemoteBreakerEvent{
+			ProfileId: p.Id,
+			Name:      p.Name,
+			Breaker:   brkr,
+		},
+	}
+	evt.Init()
+}
+
+// Record a failed connection request, the breaker opens after consecutive
+// failures and a failed half open attempt reopens it with a longer backoff
+func (p *Profile) recordRemoteFailure(remote string, err error) {
+	if p.stop {
+		return
+	}
+
+	breakersLock.Lock()
+	brkr := getBreaker(p.Id, remote)
+	state := brkr.state()
+
+	brkr.Failures += 1
+	brkr.Timestamp = time.Now().Unix()
+	if err != nil {
+		brkr.LastError = err.Error()
+	}
+
+	opened := false
+	if state == BreakerHalfOpen ||
+		(state == BreakerClosed && brkr.Failures >= breakerFailures) {
+
+		brkr.Opens += 1
+		brkr.State = BreakerOpen
+		brkr.until = time.Now().Add(breakerBackoffFor(brkr.Opens))
+		brkr.Until = brkr.until.Unix()
+		opened = true
+	}
+
+	brkrCopy := *brkr
+	breakersLock.Unlock()
+
+	if opened {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"remote":     remote,
+			"failures":   brkrCopy.Failures,
+			"until":      brkrCopy.until.Format(time.RFC3339),
+		}).Warn("profile: Remote failing, skipping remote")
+
+		p.breakerEvent(&brkrCopy)
+	}
+}
+
+func (p *Profile) recordRemoteAuthFailure(remote string) {
+	if remote == "" {
+		return
+	}
+
+	breakersLock.Lock()
+	brkr := getBreaker(p.Id, remote)
+	brkr.AuthFailures += 1
+	brkr.Timestamp = time.Now().Unix()
+	breakersLock.Unlock()
+}
+
+func (p *Profile) recordRemoteSuccess(remote string) {
+	breakersLock.Lock()
+	brkr := getBreaker(p.Id, remote)
+	closed := brkr.State != BreakerClosed
+
+	brkr.State = BreakerClosed
+	brkr.Failures = 0
+	brkr.Opens = 0
+	brkr.LastError = ""
+	brkr.Until = 0
+	brkr.until = time.Time{}
+	brkr.Timestamp = time.Now().Unix()
+
+	brkrCopy := *brkr
+	breakersLock.Unlock()
+
+	if closed {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"remote":     remote,
+		}).Info("profile: Remote recovered")
+
+		p.breakerEvent(&brkrCopy)
+	}
+}
+
+// Remove remotes with an open breaker, when every remote is open only the
+// remote with the breaker closest to reopening is attempted
+func (p *Profile) filterRemotes(remotes []string) (filtered []string) {
+	filtered = []string{}
+	skipped := []*RemoteBreaker{}
+
+	breakersLock.Lock()
+	for _, remote := range remotes {
+		brkr := breakers[p.Id][remote]
+		if brkr == nil || brkr.state() != BreakerOpen {
+			filtered = append(filtered, remote)
+		} else {
+			brkrCopy := *brkr
+			skipped = append(skipped, &brkrCopy)
+		}
+	}
+	breakersLock.Unlock()
+
+	if len(filtered) > 0 || len(skipped) == 0 {
+		return
+	}
+
+	sort.SliceStable(skipped, func(i, j int) bool {
+		return skipped[i].until.Before(skipped[j].until)
+	})
+
+	filtered = append(filtered, skipped[0].Remote)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"remote":     skipped[0].Remote,
+	}).Warn("profile: All remotes failing, retrying next remote")
+
+	return
+}
+
+func GetRemoteBreakers(prflId string) (brkrs []*RemoteBreaker) {
+	brkrs = []*RemoteBreaker{}
+
+	breakersLock.Lock()
+	for _, brkr := range breakers[prflId] {
+		brkrCopy := *brkr
+		brkrCopy.State = brkr.state()
+		brkrs = append(brkrs, &brkrCopy)
+	}
+	breakersLock.Unlock()
+
+	sort.Slice(brkrs, func(i, j int) bool {
+		return brkrs[i].Remote < brkrs[j].Remote
+	})
+
+	return
+}
+
+func ClearRemoteBreakers(prflId string) {
+	breakersLock.Lock()
+	delete(breakers, prflId)
+	breakersLock.Unlock()
+}
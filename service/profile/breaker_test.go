@@ -0,0 +1,149 @@
+package profile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+func getTestBreaker(t *testing.T, prflId, remote string) *RemoteBreaker {
+	for _, brkr := range GetRemoteBreakers(prflId) {
+		if brkr.Remote == remote {
+			return brkr
+		}
+	}
+	t.Fatalf("breaker for %s not found", remote)
+	return nil
+}
+
+// Move the backoff of an open breaker into the past
+func expireBreaker(prflId, remote string) {
+	breakersLock.Lock()
+	brkr := breakers[prflId][remote]
+	brkr.until = time.Now().Add(-time.Second)
+	breakersLock.Unlock()
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	prfl := &Profile{
+		Id: "breaker-transitions",
+	}
+	defer ClearRemoteBreakers(prfl.Id)
+
+	remote := "vpn1.example.com"
+	connErr := errors.New("connection refused")
+
+	for i := 1; i < breakerFailures; i++ {
+		prfl.recordRemoteFailure(remote, connErr)
+		brkr := getTestBreaker(t, prfl.Id, remote)
+		if brkr.State != BreakerClosed {
+			t.Fatalf("breaker %s after %d failures", brkr.State, i)
+		}
+	}
+
+	prfl.recordRemoteFailure(remote, connErr)
+	brkr := getTestBreaker(t, prfl.Id, remote)
+	if brkr.State != BreakerOpen || brkr.Opens != 1 {
+		t.Fatalf("breaker %s with %d opens after %d failures",
+			brkr.State, brkr.Opens, breakerFailures)
+	}
+	if brkr.LastError != connErr.Error() {
+		t.Fatalf("last error %q", brkr.LastError)
+	}
+	firstUntil := brkr.until
+
+	expireBreaker(prfl.Id, remote)
+	brkr = getTestBreaker(t, prfl.Id, remote)
+	if brkr.State != BreakerHalfOpen {
+		t.Fatalf("breaker %s after backoff", brkr.State)
+	}
+
+	// A failed half open attempt reopens with a longer backoff
+	prfl.recordRemoteFailure(remote, connErr)
+	brkr = getTestBreaker(t, prfl.Id, remote)
+	if brkr.State != BreakerOpen || brkr.Opens != 2 {
+		t.Fatalf("breaker %s with %d opens after half open failure",
+			brkr.State, brkr.Opens)
+	}
+	if !brkr.until.After(firstUntil) {
+		t.Fatalf("backoff not increased after reopening")
+	}
+
+	prfl.recordRemoteSuccess(remote)
+	brkr = getTestBreaker(t, prfl.Id, remote)
+	if brkr.State != BreakerClosed || brkr.Failures != 0 ||
+		brkr.Opens != 0 || brkr.LastError != "" || brkr.Until != 0 {
+
+		t.Fatalf("breaker not reset after success: %+v", brkr)
+	}
+}
+
+func TestBreakerAuthFailure(t *testing.T) {
+	prfl := &Profile{
+		Id: "breaker-auth",
+	}
+	defer ClearRemoteBreakers(prfl.Id)
+
+	remote := "vpn1.example.com"
+	for i := 0; i < breakerFailures*2; i++ {
+		prfl.recordRemoteAuthFailure(remote)
+	}
+
+	brkr := getTestBreaker(t, prfl.Id, remote)
+	if brkr.State != BreakerClosed {
+		t.Fatalf("breaker %s after auth failures", brkr.State)
+	}
+	if brkr.AuthFailures != breakerFailures*2 {
+		t.Fatalf("expected %d auth failures got %d",
+			breakerFailures*2, brkr.AuthFailures)
+	}
+}
+
+func TestBreakerFilterRemotes(t *testing.T) {
+	prfl := &Profile{
+		Id: "breaker-filter",
+	}
+	defer ClearRemoteBreakers(prfl.Id)
+
+	remotes := []string{"vpn1.example.com", "vpn2.example.com"}
+
+	for i := 0; i < breakerFailures; i++ {
+		prfl.recordRemoteFailure(remotes[0], nil)
+	}
+
+	filtered := prfl.filterRemotes(remotes)
+	if len(filtered) != 1 || filtered[0] != remotes[1] {
+		t.Fatalf("expected only %s got %v", remotes[1], filtered)
+	}
+
+	for i := 0; i < breakerFailures; i++ {
+		prfl.recordRemoteFailure(remotes[1], nil)
+	}
+
+	// With every remote open the remote reopening first is attempted
+	filtered = prfl.filterRemotes(remotes)
+	if len(filtered) != 1 || filtered[0] != remotes[0] {
+		t.Fatalf("expected only %s got %v", remotes[0], filtered)
+	}
+}
+
+func TestBreakerBackoff(t *testing.T) {
+	tests := []struct {
+		opens   int
+		backoff time.Duration
+	}{
+		{1, breakerBackoff},
+		{2, breakerBackoff * 2},
+		{3, breakerBackoff * 4},
+		{20, breakerBackoffMax},
+	}
+
+	for _, test := range tests {
+		backoff := breakerBackoffFor(test.opens)
+		if backoff != test.backoff {
+			t.Errorf("opens %d: expected %s got %s",
+				test.opens, test.backoff, backoff)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package profile
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	clockCacheTtl  = 5 * time.Minute
+	clockSkewLimit = 2 * time.Minute
+)
+
+var clockErrorMsgs = []string{
+	"certificate is not yet valid",
+	"certificate has expired",
+	"CRL is not yet valid",
+	"CRL has expired",
+}
+
+type ClockSkew struct {
+	ProfileId string `json:"profile_id"`
+	Offset    int64  `json:"offset"`
+	Source    string `json:"source"`
+}
+
+func isClockError(line string) bool {
+	for _, msg := range clockErrorMsgs {
+		if strings.Contains(line, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profile) reportClockSkew() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	result := network.GetClock()
+	if result == nil || utils.SinceAbs(result.Timestamp) > clockCacheTtl {
+		var err error
+		result, err = network.CheckClock()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to check system clock")
+			return
+		}
+	}
+
+	if result.Offset < clockSkewLimit && result.Offset > -clockSkewLimit {
+		p.pushOutput("pritunl: Certificate validity error with " +
+			"correct system clock, check certificate expiration")
+		return
+	}
+
+	direction := "behind"
+	if result.Offset < 0 {
+		direction = "ahead"
+	}
+	minutes := int64(math.Round(math.Abs(result.Offset.Minutes())))
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"offset":     result.Offset.String(),
+		"source":     result.Source,
+	}).Error("profile: System clock is wrong, TLS certificates rejected")
+
+	p.pushOutput(fmt.Sprintf(
+		"pritunl: System clock is wrong by %d minutes (%s), "+
+			"correct the system time to connect",
+		minutes, direction,
+	))
+
+	evt := &event.Event{
+		Type:      "clock_skew",
+		Workspace: p.Workspace,
+		Data: &ClockSkew{
+			ProfileId: p.Id,
+			Offset:    int64(result.Offset.Seconds()),
+			Source:    result.Source,
+		},
+	}
+	evt.Init()
+}
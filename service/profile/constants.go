@@ -15,7 +15,8 @@ const (
 	wgConfTempl       = `[Interface]
 Address = {{.Address}}
 PrivateKey = {{.PrivateKey}}{{if .HasDns}}
-DNS = {{.DnsServers}}{{end}}
+DNS = {{.DnsServers}}{{end}}{{if .Mtu}}
+MTU = {{.Mtu}}{{end}}
 
 [Peer]
 PublicKey = {{.PublicKey}}
@@ -25,8 +26,16 @@ Endpoint = {{.Endpoint}}
 )
 
 var (
-	wgIfaceMacReg = regexp.MustCompile("\\((utun[0-9]+)\\)")
-	WgConfTempl   = template.Must(template.New("wg_conf").Parse(wgConfTempl))
+	wgIfaceMacReg      = regexp.MustCompile("\\((utun[0-9]+)\\)")
+	tunIfaceReg        = regexp.MustCompile("TUN/TAP device ([a-zA-Z0-9]+) opened")
+	dataCipherReg      = regexp.MustCompile("Data Channel: [Cc]ipher '([A-Z0-9-]+)'")
+	envKeyReg          = regexp.MustCompile("^[A-Z_][A-Z0-9_]{0,63}$")
+	WgConfTempl        = template.Must(template.New("wg_conf").Parse(wgConfTempl))
+	duplicateLoginMsgs = []string{
+		"duplicate login",
+		"connected from another location",
+		"logged in from another device",
+	}
 )
 
 type WgConfData struct {
@@ -37,4 +46,5 @@ type WgConfData struct {
 	PublicKey  string
 	AllowedIps string
 	Endpoint   string
+	Mtu        int
 }
@@ -0,0 +1,178 @@
+package profile
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/token"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (p *Profile) loadSharedCredentials() (err error) {
+	shared, err := credential.GetShared(p.CredentialId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id":    p.Id,
+			"credential_id": p.CredentialId,
+			"error":         err,
+		}).Error("profile: Failed to load shared credentials")
+		return
+	}
+
+	if shared.Workspace != p.Workspace {
+		err = &errortypes.NotFoundError{
+			errors.Newf("profile: Shared credential '%s' not in workspace",
+				p.CredentialId),
+		}
+		logrus.WithFields(logrus.Fields{
+			"profile_id":    p.Id,
+			"credential_id": p.CredentialId,
+			"workspace":     p.Workspace,
+		}).Error("profile: Shared credential from another workspace")
+		return
+	}
+
+	creds, err := shared.Credentials()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id":    p.Id,
+			"credential_id": p.CredentialId,
+			"error":         err,
+		}).Error("profile: Failed to generate shared credentials")
+		return
+	}
+
+	if creds.Username != "" {
+		p.Username = creds.Username
+	}
+	if creds.Password != "" || creds.Otp != "" {
+		p.Password = creds.Password + creds.Otp
+	}
+
+	return
+}
+
+// Auto-fill requires the server to request an OTP code, push and hardware
+// token modes are not supported
+func otpAutofillMode(passwordMode string) bool {
+	hasOtp := false
+
+	for _, typ := range strings.Split(passwordMode, "_") {
+		switch typ {
+		case "otp":
+			hasOtp = true
+			break
+		case "duo", "onelogin", "okta", "yubikey":
+			return false
+		}
+	}
+
+	return hasOtp
+}
+
+// A valid token authenticates the connection without the pin and OTP code
+func (p *Profile) hasValidToken() bool {
+	if p.ServerBoxPublicKey == "" {
+		return false
+	}
+
+	tokn := token.Get(p.Id, p.ServerPublicKey, p.ServerBoxPublicKey)
+	if tokn == nil || !tokn.Valid {
+		return false
+	}
+
+	return utils.SinceAbs(tokn.Timestamp) <= time.Duration(tokn.Ttl)*time.Second
+}
+
+// Password sent for the connection attempt, the OTP code is generated for
+// each attempt and never stored in the profile password
+func (p *Profile) authPassword() string {
+	return p.Password + p.otpCode
+}
+
+// The OTP code is appended to the password and pin provided by the client
+// matching the concatenation expected by the server
+func (p *Profile) loadOtpCredentials() (err error) {
+	sprfl := sprofile.Get(p.Id)
+	if sprfl == nil || !otpAutofillMode(sprfl.PasswordMode) {
+		return
+	}
+
+	if p.hasValidToken() {
+		return
+	}
+
+	seed, err := credential.GetOtpSeed(p.Id)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	code, err := credential.GenerateProfileTotp(p.Id, seed)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to generate otp code")
+		return
+	}
+
+	p.otpCode = code
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id":    p.Id,
+		"password_mode": sprfl.PasswordMode,
+	}).Info("profile: Auto-filled otp code")
+
+	return
+}
+
+func (p *Profile) loadCredentials() (err error) {
+	p.otpCode = ""
+
+	if p.CredentialId != "" {
+		err = p.loadSharedCredentials()
+		return
+	}
+
+	if p.CredentialProvider == "" {
+		err = p.loadOtpCredentials()
+		return
+	}
+
+	creds, err := credential.Get(
+		p.CredentialProvider,
+		p.CredentialCacheTtl,
+		&credential.Request{
+			ProfileId: p.Id,
+			OrgId:     p.OrgId,
+			UserId:    p.UserId,
+			ServerId:  p.ServerId,
+			Username:  p.Username,
+		},
+	)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"provider":   p.CredentialProvider,
+			"error":      err,
+		}).Error("profile: Failed to load provider credentials")
+		return
+	}
+
+	if creds.Username != "" {
+		p.Username = creds.Username
+	}
+	if creds.Password != "" || creds.Otp != "" {
+		p.Password = creds.Password + creds.Otp
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Received bytes in a sample that show payload traffic is passing,
+	// above the keepalive and handshake traffic of both tunnel modes
+	degradedRxBytes = 1024
+	// Keepalive and handshake traffic received while sending, received
+	// traffic above the baseline shows small responses are passing
+	degradedKeepaliveBytes = 256
+	degradedKeepaliveRate  = 4
+	// Sent bytes without a response before the tunnel is degraded, an
+	// idle tunnel is not degraded
+	degradedTxBytes = 8192
+)
+
+func GetDegradedTimeout() time.Duration {
+	timeout := config.Config.DegradedTimeout
+	if timeout <= 0 {
+		timeout = 60
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+func (p *Profile) resetTraffic() {
+	p.trafficStart = time.Time{}
+	p.trafficTx = 0
+	p.trafficRx = 0
+	p.Degraded = false
+}
+
+func keepaliveBaseline(elapsed time.Duration) int64 {
+	return degradedKeepaliveBytes +
+		int64(elapsed.Seconds())*degradedKeepaliveRate
+}
+
+func (p *Profile) restoreTraffic() {
+	p.trafficStart = time.Time{}
+	p.trafficTx = 0
+	p.trafficRx = 0
+
+	if p.Degraded {
+		p.Degraded = false
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: Tunnel traffic restored")
+
+		p.update()
+	}
+}
+
+// Check the transfer counters of a bandwidth sample, the tunnel is
+// degraded when traffic is sent without traffic above the keepalives
+// received for the timeout. Handshakes and keepalives continue on a tunnel with broken
+// server side routing and do not indicate the tunnel is working.
+func (p *Profile) checkTraffic(rxDelta, txDelta int64) {
+	if config.Config.DisableDegradedCheck || p.stop ||
+		p.Status != "connected" {
+
+		return
+	}
+
+	if rxDelta >= degradedRxBytes {
+		p.restoreTraffic()
+		return
+	}
+
+	if p.trafficTx == 0 {
+		if txDelta <= 0 {
+			return
+		}
+		p.trafficStart = time.Now()
+	}
+	p.trafficTx += txDelta
+	p.trafficRx += rxDelta
+
+	// Small responses are counted across the samples, received traffic
+	// above the keepalives since the first send is a working tunnel
+	if p.trafficRx > keepaliveBaseline(time.Since(p.trafficStart)) {
+		p.restoreTraffic()
+		return
+	}
+
+	if p.Degraded || p.trafficTx < degradedTxBytes ||
+		time.Since(p.trafficStart) < GetDegradedTimeout() {
+
+		return
+	}
+
+	p.Degraded = true
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"tx_bytes":   p.trafficTx,
+		"duration":   time.Since(p.trafficStart).String(),
+		"reconnect":  config.Config.DegradedReconnect,
+	}).Warn("profile: Tunnel connected without traffic passing")
+
+	p.update()
+
+	evt := &event.Event{
+		Type:      "profile_degraded",
+		Workspace: p.Workspace,
+		Data:      p,
+	}
+	evt.Init()
+
+	if config.Config.DegradedReconnect {
+		go p.Restart()
+	}
+}
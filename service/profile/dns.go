@@ -0,0 +1,228 @@
+package profile
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (p *Profile) tunnelIface() string {
+	if p.Tuniface != "" {
+		return p.Tuniface
+	}
+	return p.Iface
+}
+
+// Configured domains and the domains applied with the search domains
+// pushed by the server when enabled for the profile
+func (p *Profile) GetSplitDns() (domains, active []string) {
+	dnsLock.Lock()
+	domains = p.SplitDnsDomains
+	active = p.getSplitDnsDomains()
+	dnsLock.Unlock()
+
+	return
+}
+
+// Configured domains with the search domains pushed by the server when
+// enabled for the profile, dnsLock must be held
+func (p *Profile) getSplitDnsDomains() (domains []string) {
+	domains = []string{}
+	seen := map[string]bool{}
+
+	inputs := p.SplitDnsDomains
+	if p.SplitDnsPushed {
+		inputs = append(append([]string{}, inputs...), p.dnsDomains...)
+	}
+
+	for _, domain := range utils.FilterDomains(inputs) {
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+
+	return
+}
+
+func (p *Profile) applySplitDns() (err error) {
+	domains := p.getSplitDnsDomains()
+	if len(domains) == 0 || p.DisableDns {
+		return
+	}
+
+	err = netconf.Get().SetSplitDns(p.Id, p.tunnelIface(), p.dnsServers,
+		domains)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"domains":    domains,
+			"error":      err,
+		}).Error("profile: Failed to configure split DNS")
+		return
+	}
+
+	p.splitDnsActive = true
+
+	return
+}
+
+func (p *Profile) clearSplitDns() {
+	dnsLock.Lock()
+	p.removeSplitDns()
+	dnsLock.Unlock()
+}
+
+// Remove the applied split DNS configuration, dnsLock must be held
+func (p *Profile) removeSplitDns() {
+	if !p.splitDnsActive {
+		return
+	}
+	p.splitDnsActive = false
+
+	err := netconf.Get().ClearSplitDns(p.Id, p.tunnelIface())
+	if err != nil {
+		p.dnsDirty = true
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to clear split DNS")
+	}
+}
+
+// Replace the domains of a running profile, the split DNS fields are read
+// by the connect path with dnsLock held
+func (p *Profile) UpdateSplitDns(domains []string) {
+	domains = utils.FilterDomains(domains)
+
+	dnsLock.Lock()
+	defer dnsLock.Unlock()
+
+	p.removeSplitDns()
+	p.SplitDnsDomains = domains
+
+	if p.Status == "connected" {
+		_ = p.applySplitDns()
+	}
+}
+
+// Pushed values are passed to system commands, anything that is not an
+// address or hostname is dropped
+func (p *Profile) filterDnsServers(inputs []string) (servers []string) {
+	servers = []string{}
+
+	for _, input := range inputs {
+		ip := net.ParseIP(strings.TrimSpace(input))
+		if ip == nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"server":     input,
+			}).Warn("profile: Ignoring invalid pushed DNS server")
+			continue
+		}
+		servers = append(servers, ip.String())
+	}
+
+	return
+}
+
+func (p *Profile) filterDnsDomains(inputs []string) (domains []string) {
+	domains = []string{}
+
+	for _, input := range inputs {
+		domain := strings.ToLower(strings.TrimRight(
+			strings.TrimSpace(input), "."))
+		if !utils.ValidDomain(domain) {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"domain":     input,
+			}).Warn("profile: Ignoring invalid pushed DNS domain")
+			continue
+		}
+		domains = append(domains, domain)
+	}
+
+	return
+}
+
+func (p *Profile) parseDnsOptions(line string) {
+	servers := []string{}
+	domains := []string{}
+
+	for _, opt := range strings.Split(line, ",") {
+		opt = strings.TrimSpace(opt)
+		fields := strings.Fields(opt)
+		if len(fields) != 3 || fields[0] != "dhcp-option" {
+			continue
+		}
+
+		switch fields[1] {
+		case "DNS", "DNS6":
+			servers = append(servers, fields[2])
+			break
+		case "DOMAIN", "DOMAIN-SEARCH":
+			domains = append(domains, fields[2])
+			break
+		}
+	}
+
+	servers = p.filterDnsServers(servers)
+	domains = p.filterDnsDomains(domains)
+
+	if len(servers) > 0 {
+		p.dnsServers = servers
+		p.dnsServersPushed = servers
+		p.dnsBenchmarks = nil
+	}
+	if len(domains) > 0 {
+		p.dnsDomains = domains
+	}
+}
+
+type DnsSnapshot struct {
+	ProfileId     string          `json:"profile_id"`
+	Status        string          `json:"status"`
+	Servers       []string        `json:"servers"`
+	PushedServers []string        `json:"pushed_servers"`
+	Domains       []string        `json:"domains"`
+	SplitDomains  []string        `json:"split_domains"`
+	Benchmarks    []*DnsBenchmark `json:"benchmarks"`
+}
+
+// DNS configuration applied for the profile, the servers are in the
+// applied order after the benchmark
+func (p *Profile) GetDnsSnapshot() (snapshot *DnsSnapshot) {
+	snapshot = &DnsSnapshot{
+		ProfileId:     p.Id,
+		Status:        p.DnsStatus,
+		Servers:       p.dnsServers,
+		PushedServers: p.dnsServersPushed,
+		Domains:       p.dnsDomains,
+		SplitDomains:  []string{},
+		Benchmarks:    p.dnsBenchmarks,
+	}
+
+	dnsLock.Lock()
+	if p.splitDnsActive {
+		snapshot.SplitDomains = p.getSplitDnsDomains()
+	}
+	dnsLock.Unlock()
+	if snapshot.Servers == nil {
+		snapshot.Servers = []string{}
+	}
+	if snapshot.PushedServers == nil {
+		snapshot.PushedServers = []string{}
+	}
+	if snapshot.Domains == nil {
+		snapshot.Domains = []string{}
+	}
+	if snapshot.Benchmarks == nil {
+		snapshot.Benchmarks = []*DnsBenchmark{}
+	}
+
+	return
+}
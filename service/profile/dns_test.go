@@ -0,0 +1,193 @@
+package profile
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+)
+
+func setRecorder(t *testing.T) (rec *netconf.Recorder) {
+	rec = netconf.NewRecorder()
+	prev := netconf.Set(rec)
+	t.Cleanup(func() {
+		netconf.Set(prev)
+	})
+	return
+}
+
+func TestApplySplitDns(t *testing.T) {
+	rec := setRecorder(t)
+
+	prfl := &Profile{
+		Id:              "split-dns",
+		Iface:           "tap0",
+		Tuniface:        "tun0",
+		SplitDnsDomains: []string{"example.com", "Example.com"},
+		SplitDnsPushed:  true,
+		dnsServers:      []string{"10.0.0.1"},
+		dnsDomains:      []string{"corp.example.com"},
+	}
+
+	err := prfl.applySplitDns()
+	if err != nil {
+		t.Fatalf("applySplitDns: %s", err)
+	}
+	if !prfl.splitDnsActive {
+		t.Fatalf("split DNS not marked active")
+	}
+
+	prfl.clearSplitDns()
+	if prfl.splitDnsActive {
+		t.Fatalf("split DNS still active after clear")
+	}
+
+	expected := []*netconf.Call{
+		{
+			Method: "SetSplitDns",
+			Args: []interface{}{"split-dns", "tun0",
+				[]string{"10.0.0.1"},
+				prfl.getSplitDnsDomains()},
+		},
+		{
+			Method: "ClearSplitDns",
+			Args:   []interface{}{"split-dns", "tun0"},
+		},
+	}
+
+	calls := rec.GetCalls()
+	if !reflect.DeepEqual(calls, expected) {
+		for _, call := range calls {
+			t.Logf("call %s %v", call.Method, call.Args)
+		}
+		t.Fatalf("unexpected calls")
+	}
+}
+
+func TestApplySplitDnsDisabled(t *testing.T) {
+	rec := setRecorder(t)
+
+	prfl := &Profile{
+		Id:         "split-dns-disabled",
+		Iface:      "tun0",
+		DisableDns: true,
+		SplitDnsDomains: []string{
+			"example.com",
+		},
+	}
+
+	_ = prfl.applySplitDns()
+	prfl.clearSplitDns()
+
+	if len(rec.GetCalls()) != 0 {
+		t.Fatalf("configurer called with DNS disabled")
+	}
+}
+
+func TestApplySplitDnsError(t *testing.T) {
+	rec := setRecorder(t)
+	rec.Errors["SetSplitDns"] = errors.New("scutil failed")
+
+	prfl := &Profile{
+		Id:              "split-dns-error",
+		Iface:           "tun0",
+		SplitDnsDomains: []string{"example.com"},
+	}
+
+	err := prfl.applySplitDns()
+	if err == nil {
+		t.Fatalf("configurer error not returned")
+	}
+	if prfl.splitDnsActive {
+		t.Fatalf("split DNS marked active after failure")
+	}
+}
+
+func TestClearSplitDnsError(t *testing.T) {
+	rec := setRecorder(t)
+	rec.Errors["ClearSplitDns"] = errors.New("netsh failed")
+
+	prfl := &Profile{
+		Id:              "split-dns-clear",
+		Iface:           "tun0",
+		SplitDnsDomains: []string{"example.com"},
+	}
+
+	_ = prfl.applySplitDns()
+	prfl.clearSplitDns()
+
+	if !prfl.dnsDirty {
+		t.Fatalf("failed clear not marked dirty")
+	}
+}
+
+func TestRouteHelpers(t *testing.T) {
+	rec := setRecorder(t)
+
+	networks := parseNetworks([]string{"10.50.0.0/16"})
+	gateway := parseNetworks([]string{"192.168.1.1/32"})[0].IP
+
+	_ = addRoute(networks[0], "tun0")
+	_ = addGatewayRoute(networks[0], gateway, "eth0")
+	_ = deleteRoute(networks[0], "tun0")
+
+	methods := []string{}
+	for _, call := range rec.GetCalls() {
+		methods = append(methods, call.Method)
+	}
+
+	expected := []string{"AddRoute", "AddGatewayRoute", "DeleteRoute"}
+	if !reflect.DeepEqual(methods, expected) {
+		t.Fatalf("expected %v got %v", expected, methods)
+	}
+}
+
+func TestUpdateSplitDns(t *testing.T) {
+	rec := setRecorder(t)
+
+	prfl := &Profile{
+		Id:              "split-dns-update",
+		Iface:           "tun0",
+		Status:          "connected",
+		SplitDnsDomains: []string{"example.com"},
+	}
+
+	err := prfl.applyDns()
+	if err != nil {
+		t.Fatalf("applyDns: %s", err)
+	}
+
+	// Updates race with the connect path applying DNS
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 50; i++ {
+			_ = prfl.applyDns()
+		}
+		done <- true
+	}()
+	for i := 0; i < 50; i++ {
+		prfl.UpdateSplitDns([]string{"example.org"})
+	}
+	<-done
+
+	domains, active := prfl.GetSplitDns()
+	if !reflect.DeepEqual(domains, []string{"example.org"}) ||
+		!reflect.DeepEqual(active, []string{"example.org"}) {
+
+		t.Fatalf("unexpected domains %v active %v", domains, active)
+	}
+
+	rec.Reset()
+	prfl.UpdateSplitDns([]string{"example.net"})
+
+	methods := []string{}
+	for _, call := range rec.GetCalls() {
+		methods = append(methods, call.Method)
+	}
+
+	expected := []string{"ClearSplitDns", "SetSplitDns"}
+	if !reflect.DeepEqual(methods, expected) {
+		t.Fatalf("expected %v got %v", expected, methods)
+	}
+}
@@ -0,0 +1,193 @@
+package profile
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DnsStatusPending = "pending"
+	DnsStatusApplied = "applied"
+	DnsStatusTimeout = "timeout"
+	DnsStatusFailed  = "failed"
+
+	DnsStatusUnsupported = "unsupported"
+
+	dnsApplyTimeout = 10 * time.Second
+	dnsApplyRetries = 3
+)
+
+type DnsStatus struct {
+	ProfileId string `json:"profile_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+}
+
+func GetDnsTimeout() time.Duration {
+	if config.Config.DnsTimeout > 0 {
+		return time.Duration(config.Config.DnsTimeout) * time.Second
+	}
+	return dnsApplyTimeout
+}
+
+func (p *Profile) setDnsStatus(status string, err error) {
+	if p.DnsStatus == status {
+		return
+	}
+	p.DnsStatus = status
+
+	data := &DnsStatus{
+		ProfileId: p.Id,
+		Status:    status,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	evt := &event.Event{
+		Type:      "dns_status",
+		Workspace: p.Workspace,
+		Data:      data,
+	}
+	evt.Init()
+}
+
+// Run the DNS configuration outside of the connect path. Each attempt is
+// given the configured timeout, a timed out attempt is left to finish in
+// the background and its result is still reported.
+func (p *Profile) applyDnsAsync(handler func() error) {
+	p.setDnsStatus(DnsStatusPending, nil)
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.applyDnsRetry(handler)
+	}()
+}
+
+func (p *Profile) applyDnsRetry(handler func() error) {
+	defer p.trackRoutine("dns_apply")()
+
+	timeout := GetDnsTimeout()
+
+	for i := 0; i < dnsApplyRetries; i++ {
+		if p.stop {
+			return
+		}
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- handler()
+		}()
+
+		var err error
+		select {
+		case err = <-errChan:
+			break
+		case <-time.After(timeout):
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"timeout":    timeout.String(),
+			}).Warn("profile: DNS configuration timed out")
+
+			p.setDnsStatus(DnsStatusTimeout, nil)
+			err = <-errChan
+			break
+		}
+
+		if err == nil {
+			p.setDnsStatus(DnsStatusApplied, nil)
+			return
+		}
+
+		if _, ok := err.(*errortypes.PreconditionError); ok {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Warn("profile: DNS configuration unsupported")
+
+			p.setDnsStatus(DnsStatusUnsupported, err)
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"attempt":    i + 1,
+			"error":      err,
+		}).Warn("profile: Failed to apply DNS configuration")
+
+		if i+1 == dnsApplyRetries {
+			p.setDnsStatus(DnsStatusFailed, err)
+			return
+		}
+
+		time.Sleep(time.Duration(i+1) * time.Second)
+	}
+}
+
+func (p *Profile) applyDns() (err error) {
+	dnsLock.Lock()
+	defer dnsLock.Unlock()
+
+	if p.scutilDns {
+		err = utils.SetScutilDns(p.Id, p.dnsServers, p.dnsDomains)
+		if err != nil {
+			return
+		}
+	}
+
+	err = p.applySplitDns()
+	if err != nil {
+		return
+	}
+
+	if p.Mode != Wg {
+		netconf.Get().FlushDnsCache()
+	}
+
+	return
+}
+
+// DNS servers changed after the connection replace the servers applied by
+// OpenVPN or wg-quick through the platform configuration
+func (p *Profile) reapplyDns() (err error) {
+	p.clearSplitDns()
+
+	if !p.scutilDns {
+		dnsLock.Lock()
+		err = netconf.Get().SetDnsServers(p.Id, p.tunnelIface(),
+			p.dnsServers, p.dnsDomains)
+		dnsLock.Unlock()
+		if err != nil {
+			return
+		}
+		p.dnsServersSet = true
+	}
+
+	err = p.applyDns()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *Profile) dnsServersReplaceable() bool {
+	return p.scutilDns || netconf.Get().SupportsDnsServers()
+}
@@ -0,0 +1,177 @@
+package profile
+
+import (
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dnsBenchTimeout = 2 * time.Second
+	dnsBenchSamples = 3
+)
+
+type DnsBenchmark struct {
+	Server    string `json:"server"`
+	Healthy   bool   `json:"healthy"`
+	Latency   int64  `json:"latency"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Best response time of the samples, a server is unhealthy when none of
+// the queries were answered
+func measureDnsServer(server string) (bench *DnsBenchmark) {
+	bench = &DnsBenchmark{
+		Server: server,
+	}
+
+	var best time.Duration
+	for i := 0; i < dnsBenchSamples; i++ {
+		latency, err := network.MeasureDns(server, dnsBenchTimeout)
+		if err != nil {
+			continue
+		}
+
+		if !bench.Healthy || latency < best {
+			best = latency
+		}
+		bench.Healthy = true
+	}
+
+	bench.Latency = best.Milliseconds()
+	bench.Timestamp = time.Now().Unix()
+
+	return
+}
+
+// Healthy servers ordered by response time, unhealthy servers are dropped
+// unless no server responded
+func orderDnsServers(servers []string, benches []*DnsBenchmark) (
+	ordered []string) {
+
+	healthy := []*DnsBenchmark{}
+	for _, bench := range benches {
+		if bench.Healthy {
+			healthy = append(healthy, bench)
+		}
+	}
+
+	if len(healthy) == 0 {
+		ordered = servers
+		return
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return healthy[i].Latency < healthy[j].Latency
+	})
+
+	ordered = []string{}
+	for _, bench := range healthy {
+		ordered = append(ordered, bench.Server)
+	}
+
+	return
+}
+
+func (p *Profile) benchmarkDns() (changed bool) {
+	servers := p.dnsServersPushed
+	if len(servers) < 2 || p.DisableDns ||
+		config.Config.DisableDnsBenchmark {
+
+		return
+	}
+
+	benches := make([]*DnsBenchmark, len(servers))
+	waiter := sync.WaitGroup{}
+
+	for i, server := range servers {
+		waiter.Add(1)
+		go func(i int, server string) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+
+			defer waiter.Done()
+
+			benches[i] = measureDnsServer(server)
+		}(i, server)
+	}
+
+	waiter.Wait()
+
+	if p.stop {
+		return
+	}
+
+	p.dnsBenchmarks = benches
+	ordered := orderDnsServers(servers, benches)
+
+	if len(ordered) != len(p.dnsServers) {
+		changed = true
+	} else {
+		for i, server := range ordered {
+			if p.dnsServers[i] != server {
+				changed = true
+				break
+			}
+		}
+	}
+
+	if changed && !p.dnsServersReplaceable() {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"servers":    servers,
+		}).Info("profile: DNS server order cannot be changed on platform")
+
+		changed = false
+	}
+
+	if changed {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"pushed":     servers,
+			"servers":    ordered,
+		}).Info("profile: Reordered DNS servers by response time")
+
+		p.dnsServers = ordered
+	}
+
+	return
+}
+
+// Measure the pushed DNS servers after the tunnel is up and reapply the
+// DNS configuration if the order changed
+func (p *Profile) benchmarkDnsAsync() {
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer p.trackRoutine("dns_benchmark")()
+
+		if !p.benchmarkDns() || p.stop {
+			return
+		}
+
+		p.applyDnsAsync(p.reapplyDns)
+	}()
+}
@@ -0,0 +1,94 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/sirupsen/logrus"
+)
+
+type DnsLeak struct {
+	ProfileId string   `json:"profile_id"`
+	Name      string   `json:"name"`
+	Resolver  string   `json:"resolver"`
+	Expected  []string `json:"expected"`
+}
+
+// Interval of the periodic DNS check, zero when disabled
+func GetDnsCheckInterval() time.Duration {
+	if config.Config.DnsCheckInterval > 0 {
+		return time.Duration(config.Config.DnsCheckInterval) * time.Minute
+	}
+	return 0
+}
+
+// Split DNS only sends the profile domains to the tunnel servers, other
+// queries are expected to use the system resolvers
+func (p *Profile) dnsCheckable() bool {
+	return p.Status == "connected" && !p.DisableDns &&
+		!p.splitDnsActive && len(p.dnsServers) > 0
+}
+
+func (p *Profile) checkDns() (result *network.DnsCheck, err error) {
+	result, err = network.CheckDns(p.dnsServers)
+	if err != nil {
+		return
+	}
+
+	p.DnsCheck = result
+
+	if result.Passed {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"domain":     result.Domain,
+		"resolver":   result.Resolver,
+		"expected":   result.Expected,
+	}).Warn("profile: DNS queries resolved outside of tunnel")
+
+	evt := &event.Event{
+		Type:      "dns_leak",
+		Workspace: p.Workspace,
+		Data: &DnsLeak{
+			ProfileId: p.Id,
+			Name:      p.Name,
+			Resolver:  result.Resolver,
+			Expected:  result.Expected,
+		},
+	}
+	evt.Init()
+
+	return
+}
+
+// Verify DNS queries resolve through the tunnel servers of the connected
+// profiles, all profiles are checked when the id is empty
+func CheckDns(prflId string) (results map[string]*network.DnsCheck) {
+	results = map[string]*network.DnsCheck{}
+
+	for _, prfl := range GetProfiles() {
+		if prflId != "" && prfl.Id != prflId {
+			continue
+		}
+		if !prfl.dnsCheckable() {
+			continue
+		}
+
+		result, err := prfl.checkDns()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": prfl.Id,
+				"error":      err,
+			}).Warn("profile: Failed to check DNS resolution")
+			continue
+		}
+
+		results[prfl.Id] = result
+	}
+
+	return
+}
@@ -0,0 +1,139 @@
+package profile
+
+import (
+	"net"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dnsFallbackTimeout = 3 * time.Second
+	// Consecutive checks without a response from any tunnel DNS server
+	// before the fallback resolvers are added
+	dnsFallbackFailures = 2
+)
+
+type DnsFallback struct {
+	ProfileId string   `json:"profile_id"`
+	Name      string   `json:"name"`
+	Active    bool     `json:"active"`
+	Servers   []string `json:"servers"`
+}
+
+// Configured fallback resolvers, the fallback is disabled when empty
+func GetDnsFallbackServers() (servers []string) {
+	servers = []string{}
+
+	for _, server := range config.Config.DnsFallbackServers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			continue
+		}
+		servers = append(servers, ip.String())
+	}
+
+	return
+}
+
+func (p *Profile) resetDnsFallback() {
+	p.dnsFailures = 0
+	p.DnsFallback = false
+}
+
+// Fallback resolvers are only added when the servers of the tunnel can be
+// replaced on the platform
+func (p *Profile) dnsFallbackCheckable() bool {
+	return p.Status == "connected" && !p.DisableDns &&
+		len(p.dnsServersPushed) > 0 && p.dnsServersReplaceable()
+}
+
+func (p *Profile) tunnelDnsResponding() bool {
+	for _, server := range p.dnsServersPushed {
+		_, err := network.MeasureDns(server, dnsFallbackTimeout)
+		if err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the fallback resolvers while none of the tunnel DNS servers respond
+// and restore the tunnel servers once one responds again
+func (p *Profile) checkDnsFallback(fallback []string) {
+	if p.tunnelDnsResponding() {
+		p.dnsFailures = 0
+
+		if p.DnsFallback && !p.stop {
+			p.DnsFallback = false
+			p.dnsServers = p.dnsServersPushed
+
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"servers":    p.dnsServers,
+			}).Info("profile: Tunnel DNS restored, removing fallback resolvers")
+
+			p.applyDnsFallback(fallback)
+		}
+		return
+	}
+
+	p.dnsFailures += 1
+	if p.DnsFallback || p.dnsFailures < dnsFallbackFailures || p.stop {
+		return
+	}
+
+	p.DnsFallback = true
+	p.dnsServers = append(append([]string{}, fallback...),
+		p.dnsServersPushed...)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"pushed":     p.dnsServersPushed,
+		"fallback":   fallback,
+	}).Warn("profile: Tunnel DNS not responding, adding fallback resolvers")
+
+	p.applyDnsFallback(fallback)
+}
+
+func (p *Profile) applyDnsFallback(fallback []string) {
+	p.applyDnsAsync(p.reapplyDns)
+
+	p.update()
+
+	typ := "dns_fallback"
+	if !p.DnsFallback {
+		typ = "dns_fallback_cleared"
+	}
+
+	evt := &event.Event{
+		Type:      typ,
+		Workspace: p.Workspace,
+		Data: &DnsFallback{
+			ProfileId: p.Id,
+			Name:      p.Name,
+			Active:    p.DnsFallback,
+			Servers:   fallback,
+		},
+	}
+	evt.Init()
+}
+
+// Check the tunnel DNS servers of the connected profiles
+func CheckDnsFallback() {
+	fallback := GetDnsFallbackServers()
+	if len(fallback) == 0 {
+		return
+	}
+
+	for _, prfl := range GetProfiles() {
+		if !prfl.dnsFallbackCheckable() {
+			continue
+		}
+
+		prfl.checkDnsFallback(fallback)
+	}
+}
@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+func isDuplicateLogin(line string) bool {
+	line = strings.ToLower(line)
+
+	for _, msg := range duplicateLoginMsgs {
+		if strings.Contains(line, msg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Profile) duplicateLogin() {
+	if p.duplicate {
+		return
+	}
+	p.duplicate = true
+
+	stop := config.Config.StopDuplicateLogin
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"stop":       stop,
+	}).Warn("profile: Connection closed by duplicate login")
+
+	evt := &event.Event{
+		Type:      "duplicate_login",
+		Workspace: p.Workspace,
+		Data:      p,
+	}
+	evt.Init()
+
+	if !stop {
+		return
+	}
+
+	p.stop = true
+
+	if p.SystemProfile != nil {
+		p.SystemProfile.State = false
+		sprofile.Deactivate(p.SystemProfile.Id)
+	}
+
+	p.StopBackground()
+}
@@ -0,0 +1,91 @@
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	envPrefix      = "PRITUNL_"
+	envMaxValueLen = 4096
+)
+
+var envBlocked = map[string]bool{
+	"PATH":      true,
+	"IFS":       true,
+	"ENV":       true,
+	"BASH_ENV":  true,
+	"SHELL":     true,
+	"SHELLOPTS": true,
+	"HOME":      true,
+	"TMPDIR":    true,
+	"TEMP":      true,
+	"TMP":       true,
+	"COMSPEC":   true,
+	"PATHEXT":   true,
+}
+
+// Keys without the prefix are only accepted when listed in the allowlist
+// of the config file or policy, the allowlist is not set through the api
+func envKeyAllowed(key string) bool {
+	if !envKeyReg.MatchString(key) || envBlocked[key] ||
+		strings.HasPrefix(key, "LD_") || strings.HasPrefix(key, "DYLD_") {
+
+		return false
+	}
+
+	if strings.HasPrefix(key, envPrefix) {
+		return true
+	}
+
+	for _, allowed := range config.Config.EnvAllowlist {
+		if key == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func FilterEnv(env map[string]string) (filtered map[string]string) {
+	filtered = map[string]string{}
+
+	for key, val := range env {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !envKeyAllowed(key) {
+			logrus.WithFields(logrus.Fields{
+				"key": key,
+			}).Warn("profile: Ignoring disallowed environment variable")
+			continue
+		}
+
+		val = strings.Map(func(r rune) rune {
+			if r == 0 || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, val)
+		if len(val) > envMaxValueLen {
+			val = val[:envMaxValueLen]
+		}
+
+		filtered[key] = val
+	}
+
+	return
+}
+
+func (p *Profile) getEnv() (env []string) {
+	env = []string{}
+
+	for key, val := range FilterEnv(p.Env) {
+		env = append(env, fmt.Sprintf("%s=%s", key, val))
+	}
+	sort.Strings(env)
+
+	return
+}
@@ -0,0 +1,37 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+)
+
+func TestEnvKeyAllowed(t *testing.T) {
+	prevConfig := config.Config
+	config.Config = &config.ConfigData{
+		EnvAllowlist: []string{"HTTPS_PROXY", "LD_PRELOAD"},
+	}
+	defer func() {
+		config.Config = prevConfig
+	}()
+
+	tests := []struct {
+		key   string
+		allow bool
+	}{
+		{"PRITUNL_SITE", true},
+		{"HTTPS_PROXY", true},
+		{"OPENSSL_CONF", false},
+		{"NODE_OPTIONS", false},
+		{"PYTHONPATH", false},
+		{"LD_PRELOAD", false},
+		{"PATH", false},
+		{"pritunl_site", false},
+	}
+
+	for _, test := range tests {
+		if envKeyAllowed(test.key) != test.allow {
+			t.Errorf("%s: expected %t", test.key, test.allow)
+		}
+	}
+}
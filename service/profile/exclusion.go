@@ -0,0 +1,156 @@
+package profile
+
+import (
+	"net"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netclean"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+type installedRoute struct {
+	network *net.IPNet
+	iface   string
+}
+
+// Networks routed to the physical gateway, the net gateway routes pushed
+// by the server and the profile route exclusions. OpenVPN only supports
+// IPv4 exclusions in the profile configuration.
+func (p *Profile) setExclusions(pushed []*net.IPNet) {
+	exclusions := append([]*net.IPNet{}, pushed...)
+
+	for _, network := range parseNetworks(p.ExcludeRoutes) {
+		if p.Mode != Wg && network.IP.To4() == nil {
+			continue
+		}
+		exclusions = append(exclusions, network)
+	}
+
+	p.exclusions = exclusions
+}
+
+func (p *Profile) addExclusionRoute(network *net.IPNet, iface string) {
+	for _, route := range p.exclusionRoutes {
+		if route.iface == iface &&
+			route.network.String() == network.String() {
+
+			return
+		}
+	}
+
+	p.exclusionRoutes = append(p.exclusionRoutes, &installedRoute{
+		network: network,
+		iface:   iface,
+	})
+	netclean.TrackRoute(network, iface)
+}
+
+// Excluded networks must stay on the physical gateway, routes moved to
+// the tunnel or removed are reinstalled on the gateway interface
+func (p *Profile) checkExclusions(fixes map[string]int) {
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	for _, network := range p.exclusions {
+		key := "exclusion-" + network.String()
+		if fixes != nil && fixes[key] >= routeGuardMaxFixes {
+			continue
+		}
+
+		gateway, gatewayIface, err := netconf.Get().GetDefaultGateway(
+			network.IP.To4() == nil)
+		if err != nil || gatewayIface == iface {
+			continue
+		}
+
+		ifaces, err := netconf.Get().GetRouteIfaces(network)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    network.String(),
+				"error":      err,
+			}).Warn("profile: Failed to check excluded route")
+			continue
+		}
+
+		onTunnel := false
+		onGateway := false
+		for _, routeIface := range ifaces {
+			if routeIface == iface {
+				onTunnel = true
+			} else if routeIface == gatewayIface {
+				onGateway = true
+			}
+		}
+		if onGateway && !onTunnel {
+			continue
+		}
+
+		if p.stop || p.Status != "connected" {
+			return
+		}
+
+		if fixes != nil {
+			fixes[key] += 1
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id":     p.Id,
+			"network":        network.String(),
+			"gateway":        gateway.String(),
+			"iface":          gatewayIface,
+			"current_ifaces": ifaces,
+		}).Warn("profile: Excluded route not on gateway, reinstalling")
+
+		evt := &event.Event{
+			Type:      "route_changed",
+			Workspace: p.Workspace,
+			Data: &RouteChange{
+				ProfileId:     p.Id,
+				Network:       network.String(),
+				Iface:         gatewayIface,
+				CurrentIfaces: ifaces,
+			},
+		}
+		evt.Init()
+
+		if onTunnel {
+			_ = deleteRoute(network, iface)
+		}
+
+		err = addGatewayRoute(network, gateway, gatewayIface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    network.String(),
+				"error":      err,
+			}).Error("profile: Failed to reinstall excluded route")
+			continue
+		}
+
+		p.addExclusionRoute(network, gatewayIface)
+	}
+}
+
+// Remove the exclusion routes installed by the route guard, routes added
+// by OpenVPN are removed by OpenVPN
+func (p *Profile) clearExclusions() {
+	for _, route := range p.exclusionRoutes {
+		err := deleteRoute(route.network, route.iface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    route.network.String(),
+				"error":      err,
+			}).Error("profile: Failed to remove excluded route")
+			continue
+		}
+		netclean.ReleaseRoute(route.network, route.iface)
+	}
+
+	p.exclusions = nil
+	p.exclusionRoutes = nil
+}
@@ -0,0 +1,194 @@
+package profile
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// Warnings are sent once for each threshold before the expiry
+	expiryWarnings = []time.Duration{
+		24 * time.Hour,
+		1 * time.Hour,
+		10 * time.Minute,
+	}
+	expiryWarned = map[string]int{}
+	expiryLock   = sync.Mutex{}
+)
+
+type ProfileExpiry struct {
+	ProfileId string `json:"profile_id"`
+	Name      string `json:"name"`
+	ExpiresAt int64  `json:"expires_at"`
+	Remaining int64  `json:"remaining"`
+	Deleted   bool   `json:"deleted"`
+}
+
+type expiringProfile struct {
+	Id           string
+	Name         string
+	Workspace    string
+	ExpiresAt    int64
+	ExpireDelete bool
+	System       bool
+}
+
+func getExpiringProfiles() (prfls []*expiringProfile) {
+	prfls = []*expiringProfile{}
+	ids := map[string]bool{}
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to get system profiles for expiry")
+	}
+
+	for _, sprfl := range sprfls {
+		ids[sprfl.Id] = true
+		if sprfl.ExpiresAt == 0 {
+			continue
+		}
+
+		prfls = append(prfls, &expiringProfile{
+			Id:           sprfl.Id,
+			Name:         sprfl.Name,
+			Workspace:    sprfl.Workspace,
+			ExpiresAt:    sprfl.ExpiresAt,
+			ExpireDelete: sprfl.ExpireDelete,
+			System:       true,
+		})
+	}
+
+	for _, prfl := range GetProfiles() {
+		if ids[prfl.Id] || prfl.ExpiresAt == 0 {
+			continue
+		}
+
+		prfls = append(prfls, &expiringProfile{
+			Id:        prfl.Id,
+			Name:      prfl.Name,
+			Workspace: prfl.Workspace,
+			ExpiresAt: prfl.ExpiresAt,
+		})
+	}
+
+	return
+}
+
+func (e *expiringProfile) event(typ string, remaining int64,
+	deleted bool) {
+
+	evt := &event.Event{
+		Type:      typ,
+		Workspace: e.Workspace,
+		Data: &ProfileExpiry{
+			ProfileId: e.Id,
+			Name:      e.Name,
+			ExpiresAt: e.ExpiresAt,
+			Remaining: remaining,
+			Deleted:   deleted,
+		},
+	}
+	evt.Init()
+}
+
+func (e *expiringProfile) warn(remaining time.Duration) {
+	level := 0
+	for i, warning := range expiryWarnings {
+		if remaining <= warning {
+			level = i + 1
+		}
+	}
+
+	expiryLock.Lock()
+	warned := expiryWarned[e.Id]
+	if level > warned {
+		expiryWarned[e.Id] = level
+	}
+	expiryLock.Unlock()
+
+	if level == 0 || level <= warned {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": e.Id,
+		"expires_at": time.Unix(e.ExpiresAt, 0).Format(time.RFC3339),
+	}).Warn("profile: Profile access expiring")
+
+	e.event("profile_expiring", int64(remaining.Seconds()), false)
+}
+
+// Disconnect the expired profile and remove the profile when configured,
+// the expired event is sent once
+func (e *expiringProfile) expire() {
+	expiryLock.Lock()
+	warned := expiryWarned[e.Id]
+	expiryWarned[e.Id] = -1
+	expiryLock.Unlock()
+
+	prfl := GetProfile(e.Id)
+	if prfl != nil {
+		prfl.Stop()
+	}
+
+	if e.System {
+		sprofile.Deactivate(e.Id)
+	}
+
+	if warned == -1 {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": e.Id,
+		"delete":     e.ExpireDelete,
+	}).Warn("profile: Profile access expired")
+
+	deleted := false
+	if e.System && e.ExpireDelete {
+		ReleaseKillSwitch(e.Id)
+		sprofile.Remove(e.Id)
+		ClearBandwidthStats(e.Id)
+		ClearRemoteBreakers(e.Id)
+		logger.ClearProfileEntries(e.Id)
+		deleted = true
+
+		expiryLock.Lock()
+		delete(expiryWarned, e.Id)
+		expiryLock.Unlock()
+	}
+
+	e.event("profile_expired", 0, deleted)
+}
+
+func CheckExpiry() {
+	now := time.Now()
+	active := map[string]bool{}
+
+	for _, prfl := range getExpiringProfiles() {
+		active[prfl.Id] = true
+
+		remaining := time.Unix(prfl.ExpiresAt, 0).Sub(now)
+		if remaining <= 0 {
+			prfl.expire()
+		} else {
+			prfl.warn(remaining)
+		}
+	}
+
+	// Expiry extended or removed, warnings are sent again
+	expiryLock.Lock()
+	for prflId := range expiryWarned {
+		if !active[prflId] {
+			delete(expiryWarned, prflId)
+		}
+	}
+	expiryLock.Unlock()
+}
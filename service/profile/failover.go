@@ -0,0 +1,344 @@
+package profile
+
+import (
+	mathrand "math/rand"
+	"net"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	failoverInterval     = 30 * time.Second
+	failoverTimeout      = 3 * time.Second
+	failoverChecks       = 3
+	failoverSettle       = 2 * time.Minute
+	failoverMinDelay     = 10 * time.Minute
+	failoverLatencyDelta = 100 * time.Millisecond
+)
+
+type HostLatency struct {
+	Host      string `json:"host"`
+	Current   bool   `json:"current"`
+	Healthy   bool   `json:"healthy"`
+	Latency   int64  `json:"latency"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type HostFailover struct {
+	ProfileId string `json:"profile_id"`
+	Host      string `json:"host"`
+	Previous  string `json:"previous"`
+	Reason    string `json:"reason"`
+}
+
+func remoteHost(remote string) string {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return strings.Trim(remote, "[]")
+	}
+	return host
+}
+
+// Shuffle remotes placing the host selected by the last failover first,
+// remotes with an open breaker are skipped
+func (p *Profile) orderRemotes(remotes []string) (ordered []string) {
+	ordered = []string{}
+	for _, i := range mathrand.Perm(len(remotes)) {
+		ordered = append(ordered, remotes[i])
+	}
+
+	if p.preferredRemote != "" {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return remoteHost(ordered[i]) == p.preferredRemote &&
+				remoteHost(ordered[j]) != p.preferredRemote
+		})
+	}
+
+	ordered = p.filterRemotes(ordered)
+
+	return
+}
+
+func (p *Profile) getRemoteHosts() (hosts []string) {
+	hosts = []string{}
+	seen := map[string]bool{}
+
+	for _, line := range strings.Split(p.Data, "\n") {
+		if !strings.HasPrefix(line, "remote ") {
+			continue
+		}
+
+		lineSpl := strings.Fields(line)
+		if len(lineSpl) < 4 {
+			continue
+		}
+
+		host := lineSpl[1]
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return
+}
+
+func (p *Profile) isCurrentHost(host string) bool {
+	if p.ServerAddr == "" {
+		return false
+	}
+	if host == p.ServerAddr {
+		return true
+	}
+
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if addr == p.ServerAddr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Physical interface of the default route, nil when the default route is
+// the tunnel
+func (p *Profile) physicalIface(ipv6 bool) (ifc *net.Interface) {
+	_, iface, err := netconf.Get().GetDefaultGateway(ipv6)
+	if err != nil || iface == "" || iface == p.Iface {
+		return
+	}
+
+	ifc, err = net.InterfaceByName(iface)
+	if err != nil {
+		ifc = nil
+		return
+	}
+
+	return
+}
+
+// Latency is measured with a tcp connection to the host web server used to
+// request the connection, the connection is bound to the physical
+// interface to measure the path outside of the tunnel
+func (p *Profile) probeHost(host string) (latency time.Duration, err error) {
+	port := 443
+	if p.WebPort != 0 {
+		port = p.WebPort
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp",
+		net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return
+	}
+
+	ipv6 := addr.IP.To4() == nil
+	dialer := &net.Dialer{
+		Timeout: failoverTimeout,
+	}
+
+	ifc := p.physicalIface(ipv6)
+	if ifc != nil {
+		dialer.Control = failoverControl(ifc, ipv6)
+	}
+
+	start := time.Now()
+
+	conn, err := dialer.Dial("tcp", addr.String())
+	if err != nil {
+		return
+	}
+	latency = time.Since(start)
+	_ = conn.Close()
+
+	return
+}
+
+// Probe the server of the current connection
+func (p *Profile) ProbeServer() (latency time.Duration, err error) {
+	if p.ServerAddr == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Server address not available"),
+		}
+		return
+	}
+
+	latency, err = p.probeHost(p.ServerAddr)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Server unreachable"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *Profile) checkHosts(hosts []string) (latencies []*HostLatency) {
+	latencies = []*HostLatency{}
+
+	for _, host := range hosts {
+		latency, err := p.probeHost(host)
+
+		latencies = append(latencies, &HostLatency{
+			Host:      host,
+			Current:   p.isCurrentHost(host),
+			Healthy:   err == nil,
+			Latency:   latency.Milliseconds(),
+			Timestamp: time.Now().Unix(),
+		})
+	}
+
+	return
+}
+
+// Select a host when the current host has been unreachable or
+// significantly slower than a healthy alternate for consecutive checks
+func selectFailover(latencies []*HostLatency) (
+	host, previous, reason string) {
+
+	var current *HostLatency
+	var best *HostLatency
+
+	for _, latency := range latencies {
+		if latency.Current {
+			current = latency
+			continue
+		}
+		if !latency.Healthy {
+			continue
+		}
+		if best == nil || latency.Latency < best.Latency {
+			best = latency
+		}
+	}
+
+	if current == nil || best == nil {
+		return
+	}
+
+	if !current.Healthy {
+		host = best.Host
+		previous = current.Host
+		reason = "unreachable"
+	} else if best.Latency*2 < current.Latency &&
+		time.Duration(current.Latency-best.Latency)*
+			time.Millisecond >= failoverLatencyDelta {
+
+		host = best.Host
+		previous = current.Host
+		reason = "latency"
+	}
+
+	return
+}
+
+func (p *Profile) watchHosts() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.trackRoutine("host_failover")()
+
+	hosts := p.getRemoteHosts()
+	if len(hosts) < 2 {
+		return
+	}
+
+	candidate := ""
+	count := 0
+
+	for {
+		time.Sleep(failoverInterval)
+
+		if p.stop {
+			return
+		}
+
+		if p.Status != "connected" {
+			continue
+		}
+
+		latencies := p.checkHosts(hosts)
+		if p.stop {
+			return
+		}
+		p.HostLatencies = latencies
+
+		if utils.SinceAbs(time.Unix(p.Timestamp, 0)) < failoverSettle ||
+			utils.SinceAbs(p.failoverTime) < failoverMinDelay {
+
+			continue
+		}
+
+		host, previous, reason := selectFailover(latencies)
+		if host == "" || host != candidate {
+			candidate = host
+			count = 0
+			if host == "" {
+				continue
+			}
+		}
+
+		count += 1
+		if count < failoverChecks {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"host":       host,
+			"previous":   previous,
+			"reason":     reason,
+		}).Warn("profile: Failing over to alternate host")
+
+		evt := &event.Event{
+			Type:      "host_failover",
+			Workspace: p.Workspace,
+			Data: &HostFailover{
+				ProfileId: p.Id,
+				Host:      host,
+				Previous:  previous,
+				Reason:    reason,
+			},
+		}
+		evt.Init()
+
+		p.preferredRemote = host
+		p.failoverTime = time.Now()
+
+		go p.Restart()
+		return
+	}
+}
+
+func (p *Profile) startHostFailover() {
+	if p.hostFailover || !p.HostFailover {
+		return
+	}
+	p.hostFailover = true
+
+	go p.watchHosts()
+}
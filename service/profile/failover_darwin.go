@@ -0,0 +1,29 @@
+package profile
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func failoverControl(ifc *net.Interface, ipv6 bool) func(
+	network, address string, conn syscall.RawConn) error {
+
+	return func(network, address string, conn syscall.RawConn) (err error) {
+		e := conn.Control(func(fd uintptr) {
+			if ipv6 {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6,
+					unix.IPV6_BOUND_IF, ifc.Index)
+			} else {
+				err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP,
+					unix.IP_BOUND_IF, ifc.Index)
+			}
+		})
+		if e != nil {
+			err = e
+		}
+
+		return
+	}
+}
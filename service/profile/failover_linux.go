@@ -0,0 +1,31 @@
+package profile
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Bind the probe socket to the physical interface, the wg-quick fwmark is
+// also set to bypass the tunnel routing policy
+func failoverControl(ifc *net.Interface, ipv6 bool) func(
+	network, address string, conn syscall.RawConn) error {
+
+	return func(network, address string, conn syscall.RawConn) (err error) {
+		e := conn.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET,
+				unix.SO_MARK, wgTcpFwmark)
+			if err != nil {
+				return
+			}
+
+			err = unix.BindToDevice(int(fd), ifc.Name)
+		})
+		if e != nil {
+			err = e
+		}
+
+		return
+	}
+}
@@ -0,0 +1,37 @@
+package profile
+
+import (
+	"math/bits"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ipUnicastIf   = 31
+	ipv6UnicastIf = 31
+)
+
+// The IPv4 interface index is set in network byte order
+func failoverControl(ifc *net.Interface, ipv6 bool) func(
+	network, address string, conn syscall.RawConn) error {
+
+	return func(network, address string, conn syscall.RawConn) (err error) {
+		e := conn.Control(func(fd uintptr) {
+			if ipv6 {
+				err = windows.SetsockoptInt(windows.Handle(fd),
+					windows.IPPROTO_IPV6, ipv6UnicastIf, ifc.Index)
+			} else {
+				err = windows.SetsockoptInt(windows.Handle(fd),
+					windows.IPPROTO_IP, ipUnicastIf,
+					int(bits.ReverseBytes32(uint32(ifc.Index))))
+			}
+		})
+		if e != nil {
+			err = e
+		}
+
+		return
+	}
+}
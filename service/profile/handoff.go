@@ -0,0 +1,557 @@
+package profile
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/token"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Connected profiles are handed to a new service image on upgrade. The
+// openvpn processes and wg interfaces are left running and the new image
+// adopts them from the serialized state.
+type Handoff struct {
+	Profiles   []*HandoffProfile    `json:"profiles"`
+	Ipv6States []*netconf.Ipv6State `json:"ipv6_states"`
+	DnsForced  bool                 `json:"dns_forced"`
+}
+
+type HandoffToken struct {
+	Token              string    `json:"token"`
+	ServerPublicKey    string    `json:"server_public_key"`
+	ServerBoxPublicKey string    `json:"server_box_public_key"`
+	Timestamp          time.Time `json:"timestamp"`
+	Ttl                int       `json:"ttl"`
+	Valid              bool      `json:"valid"`
+}
+
+// Exported profile fields are handed off by field name, the json tags of
+// the profile hide most settings from the client
+type HandoffProfile struct {
+	Fields            map[string]json.RawMessage `json:"fields"`
+	SystemProfileId   string                     `json:"system_profile_id"`
+	Pid               int                        `json:"pid"`
+	StdoutFd          int                        `json:"stdout_fd"`
+	StderrFd          int                        `json:"stderr_fd"`
+	StartTime         time.Time                  `json:"start_time"`
+	RemPaths          []string                   `json:"rem_paths"`
+	WgConfPth         string                     `json:"wg_conf_path"`
+	WgHandshake       int                        `json:"wg_handshake"`
+	WgServerPublicKey string                     `json:"wg_server_public_key"`
+	WgTcp             bool                       `json:"wg_tcp"`
+	WgUtun            bool                       `json:"wg_utun"`
+	Tap               string                     `json:"tap"`
+	ManagementPass    string                     `json:"management_pass"`
+	ManagementPort    int                        `json:"management_port"`
+	DnsServers        []string                   `json:"dns_servers"`
+	DnsServersPushed  []string                   `json:"dns_servers_pushed"`
+	DnsBenchmarks     []*DnsBenchmark            `json:"dns_benchmarks"`
+	DnsDomains        []string                   `json:"dns_domains"`
+	Networks          []string                   `json:"networks"`
+	Exclusions        []string                   `json:"exclusions"`
+	SplitDnsActive    bool                       `json:"split_dns_active"`
+	ScutilDns         bool                       `json:"scutil_dns"`
+	DnsServersSet     bool                       `json:"dns_servers_set"`
+	StatusPath        string                     `json:"status_path"`
+	PreferredRemote   string                     `json:"preferred_remote"`
+	FailoverTime      time.Time                  `json:"failover_time"`
+	Ipv6Disabled      bool                       `json:"ipv6_disabled"`
+	Token             *HandoffToken              `json:"token"`
+}
+
+// Descriptor of an open file, Fd is not used as it switches the file to
+// blocking mode
+func fileFd(file *os.File) (fd int) {
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	_ = rawConn.Control(func(f uintptr) {
+		fd = int(f)
+	})
+
+	return
+}
+
+// Runtime state that is recreated by the new image
+var handoffSkip = map[string]bool{
+	"SystemProfile":  true,
+	"RemoteBreakers": true,
+	"HostLatencies":  true,
+}
+
+func (p *Profile) handoffFields() (fields map[string]json.RawMessage) {
+	fields = map[string]json.RawMessage{}
+
+	val := reflect.ValueOf(p).Elem()
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || handoffSkip[field.Name] {
+			continue
+		}
+
+		data, err := json.Marshal(val.Field(i).Interface())
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"field":      field.Name,
+				"error":      err,
+			}).Error("profile: Failed to serialize handoff field")
+			continue
+		}
+		fields[field.Name] = data
+	}
+
+	return
+}
+
+func (p *Profile) adoptFields(fields map[string]json.RawMessage) {
+	val := reflect.ValueOf(p).Elem()
+
+	for name, data := range fields {
+		if handoffSkip[name] {
+			continue
+		}
+
+		field := val.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+
+		err := json.Unmarshal(data, field.Addr().Interface())
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"field":      name,
+				"error":      err,
+			}).Error("profile: Failed to parse handoff field")
+		}
+	}
+}
+
+func (p *Profile) handoff() (hp *HandoffProfile) {
+	hp = &HandoffProfile{
+		Fields:            p.handoffFields(),
+		StartTime:         p.startTime,
+		RemPaths:          p.remPaths,
+		WgConfPth:         p.wgConfPth,
+		WgHandshake:       p.wgHandshake,
+		WgServerPublicKey: p.wgServerPublicKey,
+		WgTcp:             p.wgTcp,
+		Tap:               p.tap,
+		ManagementPass:    p.managementPass,
+		ManagementPort:    p.managementPort,
+		DnsServers:        p.dnsServers,
+		DnsServersPushed:  p.dnsServersPushed,
+		DnsBenchmarks:     p.dnsBenchmarks,
+		DnsDomains:        p.dnsDomains,
+		Networks:          []string{},
+		SplitDnsActive:    p.splitDnsActive,
+		ScutilDns:         p.scutilDns,
+		DnsServersSet:     p.dnsServersSet,
+		StatusPath:        p.statusPath,
+		PreferredRemote:   p.preferredRemote,
+		FailoverTime:      p.failoverTime,
+		Ipv6Disabled:      ipv6Profiles[p.Id],
+	}
+
+	if p.SystemProfile != nil {
+		hp.SystemProfileId = p.SystemProfile.Id
+	}
+
+	if p.cmd != nil && p.cmd.Process != nil {
+		hp.Pid = p.cmd.Process.Pid
+	}
+
+	if p.wgUtun != nil {
+		hp.WgUtun = true
+		hp.Pid = p.wgUtun.proc.Pid
+	}
+
+	if p.stdout != nil && p.stderr != nil {
+		hp.StdoutFd = fileFd(p.stdout)
+		hp.StderrFd = fileFd(p.stderr)
+	}
+
+	for _, network := range p.networks {
+		hp.Networks = append(hp.Networks, network.String())
+	}
+
+	for _, network := range p.exclusions {
+		hp.Exclusions = append(hp.Exclusions, network.String())
+	}
+
+	tokn := p.token
+	if tokn != nil {
+		hp.Token = &HandoffToken{
+			Token:              tokn.Token,
+			ServerPublicKey:    tokn.ServerPublicKey,
+			ServerBoxPublicKey: tokn.ServerBoxPublicKey,
+			Timestamp:          tokn.Timestamp,
+			Ttl:                tokn.Ttl,
+			Valid:              tokn.Valid,
+		}
+	}
+
+	return
+}
+
+func (hp *HandoffProfile) adopt() (p *Profile) {
+	p = &Profile{
+		startTime:         hp.StartTime,
+		remPaths:          hp.RemPaths,
+		wgConfPth:         hp.WgConfPth,
+		wgHandshake:       hp.WgHandshake,
+		wgServerPublicKey: hp.WgServerPublicKey,
+		wgTcp:             hp.WgTcp,
+		tap:               hp.Tap,
+		managementPass:    hp.ManagementPass,
+		managementPort:    hp.ManagementPort,
+		dnsServers:        hp.DnsServers,
+		dnsServersPushed:  hp.DnsServersPushed,
+		dnsBenchmarks:     hp.DnsBenchmarks,
+		dnsDomains:        hp.DnsDomains,
+		splitDnsActive:    hp.SplitDnsActive,
+		scutilDns:         hp.ScutilDns,
+		dnsServersSet:     hp.DnsServersSet,
+		statusPath:        hp.StatusPath,
+		preferredRemote:   hp.PreferredRemote,
+		failoverTime:      hp.FailoverTime,
+		connected:         true,
+		state:             true,
+	}
+	p.adoptFields(hp.Fields)
+	p.Init()
+
+	if hp.SystemProfileId != "" {
+		p.SystemProfile = sprofile.Get(hp.SystemProfileId)
+	}
+
+	for _, network := range hp.Networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err == nil {
+			p.networks = append(p.networks, ipNet)
+		}
+	}
+
+	for _, network := range hp.Exclusions {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err == nil {
+			p.exclusions = append(p.exclusions, ipNet)
+		}
+	}
+
+	if hp.Token != nil {
+		tokn := &token.Token{
+			Profile:            p.Id,
+			ServerPublicKey:    hp.Token.ServerPublicKey,
+			ServerBoxPublicKey: hp.Token.ServerBoxPublicKey,
+			Token:              hp.Token.Token,
+			Timestamp:          hp.Token.Timestamp,
+			Ttl:                hp.Token.Ttl,
+			Valid:              hp.Token.Valid,
+		}
+		token.Set(tokn)
+		p.token = tokn
+	}
+
+	return
+}
+
+// Lock profile state and serialize connected profiles, the locks are held
+// until the process image is replaced or ResumeHandoff is called
+func StartHandoff() (hndoff *Handoff, err error) {
+	if runtime.GOOS == "windows" {
+		err = &errortypes.ExecError{
+			errors.New("profile: Handoff not supported on windows"),
+		}
+		return
+	}
+
+	stateLock.Lock()
+	Profiles.Lock()
+	ipv6Lock.Lock()
+
+	defer func() {
+		if err != nil {
+			ResumeHandoff()
+		}
+	}()
+
+	hndoff = &Handoff{
+		Profiles:   []*HandoffProfile{},
+		Ipv6States: ipv6States,
+		DnsForced:  DnsForced,
+	}
+
+	for _, prfl := range Profiles.m {
+		if prfl.stop || prfl.stopping || prfl.Status != "connected" {
+			err = &errortypes.PreconditionError{
+				errors.Newf(
+					"profile: Profile '%s' is %s, retry once connected",
+					prfl.Id, prfl.Status),
+			}
+			return
+		}
+
+		// The relay connection is closed when the service exits
+		if prfl.proxyRelay != nil {
+			err = &errortypes.PreconditionError{
+				errors.Newf(
+					"profile: Profile '%s' uses the proxy relay",
+					prfl.Id),
+			}
+			return
+		}
+
+		hp := prfl.handoff()
+		if prfl.Mode != Wg && (hp.Pid == 0 || hp.ManagementPort == 0) {
+			err = &errortypes.PreconditionError{
+				errors.Newf(
+					"profile: Profile '%s' has no openvpn process",
+					prfl.Id),
+			}
+			return
+		}
+
+		// Openvpn is killed by a broken pipe if the output is not read
+		if prfl.Mode != Wg && (hp.StdoutFd == 0 || hp.StderrFd == 0) {
+			err = &errortypes.PreconditionError{
+				errors.Newf(
+					"profile: Profile '%s' has no openvpn output",
+					prfl.Id),
+			}
+			return
+		}
+
+		hndoff.Profiles = append(hndoff.Profiles, hp)
+	}
+
+	return
+}
+
+// Output pipes of the openvpn processes, the descriptors must be inherited
+// by the new image
+func (h *Handoff) OutputFds() (fds []int) {
+	fds = []int{}
+
+	for _, hp := range h.Profiles {
+		if hp.StdoutFd != 0 {
+			fds = append(fds, hp.StdoutFd)
+		}
+		if hp.StderrFd != 0 {
+			fds = append(fds, hp.StderrFd)
+		}
+	}
+
+	return
+}
+
+func ResumeHandoff() {
+	ipv6Lock.Unlock()
+	Profiles.Unlock()
+	stateLock.Unlock()
+}
+
+// Check that all profiles can be handed off without holding the locks
+func CheckHandoff() (err error) {
+	_, err = StartHandoff()
+	if err != nil {
+		return
+	}
+	ResumeHandoff()
+
+	return
+}
+
+// Adopt the profiles handed off by the previous service image, must run
+// before system profiles are synced
+func Adopt(hndoff *Handoff) {
+	if hndoff == nil {
+		return
+	}
+
+	DnsForced = hndoff.DnsForced
+
+	ipv6Lock.Lock()
+	ipv6States = hndoff.Ipv6States
+	ipv6Lock.Unlock()
+
+	for _, hp := range hndoff.Profiles {
+		prfl := hp.adopt()
+
+		if hp.Ipv6Disabled {
+			ipv6Lock.Lock()
+			ipv6Profiles[prfl.Id] = true
+			ipv6Lock.Unlock()
+		}
+
+		if prfl.managementPort != 0 {
+			ManagementPortReserve(prfl.managementPort)
+		}
+
+		Profiles.Lock()
+		Profiles.m[prfl.Id] = prfl
+		Profiles.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prfl.Id,
+			"mode":       prfl.Mode,
+			"pid":        hp.Pid,
+			"iface":      prfl.Iface,
+			"tun_iface":  prfl.Tuniface,
+		}).Info("profile: Adopted connection from previous service")
+
+		if prfl.Mode == Wg {
+			prfl.adoptWg(hp.Pid, hp.WgUtun)
+		} else {
+			prfl.adoptOvpn(hp.Pid, hp.StdoutFd, hp.StderrFd)
+		}
+	}
+}
+
+func (p *Profile) adoptWg(pid int, utun bool) {
+	if utun {
+		err := p.adoptWgUtun(pid)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"pid":        pid,
+				"error":      err,
+			}).Error("profile: Failed to adopt wireguard-go process")
+
+			go p.Restart()
+			return
+		}
+	}
+
+	if p.wgTcp {
+		// The tcp relay ran in the previous process and is not recoverable
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Warn("profile: WireGuard tcp relay lost on upgrade, reconnecting")
+
+		go p.Restart()
+		return
+	}
+
+	p.startRouteGuard()
+	p.startHostFailover()
+	p.startBandwidth()
+
+	go p.watchWgAdopted()
+}
+
+func (p *Profile) watchWgAdopted() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.stopSafe()
+	defer p.trackRoutine("wg_watch")()
+
+	p.keepaliveWg()
+}
+
+func (p *Profile) adoptOvpn(pid, stdoutFd, stderrFd int) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "profile: Failed to find openvpn process"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"pid":        pid,
+			"error":      err,
+		}).Error("profile: Failed to adopt openvpn process")
+
+		go p.Restart()
+		return
+	}
+	p.cmd = &exec.Cmd{
+		Process: proc,
+	}
+
+	// Output pipes inherited from the previous image
+	var stdout, stderr *os.File
+	var outputWait *sync.WaitGroup
+	if stdoutFd != 0 && stderrFd != 0 {
+		stdout = os.NewFile(uintptr(stdoutFd), "stdout")
+		stderr = os.NewFile(uintptr(stderrFd), "stderr")
+		p.stdout = stdout
+		p.stderr = stderr
+		outputWait = p.readOutput(stdout, stderr)
+	}
+
+	p.startRouteGuard()
+	p.startHostFailover()
+	p.startBandwidth()
+
+	go p.watchManagement()
+
+	waitDone := p.trackRoutine("ovpn_wait")
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer waitDone()
+
+		// The process is still a child of this pid after the exec
+		_, _ = proc.Wait()
+		if outputWait != nil {
+			_ = stdout.Close()
+			_ = stderr.Close()
+			outputWait.Wait()
+		}
+
+		if runtime.GOOS == "darwin" {
+			err := utils.RestoreScutilDns(false)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("profile: Failed to restore DNS")
+			}
+		}
+
+		if !p.stop {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+			}).Info("profile: Profile exit, reconnecting")
+
+			p.Restart()
+		} else {
+			p.StopBackground()
+		}
+	}()
+}
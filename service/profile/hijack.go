@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"net"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DnsHijackWarn   = sprofile.DnsHijackWarn
+	DnsHijackTunnel = sprofile.DnsHijackTunnel
+	DnsHijackIgnore = sprofile.DnsHijackIgnore
+)
+
+type DnsHijack struct {
+	ProfileId string `json:"profile_id"`
+	Mode      string `json:"mode"`
+	Responder string `json:"responder"`
+}
+
+func (p *Profile) dnsHijackMode() string {
+	switch p.DnsHijackMode {
+	case DnsHijackTunnel, DnsHijackIgnore:
+		return p.DnsHijackMode
+	default:
+		return DnsHijackWarn
+	}
+}
+
+func (p *Profile) probeDnsHijack() {
+	if p.DisableDns || p.dnsHijackMode() == DnsHijackIgnore {
+		return
+	}
+
+	network.ProbeDnsHijack()
+}
+
+func (p *Profile) hasDnsRoute(network *net.IPNet, iface string) bool {
+	for _, route := range p.dnsRoutes {
+		if route.iface == iface &&
+			route.network.String() == network.String() {
+
+			return true
+		}
+	}
+	return false
+}
+
+// Route the tunnel DNS servers through the tunnel interface so queries
+// cannot be intercepted on the physical network.
+func (p *Profile) routeDnsServers() (err error) {
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	for _, server := range p.dnsServers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			continue
+		}
+
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+
+		network := &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}
+
+		err = addRoute(network, iface)
+		if err != nil {
+			return
+		}
+
+		if p.hasDnsRoute(network, iface) {
+			continue
+		}
+		p.dnsRoutes = append(p.dnsRoutes, &installedRoute{
+			network: network,
+			iface:   iface,
+		})
+	}
+
+	return
+}
+
+// Remove the DNS server routes, adapters reused across connections keep
+// the routes after the tunnel is stopped
+func (p *Profile) clearDnsRoutes() {
+	for _, route := range p.dnsRoutes {
+		err := deleteRoute(route.network, route.iface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    route.network.String(),
+				"error":      err,
+			}).Error("profile: Failed to remove DNS server route")
+		}
+	}
+
+	p.dnsRoutes = nil
+}
+
+func (p *Profile) handleDnsHijack() {
+	mode := p.dnsHijackMode()
+	if p.DisableDns || mode == DnsHijackIgnore {
+		return
+	}
+
+	result := network.GetDnsHijack()
+	if result == nil || !result.Hijacked {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"mode":       mode,
+		"responder":  result.Responder,
+	}).Warn("profile: Network intercepts DNS traffic")
+
+	evt := &event.Event{
+		Type:      "dns_hijack",
+		Workspace: p.Workspace,
+		Data: &DnsHijack{
+			ProfileId: p.Id,
+			Mode:      mode,
+			Responder: result.Responder,
+		},
+	}
+	evt.Init()
+
+	switch mode {
+	case DnsHijackTunnel:
+		err := p.routeDnsServers()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to route DNS servers through tunnel")
+			p.pushOutput("pritunl: Network intercepts DNS traffic, " +
+				"failed to route DNS servers through tunnel")
+			break
+		}
+		p.pushOutput("pritunl: Network intercepts DNS traffic, " +
+			"routing DNS servers through tunnel")
+		break
+	default:
+		p.pushOutput("pritunl: Network intercepts DNS traffic, " +
+			"DNS resolution may be unreliable")
+	}
+}
@@ -0,0 +1,213 @@
+package profile
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	HookConnect    = "connect"
+	HookUp         = "up"
+	HookDisconnect = "disconnect"
+	HookReconnect  = "reconnect"
+
+	hookTimeout = 60 * time.Second
+)
+
+var hookEvents = map[string]bool{
+	HookConnect:    true,
+	HookUp:         true,
+	HookDisconnect: true,
+	HookReconnect:  true,
+}
+
+// Hook scripts are keyed by event and must be absolute paths
+func FilterHooks(hooks map[string]string) (filtered map[string]string) {
+	filtered = map[string]string{}
+
+	for evt, pth := range hooks {
+		evt = strings.ToLower(strings.TrimSpace(evt))
+		pth = strings.TrimSpace(pth)
+		if pth == "" {
+			continue
+		}
+
+		if !hookEvents[evt] || !filepath.IsAbs(pth) {
+			logrus.WithFields(logrus.Fields{
+				"event": evt,
+				"path":  pth,
+			}).Warn("profile: Ignoring invalid hook")
+			continue
+		}
+
+		filtered[evt] = filepath.Clean(pth)
+	}
+
+	return
+}
+
+// Global hook runs before the profile hook
+func (p *Profile) hookPaths(evt string) (paths []string) {
+	paths = []string{}
+
+	pth := FilterHooks(config.Config.Hooks)[evt]
+	if pth != "" {
+		paths = append(paths, pth)
+	}
+
+	pth = FilterHooks(p.Hooks)[evt]
+	if pth != "" {
+		paths = append(paths, pth)
+	}
+
+	return
+}
+
+func (p *Profile) hookEnv(evt string) (env []string) {
+	env = append(os.Environ(), p.getEnv()...)
+	env = append(env,
+		"PRITUNL_HOOK="+evt,
+		"PRITUNL_PROFILE_ID="+p.Id,
+		"PRITUNL_PROFILE_NAME="+p.Name,
+		"PRITUNL_MODE="+p.Mode,
+		"PRITUNL_IFACE="+p.tunnelIface(),
+		"PRITUNL_CLIENT_ADDR="+p.ClientAddr,
+		"PRITUNL_SERVER_ADDR="+p.ServerAddr,
+		"PRITUNL_DNS_SERVERS="+strings.Join(p.dnsServers, " "),
+		"PRITUNL_DNS_DOMAINS="+strings.Join(p.dnsDomains, " "),
+	)
+
+	return
+}
+
+// Command for a hook script, PowerShell scripts are run with the
+// execution policy bypassed
+func HookCommand(pth string) (cmd *exec.Cmd) {
+	if runtime.GOOS == "windows" &&
+		strings.ToLower(filepath.Ext(pth)) == ".ps1" {
+
+		cmd = command.Command(
+			"powershell.exe",
+			"-NoProfile",
+			"-NonInteractive",
+			"-ExecutionPolicy", "Bypass",
+			"-File", pth,
+		)
+		return
+	}
+
+	cmd = command.Command(pth)
+	return
+}
+
+func (p *Profile) runHook(evt, pth string, env []string) {
+	err := platform.CheckSecureFile(pth)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"event":      evt,
+			"path":       pth,
+			"error":      err,
+		}).Error("profile: Refusing to run insecure hook")
+		p.pushOutput("pritunl: Refusing to run insecure " + evt +
+			" hook " + pth)
+		return
+	}
+
+	output := &bytes.Buffer{}
+	cmd := HookCommand(pth)
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err = cmd.Start()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"event":      evt,
+			"path":       pth,
+			"error":      err,
+		}).Error("profile: Failed to start hook")
+		p.pushOutput("pritunl: Failed to start " + evt + " hook " + pth)
+		return
+	}
+
+	timer := time.AfterFunc(hookTimeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timedOut := !timer.Stop()
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			p.pushOutput("hook: " + line)
+		}
+	}
+
+	if timedOut {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"event":      evt,
+			"path":       pth,
+			"timeout":    hookTimeout.String(),
+		}).Error("profile: Hook timed out")
+		p.pushOutput("pritunl: Hook " + evt + " timed out")
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"event":      evt,
+			"path":       pth,
+			"error":      err,
+		}).Warn("profile: Hook failed")
+		p.pushOutput("pritunl: Hook " + evt + " failed, " + err.Error())
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"event":      evt,
+			"path":       pth,
+		}).Info("profile: Hook completed")
+	}
+}
+
+// Run the hooks for the event, the environment is captured by the caller
+// so disconnect hooks receive the state from before the teardown
+func (p *Profile) runHooks(evt string, env []string) {
+	for _, pth := range p.hookPaths(evt) {
+		p.runHook(evt, pth, env)
+	}
+}
+
+func (p *Profile) runHooksAsync(evt string, env []string) {
+	if len(p.hookPaths(evt)) == 0 {
+		return
+	}
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.runHooks(evt, env)
+	}()
+}
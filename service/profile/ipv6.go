@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"sync"
+
+	"github.com/pritunl/pritunl-client-electron/service/netclean"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+// IPv6 is disabled once while any profile requires it and restored when
+// the last of those profiles disconnects. The previous configuration is
+// recorded for the network clean if the service exits before restoring.
+var (
+	ipv6States   []*netconf.Ipv6State
+	ipv6Profiles = map[string]bool{}
+	ipv6Lock     = sync.Mutex{}
+)
+
+func ipv6Ifaces(states []*netconf.Ipv6State) (ifaces []string) {
+	ifaces = []string{}
+	for _, state := range states {
+		ifaces = append(ifaces, state.Iface)
+	}
+	return
+}
+
+func (p *Profile) hasIpv6() bool {
+	if p.GatewayAddr6 != "" {
+		return true
+	}
+
+	for _, network := range p.networks {
+		if network.IP.To4() == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Profile) disableIpv6() {
+	if !p.DisableIpv6 {
+		return
+	}
+
+	if p.DisableGateway || p.hasIpv6() {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: Skipping ipv6 disable, not a full tunnel " +
+			"without ipv6")
+		return
+	}
+
+	ipv6Lock.Lock()
+	defer ipv6Lock.Unlock()
+
+	if ipv6Profiles[p.Id] {
+		return
+	}
+
+	if len(ipv6Profiles) == 0 {
+		excludes := []string{}
+		for _, prfl := range GetProfiles() {
+			iface := prfl.tunnelIface()
+			if iface != "" {
+				excludes = append(excludes, iface)
+			}
+		}
+
+		states, err := netconf.Get().DisableIpv6(excludes)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to disable ipv6")
+			return
+		}
+		ipv6States = states
+		netclean.TrackIpv6(states)
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"interfaces": ipv6Ifaces(states),
+		}).Info("profile: Disabled ipv6 on physical interfaces")
+	}
+
+	ipv6Profiles[p.Id] = true
+}
+
+func (p *Profile) restoreIpv6() {
+	ipv6Lock.Lock()
+	defer ipv6Lock.Unlock()
+
+	if !ipv6Profiles[p.Id] {
+		return
+	}
+	delete(ipv6Profiles, p.Id)
+
+	if len(ipv6Profiles) > 0 {
+		return
+	}
+
+	states := ipv6States
+	ipv6States = nil
+	ifaces := ipv6Ifaces(states)
+
+	err := netconf.Get().RestoreIpv6(states)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"interfaces": ifaces,
+			"error":      err,
+		}).Error("profile: Failed to restore ipv6")
+		return
+	}
+	netclean.ReleaseIpv6()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"interfaces": ifaces,
+	}).Info("profile: Restored ipv6 on physical interfaces")
+}
@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/killswitch"
+	"github.com/sirupsen/logrus"
+)
+
+type KillSwitchError struct {
+	ProfileId string `json:"profile_id"`
+	Error     string `json:"error"`
+}
+
+// Addresses of all known servers for the profile, resolved while the
+// tunnel is up so reconnecting does not depend on DNS
+func (p *Profile) killSwitchEndpoints() (endpoints []string) {
+	endpoints = []string{}
+	seen := map[string]bool{}
+
+	hosts := p.getRemoteHosts()
+	if p.ServerAddr != "" {
+		hosts = append(hosts, p.ServerAddr)
+	}
+
+	for _, host := range hosts {
+		addrs := []string{host}
+		if net.ParseIP(host) == nil {
+			resolved, err := net.LookupHost(host)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": p.Id,
+					"host":       host,
+					"error":      err,
+				}).Warn("profile: Failed to resolve kill switch endpoint")
+				continue
+			}
+			addrs = resolved
+		}
+
+		for _, addr := range addrs {
+			if !seen[addr] {
+				seen[addr] = true
+				endpoints = append(endpoints, addr)
+			}
+		}
+	}
+
+	return
+}
+
+func (p *Profile) applyKillSwitch() {
+	if !p.KillSwitch {
+		return
+	}
+
+	addrs := []string{}
+	if p.ClientAddr != "" {
+		addrs = append(addrs, strings.SplitN(p.ClientAddr, "/", 2)[0])
+	}
+
+	err := killswitch.Enable(&killswitch.Rule{
+		ProfileId: p.Id,
+		Iface:     p.tunnelIface(),
+		Addresses: addrs,
+		Endpoints: p.killSwitchEndpoints(),
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to enable kill switch")
+
+		evt := &event.Event{
+			Type:      "kill_switch_error",
+			Workspace: p.Workspace,
+			Data: &KillSwitchError{
+				ProfileId: p.Id,
+				Error:     err.Error(),
+			},
+		}
+		evt.Init()
+	}
+}
+
+// Kill switch rules are only released on an explicit disconnect and are
+// kept when the tunnel fails
+func ReleaseKillSwitch(prflId string) {
+	err := killswitch.Disable(prflId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"error":      err,
+		}).Error("profile: Failed to release kill switch")
+
+		recordDirtyTeardown(prflId, "", []string{TeardownFirewall})
+	}
+}
@@ -0,0 +1,219 @@
+package profile
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	managedLock    = sync.Mutex{}
+	managedModTime time.Time
+	mdmHash        string
+	mdmErr         string
+)
+
+type ReconcileResult struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
+
+func filterManaged(sPrfl *sprofile.Sprofile) {
+	sPrfl.Id = utils.FilterStr(sPrfl.Id)
+	sPrfl.SplitDnsDomains = utils.FilterDomains(sPrfl.SplitDnsDomains)
+	sPrfl.Env = FilterEnv(sPrfl.Env)
+	sPrfl.Hooks = FilterHooks(sPrfl.Hooks)
+	sPrfl.IncludeRoutes = utils.FilterSubnets(sPrfl.IncludeRoutes)
+	sPrfl.ExcludeRoutes = utils.FilterSubnets(sPrfl.ExcludeRoutes)
+	sPrfl.OnDemandSubnets = utils.FilterSubnets(sPrfl.OnDemandSubnets)
+	sPrfl.RestartTriggers = sprofile.FilterTriggers(sPrfl.RestartTriggers)
+	sPrfl.DnsHijackMode = sprofile.FilterDnsHijackMode(sPrfl.DnsHijackMode)
+	sPrfl.Managed = true
+}
+
+// Profiles from MDM sources replace profiles with the same ID declared in
+// the managed file
+func mergeMdm(mngd *sprofile.Managed, mdm *sprofile.Mdm) (
+	merged *sprofile.Managed) {
+
+	merged = &sprofile.Managed{
+		Profiles: []*sprofile.Sprofile{},
+	}
+
+	mdmIds := map[string]bool{}
+	for _, sPrfl := range mdm.Profiles {
+		mdmIds[utils.FilterStr(sPrfl.Id)] = true
+	}
+
+	if mngd != nil {
+		for _, sPrfl := range mngd.Profiles {
+			if !mdmIds[utils.FilterStr(sPrfl.Id)] {
+				merged.Profiles = append(merged.Profiles, sPrfl)
+			}
+		}
+	}
+
+	merged.Profiles = append(merged.Profiles, mdm.Profiles...)
+
+	return
+}
+
+func loadMdm() (mdm *sprofile.Mdm, err error) {
+	mdm, err = sprofile.LoadMdm()
+	if err != nil {
+		if err.Error() == mdmErr {
+			err = nil
+			mdm = nil
+			return
+		}
+		mdmErr = err.Error()
+		return
+	}
+	mdmErr = ""
+
+	return
+}
+
+func reconcileManaged(mngd *sprofile.Managed) (
+	result *ReconcileResult, err error) {
+
+	result = &ReconcileResult{
+		Added:   []string{},
+		Updated: []string{},
+		Removed: []string{},
+	}
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	curPrfls := map[string]*sprofile.Sprofile{}
+	for _, sPrfl := range sprfls {
+		curPrfls[sPrfl.Id] = sPrfl
+	}
+
+	declared := map[string]bool{}
+	for _, sPrfl := range mngd.Profiles {
+		filterManaged(sPrfl)
+		if sPrfl.Id == "" {
+			err = &errortypes.ParseError{
+				errors.New("profile: Managed profile missing ID"),
+			}
+			return
+		}
+		declared[sPrfl.Id] = true
+	}
+
+	for _, sPrfl := range mngd.Profiles {
+		sPrfl = sPrfl.Copy()
+
+		curPrfl := curPrfls[sPrfl.Id]
+		if curPrfl != nil {
+			sPrfl.Password = curPrfl.Password
+			if curPrfl.Equal(sPrfl) {
+				continue
+			}
+		}
+
+		err = sPrfl.Commit()
+		if err != nil {
+			return
+		}
+
+		if curPrfl == nil {
+			result.Added = append(result.Added, sPrfl.Id)
+		} else {
+			result.Updated = append(result.Updated, sPrfl.Id)
+
+			prfl := GetProfile(sPrfl.Id)
+			if prfl != nil {
+				prfl.Stop()
+			}
+		}
+	}
+
+	for _, curPrfl := range sprfls {
+		if !curPrfl.Managed || declared[curPrfl.Id] {
+			continue
+		}
+
+		prfl := GetProfile(curPrfl.Id)
+		if prfl != nil {
+			prfl.Stop()
+		}
+		ReleaseKillSwitch(curPrfl.Id)
+
+		sprofile.Remove(curPrfl.Id)
+		result.Removed = append(result.Removed, curPrfl.Id)
+	}
+
+	if len(result.Added) > 0 || len(result.Updated) > 0 ||
+		len(result.Removed) > 0 {
+
+		logrus.WithFields(logrus.Fields{
+			"added":   result.Added,
+			"updated": result.Updated,
+			"removed": result.Removed,
+		}).Info("profile: Reconciled managed profiles")
+	}
+
+	return
+}
+
+func checkManaged() (err error) {
+	managedLock.Lock()
+	defer managedLock.Unlock()
+
+	mdm, err := loadMdm()
+	if err != nil || mdm == nil {
+		return
+	}
+	hash := mdm.Hash()
+
+	modTime, err := sprofile.GetManagedModTime()
+	if err != nil {
+		return
+	}
+
+	if (modTime.IsZero() || modTime.Equal(managedModTime)) &&
+		hash == mdmHash {
+
+		return
+	}
+
+	managedModTime = modTime
+
+	if hash != mdmHash {
+		err = config.SetPolicy(mdm.Settings)
+		if err != nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profiles": len(mdm.Profiles),
+			"settings": config.GetEnforced(),
+		}).Info("profile: Managed configuration changed")
+	}
+
+	mngd, err := sprofile.LoadManaged()
+	if err != nil {
+		return
+	}
+
+	_, err = reconcileManaged(mergeMdm(mngd, mdm))
+	if err != nil {
+		return
+	}
+
+	mdmHash = hash
+
+	return
+}
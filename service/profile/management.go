@@ -0,0 +1,270 @@
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	managementTimeout  = 5 * time.Second
+	managementRetry    = 2 * time.Second
+	managementMaxRetry = 30 * time.Second
+	managementDesync   = 5
+)
+
+// Lines from the "state" command and >STATE notifications
+// {timestamp},{state},{description},{local_ip},{remote_ip},...
+func parseManagementState(line string) (state string, ok bool) {
+	fields := strings.Split(line, ",")
+	if len(fields) < 2 || fields[0] == "" {
+		return
+	}
+
+	for _, c := range fields[0] {
+		if c < '0' || c > '9' {
+			return
+		}
+	}
+
+	state = fields[1]
+	ok = true
+	return
+}
+
+func (p *Profile) dialManagement() (conn net.Conn, out *bufio.Reader,
+	err error) {
+
+	conn, err = net.DialTimeout(
+		"tcp",
+		fmt.Sprintf("127.0.0.1:%d", p.managementPort),
+		3*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to open socket"),
+		}
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			conn.Close()
+			conn = nil
+		}
+	}()
+
+	err = conn.SetDeadline(time.Now().Add(managementTimeout))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed set deadline"),
+		}
+		return
+	}
+
+	_, err = conn.Write([]byte(fmt.Sprintf("%s\n", p.managementPass)))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to write socket password"),
+		}
+		return
+	}
+
+	// The password prompt is not terminated by a newline and is read
+	// with the password response
+	out = bufio.NewReader(conn)
+	for {
+		line, e := out.ReadString('\n')
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read socket greeting"),
+			}
+			return
+		}
+
+		if strings.Contains(line, "ERROR: bad password") {
+			err = &errortypes.ReadError{
+				errors.New("profile: Management password rejected"),
+			}
+			return
+		}
+
+		if strings.Contains(line, "SUCCESS: password is correct") ||
+			strings.HasPrefix(line, ">INFO:") {
+
+			break
+		}
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed set deadline"),
+		}
+		return
+	}
+
+	_, err = conn.Write([]byte("state on\nstate\nlog on\n"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to write socket command"),
+		}
+		return
+	}
+
+	return
+}
+
+// Log lines missed while the management connection was lost are not
+// replayed, the connection state is resynchronized from the current state
+func (p *Profile) syncManagementState(state string) {
+	if p.stop {
+		return
+	}
+
+	switch state {
+	case "CONNECTED":
+		if p.Status != "connected" {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"status":     p.Status,
+			}).Info("profile: Management state resynchronized to connected")
+
+			p.handleConnected()
+		}
+		break
+	case "RECONNECTING":
+		if p.PersistTun {
+			p.resumeStart()
+		}
+		break
+	}
+}
+
+func (p *Profile) readManagement(conn net.Conn, out *bufio.Reader) (
+	err error) {
+
+	desync := 0
+
+	for {
+		line, e := out.ReadString('\n')
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read socket"),
+			}
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, ">LOG:"):
+			// >LOG:{timestamp},{flags},{message}
+			fields := strings.SplitN(line[5:], ",", 3)
+			if len(fields) == 3 && fields[2] != "" {
+				p.parseLine(fields[2])
+			}
+			break
+		case strings.HasPrefix(line, ">STATE:"):
+			state, ok := parseManagementState(line[7:])
+			if ok {
+				p.syncManagementState(state)
+			}
+			break
+		case strings.HasPrefix(line, ">"),
+			strings.HasPrefix(line, "SUCCESS:"),
+			strings.HasPrefix(line, "ERROR:"),
+			line == "END", line == "":
+
+			break
+		default:
+			state, ok := parseManagementState(line)
+			if ok {
+				p.syncManagementState(state)
+				break
+			}
+
+			desync += 1
+			if desync >= managementDesync {
+				err = &errortypes.ParseError{
+					errors.Newf("profile: Management protocol desync "+
+						"on '%s'", line),
+				}
+				return
+			}
+			continue
+		}
+
+		desync = 0
+	}
+}
+
+// The stdout pipe of an adopted openvpn process was closed by the exec,
+// the log is read from the management interface instead. The connection
+// is reopened if it is lost while the process is running.
+func (p *Profile) watchManagement() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.trackRoutine("ovpn_management")()
+
+	retry := managementRetry
+
+	for {
+		if p.stop {
+			return
+		}
+
+		conn, out, err := p.dialManagement()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to connect to management interface")
+		} else {
+			retry = managementRetry
+
+			p.managementLock.Lock()
+			p.managementConn = conn
+			p.managementLock.Unlock()
+
+			err = p.readManagement(conn, out)
+
+			p.managementLock.Lock()
+			p.managementConn = nil
+			p.managementLock.Unlock()
+
+			conn.Close()
+
+			if p.stop {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Warn("profile: Management interface lost, reconnecting")
+		}
+
+		time.Sleep(retry)
+
+		retry *= 2
+		if retry > managementMaxRetry {
+			retry = managementMaxRetry
+		}
+	}
+}
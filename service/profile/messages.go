@@ -0,0 +1,135 @@
+package profile
+
+import (
+	"math"
+
+	"github.com/pritunl/pritunl-client-electron/service/message"
+)
+
+func (p *Profile) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": p.Id,
+		"name":       p.Name,
+	}
+}
+
+func (c *ClockSkew) MessageParams() message.Params {
+	direction := "behind"
+	if c.Offset < 0 {
+		direction = "ahead"
+	}
+
+	return message.Params{
+		"profile_id": c.ProfileId,
+		"offset":     c.Offset,
+		"minutes":    int64(math.Round(math.Abs(float64(c.Offset) / 60))),
+		"direction":  direction,
+	}
+}
+
+func PrecheckParams(prflId string,
+	failures []*PrecheckFailure) message.Params {
+
+	checks := []string{}
+	for _, failure := range failures {
+		checks = append(checks, failure.Check)
+	}
+
+	return message.Params{
+		"profile_id": prflId,
+		"checks":     checks,
+	}
+}
+
+func (d *PrecheckData) MessageParams() message.Params {
+	return PrecheckParams(d.Id, d.Failures)
+}
+
+func (e *ProfileExpiry) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": e.ProfileId,
+		"name":       e.Name,
+		"minutes":    int64(math.Ceil(float64(e.Remaining) / 60)),
+	}
+}
+
+func (k *KillSwitchError) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": k.ProfileId,
+		"error":      k.Error,
+	}
+}
+
+func (h *HostFailover) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": h.ProfileId,
+		"host":       h.Host,
+		"previous":   h.Previous,
+		"reason":     h.Reason,
+	}
+}
+
+func (d *DnsHijack) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": d.ProfileId,
+		"mode":       d.Mode,
+		"responder":  d.Responder,
+	}
+}
+
+func (d *DnsLeak) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": d.ProfileId,
+		"name":       d.Name,
+		"resolver":   d.Resolver,
+	}
+}
+
+func (d *DnsFallback) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": d.ProfileId,
+		"name":       d.Name,
+		"servers":    d.Servers,
+	}
+}
+
+func (r *RemoteBreakerEvent) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": r.ProfileId,
+		"name":       r.Name,
+		"remote":     r.Breaker.Remote,
+		"state":      r.Breaker.State,
+		"failures":   r.Breaker.Failures,
+		"until":      r.Breaker.Until,
+	}
+}
+
+func (r *RouteOverlap) MessageParams() message.Params {
+	return message.Params{
+		"profile_id":           r.ProfileId,
+		"other_profile_id":     r.OtherProfileId,
+		"preferred_profile_id": r.PreferredProfileId,
+		"networks":             r.Networks,
+	}
+}
+
+func (d *DirtyTeardown) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": d.ProfileId,
+		"leftovers":  d.Leftovers,
+	}
+}
+
+func (r *Resync) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": r.ProfileId,
+		"reason":     r.Reason,
+	}
+}
+
+func (s *SsoEventData) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": s.Id,
+		"url":        s.Url,
+	}
+}
@@ -0,0 +1,18 @@
+package profile
+
+import (
+	"time"
+)
+
+// Time from starting the connection until connected
+func (p *Profile) GetConnectLatency() time.Duration {
+	return p.connectLatency
+}
+
+// Time since the last WireGuard handshake, zero when unavailable
+func (p *Profile) GetHandshakeAge() time.Duration {
+	if p.Mode != Wg || p.wgHandshake == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(int64(p.wgHandshake), 0))
+}
@@ -0,0 +1,174 @@
+package profile
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Encapsulation overhead of the tunnel protocols over IPv6 and UDP
+	mtuOverhead = 80
+	mtuProbeTtl = 24 * time.Hour
+)
+
+var mtuRecords = struct {
+	sync.Mutex
+	m      map[string]*mtuRecord
+	loaded bool
+}{
+	m: map[string]*mtuRecord{},
+}
+
+// Tunnel MTU found by a probe, reused while the remote host is unchanged
+type mtuRecord struct {
+	Host      string `json:"host"`
+	Mtu       int    `json:"mtu"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+func getMtuPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "mtu.json")
+}
+
+func loadMtuRecords() {
+	if mtuRecords.loaded {
+		return
+	}
+	mtuRecords.loaded = true
+
+	data, err := ioutil.ReadFile(getMtuPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("profile: Failed to read mtu records")
+		}
+		return
+	}
+
+	records := map[string]*mtuRecord{}
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to parse mtu records")
+		return
+	}
+
+	mtuRecords.m = records
+}
+
+func saveMtuRecords() (err error) {
+	pth := getMtuPath()
+
+	for prflId, record := range mtuRecords.m {
+		if time.Since(time.Unix(record.Timestamp, 0)) > mtuProbeTtl {
+			delete(mtuRecords.m, prflId)
+		}
+	}
+
+	err = platform.MkdirReadSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(mtuRecords.m)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to marshal mtu records"),
+		}
+		return
+	}
+
+	err = ioutil.WriteFile(pth, data, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to write mtu records"),
+		}
+		return
+	}
+
+	return
+}
+
+// Tunnel MTU for a connection to the host, zero to use the server
+// configuration. A configured MTU is used as is, otherwise the MTU is
+// probed when enabled and recorded for later connections to the host.
+func (p *Profile) getMtu(host string) (mtu int) {
+	if p.Mtu > 0 {
+		mtu = sprofile.FilterMtu(p.Mtu)
+		return
+	}
+
+	if !p.MtuProbe || host == "" {
+		return
+	}
+
+	mtuRecords.Lock()
+	defer mtuRecords.Unlock()
+
+	loadMtuRecords()
+
+	record := mtuRecords.m[p.Id]
+	if record != nil && record.Host == host &&
+		time.Since(time.Unix(record.Timestamp, 0)) < mtuProbeTtl {
+
+		mtu = record.Mtu
+		return
+	}
+
+	start := time.Now()
+	pathMtu, err := network.ProbeMtu(host)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"host":       host,
+			"error":      err,
+		}).Warn("profile: Failed to probe path mtu")
+
+		if record != nil {
+			mtu = record.Mtu
+		}
+		return
+	}
+
+	// Links without a reduced MTU keep the server configuration
+	if pathMtu < network.MaxMtu {
+		mtu = sprofile.FilterMtu(pathMtu - mtuOverhead)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"host":       host,
+		"path_mtu":   pathMtu,
+		"mtu":        mtu,
+		"duration":   time.Since(start).String(),
+	}).Info("profile: Probed path mtu")
+
+	mtuRecords.m[p.Id] = &mtuRecord{
+		Host:      host,
+		Mtu:       mtu,
+		Timestamp: time.Now().Unix(),
+	}
+
+	err = saveMtuRecords()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Failed to save mtu records")
+	}
+
+	return
+}
@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const natCacheTtl = 5 * time.Minute
+
+// OpenVPN remotes use the protocol of the remote line or the proto option
+func (p *Profile) usesUdp() bool {
+	if p.Mode == Wg {
+		return !p.wgTcp
+	}
+
+	for _, line := range strings.Split(p.Data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[0] == "remote" &&
+			strings.HasPrefix(fields[3], "udp") {
+
+			return true
+		}
+		if len(fields) >= 2 && fields[0] == "proto" &&
+			strings.HasPrefix(fields[1], "udp") {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Detect the network NAT type before connecting, the detection runs before
+// the tunnel routes are added and the result is cached for diagnostics
+func (p *Profile) precheckNat() {
+	if !p.usesUdp() {
+		return
+	}
+
+	go p.reportNat()
+}
+
+func (p *Profile) reportNat() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	result := network.GetNat()
+	if result == nil || utils.SinceAbs(result.Timestamp) > natCacheTtl {
+		var err error
+		result, err = network.DetectNat()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to detect nat type")
+			return
+		}
+	}
+
+	switch result.Type {
+	case network.NatUdpBlocked:
+		p.pushOutput("pritunl: UDP traffic appears to be blocked " +
+			"on this network")
+		break
+	case network.NatSymmetric:
+		p.pushOutput("pritunl: Network uses symmetric NAT, " +
+			"UDP connections may be unreliable")
+		break
+	default:
+		p.pushOutput(fmt.Sprintf("pritunl: Network NAT type %s",
+			result.Type))
+	}
+}
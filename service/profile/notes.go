@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"runtime/debug"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/sirupsen/logrus"
+)
+
+var errorCodes = map[string]string{
+	"auth_error":            "AUTH_FAILED",
+	"clock_skew":            "CLOCK_SKEW",
+	"configuration_error":   "CONFIGURATION_ERROR",
+	"connection_error":      "CONNECTION_ERROR",
+	"credential_error":      "CREDENTIAL_ERROR",
+	"duplicate_login":       "DUPLICATE_LOGIN",
+	"handshake_timeout":     "HANDSHAKE_TIMEOUT",
+	"inactive":              "INACTIVE",
+	"offline_error":         "OFFLINE",
+	"precondition_error":    "PRECONDITION_FAILED",
+	"registration_required": "REGISTRATION_REQUIRED",
+	"timeout_error":         "TIMEOUT",
+}
+
+func watchNotes() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	listener := event.NewListener()
+	stream := listener.Listen()
+	defer listener.Close()
+
+	for evt := range stream {
+		code := errorCodes[evt.Type]
+		if code == "" {
+			continue
+		}
+
+		prflId := ""
+		switch data := evt.Data.(type) {
+		case *Profile:
+			prflId = data.Id
+			break
+		case *ClockSkew:
+			prflId = data.ProfileId
+			break
+		case *PrecheckData:
+			prflId = data.Id
+			break
+		}
+		if prflId == "" {
+			continue
+		}
+
+		stats.SetLastError(prflId, code)
+	}
+}
@@ -0,0 +1,260 @@
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	outputBuffer  = 1000
+	outputRate    = 200
+	outputTimeout = 10 * time.Second
+)
+
+var outputCritical = []string{
+	"Initialization Sequence Completed",
+	"PUSH_REPLY",
+	"AUTH_FAILED",
+	"auth-failure",
+	"TUN/TAP device",
+	"process restarting",
+	"process exiting",
+	"link remote:",
+	"network/local/netmask",
+	"ifconfig",
+	"ip addr add dev",
+	"Inactivity timeout",
+	"Connection reset",
+	"Can't assign requested address",
+}
+
+type outputPipe struct {
+	lines       chan string
+	lock        sync.Mutex
+	windowStart time.Time
+	windowCount int
+	dropped     int
+}
+
+func newOutputPipe() *outputPipe {
+	return &outputPipe{
+		lines: make(chan string, outputBuffer),
+	}
+}
+
+func (o *outputPipe) isCritical(line string) bool {
+	if isDuplicateLogin(line) {
+		return true
+	}
+
+	for _, msg := range outputCritical {
+		if strings.Contains(line, msg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *outputPipe) allow() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(o.windowStart) >= time.Second {
+		o.windowStart = now
+		o.windowCount = 0
+	}
+
+	o.windowCount += 1
+
+	return o.windowCount <= outputRate
+}
+
+func (o *outputPipe) drop() {
+	o.lock.Lock()
+	o.dropped += 1
+	o.lock.Unlock()
+}
+
+func (o *outputPipe) Push(line string) {
+	if o.isCritical(line) {
+		select {
+		case o.lines <- line:
+		case <-time.After(outputTimeout):
+			o.drop()
+		}
+		return
+	}
+
+	if !o.allow() {
+		o.drop()
+		return
+	}
+
+	select {
+	case o.lines <- line:
+	default:
+		o.drop()
+	}
+}
+
+func (o *outputPipe) Close() {
+	o.lines <- ""
+}
+
+func (o *outputPipe) Lines() <-chan string {
+	return o.lines
+}
+
+func (o *outputPipe) TakeDropped() (dropped int) {
+	o.lock.Lock()
+	dropped = o.dropped
+	o.dropped = 0
+	o.lock.Unlock()
+
+	return
+}
+
+// Read the openvpn output pipes, the wait group is done once all output
+// has been parsed
+func (p *Profile) readOutput(stdout, stderr *os.File) (
+	outputWait *sync.WaitGroup) {
+
+	output := newOutputPipe()
+	outputWait = &sync.WaitGroup{}
+	outputWait.Add(1)
+
+	stdoutDone := p.trackRoutine("ovpn_stdout")
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer stdoutDone()
+
+		defer func() {
+			_ = stdout.Close()
+			output.Close()
+		}()
+
+		out := bufio.NewReader(stdout)
+		for {
+			line, _, err := out.ReadLine()
+			if err != nil {
+				if err != io.EOF &&
+					!strings.Contains(err.Error(), "file already closed") &&
+					!strings.Contains(err.Error(), "bad file descriptor") {
+
+					err = &errortypes.ReadError{
+						errors.Wrap(err, "profile: Failed to read stdout"),
+					}
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("profile: Stdout error")
+				}
+
+				return
+			}
+
+			lineStr := string(line)
+			if lineStr != "" {
+				output.Push(lineStr)
+			}
+		}
+	}()
+
+	stderrDone := p.trackRoutine("ovpn_stderr")
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer stderrDone()
+
+		defer stderr.Close()
+
+		out := bufio.NewReader(stderr)
+		for {
+			line, _, err := out.ReadLine()
+			if err != nil {
+				if err != io.EOF &&
+					!strings.Contains(err.Error(), "file already closed") &&
+					!strings.Contains(err.Error(), "bad file descriptor") {
+
+					err = &errortypes.ReadError{
+						errors.Wrap(err, "profile: Failed to read stderr"),
+					}
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("profile: Stderr error")
+				}
+
+				return
+			}
+
+			lineStr := string(line)
+			if lineStr != "" {
+				output.Push(lineStr)
+			}
+		}
+	}()
+
+	outputDone := p.trackRoutine("ovpn_output")
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer outputDone()
+
+		defer outputWait.Done()
+
+		for line := range output.Lines() {
+			dropped := output.TakeDropped()
+			if dropped > 0 {
+				p.pushOutput(fmt.Sprintf(
+					"pritunl: Dropped %d lines of excessive output", dropped))
+			}
+
+			if line == "" {
+				return
+			}
+
+			p.parseLine(line)
+		}
+	}()
+
+	return
+}
@@ -0,0 +1,204 @@
+package profile
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+type RouteOverlap struct {
+	ProfileId          string   `json:"profile_id"`
+	OtherProfileId     string   `json:"other_profile_id"`
+	PreferredProfileId string   `json:"preferred_profile_id"`
+	Networks           []string `json:"networks"`
+}
+
+func overlapNetworks(nets1, nets2 []*net.IPNet) (overlaps []*net.IPNet) {
+	overlaps = []*net.IPNet{}
+
+	for _, net1 := range nets1 {
+		for _, net2 := range nets2 {
+			if !net1.Contains(net2.IP) && !net2.Contains(net1.IP) {
+				continue
+			}
+
+			size1, _ := net1.Mask.Size()
+			size2, _ := net2.Mask.Size()
+			if size1 >= size2 {
+				overlaps = append(overlaps, net1)
+			} else {
+				overlaps = append(overlaps, net2)
+			}
+		}
+	}
+
+	return
+}
+
+func (p *Profile) parseRoutes(line string) {
+	networks := []*net.IPNet{}
+	exclusions := []*net.IPNet{}
+
+	for _, opt := range strings.Split(line, ",") {
+		fields := strings.Fields(opt)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "route":
+			if len(fields) < 3 {
+				continue
+			}
+
+			ip := net.ParseIP(fields[1]).To4()
+			mask := net.ParseIP(fields[2]).To4()
+			if ip == nil || mask == nil {
+				continue
+			}
+
+			network := &net.IPNet{
+				IP:   ip.Mask(net.IPMask(mask)),
+				Mask: net.IPMask(mask),
+			}
+
+			// Routes to the net gateway are excluded from the tunnel
+			if len(fields) > 3 && fields[3] == "net_gateway" {
+				exclusions = append(exclusions, network)
+			} else {
+				networks = append(networks, network)
+			}
+			break
+		case "route-ipv6":
+			_, network, err := net.ParseCIDR(fields[1])
+			if err != nil {
+				continue
+			}
+
+			if len(fields) > 2 &&
+				strings.HasPrefix(fields[2], "net_gateway") {
+
+				exclusions = append(exclusions, network)
+			} else {
+				networks = append(networks, network)
+			}
+			break
+		}
+	}
+
+	if len(networks) > 0 {
+		p.networks = networks
+	}
+	p.setExclusions(exclusions)
+}
+
+func (p *Profile) parseWgRoutes(routes []*Route) {
+	networks := []*net.IPNet{}
+	exclusions := []*net.IPNet{}
+
+	for _, route := range routes {
+		_, network, err := net.ParseCIDR(route.Network)
+		if err != nil {
+			continue
+		}
+
+		if route.NetGateway {
+			exclusions = append(exclusions, network)
+		} else {
+			networks = append(networks, network)
+		}
+	}
+
+	p.networks = networks
+	p.setExclusions(exclusions)
+}
+
+func (p *Profile) pinRoutes(networks []*net.IPNet) {
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	for _, network := range networks {
+		err := netconf.Get().PinRoute(network, iface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    network.String(),
+				"error":      err,
+			}).Error("profile: Failed to pin overlapping route")
+		}
+	}
+}
+
+func (p *Profile) resolveOverlaps() {
+	if len(p.networks) == 0 {
+		return
+	}
+
+	for _, other := range GetProfiles() {
+		if other.Id == p.Id || other.Status != "connected" {
+			continue
+		}
+
+		overlaps := overlapNetworks(p.networks, other.networks)
+		if len(overlaps) == 0 {
+			continue
+		}
+
+		preferred := other
+		if p.RoutePriority > other.RoutePriority {
+			preferred = p
+		}
+
+		networks := []string{}
+		for _, network := range overlaps {
+			networks = append(networks, network.String())
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id":           p.Id,
+			"other_profile_id":     other.Id,
+			"preferred_profile_id": preferred.Id,
+			"networks":             networks,
+		}).Warn("profile: Connected profiles have overlapping networks")
+
+		evt := &event.Event{
+			Type:      "route_overlap",
+			Workspace: p.Workspace,
+			Data: &RouteOverlap{
+				ProfileId:          p.Id,
+				OtherProfileId:     other.Id,
+				PreferredProfileId: preferred.Id,
+				Networks:           networks,
+			},
+		}
+		evt.Init()
+
+		preferred.pinRoutes(overlaps)
+	}
+}
+
+func (p *Profile) releaseOverlaps() {
+	if len(p.networks) == 0 {
+		return
+	}
+
+	for _, other := range GetProfiles() {
+		if other.Id == p.Id || other.Status != "connected" {
+			continue
+		}
+
+		overlaps := overlapNetworks(p.networks, other.networks)
+		if len(overlaps) == 0 {
+			continue
+		}
+
+		other.pinRoutes(overlaps)
+	}
+
+	p.networks = nil
+}
@@ -0,0 +1,97 @@
+package profile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func networkStrings(prfl *Profile) (networks, exclusions []string) {
+	networks = []string{}
+	for _, network := range prfl.networks {
+		networks = append(networks, network.String())
+	}
+	exclusions = []string{}
+	for _, network := range prfl.exclusions {
+		exclusions = append(exclusions, network.String())
+	}
+	return
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		excludes   []string
+		networks   []string
+		exclusions []string
+	}{
+		{
+			"ipv4",
+			"PUSH_REPLY,route 10.100.0.0 255.255.0.0,route-gateway " +
+				"10.100.0.1,route 192.168.5.7 255.255.255.0",
+			nil,
+			[]string{"10.100.0.0/16", "192.168.5.0/24"},
+			[]string{},
+		},
+		{
+			"net gateway",
+			"PUSH_REPLY,route 10.0.0.0 255.0.0.0,route 10.5.0.0 " +
+				"255.255.0.0 net_gateway",
+			nil,
+			[]string{"10.0.0.0/8"},
+			[]string{"10.5.0.0/16"},
+		},
+		{
+			"ipv6",
+			"PUSH_REPLY,route-ipv6 fd00::/64,route-ipv6 2001:db8::/32 " +
+				"net_gateway_ipv6",
+			nil,
+			[]string{"fd00::/64"},
+			[]string{"2001:db8::/32"},
+		},
+		{
+			"invalid",
+			"PUSH_REPLY,route 10.0.0.0,route invalid 255.0.0.0," +
+				"route-ipv6 invalid,route 10.1.0.0 255.255.0.0",
+			nil,
+			[]string{"10.1.0.0/16"},
+			[]string{},
+		},
+		{
+			"profile excludes",
+			"PUSH_REPLY,route 10.0.0.0 255.0.0.0",
+			[]string{"10.9.0.0/16", "fd00::/64"},
+			[]string{"10.0.0.0/8"},
+			[]string{"10.9.0.0/16"},
+		},
+	}
+
+	for _, test := range tests {
+		prfl := &Profile{
+			ExcludeRoutes: test.excludes,
+		}
+		prfl.parseRoutes(test.line)
+
+		networks, exclusions := networkStrings(prfl)
+		if !reflect.DeepEqual(networks, test.networks) {
+			t.Errorf("%s: expected networks %v got %v",
+				test.name, test.networks, networks)
+		}
+		if !reflect.DeepEqual(exclusions, test.exclusions) {
+			t.Errorf("%s: expected exclusions %v got %v",
+				test.name, test.exclusions, exclusions)
+		}
+	}
+}
+
+// Networks from an earlier push are kept when a push has no routes
+func TestParseRoutesEmpty(t *testing.T) {
+	prfl := &Profile{}
+	prfl.parseRoutes("PUSH_REPLY,route 10.0.0.0 255.0.0.0")
+	prfl.parseRoutes("PUSH_REPLY,route-gateway 10.0.0.1")
+
+	networks, _ := networkStrings(prfl)
+	if !reflect.DeepEqual(networks, []string{"10.0.0.0/8"}) {
+		t.Fatalf("expected networks kept got %v", networks)
+	}
+}
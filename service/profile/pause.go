@@ -0,0 +1,224 @@
+package profile
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const PauseMax = 24 * time.Hour
+
+var pauses = struct {
+	sync.Mutex
+	m map[string]*pauseState
+}{
+	m: map[string]*pauseState{},
+}
+
+type PauseInfo struct {
+	ProfileId string `json:"profile_id"`
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+	Resume    int64  `json:"resume"`
+}
+
+func (i *PauseInfo) MessageParams() message.Params {
+	minutes := int64(0)
+	if i.Resume != 0 {
+		minutes = (i.Resume - i.Timestamp + 59) / 60
+	}
+
+	return message.Params{
+		"profile_id": i.ProfileId,
+		"name":       i.Name,
+		"minutes":    minutes,
+	}
+}
+
+// Paused profiles keep the connect intent, system profiles stay active in
+// the system profile state and other profiles keep a copy to start on
+// resume
+type pauseState struct {
+	info   *PauseInfo
+	prfl   *Profile
+	system bool
+	timer  *time.Timer
+}
+
+func Paused(prflId string) (paused bool) {
+	pauses.Lock()
+	_, paused = pauses.m[prflId]
+	pauses.Unlock()
+	return
+}
+
+func GetPause(prflId string) (info *PauseInfo) {
+	pauses.Lock()
+	state := pauses.m[prflId]
+	if state != nil {
+		info = state.info
+	}
+	pauses.Unlock()
+	return
+}
+
+// Disconnect the profile without reconnecting, the profile is resumed after
+// the duration or when resumed on demand if the duration is zero
+func Pause(prflId string, duration time.Duration) (
+	info *PauseInfo, err error) {
+
+	prfl := GetProfile(prflId)
+	if prfl == nil || prfl.stop {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Profile not connected"),
+		}
+		return
+	}
+
+	sPrfl := sprofile.Get(prflId)
+	if sPrfl != nil && sPrfl.AlwaysOn {
+		err = &errortypes.PolicyError{
+			errors.New("profile: Cannot pause always on profile"),
+		}
+		return
+	}
+
+	if duration < 0 || duration > PauseMax {
+		err = &errortypes.ParseError{
+			errors.Newf("profile: Invalid pause duration %s", duration),
+		}
+		return
+	}
+
+	now := time.Now()
+	info = &PauseInfo{
+		ProfileId: prflId,
+		Name:      prfl.Name,
+		Timestamp: now.Unix(),
+	}
+	if duration != 0 {
+		info.Resume = now.Add(duration).Unix()
+	}
+
+	state := &pauseState{
+		info:   info,
+		prfl:   prfl.Copy(),
+		system: sPrfl != nil,
+	}
+
+	pauses.Lock()
+	curState := pauses.m[prflId]
+	if curState != nil && curState.timer != nil {
+		curState.timer.Stop()
+	}
+	pauses.m[prflId] = state
+	if duration != 0 {
+		state.timer = time.AfterFunc(duration, func() {
+			resume(prflId, state)
+		})
+	}
+	pauses.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+		"duration":   duration.String(),
+	}).Info("profile: Pausing profile")
+
+	prfl.Stop()
+	ReleaseKillSwitch(prflId)
+
+	evt := &event.Event{
+		Type: "profile_paused",
+		Data: info,
+	}
+	evt.Init()
+
+	return
+}
+
+// Clear the pause without reconnecting, used when the profile is
+// disconnected or connected by the user
+func CancelPause(prflId string) {
+	pauses.Lock()
+	state := pauses.m[prflId]
+	if state != nil {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(pauses.m, prflId)
+	}
+	pauses.Unlock()
+}
+
+func Resume(prflId string) (err error) {
+	pauses.Lock()
+	state := pauses.m[prflId]
+	pauses.Unlock()
+
+	if state == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Profile not paused"),
+		}
+		return
+	}
+
+	resume(prflId, state)
+
+	return
+}
+
+func resume(prflId string, state *pauseState) {
+	pauses.Lock()
+	if pauses.m[prflId] != state {
+		pauses.Unlock()
+		return
+	}
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	delete(pauses.m, prflId)
+	pauses.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+	}).Info("profile: Resuming profile")
+
+	evt := &event.Event{
+		Type: "profile_resumed",
+		Data: state.info,
+	}
+	evt.Init()
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		var err error
+		if state.system {
+			err = SyncSystemProfiles()
+		} else if GetProfile(prflId) == nil {
+			err = state.prfl.Start(false, true, true)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": prflId,
+				"error":      err,
+			}).Error("profile: Failed to resume profile")
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package profile
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const resumeTimeout = 30 * time.Second
+
+func (p *Profile) resumeStart() {
+	if p.stop || p.Status != "connected" {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"iface":      p.Tuniface,
+	}).Info("profile: Resuming session with persistent tunnel")
+
+	p.Status = "reconnecting"
+	p.update()
+
+	start := time.Now()
+	p.resumeTime = start
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		time.Sleep(resumeTimeout)
+
+		if p.stop || p.Status != "reconnecting" ||
+			!p.resumeTime.Equal(start) {
+
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"timeout":    resumeTimeout.String(),
+		}).Warn("profile: Session resume timed out, restarting")
+
+		p.Restart()
+	}()
+}
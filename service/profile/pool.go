@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"net"
+	"sync"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+)
+
+var (
+	startLock   = sync.Mutex{}
+	startCond   = sync.NewCond(&startLock)
+	startActive = 0
+	dnsLock     = sync.Mutex{}
+	routeLock   = sync.Mutex{}
+)
+
+// Starts are unlimited unless a start concurrency is configured, profiles
+// wait on each other when limited so a low limit delays every connection
+func GetStartConcurrency() int {
+	if config.Config.StartConcurrency > 0 {
+		return config.Config.StartConcurrency
+	}
+	return 0
+}
+
+func acquireStart() {
+	startLock.Lock()
+	for GetStartConcurrency() > 0 &&
+		startActive >= GetStartConcurrency() {
+
+		startCond.Wait()
+	}
+	startActive += 1
+	startLock.Unlock()
+}
+
+func releaseStart() {
+	startLock.Lock()
+	startActive -= 1
+	startLock.Unlock()
+	startCond.Broadcast()
+}
+
+// Start profiles in the background limited to the start concurrency when
+// configured, profiles of a sync or restart are started together and the
+// limit keeps the connect requests and system commands from contending
+func startPooled(prfl *Profile, timeout, delay, automatic bool) (err error) {
+	acquireStart()
+	defer releaseStart()
+
+	err = prfl.Start(timeout, delay, automatic)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Route table changes are global, concurrent profiles serialize them
+func addRoute(network *net.IPNet, iface string) error {
+	routeLock.Lock()
+	defer routeLock.Unlock()
+
+	return netconf.Get().AddRoute(network, iface)
+}
+
+func addGatewayRoute(network *net.IPNet, gateway net.IP,
+	iface string) error {
+
+	routeLock.Lock()
+	defer routeLock.Unlock()
+
+	return netconf.Get().AddGatewayRoute(network, gateway, 0, iface)
+}
+
+func deleteRoute(network *net.IPNet, iface string) error {
+	routeLock.Lock()
+	defer routeLock.Unlock()
+
+	return netconf.Get().DeleteRoute(network, iface)
+}
@@ -21,6 +21,21 @@ func ManagementPortAcquire() (port int) {
 	return
 }
 
+// Remove a port held by an adopted profile from the pool
+func ManagementPortReserve(port int) {
+	portsLock.Lock()
+	defer portsLock.Unlock()
+
+	for i, prt := range ports {
+		if port == prt {
+			ports = append(ports[:i], ports[i+1:]...)
+			break
+		}
+	}
+
+	return
+}
+
 func ManagementPortRelease(port int) {
 	if port == 0 {
 		return
@@ -0,0 +1,193 @@
+package profile
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	portalInterval    = 10 * time.Second
+	portalMaxInterval = 60 * time.Second
+	portalMaxWait     = 15 * time.Minute
+)
+
+var portalWaits = struct {
+	sync.Mutex
+	m map[string]*PortalWait
+}{
+	m: map[string]*PortalWait{},
+}
+
+type PortalWait struct {
+	ProfileId string `json:"profile_id"`
+	Name      string `json:"name"`
+	Redirect  string `json:"redirect"`
+	Timestamp int64  `json:"timestamp"`
+	cancel    chan bool
+}
+
+func (w *PortalWait) MessageParams() message.Params {
+	return message.Params{
+		"profile_id": w.ProfileId,
+		"name":       w.Name,
+		"redirect":   w.Redirect,
+	}
+}
+
+func GetPortalMaxWait() time.Duration {
+	if config.Config.PortalMaxWait > 0 {
+		return time.Duration(config.Config.PortalMaxWait) * time.Second
+	}
+	return portalMaxWait
+}
+
+func PortalWaiting(prflId string) (waiting bool) {
+	portalWaits.Lock()
+	_, waiting = portalWaits.m[prflId]
+	portalWaits.Unlock()
+	return
+}
+
+func GetPortalWaits() (waits []*PortalWait) {
+	waits = []*PortalWait{}
+
+	portalWaits.Lock()
+	for _, wait := range portalWaits.m {
+		waits = append(waits, wait)
+	}
+	portalWaits.Unlock()
+
+	return
+}
+
+// Abort a reconnect deferred by a captive portal
+func CancelPortalWait(prflId string) {
+	portalWaits.Lock()
+	wait := portalWaits.m[prflId]
+	portalWaits.Unlock()
+
+	if wait != nil {
+		select {
+		case wait.cancel <- true:
+		default:
+		}
+	}
+}
+
+// Defer the reconnect while the network is behind a captive portal to
+// avoid repeated authentication attempts locking out the account. The
+// reconnect is attempted after the max wait, returns false if the
+// reconnect was cancelled while waiting
+func (p *Profile) waitPortal() (ok bool) {
+	if config.Config.DisableCaptivePortal {
+		ok = true
+		return
+	}
+
+	result, err := network.DetectPortal()
+	if err != nil || !result.Portal {
+		ok = true
+		return
+	}
+
+	wait := &PortalWait{
+		ProfileId: p.Id,
+		Name:      p.Name,
+		Redirect:  result.Redirect,
+		Timestamp: time.Now().Unix(),
+		cancel:    make(chan bool, 1),
+	}
+
+	portalWaits.Lock()
+	portalWaits.m[p.Id] = wait
+	portalWaits.Unlock()
+
+	defer func() {
+		portalWaits.Lock()
+		if portalWaits.m[p.Id] == wait {
+			delete(portalWaits.m, p.Id)
+		}
+		portalWaits.Unlock()
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"redirect":   result.Redirect,
+	}).Warn("profile: Captive portal detected, deferring reconnect")
+
+	evt := &event.Event{
+		Type:      "captive_portal",
+		Workspace: p.Workspace,
+		Data:      wait,
+	}
+	evt.Init()
+
+	expired := false
+	deadline := time.Now().Add(GetPortalMaxWait())
+	interval := portalInterval
+	for {
+		select {
+		case <-wait.cancel:
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+			}).Info("profile: Deferred reconnect cancelled")
+			return
+		case <-time.After(interval):
+		}
+
+		if p.SystemProfile != nil {
+			sPrfl := sprofile.Get(p.Id)
+			if sPrfl == nil || !sPrfl.State {
+				return
+			}
+		}
+
+		// Internet access must be confirmed, probe errors are expected
+		// until the portal sign in is complete
+		result, err = network.DetectPortal()
+		if err == nil && !result.Portal {
+			break
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			expired = true
+			break
+		}
+
+		interval *= 2
+		if interval > portalMaxInterval {
+			interval = portalMaxInterval
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+	}
+
+	if expired {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Warn("profile: Captive portal wait expired, reconnecting")
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: Internet access restored, reconnecting")
+	}
+
+	evt = &event.Event{
+		Type:      "captive_portal_cleared",
+		Workspace: p.Workspace,
+		Data:      wait,
+	}
+	evt.Init()
+
+	ok = true
+	return
+}
@@ -0,0 +1,255 @@
+package profile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/container"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	PrecheckTempDir    = "temp_dir"
+	PrecheckFile       = "file"
+	PrecheckBinary     = "binary"
+	PrecheckDevice     = "device"
+	PrecheckCapability = "capability"
+	PrecheckExpired    = "expired"
+	PrecheckWgMode     = "wireguard_mode"
+)
+
+type PrecheckFailure struct {
+	Check string `json:"check"`
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type PrecheckData struct {
+	Id       string             `json:"id"`
+	Failures []*PrecheckFailure `json:"failures"`
+}
+
+func precheckTempDir() (failure *PrecheckFailure) {
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		failure = &PrecheckFailure{
+			Check: PrecheckTempDir,
+			Error: err.Error(),
+		}
+		return
+	}
+
+	pth := filepath.Join(rootDir, "precheck-"+utils.Uuid())
+
+	err = ioutil.WriteFile(pth, []byte{}, 0600)
+	if err != nil {
+		failure = &PrecheckFailure{
+			Check: PrecheckTempDir,
+			Path:  rootDir,
+			Error: "Temporary directory not writable",
+		}
+		return
+	}
+	_ = os.Remove(pth)
+
+	return
+}
+
+func precheckFile(pth string) (failure *PrecheckFailure) {
+	file, err := os.Open(pth)
+	if err != nil {
+		msg := "File not readable"
+		if os.IsNotExist(err) {
+			msg = "File does not exist"
+		}
+
+		failure = &PrecheckFailure{
+			Check: PrecheckFile,
+			Path:  pth,
+			Error: msg,
+		}
+		return
+	}
+	file.Close()
+
+	return
+}
+
+func precheckBinary(name, pth string) (failure *PrecheckFailure) {
+	if pth == "" {
+		failure = &PrecheckFailure{
+			Check: PrecheckBinary,
+			Path:  name,
+			Error: "Executable not found",
+		}
+		return
+	}
+
+	_, err := exec.LookPath(pth)
+	if err != nil {
+		failure = &PrecheckFailure{
+			Check: PrecheckBinary,
+			Path:  pth,
+			Error: "Executable not found or not executable",
+		}
+		return
+	}
+
+	return
+}
+
+func precheckTun() (failure *PrecheckFailure) {
+	err := container.CheckTun()
+	if err != nil {
+		msg := "Tunnel device not accessible, allow the device " +
+			"in the container device cgroup"
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			msg = "Tunnel device not found, start the container " +
+				"with --device " + container.TunPath
+		}
+
+		failure = &PrecheckFailure{
+			Check: PrecheckDevice,
+			Path:  container.TunPath,
+			Error: msg,
+		}
+		return
+	}
+
+	return
+}
+
+func precheckNetAdmin() (failure *PrecheckFailure) {
+	err := container.CheckNetAdmin()
+	if err != nil {
+		failure = &PrecheckFailure{
+			Check: PrecheckCapability,
+			Path:  "CAP_NET_ADMIN",
+			Error: "Missing capability, start the container " +
+				"with --cap-add",
+		}
+		return
+	}
+
+	return
+}
+
+func precheckExpiry(expiresAt int64) (failure *PrecheckFailure) {
+	if expiresAt == 0 || time.Now().Unix() < expiresAt {
+		return
+	}
+
+	failure = &PrecheckFailure{
+		Check: PrecheckExpired,
+		Error: "Profile access expired",
+	}
+
+	return
+}
+
+func (p *Profile) precheck() (failures []*PrecheckFailure) {
+	failures = []*PrecheckFailure{}
+
+	failure := precheckExpiry(p.ExpiresAt)
+	if failure != nil {
+		failures = append(failures, failure)
+		return
+	}
+
+	failure = precheckTempDir()
+	if failure != nil {
+		failures = append(failures, failure)
+	}
+
+	binaries := [][]string{}
+	if p.Mode == Wg && wgUtunMode() {
+		binaries = append(binaries,
+			[]string{"wireguard-go", GetWgUserspacePath()})
+	} else if p.Mode == Wg {
+		binaries = append(binaries, []string{"wg", p.wgPath})
+		if runtime.GOOS != "windows" {
+			binaries = append(binaries, []string{"wg-quick", p.wgQuickPath})
+		}
+		if runtime.GOOS == "linux" &&
+			config.Config.WireguardMode == WgModeUserspace {
+
+			binaries = append(binaries,
+				[]string{"wireguard-go", GetWgUserspacePath()})
+
+			if wgKernelAvailable() {
+				failures = append(failures, &PrecheckFailure{
+					Check: PrecheckWgMode,
+					Path:  "/sys/module/wireguard",
+					Error: "WireGuard kernel module loaded, unload the " +
+						"module or use the kernel mode",
+				})
+			}
+		}
+	} else {
+		binaries = append(binaries, []string{"openvpn", getOpenvpnPath()})
+
+		for _, ref := range sprofile.GetFileReferences(p.Data) {
+			failure = precheckFile(ref.Path)
+			if failure != nil {
+				failures = append(failures, failure)
+			}
+		}
+	}
+
+	for _, binary := range binaries {
+		failure = precheckBinary(binary[0], binary[1])
+		if failure != nil {
+			failures = append(failures, failure)
+		}
+	}
+
+	// Kernel WireGuard interfaces do not use the tunnel device
+	if runtime.GOOS == "linux" && container.Detected() {
+		if p.Mode != Wg || !wgKernelAvailable() {
+			failure = precheckTun()
+			if failure != nil {
+				failures = append(failures, failure)
+			}
+		}
+
+		failure = precheckNetAdmin()
+		if failure != nil {
+			failures = append(failures, failure)
+		}
+	}
+
+	return
+}
+
+func precheckError(failures []*PrecheckFailure) (err error) {
+	msgs := []string{}
+	for _, failure := range failures {
+		if failure.Path != "" {
+			msgs = append(msgs, fmt.Sprintf("%s '%s'",
+				failure.Error, failure.Path))
+		} else {
+			msgs = append(msgs, failure.Error)
+		}
+	}
+
+	err = &errortypes.PreconditionError{
+		errors.Newf("profile: Connection precondition failed: %s",
+			strings.Join(msgs, ", ")),
+	}
+
+	return
+}
+
+func (p *Profile) GetPrecheckFailures() []*PrecheckFailure {
+	return p.precheckFailures
+}
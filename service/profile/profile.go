@@ -1,7 +1,6 @@
 package profile
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto"
@@ -17,9 +16,7 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
-	"io"
 	"io/ioutil"
-	mathrand "math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -36,15 +33,25 @@ import (
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/audit"
 	"github.com/pritunl/pritunl-client-electron/service/command"
 	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/event"
 	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/netclean"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/netsnap"
 	"github.com/pritunl/pritunl-client-electron/service/network"
 	"github.com/pritunl/pritunl-client-electron/service/parser"
 	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/posture"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/secret"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
 	"github.com/pritunl/pritunl-client-electron/service/token"
 	"github.com/pritunl/pritunl-client-electron/service/tpm"
 	"github.com/pritunl/pritunl-client-electron/service/tuntap"
@@ -70,6 +77,7 @@ var (
 	}
 	Ping            = time.Now()
 	clientTransport = &http.Transport{
+		Proxy:               proxy.Func,
 		DisableKeepAlives:   true,
 		TLSHandshakeTimeout: 5 * time.Second,
 		TLSClientConfig: &tls.Config{
@@ -107,6 +115,7 @@ type WgKeyReq struct {
 type WgKeyBox struct {
 	DeviceId       string   `json:"device_id"`
 	DeviceName     string   `json:"device_name"`
+	ClientDeviceId string   `json:"client_device_id"`
 	DeviceKey      string   `json:"device_key"`
 	DeviceHostname string   `json:"device_hostname"`
 	Platform       string   `json:"platform"`
@@ -120,11 +129,14 @@ type WgKeyBox struct {
 	PublicAddress  string   `json:"public_address"`
 	PublicAddress6 string   `json:"public_address6"`
 	SsoToken       string   `json:"sso_token"`
+
+	Posture *posture.Report `json:"posture,omitempty"`
 }
 
 type OvpnKeyBox struct {
 	DeviceId       string   `json:"device_id"`
 	DeviceName     string   `json:"device_name"`
+	ClientDeviceId string   `json:"client_device_id"`
 	DeviceKey      string   `json:"device_key"`
 	DeviceHostname string   `json:"device_hostname"`
 	Platform       string   `json:"platform"`
@@ -137,6 +149,8 @@ type OvpnKeyBox struct {
 	PublicAddress  string   `json:"public_address"`
 	PublicAddress6 string   `json:"public_address6"`
 	SsoToken       string   `json:"sso_token"`
+
+	Posture *posture.Report `json:"posture,omitempty"`
 }
 
 type KeyResp struct {
@@ -163,6 +177,7 @@ type WgConf struct {
 	Gateway       string   `json:"gateway"`
 	Gateway6      string   `json:"gateway6"`
 	Port          int      `json:"port"`
+	TcpPort       int      `json:"tcp_port"`
 	WebPort       int      `json:"web_port"`
 	WebNoSsl      bool     `json:"web_no_ssl"`
 	PublicKey     string   `json:"public_key"`
@@ -198,6 +213,13 @@ type OutputData struct {
 	Output string `json:"output"`
 }
 
+type StateData struct {
+	Id         string `json:"id"`
+	Status     string `json:"status"`
+	PrevStatus string `json:"prev_status"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
 type Profile struct {
 	state           bool         `json:"-"`
 	stopping        bool         `json:"-"`
@@ -213,7 +235,10 @@ type Profile struct {
 
 	wgQuickLock        sync.Mutex         `json:"-"`
 	startTime          time.Time          `json:"-"`
+	connectLatency     time.Duration      `json:"-"`
+	auditConnected     bool               `json:"-"`
 	authFailed         bool               `json:"-"`
+	duplicate          bool               `json:"-"`
 	remPaths           []string           `json:"-"`
 	bashPath           string             `json:"-"`
 	wgPath             string             `json:"-"`
@@ -221,14 +246,57 @@ type Profile struct {
 	wgConfPth          string             `json:"-"`
 	wgHandshake        int                `json:"-"`
 	wgServerPublicKey  string             `json:"-"`
+	wgTcp              bool               `json:"-"`
+	wgTcpRetry         bool               `json:"-"`
+	wgTcpPort          int                `json:"-"`
+	wgTcpRelay         *wgTcpRelay        `json:"-"`
+	wgUtun             *wgUtunDevice      `json:"-"`
+	proxyRelay         *proxy.Relay       `json:"-"`
 	openReqCancel      context.CancelFunc `json:"-"`
 	cmd                *exec.Cmd          `json:"-"`
+	stdout             *os.File           `json:"-"`
+	stderr             *os.File           `json:"-"`
 	tap                string             `json:"-"`
 	lastAuthErr        time.Time          `json:"-"`
 	token              *token.Token       `json:"-"`
 	managementPass     string             `json:"-"`
+	otpCode            string             `json:"-"`
 	managementPort     int                `json:"-"`
+	managementConn     net.Conn           `json:"-"`
+	dnsServers         []string           `json:"-"`
+	dnsServersPushed   []string           `json:"-"`
+	dnsBenchmarks      []*DnsBenchmark    `json:"-"`
+	dnsDomains         []string           `json:"-"`
+	dnsFailures        int                `json:"-"`
+	networks           []*net.IPNet       `json:"-"`
+	summaryNetworks    []*net.IPNet       `json:"-"`
+	exclusions         []*net.IPNet       `json:"-"`
+	exclusionRoutes    []*installedRoute  `json:"-"`
+	dnsRoutes          []*installedRoute  `json:"-"`
+	resumeTime         time.Time          `json:"-"`
+	routines           map[string]int     `json:"-"`
+	splitDnsActive     bool               `json:"-"`
+	scutilDns          bool               `json:"-"`
+	dnsServersSet      bool               `json:"-"`
+	dnsDirty           bool               `json:"-"`
+	lastStatus         string             `json:"-"`
+	clockChecked       bool               `json:"-"`
+	routeGuard         bool               `json:"-"`
+	hostFailover       bool               `json:"-"`
+	bandwidth          bool               `json:"-"`
+	preferredRemote    string             `json:"-"`
+	reqRemote          string             `json:"-"`
+	failoverTime       time.Time          `json:"-"`
+	precheckFailures   []*PrecheckFailure `json:"-"`
+	statusPath         string             `json:"-"`
+	rxBytes            int64              `json:"-"`
+	txBytes            int64              `json:"-"`
+	trafficStart       time.Time          `json:"-"`
+	trafficTx          int64              `json:"-"`
+	trafficRx          int64              `json:"-"`
 	Id                 string             `json:"id"`
+	Name               string             `json:"name"`
+	Workspace          string             `json:"workspace"`
 	Mode               string             `json:"mode"`
 	OrgId              string             `json:"-"`
 	UserId             string             `json:"-"`
@@ -253,17 +321,51 @@ type Profile struct {
 	ServerPublicKey    string             `json:"-"`
 	ServerBoxPublicKey string             `json:"-"`
 	TokenTtl           int                `json:"-"`
+	SplitDnsDomains    []string           `json:"split_dns_domains"`
+	Env                map[string]string  `json:"-"`
+	Hooks              map[string]string  `json:"-"`
+	CredentialProvider string             `json:"-"`
+	CredentialCacheTtl int                `json:"-"`
+	RoutePriority      int                `json:"-"`
+	IncludeRoutes      []string           `json:"-"`
+	ExcludeRoutes      []string           `json:"-"`
+	PersistTun         bool               `json:"-"`
+	HttpProxy          string             `json:"-"`
+	SocksProxy         string             `json:"-"`
+	AlwaysOn           bool               `json:"-"`
+	KillSwitch         bool               `json:"-"`
+	CredentialId       string             `json:"-"`
+	HostFailover       bool               `json:"-"`
+	Verbosity          int                `json:"-"`
+	SplitDnsPushed     bool               `json:"-"`
+	DnsHijackMode      string             `json:"-"`
+	DisableIpv6        bool               `json:"-"`
+	SyslogTag          string             `json:"-"`
+	WgTcpFallback      bool               `json:"-"`
+	Mtu                int                `json:"-"`
+	MssFix             int                `json:"-"`
+	MtuProbe           bool               `json:"-"`
+	SummarizeRoutes    bool               `json:"-"`
+	ExpiresAt          int64              `json:"-"`
 	Iface              string             `json:"iface"`
 	Tuniface           string             `json:"tun_iface"`
 	Routes             []*Route           `json:"routes'"`
 	Routes6            []*Route           `json:"routes6'"`
 	Reconnect          bool               `json:"reconnect"`
 	Status             string             `json:"status"`
+	Degraded           bool               `json:"degraded"`
+	DnsStatus          string             `json:"dns_status"`
+	DnsCheck           *network.DnsCheck  `json:"dns_check"`
+	DnsFallback        bool               `json:"dns_fallback"`
+	RemoteBreakers     []*RemoteBreaker   `json:"remote_breakers"`
 	Timestamp          int64              `json:"timestamp"`
 	GatewayAddr        string             `json:"gateway_addr"`
 	GatewayAddr6       string             `json:"gateway_addr6"`
 	ServerAddr         string             `json:"server_addr"`
+	HostLatencies      []*HostLatency     `json:"host_latencies"`
 	ClientAddr         string             `json:"client_addr"`
+	Cipher             string             `json:"cipher"`
+	TunnelMtu          int                `json:"tunnel_mtu"`
 	MacAddr            string             `json:"mac_addr"`
 	MacAddrs           []string           `json:"mac_addrs"`
 	WebPort            int                `json:"web_port"`
@@ -300,7 +402,28 @@ func (p *Profile) write(fixedRemote, fixedRemote6 string) (
 
 	p.parsedPrfl = parser.Import(
 		p.Data, fixedRemote, fixedRemote6, p.DisableGateway, p.DisableDns)
+	if !utils.HasAesAcceleration() {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Info("profile: No AES acceleration, preferring ChaCha20-Poly1305")
+		p.parsedPrfl.PreferChacha = true
+	}
+	if p.preferredRemote != "" {
+		p.parsedPrfl.PreferRemote(p.preferredRemote)
+	}
+	if len(p.parsedPrfl.Remotes) > 0 {
+		mtu := p.getMtu(p.parsedPrfl.Remotes[0].Host)
+		if mtu > 0 {
+			p.parsedPrfl.TunMtu = mtu
+		}
+	}
+	if p.MssFix > 0 {
+		p.parsedPrfl.MssFix = sprofile.FilterMtu(p.MssFix)
+	}
+	p.TunnelMtu = p.parsedPrfl.TunMtu
 	data := p.parsedPrfl.Export()
+	data += p.overrideOvpnRoutes()
+	data += p.summarizeOvpnConf()
 
 	if runtime.GOOS == "windows" {
 		p.managementPort = ManagementPortAcquire()
@@ -319,12 +442,8 @@ func (p *Profile) write(fixedRemote, fixedRemote6 string) (
 		)
 	}
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(data), os.FileMode(0600))
+	err = utils.CreateWriteExcl(pth, data, 0600)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write profile"),
-		}
 		return
 	}
 
@@ -380,12 +499,8 @@ func (p *Profile) writeUp() (pth string, err error) {
 		panic("profile: Not implemented")
 	}
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(script), os.FileMode(0755))
+	err = utils.CreateWriteExcl(pth, script, 0700)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write up script"),
-		}
 		return
 	}
 
@@ -438,12 +553,8 @@ func (p *Profile) writeDown() (pth string, err error) {
 		panic("profile: Not implemented")
 	}
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(script), os.FileMode(0755))
+	err = utils.CreateWriteExcl(pth, script, 0700)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write down script"),
-		}
 		return
 	}
 
@@ -465,12 +576,8 @@ func (p *Profile) writeBlock() (pth string, err error) {
 		script = blockScript
 	}
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(script), os.FileMode(0755))
+	err = utils.CreateWriteExcl(pth, script, 0700)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write block script"),
-		}
 		return
 	}
 
@@ -494,13 +601,8 @@ func (p *Profile) writeManagementPass() (pth string, err error) {
 		pth = filepath.Join(rootDir, p.Id+"-management")
 	}
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(p.managementPass),
-		os.FileMode(0600))
+	err = utils.CreateWriteExcl(pth, p.managementPass, 0600)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write management"),
-		}
 		return
 	}
 
@@ -514,7 +616,7 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 	}
 
 	username := p.Username
-	password := p.Password
+	password := p.authPassword()
 
 	if fwToken != "" {
 		var serverPubKey [32]byte
@@ -535,6 +637,7 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 			}
 			return
 		}
+		defer secret.Wipe(senderPrivKey[:])
 
 		var nonce [24]byte
 		nonceHash := sha256.Sum256(senderPubKey[:])
@@ -600,6 +703,7 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 			}
 			return
 		}
+		defer secret.Wipe(senderPrivKey[:])
 
 		var nonce [24]byte
 		nonceHash := sha256.Sum256(senderPubKey[:])
@@ -667,11 +771,14 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 			return
 		}
 
+		authDataBuf := secret.FromBytes(authDataJson)
+		defer authDataBuf.Destroy()
+
 		ciphertext, e := rsa.EncryptOAEP(
 			sha512.New(),
 			rand.Reader,
 			pub,
-			authDataJson,
+			authDataBuf.Bytes(),
 			[]byte{},
 		)
 		if e != nil {
@@ -688,13 +795,8 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 
 	pth = filepath.Join(rootDir, p.Id+".auth")
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(pth, []byte(username+"\n"+password+"\n"),
-		os.FileMode(0600))
+	err = utils.CreateWriteExcl(pth, username+"\n"+password+"\n", 0600)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write profile auth"),
-		}
 		return
 	}
 
@@ -702,6 +804,11 @@ func (p *Profile) writeAuth(fwToken string) (pth string, err error) {
 }
 
 func (p *Profile) generateWgKey() (err error) {
+	if wgUtunMode() {
+		p.PrivateKeyWg, p.PublicKeyWg, err = generateWgKeyPair()
+		return
+	}
+
 	privateKey, err := utils.ExecOutput(p.wgPath, "genkey")
 	if err != nil {
 		err = &ExecError{
@@ -732,26 +839,16 @@ func (p *Profile) writeConfWgLinux() (pth string, err error) {
 
 	pth = filepath.Join(rootDir, p.Id+".key")
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(
-		pth,
-		[]byte(p.PrivateKeyWg+"\n"),
-		os.FileMode(0600),
-	)
+	err = utils.CreateWriteExcl(pth, p.PrivateKeyWg+"\n", 0600)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write private key"),
-		}
 		return
 	}
 
 	return
 }
 
-func (p *Profile) writeConfWgQuick(data *WgConf) (pth, pth2 string,
-	err error) {
-
-	allowedIps := []string{}
+func (p *Profile) wgAllowedIps(data *WgConf) (allowedIps []string) {
+	allowedIps = []string{}
 	if data.Routes != nil {
 		for _, route := range data.Routes {
 			if (p.DisableGateway && route.Network == "0.0.0.0/0") ||
@@ -775,26 +872,37 @@ func (p *Profile) writeConfWgQuick(data *WgConf) (pth, pth2 string,
 		}
 	}
 
+	return
+}
+
+func (p *Profile) writeConfWgQuick(data *WgConf) (pth, pth2 string,
+	err error) {
+
+	allowedIps := p.wgAllowedIps(data)
+
 	addr := data.Address
 	if data.Address6 != "" {
 		addr += "," + data.Address6
 	}
 
+	endpoint := fmt.Sprintf("%s:%d", data.Hostname, data.Port)
+	if p.wgTcpRelay != nil {
+		endpoint = p.wgTcpRelay.LocalAddr()
+	}
+
 	templData := WgConfData{
 		Address:    addr,
 		PrivateKey: p.PrivateKeyWg,
 		PublicKey:  data.PublicKey,
 		AllowedIps: strings.Join(allowedIps, ","),
-		Endpoint:   fmt.Sprintf("%s:%d", data.Hostname, data.Port),
+		Endpoint:   endpoint,
+		Mtu:        p.getMtu(data.Hostname),
 	}
+	p.TunnelMtu = templData.Mtu
 
 	if !p.DisableDns && data.DnsServers != nil && len(data.DnsServers) > 0 {
 		if runtime.GOOS == "darwin" && config.Config.EnableWgDns {
-			err = utils.SetScutilDns(p.Id,
-				data.DnsServers, data.SearchDomains)
-			if err != nil {
-				return
-			}
+			p.scutilDns = true
 		} else {
 			templData.HasDns = true
 			templData.DnsServers = strings.Join(data.DnsServers, ",")
@@ -851,32 +959,16 @@ func (p *Profile) writeConfWgQuick(data *WgConf) (pth, pth2 string,
 
 	pth = filepath.Join(rootDir, p.Iface+".conf")
 
-	_ = os.Remove(pth)
-	err = ioutil.WriteFile(
-		pth,
-		[]byte(output.String()),
-		os.FileMode(0600),
-	)
+	err = utils.CreateWriteExcl(pth, output.String(), 0600)
 	if err != nil {
-		err = &WriteError{
-			errors.Wrap(err, "profile: Failed to write wg conf"),
-		}
 		return
 	}
 
 	if rootDir2 != "" {
 		pth2 = filepath.Join(rootDir2, p.Iface+".conf")
 
-		_ = os.Remove(pth2)
-		err = ioutil.WriteFile(
-			pth2,
-			[]byte(output.String()),
-			os.FileMode(0600),
-		)
+		err = utils.CreateWriteExcl(pth2, output.String(), 0600)
 		if err != nil {
-			err = &WriteError{
-				errors.Wrap(err, "profile: Failed to write wg conf2"),
-			}
 			return
 		}
 	}
@@ -900,9 +992,39 @@ func (p *Profile) writeWgConf(data *WgConf) (pth, pth2 string, err error) {
 }
 
 func (p *Profile) update() {
+	p.RemoteBreakers = GetRemoteBreakers(p.Id)
+
+	if p.Status != p.lastStatus {
+		stats.Record(p.Id, p.Mode, p.lastStatus, p.Status)
+
+		if p.Status == "connected" && !p.startTime.IsZero() {
+			p.connectLatency = time.Since(p.startTime)
+		}
+
+		if p.Status == "connected" && !p.auditConnected {
+			p.auditConnected = true
+			p.recordAudit(audit.Connect, "")
+		}
+
+		evt := event.Event{
+			Type:      "state",
+			Workspace: p.Workspace,
+			Data: &StateData{
+				Id:         p.Id,
+				Status:     p.Status,
+				PrevStatus: p.lastStatus,
+				Timestamp:  time.Now().Unix(),
+			},
+		}
+		evt.Init()
+
+		p.lastStatus = p.Status
+	}
+
 	evt := event.Event{
-		Type: "update",
-		Data: p,
+		Type:      "update",
+		Workspace: p.Workspace,
+		Data:      p,
 	}
 	evt.Init()
 
@@ -940,7 +1062,8 @@ func (p *Profile) pushOutput(output string) {
 	// TODO classic client
 	if p.SystemProfile == nil {
 		evt := &event.Event{
-			Type: "output",
+			Type:      "output",
+			Workspace: p.Workspace,
 			Data: &OutputData{
 				Id:     p.Id,
 				Output: output,
@@ -957,54 +1080,93 @@ func (p *Profile) pushOutput(output string) {
 		}).Error("profile: Failed to push profile log output")
 	}
 
+	tag := p.SyslogTag
+	if tag == "" {
+		tag = p.Name
+	}
+	logger.SyslogPush(p.Id, tag, output)
+
 	return
 }
 
-func (p *Profile) parseLine(line string) {
-	p.pushOutput(line)
+func (p *Profile) handleConnected() {
+	if p.stop {
+		p.StopBackground()
+		return
+	}
 
-	if strings.Contains(line, "Initialization Sequence Completed") {
-		if p.stop {
-			p.StopBackground()
-			return
-		}
+	resumed := p.Status == "reconnecting" && !p.resumeTime.IsZero()
+	p.resumeTime = time.Time{}
 
-		p.connected = true
-		p.Status = "connected"
+	p.connected = true
+	p.Status = "connected"
+	p.resetTraffic()
+	p.resetDnsFallback()
+	if !resumed {
 		p.Timestamp = time.Now().Unix() - 5
-		p.update()
+	}
+	p.update()
 
-		tokn := p.token
-		if tokn != nil {
-			tokn.Valid = true
-		}
+	tokn := p.token
+	if tokn != nil {
+		tokn.Valid = true
+	}
 
-		go func() {
-			defer func() {
-				panc := recover()
-				if panc != nil {
-					logrus.WithFields(logrus.Fields{
-						"stack": string(debug.Stack()),
-						"panic": panc,
-					}).Error("profile: Panic")
-					panic(panc)
-				}
-			}()
+	p.startRouteGuard()
+	p.startHostFailover()
+	p.startBandwidth()
 
-			utils.ClearDNSCache()
-		}()
+	p.setupNetwork()
+}
+
+func (p *Profile) parseLine(line string) {
+	p.pushOutput(line)
+
+	if strings.Contains(line, "Initialization Sequence Completed") {
+		p.handleConnected()
+	} else if isDuplicateLogin(line) {
+		p.duplicateLogin()
+	} else if strings.Contains(line, "process restarting") &&
+		strings.Contains(line, "soft") {
+
+		if p.PersistTun {
+			p.resumeStart()
+		}
+	} else if strings.Contains(line, "PUSH_REPLY") {
+		p.parseDnsOptions(line)
+		p.parseRoutes(line)
+	} else if strings.Contains(line, "TUN/TAP device") &&
+		strings.Contains(line, "opened") {
+
+		match := tunIfaceReg.FindStringSubmatch(line)
+		if match != nil && len(match) >= 2 {
+			p.Tuniface = match[1]
+		}
+	} else if strings.Contains(line, "Data Channel") {
+		match := dataCipherReg.FindStringSubmatch(line)
+		if match != nil && len(match) >= 2 && p.Cipher != match[1] {
+			p.Cipher = match[1]
+			p.update()
+		}
+	} else if isClockError(line) {
+		if !p.clockChecked {
+			p.clockChecked = true
+			go p.reportClockSkew()
+		}
 	} else if strings.Contains(line, "Inactivity timeout (--inactive)") {
 		evt := &event.Event{
-			Type: "inactive",
-			Data: p,
+			Type:      "inactive",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 		evt.Init()
 	} else if strings.Contains(line, "Inactivity timeout") ||
 		strings.Contains(line, "Connection reset") {
 
 		evt := &event.Event{
-			Type: "timeout_error",
-			Data: p,
+			Type:      "timeout_error",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 		evt.Init()
 	} else if strings.Contains(
@@ -1034,6 +1196,8 @@ func (p *Profile) parseLine(line string) {
 		p.stop = true
 		p.authFailed = true
 
+		credential.Clear(p.Id)
+
 		tokn := p.token
 		if tokn != nil {
 			tokn.Init()
@@ -1048,8 +1212,9 @@ func (p *Profile) parseLine(line string) {
 			}
 
 			evt := &event.Event{
-				Type: "auth_error",
-				Data: p,
+				Type:      "auth_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 
@@ -1174,11 +1339,17 @@ func (p *Profile) clearWgLinux() {
 			"down", p.Iface,
 		)
 		p.wgQuickLock.Unlock()
+		netclean.ReleaseIface(p.Iface)
 		network.InterfaceRelease(p.Iface)
 	}
 }
 
 func (p *Profile) clearWgMac() {
+	if p.wgUtun != nil {
+		p.clearWgUtun()
+		return
+	}
+
 	if p.Iface != "" {
 		p.wgQuickLock.Lock()
 		utils.ExecCombinedOutputLogged(
@@ -1210,6 +1381,8 @@ func (p *Profile) clearWgWin() {
 }
 
 func (p *Profile) clearWg() {
+	p.stopWgTcp()
+
 	switch runtime.GOOS {
 	case "linux":
 		p.clearWgLinux()
@@ -1232,12 +1405,16 @@ func (p *Profile) clearOvpn() {
 		time.Sleep(500 * time.Millisecond)
 	}
 
+	p.stopProxyRelay()
+
 	return
 }
 
 func (p *Profile) Copy() (prfl *Profile) {
 	prfl = &Profile{
 		Id:                 p.Id,
+		Name:               p.Name,
+		Workspace:          p.Workspace,
 		Mode:               p.Mode,
 		OrgId:              p.OrgId,
 		UserId:             p.UserId,
@@ -1257,8 +1434,37 @@ func (p *Profile) Copy() (prfl *Profile) {
 		ServerPublicKey:    p.ServerPublicKey,
 		ServerBoxPublicKey: p.ServerBoxPublicKey,
 		Reconnect:          p.Reconnect,
+		SplitDnsDomains:    p.SplitDnsDomains,
+		Env:                p.Env,
+		Hooks:              p.Hooks,
+		CredentialProvider: p.CredentialProvider,
+		CredentialCacheTtl: p.CredentialCacheTtl,
+		RoutePriority:      p.RoutePriority,
+		IncludeRoutes:      p.IncludeRoutes,
+		ExcludeRoutes:      p.ExcludeRoutes,
+		PersistTun:         p.PersistTun,
+		HttpProxy:          p.HttpProxy,
+		SocksProxy:         p.SocksProxy,
+		AlwaysOn:           p.AlwaysOn,
+		KillSwitch:         p.KillSwitch,
+		CredentialId:       p.CredentialId,
+		HostFailover:       p.HostFailover,
+		Verbosity:          p.Verbosity,
+		SplitDnsPushed:     p.SplitDnsPushed,
+		DnsHijackMode:      p.DnsHijackMode,
+		DisableIpv6:        p.DisableIpv6,
+		SyslogTag:          p.SyslogTag,
+		WgTcpFallback:      p.WgTcpFallback,
+		Mtu:                p.Mtu,
+		MssFix:             p.MssFix,
+		MtuProbe:           p.MtuProbe,
+		SummarizeRoutes:    p.SummarizeRoutes,
+		ExpiresAt:          p.ExpiresAt,
 		SystemProfile:      p.SystemProfile,
 		connected:          p.connected,
+		wgTcp:              p.wgTcpRetry,
+		preferredRemote:    p.preferredRemote,
+		failoverTime:       p.failoverTime,
 	}
 	prfl.Init()
 
@@ -1267,6 +1473,7 @@ func (p *Profile) Copy() (prfl *Profile) {
 
 func (p *Profile) Init() {
 	p.Id = utils.FilterStr(p.Id)
+	p.SplitDnsDomains = utils.FilterDomains(p.SplitDnsDomains)
 	p.waiters = []chan bool{}
 	p.bashPath = GetBashPath()
 	p.wgPath = GetWgPath()
@@ -1294,6 +1501,10 @@ func (p *Profile) Start(timeout, delay, automatic bool) (err error) {
 	stateLock.Unlock()
 
 	Profiles.RLock()
+	first := len(Profiles.m) == 0
+	if first {
+		p.probeDnsHijack()
+	}
 	if runtime.GOOS == "darwin" && len(Profiles.m) == 0 {
 		err = utils.ClearScutilConnKeys()
 		if err != nil {
@@ -1304,7 +1515,7 @@ func (p *Profile) Start(timeout, delay, automatic bool) (err error) {
 			err = nil
 		}
 		if DnsForced {
-			utils.ClearDns()
+			netconf.Get().ClearDns()
 		}
 	}
 
@@ -1314,6 +1525,12 @@ func (p *Profile) Start(timeout, delay, automatic bool) (err error) {
 		return
 	}
 
+	// Full tunnel profiles replace the default route, snapshot the
+	// network before the first profile changes it
+	if first && !p.DisableGateway {
+		_, _ = netsnap.Capture(netsnap.ReasonConnect)
+	}
+
 	logrus.WithFields(logrus.Fields{
 		"profile_id":       p.Id,
 		"mode":             p.Mode,
@@ -1349,6 +1566,8 @@ func (p *Profile) Start(timeout, delay, automatic bool) (err error) {
 		}
 	}
 
+	p.applyFeatures()
+
 	if delay {
 		time.Sleep(3 * time.Second)
 		if p.stop {
@@ -1357,6 +1576,51 @@ func (p *Profile) Start(timeout, delay, automatic bool) (err error) {
 		}
 	}
 
+	failures := p.precheck()
+	if len(failures) > 0 {
+		p.precheckFailures = failures
+		err = precheckError(failures)
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Connection precondition failed")
+
+		evt := &event.Event{
+			Type:      "precondition_error",
+			Workspace: p.Workspace,
+			Data: &PrecheckData{
+				Id:       p.Id,
+				Failures: failures,
+			},
+		}
+		evt.Init()
+
+		p.stopSafe()
+		return
+	}
+
+	p.precheckNat()
+
+	err = p.loadCredentials()
+	if err != nil {
+		evt := &event.Event{
+			Type:      "credential_error",
+			Workspace: p.Workspace,
+			Data:      p,
+		}
+		evt.Init()
+
+		p.stopSafe()
+		return
+	}
+
+	p.runHooks(HookConnect, p.hookEnv(HookConnect))
+	if p.stop {
+		p.stopSafe()
+		return
+	}
+
 	if p.Mode == Wg {
 		err = p.startWg(timeout)
 	} else {
@@ -1407,8 +1671,9 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 				}
 
 				evt := &event.Event{
-					Type: "registration_required",
-					Data: p,
+					Type:      "registration_required",
+					Workspace: p.Workspace,
+					Data:      p,
 				}
 				evt.Init()
 			} else {
@@ -1416,9 +1681,12 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 					"reason": data.Reason,
 				}).Error("profile: Failed to authenticate ovpn")
 
+				p.recordRemoteAuthFailure(p.reqRemote)
+
 				evt := &event.Event{
-					Type: "auth_error",
-					Data: p,
+					Type:      "auth_error",
+					Workspace: p.Workspace,
+					Data:      p,
 				}
 				evt.Init()
 			}
@@ -1436,8 +1704,9 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 				}
 			} else {
 				evt := &event.Event{
-					Type: "registration_pass",
-					Data: p,
+					Type:      "registration_pass",
+					Workspace: p.Workspace,
+					Data:      p,
 				}
 				evt.Init()
 			}
@@ -1488,7 +1757,7 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 	var authPath string
 	tokn := token.Get(p.Id, p.ServerPublicKey, p.ServerBoxPublicKey)
 
-	if (p.Username != "" && p.Password != "") ||
+	if (p.Username != "" && p.authPassword() != "") ||
 		p.parsedPrfl.AuthUserPass ||
 		tokn != nil || fwToken != "" {
 
@@ -1508,7 +1777,20 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 
 	args := []string{
 		"--config", confPath,
-		"--verb", "2",
+		"--verb", strconv.Itoa(p.getVerbosity()),
+	}
+
+	statusPath, err := p.getStatusPath()
+	if err != nil {
+		return
+	}
+	p.statusPath = statusPath
+	p.remPaths = append(p.remPaths, statusPath)
+
+	args = append(args, "--status", statusPath, "10")
+
+	if p.PersistTun {
+		args = append(args, "--persist-tun")
 	}
 
 	if p.stop {
@@ -1606,6 +1888,12 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 		args = append(args, "--auth-user-pass", authPath)
 	}
 
+	proxyArgs, err := p.proxyArgs()
+	if err != nil {
+		return
+	}
+	args = append(args, proxyArgs...)
+
 	if p.stop {
 		p.stopSafe()
 		return
@@ -1613,9 +1901,12 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 
 	cmd := command.Command(getOpenvpnPath(), args...)
 	cmd.Dir = getOpenvpnDir()
+	cmd.Env = append(os.Environ(), p.getEnv()...)
 	p.cmd = cmd
 
-	stdout, err := cmd.StdoutPipe()
+	// The read ends are handed to the new image on upgrade, pipes from
+	// StdoutPipe are closed when the command exits
+	stdout, stdoutWrite, err := os.Pipe()
 	if err != nil {
 		err = &ExecError{
 			errors.Wrap(err, "profile: Failed to get stdout"),
@@ -1623,133 +1914,37 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 		return
 	}
 
-	stderr, err := cmd.StderrPipe()
+	stderr, stderrWrite, err := os.Pipe()
 	if err != nil {
+		_ = stdout.Close()
+		_ = stdoutWrite.Close()
 		err = &ExecError{
 			errors.Wrap(err, "profile: Failed to get stderr"),
 		}
 		return
 	}
 
+	cmd.Stdout = stdoutWrite
+	cmd.Stderr = stderrWrite
+
 	if p.stop {
+		_ = stdout.Close()
+		_ = stdoutWrite.Close()
+		_ = stderr.Close()
+		_ = stderrWrite.Close()
 		p.stopSafe()
 		return
 	}
 
-	output := make(chan string, 100)
-	outputWait := sync.WaitGroup{}
-	outputWait.Add(1)
-
-	go func() {
-		defer func() {
-			panc := recover()
-			if panc != nil {
-				logrus.WithFields(logrus.Fields{
-					"stack": string(debug.Stack()),
-					"panic": panc,
-				}).Error("profile: Panic")
-				panic(panc)
-			}
-		}()
-
-		defer func() {
-			_ = stdout.Close()
-			output <- ""
-		}()
-
-		out := bufio.NewReader(stdout)
-		for {
-			line, _, err := out.ReadLine()
-			if err != nil {
-				if err != io.EOF &&
-					!strings.Contains(err.Error(), "file already closed") &&
-					!strings.Contains(err.Error(), "bad file descriptor") {
-
-					err = &errortypes.ReadError{
-						errors.Wrap(err, "profile: Failed to read stdout"),
-					}
-					logrus.WithFields(logrus.Fields{
-						"error": err,
-					}).Error("profile: Stdout error")
-				}
-
-				return
-			}
-
-			lineStr := string(line)
-			if lineStr != "" {
-				output <- lineStr
-			}
-		}
-	}()
-
-	go func() {
-		defer func() {
-			panc := recover()
-			if panc != nil {
-				logrus.WithFields(logrus.Fields{
-					"stack": string(debug.Stack()),
-					"panic": panc,
-				}).Error("profile: Panic")
-				panic(panc)
-			}
-		}()
-
-		defer stderr.Close()
-
-		out := bufio.NewReader(stderr)
-		for {
-			line, _, err := out.ReadLine()
-			if err != nil {
-				if err != io.EOF &&
-					!strings.Contains(err.Error(), "file already closed") &&
-					!strings.Contains(err.Error(), "bad file descriptor") {
-
-					err = &errortypes.ReadError{
-						errors.Wrap(err, "profile: Failed to read stderr"),
-					}
-					logrus.WithFields(logrus.Fields{
-						"error": err,
-					}).Error("profile: Stderr error")
-				}
-
-				return
-			}
-
-			lineStr := string(line)
-			if lineStr != "" {
-				output <- lineStr
-			}
-		}
-	}()
-
-	go func() {
-		defer func() {
-			panc := recover()
-			if panc != nil {
-				logrus.WithFields(logrus.Fields{
-					"stack": string(debug.Stack()),
-					"panic": panc,
-				}).Error("profile: Panic")
-				panic(panc)
-			}
-		}()
-
-		defer outputWait.Done()
-
-		for {
-			line := <-output
-			if line == "" {
-				return
-			}
-
-			p.parseLine(line)
-		}
-	}()
+	p.stdout = stdout
+	p.stderr = stderr
+	outputWait := p.readOutput(stdout, stderr)
 
 	startTime := time.Now()
 
 	err = cmd.Start()
+	_ = stdoutWrite.Close()
+	_ = stderrWrite.Close()
 	if err != nil {
 		err = &ExecError{
 			errors.Wrap(err, "profile: Failed to start openvpn"),
@@ -1758,6 +1953,7 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 	}
 
 	running := true
+	waitDone := p.trackRoutine("ovpn_wait")
 	go func() {
 		defer func() {
 			panc := recover()
@@ -1770,7 +1966,12 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 			}
 		}()
 
+		defer waitDone()
+
 		cmd.Wait()
+		// Scripts started by openvpn can keep the write ends open
+		_ = stdout.Close()
+		_ = stderr.Close()
 		outputWait.Wait()
 		running = false
 
@@ -1858,8 +2059,9 @@ func (p *Profile) startOvpn(timeout bool) (err error) {
 				}
 
 				evt := &event.Event{
-					Type: "timeout_error",
-					Data: p,
+					Type:      "timeout_error",
+					Workspace: p.Workspace,
+					Data:      p,
 				}
 				evt.Init()
 			}
@@ -1968,13 +2170,14 @@ func (p *Profile) openOvpn() (data *OvpnData, err error) {
 
 	var evt *event.Event
 	final := false
-	for _, i := range mathrand.Perm(len(syncRemotes)) {
-		remote := syncRemotes[i]
-
+	for _, remote := range p.orderRemotes(syncRemotes) {
 		data, final, evt, err = p.reqOvpn(remote, "", time.Time{})
 		if err == nil || final {
+			p.reqRemote = remote
+			p.recordRemoteSuccess(remote)
 			break
 		}
+		p.recordRemoteFailure(remote, err)
 
 		logrus.WithFields(logrus.Fields{
 			"error": err,
@@ -1987,13 +2190,14 @@ func (p *Profile) openOvpn() (data *OvpnData, err error) {
 	}
 
 	if err != nil {
-		for _, i := range mathrand.Perm(len(remotes)) {
-			remote := remotes[i]
-
+		for _, remote := range p.orderRemotes(remotes) {
 			data, final, evt, err = p.reqOvpn(remote, "", time.Time{})
 			if err == nil || final {
+				p.reqRemote = remote
+				p.recordRemoteSuccess(remote)
 				break
 			}
+			p.recordRemoteFailure(remote, err)
 
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -2013,8 +2217,9 @@ func (p *Profile) openOvpn() (data *OvpnData, err error) {
 			evt.Init()
 		} else {
 			evt = &event.Event{
-				Type: "connection_error",
-				Data: p,
+				Type:      "connection_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 		}
@@ -2129,16 +2334,18 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 	ovpnBox := &OvpnKeyBox{
 		DeviceId:       p.DeviceId,
 		DeviceName:     p.DeviceName,
+		ClientDeviceId: config.Config.DeviceId,
 		Platform:       pltfrm,
 		MacAddr:        p.MacAddr,
 		MacAddrs:       p.MacAddrs,
 		Token:          authToken,
 		Nonce:          tokenNonce,
-		Password:       p.Password,
+		Password:       p.authPassword(),
 		Timestamp:      time.Now().Unix(),
 		PublicAddress:  addr4,
 		PublicAddress6: addr6,
 		SsoToken:       ssoToken,
+		Posture:        posture.Get(),
 	}
 
 	var tp tpm.TpmCaller
@@ -2184,6 +2391,8 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 		}
 		return
 	}
+	defer secret.Wipe(senderPrivKey[:])
+
 	senderPubKey64 := base64.StdEncoding.EncodeToString(senderPubKey[:])
 
 	var nonce [24]byte
@@ -2216,6 +2425,7 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 		}
 		return
 	}
+	defer secret.Wipe(userPrivKeyBlock.Bytes)
 
 	userPrivKey, err := x509.ParsePKCS1PrivateKey(userPrivKeyBlock.Bytes)
 	if err != nil {
@@ -2319,6 +2529,7 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 
 	req.Header.Set("User-Agent", "pritunl-client")
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Client-Device-Id", config.Config.DeviceId)
 
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	authNonce, err := utils.RandStr(32)
@@ -2355,6 +2566,12 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 	req.Header.Set("Auth-Nonce", authNonce)
 	req.Header.Set("Auth-Signature", sig)
 
+	req, err = p.proxyRequest(req)
+	if err != nil {
+		cancel()
+		return
+	}
+
 	p.openReqCancel = cancel
 	res, err := clientConnInsecure.Do(req)
 	if err != nil {
@@ -2369,8 +2586,9 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 	if res.StatusCode == 428 && ssoToken != "" {
 		if time.Since(ssoStart) > 120*time.Second {
 			evt = &event.Event{
-				Type: "timeout_error",
-				Data: p,
+				Type:      "timeout_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 
 			err = &errortypes.RequestError{
@@ -2390,8 +2608,9 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 
 	if res.StatusCode == 429 {
 		evt = &event.Event{
-			Type: "offline_error",
-			Data: p,
+			Type:      "offline_error",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 
 		err = &errortypes.RequestError{
@@ -2421,7 +2640,8 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 
 	if ovpnResp.SsoUrl != "" && ovpnResp.SsoToken != "" && ssoToken == "" {
 		evt2 := event.Event{
-			Type: "sso_auth",
+			Type:      "sso_auth",
+			Workspace: p.Workspace,
 			Data: &SsoEventData{
 				Id:  p.Id,
 				Url: ovpnResp.SsoUrl,
@@ -2493,8 +2713,11 @@ func (p *Profile) reqOvpn(remote, ssoToken string, ssoStart time.Time) (
 		return
 	}
 
+	plaintext := secret.FromBytes(respPlaintext)
+	defer plaintext.Destroy()
+
 	ovpnData = &OvpnData{}
-	err = json.Unmarshal(respPlaintext, ovpnData)
+	err = json.Unmarshal(plaintext.Bytes(), ovpnData)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.New("profile: Failed to parse response"),
@@ -2601,17 +2824,19 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 	wgBox := &WgKeyBox{
 		DeviceId:       p.DeviceId,
 		DeviceName:     p.DeviceName,
+		ClientDeviceId: config.Config.DeviceId,
 		Platform:       pltfrm,
 		MacAddr:        p.MacAddr,
 		MacAddrs:       p.MacAddrs,
 		Token:          authToken,
 		Nonce:          tokenNonce,
-		Password:       p.Password,
+		Password:       p.authPassword(),
 		Timestamp:      time.Now().Unix(),
 		PublicAddress:  addr4,
 		PublicAddress6: addr6,
 		WgPublicKey:    p.PublicKeyWg,
 		SsoToken:       ssoToken,
+		Posture:        posture.Get(),
 	}
 
 	var tp tpm.TpmCaller
@@ -2657,6 +2882,8 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 		}
 		return
 	}
+	defer secret.Wipe(senderPrivKey[:])
+
 	senderPubKey64 := base64.StdEncoding.EncodeToString(senderPubKey[:])
 
 	var nonce [24]byte
@@ -2689,6 +2916,7 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 		}
 		return
 	}
+	defer secret.Wipe(userPrivKeyBlock.Bytes)
 
 	userPrivKey, err := x509.ParsePKCS1PrivateKey(userPrivKeyBlock.Bytes)
 	if err != nil {
@@ -2792,6 +3020,7 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 
 	req.Header.Set("User-Agent", "pritunl-client")
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Client-Device-Id", config.Config.DeviceId)
 
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	authNonce, err := utils.RandStr(32)
@@ -2828,6 +3057,12 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 	req.Header.Set("Auth-Nonce", authNonce)
 	req.Header.Set("Auth-Signature", sig)
 
+	req, err = p.proxyRequest(req)
+	if err != nil {
+		cancel()
+		return
+	}
+
 	p.openReqCancel = cancel
 	res, err := clientConnInsecure.Do(req)
 	if err != nil {
@@ -2842,8 +3077,9 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 	if res.StatusCode == 428 && ssoToken != "" {
 		if time.Since(ssoStart) > 60*time.Second {
 			evt = &event.Event{
-				Type: "timeout_error",
-				Data: p,
+				Type:      "timeout_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 
 			err = &errortypes.RequestError{
@@ -2863,8 +3099,9 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 
 	if res.StatusCode == 429 {
 		evt = &event.Event{
-			Type: "offline_error",
-			Data: p,
+			Type:      "offline_error",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 
 		err = &errortypes.RequestError{
@@ -2894,7 +3131,8 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 
 	if wgResp.SsoUrl != "" && wgResp.SsoToken != "" && ssoToken == "" {
 		evt2 := &event.Event{
-			Type: "sso_auth",
+			Type:      "sso_auth",
+			Workspace: p.Workspace,
 			Data: &SsoEventData{
 				Id:  p.Id,
 				Url: wgResp.SsoUrl,
@@ -2966,8 +3204,11 @@ func (p *Profile) reqWg(remote, ssoToken string, ssoStart time.Time) (
 		return
 	}
 
+	plaintext := secret.FromBytes(respPlaintext)
+	defer plaintext.Destroy()
+
 	wgData = &WgData{}
-	err = json.Unmarshal(respPlaintext, wgData)
+	err = json.Unmarshal(plaintext.Bytes(), wgData)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.New("profile: Failed to parse response"),
@@ -3016,13 +3257,14 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 	}
 
 	wgBox := &WgKeyBox{
-		DeviceId:    p.DeviceId,
-		DeviceName:  p.DeviceName,
-		Platform:    platform,
-		MacAddr:     p.MacAddr,
-		MacAddrs:    p.MacAddrs,
-		Timestamp:   time.Now().Unix(),
-		WgPublicKey: p.PublicKeyWg,
+		DeviceId:       p.DeviceId,
+		DeviceName:     p.DeviceName,
+		ClientDeviceId: config.Config.DeviceId,
+		Platform:       platform,
+		MacAddr:        p.MacAddr,
+		MacAddrs:       p.MacAddrs,
+		Timestamp:      time.Now().Unix(),
+		WgPublicKey:    p.PublicKeyWg,
 	}
 
 	wgBoxData, err := json.Marshal(wgBox)
@@ -3040,6 +3282,8 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 		}
 		return
 	}
+	defer secret.Wipe(senderPrivKey[:])
+
 	senderPubKey64 := base64.StdEncoding.EncodeToString(senderPubKey[:])
 
 	var nonce [24]byte
@@ -3072,6 +3316,7 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 		}
 		return
 	}
+	defer secret.Wipe(userPrivKeyBlock.Bytes)
 
 	userPrivKey, err := x509.ParsePKCS1PrivateKey(userPrivKeyBlock.Bytes)
 	if err != nil {
@@ -3162,6 +3407,7 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 
 	req.Header.Set("User-Agent", "pritunl-client")
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Client-Device-Id", config.Config.DeviceId)
 
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	authNonce, err := utils.RandStr(32)
@@ -3191,6 +3437,11 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 	req.Header.Set("Auth-Nonce", authNonce)
 	req.Header.Set("Auth-Signature", sig)
 
+	req, err = p.proxyRequest(req)
+	if err != nil {
+		return
+	}
+
 	res, err := clientInsecure.Do(req)
 	if err != nil {
 		retry = true
@@ -3261,8 +3512,11 @@ func (p *Profile) pingWg() (wgData *WgPingData, retry bool, err error) {
 		return
 	}
 
+	plaintext := secret.FromBytes(respPlaintext)
+	defer plaintext.Destroy()
+
 	wgData = &WgPingData{}
-	err = json.Unmarshal(respPlaintext, wgData)
+	err = json.Unmarshal(plaintext.Bytes(), wgData)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.New("profile: Failed to parse response"),
@@ -3350,79 +3604,22 @@ func (p *Profile) confWgLinux(data *WgConf) (err error) {
 		return
 	}
 
+	routeLock.Lock()
+	defer routeLock.Unlock()
+
 	if data.Routes != nil {
 		p.Routes = data.Routes
-		for _, route := range data.Routes {
-			if route.NetGateway {
-
-			} else {
-				if route.Metric != 0 {
-					_, err = utils.ExecCombinedOutputLogged(
-						[]string{
-							"File exists",
-						},
-						"ip", "route",
-						"add", route.Network,
-						"via", route.NextHop,
-						"metric", strconv.Itoa(route.Metric),
-						"dev", p.Iface,
-					)
-					if err != nil {
-						return
-					}
-				} else {
-					_, err = utils.ExecCombinedOutputLogged(
-						[]string{
-							"File exists",
-						},
-						"ip", "route",
-						"add", route.Network,
-						"via", route.NextHop,
-						"dev", p.Iface,
-					)
-					if err != nil {
-						return
-					}
-				}
-			}
+		err = p.addWgRoutes(data.Routes)
+		if err != nil {
+			return
 		}
 	}
 
 	if data.Routes6 != nil {
 		p.Routes6 = data.Routes6
-		for _, route := range data.Routes6 {
-			if route.NetGateway {
-
-			} else {
-				if route.Metric != 0 {
-					_, err = utils.ExecCombinedOutputLogged(
-						[]string{
-							"File exists",
-						},
-						"ip", "-6", "route",
-						"add", route.Network,
-						"via", route.NextHop,
-						"metric", strconv.Itoa(route.Metric),
-						"dev", p.Iface,
-					)
-					if err != nil {
-						return
-					}
-				} else {
-					_, err = utils.ExecCombinedOutputLogged(
-						[]string{
-							"File exists",
-						},
-						"ip", "-6", "route",
-						"add", route.Network,
-						"via", route.NextHop,
-						"dev", p.Iface,
-					)
-					if err != nil {
-						return
-					}
-				}
-			}
+		err = p.addWgRoutes(data.Routes6)
+		if err != nil {
+			return
 		}
 	}
 
@@ -3433,6 +3630,20 @@ func (p *Profile) sendManagementCommand(cmd string) (err error) {
 	p.managementLock.Lock()
 	defer p.managementLock.Unlock()
 
+	// OpenVPN accepts one management client, adopted profiles hold the
+	// connection open to receive the log
+	monConn := p.managementConn
+	if monConn != nil {
+		_, err = monConn.Write([]byte(fmt.Sprintf("%s\n", cmd)))
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "profile: Failed to write socket command"),
+			}
+			return
+		}
+		return
+	}
+
 	conn, err := net.DialTimeout(
 		"tcp",
 		fmt.Sprintf("127.0.0.1:%d", p.managementPort),
@@ -3486,6 +3697,11 @@ func (p *Profile) sendManagementCommand(cmd string) (err error) {
 }
 
 func (p *Profile) confWgLinuxQuick() (err error) {
+	err = configureWgMode()
+	if err != nil {
+		return
+	}
+
 	p.wgQuickLock.Lock()
 	defer p.wgQuickLock.Unlock()
 
@@ -3514,6 +3730,16 @@ func (p *Profile) confWgLinuxQuick() (err error) {
 		return
 	}
 
+	netclean.TrackIface(p.Iface)
+
+	err = checkWgUserspace(p.Iface)
+	if err != nil {
+		_, _ = utils.ExecCombinedOutput(
+			p.wgQuickPath, "down", p.Iface,
+		)
+		return
+	}
+
 	return
 }
 
@@ -3616,15 +3842,25 @@ func (p *Profile) confWgWin() (err error) {
 func (p *Profile) confWg(data *WgConf) (err error) {
 	p.ClientAddr = data.Address
 	p.ServerAddr = data.Hostname
+	p.Cipher = "CHACHA20-POLY1305"
 	p.GatewayAddr = data.Gateway
 	p.GatewayAddr6 = data.Gateway6
 	p.WebPort = data.WebPort
 	p.WebNoSsl = data.WebNoSsl
 	p.wgServerPublicKey = data.PublicKey
+	p.dnsServers = p.filterDnsServers(data.DnsServers)
+	p.dnsServersPushed = p.dnsServers
+	p.dnsBenchmarks = nil
+	p.dnsDomains = p.filterDnsDomains(data.SearchDomains)
+	p.parseWgRoutes(append(data.Routes, data.Routes6...))
 
 	switch runtime.GOOS {
 	case "darwin":
-		err = p.confWgMac()
+		if wgUtunMode() {
+			err = p.confWgUtun(data)
+		} else {
+			err = p.confWgMac()
+		}
 		break
 	case "windows":
 		err = p.confWgWin()
@@ -3643,6 +3879,11 @@ func (p *Profile) confWg(data *WgConf) (err error) {
 }
 
 func (p *Profile) updateWgHandshake() (err error) {
+	if p.wgUtun != nil {
+		err = p.updateWgUtunHandshake()
+		return
+	}
+
 	iface := ""
 	if runtime.GOOS == "darwin" {
 		iface = p.Tuniface
@@ -3696,6 +3937,7 @@ func (p *Profile) watchWg() {
 	}()
 
 	defer p.stopSafe()
+	defer p.trackRoutine("wg_watch")()
 
 	time.Sleep(1 * time.Second)
 
@@ -3741,15 +3983,38 @@ func (p *Profile) watchWg() {
 		}
 
 		evt := &event.Event{
-			Type: "handshake_timeout",
-			Data: p,
+			Type:      "handshake_timeout",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 		evt.Init()
 
+		// The tcp transport is only used for the connection after the
+		// failed handshake and only when the server provides it
+		if p.WgTcpFallback && !p.wgTcp && p.wgTcpPort != 0 {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+			}).Warn("profile: WireGuard udp handshake failed, " +
+				"falling back to tcp transport")
+
+			p.wgTcpRetry = true
+
+			evt := &event.Event{
+				Type:      "wg_tcp_fallback",
+				Workspace: p.Workspace,
+				Data:      p,
+			}
+			evt.Init()
+		}
+
 		p.restartSafe()
 		return
 	}
 
+	p.keepaliveWg()
+}
+
+func (p *Profile) keepaliveWg() {
 	for {
 		for i := 0; i < 10; i++ {
 			if p.stop {
@@ -3759,6 +4024,8 @@ func (p *Profile) watchWg() {
 			time.Sleep(1 * time.Second)
 		}
 
+		_ = p.updateWgTransfer()
+
 		var data *WgPingData
 		var retry bool
 		var err error
@@ -3907,13 +4174,14 @@ func (p *Profile) startWg(timeout bool) (err error) {
 	final := false
 	var data *WgData
 
-	for _, i := range mathrand.Perm(len(syncRemotes)) {
-		remote := syncRemotes[i]
-
+	for _, remote := range p.orderRemotes(syncRemotes) {
 		data, final, evt, err = p.reqWg(remote, "", time.Time{})
 		if err == nil || final {
+			p.reqRemote = remote
+			p.recordRemoteSuccess(remote)
 			break
 		}
+		p.recordRemoteFailure(remote, err)
 
 		if p.stop {
 			p.stopSafe()
@@ -3922,13 +4190,14 @@ func (p *Profile) startWg(timeout bool) (err error) {
 	}
 
 	if err != nil {
-		for _, i := range mathrand.Perm(len(remotes)) {
-			remote := remotes[i]
-
+		for _, remote := range p.orderRemotes(remotes) {
 			data, final, evt, err = p.reqWg(remote, "", time.Time{})
 			if err == nil || final {
+				p.reqRemote = remote
+				p.recordRemoteSuccess(remote)
 				break
 			}
+			p.recordRemoteFailure(remote, err)
 
 			if p.stop {
 				p.stopSafe()
@@ -3942,8 +4211,9 @@ func (p *Profile) startWg(timeout bool) (err error) {
 			evt.Init()
 		} else {
 			evt = &event.Event{
-				Type: "connection_error",
-				Data: p,
+				Type:      "connection_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 		}
@@ -4000,8 +4270,9 @@ func (p *Profile) startWg(timeout bool) (err error) {
 			}
 
 			evt := &event.Event{
-				Type: "registration_required",
-				Data: p,
+				Type:      "registration_required",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 		} else {
@@ -4009,9 +4280,12 @@ func (p *Profile) startWg(timeout bool) (err error) {
 				"reason": data.Reason,
 			}).Error("profile: Failed to authenticate ovpn")
 
+			p.recordRemoteAuthFailure(p.reqRemote)
+
 			evt := &event.Event{
-				Type: "auth_error",
-				Data: p,
+				Type:      "auth_error",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 
@@ -4045,8 +4319,9 @@ func (p *Profile) startWg(timeout bool) (err error) {
 			}
 		} else {
 			evt := &event.Event{
-				Type: "registration_pass",
-				Data: p,
+				Type:      "registration_pass",
+				Workspace: p.Workspace,
+				Data:      p,
 			}
 			evt.Init()
 		}
@@ -4091,21 +4366,57 @@ func (p *Profile) startWg(timeout bool) (err error) {
 		data.Configuration.Routes6 = routes6
 	}
 
-	wgConfPth, wgConfPth2, err := p.writeWgConf(data.Configuration)
-	if err != nil {
-		return
+	endpointRoute := p.overrideWgRoutes(data.Configuration)
+	p.summarizeWgRoutes(data.Configuration)
+
+	if endpointRoute {
+		err = p.routeWgEndpoint(data.Configuration)
+		if err != nil {
+			return
+		}
 	}
-	p.remPaths = append(p.remPaths, wgConfPth)
-	if wgConfPth2 != "" {
-		p.remPaths = append(p.remPaths, wgConfPth2)
+
+	p.wgTcpPort = data.Configuration.TcpPort
+	if p.wgTcp && p.wgTcpPort == 0 {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+		}).Warn("profile: Server does not provide wg tcp transport, " +
+			"using udp")
+		p.wgTcp = false
+	}
+
+	if p.wgTcp {
+		err = p.startWgTcp(data.Configuration)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"error":      err,
+			}).Error("profile: Failed to start wg tcp transport, using udp")
+			err = nil
+			p.wgTcp = false
+		}
+	}
+
+	// The utun mode configures the device without a configuration file
+	if !wgUtunMode() {
+		wgConfPth, wgConfPth2, e := p.writeWgConf(data.Configuration)
+		if e != nil {
+			err = e
+			return
+		}
+		p.remPaths = append(p.remPaths, wgConfPth)
+		if wgConfPth2 != "" {
+			p.remPaths = append(p.remPaths, wgConfPth2)
+		}
+		p.wgConfPth = wgConfPth
 	}
-	p.wgConfPth = wgConfPth
 
 	err = p.confWg(data.Configuration)
 	if err != nil {
 		evt := &event.Event{
-			Type: "configuration_error",
-			Data: p,
+			Type:      "configuration_error",
+			Workspace: p.Workspace,
+			Data:      p,
 		}
 		evt.Init()
 
@@ -4122,7 +4433,12 @@ func (p *Profile) startWg(timeout bool) (err error) {
 		tokn.Valid = true
 	}
 
+	p.setupNetwork()
+
 	go p.watchWg()
+	p.startRouteGuard()
+	p.startHostFailover()
+	p.startBandwidth()
 
 	return
 }
@@ -4296,6 +4612,8 @@ func (p *Profile) Restart() {
 		"profile_id": p.Id,
 	}).Info("profile: Reconnecting")
 
+	p.runHooksAsync(HookReconnect, p.hookEnv(HookReconnect))
+
 	p.Status = "reconnecting"
 	p.update()
 
@@ -4330,11 +4648,16 @@ func (p *Profile) Restart() {
 		ManagementPortRelease(p.managementPort)
 	}
 
+	p.releaseOverlaps()
+	p.clearExclusions()
+	p.clearDnsRoutes()
+	p.clearSplitDns()
+	p.restoreIpv6()
 	p.clearWg()
 	p.clearOvpn()
 
 	for _, path := range p.remPaths {
-		os.Remove(path)
+		_ = utils.Shred(path)
 	}
 
 	Profiles.Lock()
@@ -4353,7 +4676,7 @@ func (p *Profile) Restart() {
 			err = nil
 		}
 		if DnsForced {
-			utils.ClearDns()
+			netconf.Get().ClearDns()
 		}
 	}
 	Profiles.Unlock()
@@ -4370,7 +4693,13 @@ func (p *Profile) Restart() {
 	p.waiters = []chan bool{}
 	stateLock.Unlock()
 
+	p.checkRoutines()
+
 	go func() {
+		if !prflCopy.waitPortal() {
+			return
+		}
+
 		err = prflCopy.Start(false, false, true)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
@@ -4437,6 +4766,9 @@ func (p *Profile) Stop() {
 		time.Sleep(1 * time.Second)
 	}
 
+	td := p.captureTeardown()
+	hookEnv := p.hookEnv(HookDisconnect)
+
 	if p.Mode == Wg {
 		err = p.stopWg()
 	} else {
@@ -4452,13 +4784,16 @@ func (p *Profile) Stop() {
 	if p.Mode == Wg && runtime.GOOS == "darwin" &&
 		config.Config.EnableWgDns {
 
+		dnsLock.Lock()
 		err = utils.ClearScutilDns(p.Id)
+		dnsLock.Unlock()
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"profile_id": p.Id,
 				"error":      err,
 			}).Error("profile: Failed to clear scutil DNS")
 			err = nil
+			p.dnsDirty = true
 		}
 	}
 
@@ -4470,17 +4805,36 @@ func (p *Profile) Stop() {
 		ManagementPortRelease(p.managementPort)
 	}
 
+	p.releaseOverlaps()
+	p.clearExclusions()
+	p.clearDnsRoutes()
+	p.clearSplitDns()
+	p.restoreIpv6()
 	p.clearWg()
 	p.clearOvpn()
 
+	leftovers := p.verifyTeardown(td)
+
+	if p.auditConnected {
+		p.recordAudit(audit.Disconnect, p.auditReason())
+	}
+
 	p.Status = "disconnected"
 	p.Timestamp = 0
 	p.ClientAddr = ""
 	p.ServerAddr = ""
+	p.Cipher = ""
+	p.TunnelMtu = 0
+	p.resetTraffic()
+	p.resetDnsFallback()
 	p.update()
 
+	if len(leftovers) > 0 {
+		recordDirtyTeardown(p.Id, p.Mode, leftovers)
+	}
+
 	for _, path := range p.remPaths {
-		os.Remove(path)
+		_ = utils.Shred(path)
 	}
 
 	Profiles.Lock()
@@ -4499,7 +4853,7 @@ func (p *Profile) Stop() {
 			err = nil
 		}
 		if DnsForced {
-			utils.ClearDns()
+			netconf.Get().ClearDns()
 		}
 	}
 	Profiles.Unlock()
@@ -4508,6 +4862,8 @@ func (p *Profile) Stop() {
 		"profile_id": p.Id,
 	}).Info("profile: Disconnected")
 
+	p.runHooksAsync(HookDisconnect, hookEnv)
+
 	stateLock.Lock()
 	p.state = false
 	for _, waiter := range p.waiters {
@@ -4516,6 +4872,8 @@ func (p *Profile) Stop() {
 	p.waiters = []chan bool{}
 	stateLock.Unlock()
 
+	p.checkRoutines()
+
 	return
 }
 
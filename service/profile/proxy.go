@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func (p *Profile) proxyRequest(req *http.Request) (
+	proxyReq *http.Request, err error) {
+
+	u, err := proxy.Get(p.HttpProxy, p.SocksProxy)
+	if err != nil {
+		return
+	}
+
+	proxyReq = proxy.WithProxy(req, u)
+
+	return
+}
+
+func (p *Profile) writeProxyAuth(username, password string) (
+	pth string, err error) {
+
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		return
+	}
+
+	if runtime.GOOS == "windows" {
+		pth = filepath.Join(rootDir, p.Id+"-proxy.txt")
+	} else {
+		pth = filepath.Join(rootDir, p.Id+"-proxy")
+	}
+
+	err = utils.CreateWriteExcl(pth, username+"\n"+password+"\n", 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Http proxies without credentials are connected through the local relay
+// when integrated authentication is enabled, the proxy is routed to the
+// physical gateway as OpenVPN only excludes the relay from the tunnel
+func (p *Profile) startProxyRelay(u *url.URL) (args []string, err error) {
+	err = p.routeHostGateway(u.Hostname())
+	if err != nil {
+		return
+	}
+
+	relay, err := proxy.NewRelay(u)
+	if err != nil {
+		return
+	}
+	p.proxyRelay = relay
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"proxy":      u.Host,
+		"local":      relay.Addr().String(),
+	}).Info("profile: Using integrated proxy authentication")
+
+	args = []string{
+		"--http-proxy",
+		relay.Addr().IP.String(),
+		strconv.Itoa(relay.Addr().Port),
+	}
+
+	return
+}
+
+func (p *Profile) stopProxyRelay() {
+	if p.proxyRelay != nil {
+		p.proxyRelay.Close()
+		p.proxyRelay = nil
+	}
+}
+
+// OpenVPN options to connect to the server through the profile or global
+// proxy, credentials are passed in a temporary file
+func (p *Profile) proxyArgs() (args []string, err error) {
+	u, err := proxy.Get(p.HttpProxy, p.SocksProxy)
+	if err != nil || u == nil {
+		return
+	}
+
+	if u.Scheme == "http" && u.User == nil &&
+		config.Config.ProxyNegotiate && proxy.NegotiateSupported {
+
+		args, err = p.startProxyRelay(u)
+		return
+	}
+
+	authPath := ""
+	if u.User != nil {
+		password, _ := u.User.Password()
+
+		authPath, err = p.writeProxyAuth(u.User.Username(), password)
+		if err != nil {
+			return
+		}
+		p.remPaths = append(p.remPaths, authPath)
+	}
+
+	switch u.Scheme {
+	case "http":
+		args = []string{"--http-proxy", u.Hostname(), u.Port()}
+		if authPath != "" {
+			args = append(args, authPath, "basic")
+		}
+		break
+	case "socks5":
+		args = []string{"--socks-proxy", u.Hostname(), u.Port()}
+		if authPath != "" {
+			args = append(args, authPath)
+		}
+		break
+	}
+
+	return
+}
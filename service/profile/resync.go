@@ -0,0 +1,149 @@
+package profile
+
+import (
+	"runtime/debug"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ResyncAdapterMissing = "adapter_missing"
+	ResyncAdapterDown    = "adapter_down"
+	ResyncRoutesMissing  = "routes_missing"
+)
+
+type Resync struct {
+	ProfileId string `json:"profile_id"`
+	Reason    string `json:"reason"`
+	Restarted bool   `json:"restarted"`
+}
+
+func (p *Profile) missingRoutes() (missing []string) {
+	iface := p.tunnelIface()
+
+	for _, network := range p.guardedNetworks() {
+		ifaces, err := netconf.Get().GetRouteIfaces(network)
+		if err != nil {
+			continue
+		}
+
+		found := false
+		for _, routeIface := range ifaces {
+			if routeIface == iface {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, network.String())
+		}
+	}
+
+	return
+}
+
+func (p *Profile) resync(adapters map[string]*platform.Adapter) (
+	resync *Resync) {
+
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	resync = &Resync{
+		ProfileId: p.Id,
+	}
+
+	adapter := adapters[iface]
+	if adapter != nil && adapter.Up {
+		p.checkExclusions(nil)
+	}
+
+	if adapter == nil {
+		resync.Reason = ResyncAdapterMissing
+	} else if !adapter.Up {
+		resync.Reason = ResyncAdapterDown
+	} else if missing := p.missingRoutes(); len(missing) > 0 {
+		resync.Reason = ResyncRoutesMissing
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"iface":      iface,
+			"networks":   missing,
+		}).Warn("profile: Tunnel routes lost on resume, reinstalling")
+
+		for _, network := range p.guardedNetworks() {
+			_ = addRoute(network, iface)
+		}
+
+		if len(p.missingRoutes()) == 0 {
+			return
+		}
+	} else {
+		resync = nil
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"iface":      iface,
+		"reason":     resync.Reason,
+	}).Warn("profile: Connection state stale after resume, reconnecting")
+
+	resync.Restarted = true
+
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		p.Restart()
+	}()
+
+	return
+}
+
+// Verify the adapter and routes of connected profiles after the system
+// resumes from hibernation, profiles with a reset network stack are
+// reconnected instead of being reported as connected
+func ResyncProfiles() {
+	adaptersList, err := platform.GetAdapters()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("profile: Failed to get adapters for resync")
+		return
+	}
+
+	adapters := map[string]*platform.Adapter{}
+	for _, adapter := range adaptersList {
+		adapters[adapter.Name] = adapter
+	}
+
+	for _, prfl := range GetProfiles() {
+		if prfl.stop || prfl.Status != "connected" {
+			continue
+		}
+
+		resync := prfl.resync(adapters)
+		if resync == nil {
+			continue
+		}
+
+		evt := &event.Event{
+			Type: "profile_resync",
+			Data: resync,
+		}
+		evt.Init()
+	}
+}
@@ -0,0 +1,170 @@
+package profile
+
+import (
+	"net"
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	routeGuardInterval = 10 * time.Second
+	routeGuardMaxFixes = 5
+)
+
+type RouteChange struct {
+	ProfileId     string   `json:"profile_id"`
+	Network       string   `json:"network"`
+	Iface         string   `json:"iface"`
+	CurrentIfaces []string `json:"current_ifaces"`
+}
+
+// Networks shared with another connected profile are managed by
+// resolveOverlaps and ignored by the route guard.
+func (p *Profile) guardedNetworks() (networks []*net.IPNet) {
+	networks = p.networks
+
+	for _, other := range GetProfiles() {
+		if other.Id == p.Id || other.Status != "connected" {
+			continue
+		}
+
+		overlaps := overlapNetworks(networks, other.networks)
+		if len(overlaps) == 0 {
+			continue
+		}
+
+		filtered := []*net.IPNet{}
+		for _, network := range networks {
+			if len(overlapNetworks([]*net.IPNet{network}, overlaps)) == 0 {
+				filtered = append(filtered, network)
+			}
+		}
+		networks = filtered
+	}
+
+	return
+}
+
+func (p *Profile) checkRoutes(fixes map[string]int) {
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	for _, network := range p.guardedNetworks() {
+		key := network.String()
+		if fixes[key] >= routeGuardMaxFixes {
+			continue
+		}
+
+		ifaces, err := netconf.Get().GetRouteIfaces(network)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    key,
+				"error":      err,
+			}).Warn("profile: Failed to check tunnel route")
+			continue
+		}
+
+		found := false
+		for _, routeIface := range ifaces {
+			if routeIface == iface {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		if p.stop || p.Status != "connected" {
+			return
+		}
+
+		fixes[key] += 1
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id":     p.Id,
+			"network":        key,
+			"iface":          iface,
+			"current_ifaces": ifaces,
+		}).Warn("profile: Tunnel route removed or overridden, reinstalling")
+
+		evt := &event.Event{
+			Type:      "route_changed",
+			Workspace: p.Workspace,
+			Data: &RouteChange{
+				ProfileId:     p.Id,
+				Network:       key,
+				Iface:         iface,
+				CurrentIfaces: ifaces,
+			},
+		}
+		evt.Init()
+
+		err = addRoute(network, iface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    key,
+				"error":      err,
+			}).Error("profile: Failed to reinstall tunnel route")
+			continue
+		}
+
+		if fixes[key] >= routeGuardMaxFixes {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    key,
+			}).Error("profile: Tunnel route repeatedly removed, " +
+				"route guard disabled for network")
+		}
+	}
+}
+
+func (p *Profile) watchRoutes() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.trackRoutine("route_guard")()
+
+	fixes := map[string]int{}
+
+	for {
+		time.Sleep(routeGuardInterval)
+
+		if p.stop {
+			return
+		}
+
+		if p.Status != "connected" {
+			continue
+		}
+
+		p.checkRoutes(fixes)
+		p.checkExclusions(fixes)
+	}
+}
+
+func (p *Profile) startRouteGuard() {
+	if p.routeGuard || config.Config.DisableRouteGuard {
+		return
+	}
+	p.routeGuard = true
+
+	go p.watchRoutes()
+}
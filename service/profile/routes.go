@@ -0,0 +1,503 @@
+package profile
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/sirupsen/logrus"
+)
+
+func parseNetworks(subnets []string) (networks []*net.IPNet) {
+	networks = []*net.IPNet{}
+	for _, subnet := range subnets {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(subnet))
+		if err != nil {
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+func networkIp(network *net.IPNet) net.IP {
+	if ip := network.IP.To4(); ip != nil {
+		return ip
+	}
+	return network.IP.To16()
+}
+
+// Split network into the subnets that do not overlap exclude
+func subtractNetwork(network, exclude *net.IPNet) (remain []*net.IPNet) {
+	netIp := networkIp(network)
+	exIp := networkIp(exclude)
+	if len(netIp) != len(exIp) {
+		return []*net.IPNet{network}
+	}
+
+	netOnes, bits := network.Mask.Size()
+	exOnes, _ := exclude.Mask.Size()
+
+	if !network.Contains(exclude.IP) && !exclude.Contains(network.IP) {
+		return []*net.IPNet{network}
+	}
+	if exOnes <= netOnes {
+		return
+	}
+
+	// Halve the network until the excluded subnet is reached, the half
+	// without the excluded subnet is kept at each step
+	cur := new(big.Int).SetBytes(netIp)
+	target := new(big.Int).SetBytes(exIp)
+
+	for ones := netOnes + 1; ones <= exOnes; ones++ {
+		half := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+		upper := new(big.Int).Add(cur, half)
+
+		other := upper
+		if target.Cmp(upper) >= 0 {
+			other = cur
+			cur = upper
+		}
+
+		ipByt := other.FillBytes(make([]byte, len(netIp)))
+		remain = append(remain, &net.IPNet{
+			IP:   net.IP(ipByt),
+			Mask: net.CIDRMask(ones, bits),
+		})
+	}
+
+	return
+}
+
+func subtractNetworks(networks, excludes []*net.IPNet) []*net.IPNet {
+	for _, exclude := range excludes {
+		remain := []*net.IPNet{}
+		for _, network := range networks {
+			remain = append(remain, subtractNetwork(network, exclude)...)
+		}
+		networks = remain
+	}
+	return networks
+}
+
+func subtractRoutes(routes []*Route, excludes []*net.IPNet) (
+	remain []*Route) {
+
+	remain = []*Route{}
+
+	for _, route := range routes {
+		_, network, err := net.ParseCIDR(route.Network)
+		if err != nil || route.NetGateway {
+			remain = append(remain, route)
+			continue
+		}
+
+		for _, subnet := range subtractNetworks(
+			[]*net.IPNet{network}, excludes) {
+
+			remain = append(remain, &Route{
+				NextHop:    route.NextHop,
+				Network:    subnet.String(),
+				Metric:     route.Metric,
+				NetGateway: route.NetGateway,
+			})
+		}
+	}
+
+	return
+}
+
+func hasDefaultRoute(routes []*Route) bool {
+	for _, route := range routes {
+		if !route.NetGateway && (route.Network == "0.0.0.0/0" ||
+			route.Network == "::/0") {
+
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the profile route overrides to the server routes, excluded subnets
+// are removed from the allowed IPs to leave them on the system routes.
+// Returns true when a default route was split by an exclusion, the
+// WireGuard tools only bypass the tunnel for the endpoint when the allowed
+// IPs include a default route.
+func (p *Profile) overrideWgRoutes(data *WgConf) (split bool) {
+	if len(p.IncludeRoutes) == 0 && len(p.ExcludeRoutes) == 0 {
+		return
+	}
+
+	for _, network := range parseNetworks(p.IncludeRoutes) {
+		if network.IP.To4() != nil {
+			data.Routes = append(data.Routes, &Route{
+				NextHop: data.Gateway,
+				Network: network.String(),
+			})
+		} else if data.Address6 != "" {
+			data.Routes6 = append(data.Routes6, &Route{
+				NextHop: data.Gateway6,
+				Network: network.String(),
+			})
+		}
+	}
+
+	hasDefault := hasDefaultRoute(data.Routes) ||
+		hasDefaultRoute(data.Routes6)
+
+	excludes := parseNetworks(p.ExcludeRoutes)
+	data.Routes = subtractRoutes(data.Routes, excludes)
+	data.Routes6 = subtractRoutes(data.Routes6, excludes)
+
+	split = hasDefault && !hasDefaultRoute(data.Routes) &&
+		!hasDefaultRoute(data.Routes6)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id":     p.Id,
+		"include_routes": p.IncludeRoutes,
+		"exclude_routes": p.ExcludeRoutes,
+	}).Info("profile: Applied profile route overrides")
+
+	return
+}
+
+// Route the server endpoint to the physical gateway, without the default
+// route in the allowed IPs the endpoint would be routed into the tunnel
+func (p *Profile) routeWgEndpoint(data *WgConf) (err error) {
+	err = p.routeHostGateway(data.Hostname)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Route each address of a host to the physical gateway
+func (p *Profile) routeHostGateway(host string) (err error) {
+	ips := []net.IP{}
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		ips, err = net.LookupIP(host)
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrapf(err, "profile: Failed to resolve '%s'", host),
+			}
+			return
+		}
+	}
+
+	for _, ip := range ips {
+		ipv6 := ip.To4() == nil
+		bits := 32
+		if ipv6 {
+			bits = 128
+		}
+
+		gateway, gatewayIface, e := netconf.Get().GetDefaultGateway(ipv6)
+		if e != nil {
+			if !ipv6 {
+				err = e
+				return
+			}
+			continue
+		}
+
+		network := &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}
+
+		err = addGatewayRoute(network, gateway, gatewayIface)
+		if err != nil {
+			return
+		}
+		p.addExclusionRoute(network, gatewayIface)
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"host":       host,
+			"address":    ip.String(),
+			"gateway":    gateway.String(),
+			"iface":      gatewayIface,
+		}).Info("profile: Routed host to gateway")
+	}
+
+	return
+}
+
+// OpenVPN applies routes in the configuration after the pushed routes,
+// excluded IPv4 subnets are routed to the gateway of the system
+func (p *Profile) overrideOvpnRoutes() (conf string) {
+	includes := parseNetworks(p.IncludeRoutes)
+	if p.SummarizeRoutes {
+		includes = summarizeNetworks(includes)
+	}
+
+	for _, network := range includes {
+		if network.IP.To4() != nil {
+			conf += fmt.Sprintf("route %s %s\n",
+				network.IP.String(), net.IP(network.Mask).String())
+		} else {
+			conf += fmt.Sprintf("route-ipv6 %s\n", network.String())
+		}
+	}
+
+	for _, network := range parseNetworks(p.ExcludeRoutes) {
+		if network.IP.To4() != nil {
+			conf += fmt.Sprintf("route %s %s net_gateway\n",
+				network.IP.String(), net.IP(network.Mask).String())
+		} else {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    network.String(),
+			}).Warn("profile: IPv6 route exclusions not supported by OpenVPN")
+		}
+	}
+
+	return
+}
+
+type routePrefix struct {
+	start *big.Int
+	ones  int
+}
+
+func (r *routePrefix) size(bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-r.ones))
+}
+
+func (r *routePrefix) contains(other *routePrefix, bits int) bool {
+	if other.ones < r.ones {
+		return false
+	}
+	end := new(big.Int).Add(r.start, r.size(bits))
+	return other.start.Cmp(r.start) >= 0 && other.start.Cmp(end) < 0
+}
+
+// Merge a pair of sibling prefixes into the parent prefix, the lower
+// sibling must be aligned to the parent and the upper sibling adjacent
+func (r *routePrefix) merge(upper *routePrefix, bits int) *routePrefix {
+	if r.ones != upper.ones || r.ones == 0 {
+		return nil
+	}
+
+	size := r.size(bits)
+	if new(big.Int).Mod(r.start, new(big.Int).Lsh(size, 1)).Sign() != 0 {
+		return nil
+	}
+	if new(big.Int).Add(r.start, size).Cmp(upper.start) != 0 {
+		return nil
+	}
+
+	return &routePrefix{
+		start: r.start,
+		ones:  r.ones - 1,
+	}
+}
+
+func summarizeFamily(networks []*net.IPNet, ipLen int) (
+	summary []*net.IPNet) {
+
+	bits := ipLen * 8
+	prefixes := []*routePrefix{}
+	for _, network := range networks {
+		ones, _ := network.Mask.Size()
+		prefixes = append(prefixes, &routePrefix{
+			start: new(big.Int).SetBytes(networkIp(network)),
+			ones:  ones,
+		})
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool {
+		cmp := prefixes[i].start.Cmp(prefixes[j].start)
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return prefixes[i].ones < prefixes[j].ones
+	})
+
+	// Prefixes are sorted by address so a merged parent can only combine
+	// with the prefix before it on the stack
+	stack := []*routePrefix{}
+	for _, prefix := range prefixes {
+		if len(stack) > 0 && stack[len(stack)-1].contains(prefix, bits) {
+			continue
+		}
+
+		stack = append(stack, prefix)
+		for len(stack) > 1 {
+			parent := stack[len(stack)-2].merge(stack[len(stack)-1], bits)
+			if parent == nil {
+				break
+			}
+			stack = append(stack[:len(stack)-2], parent)
+		}
+	}
+
+	for _, prefix := range stack {
+		summary = append(summary, &net.IPNet{
+			IP:   net.IP(prefix.start.FillBytes(make([]byte, ipLen))),
+			Mask: net.CIDRMask(prefix.ones, bits),
+		})
+	}
+
+	return
+}
+
+// Combine contiguous and overlapping networks into the smallest set of
+// prefixes covering exactly the same addresses
+func summarizeNetworks(networks []*net.IPNet) (summary []*net.IPNet) {
+	networks4 := []*net.IPNet{}
+	networks6 := []*net.IPNet{}
+
+	for _, network := range networks {
+		if len(networkIp(network)) == net.IPv4len {
+			networks4 = append(networks4, network)
+		} else {
+			networks6 = append(networks6, network)
+		}
+	}
+
+	summary = []*net.IPNet{}
+	summary = append(summary, summarizeFamily(networks4, net.IPv4len)...)
+	summary = append(summary, summarizeFamily(networks6, net.IPv6len)...)
+
+	return
+}
+
+// Summarize routes sharing a next hop and metric, net gateway routes and
+// routes that cannot be parsed are left unchanged
+func summarizeRoutes(routes []*Route) (summary []*Route) {
+	summary = []*Route{}
+	groups := map[string][]*net.IPNet{}
+	groupRoutes := map[string]*Route{}
+	groupKeys := []string{}
+
+	for _, route := range routes {
+		_, network, err := net.ParseCIDR(route.Network)
+		if err != nil || route.NetGateway {
+			summary = append(summary, route)
+			continue
+		}
+
+		key := fmt.Sprintf("%s-%d", route.NextHop, route.Metric)
+		if _, ok := groups[key]; !ok {
+			groupKeys = append(groupKeys, key)
+			groupRoutes[key] = route
+		}
+		groups[key] = append(groups[key], network)
+	}
+
+	for _, key := range groupKeys {
+		route := groupRoutes[key]
+		for _, network := range summarizeNetworks(groups[key]) {
+			summary = append(summary, &Route{
+				NextHop: route.NextHop,
+				Network: network.String(),
+				Metric:  route.Metric,
+			})
+		}
+	}
+
+	return
+}
+
+// OpenVPN installs each pushed route, with summarization the pushed routes
+// are ignored and the summarized routes installed once connected
+func (p *Profile) summarizeOvpnConf() (conf string) {
+	if !p.SummarizeRoutes {
+		return
+	}
+
+	conf = "pull-filter ignore \"route \"\n" +
+		"pull-filter ignore \"route-ipv6 \"\n"
+
+	return
+}
+
+// Install the summarized routes pushed by the server, the pushed net
+// gateway routes are reinstalled on the gateway by the exclusion check
+func (p *Profile) addSummarizedOvpnRoutes() {
+	p.summaryNetworks = nil
+
+	if !p.SummarizeRoutes || p.Mode == Wg || len(p.networks) == 0 {
+		return
+	}
+
+	iface := p.tunnelIface()
+	if iface == "" {
+		return
+	}
+
+	networks := summarizeNetworks(p.networks)
+	p.summaryNetworks = networks
+	for _, network := range networks {
+		err := addRoute(network, iface)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"network":    network.String(),
+				"iface":      iface,
+				"error":      err,
+			}).Error("profile: Failed to add summarized route")
+		}
+	}
+
+	p.checkExclusions(nil)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"routes":     len(p.networks),
+		"summarized": len(networks),
+	}).Info("profile: Summarized server routes")
+}
+
+// Reduce the number of routes installed for servers pushing many
+// individual routes
+func (p *Profile) summarizeWgRoutes(data *WgConf) {
+	if !p.SummarizeRoutes {
+		return
+	}
+
+	count := len(data.Routes) + len(data.Routes6)
+
+	data.Routes = summarizeRoutes(data.Routes)
+	data.Routes6 = summarizeRoutes(data.Routes6)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"routes":     count,
+		"summarized": len(data.Routes) + len(data.Routes6),
+	}).Info("profile: Summarized server routes")
+}
+
+func (p *Profile) addWgRoutes(routes []*Route) (err error) {
+	for _, route := range routes {
+		if route.NetGateway {
+			continue
+		}
+
+		_, network, e := net.ParseCIDR(route.Network)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "profile: Failed to parse route network"),
+			}
+			return
+		}
+
+		err = netconf.Get().AddGatewayRoute(network,
+			net.ParseIP(route.NextHop), route.Metric, p.Iface)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,80 @@
+package profile
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeFamily(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks []string
+		ipLen    int
+		summary  []string
+	}{
+		{
+			"adjacent",
+			[]string{"10.0.0.0/24", "10.0.1.0/24"},
+			net.IPv4len,
+			[]string{"10.0.0.0/23"},
+		},
+		{
+			"unaligned adjacent",
+			[]string{"10.0.1.0/24", "10.0.2.0/24"},
+			net.IPv4len,
+			[]string{"10.0.1.0/24", "10.0.2.0/24"},
+		},
+		{
+			"contained",
+			[]string{"10.0.0.0/8", "10.1.0.0/16", "10.2.3.0/24"},
+			net.IPv4len,
+			[]string{"10.0.0.0/8"},
+		},
+		{
+			"cascading merge",
+			[]string{"10.0.3.0/24", "10.0.0.0/24", "10.0.2.0/24",
+				"10.0.1.0/24"},
+			net.IPv4len,
+			[]string{"10.0.0.0/22"},
+		},
+		{
+			"merge into preceding",
+			[]string{"10.0.0.0/23", "10.0.2.0/24", "10.0.3.0/24"},
+			net.IPv4len,
+			[]string{"10.0.0.0/22"},
+		},
+		{
+			"disjoint",
+			[]string{"192.168.0.0/24", "10.0.0.0/24"},
+			net.IPv4len,
+			[]string{"10.0.0.0/24", "192.168.0.0/24"},
+		},
+		{
+			"full range",
+			[]string{"0.0.0.0/1", "128.0.0.0/1"},
+			net.IPv4len,
+			[]string{"0.0.0.0/0"},
+		},
+		{
+			"ipv6",
+			[]string{"fd00::/65", "fd00:0:0:0:8000::/65", "fd01::/64"},
+			net.IPv6len,
+			[]string{"fd00::/64", "fd01::/64"},
+		},
+	}
+
+	for _, test := range tests {
+		summary := []string{}
+		for _, network := range summarizeFamily(
+			parseNetworks(test.networks), test.ipLen) {
+
+			summary = append(summary, network.String())
+		}
+
+		if !reflect.DeepEqual(summary, test.summary) {
+			t.Errorf("%s: expected %v got %v",
+				test.name, test.summary, summary)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/sirupsen/logrus"
+)
+
+const routineLeakGrace = 60 * time.Second
+
+var (
+	routinesLock    = sync.Mutex{}
+	routineProfiles = map[*Profile]bool{}
+)
+
+type RoutineLeak struct {
+	ProfileId string         `json:"profile_id"`
+	Routines  map[string]int `json:"routines"`
+}
+
+func (p *Profile) trackRoutine(name string) (done func()) {
+	routinesLock.Lock()
+	if p.routines == nil {
+		p.routines = map[string]int{}
+	}
+	p.routines[name] += 1
+	routineProfiles[p] = true
+	routinesLock.Unlock()
+
+	once := sync.Once{}
+	done = func() {
+		once.Do(func() {
+			routinesLock.Lock()
+			p.routines[name] -= 1
+			if p.routines[name] <= 0 {
+				delete(p.routines, name)
+			}
+			if len(p.routines) == 0 {
+				delete(routineProfiles, p)
+			}
+			routinesLock.Unlock()
+		})
+	}
+
+	return
+}
+
+func (p *Profile) liveRoutines() (routines map[string]int) {
+	routinesLock.Lock()
+	defer routinesLock.Unlock()
+
+	routines = map[string]int{}
+	for name, count := range p.routines {
+		routines[name] = count
+	}
+
+	return
+}
+
+func (p *Profile) checkRoutines() {
+	time.AfterFunc(routineLeakGrace, func() {
+		routines := p.liveRoutines()
+		if len(routines) == 0 {
+			return
+		}
+
+		names := []string{}
+		for name := range routines {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"routines":   names,
+			"grace":      routineLeakGrace.String(),
+		}).Warn("profile: Disconnected profile has live goroutines")
+
+		evt := &event.Event{
+			Type:      "routine_leak",
+			Workspace: p.Workspace,
+			Data: &RoutineLeak{
+				ProfileId: p.Id,
+				Routines:  routines,
+			},
+		}
+		evt.Init()
+	})
+}
+
+func GetRoutines() (routines map[string]map[string]int) {
+	routinesLock.Lock()
+	defer routinesLock.Unlock()
+
+	routines = map[string]map[string]int{}
+
+	for prfl := range routineProfiles {
+		prflRoutines := routines[prfl.Id]
+		if prflRoutines == nil {
+			prflRoutines = map[string]int{}
+			routines[prfl.Id] = prflRoutines
+		}
+
+		for name, count := range prfl.routines {
+			prflRoutines[name] += count
+		}
+	}
+
+	return
+}
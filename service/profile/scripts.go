@@ -14,7 +14,7 @@ for optionname in ${!foreign_option_*} ; do
   if [ "$part1" == "dhcp-option" ] ; then
     part2=$(echo "$option" | cut -d " " -f 2)
     part3=$(echo "$option" | cut -d " " -f 3)
-    if [ "$part2" == "DNS" ] ; then
+    if [[ "$part2" == "DNS" || "$part2" == "DNS6" ]] ; then
       DNS_SERVERS="$DNS_SERVERS $part3"
     fi
     if [[ "$part2" == "DOMAIN" || "$part2" == "DOMAIN-SEARCH" ]] ; then
@@ -35,6 +35,16 @@ EOF
 grep PrimaryService | sed -e 's/.*PrimaryService : //'
 )"
 
+if [ -z "$SERVICE_ID" ]; then
+  SERVICE_ID="$(/usr/sbin/scutil <<-EOF |
+open
+show State:/Network/Global/IPv6
+quit
+EOF
+grep PrimaryService | sed -e 's/.*PrimaryService : //'
+)"
+fi
+
 SERVICE_ORIG="$(/usr/sbin/scutil <<-EOF |
 open
 show State:/Network/Service/${SERVICE_ID}/DNS
@@ -107,7 +117,7 @@ for optionname in ${!foreign_option_*} ; do
   if [ "$part1" == "dhcp-option" ] ; then
     part2=$(echo "$option" | cut -d " " -f 2)
     part3=$(echo "$option" | cut -d " " -f 3)
-    if [ "$part2" == "DNS" ] ; then
+    if [[ "$part2" == "DNS" || "$part2" == "DNS6" ]] ; then
       DNS_SERVERS="$DNS_SERVERS $part3"
     fi
     if [[ "$part2" == "DOMAIN" || "$part2" == "DOMAIN-SEARCH" ]] ; then
@@ -128,6 +138,16 @@ EOF
 grep PrimaryService | sed -e 's/.*PrimaryService : //'
 )"
 
+if [ -z "$SERVICE_ID" ]; then
+  SERVICE_ID="$(/usr/sbin/scutil <<-EOF |
+open
+show State:/Network/Global/IPv6
+quit
+EOF
+grep PrimaryService | sed -e 's/.*PrimaryService : //'
+)"
+fi
+
 SERVICE_ORIG="$(/usr/sbin/scutil <<-EOF |
 open
 show State:/Network/Service/${SERVICE_ID}/DNS
@@ -251,7 +271,7 @@ up)
     if [ "$part1" == "dhcp-option" ] ; then
       part2=$(echo "$option" | cut -d " " -f 2)
       part3=$(echo "$option" | cut -d " " -f 3)
-      if [ "$part2" == "DNS" ] ; then
+      if [[ "$part2" == "DNS" || "$part2" == "DNS6" ]] ; then
         IF_DNS_NAMESERVERS="$IF_DNS_NAMESERVERS $part3"
       fi
       if [[ "$part2" == "DOMAIN" || "$part2" == "DOMAIN-SEARCH" ]] ; then
@@ -0,0 +1,205 @@
+package profile
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+// Network configuration step run after the tunnel is up, steps run once
+// the steps they depend on have completed
+type setupStep struct {
+	name string
+	deps []string
+	run  func()
+}
+
+// Order the steps so each step follows its dependencies, fails on a
+// missing dependency or a cycle
+func sortSteps(steps []*setupStep) (ordered []*setupStep, err error) {
+	byName := map[string]*setupStep{}
+	for _, step := range steps {
+		byName[step.name] = step
+	}
+
+	remaining := map[string]int{}
+	dependents := map[string][]*setupStep{}
+	for _, step := range steps {
+		for _, dep := range step.deps {
+			if byName[dep] == nil {
+				err = &errortypes.ParseError{
+					errors.Newf("profile: Unknown setup step '%s'", dep),
+				}
+				return
+			}
+			dependents[dep] = append(dependents[dep], step)
+		}
+		remaining[step.name] = len(step.deps)
+	}
+
+	ordered = []*setupStep{}
+	for _, step := range steps {
+		if remaining[step.name] == 0 {
+			ordered = append(ordered, step)
+		}
+	}
+
+	for i := 0; i < len(ordered); i++ {
+		for _, step := range dependents[ordered[i].name] {
+			remaining[step.name] -= 1
+			if remaining[step.name] == 0 {
+				ordered = append(ordered, step)
+			}
+		}
+	}
+
+	if len(ordered) != len(steps) {
+		err = &errortypes.ParseError{
+			errors.New("profile: Setup steps contain a cycle"),
+		}
+		return
+	}
+
+	return
+}
+
+// Run the steps concurrently, most steps are bound by external commands
+// and independent steps do not need to wait on each other
+func (p *Profile) runSteps(steps []*setupStep) {
+	ordered, err := sortSteps(steps)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"error":      err,
+		}).Error("profile: Invalid setup steps, running sequentially")
+
+		for _, step := range steps {
+			if !p.stop {
+				step.run()
+			}
+		}
+		return
+	}
+
+	start := time.Now()
+	done := map[string]chan bool{}
+	for _, step := range ordered {
+		done[step.name] = make(chan bool)
+	}
+
+	for _, step := range ordered {
+		go func(step *setupStep) {
+			defer func() {
+				panc := recover()
+				if panc != nil {
+					logrus.WithFields(logrus.Fields{
+						"stack": string(debug.Stack()),
+						"panic": panc,
+					}).Error("profile: Panic")
+					panic(panc)
+				}
+			}()
+
+			defer close(done[step.name])
+
+			for _, dep := range step.deps {
+				<-done[dep]
+			}
+
+			if p.stop {
+				return
+			}
+
+			stepStart := time.Now()
+			step.run()
+
+			logrus.WithFields(logrus.Fields{
+				"profile_id": p.Id,
+				"step":       step.name,
+				"duration":   time.Since(stepStart).String(),
+			}).Debug("profile: Network setup step completed")
+		}(step)
+	}
+
+	for _, step := range ordered {
+		<-done[step.name]
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"duration":   time.Since(start).String(),
+	}).Info("profile: Network setup completed")
+}
+
+// Configure the network after the tunnel is up. DNS, routes and firewall
+// rules are independent, the up hooks run once all are applied.
+func (p *Profile) setupNetwork() {
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("profile: Panic")
+				panic(panc)
+			}
+		}()
+
+		defer p.trackRoutine("network_setup")()
+
+		p.setDnsStatus(DnsStatusPending, nil)
+
+		p.runSteps([]*setupStep{
+			{
+				name: "dns",
+				run: func() {
+					p.applyDnsRetry(p.applyDns)
+				},
+			},
+			{
+				name: "routes",
+				run:  p.addSummarizedOvpnRoutes,
+			},
+			{
+				name: "overlaps",
+				deps: []string{"routes"},
+				run:  p.resolveOverlaps,
+			},
+			{
+				name: "ipv6",
+				run:  p.disableIpv6,
+			},
+			{
+				name: "dns_hijack",
+				run:  p.handleDnsHijack,
+			},
+			{
+				name: "kill_switch",
+				run:  p.applyKillSwitch,
+			},
+			{
+				name: "dns_benchmark",
+				deps: []string{"dns", "dns_hijack"},
+				run:  p.benchmarkDnsAsync,
+			},
+			{
+				name: "hooks",
+				deps: []string{
+					"dns",
+					"routes",
+					"overlaps",
+					"ipv6",
+					"dns_hijack",
+					"kill_switch",
+				},
+				run: func() {
+					p.runHooksAsync(HookUp, p.hookEnv(HookUp))
+				},
+			},
+		})
+	}()
+}
@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func stepNames(steps []*setupStep) (names []string) {
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	return
+}
+
+func TestSortSteps(t *testing.T) {
+	steps := []*setupStep{
+		{name: "dns", deps: []string{"routes", "iface"}},
+		{name: "routes", deps: []string{"iface"}},
+		{name: "iface"},
+		{name: "ipv6"},
+		{name: "hooks", deps: []string{"dns"}},
+	}
+
+	ordered, err := sortSteps(steps)
+	if err != nil {
+		t.Fatalf("sortSteps: %s", err)
+	}
+
+	if len(ordered) != len(steps) {
+		t.Fatalf("expected %d steps got %v", len(steps), stepNames(ordered))
+	}
+
+	index := map[string]int{}
+	for i, step := range ordered {
+		index[step.name] = i
+	}
+
+	for _, step := range steps {
+		for _, dep := range step.deps {
+			if index[dep] >= index[step.name] {
+				t.Errorf("step %s ordered before dependency %s: %v",
+					step.name, dep, stepNames(ordered))
+			}
+		}
+	}
+}
+
+func TestSortStepsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []*setupStep
+	}{
+		{
+			"unknown dependency",
+			[]*setupStep{
+				{name: "dns", deps: []string{"routes"}},
+			},
+		},
+		{
+			"self dependency",
+			[]*setupStep{
+				{name: "dns", deps: []string{"dns"}},
+			},
+		},
+		{
+			"cycle",
+			[]*setupStep{
+				{name: "iface"},
+				{name: "routes", deps: []string{"iface", "dns"}},
+				{name: "dns", deps: []string{"routes"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := sortSteps(test.steps)
+		if _, ok := err.(*errortypes.ParseError); !ok {
+			t.Errorf("%s: expected parse error got %v", test.name, err)
+		}
+	}
+}
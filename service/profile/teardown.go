@@ -0,0 +1,178 @@
+package profile
+
+import (
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	TeardownInterface = "interface"
+	TeardownRoutes    = "routes"
+	TeardownDns       = "dns"
+	TeardownFirewall  = "firewall"
+
+	teardownRetries = 3
+	teardownWait    = 1 * time.Second
+)
+
+type DirtyTeardown struct {
+	ProfileId string   `json:"profile_id"`
+	Leftovers []string `json:"leftovers"`
+}
+
+// State captured before the tunnel is stopped, the profile fields are
+// cleared during the stop
+type teardownState struct {
+	iface    string
+	networks []*net.IPNet
+}
+
+func (p *Profile) captureTeardown() (td *teardownState) {
+	td = &teardownState{
+		networks: append(append([]*net.IPNet{}, p.networks...),
+			p.summaryNetworks...),
+	}
+
+	// TAP adapters are persistent and reused across connections
+	if p.tap == "" {
+		td.iface = p.tunnelIface()
+	}
+
+	return
+}
+
+func ifaceExists(iface string) bool {
+	_, err := net.InterfaceByName(iface)
+	return err == nil
+}
+
+func (p *Profile) teardownRoutes(td *teardownState) (routes []*net.IPNet) {
+	if td.iface == "" {
+		return
+	}
+
+	for _, network := range td.networks {
+		ifaces, err := netconf.Get().GetRouteIfaces(network)
+		if err != nil {
+			continue
+		}
+
+		for _, iface := range ifaces {
+			if iface == td.iface {
+				routes = append(routes, network)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func (p *Profile) teardownLeftovers(td *teardownState) (leftovers []string) {
+	if td.iface != "" && ifaceExists(td.iface) {
+		leftovers = append(leftovers, TeardownInterface)
+	}
+	if len(p.teardownRoutes(td)) > 0 {
+		leftovers = append(leftovers, TeardownRoutes)
+	}
+	if p.dnsDirty {
+		leftovers = append(leftovers, TeardownDns)
+	}
+	return
+}
+
+func (p *Profile) repairTeardown(td *teardownState, leftovers []string) {
+	for _, leftover := range leftovers {
+		switch leftover {
+		case TeardownInterface:
+			if runtime.GOOS == "linux" {
+				_, _ = utils.ExecCombinedOutputLogged(
+					[]string{
+						"Cannot find device",
+					},
+					"ip", "link", "delete", td.iface,
+				)
+			}
+			break
+		case TeardownRoutes:
+			for _, network := range p.teardownRoutes(td) {
+				_ = deleteRoute(network, td.iface)
+			}
+			break
+		case TeardownDns:
+			p.dnsDirty = false
+
+			err := netconf.Get().ClearSplitDns(p.Id, td.iface)
+			if err != nil {
+				p.dnsDirty = true
+			}
+
+			if p.scutilDns {
+				err = utils.ClearScutilDns(p.Id)
+				if err != nil {
+					p.dnsDirty = true
+				}
+			}
+
+			if p.dnsServersSet {
+				err = netconf.Get().ClearDnsServers(p.Id, td.iface)
+				if err != nil {
+					p.dnsDirty = true
+				} else {
+					p.dnsServersSet = false
+				}
+			}
+			break
+		}
+	}
+}
+
+// Check that the interface, routes and DNS configuration were removed and
+// retry the removal of anything left
+func (p *Profile) verifyTeardown(td *teardownState) (leftovers []string) {
+	for i := 0; i < teardownRetries; i++ {
+		leftovers = p.teardownLeftovers(td)
+		if len(leftovers) == 0 {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"iface":      td.iface,
+			"leftovers":  leftovers,
+			"attempt":    i + 1,
+		}).Warn("profile: Teardown incomplete, retrying removal")
+
+		p.repairTeardown(td, leftovers)
+		time.Sleep(teardownWait)
+	}
+
+	leftovers = p.teardownLeftovers(td)
+
+	return
+}
+
+func recordDirtyTeardown(prflId, mode string, leftovers []string) {
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+		"leftovers":  leftovers,
+	}).Error("profile: Dirty teardown, resources left after disconnect")
+
+	stats.RecordDirtyTeardown(prflId, mode, leftovers)
+
+	evt := &event.Event{
+		Type: "dirty_teardown",
+		Data: &DirtyTeardown{
+			ProfileId: prflId,
+			Leftovers: leftovers,
+		},
+	}
+	evt.Init()
+}
@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func (p *Profile) getStatusPath() (pth string, err error) {
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		return
+	}
+
+	pth = filepath.Join(rootDir, p.Id+"-status")
+
+	return
+}
+
+func (p *Profile) updateOvpnTransfer() {
+	if p.statusPath == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(p.statusPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		lineSpl := strings.SplitN(strings.TrimSpace(line), ",", 2)
+		if len(lineSpl) != 2 {
+			continue
+		}
+
+		val, e := strconv.ParseInt(lineSpl[1], 10, 64)
+		if e != nil {
+			continue
+		}
+
+		switch lineSpl[0] {
+		case "TUN/TAP read bytes":
+			p.txBytes = val
+			break
+		case "TUN/TAP write bytes":
+			p.rxBytes = val
+			break
+		}
+	}
+}
+
+func (p *Profile) updateWgTransfer() (err error) {
+	iface := ""
+	if runtime.GOOS == "darwin" {
+		iface = p.Tuniface
+	} else {
+		iface = p.Iface
+	}
+
+	if iface == "" {
+		return
+	}
+
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such device",
+			"access interface",
+		},
+		p.wgPath, "show", iface,
+		"transfer",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != p.wgServerPublicKey {
+			continue
+		}
+
+		rx, e := strconv.ParseInt(fields[1], 10, 64)
+		if e != nil {
+			continue
+		}
+
+		tx, e := strconv.ParseInt(fields[2], 10, 64)
+		if e != nil {
+			continue
+		}
+
+		p.rxBytes = rx
+		p.txBytes = tx
+		return
+	}
+
+	return
+}
+
+func (p *Profile) GetTransfer() (rx, tx int64) {
+	if p.Mode != Wg {
+		p.updateOvpnTransfer()
+	}
+
+	rx = p.rxBytes
+	tx = p.txBytes
+
+	return
+}
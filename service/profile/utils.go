@@ -13,6 +13,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
 	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
 	"github.com/pritunl/pritunl-client-electron/service/sprofile"
 	"github.com/pritunl/pritunl-client-electron/service/update"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
@@ -21,6 +22,7 @@ import (
 
 var (
 	restartLock sync.Mutex
+	syncLock    sync.Mutex
 )
 
 func GetWgPath() string {
@@ -251,6 +253,8 @@ func UpdateSystemProfile(prfl *Profile, sPrfl *sprofile.Sprofile) {
 	}
 
 	prfl.Id = sPrfl.Id
+	prfl.Name = sPrfl.Name
+	prfl.Workspace = sPrfl.Workspace
 	prfl.Mode = lastMode
 	prfl.OrgId = sPrfl.OrganizationId
 	prfl.UserId = sPrfl.UserId
@@ -270,10 +274,86 @@ func UpdateSystemProfile(prfl *Profile, sPrfl *sprofile.Sprofile) {
 	prfl.ServerPublicKey = serverPublicKey
 	prfl.ServerBoxPublicKey = sPrfl.ServerBoxPublicKey
 	prfl.TokenTtl = sPrfl.TokenTtl
+	prfl.SplitDnsDomains = sPrfl.SplitDnsDomains
+	prfl.Env = sPrfl.Env
+	prfl.Hooks = sPrfl.Hooks
+	prfl.CredentialProvider = sPrfl.CredentialProvider
+	prfl.CredentialCacheTtl = sPrfl.CredentialCacheTtl
+	prfl.RoutePriority = sPrfl.RoutePriority
+	prfl.IncludeRoutes = sPrfl.IncludeRoutes
+	prfl.ExcludeRoutes = sPrfl.ExcludeRoutes
+	prfl.PersistTun = sPrfl.PersistTun
+	prfl.HttpProxy = sPrfl.HttpProxy
+	prfl.SocksProxy = sPrfl.SocksProxy
+	prfl.AlwaysOn = sPrfl.AlwaysOn
+	prfl.KillSwitch = sPrfl.KillSwitch
+	prfl.CredentialId = sPrfl.CredentialId
+	prfl.HostFailover = sPrfl.HostFailover
+	prfl.Verbosity = sPrfl.Verbosity
+	prfl.SplitDnsPushed = sPrfl.SplitDnsPushed
+	prfl.DnsHijackMode = sPrfl.DnsHijackMode
+	prfl.DisableIpv6 = sPrfl.DisableIpv6
+	prfl.SyslogTag = sPrfl.SyslogTag
+	prfl.WgTcpFallback = sPrfl.WgTcpFallback
+	prfl.Mtu = sPrfl.Mtu
+	prfl.MssFix = sPrfl.MssFix
+	prfl.MtuProbe = sPrfl.MtuProbe
+	prfl.SummarizeRoutes = sPrfl.SummarizeRoutes
+	prfl.ExpiresAt = sPrfl.ExpiresAt
 	prfl.Reconnect = true
 	prfl.SystemProfile = sPrfl
 }
 
+// Feature flags from the server override the settings provided by the
+// client when connecting
+func (p *Profile) applyFeatures() {
+	sPrfl := p.SystemProfile
+	if sPrfl == nil {
+		sPrfl = sprofile.Get(p.Id)
+	}
+	if sPrfl == nil || sPrfl.Features == nil {
+		return
+	}
+
+	flags := sPrfl.Features
+	if flags.KillSwitch != nil {
+		p.KillSwitch = *flags.KillSwitch
+	}
+	if flags.AlwaysOn != nil {
+		p.AlwaysOn = *flags.AlwaysOn
+	}
+	if flags.ForceDns != nil {
+		p.ForceDns = *flags.ForceDns
+	}
+	if flags.DisableIpv6 != nil {
+		p.DisableIpv6 = *flags.DisableIpv6
+	}
+	if flags.PersistTun != nil {
+		p.PersistTun = *flags.PersistTun
+	}
+	if flags.HostFailover != nil {
+		p.HostFailover = *flags.HostFailover
+	}
+	if flags.WgTcpFallback != nil {
+		p.WgTcpFallback = *flags.WgTcpFallback
+	}
+	if flags.DnsHijackMode != "" {
+		p.DnsHijackMode = sprofile.FilterDnsHijackMode(flags.DnsHijackMode)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id":      p.Id,
+		"kill_switch":     p.KillSwitch,
+		"always_on":       p.AlwaysOn,
+		"force_dns":       p.ForceDns,
+		"disable_ipv6":    p.DisableIpv6,
+		"persist_tun":     p.PersistTun,
+		"host_failover":   p.HostFailover,
+		"wg_tcp_fallback": p.WgTcpFallback,
+		"dns_hijack_mode": p.DnsHijackMode,
+	}).Info("profile: Applied server feature flags")
+}
+
 func ImportSystemProfile(sPrfl *sprofile.Sprofile) (prfl *Profile) {
 	prfl = &Profile{
 		Id: sPrfl.Id,
@@ -323,6 +403,20 @@ func GetProfiles() (prfls map[string]*Profile) {
 	return
 }
 
+func GetTapOwners() (owners map[string]string) {
+	owners = map[string]string{}
+
+	Profiles.RLock()
+	for _, prfl := range Profiles.m {
+		if prfl.tap != "" && prfl.tap != "null" {
+			owners[prfl.tap] = prfl.Id
+		}
+	}
+	Profiles.RUnlock()
+
+	return
+}
+
 func GetProfilesId() (prflsId set.Set) {
 	prflsId = set.NewSet()
 
@@ -360,14 +454,15 @@ func RestartProfiles(resetNet bool) (err error) {
 	time.Sleep(resetWait)
 
 	if resetNet {
-		utils.ResetNetworking()
+		netconf.Get().ResetNetworking()
 		time.Sleep(netResetWait)
 	}
 
 	for _, prfl := range prfls2 {
+		prfl := prfl
 		if prfl.Reconnect {
 			go func() {
-				e := prfl.Start(false, true, true)
+				e := startPooled(prfl, false, true, true)
 				if e != nil {
 					logrus.WithFields(logrus.Fields{
 						"profile_id": prfl.Id,
@@ -382,21 +477,36 @@ func RestartProfiles(resetNet bool) (err error) {
 }
 
 func SyncSystemProfiles() (err error) {
+	syncLock.Lock()
+	defer syncLock.Unlock()
+
 	sprfls, err := sprofile.GetAll()
 	if err != nil {
 		return
 	}
 
 	prfls := GetProfiles()
+	boot := autostartBoot()
 
 	update := false
 	waiter := sync.WaitGroup{}
 
 	for _, sPrfl := range sprfls {
+		sPrfl := sPrfl
 		curPrfl := prfls[sPrfl.Id]
 
+		if sPrfl.State && (Paused(sPrfl.Id) || PortalWaiting(sPrfl.Id) ||
+			sPrfl.Expired()) {
+
+			continue
+		}
+
 		if sPrfl.State {
 			if curPrfl == nil {
+				if delayAutostart(sPrfl, boot) {
+					continue
+				}
+
 				prfl := ImportSystemProfile(sPrfl)
 
 				update = true
@@ -409,13 +519,12 @@ func SyncSystemProfiles() (err error) {
 							"profile_id": prfl.Id,
 						}).Info("profile: Profile not ready, waiting")
 					} else {
-						err = prfl.Start(false, false, false)
-						if err != nil {
+						e := startPooled(prfl, false, false, false)
+						if e != nil {
 							logrus.WithFields(logrus.Fields{
 								"profile_id": prfl.Id,
-								"error":      err,
+								"error":      e,
 							}).Error("profile: Failed to start system profile")
-							err = nil
 						}
 					}
 
@@ -431,13 +540,12 @@ func SyncSystemProfiles() (err error) {
 					curPrfl.Stop()
 
 					prfl := ImportSystemProfile(sPrfl)
-					err = prfl.Start(false, false, false)
-					if err != nil {
+					e := startPooled(prfl, false, false, false)
+					if e != nil {
 						logrus.WithFields(logrus.Fields{
 							"profile_id": curPrfl.Id,
-							"error":      err,
+							"error":      e,
 						}).Error("profile: Failed to start system profile")
-						err = nil
 					}
 
 					waiter.Done()
@@ -449,6 +557,7 @@ func SyncSystemProfiles() (err error) {
 
 			go func() {
 				curPrfl.Stop()
+				ReleaseKillSwitch(curPrfl.Id)
 				waiter.Done()
 			}()
 		}
@@ -506,7 +615,14 @@ func watchSystemProfiles() {
 			_ = update.Check()
 		}
 
-		err := SyncSystemProfiles()
+		err := checkManaged()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("profile: Failed to reconcile managed profiles")
+		}
+
+		err = SyncSystemProfiles()
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"error": err,
@@ -517,4 +633,5 @@ func watchSystemProfiles() {
 
 func WatchSystemProfiles() {
 	go watchSystemProfiles()
+	go watchNotes()
 }
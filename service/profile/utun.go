@@ -0,0 +1,546 @@
+package profile
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/network"
+	"github.com/pritunl/pritunl-client-electron/service/tuntap"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	wgUapiTimeout = 5 * time.Second
+	wgUtunTimeout = 5 * time.Second
+)
+
+// Directory of the wireguard-go control sockets
+var wgUapiDir = "/var/run/wireguard"
+
+// WireGuard device of the utun mode, wireguard-go runs the data plane on
+// the utun device created by the service. The device is removed when
+// wireguard-go exits and the service closes its descriptor.
+type wgUtunDevice struct {
+	proc   *os.Process
+	file   *os.File
+	exited chan bool
+}
+
+// Reap the wireguard-go process, the output is discarded so the process
+// is not killed by a broken pipe when the service is upgraded
+func (d *wgUtunDevice) wait(prflId string) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	state, err := d.proc.Wait()
+	close(d.exited)
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+		"pid":        d.proc.Pid,
+		"state":      fmt.Sprintf("%v", state),
+		"error":      err,
+	}).Info("profile: WireGuard userspace process exited")
+}
+
+func (d *wgUtunDevice) close() {
+	select {
+	case <-d.exited:
+	default:
+		_ = d.proc.Signal(syscall.SIGTERM)
+
+		select {
+		case <-d.exited:
+		case <-time.After(wgUtunTimeout):
+			_ = d.proc.Kill()
+			<-d.exited
+		}
+	}
+
+	if d.file != nil {
+		_ = d.file.Close()
+		d.file = nil
+	}
+}
+
+// Generate a WireGuard key pair without the wg tool
+func generateWgKeyPair() (privateKey, publicKey string, err error) {
+	key := make([]byte, curve25519.ScalarSize)
+
+	_, err = rand.Read(key)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "profile: Failed to generate private key"),
+		}
+		return
+	}
+
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+
+	pubKey, err := curve25519.X25519(key, curve25519.Basepoint)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to get public key"),
+		}
+		return
+	}
+
+	privateKey = base64.StdEncoding.EncodeToString(key)
+	publicKey = base64.StdEncoding.EncodeToString(pubKey)
+
+	return
+}
+
+// Keys are hex encoded in the WireGuard control protocol
+func wgKeyHex(key string) (hexKey string, err error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "profile: Failed to parse WireGuard key"),
+		}
+		return
+	}
+
+	if len(data) != curve25519.PointSize {
+		err = &errortypes.ParseError{
+			errors.New("profile: Invalid WireGuard key length"),
+		}
+		return
+	}
+
+	hexKey = hex.EncodeToString(data)
+
+	return
+}
+
+// Set operation of the WireGuard control protocol replacing the peer
+func wgUapiConfig(privateKey, publicKey, endpoint string,
+	allowedIps []string) (req string, err error) {
+
+	privateKeyHex, err := wgKeyHex(privateKey)
+	if err != nil {
+		return
+	}
+
+	publicKeyHex, err := wgKeyHex(publicKey)
+	if err != nil {
+		return
+	}
+
+	lines := []string{
+		"set=1",
+		"private_key=" + privateKeyHex,
+		"replace_peers=true",
+		"public_key=" + publicKeyHex,
+		"endpoint=" + endpoint,
+		"replace_allowed_ips=true",
+	}
+
+	for _, allowedIp := range allowedIps {
+		lines = append(lines, "allowed_ip="+allowedIp)
+	}
+
+	req = strings.Join(lines, "\n") + "\n"
+
+	return
+}
+
+// Latest handshake of the peer from a get operation, zero without a
+// handshake
+func parseWgHandshake(lines []string, publicKeyHex string) (
+	handshake int) {
+
+	peer := ""
+	for _, line := range lines {
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "public_key":
+			peer = val
+		case "last_handshake_time_sec":
+			if peer == publicKeyHex {
+				handshake, _ = strconv.Atoi(val)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func wgUapiPath(iface string) string {
+	return filepath.Join(wgUapiDir, iface+".sock")
+}
+
+// Send an operation to the wireguard-go control socket of the interface
+// and return the response lines without the errno
+func wgUapi(iface, req string) (lines []string, err error) {
+	conn, err := net.DialTimeout("unix", wgUapiPath(iface), wgUapiTimeout)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to connect to wg socket"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(wgUapiTimeout))
+
+	_, err = conn.Write([]byte(req + "\n"))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to write wg socket"),
+		}
+		return
+	}
+
+	lines = []string{}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, "errno=") {
+			if line != "errno=0" {
+				err = &errortypes.RequestError{
+					errors.Newf("profile: WireGuard operation "+
+						"failed with %s", line),
+				}
+				return
+			}
+			return
+		}
+
+		lines = append(lines, line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to read wg socket"),
+		}
+		return
+	}
+
+	err = &errortypes.RequestError{
+		errors.New("profile: WireGuard operation returned no errno"),
+	}
+
+	return
+}
+
+// Routes of the allowed IPs, default routes are split in two halves to
+// take precedence over the default route of the system
+func utunRoutes(allowedIps []string) (networks []*net.IPNet,
+	hasDefault bool, err error) {
+
+	networks = []*net.IPNet{}
+
+	for _, allowedIp := range allowedIps {
+		_, network, e := net.ParseCIDR(allowedIp)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "profile: Failed to parse route network"),
+			}
+			return
+		}
+
+		ones, bits := network.Mask.Size()
+		if ones != 0 {
+			networks = append(networks, network)
+			continue
+		}
+
+		hasDefault = true
+
+		upper := make(net.IP, bits/8)
+		upper[0] = 0x80
+		networks = append(networks,
+			&net.IPNet{
+				IP:   make(net.IP, bits/8),
+				Mask: net.CIDRMask(1, bits),
+			},
+			&net.IPNet{
+				IP:   upper,
+				Mask: net.CIDRMask(1, bits),
+			},
+		)
+	}
+
+	return
+}
+
+// Interface address with the prefix of the tunnel network, a single
+// address uses a host prefix
+func parseUtunAddress(addr string) (ip net.IP, network *net.IPNet,
+	err error) {
+
+	if !strings.Contains(addr, "/") {
+		ip = net.ParseIP(addr)
+		if ip == nil {
+			err = &errortypes.ParseError{
+				errors.Newf("profile: Invalid address '%s'", addr),
+			}
+			return
+		}
+
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+
+		network = &net.IPNet{
+			IP:   ip.Mask(net.CIDRMask(bits, bits)),
+			Mask: net.CIDRMask(bits, bits),
+		}
+		return
+	}
+
+	ip, network, err = net.ParseCIDR(addr)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "profile: Invalid address '%s'", addr),
+		}
+		return
+	}
+
+	return
+}
+
+func waitWgUapi(iface string) (err error) {
+	start := time.Now()
+
+	for {
+		_, err = os.Stat(wgUapiPath(iface))
+		if err == nil {
+			return
+		}
+
+		if time.Since(start) > wgUtunTimeout {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "profile: Timeout waiting for wg socket"),
+			}
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Start wireguard-go on a utun device created by the service, the peer is
+// configured through the control socket and the addresses, routes and
+// DNS are applied by the service
+func (p *Profile) confWgUtun(data *WgConf) (err error) {
+	wgGoPath := GetWgUserspacePath()
+	if wgGoPath == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: WireGuard utun mode enabled " +
+				"without wireguard-go"),
+		}
+		return
+	}
+
+	file, iface, err := tuntap.OpenUtun()
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command(wgGoPath, "-f", iface)
+	cmd.Env = append(os.Environ(), "WG_TUN_FD=3")
+	cmd.ExtraFiles = []*os.File{file}
+
+	err = cmd.Start()
+	if err != nil {
+		_ = file.Close()
+		err = &ExecError{
+			errors.Wrap(err, "profile: Failed to start wireguard-go"),
+		}
+		return
+	}
+
+	dev := &wgUtunDevice{
+		proc:   cmd.Process,
+		file:   file,
+		exited: make(chan bool),
+	}
+	go dev.wait(p.Id)
+
+	p.wgUtun = dev
+	p.Tuniface = iface
+
+	err = waitWgUapi(iface)
+	if err != nil {
+		return
+	}
+
+	endpoint := fmt.Sprintf("%s:%d", data.Hostname, data.Port)
+	if p.wgTcpRelay != nil {
+		endpoint = p.wgTcpRelay.LocalAddr()
+	}
+
+	endpointAddr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrapf(err, "profile: Failed to resolve '%s'", endpoint),
+		}
+		return
+	}
+
+	allowedIps := p.wgAllowedIps(data)
+
+	req, err := wgUapiConfig(p.PrivateKeyWg, data.PublicKey,
+		endpointAddr.String(), allowedIps)
+	if err != nil {
+		return
+	}
+
+	_, err = wgUapi(iface, req)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range []string{data.Address, data.Address6} {
+		if addr == "" {
+			continue
+		}
+
+		ip, network, e := parseUtunAddress(addr)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = tuntap.SetUtunAddress(iface, ip, network)
+		if err != nil {
+			return
+		}
+	}
+
+	mtu := p.getMtu(data.Hostname)
+	p.TunnelMtu = mtu
+	if mtu > 0 {
+		err = tuntap.SetUtunMtu(iface, mtu)
+		if err != nil {
+			return
+		}
+	}
+
+	networks, hasDefault, err := utunRoutes(allowedIps)
+	if err != nil {
+		return
+	}
+
+	// The endpoint is routed to the physical gateway before the default
+	// route is moved into the tunnel
+	if hasDefault {
+		err = p.routeHostGateway(data.Hostname)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, network := range networks {
+		err = addRoute(network, iface)
+		if err != nil {
+			return
+		}
+	}
+
+	if !p.DisableDns && len(data.DnsServers) > 0 {
+		p.scutilDns = true
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"tun_iface":  iface,
+		"pid":        cmd.Process.Pid,
+		"routes":     len(networks),
+	}).Info("profile: WireGuard utun device configured")
+
+	return
+}
+
+func (p *Profile) updateWgUtunHandshake() (err error) {
+	publicKeyHex, err := wgKeyHex(p.wgServerPublicKey)
+	if err != nil {
+		return
+	}
+
+	lines, err := wgUapi(p.Tuniface, "get=1\n")
+	if err != nil {
+		return
+	}
+
+	p.wgHandshake = parseWgHandshake(lines, publicKeyHex)
+
+	return
+}
+
+// Adopt the wireguard-go process of the previous service, the utun device
+// stays open in the process
+func (p *Profile) adoptWgUtun(pid int) (err error) {
+	if pid == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: Missing wireguard-go process"),
+		}
+		return
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		err = &ExecError{
+			errors.Wrap(err, "profile: Failed to find wireguard-go process"),
+		}
+		return
+	}
+
+	dev := &wgUtunDevice{
+		proc:   proc,
+		exited: make(chan bool),
+	}
+	go dev.wait(p.Id)
+
+	p.wgUtun = dev
+
+	return
+}
+
+func (p *Profile) clearWgUtun() {
+	dev := p.wgUtun
+	if dev != nil {
+		dev.close()
+	}
+
+	if p.Iface != "" {
+		network.InterfaceRelease(p.Iface)
+	}
+}
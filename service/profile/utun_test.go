@@ -0,0 +1,272 @@
+package profile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestGenerateWgKeyPair(t *testing.T) {
+	privateKey, publicKey, err := generateWgKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil || len(key) != curve25519.ScalarSize {
+		t.Fatalf("invalid private key %q", privateKey)
+	}
+
+	if key[0]&7 != 0 || key[31]&128 != 0 || key[31]&64 == 0 {
+		t.Fatalf("private key not clamped %x", key)
+	}
+
+	pubKey, err := curve25519.X25519(key, curve25519.Basepoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if base64.StdEncoding.EncodeToString(pubKey) != publicKey {
+		t.Fatalf("public key %q does not match private key", publicKey)
+	}
+}
+
+func TestWgKeyHex(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, 32)
+
+	hexKey, err := wgKeyHex(base64.StdEncoding.EncodeToString(key) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexKey != hex.EncodeToString(key) {
+		t.Fatalf("got %q", hexKey)
+	}
+
+	for _, invalid := range []string{"", "not base64", "YWJj"} {
+		_, err = wgKeyHex(invalid)
+		if err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestWgUapiConfig(t *testing.T) {
+	privateKey := base64.StdEncoding.EncodeToString(
+		bytes.Repeat([]byte{0x01}, 32))
+	publicKey := base64.StdEncoding.EncodeToString(
+		bytes.Repeat([]byte{0x02}, 32))
+
+	req, err := wgUapiConfig(privateKey, publicKey, "192.0.2.1:51820",
+		[]string{"10.0.0.0/8", "::/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"set=1",
+		"private_key=" + strings.Repeat("01", 32),
+		"replace_peers=true",
+		"public_key=" + strings.Repeat("02", 32),
+		"endpoint=192.0.2.1:51820",
+		"replace_allowed_ips=true",
+		"allowed_ip=10.0.0.0/8",
+		"allowed_ip=::/0",
+	}, "\n") + "\n"
+
+	if req != expected {
+		t.Fatalf("got request\n%s\nexpected\n%s", req, expected)
+	}
+
+	_, err = wgUapiConfig("", publicKey, "192.0.2.1:51820", nil)
+	if err == nil {
+		t.Fatal("expected error for missing private key")
+	}
+}
+
+func TestParseWgHandshake(t *testing.T) {
+	lines := []string{
+		"private_key=" + strings.Repeat("01", 32),
+		"listen_port=51820",
+		"public_key=" + strings.Repeat("03", 32),
+		"last_handshake_time_sec=100",
+		"public_key=" + strings.Repeat("02", 32),
+		"endpoint=192.0.2.1:51820",
+		"last_handshake_time_sec=1700000000",
+		"last_handshake_time_nsec=0",
+	}
+
+	handshake := parseWgHandshake(lines, strings.Repeat("02", 32))
+	if handshake != 1700000000 {
+		t.Fatalf("got %d", handshake)
+	}
+
+	handshake = parseWgHandshake(lines, strings.Repeat("04", 32))
+	if handshake != 0 {
+		t.Fatalf("got %d for missing peer", handshake)
+	}
+}
+
+func TestUtunRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowedIps []string
+		networks   []string
+		hasDefault bool
+	}{
+		{
+			"split",
+			[]string{"10.0.0.0/8", "fd00::/64"},
+			[]string{"10.0.0.0/8", "fd00::/64"},
+			false,
+		},
+		{
+			"ipv4 default",
+			[]string{"0.0.0.0/0"},
+			[]string{"0.0.0.0/1", "128.0.0.0/1"},
+			true,
+		},
+		{
+			"ipv6 default",
+			[]string{"10.0.0.0/8", "::/0"},
+			[]string{"10.0.0.0/8", "::/1", "8000::/1"},
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		networks, hasDefault, err := utunRoutes(test.allowedIps)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		result := []string{}
+		for _, network := range networks {
+			result = append(result, network.String())
+		}
+
+		if !reflect.DeepEqual(result, test.networks) {
+			t.Errorf("%s: got %v, expected %v", test.name, result,
+				test.networks)
+		}
+		if hasDefault != test.hasDefault {
+			t.Errorf("%s: got default %t", test.name, hasDefault)
+		}
+	}
+
+	_, _, err := utunRoutes([]string{"10.0.0.0"})
+	if err == nil {
+		t.Fatal("expected error for invalid network")
+	}
+}
+
+func TestParseUtunAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		ip      string
+		network string
+	}{
+		{"10.8.0.2/24", "10.8.0.2", "10.8.0.0/24"},
+		{"10.8.0.2", "10.8.0.2", "10.8.0.2/32"},
+		{"fd00::2/64", "fd00::2", "fd00::/64"},
+		{"fd00::2", "fd00::2", "fd00::2/128"},
+	}
+
+	for _, test := range tests {
+		ip, network, err := parseUtunAddress(test.addr)
+		if err != nil {
+			t.Errorf("%s: %s", test.addr, err)
+			continue
+		}
+
+		if ip.String() != test.ip || network.String() != test.network {
+			t.Errorf("%s: got %s %s", test.addr, ip, network)
+		}
+	}
+
+	_, _, err := parseUtunAddress("invalid")
+	if err == nil {
+		t.Fatal("expected error for invalid address")
+	}
+}
+
+// Control socket answering one operation with the response lines
+func serveWgUapi(t *testing.T, iface string, response []string) (
+	requests chan string) {
+
+	wgUapiDir = t.TempDir()
+	t.Cleanup(func() {
+		wgUapiDir = "/var/run/wireguard"
+	})
+
+	lstnr, err := net.Listen("unix", wgUapiPath(iface))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		lstnr.Close()
+	})
+
+	requests = make(chan string, 1)
+
+	go func() {
+		conn, e := lstnr.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+
+		req := []string{}
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				break
+			}
+			req = append(req, scanner.Text())
+		}
+		requests <- strings.Join(req, "\n")
+
+		_, _ = conn.Write([]byte(strings.Join(response, "\n") + "\n\n"))
+	}()
+
+	return
+}
+
+func TestWgUapi(t *testing.T) {
+	requests := serveWgUapi(t, "utun9", []string{
+		"public_key=" + strings.Repeat("02", 32),
+		"last_handshake_time_sec=5",
+		"errno=0",
+	})
+
+	lines, err := wgUapi("utun9", "get=1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req := <-requests; req != "get=1" {
+		t.Fatalf("got request %q", req)
+	}
+
+	if len(lines) != 2 || lines[1] != "last_handshake_time_sec=5" {
+		t.Fatalf("got lines %v", lines)
+	}
+}
+
+func TestWgUapiError(t *testing.T) {
+	serveWgUapi(t, "utun9", []string{
+		"errno=22",
+	})
+
+	_, err := wgUapi("utun9", "set=1\nprivate_key=invalid\n")
+	if err == nil {
+		t.Fatal("expected error for errno response")
+	}
+}
@@ -0,0 +1,53 @@
+package profile
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultVerbosity = 2
+	MaxVerbosity     = 11
+)
+
+func FilterVerbosity(level int) int {
+	if level < 0 {
+		return 0
+	} else if level > MaxVerbosity {
+		return MaxVerbosity
+	}
+	return level
+}
+
+func (p *Profile) getVerbosity() int {
+	if p.Verbosity > 0 {
+		return p.Verbosity
+	}
+	return DefaultVerbosity
+}
+
+// Update the verbosity of a running OpenVPN process through the management
+// interface, WireGuard tunnels are run by the system implementation which
+// has no per interface log level
+func (p *Profile) UpdateVerbosity(level int) (err error) {
+	p.Verbosity = FilterVerbosity(level)
+
+	if p.Mode == Wg || p.managementPort == 0 || p.cmd == nil ||
+		p.cmd.Process == nil {
+
+		return
+	}
+
+	err = p.sendManagementCommand(fmt.Sprintf("verb %d", p.getVerbosity()))
+	if err != nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"verbosity":  p.getVerbosity(),
+	}).Info("profile: Updated process verbosity")
+
+	return
+}
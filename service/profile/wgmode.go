@@ -0,0 +1,98 @@
+package profile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	WgModeKernel    = "kernel"
+	WgModeUserspace = "userspace"
+	WgModeUtun      = "utun"
+)
+
+var wgModeOnce = sync.Once{}
+
+func GetWgUserspacePath() string {
+	path, _ := exec.LookPath("wireguard-go")
+	return path
+}
+
+// Native utun mode on macOS, the service creates the utun device and
+// configures it without wg-quick
+func wgUtunMode() bool {
+	return runtime.GOOS == "darwin" &&
+		config.Config.WireguardMode == WgModeUtun
+}
+
+func wgKernelAvailable() bool {
+	_, err := os.Stat("/sys/module/wireguard")
+	return err == nil
+}
+
+// wg-quick only uses the userspace implementation named by
+// WG_QUICK_USERSPACE_IMPLEMENTATION when the kernel interface cannot be
+// created, userspace mode fails instead of silently using the kernel
+// module when the module is loaded
+func configureWgMode() (err error) {
+	if runtime.GOOS != "linux" ||
+		config.Config.WireguardMode != WgModeUserspace {
+
+		return
+	}
+
+	pth := GetWgUserspacePath()
+	if pth == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("profile: WireGuard userspace mode enabled " +
+				"without wireguard-go"),
+		}
+		return
+	}
+
+	if wgKernelAvailable() {
+		err = &errortypes.PreconditionError{
+			errors.New("profile: WireGuard userspace mode unavailable " +
+				"with the kernel module loaded"),
+		}
+		return
+	}
+
+	os.Setenv("WG_QUICK_USERSPACE_IMPLEMENTATION", pth)
+
+	wgModeOnce.Do(func() {
+		logrus.WithFields(logrus.Fields{
+			"path": pth,
+		}).Info("profile: WireGuard userspace mode enabled")
+	})
+
+	return
+}
+
+// Userspace interfaces are tun devices, kernel interfaces have no tun flags
+func checkWgUserspace(iface string) (err error) {
+	if runtime.GOOS != "linux" ||
+		config.Config.WireguardMode != WgModeUserspace {
+
+		return
+	}
+
+	_, err = os.Stat(filepath.Join("/sys/class/net", iface, "tun_flags"))
+	if err != nil {
+		err = &errortypes.PreconditionError{
+			errors.Wrap(err, "profile: WireGuard interface not created "+
+				"by the userspace implementation"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,219 @@
+package profile
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+// WireGuard datagrams are carried over TCP using udptunnel framing, each
+// datagram is prefixed with a two byte big-endian length.
+const (
+	wgTcpDialTimeout = 10 * time.Second
+	wgTcpMaxPacket   = 65535
+)
+
+type wgTcpRelay struct {
+	remote  string
+	udpConn *net.UDPConn
+	tcpConn net.Conn
+	peer    *net.UDPAddr
+	lock    sync.Mutex
+	closed  bool
+}
+
+func newWgTcpRelay(host string, port int) (relay *wgTcpRelay, err error) {
+	relay = &wgTcpRelay{
+		remote: net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+
+	dialer := &net.Dialer{
+		Timeout: wgTcpDialTimeout,
+		Control: wgTcpControl,
+	}
+
+	relay.tcpConn, err = dialer.Dial("tcp", relay.remote)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "profile: Failed to connect wg tcp transport"),
+		}
+		return
+	}
+
+	relay.udpConn, err = net.ListenUDP("udp", &net.UDPAddr{
+		IP: net.IPv4(127, 0, 0, 1),
+	})
+	if err != nil {
+		relay.tcpConn.Close()
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "profile: Failed to listen wg tcp relay"),
+		}
+		return
+	}
+
+	return
+}
+
+func (r *wgTcpRelay) LocalAddr() string {
+	return r.udpConn.LocalAddr().String()
+}
+
+func (r *wgTcpRelay) udpToTcp() (err error) {
+	buf := make([]byte, 2+wgTcpMaxPacket)
+
+	for {
+		n, addr, e := r.udpConn.ReadFromUDP(buf[2:])
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read wg tcp relay"),
+			}
+			return
+		}
+
+		r.lock.Lock()
+		r.peer = addr
+		r.lock.Unlock()
+
+		binary.BigEndian.PutUint16(buf[:2], uint16(n))
+
+		_, e = r.tcpConn.Write(buf[:2+n])
+		if e != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(e, "profile: Failed to write wg tcp transport"),
+			}
+			return
+		}
+	}
+}
+
+func (r *wgTcpRelay) tcpToUdp() (err error) {
+	header := make([]byte, 2)
+	buf := make([]byte, wgTcpMaxPacket)
+
+	for {
+		_, e := io.ReadFull(r.tcpConn, header)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read wg tcp transport"),
+			}
+			return
+		}
+
+		n := int(binary.BigEndian.Uint16(header))
+
+		_, e = io.ReadFull(r.tcpConn, buf[:n])
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "profile: Failed to read wg tcp transport"),
+			}
+			return
+		}
+
+		r.lock.Lock()
+		peer := r.peer
+		r.lock.Unlock()
+
+		if peer == nil {
+			continue
+		}
+
+		_, e = r.udpConn.WriteToUDP(buf[:n], peer)
+		if e != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(e, "profile: Failed to write wg tcp relay"),
+			}
+			return
+		}
+	}
+}
+
+func (p *Profile) runWgTcpRelay(name string, relay *wgTcpRelay,
+	handler func() error) {
+
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("profile: Panic")
+			panic(panc)
+		}
+	}()
+
+	defer p.trackRoutine(name)()
+
+	err := handler()
+
+	relay.lock.Lock()
+	closed := relay.closed
+	relay.lock.Unlock()
+
+	if err != nil && !closed {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": p.Id,
+			"remote":     relay.remote,
+			"error":      err,
+		}).Error("profile: WireGuard tcp transport failed")
+	}
+
+	relay.Close()
+}
+
+func (r *wgTcpRelay) Close() {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	r.closed = true
+	r.lock.Unlock()
+
+	_ = r.udpConn.Close()
+	_ = r.tcpConn.Close()
+}
+
+// The transport connection is routed to the physical gateway, the
+// endpoint of the tunnel is the local relay and is not excluded from the
+// tunnel routes by the WireGuard tools
+func (p *Profile) startWgTcp(data *WgConf) (err error) {
+	err = p.routeWgEndpoint(data)
+	if err != nil {
+		return
+	}
+
+	relay, err := newWgTcpRelay(data.Hostname, data.TcpPort)
+	if err != nil {
+		return
+	}
+	p.wgTcpRelay = relay
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": p.Id,
+		"remote":     relay.remote,
+		"local":      relay.LocalAddr(),
+	}).Info("profile: Using WireGuard tcp transport")
+
+	go p.runWgTcpRelay("wg_tcp_send", relay, relay.udpToTcp)
+	go p.runWgTcpRelay("wg_tcp_recv", relay, relay.tcpToUdp)
+
+	return
+}
+
+func (p *Profile) stopWgTcp() {
+	relay := p.wgTcpRelay
+	if relay == nil {
+		return
+	}
+	p.wgTcpRelay = nil
+
+	relay.Close()
+}
@@ -0,0 +1,25 @@
+package profile
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// wg-quick routes all traffic not marked with its fwmark into the tunnel,
+// mark the transport socket so it is routed outside of the tunnel.
+const wgTcpFwmark = 51820
+
+func wgTcpControl(network, address string, conn syscall.RawConn) (
+	err error) {
+
+	e := conn.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET,
+			unix.SO_MARK, wgTcpFwmark)
+	})
+	if e != nil {
+		err = e
+	}
+
+	return
+}
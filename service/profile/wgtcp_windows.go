@@ -0,0 +1,11 @@
+package profile
+
+import (
+	"syscall"
+)
+
+func wgTcpControl(network, address string, conn syscall.RawConn) (
+	err error) {
+
+	return
+}
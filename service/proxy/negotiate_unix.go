@@ -0,0 +1,28 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+// GSSAPI requires the system kerberos library which is not linked by the
+// service
+const NegotiateSupported = false
+
+type negotiator struct{}
+
+func newNegotiator(scheme, host string) (ngtr *negotiator, err error) {
+	err = &errortypes.PreconditionError{
+		errors.New("proxy: Integrated proxy authentication not supported"),
+	}
+	return
+}
+
+func (n *negotiator) Step(challenge []byte) (token []byte, err error) {
+	return
+}
+
+func (n *negotiator) Close() {
+}
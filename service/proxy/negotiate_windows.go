@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const NegotiateSupported = true
+
+const (
+	secpkgCredOutbound   = 2
+	securityNativeDrep   = 0x10
+	secbufferVersion     = 0
+	secbufferToken       = 2
+	iscReqAllocateMemory = 0x100
+	iscReqConnection     = 0x800
+	secEOk               = 0
+	secIContinueNeeded   = 0x90312
+	noConsoleSession     = 0xffffffff
+)
+
+var (
+	secur32                        = windows.NewLazySystemDLL("secur32.dll")
+	advapi32                       = windows.NewLazySystemDLL("advapi32.dll")
+	procAcquireCredentialsHandleW  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContextW = secur32.NewProc("InitializeSecurityContextW")
+	procDeleteSecurityContext      = secur32.NewProc("DeleteSecurityContext")
+	procFreeCredentialsHandle      = secur32.NewProc("FreeCredentialsHandle")
+	procFreeContextBuffer          = secur32.NewProc("FreeContextBuffer")
+	procImpersonateLoggedOnUser    = advapi32.NewProc("ImpersonateLoggedOnUser")
+)
+
+// SecHandle
+type secHandle struct {
+	Lower uintptr
+	Upper uintptr
+}
+
+// SecBuffer
+type secBuffer struct {
+	Size   uint32
+	Type   uint32
+	Buffer *byte
+}
+
+// SecBufferDesc
+type secBufferDesc struct {
+	Version uint32
+	Count   uint32
+	Buffers *secBuffer
+}
+
+// Security context of one proxy connection, the negotiate package uses
+// kerberos with the http service principal of the proxy and falls back to
+// ntlm
+type negotiator struct {
+	target  *uint16
+	cred    secHandle
+	ctx     secHandle
+	started bool
+}
+
+// Credentials are acquired while impersonating the console user, the
+// credentials handle references the logon session of the user after the
+// thread reverts to the service account
+func acquireUserCredentials(pkg string) (cred secHandle, err error) {
+	pkgPtr, err := windows.UTF16PtrFromString(pkg)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "proxy: Invalid security package"),
+		}
+		return
+	}
+
+	session := windows.WTSGetActiveConsoleSessionId()
+	if session == noConsoleSession {
+		err = &errortypes.NotFoundError{
+			errors.New("proxy: No user logged on to the console"),
+		}
+		return
+	}
+
+	var token windows.Token
+	err = windows.WTSQueryUserToken(session, &token)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "proxy: Failed to get console user token"),
+		}
+		return
+	}
+	defer token.Close()
+
+	runtime.LockOSThread()
+
+	ret, _, e := procImpersonateLoggedOnUser.Call(uintptr(token))
+	if ret == 0 {
+		runtime.UnlockOSThread()
+		err = &errortypes.ExecError{
+			errors.Wrap(e, "proxy: Failed to impersonate console user"),
+		}
+		return
+	}
+
+	var expiry int64
+	status, _, _ := procAcquireCredentialsHandleW.Call(
+		0,
+		uintptr(unsafe.Pointer(pkgPtr)),
+		secpkgCredOutbound,
+		0,
+		0,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+
+	// A thread that fails to revert remains locked and exits with the
+	// goroutine instead of running other goroutines as the user
+	if windows.RevertToSelf() == nil {
+		runtime.UnlockOSThread()
+	}
+
+	if status != secEOk {
+		err = &errortypes.RequestError{
+			errors.Wrapf(windows.Errno(status),
+				"proxy: Failed to acquire %s credentials", pkg),
+		}
+		return
+	}
+
+	return
+}
+
+func newNegotiator(scheme, host string) (ngtr *negotiator, err error) {
+	target, err := windows.UTF16PtrFromString("HTTP/" + host)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "proxy: Invalid proxy host"),
+		}
+		return
+	}
+
+	cred, err := acquireUserCredentials(scheme)
+	if err != nil {
+		return
+	}
+
+	ngtr = &negotiator{
+		target: target,
+		cred:   cred,
+	}
+
+	return
+}
+
+// Token for the next leg of the authentication, the first token is
+// generated without a challenge
+func (n *negotiator) Step(challenge []byte) (token []byte, err error) {
+	output := &secBuffer{
+		Type: secbufferToken,
+	}
+	outputDesc := &secBufferDesc{
+		Version: secbufferVersion,
+		Count:   1,
+		Buffers: output,
+	}
+
+	var inputDesc *secBufferDesc
+	if len(challenge) > 0 {
+		inputDesc = &secBufferDesc{
+			Version: secbufferVersion,
+			Count:   1,
+			Buffers: &secBuffer{
+				Size:   uint32(len(challenge)),
+				Type:   secbufferToken,
+				Buffer: &challenge[0],
+			},
+		}
+	}
+
+	var ctx *secHandle
+	if n.started {
+		ctx = &n.ctx
+	}
+
+	var attrs uint32
+	var expiry int64
+	status, _, _ := procInitializeSecurityContextW.Call(
+		uintptr(unsafe.Pointer(&n.cred)),
+		uintptr(unsafe.Pointer(ctx)),
+		uintptr(unsafe.Pointer(n.target)),
+		iscReqAllocateMemory|iscReqConnection,
+		0,
+		securityNativeDrep,
+		uintptr(unsafe.Pointer(inputDesc)),
+		0,
+		uintptr(unsafe.Pointer(&n.ctx)),
+		uintptr(unsafe.Pointer(outputDesc)),
+		uintptr(unsafe.Pointer(&attrs)),
+		uintptr(unsafe.Pointer(&expiry)),
+	)
+	if status != secEOk && status != secIContinueNeeded {
+		err = &errortypes.RequestError{
+			errors.Wrap(windows.Errno(status),
+				"proxy: Failed to initialize security context"),
+		}
+		return
+	}
+	n.started = true
+
+	if output.Buffer != nil {
+		token = make([]byte, output.Size)
+		copy(token, unsafe.Slice(output.Buffer, output.Size))
+		procFreeContextBuffer.Call(uintptr(unsafe.Pointer(output.Buffer)))
+	}
+
+	return
+}
+
+func (n *negotiator) Close() {
+	if n.started {
+		procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&n.ctx)))
+		n.started = false
+	}
+	procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&n.cred)))
+}
@@ -0,0 +1,139 @@
+// Proxy settings for connections to pritunl servers.
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+type contextKey struct{}
+
+func Parse(raw string, socks bool) (u *url.URL, err error) {
+	if !strings.Contains(raw, "://") {
+		if socks {
+			raw = "socks5://" + raw
+		} else {
+			raw = "http://" + raw
+		}
+	}
+
+	u, err = url.Parse(raw)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "proxy: Failed to parse proxy url"),
+		}
+		return
+	}
+
+	switch u.Scheme {
+	case "http":
+		if socks {
+			err = &errortypes.ParseError{
+				errors.Newf("proxy: Invalid socks proxy scheme '%s'",
+					u.Scheme),
+			}
+			return
+		}
+		break
+	case "socks5":
+		if !socks {
+			err = &errortypes.ParseError{
+				errors.Newf("proxy: Invalid http proxy scheme '%s'",
+					u.Scheme),
+			}
+			return
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("proxy: Unknown proxy scheme '%s'", u.Scheme),
+		}
+		return
+	}
+
+	if u.Hostname() == "" || u.Port() == "" {
+		err = &errortypes.ParseError{
+			errors.New("proxy: Proxy url requires host and port"),
+		}
+		return
+	}
+
+	return
+}
+
+// Get the proxy for a profile, the global proxy is used when the profile
+// does not set one. The http proxy takes priority over the socks proxy.
+func Get(httpProxy, socksProxy string) (u *url.URL, err error) {
+	if httpProxy == "" && socksProxy == "" {
+		httpProxy = config.Config.HttpProxy
+		socksProxy = config.Config.SocksProxy
+	}
+
+	if httpProxy != "" {
+		u, err = Parse(httpProxy, false)
+		return
+	}
+
+	if socksProxy != "" {
+		u, err = Parse(socksProxy, true)
+		return
+	}
+
+	return
+}
+
+// Set the proxy used for a request, a nil proxy connects directly
+func WithProxy(req *http.Request, u *url.URL) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, u))
+}
+
+// Proxy function for http transports, requests without a proxy set with
+// WithProxy use the global proxy
+func Func(req *http.Request) (u *url.URL, err error) {
+	u, ok := req.Context().Value(contextKey{}).(*url.URL)
+	if ok {
+		return
+	}
+
+	u, err = Get("", "")
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Proxy url with the password masked for client responses, a url that
+// cannot be parsed is masked entirely
+func Redact(raw string) string {
+	if raw == "" || !strings.Contains(raw, "@") {
+		return raw
+	}
+
+	prefix := ""
+	if !strings.Contains(raw, "://") {
+		prefix = "http://"
+	}
+
+	u, err := url.Parse(prefix + raw)
+	if err != nil {
+		return config.Masked
+	}
+
+	return strings.TrimPrefix(u.Redacted(), prefix)
+}
+
+// Keep the current proxy url when a client sends back the redacted url
+// from a previous response
+func Unredact(raw, cur string) string {
+	if raw != "" && raw != cur && raw == Redact(cur) {
+		return cur
+	}
+	return raw
+}
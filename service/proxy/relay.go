@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	relayTimeout = 10 * time.Second
+	relayLegs    = 3
+)
+
+// Local http proxy for the OpenVPN transport. Connect requests are
+// forwarded to the upstream proxy with integrated authentication using the
+// credentials of the logged in user, OpenVPN only supports proxy
+// authentication with a stored password.
+type Relay struct {
+	upstream *url.URL
+	listener net.Listener
+	lock     sync.Mutex
+	closed   bool
+}
+
+func NewRelay(upstream *url.URL) (relay *Relay, err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to listen proxy relay"),
+		}
+		return
+	}
+
+	relay = &Relay{
+		upstream: upstream,
+		listener: listener,
+	}
+
+	go relay.accept()
+
+	return
+}
+
+func (r *Relay) Addr() *net.TCPAddr {
+	return r.listener.Addr().(*net.TCPAddr)
+}
+
+func (r *Relay) Upstream() string {
+	return r.upstream.Hostname()
+}
+
+func (r *Relay) Close() {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	r.closed = true
+	r.lock.Unlock()
+
+	_ = r.listener.Close()
+}
+
+func (r *Relay) accept() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			r.lock.Lock()
+			closed := r.closed
+			r.lock.Unlock()
+
+			if !closed {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("proxy: Proxy relay accept failed")
+			}
+			return
+		}
+
+		go r.handle(conn)
+	}
+}
+
+func (r *Relay) handle(conn net.Conn) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("proxy: Panic")
+			panic(panc)
+		}
+	}()
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(relayTimeout))
+	client := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(client)
+	if err != nil {
+		return
+	}
+
+	if req.Method != http.MethodConnect {
+		_, _ = io.WriteString(conn,
+			"HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+
+	upConn, upstream, err := r.connect(req.Host)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"proxy":  r.upstream.Host,
+			"target": req.Host,
+			"error":  err,
+		}).Error("proxy: Failed to connect through upstream proxy")
+
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upConn.Close()
+
+	_, err = io.WriteString(conn,
+		"HTTP/1.1 200 Connection established\r\n\r\n")
+	if err != nil {
+		return
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	_ = upConn.SetDeadline(time.Time{})
+
+	go func() {
+		_, _ = io.Copy(upConn, client)
+		_ = upConn.Close()
+	}()
+
+	_, _ = io.Copy(conn, upstream)
+}
+
+// Schemes offered by the upstream proxy, negotiate is preferred as it
+// uses kerberos when available and falls back to ntlm
+func selectScheme(headers []string) string {
+	scheme := ""
+	for _, header := range headers {
+		name := strings.Fields(header)
+		if len(name) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(name[0]) {
+		case "negotiate":
+			return "Negotiate"
+		case "ntlm":
+			scheme = "NTLM"
+			break
+		}
+	}
+
+	return scheme
+}
+
+func getChallenge(headers []string, scheme string) (
+	challenge []byte, err error) {
+
+	for _, header := range headers {
+		fields := strings.Fields(header)
+		if len(fields) != 2 || !strings.EqualFold(fields[0], scheme) {
+			continue
+		}
+
+		challenge, err = base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "proxy: Failed to parse proxy challenge"),
+			}
+			return
+		}
+		return
+	}
+
+	err = &errortypes.RequestError{
+		errors.New("proxy: Upstream proxy rejected authentication"),
+	}
+	return
+}
+
+// Send the connect request to the upstream proxy, the first request is
+// sent without authentication to get the schemes supported by the proxy
+func (r *Relay) connect(target string) (conn net.Conn,
+	reader *bufio.Reader, err error) {
+
+	var ngtr *negotiator
+	defer func() {
+		if ngtr != nil {
+			ngtr.Close()
+		}
+		if err != nil && conn != nil {
+			_ = conn.Close()
+			conn = nil
+		}
+	}()
+
+	scheme := ""
+	var challenge []byte
+
+	for i := 0; i <= relayLegs; i++ {
+		if conn == nil {
+			conn, err = net.DialTimeout("tcp", r.upstream.Host, relayTimeout)
+			if err != nil {
+				err = &errortypes.RequestError{
+					errors.Wrap(err, "proxy: Failed to connect to proxy"),
+				}
+				return
+			}
+			_ = conn.SetDeadline(time.Now().Add(relayTimeout))
+			reader = bufio.NewReader(conn)
+		}
+
+		auth := ""
+		if ngtr != nil {
+			token, e := ngtr.Step(challenge)
+			if e != nil {
+				err = e
+				return
+			}
+			auth = fmt.Sprintf("Proxy-Authorization: %s %s\r\n",
+				scheme, base64.StdEncoding.EncodeToString(token))
+		}
+
+		_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n%s\r\n",
+			target, target, auth)
+		if err != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "proxy: Failed to write proxy request"),
+			}
+			return
+		}
+
+		resp, e := http.ReadResponse(reader, &http.Request{
+			Method: http.MethodConnect,
+		})
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "proxy: Failed to read proxy response"),
+			}
+			return
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return
+		}
+
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusProxyAuthRequired {
+			err = &errortypes.RequestError{
+				errors.Newf("proxy: Upstream proxy returned status %d",
+					resp.StatusCode),
+			}
+			return
+		}
+
+		headers := resp.Header.Values("Proxy-Authenticate")
+		if ngtr == nil {
+			scheme = selectScheme(headers)
+			if scheme == "" {
+				err = &errortypes.RequestError{
+					errors.New("proxy: Upstream proxy authentication " +
+						"scheme not supported"),
+				}
+				return
+			}
+
+			ngtr, err = newNegotiator(scheme, r.upstream.Hostname())
+			if err != nil {
+				return
+			}
+		} else {
+			challenge, err = getChallenge(headers, scheme)
+			if err != nil {
+				return
+			}
+		}
+
+		if resp.Close {
+			_ = conn.Close()
+			conn = nil
+		}
+	}
+
+	err = &errortypes.RequestError{
+		errors.New("proxy: Upstream proxy authentication not completed"),
+	}
+	return
+}
@@ -0,0 +1,52 @@
+// Reload applies config changes without stopping active profiles.
+package reload
+
+import (
+	"sync"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/watch"
+	"github.com/sirupsen/logrus"
+)
+
+var reloadLock = sync.Mutex{}
+
+// Timeouts and proxy settings are read from the config when used, the
+// log level, syslog sink and watches are reinitialized
+func Reload() (result *config.ReloadResult, err error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	result, err = config.Reload()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("reload: Failed to reload config")
+		return
+	}
+
+	logger.SetLevel()
+	logger.SetSyslog()
+	watch.Reload()
+
+	if len(result.RestartRequired) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"changed":          result.Changed,
+			"restart_required": result.RestartRequired,
+		}).Warn("reload: Config reloaded, some settings require restart")
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"changed": result.Changed,
+		}).Info("reload: Config reloaded")
+	}
+
+	evt := &event.Event{
+		Type: "config_reload",
+		Data: result,
+	}
+	evt.Init()
+
+	return
+}
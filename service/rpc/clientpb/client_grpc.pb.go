@@ -0,0 +1,485 @@
+// Management api of the client service, served on the api listener next to
+// the http handlers. Requests are authenticated with the auth key or an api
+// token in the auth-token metadata and the client must set the pritunl
+// user agent.
+//
+// Generate with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative client.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: client.proto
+
+package clientpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Client_ListProfiles_FullMethodName  = "/pritunl.client.v1.Client/ListProfiles"
+	Client_GetProfile_FullMethodName    = "/pritunl.client.v1.Client/GetProfile"
+	Client_PutProfile_FullMethodName    = "/pritunl.client.v1.Client/PutProfile"
+	Client_DeleteProfile_FullMethodName = "/pritunl.client.v1.Client/DeleteProfile"
+	Client_Connect_FullMethodName       = "/pritunl.client.v1.Client/Connect"
+	Client_Disconnect_FullMethodName    = "/pritunl.client.v1.Client/Disconnect"
+	Client_GetStatus_FullMethodName     = "/pritunl.client.v1.Client/GetStatus"
+	Client_WatchStatus_FullMethodName   = "/pritunl.client.v1.Client/WatchStatus"
+	Client_TailLog_FullMethodName       = "/pritunl.client.v1.Client/TailLog"
+)
+
+// ClientClient is the client API for Client service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ClientClient interface {
+	// Profiles stored by the service, equivalent to GET /sprofile
+	ListProfiles(ctx context.Context, in *ListProfilesRequest, opts ...grpc.CallOption) (*ListProfilesResponse, error)
+	GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*Profile, error)
+	// Create or replace a profile, equivalent to PUT /sprofile
+	PutProfile(ctx context.Context, in *PutProfileRequest, opts ...grpc.CallOption) (*Profile, error)
+	// Equivalent to DELETE /sprofile/:profile_id
+	DeleteProfile(ctx context.Context, in *DeleteProfileRequest, opts ...grpc.CallOption) (*DeleteProfileResponse, error)
+	// Connect a stored profile, equivalent to POST /profile
+	Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error)
+	// Equivalent to DELETE /profile/:profile_id
+	Disconnect(ctx context.Context, in *DisconnectRequest, opts ...grpc.CallOption) (*DisconnectResponse, error)
+	// Equivalent to GET /status
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// Current state of the active profiles followed by every change
+	WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (Client_WatchStatusClient, error)
+	// Last lines of the profile log, optionally followed by new output
+	TailLog(ctx context.Context, in *TailLogRequest, opts ...grpc.CallOption) (Client_TailLogClient, error)
+}
+
+type clientClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClientClient(cc grpc.ClientConnInterface) ClientClient {
+	return &clientClient{cc}
+}
+
+func (c *clientClient) ListProfiles(ctx context.Context, in *ListProfilesRequest, opts ...grpc.CallOption) (*ListProfilesResponse, error) {
+	out := new(ListProfilesResponse)
+	err := c.cc.Invoke(ctx, Client_ListProfiles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) GetProfile(ctx context.Context, in *GetProfileRequest, opts ...grpc.CallOption) (*Profile, error) {
+	out := new(Profile)
+	err := c.cc.Invoke(ctx, Client_GetProfile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) PutProfile(ctx context.Context, in *PutProfileRequest, opts ...grpc.CallOption) (*Profile, error) {
+	out := new(Profile)
+	err := c.cc.Invoke(ctx, Client_PutProfile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) DeleteProfile(ctx context.Context, in *DeleteProfileRequest, opts ...grpc.CallOption) (*DeleteProfileResponse, error) {
+	out := new(DeleteProfileResponse)
+	err := c.cc.Invoke(ctx, Client_DeleteProfile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error) {
+	out := new(ConnectResponse)
+	err := c.cc.Invoke(ctx, Client_Connect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) Disconnect(ctx context.Context, in *DisconnectRequest, opts ...grpc.CallOption) (*DisconnectResponse, error) {
+	out := new(DisconnectResponse)
+	err := c.cc.Invoke(ctx, Client_Disconnect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	out := new(Status)
+	err := c.cc.Invoke(ctx, Client_GetStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clientClient) WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (Client_WatchStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Client_ServiceDesc.Streams[0], Client_WatchStatus_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clientWatchStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Client_WatchStatusClient interface {
+	Recv() (*ProfileStatus, error)
+	grpc.ClientStream
+}
+
+type clientWatchStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *clientWatchStatusClient) Recv() (*ProfileStatus, error) {
+	m := new(ProfileStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *clientClient) TailLog(ctx context.Context, in *TailLogRequest, opts ...grpc.CallOption) (Client_TailLogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Client_ServiceDesc.Streams[1], Client_TailLog_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clientTailLogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Client_TailLogClient interface {
+	Recv() (*LogLine, error)
+	grpc.ClientStream
+}
+
+type clientTailLogClient struct {
+	grpc.ClientStream
+}
+
+func (x *clientTailLogClient) Recv() (*LogLine, error) {
+	m := new(LogLine)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ClientServer is the server API for Client service.
+// All implementations must embed UnimplementedClientServer
+// for forward compatibility
+type ClientServer interface {
+	// Profiles stored by the service, equivalent to GET /sprofile
+	ListProfiles(context.Context, *ListProfilesRequest) (*ListProfilesResponse, error)
+	GetProfile(context.Context, *GetProfileRequest) (*Profile, error)
+	// Create or replace a profile, equivalent to PUT /sprofile
+	PutProfile(context.Context, *PutProfileRequest) (*Profile, error)
+	// Equivalent to DELETE /sprofile/:profile_id
+	DeleteProfile(context.Context, *DeleteProfileRequest) (*DeleteProfileResponse, error)
+	// Connect a stored profile, equivalent to POST /profile
+	Connect(context.Context, *ConnectRequest) (*ConnectResponse, error)
+	// Equivalent to DELETE /profile/:profile_id
+	Disconnect(context.Context, *DisconnectRequest) (*DisconnectResponse, error)
+	// Equivalent to GET /status
+	GetStatus(context.Context, *GetStatusRequest) (*Status, error)
+	// Current state of the active profiles followed by every change
+	WatchStatus(*WatchStatusRequest, Client_WatchStatusServer) error
+	// Last lines of the profile log, optionally followed by new output
+	TailLog(*TailLogRequest, Client_TailLogServer) error
+	mustEmbedUnimplementedClientServer()
+}
+
+// UnimplementedClientServer must be embedded to have forward compatible implementations.
+type UnimplementedClientServer struct {
+}
+
+func (UnimplementedClientServer) ListProfiles(context.Context, *ListProfilesRequest) (*ListProfilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProfiles not implemented")
+}
+func (UnimplementedClientServer) GetProfile(context.Context, *GetProfileRequest) (*Profile, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProfile not implemented")
+}
+func (UnimplementedClientServer) PutProfile(context.Context, *PutProfileRequest) (*Profile, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutProfile not implemented")
+}
+func (UnimplementedClientServer) DeleteProfile(context.Context, *DeleteProfileRequest) (*DeleteProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProfile not implemented")
+}
+func (UnimplementedClientServer) Connect(context.Context, *ConnectRequest) (*ConnectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedClientServer) Disconnect(context.Context, *DisconnectRequest) (*DisconnectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Disconnect not implemented")
+}
+func (UnimplementedClientServer) GetStatus(context.Context, *GetStatusRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedClientServer) WatchStatus(*WatchStatusRequest, Client_WatchStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedClientServer) TailLog(*TailLogRequest, Client_TailLogServer) error {
+	return status.Errorf(codes.Unimplemented, "method TailLog not implemented")
+}
+func (UnimplementedClientServer) mustEmbedUnimplementedClientServer() {}
+
+// UnsafeClientServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClientServer will
+// result in compilation errors.
+type UnsafeClientServer interface {
+	mustEmbedUnimplementedClientServer()
+}
+
+func RegisterClientServer(s grpc.ServiceRegistrar, srv ClientServer) {
+	s.RegisterService(&Client_ServiceDesc, srv)
+}
+
+func _Client_ListProfiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProfilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).ListProfiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_ListProfiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).ListProfiles(ctx, req.(*ListProfilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).GetProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_GetProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).GetProfile(ctx, req.(*GetProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_PutProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).PutProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_PutProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).PutProfile(ctx, req.(*PutProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_DeleteProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).DeleteProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_DeleteProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).DeleteProfile(ctx, req.(*DeleteProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_Connect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).Connect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_Connect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).Connect(ctx, req.(*ConnectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_Disconnect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisconnectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).Disconnect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_Disconnect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).Disconnect(ctx, req.(*DisconnectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClientServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Client_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClientServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Client_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClientServer).WatchStatus(m, &clientWatchStatusServer{stream})
+}
+
+type Client_WatchStatusServer interface {
+	Send(*ProfileStatus) error
+	grpc.ServerStream
+}
+
+type clientWatchStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *clientWatchStatusServer) Send(m *ProfileStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Client_TailLog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TailLogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClientServer).TailLog(m, &clientTailLogServer{stream})
+}
+
+type Client_TailLogServer interface {
+	Send(*LogLine) error
+	grpc.ServerStream
+}
+
+type clientTailLogServer struct {
+	grpc.ServerStream
+}
+
+func (x *clientTailLogServer) Send(m *LogLine) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Client_ServiceDesc is the grpc.ServiceDesc for Client service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Client_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pritunl.client.v1.Client",
+	HandlerType: (*ClientServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListProfiles",
+			Handler:    _Client_ListProfiles_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _Client_GetProfile_Handler,
+		},
+		{
+			MethodName: "PutProfile",
+			Handler:    _Client_PutProfile_Handler,
+		},
+		{
+			MethodName: "DeleteProfile",
+			Handler:    _Client_DeleteProfile_Handler,
+		},
+		{
+			MethodName: "Connect",
+			Handler:    _Client_Connect_Handler,
+		},
+		{
+			MethodName: "Disconnect",
+			Handler:    _Client_Disconnect_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Client_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _Client_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TailLog",
+			Handler:       _Client_TailLog_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "client.proto",
+}
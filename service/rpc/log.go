@@ -0,0 +1,94 @@
+package rpc
+
+import (
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/log"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/rpc/clientpb"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const tailLines = 100
+
+func (s *clientServer) TailLog(req *clientpb.TailLogRequest,
+	stream clientpb.Client_TailLogServer) (err error) {
+
+	prflId, err := filterProfileId(req.ProfileId)
+	if err != nil {
+		return
+	}
+
+	err = checkProfileWorkspace(utils.FilterStr(req.WorkspaceId), prflId)
+	if err != nil {
+		return
+	}
+
+	if profile.GetProfile(prflId) == nil && sprofile.Get(prflId) == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("rpc: Profile not found"),
+		}
+		return
+	}
+
+	// Follow before reading the log so no output is missed, output pushed
+	// while the log is read can be sent twice
+	var tail *log.Tail
+	if req.Follow {
+		tail = log.NewTail(prflId)
+		defer tail.Close()
+	}
+
+	data, err := log.GetProfileLog(prflId)
+	if err != nil {
+		return
+	}
+
+	count := int(req.Lines)
+	if count <= 0 {
+		count = tailLines
+	}
+
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		err = stream.Send(&clientpb.LogLine{
+			ProfileId: prflId,
+			Line:      line,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if tail == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return
+		case output := <-tail.Listen():
+			for _, line := range strings.Split(output, "\n") {
+				err = stream.Send(&clientpb.LogLine{
+					ProfileId: prflId,
+					Line:      line,
+				})
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+}
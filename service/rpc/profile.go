@@ -0,0 +1,481 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/logger"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/rpc/clientpb"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type clientServer struct {
+	clientpb.UnimplementedClientServer
+}
+
+func checkWorkspace(wsId string) (err error) {
+	if wsId != "" && sprofile.GetWorkspace(wsId) == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("rpc: Workspace not found"),
+		}
+		return
+	}
+
+	return
+}
+
+// Profiles are only accessible from the workspace of the profile, unknown
+// profiles are left to the method
+func checkProfileWorkspace(wsId, prflId string) (err error) {
+	err = checkWorkspace(wsId)
+	if err != nil {
+		return
+	}
+
+	found := false
+	prflWsId := ""
+	if sprfl := sprofile.Get(prflId); sprfl != nil {
+		found = true
+		prflWsId = sprfl.Workspace
+	} else if prfl := profile.GetProfile(prflId); prfl != nil {
+		found = true
+		prflWsId = prfl.Workspace
+	}
+
+	if found && prflWsId != wsId {
+		err = &errortypes.NotFoundError{
+			errors.New("rpc: Profile not found in workspace"),
+		}
+		return
+	}
+
+	return
+}
+
+func filterProfileId(prflId string) (id string, err error) {
+	id = utils.FilterStr(prflId)
+	if id == "" {
+		err = &errortypes.ParseError{
+			errors.New("rpc: Invalid profile ID"),
+		}
+		return
+	}
+
+	return
+}
+
+func newProfile(prfl *sprofile.SprofileClient) *clientpb.Profile {
+	note := stats.GetNotes(prfl.Id)
+	if note != nil {
+		prfl.LastError = note.LastError
+		prfl.LastErrorTime = note.LastErrorTime
+		prfl.LastConnected = note.LastConnected
+	}
+
+	return &clientpb.Profile{
+		Id:                 prfl.Id,
+		Name:               prfl.Name,
+		WorkspaceId:        prfl.Workspace,
+		State:              prfl.State,
+		Wg:                 prfl.Wg,
+		LastMode:           prfl.LastMode,
+		OrganizationId:     prfl.OrganizationId,
+		Organization:       prfl.Organization,
+		ServerId:           prfl.ServerId,
+		Server:             prfl.Server,
+		UserId:             prfl.UserId,
+		User:               prfl.User,
+		PreConnectMsg:      prfl.PreConnectMsg,
+		DynamicFirewall:    prfl.DynamicFirewall,
+		DeviceAuth:         prfl.DeviceAuth,
+		DisableGateway:     prfl.DisableGateway,
+		DisableDns:         prfl.DisableDns,
+		ForceDns:           prfl.ForceDns,
+		SplitDnsDomains:    prfl.SplitDnsDomains,
+		Env:                prfl.Env,
+		Hooks:              prfl.Hooks,
+		CredentialProvider: prfl.CredentialProvider,
+		CredentialCacheTtl: int32(prfl.CredentialCacheTtl),
+		RoutePriority:      int32(prfl.RoutePriority),
+		IncludeRoutes:      prfl.IncludeRoutes,
+		ExcludeRoutes:      prfl.ExcludeRoutes,
+		PersistTun:         prfl.PersistTun,
+		HttpProxy:          prfl.HttpProxy,
+		SocksProxy:         prfl.SocksProxy,
+		AlwaysOn:           prfl.AlwaysOn,
+		KillSwitch:         prfl.KillSwitch,
+		CredentialId:       prfl.CredentialId,
+		HostFailover:       prfl.HostFailover,
+		Verbosity:          int32(prfl.Verbosity),
+		SplitDnsPushed:     prfl.SplitDnsPushed,
+		DnsHijackMode:      prfl.DnsHijackMode,
+		DisableIpv6:        prfl.DisableIpv6,
+		SyslogTag:          prfl.SyslogTag,
+		WgTcpFallback:      prfl.WgTcpFallback,
+		Mtu:                int32(prfl.Mtu),
+		MssFix:             int32(prfl.MssFix),
+		MtuProbe:           prfl.MtuProbe,
+		SummarizeRoutes:    prfl.SummarizeRoutes,
+		ExpiresAt:          prfl.ExpiresAt,
+		ExpireDelete:       prfl.ExpireDelete,
+		OnDemandSubnets:    prfl.OnDemandSubnets,
+		OnDemandIdle:       int32(prfl.OnDemandIdle),
+		RestartTriggers:    prfl.RestartTriggers,
+		StartDelay:         int32(prfl.StartDelay),
+		StartJitter:        int32(prfl.StartJitter),
+		Managed:            prfl.Managed,
+		ReadOnly:           prfl.ReadOnly,
+		SsoAuth:            prfl.SsoAuth,
+		PasswordMode:       prfl.PasswordMode,
+		Token:              prfl.Token,
+		TokenTtl:           int32(prfl.TokenTtl),
+		Disabled:           prfl.Disabled,
+		SyncTime:           prfl.SyncTime,
+		SyncHosts:          prfl.SyncHosts,
+		SyncHash:           prfl.SyncHash,
+		SyncSecret:         prfl.SyncSecret,
+		SyncToken:          prfl.SyncToken,
+		ServerPublicKey:    prfl.ServerPublicKey,
+		ServerBoxPublicKey: prfl.ServerBoxPublicKey,
+		RegistrationKey:    prfl.RegistrationKey,
+		OvpnData:           prfl.OvpnData,
+		LastError:          prfl.LastError,
+		LastErrorTime:      prfl.LastErrorTime,
+		LastConnected:      prfl.LastConnected,
+	}
+}
+
+func getProfiles(wsId string) (prfls []*clientpb.Profile, err error) {
+	err = sprofile.Reload(false)
+	if err != nil {
+		return
+	}
+
+	allPrfls, err := sprofile.GetAllClient()
+	if err != nil {
+		return
+	}
+
+	prfls = []*clientpb.Profile{}
+	for _, prfl := range allPrfls {
+		if prfl.Workspace == wsId {
+			prfls = append(prfls, newProfile(prfl))
+		}
+	}
+
+	return
+}
+
+func (s *clientServer) ListProfiles(ctx context.Context,
+	req *clientpb.ListProfilesRequest) (
+	resp *clientpb.ListProfilesResponse, err error) {
+
+	wsId := utils.FilterStr(req.WorkspaceId)
+	err = checkWorkspace(wsId)
+	if err != nil {
+		return
+	}
+
+	prfls, err := getProfiles(wsId)
+	if err != nil {
+		return
+	}
+
+	resp = &clientpb.ListProfilesResponse{
+		Profiles: prfls,
+	}
+
+	return
+}
+
+func (s *clientServer) GetProfile(ctx context.Context,
+	req *clientpb.GetProfileRequest) (resp *clientpb.Profile, err error) {
+
+	wsId := utils.FilterStr(req.WorkspaceId)
+	prflId, err := filterProfileId(req.ProfileId)
+	if err != nil {
+		return
+	}
+
+	err = checkWorkspace(wsId)
+	if err != nil {
+		return
+	}
+
+	prfls, err := getProfiles(wsId)
+	if err != nil {
+		return
+	}
+
+	for _, prfl := range prfls {
+		if prfl.Id == prflId {
+			resp = prfl
+			return
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("rpc: Profile not found"),
+	}
+	return
+}
+
+func (s *clientServer) PutProfile(ctx context.Context,
+	req *clientpb.PutProfileRequest) (resp *clientpb.Profile, err error) {
+
+	data := req.Profile
+	if data == nil {
+		err = &errortypes.ParseError{
+			errors.New("rpc: Missing profile"),
+		}
+		return
+	}
+
+	wsId := utils.FilterStr(req.WorkspaceId)
+	prflId, err := filterProfileId(data.Id)
+	if err != nil {
+		return
+	}
+
+	err = checkWorkspace(wsId)
+	if err != nil {
+		return
+	}
+
+	err = sprofile.CheckReadOnly(prflId)
+	if err != nil {
+		return
+	}
+
+	curPrfl := sprofile.Get(prflId)
+	if curPrfl != nil && curPrfl.Workspace != wsId {
+		err = &errortypes.ParseError{
+			errors.New("rpc: Profile belongs to another workspace"),
+		}
+		return
+	}
+
+	httpProxy := data.HttpProxy
+	socksProxy := data.SocksProxy
+	if curPrfl != nil {
+		httpProxy = proxy.Unredact(httpProxy, curPrfl.HttpProxy)
+		socksProxy = proxy.Unredact(socksProxy, curPrfl.SocksProxy)
+	}
+
+	if httpProxy != "" {
+		_, err = proxy.Parse(httpProxy, false)
+		if err != nil {
+			return
+		}
+	}
+	if socksProxy != "" {
+		_, err = proxy.Parse(socksProxy, true)
+		if err != nil {
+			return
+		}
+	}
+
+	prfl := &sprofile.Sprofile{
+		Id:                 prflId,
+		Name:               data.Name,
+		Workspace:          wsId,
+		Wg:                 data.Wg,
+		LastMode:           data.LastMode,
+		OrganizationId:     data.OrganizationId,
+		Organization:       data.Organization,
+		ServerId:           data.ServerId,
+		Server:             data.Server,
+		UserId:             data.UserId,
+		User:               data.User,
+		PreConnectMsg:      data.PreConnectMsg,
+		DynamicFirewall:    data.DynamicFirewall,
+		DeviceAuth:         data.DeviceAuth,
+		DisableGateway:     data.DisableGateway,
+		DisableDns:         data.DisableDns,
+		ForceDns:           data.ForceDns,
+		SplitDnsDomains:    utils.FilterDomains(data.SplitDnsDomains),
+		Env:                profile.FilterEnv(data.Env),
+		Hooks:              profile.FilterHooks(data.Hooks),
+		CredentialProvider: data.CredentialProvider,
+		CredentialCacheTtl: int(data.CredentialCacheTtl),
+		RoutePriority:      int(data.RoutePriority),
+		IncludeRoutes:      utils.FilterSubnets(data.IncludeRoutes),
+		ExcludeRoutes:      utils.FilterSubnets(data.ExcludeRoutes),
+		PersistTun:         data.PersistTun,
+		HttpProxy:          httpProxy,
+		SocksProxy:         socksProxy,
+		AlwaysOn:           data.AlwaysOn,
+		KillSwitch:         data.KillSwitch,
+		CredentialId:       data.CredentialId,
+		HostFailover:       data.HostFailover,
+		Verbosity:          profile.FilterVerbosity(int(data.Verbosity)),
+		SplitDnsPushed:     data.SplitDnsPushed,
+		DnsHijackMode:      sprofile.FilterDnsHijackMode(data.DnsHijackMode),
+		DisableIpv6:        data.DisableIpv6,
+		SyslogTag:          data.SyslogTag,
+		WgTcpFallback:      data.WgTcpFallback,
+		Mtu:                sprofile.FilterMtu(int(data.Mtu)),
+		MssFix:             sprofile.FilterMtu(int(data.MssFix)),
+		MtuProbe:           data.MtuProbe,
+		SummarizeRoutes:    data.SummarizeRoutes,
+		OnDemandSubnets:    utils.FilterSubnets(data.OnDemandSubnets),
+		OnDemandIdle:       int(data.OnDemandIdle),
+		RestartTriggers:    sprofile.FilterTriggers(data.RestartTriggers),
+		StartDelay:         sprofile.FilterStartDelay(int(data.StartDelay)),
+		StartJitter:        sprofile.FilterStartDelay(int(data.StartJitter)),
+		SsoAuth:            data.SsoAuth,
+		PasswordMode:       data.PasswordMode,
+		Token:              data.Token,
+		TokenTtl:           int(data.TokenTtl),
+		Disabled:           data.Disabled,
+		SyncTime:           data.SyncTime,
+		SyncHosts:          data.SyncHosts,
+		SyncHash:           data.SyncHash,
+		SyncSecret:         data.SyncSecret,
+		SyncToken:          data.SyncToken,
+		ServerPublicKey:    data.ServerPublicKey,
+		ServerBoxPublicKey: data.ServerBoxPublicKey,
+		RegistrationKey:    data.RegistrationKey,
+		OvpnData:           data.OvpnData,
+	}
+
+	// Expiry is only set by the server sync or an admin api token, other
+	// requests keep the current expiry
+	if auth.TokenScope(getRequest(ctx)) == auth.ScopeAdmin {
+		prfl.ExpiresAt = data.ExpiresAt
+		prfl.ExpireDelete = data.ExpireDelete
+	} else if curPrfl != nil {
+		prfl.ExpiresAt = curPrfl.ExpiresAt
+		prfl.ExpireDelete = curPrfl.ExpireDelete
+	}
+
+	if curPrfl != nil {
+		prfl.Features = curPrfl.Features.Copy()
+	}
+	prfl.ApplyWorkspace()
+	prfl.ApplyFeatures()
+
+	err = prfl.Commit()
+	if err != nil {
+		return
+	}
+
+	resp = newProfile(prfl.Client())
+
+	return
+}
+
+func (s *clientServer) DeleteProfile(ctx context.Context,
+	req *clientpb.DeleteProfileRequest) (
+	resp *clientpb.DeleteProfileResponse, err error) {
+
+	prflId, err := filterProfileId(req.ProfileId)
+	if err != nil {
+		return
+	}
+
+	err = checkProfileWorkspace(utils.FilterStr(req.WorkspaceId), prflId)
+	if err != nil {
+		return
+	}
+
+	err = sprofile.CheckReadOnly(prflId)
+	if err != nil {
+		return
+	}
+
+	prfl := profile.GetProfile(prflId)
+	if prfl != nil {
+		prfl.Stop()
+	}
+
+	sprofile.Remove(prflId)
+	profile.ClearBandwidthStats(prflId)
+	profile.ClearRemoteBreakers(prflId)
+	profile.ReleaseKillSwitch(prflId)
+	logger.ClearProfileEntries(prflId)
+
+	resp = &clientpb.DeleteProfileResponse{}
+
+	return
+}
+
+// Only profiles stored by the service are connected, the connection is
+// started by the system profile watch
+func (s *clientServer) Connect(ctx context.Context,
+	req *clientpb.ConnectRequest) (resp *clientpb.ConnectResponse,
+	err error) {
+
+	prflId, err := filterProfileId(req.ProfileId)
+	if err != nil {
+		return
+	}
+
+	err = checkProfileWorkspace(utils.FilterStr(req.WorkspaceId), prflId)
+	if err != nil {
+		return
+	}
+
+	sprfl := sprofile.Get(prflId)
+	if sprfl == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("rpc: Profile not found"),
+		}
+		return
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = sprfl.LastMode
+	}
+
+	profile.CancelPause(prflId)
+
+	err = sprofile.Activate(prflId, mode, req.Password)
+	if err != nil {
+		return
+	}
+
+	resp = &clientpb.ConnectResponse{}
+
+	return
+}
+
+func (s *clientServer) Disconnect(ctx context.Context,
+	req *clientpb.DisconnectRequest) (resp *clientpb.DisconnectResponse,
+	err error) {
+
+	prflId, err := filterProfileId(req.ProfileId)
+	if err != nil {
+		return
+	}
+
+	err = checkProfileWorkspace(utils.FilterStr(req.WorkspaceId), prflId)
+	if err != nil {
+		return
+	}
+
+	profile.CancelPause(prflId)
+	profile.CancelPortalWait(prflId)
+
+	sprfl := sprofile.Get(prflId)
+	if sprfl != nil {
+		sprofile.Deactivate(prflId)
+	} else {
+		prfl := profile.GetProfile(prflId)
+		if prfl != nil {
+			prfl.Stop()
+		}
+	}
+	profile.ReleaseKillSwitch(prflId)
+
+	resp = &clientpb.DisconnectResponse{}
+
+	return
+}
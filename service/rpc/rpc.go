@@ -0,0 +1,187 @@
+// Grpc management api served on the api listener.
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/auth"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/handlers"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/rpc/clientpb"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Methods held until startup completes, equivalent to the mutating
+// requests of the http handlers
+var mutating = map[string]bool{
+	"/pritunl.client.v1.Client/PutProfile":    true,
+	"/pritunl.client.v1.Client/DeleteProfile": true,
+	"/pritunl.client.v1.Client/Connect":       true,
+	"/pritunl.client.v1.Client/Disconnect":    true,
+}
+
+var server = newServer()
+
+type requestKey struct{}
+
+func newServer() (srv *grpc.Server) {
+	srv = grpc.NewServer(
+		grpc.UnaryInterceptor(unaryInterceptor),
+		grpc.StreamInterceptor(streamInterceptor),
+	)
+	clientpb.RegisterClientServer(srv, &clientServer{})
+	return
+}
+
+func getRequest(ctx context.Context) (r *http.Request) {
+	r, _ = ctx.Value(requestKey{}).(*http.Request)
+	return
+}
+
+func newStatus(code codes.Code, httpCode int) error {
+	return status.Error(code, message.Status(httpCode).Message)
+}
+
+// Requests are validated with the auth chain of the transport, the grpc
+// method is the request path used to check the api token scope
+func authorize(ctx context.Context, method string) (err error) {
+	r := getRequest(ctx)
+	if r == nil {
+		err = newStatus(codes.Unauthenticated, 401)
+		return
+	}
+
+	err = auth.Validate(r)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  r.URL.Path,
+			"error": err,
+		}).Debug("rpc: Request authentication failed")
+
+		if _, ok := err.(*auth.ScopeError); ok {
+			err = newStatus(codes.PermissionDenied, 403)
+		} else {
+			err = newStatus(codes.Unauthenticated, 401)
+		}
+		return
+	}
+
+	// Mutating methods are rejected rather than queued, the client
+	// retries on unavailable
+	if mutating[method] && !handlers.IsReady() {
+		err = status.Error(codes.Unavailable,
+			message.New(message.ServiceStarting, nil).Message)
+		return
+	}
+
+	return
+}
+
+// Convert handler errors to a status with the message of the equivalent
+// http status, the error is logged as with the http handlers
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"error": err,
+	}).Error("rpc: Handler error")
+
+	switch err.(type) {
+	case *errortypes.NotFoundError:
+		return newStatus(codes.NotFound, 404)
+	case *errortypes.ParseError:
+		return newStatus(codes.InvalidArgument, 400)
+	case *errortypes.PolicyError:
+		return newStatus(codes.PermissionDenied, 403)
+	case *errortypes.PreconditionError:
+		return newStatus(codes.FailedPrecondition, 412)
+	default:
+		return newStatus(codes.Internal, 500)
+	}
+}
+
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		logrus.WithFields(logrus.Fields{
+			"stack": string(debug.Stack()),
+			"error": errors.New(fmt.Sprintf("%s", r)),
+		}).Error("rpc: Handler panic")
+		*err = newStatus(codes.Internal, 500)
+	}
+}
+
+func unaryInterceptor(ctx context.Context, req interface{},
+	info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (
+	resp interface{}, err error) {
+
+	defer recoverPanic(&err)
+
+	err = authorize(ctx, info.FullMethod)
+	if err != nil {
+		return
+	}
+
+	resp, err = handler(ctx, req)
+	err = convertError(err)
+
+	return
+}
+
+func streamInterceptor(srv interface{}, stream grpc.ServerStream,
+	info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+
+	defer recoverPanic(&err)
+
+	err = authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return
+	}
+
+	err = handler(srv, stream)
+	err = convertError(err)
+
+	return
+}
+
+// Serve grpc requests on the api listener, http/2 is accepted without tls
+// with prior knowledge and other requests are passed to the http handlers
+func Handler(next http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !auth.IsGrpc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(
+				r.Context(), requestKey{}, r))
+			server.ServeHTTP(w, r)
+		},
+	), &http2.Server{})
+}
+
+// Close the grpc streams, connections upgraded to http/2 are not closed
+// by the http server shutdown
+func Stop() {
+	server.Stop()
+}
@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/rpc/clientpb"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+func newProfileStatus(prfl *profile.Profile) *clientpb.ProfileStatus {
+	return &clientpb.ProfileStatus{
+		Id:           prfl.Id,
+		Name:         prfl.Name,
+		WorkspaceId:  prfl.Workspace,
+		Mode:         prfl.Mode,
+		Status:       prfl.Status,
+		Degraded:     prfl.Degraded,
+		DnsStatus:    prfl.DnsStatus,
+		Iface:        prfl.Iface,
+		TunIface:     prfl.Tuniface,
+		ServerAddr:   prfl.ServerAddr,
+		ClientAddr:   prfl.ClientAddr,
+		GatewayAddr:  prfl.GatewayAddr,
+		GatewayAddr6: prfl.GatewayAddr6,
+		Timestamp:    prfl.Timestamp,
+	}
+}
+
+func (s *clientServer) GetStatus(ctx context.Context,
+	req *clientpb.GetStatusRequest) (resp *clientpb.Status, err error) {
+
+	resp = &clientpb.Status{
+		Connected: profile.GetStatus(),
+	}
+
+	for _, wait := range profile.GetPortalWaits() {
+		resp.CaptivePortal = true
+		if wait.Redirect != "" {
+			resp.CaptivePortalUrl = wait.Redirect
+		}
+	}
+
+	return
+}
+
+// Profile updates are sent from the event stream, profiles removed after
+// disconnecting are last sent with the disconnected status
+func (s *clientServer) WatchStatus(req *clientpb.WatchStatusRequest,
+	stream clientpb.Client_WatchStatusServer) (err error) {
+
+	wsId := utils.FilterStr(req.WorkspaceId)
+	prflId := utils.FilterStr(req.ProfileId)
+
+	if prflId != "" {
+		err = checkProfileWorkspace(wsId, prflId)
+	} else {
+		err = checkWorkspace(wsId)
+	}
+	if err != nil {
+		return
+	}
+
+	match := func(prfl *profile.Profile) bool {
+		return prfl.Id != "" &&
+			(wsId == "" || prfl.Workspace == wsId) &&
+			(prflId == "" || prfl.Id == prflId)
+	}
+
+	// Listen before the current state is sent so no update is missed
+	list := event.NewListener()
+	evts := list.Listen()
+	defer list.Close()
+
+	for _, prfl := range profile.GetProfiles() {
+		if !match(prfl) {
+			continue
+		}
+
+		err = stream.Send(newProfileStatus(prfl))
+		if err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return
+		case evt, ok := <-evts:
+			if !ok {
+				return
+			}
+
+			if evt.Type != "update" {
+				continue
+			}
+
+			prfl, ok := evt.Data.(*profile.Profile)
+			if !ok || !match(prfl) {
+				continue
+			}
+
+			err = stream.Send(newProfileStatus(prfl))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
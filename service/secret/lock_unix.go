@@ -0,0 +1,33 @@
+//go:build !windows
+
+package secret
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+func lockMemory(data []byte) (err error) {
+	err = unix.Mlock(data)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "secret: Failed to lock memory"),
+		}
+		return
+	}
+
+	return
+}
+
+func unlockMemory(data []byte) (err error) {
+	err = unix.Munlock(data)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "secret: Failed to unlock memory"),
+		}
+		return
+	}
+
+	return
+}
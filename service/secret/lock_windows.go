@@ -0,0 +1,35 @@
+package secret
+
+import (
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+func lockMemory(data []byte) (err error) {
+	err = windows.VirtualLock(
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "secret: Failed to lock memory"),
+		}
+		return
+	}
+
+	return
+}
+
+func unlockMemory(data []byte) (err error) {
+	err = windows.VirtualUnlock(
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "secret: Failed to unlock memory"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+package secret
+
+import (
+	"runtime"
+	"sync"
+)
+
+type Buffer struct {
+	data   []byte
+	locked bool
+	lock   sync.Mutex
+}
+
+func New(size int) (buf *Buffer) {
+	buf = &Buffer{
+		data: make([]byte, size),
+	}
+
+	if size > 0 {
+		buf.locked = lockMemory(buf.data) == nil
+	}
+
+	runtime.SetFinalizer(buf, func(b *Buffer) {
+		b.Destroy()
+	})
+
+	return
+}
+
+func FromBytes(data []byte) (buf *Buffer) {
+	buf = New(len(data))
+	copy(buf.data, data)
+	Wipe(data)
+
+	return
+}
+
+func FromString(data string) (buf *Buffer) {
+	buf = New(len(data))
+	copy(buf.data, data)
+
+	return
+}
+
+func Concat(bufs ...*Buffer) (buf *Buffer) {
+	size := 0
+	for _, b := range bufs {
+		size += b.Len()
+	}
+
+	buf = New(size)
+
+	n := 0
+	for _, b := range bufs {
+		b.lock.Lock()
+		n += copy(buf.data[n:], b.data)
+		b.lock.Unlock()
+	}
+
+	return
+}
+
+func (b *Buffer) Bytes() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.data
+}
+
+func (b *Buffer) Len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.data)
+}
+
+func (b *Buffer) Destroy() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.data == nil {
+		return
+	}
+
+	Wipe(b.data)
+
+	if b.locked {
+		_ = unlockMemory(b.data)
+		b.locked = false
+	}
+
+	b.data = nil
+}
+
+func Wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+	runtime.KeepAlive(data)
+}
@@ -0,0 +1,143 @@
+// Storage of saved passwords and keys in the native credential store of
+// the platform. Values are base64 encoded before being passed to the
+// backend so binary and multi-line secrets survive command line tools.
+package secrets
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const service = "pritunl-client"
+
+var (
+	lock       = sync.Mutex{}
+	detected   bool
+	hasBackend bool
+)
+
+// Keys are alphanumeric segments separated by dashes
+func filterKey(key string) (filtered string, err error) {
+	for _, part := range strings.Split(key, "-") {
+		if part == "" || utils.FilterStr(part) != part {
+			err = &errortypes.ParseError{
+				errors.Newf("secrets: Invalid secret key '%s'", key),
+			}
+			return
+		}
+	}
+
+	filtered = key
+
+	return
+}
+
+// Native credential store is available and not disabled in the config,
+// existing secrets can still be read when the store is disabled
+func Available() bool {
+	if config.Config.DisableKeychain {
+		return false
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	return detect()
+}
+
+// Must be called with the lock held
+func detect() bool {
+	if !detected {
+		detected = true
+		hasBackend = available()
+
+		logrus.WithFields(logrus.Fields{
+			"backend":   backendName(),
+			"available": hasBackend,
+		}).Info("secrets: Detected credential store")
+	}
+
+	return hasBackend
+}
+
+// Secret from the credential store, returns a NotFoundError if the secret
+// does not exist
+func Get(key string) (val string, err error) {
+	key, err = filterKey(key)
+	if err != nil {
+		return
+	}
+
+	lock.Lock()
+	detect()
+	encoded, err := get(key)
+	lock.Unlock()
+	if err != nil {
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Failed to decode secret"),
+		}
+		return
+	}
+
+	val = string(data)
+
+	return
+}
+
+func Set(key, val string) (err error) {
+	key, err = filterKey(key)
+	if err != nil {
+		return
+	}
+
+	if !Available() {
+		err = &errortypes.PreconditionError{
+			errors.New("secrets: Credential store unavailable"),
+		}
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	err = set(key, base64.StdEncoding.EncodeToString([]byte(val)))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Remove the secret, removing a secret that does not exist is not an error
+func Remove(key string) (err error) {
+	key, err = filterKey(key)
+	if err != nil {
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	detect()
+	err = remove(key)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	return
+}
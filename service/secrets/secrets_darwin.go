@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	securityPath = "/usr/bin/security"
+	keychainPath = "/Library/Keychains/System.keychain"
+	itemNotFound = 44
+)
+
+func backendName() string {
+	return "keychain"
+}
+
+func available() bool {
+	exists, _ := utils.Exists(securityPath)
+	if !exists {
+		return false
+	}
+
+	exists, _ = utils.Exists(keychainPath)
+	return exists
+}
+
+func securityErr(err error, stderr *bytes.Buffer, msg string) error {
+	if exitErr, ok := err.(*exec.ExitError); ok &&
+		exitErr.ExitCode() == itemNotFound {
+
+		return &errortypes.NotFoundError{
+			errors.New("secrets: Secret not found in keychain"),
+		}
+	}
+
+	return &errortypes.ExecError{
+		errors.Wrapf(err, "secrets: %s '%s'", msg,
+			strings.TrimSpace(stderr.String())),
+	}
+}
+
+func get(key string) (val string, err error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := command.Command(
+		securityPath,
+		"find-generic-password",
+		"-s", service,
+		"-a", key,
+		"-w",
+		keychainPath,
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if err != nil {
+		err = securityErr(err, stderr,
+			"Failed to read secret from keychain")
+		return
+	}
+
+	val = strings.TrimSpace(stdout.String())
+
+	return
+}
+
+// Secret is passed through stdin in interactive mode to keep it out of
+// the process arguments
+func set(key, val string) (err error) {
+	stderr := &bytes.Buffer{}
+
+	cmd := command.Command(securityPath, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -U -s %s -a %s -X %s %s\n",
+		service, key, hex.EncodeToString([]byte(val)), keychainPath,
+	))
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if err != nil {
+		err = securityErr(err, stderr,
+			"Failed to write secret to keychain")
+		return
+	}
+
+	if stderr.Len() > 0 {
+		err = &errortypes.ExecError{
+			errors.Newf("secrets: Failed to write secret to keychain '%s'",
+				strings.TrimSpace(stderr.String())),
+		}
+		return
+	}
+
+	return
+}
+
+func remove(key string) (err error) {
+	stderr := &bytes.Buffer{}
+
+	cmd := command.Command(
+		securityPath,
+		"delete-generic-password",
+		"-s", service,
+		"-a", key,
+		keychainPath,
+	)
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if err != nil {
+		err = securityErr(err, stderr,
+			"Failed to remove secret from keychain")
+		return
+	}
+
+	return
+}
@@ -0,0 +1,313 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const (
+	libsecret  = "libsecret"
+	kwallet    = "kwallet"
+	file       = "file"
+	walletName = "kdewallet"
+	keyName    = "secrets.key"
+)
+
+var backend string
+
+// The Secret Service and KWallet are only reachable through a session bus,
+// the system service has no session bus and uses the encrypted file store
+func available() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			backend = libsecret
+			return true
+		}
+
+		if _, err := exec.LookPath("kwallet-query"); err == nil {
+			backend = kwallet
+			return true
+		}
+	}
+
+	if os.Geteuid() == 0 {
+		backend = file
+		return true
+	}
+
+	return false
+}
+
+// Secrets are stored in a directory only readable by root, each secret is
+// encrypted with a key stored in the same directory so the values are not
+// exposed by backups or copies of a single file
+func storeDir() string {
+	return filepath.Join(filepath.Dir(config.GetPath()), "secrets")
+}
+
+func storeCipher() (aead cipher.AEAD, err error) {
+	dir := storeDir()
+
+	err = os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "secrets: Failed to create config directory"),
+		}
+		return
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "secrets: Failed to create secrets directory"),
+		}
+		return
+	}
+
+	err = os.Chmod(dir, 0700)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "secrets: Failed to chmod secrets directory"),
+		}
+		return
+	}
+
+	keyPth := filepath.Join(dir, keyName)
+	key, err := ioutil.ReadFile(keyPth)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "secrets: Failed to read secrets key"),
+			}
+			return
+		}
+
+		key, err = utils.RandBytes(32)
+		if err != nil {
+			return
+		}
+
+		err = utils.CreateWrite(keyPth, string(key), 0600)
+		if err != nil {
+			return
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Invalid secrets key"),
+		}
+		return
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Invalid secrets key"),
+		}
+		return
+	}
+
+	return
+}
+
+// Keys are validated by filterKey and are safe to use as file names
+func storePath(key string) string {
+	return filepath.Join(storeDir(), key+".secret")
+}
+
+func fileGet(key string) (val string, err error) {
+	ciphertext, err := ioutil.ReadFile(storePath(key))
+	if err != nil {
+		err = &errortypes.NotFoundError{
+			errors.New("secrets: Secret not found in credential store"),
+		}
+		return
+	}
+
+	aead, err := storeCipher()
+	if err != nil {
+		return
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("secrets: Stored secret truncated"),
+		}
+		return
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+	data, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():],
+		[]byte(key))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Failed to decrypt secret"),
+		}
+		return
+	}
+
+	val = string(data)
+
+	return
+}
+
+func fileSet(key, val string) (err error) {
+	aead, err := storeCipher()
+	if err != nil {
+		return
+	}
+
+	nonce, err := utils.RandBytes(aead.NonceSize())
+	if err != nil {
+		return
+	}
+
+	ciphertext := aead.Seal(nonce, nonce, []byte(val), []byte(key))
+
+	pth := storePath(key)
+	_ = utils.Shred(pth)
+
+	err = utils.CreateWrite(pth, string(ciphertext), 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func fileRemove(key string) (err error) {
+	err = utils.Shred(storePath(key))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func backendName() string {
+	if backend == "" {
+		return "none"
+	}
+	return backend
+}
+
+func run(stdin string, name string, args ...string) (
+	output string, err error) {
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := command.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrapf(err, "secrets: Failed to exec %s '%s'",
+				name, strings.TrimSpace(stderr.String())),
+		}
+		return
+	}
+
+	output = strings.TrimSpace(stdout.String())
+
+	return
+}
+
+func get(key string) (val string, err error) {
+	switch backend {
+	case libsecret:
+		val, err = run("", "secret-tool", "lookup",
+			"service", service, "account", key)
+		break
+	case kwallet:
+		val, err = run("", "kwallet-query", "-f", service,
+			"-r", key, walletName)
+		break
+	case file:
+		val, err = fileGet(key)
+		return
+	default:
+		err = &errortypes.PreconditionError{
+			errors.New("secrets: Credential store unavailable"),
+		}
+		return
+	}
+
+	// Lookups of missing secrets exit with an error and no output
+	if err != nil || val == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("secrets: Secret not found in credential store"),
+		}
+		return
+	}
+
+	return
+}
+
+func set(key, val string) (err error) {
+	switch backend {
+	case libsecret:
+		_, err = run(val, "secret-tool", "store",
+			"--label", service+" "+key,
+			"service", service, "account", key)
+		break
+	case kwallet:
+		_, err = run(val, "kwallet-query", "-f", service,
+			"-w", key, walletName)
+		break
+	case file:
+		err = fileSet(key, val)
+		break
+	default:
+		err = &errortypes.PreconditionError{
+			errors.New("secrets: Credential store unavailable"),
+		}
+		break
+	}
+
+	return
+}
+
+func remove(key string) (err error) {
+	switch backend {
+	case libsecret:
+		_, err = run("", "secret-tool", "clear",
+			"service", service, "account", key)
+		break
+	case kwallet:
+		// KWallet has no removal from the command line, overwrite the
+		// entry with an empty value which is treated as not found
+		_, err = run("\n", "kwallet-query", "-f", service,
+			"-w", key, walletName)
+		break
+	case file:
+		err = fileRemove(key)
+		break
+	default:
+		err = &errortypes.PreconditionError{
+			errors.New("secrets: Credential store unavailable"),
+		}
+		break
+	}
+
+	return
+}
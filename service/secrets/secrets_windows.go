@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32      = windows.NewLazySystemDLL("advapi32.dll")
+	procCredRead  = advapi32.NewProc("CredReadW")
+	procCredWrite = advapi32.NewProc("CredWriteW")
+	procCredDel   = advapi32.NewProc("CredDeleteW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func backendName() string {
+	return "credential_manager"
+}
+
+// Credentials are encrypted with DPAPI under the service account
+func available() bool {
+	return procCredRead.Find() == nil && procCredWrite.Find() == nil &&
+		procCredDel.Find() == nil && procCredFree.Find() == nil
+}
+
+func targetName(key string) (*uint16, error) {
+	return windows.UTF16PtrFromString(service + ":" + key)
+}
+
+func credErr(err error, msg string) error {
+	if err == windows.ERROR_NOT_FOUND {
+		return &errortypes.NotFoundError{
+			errors.New("secrets: Secret not found in credential manager"),
+		}
+	}
+
+	return &errortypes.ExecError{
+		errors.Wrap(err, msg),
+	}
+}
+
+func get(key string) (val string, err error) {
+	target, err := targetName(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Failed to parse secret key"),
+		}
+		return
+	}
+
+	var cred *credential
+	ret, _, e := procCredRead.Call(
+		uintptr(unsafe.Pointer(target)),
+		credTypeGeneric,
+		0,
+		uintptr(unsafe.Pointer(&cred)),
+	)
+	if ret == 0 {
+		err = credErr(e, "secrets: Failed to read secret")
+		return
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize > 0 && cred.CredentialBlob != nil {
+		val = string(unsafe.Slice(
+			cred.CredentialBlob, cred.CredentialBlobSize))
+	}
+
+	return
+}
+
+func set(key, val string) (err error) {
+	target, err := targetName(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Failed to parse secret key"),
+		}
+		return
+	}
+
+	user, _ := windows.UTF16PtrFromString(service)
+	data := []byte(val)
+
+	cred := &credential{
+		Type:       credTypeGeneric,
+		TargetName: target,
+		Persist:    credPersistLocalMachine,
+		UserName:   user,
+	}
+	if len(data) > 0 {
+		cred.CredentialBlobSize = uint32(len(data))
+		cred.CredentialBlob = &data[0]
+	}
+
+	ret, _, e := procCredWrite.Call(
+		uintptr(unsafe.Pointer(cred)),
+		0,
+	)
+	if ret == 0 {
+		err = credErr(e, "secrets: Failed to write secret")
+		return
+	}
+
+	return
+}
+
+func remove(key string) (err error) {
+	target, err := targetName(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "secrets: Failed to parse secret key"),
+		}
+		return
+	}
+
+	ret, _, e := procCredDel.Call(
+		uintptr(unsafe.Pointer(target)),
+		credTypeGeneric,
+		0,
+	)
+	if ret == 0 {
+		err = credErr(e, "secrets: Failed to remove secret")
+		return
+	}
+
+	return
+}
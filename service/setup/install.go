@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/config"
 )
 
 func Install() {
@@ -16,7 +17,15 @@ func Install() {
 	cmd.Stderr = os.Stderr
 	cmd.Run()
 
-	err := TunTapInstall()
+	err := config.Load()
+	if err == nil {
+		err = config.InitDeviceId()
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+
+	err = TunTapInstall()
 	if err != nil {
 		fmt.Println(err.Error())
 	}
@@ -0,0 +1,35 @@
+package sprofile
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+)
+
+// Maximum autostart delay and jitter in seconds
+const MaxStartDelay = 3600
+
+func FilterStartDelay(secs int) int {
+	if secs < 0 {
+		return 0
+	} else if secs > MaxStartDelay {
+		return MaxStartDelay
+	}
+	return secs
+}
+
+// Delay before the profile is started when the service starts, the
+// random jitter spreads the connections of devices that boot together
+func (s *Sprofile) GetStartDelay() (delay time.Duration) {
+	delay = time.Duration(FilterStartDelay(s.StartDelay)) * time.Second
+
+	jitter := FilterStartDelay(s.StartJitter)
+	if jitter > 0 {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(jitter)*1000))
+		if err == nil {
+			delay += time.Duration(n.Int64()) * time.Millisecond
+		}
+	}
+
+	return
+}
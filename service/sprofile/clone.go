@@ -0,0 +1,120 @@
+package sprofile
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+type remoteTemplate struct {
+	Port  string
+	Proto string
+}
+
+func (s *Sprofile) Clone() (sprfl *Sprofile) {
+	sprfl = s.Copy()
+	sprfl.Id = utils.Uuid()
+	sprfl.State = false
+	sprfl.Managed = false
+	sprfl.ReadOnly = false
+	sprfl.Path = ""
+	sprfl.AuthErrorCount = 0
+
+	return
+}
+
+func (s *Sprofile) SetRemotes(remotes []string) (err error) {
+	templs := []remoteTemplate{}
+	templsSet := map[remoteTemplate]bool{}
+	lines := []string{}
+	remotesIndex := -1
+
+	for _, line := range strings.Split(s.OvpnData, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "remote" {
+			lines = append(lines, line)
+			continue
+		}
+
+		templ := remoteTemplate{
+			Port:  fields[2],
+			Proto: fields[3],
+		}
+		if !templsSet[templ] {
+			templsSet[templ] = true
+			templs = append(templs, templ)
+		}
+
+		if remotesIndex == -1 {
+			remotesIndex = len(lines)
+		}
+	}
+
+	if remotesIndex == -1 || len(templs) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Profile configuration has no remotes"),
+		}
+		return
+	}
+
+	remoteLines := []string{}
+	for _, remote := range remotes {
+		host := strings.TrimSpace(remote)
+		port := ""
+
+		h, p, e := net.SplitHostPort(host)
+		if e == nil {
+			host = h
+			port = p
+
+			_, e = strconv.Atoi(port)
+			if e != nil {
+				err = &errortypes.ParseError{
+					errors.Newf("sprofile: Invalid remote port '%s'", remote),
+				}
+				return
+			}
+		}
+
+		if net.ParseIP(host) == nil {
+			host = utils.FilterDomain(host)
+		}
+		if host == "" {
+			err = &errortypes.ParseError{
+				errors.Newf("sprofile: Invalid remote '%s'", remote),
+			}
+			return
+		}
+
+		for _, templ := range templs {
+			remotePort := templ.Port
+			if port != "" {
+				remotePort = port
+			}
+
+			remoteLines = append(remoteLines, fmt.Sprintf(
+				"remote %s %s %s", host, remotePort, templ.Proto))
+		}
+	}
+
+	if len(remoteLines) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: No remotes provided"),
+		}
+		return
+	}
+
+	newLines := []string{}
+	newLines = append(newLines, lines[:remotesIndex]...)
+	newLines = append(newLines, remoteLines...)
+	newLines = append(newLines, lines[remotesIndex:]...)
+
+	s.OvpnData = strings.Join(newLines, "\n")
+
+	return
+}
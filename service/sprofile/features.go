@@ -0,0 +1,88 @@
+package sprofile
+
+const (
+	DnsHijackWarn   = "warn"
+	DnsHijackTunnel = "tunnel"
+	DnsHijackIgnore = "ignore"
+)
+
+// Unknown modes are cleared and use the default warn mode
+func FilterDnsHijackMode(mode string) string {
+	switch mode {
+	case DnsHijackWarn, DnsHijackTunnel, DnsHijackIgnore:
+		return mode
+	default:
+		return ""
+	}
+}
+
+// Feature flags set by the server administrator in the synced profile,
+// unset flags keep the local profile setting
+type Flags struct {
+	KillSwitch    *bool  `json:"kill_switch,omitempty"`
+	AlwaysOn      *bool  `json:"always_on,omitempty"`
+	ForceDns      *bool  `json:"force_dns,omitempty"`
+	DisableIpv6   *bool  `json:"disable_ipv6,omitempty"`
+	PersistTun    *bool  `json:"persist_tun,omitempty"`
+	HostFailover  *bool  `json:"host_failover,omitempty"`
+	WgTcpFallback *bool  `json:"wg_tcp_fallback,omitempty"`
+	DnsHijackMode string `json:"dns_hijack_mode,omitempty"`
+}
+
+func copyFlag(flag *bool) *bool {
+	if flag == nil {
+		return nil
+	}
+	val := *flag
+	return &val
+}
+
+func (f *Flags) Copy() *Flags {
+	if f == nil {
+		return nil
+	}
+
+	return &Flags{
+		KillSwitch:    copyFlag(f.KillSwitch),
+		AlwaysOn:      copyFlag(f.AlwaysOn),
+		ForceDns:      copyFlag(f.ForceDns),
+		DisableIpv6:   copyFlag(f.DisableIpv6),
+		PersistTun:    copyFlag(f.PersistTun),
+		HostFailover:  copyFlag(f.HostFailover),
+		WgTcpFallback: copyFlag(f.WgTcpFallback),
+		DnsHijackMode: f.DnsHijackMode,
+	}
+}
+
+// Override the profile settings with the flags set by the server
+func (s *Sprofile) ApplyFeatures() {
+	f := s.Features
+	if f == nil {
+		return
+	}
+
+	if f.KillSwitch != nil {
+		s.KillSwitch = *f.KillSwitch
+	}
+	if f.AlwaysOn != nil {
+		s.AlwaysOn = *f.AlwaysOn
+	}
+	if f.ForceDns != nil {
+		s.ForceDns = *f.ForceDns
+	}
+	if f.DisableIpv6 != nil {
+		s.DisableIpv6 = *f.DisableIpv6
+	}
+	if f.PersistTun != nil {
+		s.PersistTun = *f.PersistTun
+	}
+	if f.HostFailover != nil {
+		s.HostFailover = *f.HostFailover
+	}
+	if f.WgTcpFallback != nil {
+		s.WgTcpFallback = *f.WgTcpFallback
+	}
+	if f.DnsHijackMode != "" {
+		s.DnsHijackMode = FilterDnsHijackMode(f.DnsHijackMode)
+	}
+}
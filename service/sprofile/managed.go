@@ -0,0 +1,86 @@
+package sprofile
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+type Managed struct {
+	Profiles []*Sprofile `json:"profiles"`
+}
+
+// Declared profiles are written to the managed file by configuration
+// management, the file is in the root owned profiles directory
+func GetManagedPath() string {
+	return filepath.Join(filepath.Dir(GetPath()), "managed.json")
+}
+
+func GetManagedModTime() (modTime time.Time, err error) {
+	stat, err := os.Stat(GetManagedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to stat managed profiles"),
+		}
+		return
+	}
+
+	modTime = stat.ModTime()
+
+	return
+}
+
+func LoadManaged() (mngd *Managed, err error) {
+	data, err := ioutil.ReadFile(GetManagedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read managed profiles"),
+		}
+		return
+	}
+
+	mngd = &Managed{}
+
+	err = json.Unmarshal(data, mngd)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to parse managed profiles"),
+		}
+		return
+	}
+
+	if mngd.Profiles == nil {
+		mngd.Profiles = []*Sprofile{}
+	}
+
+	return
+}
+
+func (s *Sprofile) Equal(prfl *Sprofile) bool {
+	data1, err := json.Marshal(s)
+	if err != nil {
+		return false
+	}
+
+	data2, err := json.Marshal(prfl)
+	if err != nil {
+		return false
+	}
+
+	return string(data1) == string(data2)
+}
@@ -0,0 +1,139 @@
+package sprofile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+const MdmDir = "/etc/pritunl-client/managed"
+
+// Profiles and settings pushed by an administrator with MDM tools, the
+// documents use the same format in all sources
+type Mdm struct {
+	Profiles []*Sprofile                `json:"profiles"`
+	Settings map[string]json.RawMessage `json:"settings"`
+}
+
+func (m *Mdm) Empty() bool {
+	return len(m.Profiles) == 0 && len(m.Settings) == 0
+}
+
+func (m *Mdm) Hash() string {
+	if m.Empty() {
+		return ""
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Later sources override profiles with the same ID and settings
+func (m *Mdm) merge(other *Mdm) {
+	for _, prfl := range other.Profiles {
+		replaced := false
+		for i, curPrfl := range m.Profiles {
+			if curPrfl.Id == prfl.Id {
+				m.Profiles[i] = prfl
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			m.Profiles = append(m.Profiles, prfl)
+		}
+	}
+
+	for key, val := range other.Settings {
+		m.Settings[key] = val
+	}
+}
+
+func (m *Mdm) mergeData(data []byte, source string) (err error) {
+	other := &Mdm{}
+
+	err = json.Unmarshal(data, other)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "sprofile: Failed to parse managed source '%s'",
+				source),
+		}
+		return
+	}
+
+	m.merge(other)
+
+	return
+}
+
+// Each json file in the directory is a document, files are applied in
+// name order
+func (m *Mdm) loadDir(dir string) (err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read managed directory"),
+		}
+		return
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pth := filepath.Join(dir, name)
+
+		data, e := ioutil.ReadFile(pth)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "sprofile: Failed to read managed file"),
+			}
+			return
+		}
+
+		err = m.mergeData(data, pth)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func LoadMdm() (mdm *Mdm, err error) {
+	mdm = &Mdm{
+		Profiles: []*Sprofile{},
+		Settings: map[string]json.RawMessage{},
+	}
+
+	err = loadMdm(mdm)
+	if err != nil {
+		return
+	}
+
+	return
+}
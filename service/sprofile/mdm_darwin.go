@@ -0,0 +1,37 @@
+package sprofile
+
+import (
+	"os"
+
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+const mdmPlistPath = "/Library/Managed Preferences/com.pritunl.client.plist"
+
+// Configuration profiles installed by the MDM are written to the managed
+// preferences plist
+func loadMdm(mdm *Mdm) (err error) {
+	err = mdm.loadDir(MdmDir)
+	if err != nil {
+		return
+	}
+
+	_, err = os.Stat(mdmPlistPath)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	output, err := utils.ExecOutput(
+		"plutil", "-convert", "json", "-o", "-", mdmPlistPath)
+	if err != nil {
+		return
+	}
+
+	err = mdm.mergeData([]byte(output), mdmPlistPath)
+	if err != nil {
+		return
+	}
+
+	return
+}
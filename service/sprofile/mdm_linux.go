@@ -0,0 +1,10 @@
+package sprofile
+
+func loadMdm(mdm *Mdm) (err error) {
+	err = mdm.loadDir(MdmDir)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+package sprofile
+
+import (
+	"encoding/json"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows/registry"
+)
+
+const mdmRegistryKey = `SOFTWARE\Policies\Pritunl\Client`
+
+// Group policy and Intune write the Settings value as a json object and
+// each value of the Profiles subkey as a json profile named by the ID
+func loadMdm(mdm *Mdm) (err error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		mdmRegistryKey, registry.QUERY_VALUE)
+	if err != nil {
+		if err == registry.ErrNotExist {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to open policy key"),
+		}
+		return
+	}
+	defer key.Close()
+
+	settings, _, err := key.GetStringValue("Settings")
+	if err == nil {
+		err = mdm.mergeData([]byte(`{"settings":`+settings+`}`),
+			mdmRegistryKey+`\Settings`)
+		if err != nil {
+			return
+		}
+	} else if err != registry.ErrNotExist {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read policy settings"),
+		}
+		return
+	}
+	err = nil
+
+	prflsKey, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		mdmRegistryKey+`\Profiles`, registry.QUERY_VALUE)
+	if err != nil {
+		if err == registry.ErrNotExist {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to open policy profiles key"),
+		}
+		return
+	}
+	defer prflsKey.Close()
+
+	names, err := prflsKey.ReadValueNames(0)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read policy profiles"),
+		}
+		return
+	}
+
+	other := &Mdm{
+		Settings: map[string]json.RawMessage{},
+	}
+	for _, name := range names {
+		val, _, e := prflsKey.GetStringValue(name)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "sprofile: Failed to read policy profile"),
+			}
+			return
+		}
+
+		prfl := &Sprofile{}
+		e = json.Unmarshal([]byte(val), prfl)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrapf(e, "sprofile: Failed to parse policy "+
+					"profile '%s'", name),
+			}
+			return
+		}
+
+		if prfl.Id == "" {
+			prfl.Id = name
+		}
+		other.Profiles = append(other.Profiles, prfl)
+	}
+
+	mdm.merge(other)
+
+	return
+}
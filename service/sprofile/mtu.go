@@ -0,0 +1,18 @@
+package sprofile
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/network"
+)
+
+// Zero uses the server configuration, other values are limited to the
+// range of valid link MTUs
+func FilterMtu(mtu int) int {
+	if mtu <= 0 {
+		return 0
+	} else if mtu < network.MinMtu {
+		return network.MinMtu
+	} else if mtu > network.MaxMtu {
+		return network.MaxMtu
+	}
+	return mtu
+}
@@ -17,14 +17,21 @@ import (
 	"time"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
 	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/posture"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/secrets"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
 )
 
 var (
 	clientInsecure = &http.Client{
 		Transport: &http.Transport{
+			Proxy:               proxy.Func,
 			TLSHandshakeTimeout: 10 * time.Second,
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: true,
@@ -42,85 +49,186 @@ type SyncData struct {
 }
 
 type Sprofile struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"-"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DeviceAuth         bool     `json:"device_auth"`
-	DisableGateway     bool     `json:"disable_gateway"`
-	DisableDns         bool     `json:"disable_dns"`
-	ForceDns           bool     `json:"force_dns"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	RegistrationKey    string   `json:"registration_key"`
-	OvpnData           string   `json:"ovpn_data"`
-	Path               string   `json:"-"`
-	Password           string   `json:"password"`
-	AuthErrorCount     int      `json:"-"`
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Workspace          string            `json:"-"`
+	State              bool              `json:"-"`
+	Wg                 bool              `json:"wg"`
+	LastMode           string            `json:"last_mode"`
+	OrganizationId     string            `json:"organization_id"`
+	Organization       string            `json:"organization"`
+	ServerId           string            `json:"server_id"`
+	Server             string            `json:"server"`
+	UserId             string            `json:"user_id"`
+	User               string            `json:"user"`
+	PreConnectMsg      string            `json:"pre_connect_msg"`
+	DynamicFirewall    bool              `json:"dynamic_firewall"`
+	DeviceAuth         bool              `json:"device_auth"`
+	DisableGateway     bool              `json:"disable_gateway"`
+	DisableDns         bool              `json:"disable_dns"`
+	ForceDns           bool              `json:"force_dns"`
+	SplitDnsDomains    []string          `json:"split_dns_domains"`
+	Env                map[string]string `json:"env"`
+	Hooks              map[string]string `json:"hooks"`
+	CredentialProvider string            `json:"credential_provider"`
+	CredentialCacheTtl int               `json:"credential_cache_ttl"`
+	RoutePriority      int               `json:"route_priority"`
+	IncludeRoutes      []string          `json:"include_routes"`
+	ExcludeRoutes      []string          `json:"exclude_routes"`
+	PersistTun         bool              `json:"persist_tun"`
+	HttpProxy          string            `json:"http_proxy"`
+	SocksProxy         string            `json:"socks_proxy"`
+	AlwaysOn           bool              `json:"always_on"`
+	KillSwitch         bool              `json:"kill_switch"`
+	CredentialId       string            `json:"credential_id"`
+	HostFailover       bool              `json:"host_failover"`
+	Verbosity          int               `json:"verbosity"`
+	SplitDnsPushed     bool              `json:"split_dns_pushed"`
+	DnsHijackMode      string            `json:"dns_hijack_mode"`
+	DisableIpv6        bool              `json:"disable_ipv6"`
+	SyslogTag          string            `json:"syslog_tag"`
+	WgTcpFallback      bool              `json:"wg_tcp_fallback"`
+	Mtu                int               `json:"mtu"`
+	MssFix             int               `json:"mss_fix"`
+	MtuProbe           bool              `json:"mtu_probe"`
+	SummarizeRoutes    bool              `json:"summarize_routes"`
+	ExpiresAt          int64             `json:"expires_at"`
+	ExpireDelete       bool              `json:"expire_delete"`
+	OnDemandSubnets    []string          `json:"on_demand_subnets"`
+	OnDemandIdle       int               `json:"on_demand_idle"`
+	RestartTriggers    []string          `json:"restart_triggers"`
+	StartDelay         int               `json:"start_delay"`
+	StartJitter        int               `json:"start_jitter"`
+	Features           *Flags            `json:"features"`
+	Managed            bool              `json:"managed"`
+	ReadOnly           bool              `json:"read_only"`
+	SsoAuth            bool              `json:"sso_auth"`
+	PasswordMode       string            `json:"password_mode"`
+	Token              bool              `json:"token"`
+	TokenTtl           int               `json:"token_ttl"`
+	Disabled           bool              `json:"disabled"`
+	SyncTime           int64             `json:"sync_time"`
+	SyncHosts          []string          `json:"sync_hosts"`
+	SyncHash           string            `json:"sync_hash"`
+	SyncSecret         string            `json:"sync_secret"`
+	SyncToken          string            `json:"sync_token"`
+	ServerPublicKey    []string          `json:"server_public_key"`
+	ServerBoxPublicKey string            `json:"server_box_public_key"`
+	RegistrationKey    string            `json:"registration_key"`
+	OvpnData           string            `json:"ovpn_data"`
+	Path               string            `json:"-"`
+	Password           string            `json:"password,omitempty"`
+	PasswordProtected  string            `json:"password_protected,omitempty"`
+	PasswordKeychain   bool              `json:"password_keychain,omitempty"`
+	AuthErrorCount     int               `json:"-"`
+	keychainErr        bool
 }
 
 type SprofileClient struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	State              bool     `json:"state"`
-	Wg                 bool     `json:"wg"`
-	LastMode           string   `json:"last_mode"`
-	OrganizationId     string   `json:"organization_id"`
-	Organization       string   `json:"organization"`
-	ServerId           string   `json:"server_id"`
-	Server             string   `json:"server"`
-	UserId             string   `json:"user_id"`
-	User               string   `json:"user"`
-	PreConnectMsg      string   `json:"pre_connect_msg"`
-	DynamicFirewall    bool     `json:"dynamic_firewall"`
-	DeviceAuth         bool     `json:"device_auth"`
-	DisableGateway     bool     `json:"disable_Gateway"`
-	DisableDns         bool     `json:"disable_dns"`
-	ForceDns           bool     `json:"force_dns"`
-	SsoAuth            bool     `json:"sso_auth"`
-	PasswordMode       string   `json:"password_mode"`
-	Token              bool     `json:"token"`
-	TokenTtl           int      `json:"token_ttl"`
-	Disabled           bool     `json:"disabled"`
-	SyncTime           int64    `json:"sync_time"`
-	SyncHosts          []string `json:"sync_hosts"`
-	SyncHash           string   `json:"sync_hash"`
-	SyncSecret         string   `json:"sync_secret"`
-	SyncToken          string   `json:"sync_token"`
-	ServerPublicKey    []string `json:"server_public_key"`
-	ServerBoxPublicKey string   `json:"server_box_public_key"`
-	RegistrationKey    string   `json:"registration_key"`
-	OvpnData           string   `json:"ovpn_data"`
+	Id                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Workspace          string            `json:"workspace"`
+	State              bool              `json:"state"`
+	Wg                 bool              `json:"wg"`
+	LastMode           string            `json:"last_mode"`
+	OrganizationId     string            `json:"organization_id"`
+	Organization       string            `json:"organization"`
+	ServerId           string            `json:"server_id"`
+	Server             string            `json:"server"`
+	UserId             string            `json:"user_id"`
+	User               string            `json:"user"`
+	PreConnectMsg      string            `json:"pre_connect_msg"`
+	DynamicFirewall    bool              `json:"dynamic_firewall"`
+	DeviceAuth         bool              `json:"device_auth"`
+	DisableGateway     bool              `json:"disable_Gateway"`
+	DisableDns         bool              `json:"disable_dns"`
+	ForceDns           bool              `json:"force_dns"`
+	SplitDnsDomains    []string          `json:"split_dns_domains"`
+	Env                map[string]string `json:"env"`
+	Hooks              map[string]string `json:"hooks"`
+	CredentialProvider string            `json:"credential_provider"`
+	CredentialCacheTtl int               `json:"credential_cache_ttl"`
+	RoutePriority      int               `json:"route_priority"`
+	IncludeRoutes      []string          `json:"include_routes"`
+	ExcludeRoutes      []string          `json:"exclude_routes"`
+	PersistTun         bool              `json:"persist_tun"`
+	HttpProxy          string            `json:"http_proxy"`
+	SocksProxy         string            `json:"socks_proxy"`
+	AlwaysOn           bool              `json:"always_on"`
+	KillSwitch         bool              `json:"kill_switch"`
+	CredentialId       string            `json:"credential_id"`
+	HostFailover       bool              `json:"host_failover"`
+	Verbosity          int               `json:"verbosity"`
+	SplitDnsPushed     bool              `json:"split_dns_pushed"`
+	DnsHijackMode      string            `json:"dns_hijack_mode"`
+	DisableIpv6        bool              `json:"disable_ipv6"`
+	SyslogTag          string            `json:"syslog_tag"`
+	WgTcpFallback      bool              `json:"wg_tcp_fallback"`
+	Mtu                int               `json:"mtu"`
+	MssFix             int               `json:"mss_fix"`
+	MtuProbe           bool              `json:"mtu_probe"`
+	SummarizeRoutes    bool              `json:"summarize_routes"`
+	ExpiresAt          int64             `json:"expires_at"`
+	ExpireDelete       bool              `json:"expire_delete"`
+	OnDemandSubnets    []string          `json:"on_demand_subnets"`
+	OnDemandIdle       int               `json:"on_demand_idle"`
+	RestartTriggers    []string          `json:"restart_triggers"`
+	StartDelay         int               `json:"start_delay"`
+	StartJitter        int               `json:"start_jitter"`
+	Features           *Flags            `json:"features"`
+	Managed            bool              `json:"managed"`
+	ReadOnly           bool              `json:"read_only"`
+	SsoAuth            bool              `json:"sso_auth"`
+	PasswordMode       string            `json:"password_mode"`
+	Token              bool              `json:"token"`
+	TokenTtl           int               `json:"token_ttl"`
+	Disabled           bool              `json:"disabled"`
+	SyncTime           int64             `json:"sync_time"`
+	SyncHosts          []string          `json:"sync_hosts"`
+	SyncHash           string            `json:"sync_hash"`
+	SyncSecret         string            `json:"sync_secret"`
+	SyncToken          string            `json:"sync_token"`
+	ServerPublicKey    []string          `json:"server_public_key"`
+	ServerBoxPublicKey string            `json:"server_box_public_key"`
+	RegistrationKey    string            `json:"registration_key"`
+	OvpnData           string            `json:"ovpn_data"`
+	LastError          string            `json:"last_error"`
+	LastErrorTime      int64             `json:"last_error_time"`
+	LastConnected      int64             `json:"last_connected"`
 }
 
 func (s *Sprofile) BasePath() string {
-	prflsPath := GetPath()
+	prflsPath := s.Dir()
 	return filepath.Join(prflsPath, s.Id)
 }
 
+func (s *Sprofile) IsReadOnly() bool {
+	return s.ReadOnly || s.Managed
+}
+
+// Read-only and managed profiles can only be changed by an administrator,
+// connecting and disconnecting is not affected
+func CheckReadOnly(prflId string) (err error) {
+	sprfl := Get(prflId)
+	if sprfl != nil && sprfl.IsReadOnly() {
+		err = &errortypes.PolicyError{
+			errors.New("sprofile: System profile is read-only"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Sprofile) Expired() bool {
+	return s.ExpiresAt != 0 && time.Now().Unix() >= s.ExpiresAt
+}
+
 func (s *Sprofile) Client() (sprflc *SprofileClient) {
 	sprflc = &SprofileClient{
 		Id:                 s.Id,
 		Name:               s.Name,
+		Workspace:          s.Workspace,
 		State:              s.State,
 		Wg:                 s.Wg,
 		LastMode:           s.LastMode,
@@ -136,6 +244,41 @@ func (s *Sprofile) Client() (sprflc *SprofileClient) {
 		DisableGateway:     s.DisableGateway,
 		DisableDns:         s.DisableDns,
 		ForceDns:           s.ForceDns,
+		SplitDnsDomains:    s.SplitDnsDomains,
+		Env:                s.Env,
+		Hooks:              s.Hooks,
+		CredentialProvider: s.CredentialProvider,
+		CredentialCacheTtl: s.CredentialCacheTtl,
+		RoutePriority:      s.RoutePriority,
+		IncludeRoutes:      s.IncludeRoutes,
+		ExcludeRoutes:      s.ExcludeRoutes,
+		PersistTun:         s.PersistTun,
+		HttpProxy:          proxy.Redact(s.HttpProxy),
+		SocksProxy:         proxy.Redact(s.SocksProxy),
+		AlwaysOn:           s.AlwaysOn,
+		KillSwitch:         s.KillSwitch,
+		CredentialId:       s.CredentialId,
+		HostFailover:       s.HostFailover,
+		Verbosity:          s.Verbosity,
+		SplitDnsPushed:     s.SplitDnsPushed,
+		DnsHijackMode:      s.DnsHijackMode,
+		DisableIpv6:        s.DisableIpv6,
+		SyslogTag:          s.SyslogTag,
+		WgTcpFallback:      s.WgTcpFallback,
+		Mtu:                s.Mtu,
+		MssFix:             s.MssFix,
+		MtuProbe:           s.MtuProbe,
+		SummarizeRoutes:    s.SummarizeRoutes,
+		ExpiresAt:          s.ExpiresAt,
+		ExpireDelete:       s.ExpireDelete,
+		OnDemandSubnets:    s.OnDemandSubnets,
+		OnDemandIdle:       s.OnDemandIdle,
+		RestartTriggers:    s.RestartTriggers,
+		StartDelay:         s.StartDelay,
+		StartJitter:        s.StartJitter,
+		Features:           s.Features.Copy(),
+		Managed:            s.Managed,
+		ReadOnly:           s.ReadOnly,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
 		Token:              s.Token,
@@ -164,6 +307,62 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		}
 	}
 
+	var splitDnsDomains []string
+	if s.SplitDnsDomains != nil {
+		splitDnsDomains = []string{}
+		for _, domain := range s.SplitDnsDomains {
+			splitDnsDomains = append(splitDnsDomains, domain)
+		}
+	}
+
+	var onDemandSubnets []string
+	if s.OnDemandSubnets != nil {
+		onDemandSubnets = []string{}
+		for _, subnet := range s.OnDemandSubnets {
+			onDemandSubnets = append(onDemandSubnets, subnet)
+		}
+	}
+
+	var restartTriggers []string
+	if s.RestartTriggers != nil {
+		restartTriggers = []string{}
+		for _, trigger := range s.RestartTriggers {
+			restartTriggers = append(restartTriggers, trigger)
+		}
+	}
+
+	var includeRoutes []string
+	if s.IncludeRoutes != nil {
+		includeRoutes = []string{}
+		for _, route := range s.IncludeRoutes {
+			includeRoutes = append(includeRoutes, route)
+		}
+	}
+
+	var excludeRoutes []string
+	if s.ExcludeRoutes != nil {
+		excludeRoutes = []string{}
+		for _, route := range s.ExcludeRoutes {
+			excludeRoutes = append(excludeRoutes, route)
+		}
+	}
+
+	var env map[string]string
+	if s.Env != nil {
+		env = map[string]string{}
+		for key, val := range s.Env {
+			env[key] = val
+		}
+	}
+
+	var hooks map[string]string
+	if s.Hooks != nil {
+		hooks = map[string]string{}
+		for key, val := range s.Hooks {
+			hooks[key] = val
+		}
+	}
+
 	var serverPublicKey []string
 	if s.ServerPublicKey != nil {
 		serverPublicKey = []string{}
@@ -175,6 +374,7 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 	sprfl = &Sprofile{
 		Id:                 s.Id,
 		Name:               s.Name,
+		Workspace:          s.Workspace,
 		State:              s.State,
 		Wg:                 s.Wg,
 		LastMode:           s.LastMode,
@@ -190,6 +390,41 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		DisableGateway:     s.DisableGateway,
 		DisableDns:         s.DisableDns,
 		ForceDns:           s.ForceDns,
+		SplitDnsDomains:    splitDnsDomains,
+		Env:                env,
+		Hooks:              hooks,
+		CredentialProvider: s.CredentialProvider,
+		CredentialCacheTtl: s.CredentialCacheTtl,
+		RoutePriority:      s.RoutePriority,
+		IncludeRoutes:      includeRoutes,
+		ExcludeRoutes:      excludeRoutes,
+		PersistTun:         s.PersistTun,
+		HttpProxy:          s.HttpProxy,
+		SocksProxy:         s.SocksProxy,
+		AlwaysOn:           s.AlwaysOn,
+		KillSwitch:         s.KillSwitch,
+		CredentialId:       s.CredentialId,
+		HostFailover:       s.HostFailover,
+		Verbosity:          s.Verbosity,
+		SplitDnsPushed:     s.SplitDnsPushed,
+		DnsHijackMode:      s.DnsHijackMode,
+		DisableIpv6:        s.DisableIpv6,
+		SyslogTag:          s.SyslogTag,
+		WgTcpFallback:      s.WgTcpFallback,
+		Mtu:                s.Mtu,
+		MssFix:             s.MssFix,
+		MtuProbe:           s.MtuProbe,
+		SummarizeRoutes:    s.SummarizeRoutes,
+		ExpiresAt:          s.ExpiresAt,
+		ExpireDelete:       s.ExpireDelete,
+		OnDemandSubnets:    onDemandSubnets,
+		OnDemandIdle:       s.OnDemandIdle,
+		RestartTriggers:    restartTriggers,
+		StartDelay:         s.StartDelay,
+		StartJitter:        s.StartJitter,
+		Features:           s.Features.Copy(),
+		Managed:            s.Managed,
+		ReadOnly:           s.ReadOnly,
 		SsoAuth:            s.SsoAuth,
 		PasswordMode:       s.PasswordMode,
 		Token:              s.Token,
@@ -206,7 +441,10 @@ func (s *Sprofile) Copy() (sprfl *Sprofile) {
 		OvpnData:           s.OvpnData,
 		Path:               s.Path,
 		Password:           s.Password,
+		PasswordProtected:  s.PasswordProtected,
+		PasswordKeychain:   s.PasswordKeychain,
 		AuthErrorCount:     s.AuthErrorCount,
+		keychainErr:        s.keychainErr,
 	}
 
 	return
@@ -371,6 +609,15 @@ func (s *Sprofile) syncUpdate(data string) (updated bool, err error) {
 		s.SyncHash = confData.SyncHash
 		s.ServerPublicKey = confData.ServerPublicKey
 		s.ServerBoxPublicKey = confData.ServerBoxPublicKey
+		s.Features = confData.Features
+		// Expiry pushed by the server, an expiry set by an admin token is
+		// kept when the server does not set one
+		if confData.ExpiresAt != 0 {
+			s.ExpiresAt = confData.ExpiresAt
+			s.ExpireDelete = confData.ExpireDelete
+		}
+		s.ApplyWorkspace()
+		s.ApplyFeatures()
 	}
 
 	if strings.Contains(s.OvpnData, "key-direction") &&
@@ -463,6 +710,15 @@ func (s *Sprofile) syncProfile(host string) (updated bool, err error) {
 	req.Header.Set("Auth-Nonce", authNonce)
 	req.Header.Set("Auth-Signature", sig)
 	req.Header.Set("User-Agent", "pritunl")
+	req.Header.Set("Client-Device-Id", config.Config.DeviceId)
+
+	posture.Sign(req, s.SyncToken, s.SyncSecret, timestamp, authNonce)
+
+	prxy, err := proxy.Get(s.HttpProxy, s.SocksProxy)
+	if err != nil {
+		return
+	}
+	req = proxy.WithProxy(req, prxy)
 
 	res, err := clientInsecure.Do(req)
 	if err != nil {
@@ -534,8 +790,107 @@ func (s *Sprofile) Sync() (updated bool, err error) {
 	return
 }
 
+func passwordKey(prflId string) string {
+	return "profile-" + prflId + "-password"
+}
+
+// Copy of the profile with the password moved to the credential store or
+// encrypted for storage when supported by the platform
+func (s *Sprofile) protect() (sprfl *Sprofile, err error) {
+	sprfl = s
+
+	// Keep the stored password if it could not be read at load
+	if s.Password == "" {
+		if s.PasswordKeychain && !s.keychainErr {
+			err = secrets.Remove(passwordKey(s.Id))
+			if err != nil {
+				return
+			}
+			s.PasswordKeychain = false
+		}
+		return
+	}
+
+	if secrets.Available() {
+		err = secrets.Set(passwordKey(s.Id), s.Password)
+		if err == nil {
+			s.PasswordKeychain = true
+			s.keychainErr = false
+
+			sprfl = s.Copy()
+			sprfl.Password = ""
+			sprfl.PasswordProtected = ""
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": s.Id,
+			"error":      err,
+		}).Error("sprofile: Failed to store password in credential store")
+		err = nil
+	}
+
+	if s.PasswordKeychain {
+		_ = secrets.Remove(passwordKey(s.Id))
+		s.PasswordKeychain = false
+	}
+
+	if !platform.ProtectSupported {
+		return
+	}
+
+	protected, err := platform.Protect([]byte(s.Password))
+	if err != nil {
+		return
+	}
+
+	sprfl = s.Copy()
+	sprfl.Password = ""
+	sprfl.PasswordProtected = base64.StdEncoding.EncodeToString(protected)
+
+	return
+}
+
+func (s *Sprofile) unprotect() (err error) {
+	if s.PasswordKeychain {
+		password, e := secrets.Get(passwordKey(s.Id))
+		if e != nil {
+			s.keychainErr = true
+			err = e
+			return
+		}
+
+		s.Password = password
+		s.keychainErr = false
+		s.PasswordProtected = ""
+		return
+	}
+
+	if s.PasswordProtected == "" {
+		return
+	}
+
+	protected, err := base64.StdEncoding.DecodeString(s.PasswordProtected)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "sprofile: Failed to decode password"),
+		}
+		return
+	}
+
+	password, err := platform.Unprotect(protected)
+	if err != nil {
+		return
+	}
+
+	s.Password = string(password)
+	s.PasswordProtected = ""
+
+	return
+}
+
 func (s *Sprofile) Commit() (err error) {
-	prflsPath := GetPath()
+	prflsPath := s.Dir()
 
 	err = platform.MkdirSecure(prflsPath)
 	if err != nil {
@@ -544,7 +899,12 @@ func (s *Sprofile) Commit() (err error) {
 
 	pth := filepath.Join(prflsPath, s.Id+".conf")
 
-	data, err := json.Marshal(s)
+	sprfl, err := s.protect()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(sprfl)
 	if err != nil {
 		err = &errortypes.ParseError{
 			errors.Wrap(err, "sprofiles: Failed to parse profile data"),
@@ -557,6 +917,11 @@ func (s *Sprofile) Commit() (err error) {
 		return
 	}
 
+	err = writeChecksum(pth, data)
+	if err != nil {
+		return
+	}
+
 	cacheStale = true
 
 	return
@@ -568,8 +933,14 @@ func (s *Sprofile) Delete() (err error) {
 	logPth2 := s.BasePath() + ".log.1"
 
 	_ = utils.Remove(prflPth)
+	_ = utils.Remove(sumPath(prflPth))
 	_ = utils.Remove(logPth1)
 	_ = utils.Remove(logPth2)
 
+	if s.PasswordKeychain {
+		_ = secrets.Remove(passwordKey(s.Id))
+	}
+	_ = credential.RemoveOtpSeed(s.Id)
+
 	return
 }
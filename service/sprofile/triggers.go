@@ -0,0 +1,61 @@
+package sprofile
+
+import (
+	"strings"
+)
+
+const (
+	TriggerDnsChange     = "dns_change"
+	TriggerGatewayChange = "gateway_change"
+	TriggerProcess       = "process:"
+)
+
+func FilterTriggers(inputs []string) (triggers []string) {
+	triggers = []string{}
+	seen := map[string]bool{}
+
+	for _, input := range inputs {
+		trigger := strings.TrimSpace(input)
+
+		switch {
+		case trigger == TriggerDnsChange, trigger == TriggerGatewayChange:
+			break
+		case strings.HasPrefix(trigger, TriggerProcess):
+			name := strings.ToLower(strings.TrimSpace(
+				trigger[len(TriggerProcess):]))
+			if name == "" || strings.ContainsAny(name, "/\\") {
+				continue
+			}
+			trigger = TriggerProcess + name
+			break
+		default:
+			continue
+		}
+
+		if seen[trigger] {
+			continue
+		}
+		seen[trigger] = true
+
+		triggers = append(triggers, trigger)
+	}
+
+	return
+}
+
+// Always-on profiles reconnect when the network changes
+func (s *Sprofile) GetRestartTriggers() (triggers []string) {
+	triggers = s.RestartTriggers
+	if !s.AlwaysOn {
+		return
+	}
+
+	for _, trigger := range triggers {
+		if trigger == TriggerGatewayChange {
+			return
+		}
+	}
+	triggers = append(append([]string{}, triggers...), TriggerGatewayChange)
+
+	return
+}
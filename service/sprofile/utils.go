@@ -11,7 +11,11 @@ import (
 	"sync"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/credential"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/secrets"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -65,6 +69,56 @@ func Deactivate(prflId string) {
 	cache = prflsCache
 }
 
+func SetSplitDnsDomains(prflId string, domains []string) (err error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	prflsCache := []*Sprofile{}
+
+	for _, prfl := range cache {
+		if prfl.Id == prflId {
+			prfl = prfl.Copy()
+
+			prfl.SplitDnsDomains = domains
+
+			err = prfl.Commit()
+			if err != nil {
+				return
+			}
+		}
+		prflsCache = append(prflsCache, prfl)
+	}
+
+	cache = prflsCache
+
+	return
+}
+
+func SetVerbosity(prflId string, level int) (err error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	prflsCache := []*Sprofile{}
+
+	for _, prfl := range cache {
+		if prfl.Id == prflId {
+			prfl = prfl.Copy()
+
+			prfl.Verbosity = level
+
+			err = prfl.Commit()
+			if err != nil {
+				return
+			}
+		}
+		prflsCache = append(prflsCache, prfl)
+	}
+
+	cache = prflsCache
+
+	return
+}
+
 func SetAuthErrorCount(prflId string, errorCount int) {
 	cacheLock.Lock()
 	defer cacheLock.Unlock()
@@ -82,6 +136,10 @@ func SetAuthErrorCount(prflId string, errorCount int) {
 }
 
 func GetPath() string {
+	if constants.ProfilesDir != "" {
+		return constants.ProfilesDir
+	}
+
 	switch runtime.GOOS {
 	case "windows":
 		return filepath.Join(utils.GetWinDrive(), "ProgramData",
@@ -150,11 +208,19 @@ func GetAllClient() (prfls []*SprofileClient, err error) {
 
 func Remove(prflId string) {
 	prflsPath := GetPath()
+	prfl := Get(prflId)
+	if prfl != nil {
+		prflsPath = prfl.Dir()
+	}
+
 	prflPth := filepath.Join(prflsPath, fmt.Sprintf("%s.conf", prflId))
 	logPth := filepath.Join(prflsPath, fmt.Sprintf("%s.log", prflId))
 
 	_ = os.Remove(prflPth)
+	_ = os.Remove(sumPath(prflPth))
 	_ = os.Remove(logPth)
+	_ = secrets.Remove(passwordKey(prflId))
+	_ = credential.RemoveOtpSeed(prflId)
 
 	cacheStale = true
 }
@@ -163,7 +229,6 @@ func Reload(init bool) (err error) {
 	cacheLock.Lock()
 	defer cacheLock.Unlock()
 
-	prflsPath := GetPath()
 	prfls := []*Sprofile{}
 
 	curPrfls := map[string]*Sprofile{}
@@ -171,6 +236,31 @@ func Reload(init bool) (err error) {
 		curPrfls[prfl.Id] = prfl
 	}
 
+	wsIds := []string{""}
+	for _, ws := range GetWorkspaces() {
+		wsIds = append(wsIds, ws.Id)
+	}
+
+	for _, wsId := range wsIds {
+		wsPrfls, e := load(wsId, init, curPrfls)
+		if e != nil {
+			err = e
+			return
+		}
+		prfls = append(prfls, wsPrfls...)
+	}
+
+	cache = prfls
+	cacheStale = false
+
+	return
+}
+
+func load(wsId string, init bool, curPrfls map[string]*Sprofile) (
+	prfls []*Sprofile, err error) {
+
+	prflsPath := GetWorkspacePath(wsId)
+
 	files, err := ioutil.ReadDir(prflsPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -201,8 +291,17 @@ func Reload(init bool) (err error) {
 			continue
 		}
 
+		if init {
+			e = verifyChecksum(pth, data)
+			if e != nil {
+				quarantine(pth, "", e)
+				continue
+			}
+		}
+
 		prfl := &Sprofile{
-			Path: pth,
+			Workspace: wsId,
+			Path:      pth,
 		}
 
 		e = json.Unmarshal(data, prfl)
@@ -210,6 +309,11 @@ func Reload(init bool) (err error) {
 			err = &errortypes.ParseError{
 				errors.Wrap(e, "sprofile: Failed to parse conf"),
 			}
+			if init {
+				quarantine(pth, "", err)
+				err = nil
+				continue
+			}
 			logrus.WithFields(logrus.Fields{
 				"path":  pth,
 				"error": err,
@@ -217,8 +321,41 @@ func Reload(init bool) (err error) {
 			continue
 		}
 
+		plaintext := prfl.Password != "" && prfl.PasswordProtected == ""
+		migrate := !prfl.PasswordKeychain &&
+			(prfl.Password != "" || prfl.PasswordProtected != "")
+
+		e = prfl.unprotect()
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": e,
+			}).Error("sprofile: Failed to decrypt profile password")
+			migrate = false
+		}
+
 		if init {
-			prfl.State = !prfl.Disabled
+			e = prfl.verify()
+			if e != nil {
+				quarantine(pth, prfl.Id, e)
+				continue
+			}
+
+			prfl.State = !prfl.Disabled || prfl.AlwaysOn
+
+			// Move passwords from the profile file to the credential
+			// store when one becomes available
+			if (plaintext && platform.ProtectSupported) ||
+				(migrate && secrets.Available()) {
+
+				e = prfl.Commit()
+				if e != nil {
+					logrus.WithFields(logrus.Fields{
+						"path":  pth,
+						"error": e,
+					}).Error("sprofile: Failed to protect profile password")
+				}
+			}
 		} else {
 			curPrfl := curPrfls[prfl.Id]
 			if curPrfl != nil {
@@ -229,14 +366,16 @@ func Reload(init bool) (err error) {
 		prfls = append(prfls, prfl)
 	}
 
-	cache = prfls
-	cacheStale = false
-
 	return
 }
 
 func ClearLog(prflId string) (err error) {
 	prflsPath := GetPath()
+	prfl := Get(prflId)
+	if prfl != nil {
+		prflsPath = prfl.Dir()
+	}
+
 	pth := filepath.Join(prflsPath, fmt.Sprintf("%s.log", prflId))
 
 	err = utils.CreateWriteLock(pth, "", 0600)
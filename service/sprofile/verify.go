@@ -0,0 +1,239 @@
+package sprofile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	quarantined     = []*Quarantined{}
+	quarantinedLock = sync.Mutex{}
+	inlineTags      = []string{
+		"ca",
+		"cert",
+		"key",
+		"tls-auth",
+		"tls-crypt",
+	}
+	fileDirectives = map[string]bool{
+		"ca":             true,
+		"cert":           true,
+		"key":            true,
+		"tls-auth":       true,
+		"tls-crypt":      true,
+		"pkcs12":         true,
+		"dh":             true,
+		"secret":         true,
+		"auth-user-pass": true,
+	}
+)
+
+type FileReference struct {
+	Directive string
+	Name      string
+	Path      string
+}
+
+type Quarantined struct {
+	Id        string `json:"id"`
+	Path      string `json:"path"`
+	Error     string `json:"error"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+func GetQuarantinePath() string {
+	return filepath.Join(GetPath(), "quarantine")
+}
+
+func GetQuarantined() (prfls []*Quarantined) {
+	quarantinedLock.Lock()
+	defer quarantinedLock.Unlock()
+
+	prfls = []*Quarantined{}
+	for _, prfl := range quarantined {
+		prflCopy := &Quarantined{}
+		*prflCopy = *prfl
+		prfls = append(prfls, prflCopy)
+	}
+
+	return
+}
+
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func sumPath(confPth string) string {
+	return strings.TrimSuffix(confPth, ".conf") + ".sum"
+}
+
+func writeChecksum(confPth string, data []byte) (err error) {
+	err = utils.CreateWrite(sumPath(confPth), checksum(data), 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func verifyChecksum(confPth string, data []byte) (err error) {
+	sumData, err := ioutil.ReadFile(sumPath(confPth))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "sprofile: Failed to read profile checksum"),
+		}
+		return
+	}
+
+	if strings.TrimSpace(string(sumData)) != checksum(data) {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Profile checksum mismatch"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *Sprofile) verify() (err error) {
+	name := strings.TrimSuffix(filepath.Base(s.Path), ".conf")
+
+	if s.Id == "" {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Profile missing ID"),
+		}
+		return
+	}
+
+	if s.Id != name {
+		err = &errortypes.ParseError{
+			errors.Newf("sprofile: Profile ID '%s' does not match file",
+				s.Id),
+		}
+		return
+	}
+
+	if strings.TrimSpace(s.OvpnData) == "" {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Profile missing configuration"),
+		}
+		return
+	}
+
+	data := strings.ReplaceAll(s.OvpnData, "\r", "")
+
+	for _, tag := range inlineTags {
+		if strings.Count(data, fmt.Sprintf("<%s>", tag)) !=
+			strings.Count(data, fmt.Sprintf("</%s>", tag)) {
+
+			err = &errortypes.ParseError{
+				errors.Newf("sprofile: Profile configuration has "+
+					"unterminated <%s> block", tag),
+			}
+			return
+		}
+	}
+
+	for _, ref := range GetFileReferences(data) {
+		_, e := os.Stat(ref.Path)
+		if e != nil {
+			err = &errortypes.NotFoundError{
+				errors.Wrapf(e, "sprofile: Profile references missing "+
+					"file '%s'", ref.Name),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func GetFileReferences(data string) (refs []*FileReference) {
+	refs = []*FileReference{}
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !fileDirectives[strings.ToLower(fields[0])] {
+			continue
+		}
+
+		pth := fields[1]
+		if pth == "[inline]" {
+			continue
+		}
+
+		if !filepath.IsAbs(pth) {
+			pth = filepath.Join(GetPath(), pth)
+		}
+
+		refs = append(refs, &FileReference{
+			Directive: strings.ToLower(fields[0]),
+			Name:      fields[1],
+			Path:      pth,
+		})
+	}
+
+	return
+}
+
+func quarantine(pth string, prflId string, cause error) {
+	quarPath := GetQuarantinePath()
+	name := filepath.Base(pth)
+
+	if prflId == "" {
+		prflId = strings.TrimSuffix(name, ".conf")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": prflId,
+		"path":       pth,
+		"error":      cause,
+	}).Error("sprofile: Quarantining corrupt profile")
+
+	quarPth := pth
+	err := platform.MkdirSecure(quarPath)
+	if err == nil {
+		quarPth = filepath.Join(quarPath, name)
+		err = os.Rename(pth, quarPth)
+		if err != nil {
+			quarPth = pth
+		} else {
+			_ = os.Rename(sumPath(pth), sumPath(quarPth))
+		}
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": prflId,
+			"path":       pth,
+			"error":      err,
+		}).Error("sprofile: Failed to move corrupt profile to quarantine")
+	}
+
+	quarantinedLock.Lock()
+	quarantined = append(quarantined, &Quarantined{
+		Id:        prflId,
+		Path:      quarPth,
+		Error:     cause.Error(),
+		Timestamp: time.Now().Unix(),
+	})
+	quarantinedLock.Unlock()
+}
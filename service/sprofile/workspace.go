@@ -0,0 +1,197 @@
+package sprofile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+)
+
+var workspacesLock = sync.Mutex{}
+
+// Profiles of the default workspace are stored in the profiles directory,
+// other workspaces use a subdirectory
+func GetWorkspacePath(wsId string) string {
+	if wsId == "" {
+		return GetPath()
+	}
+	return filepath.Join(GetPath(), "workspaces", wsId)
+}
+
+func (s *Sprofile) Dir() string {
+	return GetWorkspacePath(s.Workspace)
+}
+
+func GetWorkspaces() (wss []*config.Workspace) {
+	wss = []*config.Workspace{}
+
+	workspacesLock.Lock()
+	defer workspacesLock.Unlock()
+
+	for _, ws := range config.Config.Workspaces {
+		wsCopy := *ws
+		wss = append(wss, &wsCopy)
+	}
+
+	return
+}
+
+func GetWorkspace(wsId string) (ws *config.Workspace) {
+	if wsId == "" {
+		return
+	}
+
+	workspacesLock.Lock()
+	defer workspacesLock.Unlock()
+
+	for _, w := range config.Config.Workspaces {
+		if w.Id == wsId {
+			wsCopy := *w
+			ws = &wsCopy
+			return
+		}
+	}
+
+	return
+}
+
+func ValidateWorkspace(ws *config.Workspace) (err error) {
+	ws.Name = utils.FilterStr(ws.Name)
+	ws.HttpProxy = strings.TrimSpace(ws.HttpProxy)
+	ws.SocksProxy = strings.TrimSpace(ws.SocksProxy)
+
+	if ws.Name == "" {
+		err = &errortypes.ParseError{
+			errors.New("sprofile: Workspace name required"),
+		}
+		return
+	}
+
+	if ws.HttpProxy != "" {
+		_, err = proxy.Parse(ws.HttpProxy, false)
+		if err != nil {
+			return
+		}
+	}
+
+	if ws.SocksProxy != "" {
+		_, err = proxy.Parse(ws.SocksProxy, true)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func CreateWorkspace(ws *config.Workspace) (err error) {
+	err = ValidateWorkspace(ws)
+	if err != nil {
+		return
+	}
+
+	wsId, err := utils.RandStr(16)
+	if err != nil {
+		return
+	}
+
+	ws.Id = strings.ToLower(wsId)
+	ws.Timestamp = time.Now().Unix()
+
+	workspacesLock.Lock()
+	defer workspacesLock.Unlock()
+
+	prevWss := config.Config.Workspaces
+	config.Config.Workspaces = append(append(
+		[]*config.Workspace{}, prevWss...), ws)
+
+	err = config.Save()
+	if err != nil {
+		config.Config.Workspaces = prevWss
+		return
+	}
+
+	return
+}
+
+// Workspaces must be emptied before removal to avoid discarding the
+// profiles of an organization
+func DeleteWorkspace(wsId string) (found bool, err error) {
+	prfls, err := GetAll()
+	if err != nil {
+		return
+	}
+
+	for _, prfl := range prfls {
+		if prfl.Workspace == wsId {
+			err = &errortypes.ParseError{
+				errors.New("sprofile: Workspace contains profiles"),
+			}
+			return
+		}
+	}
+
+	workspacesLock.Lock()
+	defer workspacesLock.Unlock()
+
+	prevWss := config.Config.Workspaces
+	wss := []*config.Workspace{}
+
+	for _, ws := range prevWss {
+		if ws.Id == wsId {
+			found = true
+			continue
+		}
+		wss = append(wss, ws)
+	}
+
+	if !found {
+		return
+	}
+
+	config.Config.Workspaces = wss
+
+	err = config.Save()
+	if err != nil {
+		config.Config.Workspaces = prevWss
+		return
+	}
+
+	_ = os.RemoveAll(GetWorkspacePath(wsId))
+
+	return
+}
+
+// Override the profile settings with the workspace policy
+func (s *Sprofile) ApplyWorkspace() {
+	ws := GetWorkspace(s.Workspace)
+	if ws == nil {
+		return
+	}
+
+	if ws.KillSwitch != nil {
+		s.KillSwitch = *ws.KillSwitch
+	}
+	if ws.AlwaysOn != nil {
+		s.AlwaysOn = *ws.AlwaysOn
+	}
+	if ws.ForceDns != nil {
+		s.ForceDns = *ws.ForceDns
+	}
+	if ws.DisableIpv6 != nil {
+		s.DisableIpv6 = *ws.DisableIpv6
+	}
+	if ws.HttpProxy != "" {
+		s.HttpProxy = ws.HttpProxy
+	}
+	if ws.SocksProxy != "" {
+		s.SocksProxy = ws.SocksProxy
+	}
+}
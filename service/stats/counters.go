@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"sync"
+)
+
+var (
+	counters     = map[string]*Counters{}
+	countersLock = sync.Mutex{}
+)
+
+// Connection counts per profile since the service started
+type Counters struct {
+	Connects   int64 `json:"connects"`
+	Reconnects int64 `json:"reconnects"`
+}
+
+func count(prflId, status string) {
+	countersLock.Lock()
+	defer countersLock.Unlock()
+
+	cntrs := counters[prflId]
+	if cntrs == nil {
+		cntrs = &Counters{}
+		counters[prflId] = cntrs
+	}
+
+	switch status {
+	case "connected":
+		cntrs.Connects += 1
+		break
+	case "reconnecting":
+		cntrs.Reconnects += 1
+		break
+	}
+}
+
+func GetCounters() (cntrs map[string]*Counters) {
+	cntrs = map[string]*Counters{}
+
+	countersLock.Lock()
+	for prflId, c := range counters {
+		cntrs[prflId] = &Counters{
+			Connects:   c.Connects,
+			Reconnects: c.Reconnects,
+		}
+	}
+	countersLock.Unlock()
+
+	return
+}
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func readHistory(pth, prflId string) (transs []*Transition, err error) {
+	transs = []*Transition{}
+
+	file, err := os.Open(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "stats: Failed to open history file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		trans := &Transition{}
+
+		e := json.Unmarshal(scanner.Bytes(), trans)
+		if e != nil {
+			continue
+		}
+
+		if prflId != "" && trans.ProfileId != prflId {
+			continue
+		}
+
+		transs = append(transs, trans)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "stats: Failed to read history file"),
+		}
+		return
+	}
+
+	return
+}
+
+func GetHistory(prflId string, limit int) (transs []*Transition, err error) {
+	Flush()
+
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	pth := GetHistoryPath()
+
+	transs, err = readHistory(pth+".1", prflId)
+	if err != nil {
+		return
+	}
+
+	cur, err := readHistory(pth, prflId)
+	if err != nil {
+		return
+	}
+	transs = append(transs, cur...)
+
+	if limit > 0 && len(transs) > limit {
+		transs = transs[len(transs)-limit:]
+	}
+
+	return
+}
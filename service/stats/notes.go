@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	notes       = map[string]*Notes{}
+	notesLoaded = false
+	notesDirty  = false
+	notesLock   = sync.Mutex{}
+	notesSave   = make(chan bool, 1)
+)
+
+type Notes struct {
+	LastError     string `json:"last_error"`
+	LastErrorTime int64  `json:"last_error_time"`
+	LastConnected int64  `json:"last_connected"`
+}
+
+func GetNotesPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "notes.json")
+}
+
+func loadNotes() {
+	if notesLoaded {
+		return
+	}
+	notesLoaded = true
+
+	data, err := ioutil.ReadFile(GetNotesPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "stats: Failed to read notes file"),
+			}
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("stats: Failed to load profile notes")
+		}
+		return
+	}
+
+	loaded := map[string]*Notes{}
+	err = json.Unmarshal(data, &loaded)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "stats: Failed to parse notes file"),
+		}
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("stats: Failed to load profile notes")
+		return
+	}
+
+	for prflId, note := range loaded {
+		if notes[prflId] == nil {
+			notes[prflId] = note
+		}
+	}
+}
+
+func updateNotes(prflId string, handler func(note *Notes)) {
+	notesLock.Lock()
+	note := notes[prflId]
+	if note == nil {
+		note = &Notes{}
+		notes[prflId] = note
+	}
+	handler(note)
+	notesDirty = true
+	notesLock.Unlock()
+
+	select {
+	case notesSave <- true:
+	default:
+	}
+}
+
+func GetNotes(prflId string) (note *Notes) {
+	notesLock.Lock()
+	defer notesLock.Unlock()
+
+	loadNotes()
+
+	cur := notes[prflId]
+	if cur == nil {
+		return
+	}
+
+	note = &Notes{}
+	*note = *cur
+
+	return
+}
+
+func SetLastError(prflId, code string) {
+	updateNotes(prflId, func(note *Notes) {
+		note.LastError = code
+		note.LastErrorTime = time.Now().Unix()
+	})
+}
+
+func setLastConnected(prflId string, timestamp time.Time) {
+	updateNotes(prflId, func(note *Notes) {
+		note.LastConnected = timestamp.Unix()
+	})
+}
+
+func saveNotes() (err error) {
+	notesLock.Lock()
+	if !notesDirty {
+		notesLock.Unlock()
+		return
+	}
+	notesDirty = false
+
+	data, err := json.Marshal(notes)
+	notesLock.Unlock()
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "stats: Failed to marshal notes"),
+		}
+		return
+	}
+
+	pth := GetNotesPath()
+
+	err = platform.MkdirReadSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(pth, string(data), 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func commitNotes() {
+	err := saveNotes()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("stats: Failed to save profile notes")
+	}
+}
@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	queueSize     = 1024
+	batchSize     = 128
+	flushInterval = 2 * time.Second
+	historyMax    = 2000000
+)
+
+var (
+	queue     = make(chan *Transition, queueSize)
+	flushReq  = make(chan chan bool)
+	dropped   = 0
+	writeLock = sync.Mutex{}
+	startOnce = sync.Once{}
+)
+
+type Transition struct {
+	Timestamp  time.Time `json:"timestamp"`
+	ProfileId  string    `json:"profile_id"`
+	Mode       string    `json:"mode"`
+	PrevStatus string    `json:"prev_status"`
+	Status     string    `json:"status"`
+	Leftovers  []string  `json:"leftovers,omitempty"`
+}
+
+func GetHistoryPath() string {
+	return filepath.Join(filepath.Dir(sprofile.GetPath()), "history.log")
+}
+
+func Record(prflId, mode, prevStatus, status string) {
+	trans := &Transition{
+		Timestamp:  time.Now(),
+		ProfileId:  prflId,
+		Mode:       mode,
+		PrevStatus: prevStatus,
+		Status:     status,
+	}
+
+	if status == "connected" {
+		setLastConnected(prflId, trans.Timestamp)
+	}
+	count(prflId, status)
+
+	enqueue(trans)
+}
+
+// Record resources left on the system after a disconnect
+func RecordDirtyTeardown(prflId, mode string, leftovers []string) {
+	enqueue(&Transition{
+		Timestamp:  time.Now(),
+		ProfileId:  prflId,
+		Mode:       mode,
+		PrevStatus: "disconnected",
+		Status:     "dirty_teardown",
+		Leftovers:  leftovers,
+	})
+}
+
+func enqueue(trans *Transition) {
+	select {
+	case queue <- trans:
+	default:
+		writeLock.Lock()
+		dropped += 1
+		writeLock.Unlock()
+	}
+}
+
+func commit(batch []*Transition) (err error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	if dropped > 0 {
+		logrus.WithFields(logrus.Fields{
+			"dropped": dropped,
+		}).Warn("stats: History queue full, dropped transitions")
+		dropped = 0
+	}
+
+	pth := GetHistoryPath()
+
+	err = platform.MkdirReadSecure(filepath.Dir(pth))
+	if err != nil {
+		return
+	}
+
+	stat, err := os.Stat(pth)
+	if err == nil && stat.Size() >= historyMax {
+		os.Remove(pth + ".1")
+		err = os.Rename(pth, pth+".1")
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "stats: Failed to rotate history file"),
+			}
+			return
+		}
+	}
+	err = nil
+
+	file, err := os.OpenFile(pth, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to open history file"),
+		}
+		return
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, trans := range batch {
+		data, e := json.Marshal(trans)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "stats: Failed to marshal transition"),
+			}
+			return
+		}
+
+		_, _ = writer.Write(data)
+		_ = writer.WriteByte('\n')
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "stats: Failed to write history file"),
+		}
+		return
+	}
+
+	return
+}
+
+func flush(batch []*Transition) []*Transition {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	err := commit(batch)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"count": len(batch),
+			"error": err,
+		}).Error("stats: Failed to commit history")
+	}
+
+	return batch[:0]
+}
+
+func writer() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("stats: Panic")
+			panic(panc)
+		}
+	}()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Transition, 0, batchSize)
+
+	for {
+		select {
+		case trans := <-queue:
+			batch = append(batch, trans)
+			if len(batch) >= batchSize {
+				batch = flush(batch)
+			}
+			break
+		case <-ticker.C:
+			batch = flush(batch)
+			break
+		case <-notesSave:
+			commitNotes()
+			break
+		case done := <-flushReq:
+		drain:
+			for {
+				select {
+				case trans := <-queue:
+					batch = append(batch, trans)
+					break
+				default:
+					break drain
+				}
+			}
+			batch = flush(batch)
+			commitNotes()
+			done <- true
+			break
+		}
+	}
+}
+
+func StartWriter() {
+	startOnce.Do(func() {
+		notesLock.Lock()
+		loadNotes()
+		notesLock.Unlock()
+
+		go writer()
+	})
+}
+
+func Flush() {
+	done := make(chan bool, 1)
+
+	select {
+	case flushReq <- done:
+		break
+	case <-time.After(3 * time.Second):
+		return
+	}
+
+	select {
+	case <-done:
+		break
+	case <-time.After(5 * time.Second):
+		logrus.Warn("stats: Timeout flushing history")
+	}
+}
@@ -0,0 +1,436 @@
+// Time limited streaming of service logs and events to a support endpoint,
+// sessions are only started by the user and end at the expiration.
+package support
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	KindLog   = "log"
+	KindEvent = "event"
+
+	DefaultDuration = 1 * time.Hour
+	MaxDuration     = 4 * time.Hour
+
+	queueSize     = 1024
+	batchSize     = 200
+	flushInterval = 2 * time.Second
+	maxFailures   = 10
+)
+
+var (
+	current     *Session
+	currentLock = sync.Mutex{}
+	client      = &http.Client{
+		Transport: &http.Transport{
+			Proxy: proxy.Func,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+)
+
+type Session struct {
+	Id      string    `json:"id"`
+	Url     string    `json:"url"`
+	Start   time.Time `json:"start"`
+	Expires time.Time `json:"expires"`
+	Sent    int       `json:"sent"`
+	Dropped int       `json:"dropped"`
+	token   string
+	queue   chan *Record
+	stop    chan bool
+	once    sync.Once
+}
+
+// Log entry or event sent to the support endpoint
+type Record struct {
+	Kind      string      `json:"kind"`
+	Timestamp time.Time   `json:"timestamp"`
+	Type      string      `json:"type,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+type batchData struct {
+	SessionId string    `json:"session_id"`
+	Version   string    `json:"version"`
+	Records   []*Record `json:"records"`
+}
+
+type SessionEvent struct {
+	Id      string    `json:"id"`
+	Url     string    `json:"url"`
+	Active  bool      `json:"active"`
+	Expires time.Time `json:"expires"`
+}
+
+func (s *Session) copy() *Session {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	return &Session{
+		Id:      s.Id,
+		Url:     s.Url,
+		Start:   s.Start,
+		Expires: s.Expires,
+		Sent:    s.Sent,
+		Dropped: s.Dropped,
+	}
+}
+
+func (s *Session) push(rec *Record) {
+	select {
+	case s.queue <- rec:
+		break
+	default:
+		currentLock.Lock()
+		s.Dropped += 1
+		currentLock.Unlock()
+	}
+}
+
+func (s *Session) send(records []*Record) (err error) {
+	data, err := json.Marshal(&batchData{
+		SessionId: s.Id,
+		Version:   constants.Version,
+		Records:   records,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "support: Failed to marshal records"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest("POST", s.Url, bytes.NewReader(data))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "support: Request error"),
+		}
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pritunl-client")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	res, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "support: Request error"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err = &errortypes.RequestError{
+			errors.Newf("support: Bad status %d from support endpoint",
+				res.StatusCode),
+		}
+		return
+	}
+
+	currentLock.Lock()
+	s.Sent += len(records)
+	currentLock.Unlock()
+
+	return
+}
+
+func (s *Session) sender() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("support: Panic")
+			panic(panc)
+		}
+	}()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	expire := time.NewTimer(time.Until(s.Expires))
+	defer expire.Stop()
+
+	batch := make([]*Record, 0, batchSize)
+	failures := 0
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+
+		err := s.send(batch)
+		batch = batch[:0]
+		if err != nil {
+			failures += 1
+			if failures >= maxFailures {
+				logrus.WithFields(logrus.Fields{
+					"session_id": s.Id,
+					"error":      err,
+				}).Error("support: Support endpoint unreachable, " +
+					"ending session")
+				return false
+			}
+			return true
+		}
+		failures = 0
+
+		return true
+	}
+
+	for {
+		select {
+		case rec := <-s.queue:
+			batch = append(batch, rec)
+			if len(batch) >= batchSize && !flush() {
+				end(s)
+				return
+			}
+			break
+		case <-ticker.C:
+			if !flush() {
+				end(s)
+				return
+			}
+			break
+		case <-expire.C:
+			flush()
+			end(s)
+			return
+		case <-s.stop:
+			flush()
+			return
+		}
+	}
+}
+
+func (s *Session) events() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("support: Panic")
+			panic(panc)
+		}
+	}()
+
+	listener := event.NewListener()
+	stream := listener.Listen()
+	defer listener.Close()
+
+	for {
+		select {
+		case evt := <-stream:
+			// Full profile updates are sent frequently and include
+			// the profile configuration
+			if evt.Type == "update" || evt.Type == "output" {
+				continue
+			}
+
+			s.push(&Record{
+				Kind:      KindEvent,
+				Timestamp: time.Now(),
+				Type:      evt.Type,
+				Data:      evt.Message,
+			})
+			break
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func publish(s *Session, active bool) {
+	evt := &event.Event{
+		Type: "support_session",
+		Data: &SessionEvent{
+			Id:      s.Id,
+			Url:     s.Url,
+			Active:  active,
+			Expires: s.Expires,
+		},
+	}
+	evt.Init()
+}
+
+func end(s *Session) {
+	currentLock.Lock()
+	if current == s {
+		current = nil
+	}
+	currentLock.Unlock()
+
+	s.once.Do(func() {
+		close(s.stop)
+
+		currentLock.Lock()
+		sent := s.Sent
+		dropped := s.Dropped
+		currentLock.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"session_id": s.Id,
+			"sent":       sent,
+			"dropped":    dropped,
+		}).Info("support: Support session ended")
+
+		publish(s, false)
+	})
+}
+
+func parseUrl(u string) (parsed string, err error) {
+	endpoint, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		err = &errortypes.ParseError{
+			errors.New("support: Support url must be a https url"),
+		}
+		return
+	}
+
+	parsed = endpoint.String()
+
+	return
+}
+
+func Start(u, token string, duration time.Duration) (
+	sess *Session, err error) {
+
+	if config.Config.DisableSupport {
+		err = &errortypes.PolicyError{
+			errors.New("support: Support sessions disabled by policy"),
+		}
+		return
+	}
+
+	endpoint, err := parseUrl(u)
+	if err != nil {
+		return
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		err = &errortypes.ParseError{
+			errors.New("support: Support token required"),
+		}
+		return
+	}
+
+	if duration <= 0 {
+		duration = DefaultDuration
+	}
+	if duration > MaxDuration {
+		err = &errortypes.ParseError{
+			errors.Newf("support: Session duration exceeds %s",
+				MaxDuration),
+		}
+		return
+	}
+
+	Stop()
+
+	start := time.Now()
+	s := &Session{
+		Id:      utils.Uuid(),
+		Url:     endpoint,
+		Start:   start,
+		Expires: start.Add(duration),
+		token:   token,
+		queue:   make(chan *Record, queueSize),
+		stop:    make(chan bool),
+	}
+
+	currentLock.Lock()
+	current = s
+	currentLock.Unlock()
+
+	go s.sender()
+	go s.events()
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": s.Id,
+		"url":        s.Url,
+		"expires":    s.Expires,
+	}).Info("support: Support session started")
+
+	publish(s, true)
+
+	sess = s.copy()
+
+	return
+}
+
+func Stop() {
+	currentLock.Lock()
+	s := current
+	currentLock.Unlock()
+
+	if s != nil {
+		end(s)
+	}
+}
+
+// Active session, nil when no session is running
+func Get() (sess *Session) {
+	currentLock.Lock()
+	s := current
+	currentLock.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	sess = s.copy()
+
+	return
+}
+
+func Active() bool {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	return current != nil
+}
+
+// Queue a log entry for the active session
+func PushLog(timestamp time.Time, entry interface{}) {
+	currentLock.Lock()
+	s := current
+	currentLock.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	s.push(&Record{
+		Kind:      KindLog,
+		Timestamp: timestamp,
+		Data:      entry,
+	})
+}
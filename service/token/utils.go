@@ -50,3 +50,11 @@ func Update(profile, pubKey, pubBoxKey string, ttl int) (
 func Clear(profile string) {
 	delete(store, profile)
 }
+
+func Set(tokn *Token) {
+	if tokn == nil || tokn.Profile == "" {
+		return
+	}
+
+	store[tokn.Profile] = tokn
+}
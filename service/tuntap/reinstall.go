@@ -0,0 +1,371 @@
+package tuntap
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	StageRemoveAdapters = "remove_adapters"
+	StageRemoveDriver   = "remove_driver"
+	StageInstallDriver  = "install_driver"
+	StageCreateAdapters = "create_adapters"
+	StageConfigure      = "configure"
+
+	driverInf = "oemvista.inf"
+)
+
+var (
+	reinstallStages = []string{
+		StageRemoveAdapters,
+		StageRemoveDriver,
+		StageInstallDriver,
+		StageCreateAdapters,
+		StageConfigure,
+	}
+	reinstalling  = false
+	reinstallLock = sync.Mutex{}
+)
+
+// Progress of a driver reinstall sent to the client with the
+// tuntap_reinstall, tuntap_reinstalled and tuntap_reinstall_error events
+type ReinstallProgress struct {
+	Stage string `json:"stage"`
+	Step  int    `json:"step"`
+	Total int    `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+func (p *ReinstallProgress) MessageParams() message.Params {
+	return message.Params{
+		"stage": p.Stage,
+		"step":  p.Step,
+		"total": p.Total,
+	}
+}
+
+func (p *ReinstallProgress) publish(typ string) {
+	evt := &event.Event{
+		Type: typ,
+		Data: p,
+	}
+	evt.Init()
+}
+
+func getPnputil() (pth string, err error) {
+	systemDir, err := platform.SystemDirectory()
+	if err != nil {
+		return
+	}
+
+	pth = path.Join(systemDir, "pnputil.exe")
+	return
+}
+
+type pnputilDriver struct {
+	DriverName   string `xml:"DriverName,attr"`
+	OriginalName string `xml:"OriginalName"`
+}
+
+type pnputilDrivers struct {
+	Drivers []*pnputilDriver `xml:"Driver"`
+}
+
+type windowsDriver struct {
+	Driver           string `json:"Driver"`
+	OriginalFileName string `json:"OriginalFileName"`
+}
+
+// Published names from the xml output of pnputil, available since
+// Windows 11 22H2
+func driverPackagesXml(pnputil string) (names []string, err error) {
+	output, err := utils.ExecOutput(
+		pnputil,
+		"/enum-drivers",
+		"/format", "xml",
+	)
+	if err != nil {
+		return
+	}
+
+	drivers := &pnputilDrivers{}
+	err = xml.Unmarshal([]byte(output), drivers)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "tuntap: Failed to parse pnputil output"),
+		}
+		return
+	}
+
+	names = []string{}
+	for _, driver := range drivers.Drivers {
+		if driver.DriverName != "" &&
+			strings.EqualFold(driver.OriginalName, driverInf) {
+
+			names = append(names, driver.DriverName)
+		}
+	}
+
+	return
+}
+
+// Published names from the driver store with the DISM module, used with
+// earlier versions of pnputil
+func driverPackagesDism() (names []string, err error) {
+	output, err := utils.ExecOutput(
+		"powershell.exe",
+		"-NoProfile",
+		"-Command",
+		"@(Get-WindowsDriver -Online | "+
+			"Select-Object Driver,OriginalFileName) | ConvertTo-Json",
+	)
+	if err != nil {
+		return
+	}
+
+	drivers := []*windowsDriver{}
+	err = json.Unmarshal([]byte(output), &drivers)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "tuntap: Failed to parse driver store"),
+		}
+		return
+	}
+
+	names = []string{}
+	for _, driver := range drivers {
+		if driver.Driver != "" && strings.EqualFold(
+			filepath.Base(driver.OriginalFileName), driverInf) {
+
+			names = append(names, driver.Driver)
+		}
+	}
+
+	return
+}
+
+// Published names of the installed tap driver packages, the text output
+// of pnputil is localized and not parsed
+func driverPackages(pnputil string) (names []string, err error) {
+	names, err = driverPackagesXml(pnputil)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Info("tuntap: Driver xml listing unavailable, using driver store")
+
+		names, err = driverPackagesDism()
+		if err != nil {
+			return
+		}
+	}
+
+	if len(names) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.Newf("tuntap: No installed '%s' driver package found",
+				driverInf),
+		}
+		return
+	}
+
+	return
+}
+
+func removeDriver() (err error) {
+	pnputil, err := getPnputil()
+	if err != nil {
+		return
+	}
+
+	names, err := driverPackages(pnputil)
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			pnputil,
+			"/delete-driver", name,
+			"/uninstall",
+			"/force",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func installDriver() (err error) {
+	pnputil, err := getPnputil()
+	if err != nil {
+		return
+	}
+
+	infPth := filepath.Join(filepath.Dir(getToolpath()), driverInf)
+
+	exists, err := utils.ExistsFile(infPth)
+	if err != nil {
+		return
+	}
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("tuntap: Driver '%s' not found", infPth),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		pnputil,
+		"-a", infPth,
+		"-i",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func DescribeReinstall() (actions []string) {
+	actions = []string{
+		"Remove all Pritunl network adapters",
+		"Uninstall the TAP driver",
+		"Install the TAP driver",
+		"Create the Pritunl network adapters",
+		"Configure the interface metrics",
+	}
+
+	return
+}
+
+// Reinstall is only available on Windows and runs once at a time
+func CheckReinstall() (err error) {
+	if runtime.GOOS != "windows" {
+		err = &errortypes.PreconditionError{
+			errors.New("tuntap: Driver reinstall only supported on windows"),
+		}
+		return
+	}
+
+	reinstallLock.Lock()
+	defer reinstallLock.Unlock()
+
+	if reinstalling {
+		err = &errortypes.PreconditionError{
+			errors.New("tuntap: Driver reinstall already in progress"),
+		}
+		return
+	}
+
+	return
+}
+
+// Remove the adapters and driver then install the driver and recreate
+// the adapter pool, fixes adapters left unusable by a corrupted driver.
+// Profiles using the adapters must be stopped.
+func Reinstall() (err error) {
+	err = CheckReinstall()
+	if err != nil {
+		return
+	}
+
+	reinstallLock.Lock()
+	if reinstalling {
+		reinstallLock.Unlock()
+		err = &errortypes.PreconditionError{
+			errors.New("tuntap: Driver reinstall already in progress"),
+		}
+		return
+	}
+	reinstalling = true
+	reinstallLock.Unlock()
+
+	defer func() {
+		reinstallLock.Lock()
+		reinstalling = false
+		reinstallLock.Unlock()
+	}()
+
+	progress := &ReinstallProgress{
+		Total: len(reinstallStages),
+	}
+
+	defer func() {
+		if err != nil {
+			progress.Error = err.Error()
+			progress.publish("tuntap_reinstall_error")
+
+			logrus.WithFields(logrus.Fields{
+				"stage": progress.Stage,
+				"error": err,
+			}).Error("tuntap: Failed to reinstall driver")
+		} else {
+			progress.publish("tuntap_reinstalled")
+
+			logrus.Info("tuntap: Driver reinstalled")
+		}
+	}()
+
+	tapsLock.Lock()
+	if len(acquired) > 0 {
+		tapsLock.Unlock()
+		err = &errortypes.PreconditionError{
+			errors.New("tuntap: Adapters in use by profiles"),
+		}
+		return
+	}
+	size := curSize
+	taps = []string{}
+	tapsLock.Unlock()
+
+	for i, stage := range reinstallStages {
+		progress.Stage = stage
+		progress.Step = i + 1
+		progress.publish("tuntap_reinstall")
+
+		logrus.WithFields(logrus.Fields{
+			"stage": stage,
+		}).Info("tuntap: Reinstalling driver")
+
+		switch stage {
+		case StageRemoveAdapters:
+			err = Clean()
+			break
+		case StageRemoveDriver:
+			err = removeDriver()
+			break
+		case StageInstallDriver:
+			err = installDriver()
+			break
+		case StageCreateAdapters:
+			if size > 0 {
+				err = Resize(size)
+			}
+			break
+		case StageConfigure:
+			err = Configure()
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
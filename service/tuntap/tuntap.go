@@ -20,9 +20,17 @@ var (
 	curSize      = 0
 	curTotalSize = 0
 	taps         = []string{}
+	acquired     = map[string]bool{}
 	tapsLock     = sync.Mutex{}
 )
 
+type Adapter struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Managed   bool   `json:"managed"`
+	Available bool   `json:"available"`
+}
+
 func getToolpath() string {
 	pth := filepath.Join(utils.GetRootDir(), "..",
 		"tuntap_win", "tapctl.exe")
@@ -111,6 +119,100 @@ func Get() (adpaters []string, count int, err error) {
 	return
 }
 
+// List adapters created by the service, adapters that are neither in the
+// pool or acquired by a profile are orphaned
+func List() (adapters []*Adapter, err error) {
+	adapters = []*Adapter{}
+
+	if runtime.GOOS != "windows" {
+		return
+	}
+
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		getToolpath(),
+		"list",
+	)
+	if err != nil {
+		return
+	}
+
+	tapsLock.Lock()
+	defer tapsLock.Unlock()
+
+	available := map[string]bool{}
+	for _, tap := range taps {
+		available[tap] = true
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		lines := strings.Fields(line)
+		if len(lines) < 2 {
+			continue
+		}
+
+		if !strings.Contains(strings.ToLower(line), "pritunl") {
+			continue
+		}
+
+		name := strings.TrimSpace(
+			strings.TrimSpace(line)[len(lines[0]):])
+
+		adapters = append(adapters, &Adapter{
+			Id:        lines[0],
+			Name:      name,
+			Managed:   available[name] || acquired[name],
+			Available: available[name],
+		})
+	}
+
+	return
+}
+
+// Remove orphaned adapters without restarting the service
+func Repair() (removed []*Adapter, err error) {
+	removed = []*Adapter{}
+
+	adapters, err := List()
+	if err != nil {
+		return
+	}
+
+	tapsLock.Lock()
+	defer tapsLock.Unlock()
+
+	toolpath := getToolpath()
+
+	// Pool may have changed since the adapters were listed
+	available := map[string]bool{}
+	for _, tap := range taps {
+		available[tap] = true
+	}
+
+	for _, adapter := range adapters {
+		if available[adapter.Name] || acquired[adapter.Name] {
+			continue
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"No devices",
+			},
+			toolpath,
+			"delete",
+			adapter.Id,
+		)
+		if err != nil {
+			return
+		}
+
+		curTotalSize -= 1
+		removed = append(removed, adapter)
+	}
+
+	return
+}
+
 func Clean() (err error) {
 	toolpath := getToolpath()
 
@@ -228,6 +330,7 @@ func Acquire() (tap string) {
 	}
 
 	tap, taps = taps[0], taps[1:]
+	acquired[tap] = true
 	return
 }
 
@@ -239,6 +342,7 @@ func Release(tap string) {
 		return
 	}
 
+	delete(acquired, tap)
 	taps = append(taps, tap)
 	sort.Strings(taps)
 }
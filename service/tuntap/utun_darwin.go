@@ -0,0 +1,204 @@
+package tuntap
+
+import (
+	"net"
+	"os"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	utunControl     = "com.apple.net.utun_control"
+	utunOptIfname   = 2
+	sysprotoControl = 2
+
+	siocaifaddrIn6      = 0x8080691a
+	nd6InfiniteLifetime = 0xffffffff
+)
+
+type ifAliasReq struct {
+	Name    [unix.IFNAMSIZ]byte
+	Addr    unix.RawSockaddrInet4
+	Dstaddr unix.RawSockaddrInet4
+	Mask    unix.RawSockaddrInet4
+}
+
+type in6AddrLifetime struct {
+	Expire    int64
+	Preferred int64
+	Vltime    uint32
+	Pltime    uint32
+}
+
+type in6AliasReq struct {
+	Name       [unix.IFNAMSIZ]byte
+	Addr       unix.RawSockaddrInet6
+	Dstaddr    unix.RawSockaddrInet6
+	Prefixmask unix.RawSockaddrInet6
+	Flags      uint32
+	Lifetime   in6AddrLifetime
+}
+
+// Create a utun device through the utun kernel control, the device is
+// removed when the last descriptor of the file is closed
+func OpenUtun() (file *os.File, name string, err error) {
+	fd, err := unix.Socket(unix.AF_SYSTEM, unix.SOCK_DGRAM, sysprotoControl)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tuntap: Failed to open utun control socket"),
+		}
+		return
+	}
+	unix.CloseOnExec(fd)
+
+	defer func() {
+		if err != nil {
+			_ = unix.Close(fd)
+		}
+	}()
+
+	info := &unix.CtlInfo{}
+	copy(info.Name[:], utunControl)
+
+	err = unix.IoctlCtlInfo(fd, info)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tuntap: Failed to get utun control info"),
+		}
+		return
+	}
+
+	// Unit zero allocates the next free utun device
+	err = unix.Connect(fd, &unix.SockaddrCtl{
+		ID:   info.Id,
+		Unit: 0,
+	})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tuntap: Failed to create utun device"),
+		}
+		return
+	}
+
+	name, err = unix.GetsockoptString(fd, sysprotoControl, utunOptIfname)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "tuntap: Failed to get utun device name"),
+		}
+		return
+	}
+
+	file = os.NewFile(uintptr(fd), name)
+
+	return
+}
+
+func ifaceName(iface string) (name [unix.IFNAMSIZ]byte) {
+	copy(name[:], iface)
+	return
+}
+
+// Add an address to the utun device, IPv4 addresses are point to point
+// addresses with the tunnel address as the destination
+func SetUtunAddress(iface string, ip net.IP, network *net.IPNet) (
+	err error) {
+
+	if ip4 := ip.To4(); ip4 != nil {
+		req := &ifAliasReq{
+			Name: ifaceName(iface),
+		}
+
+		for _, addr := range []*unix.RawSockaddrInet4{
+			&req.Addr,
+			&req.Dstaddr,
+		} {
+			addr.Len = unix.SizeofSockaddrInet4
+			addr.Family = unix.AF_INET
+			copy(addr.Addr[:], ip4)
+		}
+
+		req.Mask.Len = unix.SizeofSockaddrInet4
+		req.Mask.Family = unix.AF_INET
+		copy(req.Mask.Addr[:], net.IP(network.Mask).To4())
+
+		err = ifaceIoctl(unix.AF_INET, unix.SIOCAIFADDR,
+			unsafe.Pointer(req))
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	req := &in6AliasReq{
+		Name: ifaceName(iface),
+		Lifetime: in6AddrLifetime{
+			Vltime: nd6InfiniteLifetime,
+			Pltime: nd6InfiniteLifetime,
+		},
+	}
+
+	req.Addr.Len = unix.SizeofSockaddrInet6
+	req.Addr.Family = unix.AF_INET6
+	copy(req.Addr.Addr[:], ip.To16())
+
+	req.Prefixmask.Len = unix.SizeofSockaddrInet6
+	req.Prefixmask.Family = unix.AF_INET6
+	copy(req.Prefixmask.Addr[:], network.Mask)
+
+	err = ifaceIoctl(unix.AF_INET6, siocaifaddrIn6, unsafe.Pointer(req))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func SetUtunMtu(iface string, mtu int) (err error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tuntap: Failed to open interface socket"),
+		}
+		return
+	}
+	defer unix.Close(fd)
+
+	err = unix.IoctlSetIfreqMTU(fd, &unix.IfreqMTU{
+		Name: ifaceName(iface),
+		MTU:  int32(mtu),
+	})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tuntap: Failed to set utun mtu"),
+		}
+		return
+	}
+
+	return
+}
+
+func ifaceIoctl(family int, req uint, arg unsafe.Pointer) (err error) {
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "tuntap: Failed to open interface socket"),
+		}
+		return
+	}
+	defer unix.Close(fd)
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req),
+		uintptr(arg))
+	if errno != 0 {
+		err = &errortypes.WriteError{
+			errors.Wrap(errno, "tuntap: Failed to add utun address"),
+		}
+		return
+	}
+
+	return
+}
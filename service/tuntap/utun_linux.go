@@ -0,0 +1,32 @@
+package tuntap
+
+import (
+	"net"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func OpenUtun() (file *os.File, name string, err error) {
+	err = &errortypes.UnknownError{
+		errors.New("tuntap: Utun devices not supported on this platform"),
+	}
+	return
+}
+
+func SetUtunAddress(iface string, ip net.IP, network *net.IPNet) (
+	err error) {
+
+	err = &errortypes.UnknownError{
+		errors.New("tuntap: Utun devices not supported on this platform"),
+	}
+	return
+}
+
+func SetUtunMtu(iface string, mtu int) (err error) {
+	err = &errortypes.UnknownError{
+		errors.New("tuntap: Utun devices not supported on this platform"),
+	}
+	return
+}
@@ -0,0 +1,705 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+
+	TargetService = "service"
+	TargetCli     = "cli"
+
+	maxManifestSize = 1 << 20
+	maxBinarySize   = 256 << 20
+
+	maxUpdateStarts    = 3
+	updateHealthyDelay = 2 * time.Minute
+)
+
+var autoLock = sync.Mutex{}
+
+// Release manifest of a channel, the manifest is signed with a detached
+// ed25519 signature and the files are verified with the manifest hashes.
+// The platform and channel are part of the signed payload so a manifest
+// cannot be served for another platform or channel.
+type Manifest struct {
+	Version string          `json:"version"`
+	Os      string          `json:"os"`
+	Arch    string          `json:"arch"`
+	Channel string          `json:"channel"`
+	Files   []*ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Target string `json:"target"`
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256"`
+}
+
+func GetChannel() string {
+	switch config.Config.UpdateChannel {
+	case ChannelBeta:
+		return ChannelBeta
+	default:
+		return ChannelStable
+	}
+}
+
+func ValidChannel(channel string) bool {
+	return channel == "" || channel == ChannelStable || channel == ChannelBeta
+}
+
+// Auto update requires the channel url and pinned public key to be set in
+// the configuration
+func AutoEnabled() bool {
+	return config.Config.AutoUpdate && config.Config.UpdateUrl != "" &&
+		config.Config.UpdatePublicKey != "" && !constants.Development
+}
+
+func manifestUrl() (u string, err error) {
+	base, err := url.Parse(config.Config.UpdateUrl)
+	if err != nil || base.Scheme != "https" || base.Host == "" {
+		err = &errortypes.ParseError{
+			errors.New("update: Update url must be a https url"),
+		}
+		return
+	}
+
+	u = strings.TrimRight(base.String(), "/") + fmt.Sprintf(
+		"/%s/%s-%s.json", GetChannel(), runtime.GOOS, runtime.GOARCH)
+
+	return
+}
+
+func publicKey() (key ed25519.PublicKey, err error) {
+	keyByt, err := base64.StdEncoding.DecodeString(
+		strings.TrimSpace(config.Config.UpdatePublicKey))
+	if err != nil || len(keyByt) != ed25519.PublicKeySize {
+		err = &errortypes.ParseError{
+			errors.New("update: Invalid update public key"),
+		}
+		return
+	}
+
+	key = ed25519.PublicKey(keyByt)
+
+	return
+}
+
+// Signatures are accepted as raw bytes or base64 encoded
+func decodeSignature(data []byte) (sig []byte, err error) {
+	if len(data) == ed25519.SignatureSize {
+		sig = data
+		return
+	}
+
+	sig, err = base64.StdEncoding.DecodeString(
+		strings.TrimSpace(string(data)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		err = &errortypes.ParseError{
+			errors.New("update: Invalid manifest signature"),
+		}
+		return
+	}
+
+	return
+}
+
+func fetch(u string, limit int64) (data []byte, err error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "update: Update request error"),
+		}
+		return
+	}
+
+	req.Header.Set("User-Agent", "pritunl-client")
+
+	res, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "update: Update request error"),
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		err = utils.LogRequestError(res, "")
+		return
+	}
+
+	data, err = ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "update: Failed to read response body"),
+		}
+		return
+	}
+
+	if int64(len(data)) > limit {
+		err = &errortypes.RequestError{
+			errors.New("update: Response body too large"),
+		}
+		return
+	}
+
+	return
+}
+
+func FetchManifest() (manifest *Manifest, err error) {
+	key, err := publicKey()
+	if err != nil {
+		return
+	}
+
+	u, err := manifestUrl()
+	if err != nil {
+		return
+	}
+
+	data, err := fetch(u, maxManifestSize)
+	if err != nil {
+		return
+	}
+
+	sigData, err := fetch(u+".sig", maxManifestSize)
+	if err != nil {
+		return
+	}
+
+	sig, err := decodeSignature(sigData)
+	if err != nil {
+		return
+	}
+
+	if !ed25519.Verify(key, data, sig) {
+		err = &errortypes.ParseError{
+			errors.New("update: Manifest signature verification failed"),
+		}
+		return
+	}
+
+	manifest = &Manifest{}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		manifest = nil
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "update: Failed to parse manifest"),
+		}
+		return
+	}
+
+	if manifest.Os != runtime.GOOS || manifest.Arch != runtime.GOARCH ||
+		manifest.Channel != GetChannel() {
+
+		err = &errortypes.ParseError{
+			errors.Newf("update: Manifest for '%s-%s' on channel '%s' "+
+				"does not match '%s-%s' on channel '%s'",
+				manifest.Os, manifest.Arch, manifest.Channel,
+				runtime.GOOS, runtime.GOARCH, GetChannel()),
+		}
+		manifest = nil
+		return
+	}
+
+	return
+}
+
+func parseVersion(version string) (parts []int, ok bool) {
+	parts = []int{}
+
+	for _, part := range strings.Split(strings.TrimPrefix(
+		version, "v"), ".") {
+
+		num, err := strconv.Atoi(part)
+		if err != nil {
+			return
+		}
+		parts = append(parts, num)
+	}
+
+	ok = len(parts) > 0
+	return
+}
+
+func newerVersion(version, current string) bool {
+	verParts, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+
+	curParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for i := 0; i < len(verParts) || i < len(curParts); i++ {
+		ver := 0
+		if i < len(verParts) {
+			ver = verParts[i]
+		}
+		cur := 0
+		if i < len(curParts) {
+			cur = curParts[i]
+		}
+
+		if ver != cur {
+			return ver > cur
+		}
+	}
+
+	return false
+}
+
+// The cli is installed in the same directory as the service on every
+// platform
+func targetPath(target string) (pth string, err error) {
+	exePth, err := os.Executable()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "update: Failed to get executable path"),
+		}
+		return
+	}
+
+	switch target {
+	case TargetService:
+		pth = exePth
+		break
+	case TargetCli:
+		name := "pritunl-client"
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		pth = filepath.Join(filepath.Dir(exePth), name)
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("update: Unknown update target '%s'", target),
+		}
+		return
+	}
+
+	return
+}
+
+// Download to a staging file in the directory of the binary so the swap
+// is a rename on the same filesystem
+func download(file *ManifestFile, pth string) (err error) {
+	u, err := url.Parse(file.Url)
+	if err != nil || u.Scheme != "https" {
+		err = &errortypes.ParseError{
+			errors.New("update: Update file url must be a https url"),
+		}
+		return
+	}
+
+	expected, err := hex.DecodeString(file.Sha256)
+	if err != nil || len(expected) != sha256.Size {
+		err = &errortypes.ParseError{
+			errors.New("update: Invalid update file hash"),
+		}
+		return
+	}
+
+	data, err := fetch(u.String(), maxBinarySize)
+	if err != nil {
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hex.EncodeToString(expected) {
+		err = &errortypes.ParseError{
+			errors.Newf("update: Hash mismatch for update target '%s'",
+				file.Target),
+		}
+		return
+	}
+
+	newPth := pth + ".new"
+	_ = os.Remove(newPth)
+
+	newFile, err := os.OpenFile(newPth, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "update: Failed to create update file"),
+		}
+		return
+	}
+
+	_, err = newFile.Write(data)
+	if err == nil {
+		err = newFile.Sync()
+	}
+	closeErr := newFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(newPth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "update: Failed to write update file"),
+		}
+		return
+	}
+
+	return
+}
+
+// Replace the binary with the staged file keeping the previous binary as a
+// backup. Windows cannot replace a running executable but allows it to be
+// renamed.
+func swap(pth string) (err error) {
+	newPth := pth + ".new"
+	oldPth := pth + ".old"
+
+	_ = os.Remove(oldPth)
+
+	switch runtime.GOOS {
+	case "windows":
+		err = os.Rename(pth, oldPth)
+		if err != nil && !os.IsNotExist(err) {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "update: Failed to backup binary"),
+			}
+			return
+		}
+		break
+	default:
+		err = os.Link(pth, oldPth)
+		if err != nil && !os.IsNotExist(err) {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "update: Failed to backup binary"),
+			}
+			return
+		}
+		break
+	}
+
+	err = os.Rename(newPth, pth)
+	if err != nil {
+		_ = os.Rename(oldPth, pth)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "update: Failed to replace binary"),
+		}
+		return
+	}
+
+	return
+}
+
+func restore(pth string) {
+	err := os.Rename(pth+".old", pth)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  pth,
+			"error": err,
+		}).Error("update: Failed to restore binary")
+	}
+}
+
+// Update installed by a previous service binary that has not been
+// confirmed, the backups are kept until the updated binary is healthy
+type pendingUpdate struct {
+	Version string `json:"version"`
+	Starts  int    `json:"starts"`
+}
+
+func pendingPath() (pth string, err error) {
+	pth, err = targetPath(TargetService)
+	if err != nil {
+		return
+	}
+	pth += ".update"
+
+	return
+}
+
+func getPending() (pending *pendingUpdate, err error) {
+	pth, err := pendingPath()
+	if err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "update: Failed to read pending update"),
+		}
+		return
+	}
+
+	pending = &pendingUpdate{}
+	err = json.Unmarshal(data, pending)
+	if err != nil {
+		pending = nil
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "update: Failed to parse pending update"),
+		}
+		return
+	}
+
+	return
+}
+
+func setPending(pending *pendingUpdate) (err error) {
+	pth, err := pendingPath()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "update: Failed to marshal pending update"),
+		}
+		return
+	}
+
+	err = utils.CreateWrite(pth, string(data), 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearPending() {
+	pth, err := pendingPath()
+	if err != nil {
+		return
+	}
+
+	_ = os.Remove(pth)
+}
+
+// Remove staged and backup binaries left by a previous update
+func cleanBackups() {
+	for _, target := range []string{TargetService, TargetCli} {
+		pth, err := targetPath(target)
+		if err != nil {
+			continue
+		}
+
+		_ = os.Remove(pth + ".new")
+		_ = os.Remove(pth + ".old")
+	}
+}
+
+// Move the updated binaries aside and restore the backups, the running
+// binary can be renamed on every platform
+func rollback() {
+	for _, target := range []string{TargetService, TargetCli} {
+		pth, err := targetPath(target)
+		if err != nil {
+			continue
+		}
+
+		exists, _ := utils.Exists(pth + ".old")
+		if !exists {
+			continue
+		}
+
+		_ = os.Remove(pth + ".new")
+		err = os.Rename(pth, pth+".new")
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":  pth,
+				"error": err,
+			}).Error("update: Failed to move updated binary")
+			continue
+		}
+
+		restore(pth)
+	}
+
+	clearPending()
+}
+
+// Check the update installed by a previous service binary. The backups are
+// removed once the updated binary has run for the healthy delay, an update
+// that does not match the release version or fails to stay running is
+// rolled back. Returns true when the service must be restarted to run the
+// restored binaries.
+func CheckBackups() (restart bool) {
+	pending, err := getPending()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("update: Failed to check pending update")
+		return
+	}
+
+	if pending == nil {
+		cleanBackups()
+		return
+	}
+
+	pending.Starts += 1
+	if pending.Version != constants.Version ||
+		pending.Starts > maxUpdateStarts {
+
+		logrus.WithFields(logrus.Fields{
+			"version":         pending.Version,
+			"current_version": constants.Version,
+			"starts":          pending.Starts,
+		}).Error("update: Service update unhealthy, restoring backup")
+
+		rollback()
+		restart = true
+		return
+	}
+
+	err = setPending(pending)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("update: Failed to store pending update")
+	}
+
+	go func() {
+		time.Sleep(updateHealthyDelay)
+
+		autoLock.Lock()
+		defer autoLock.Unlock()
+
+		clearPending()
+		cleanBackups()
+
+		logrus.WithFields(logrus.Fields{
+			"version": pending.Version,
+		}).Info("update: Service update healthy, removed backup")
+	}()
+
+	return
+}
+
+// Download and install the binaries of a newer release from the update
+// channel, the service must be restarted to run the new version
+func AutoUpdate() (version string, err error) {
+	autoLock.Lock()
+	defer autoLock.Unlock()
+
+	if !AutoEnabled() {
+		return
+	}
+
+	// Backups of an unconfirmed update are not replaced
+	pending, err := getPending()
+	if err != nil || pending != nil {
+		return
+	}
+
+	manifest, err := FetchManifest()
+	if err != nil {
+		return
+	}
+
+	if !newerVersion(manifest.Version, constants.Version) {
+		return
+	}
+
+	hasService := false
+	pths := []string{}
+	for _, file := range manifest.Files {
+		pth, e := targetPath(file.Target)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"version": manifest.Version,
+				"target":  file.Target,
+			}).Warn("update: Ignoring unknown update target")
+			continue
+		}
+
+		if file.Target == TargetService {
+			hasService = true
+		}
+
+		err = download(file, pth)
+		if err != nil {
+			for _, stagedPth := range pths {
+				_ = os.Remove(stagedPth + ".new")
+			}
+			return
+		}
+
+		pths = append(pths, pth)
+	}
+
+	if !hasService {
+		for _, stagedPth := range pths {
+			_ = os.Remove(stagedPth + ".new")
+		}
+		err = &errortypes.ParseError{
+			errors.Newf("update: Release '%s' missing service binary",
+				manifest.Version),
+		}
+		return
+	}
+
+	for i, pth := range pths {
+		err = swap(pth)
+		if err != nil {
+			for _, swappedPth := range pths[:i] {
+				restore(swappedPth)
+			}
+			for _, stagedPth := range pths[i:] {
+				_ = os.Remove(stagedPth + ".new")
+			}
+			return
+		}
+	}
+
+	version = manifest.Version
+
+	err = setPending(&pendingUpdate{
+		Version: version,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"version": version,
+			"error":   err,
+		}).Error("update: Failed to store pending update")
+		err = nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"channel":         GetChannel(),
+		"version":         version,
+		"current_version": constants.Version,
+	}).Info("update: Installed service update")
+
+	return
+}
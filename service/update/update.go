@@ -10,6 +10,7 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-client-electron/service/constants"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 )
 
@@ -19,6 +20,7 @@ var (
 	lastCheck time.Time
 	client    = &http.Client{
 		Transport: &http.Transport{
+			Proxy:               proxy.Func,
 			TLSHandshakeTimeout: 30 * time.Second,
 			TLSClientConfig: &tls.Config{
 				MinVersion: tls.VersionTLS12,
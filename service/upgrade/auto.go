@@ -0,0 +1,125 @@
+package upgrade
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/container"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/update"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	autoInterval      = 6 * time.Hour
+	autoRetryInterval = 5 * time.Minute
+)
+
+var pendingVersion = ""
+
+// Run the installed update, the process image is replaced when supported
+// so connected profiles are kept. Otherwise the service is restarted by
+// the service manager once no profiles are connected.
+func restartUpdate() (restarted bool, err error) {
+	if Supported {
+		err = Upgrade()
+		if err == nil {
+			restarted = true
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("upgrade: Failed to exec update, restarting service")
+		err = nil
+	}
+
+	if profile.GetActive() {
+		return
+	}
+
+	err = restartService()
+	if err != nil {
+		return
+	}
+	restarted = true
+
+	return
+}
+
+func autoUpdate() {
+	if pendingVersion == "" {
+		version, err := update.AutoUpdate()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("upgrade: Failed to auto update service")
+			return
+		}
+		pendingVersion = version
+	}
+
+	if pendingVersion == "" {
+		return
+	}
+
+	restarted, err := restartUpdate()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"version": pendingVersion,
+			"error":   err,
+		}).Error("upgrade: Failed to restart updated service")
+		return
+	}
+
+	if !restarted {
+		logrus.WithFields(logrus.Fields{
+			"version": pendingVersion,
+		}).Info("upgrade: Update restart waiting for profiles to disconnect")
+	}
+}
+
+func autoWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("upgrade: Panic")
+			panic(panc)
+		}
+	}()
+
+	time.Sleep(autoRetryInterval)
+
+	for {
+		if update.AutoEnabled() {
+			autoUpdate()
+		}
+
+		if pendingVersion != "" {
+			time.Sleep(autoRetryInterval)
+		} else {
+			time.Sleep(autoInterval)
+		}
+	}
+}
+
+// Binaries in a container are managed by the image
+func StartAutoUpdate() {
+	if update.CheckBackups() {
+		err := restartService()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("upgrade: Failed to restart restored service")
+		}
+	}
+
+	if container.Detected() {
+		return
+	}
+
+	go autoWatch()
+}
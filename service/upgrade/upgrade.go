@@ -0,0 +1,392 @@
+// Upgrade replaces the service process image with the installed service
+// binary. The api listener and connected profiles are handed to the new
+// image so tunnels stay up across client updates.
+package upgrade
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/constants"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/stats"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	listenerEnv = "PRITUNL_UPGRADE_FD"
+	stateEnv    = "PRITUNL_UPGRADE_STATE"
+	keyEnv      = "PRITUNL_UPGRADE_KEY"
+)
+
+var (
+	listener    net.Listener
+	upgradeLock = sync.Mutex{}
+)
+
+type State struct {
+	Version string           `json:"version"`
+	Pid     int              `json:"pid"`
+	Handoff *profile.Handoff `json:"handoff"`
+}
+
+// Service was started by an upgrade of a previous service image
+func Upgrading() bool {
+	return os.Getenv(stateEnv) != ""
+}
+
+// Open the api unix socket or inherit it from the previous service image
+func Listen(pth string) (lstnr net.Listener, err error) {
+	fdStr := os.Getenv(listenerEnv)
+	_ = os.Unsetenv(listenerEnv)
+
+	if fdStr != "" {
+		fd, e := strconv.Atoi(fdStr)
+		if e == nil {
+			file := os.NewFile(uintptr(fd), pth)
+			lstnr, e = net.FileListener(file)
+			_ = file.Close()
+			if e == nil {
+				logrus.WithFields(logrus.Fields{
+					"path": pth,
+				}).Info("upgrade: Inherited unix socket")
+
+				listener = lstnr
+				return
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"error": e,
+		}).Error("upgrade: Failed to inherit unix socket")
+	}
+
+	_ = os.Remove(pth)
+
+	lstnr, err = net.Listen("unix", pth)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "upgrade: Failed to create unix socket"),
+		}
+		return
+	}
+
+	err = os.Chmod(pth, 0777)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "upgrade: Failed to chmod unix socket"),
+		}
+		return
+	}
+
+	listener = lstnr
+
+	return
+}
+
+// The state contains profile keys and passwords, it is encrypted with a
+// key that is only passed to the new image in the environment
+func stateCipher(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to init state cipher"),
+		}
+		return
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to init state cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+func encryptState(key, data []byte) (ciphertext []byte, err error) {
+	aead, err := stateCipher(key)
+	if err != nil {
+		return
+	}
+
+	nonce, err := utils.RandBytes(aead.NonceSize())
+	if err != nil {
+		return
+	}
+
+	ciphertext = aead.Seal(nonce, nonce, data, nil)
+
+	return
+}
+
+func decryptState(key, ciphertext []byte) (data []byte, err error) {
+	aead, err := stateCipher(key)
+	if err != nil {
+		return
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("upgrade: Upgrade state truncated"),
+		}
+		return
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+	data, err = aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to decrypt upgrade state"),
+		}
+		return
+	}
+
+	return
+}
+
+// Adopt the profiles handed off by the previous service image
+func Restore() (err error) {
+	pth := os.Getenv(stateEnv)
+	keyHex := os.Getenv(keyEnv)
+	_ = os.Unsetenv(stateEnv)
+	_ = os.Unsetenv(keyEnv)
+	if pth == "" {
+		return
+	}
+
+	ciphertext, err := ioutil.ReadFile(pth)
+	_ = utils.Shred(pth)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "upgrade: Failed to read upgrade state"),
+		}
+		return
+	}
+
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to parse upgrade state key"),
+		}
+		return
+	}
+
+	data, err := decryptState(key, ciphertext)
+	if err != nil {
+		return
+	}
+
+	state := &State{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to parse upgrade state"),
+		}
+		return
+	}
+
+	if state.Handoff == nil {
+		err = &errortypes.ParseError{
+			errors.New("upgrade: Upgrade state missing handoff"),
+		}
+		return
+	}
+
+	if state.Pid != os.Getpid() {
+		err = &errortypes.ParseError{
+			errors.Newf("upgrade: Upgrade state from other process %d",
+				state.Pid),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"previous_version": state.Version,
+		"version":          constants.Version,
+		"profiles":         len(state.Handoff.Profiles),
+	}).Info("upgrade: Service upgraded")
+
+	closeOnExec(state.Handoff.OutputFds())
+	profile.Adopt(state.Handoff)
+
+	return
+}
+
+func statePath() (pth string, err error) {
+	rootDir, err := utils.GetTempDir()
+	if err != nil {
+		return
+	}
+
+	pth = filepath.Join(rootDir, "upgrade.json")
+
+	return
+}
+
+func upgradeEnv(fd uintptr, statePth string, key []byte) (env []string) {
+	env = []string{}
+
+	for _, val := range os.Environ() {
+		if strings.HasPrefix(val, listenerEnv+"=") ||
+			strings.HasPrefix(val, stateEnv+"=") ||
+			strings.HasPrefix(val, keyEnv+"=") {
+
+			continue
+		}
+		env = append(env, val)
+	}
+
+	env = append(env,
+		listenerEnv+"="+strconv.Itoa(int(fd)),
+		stateEnv+"="+statePth,
+		keyEnv+"="+hex.EncodeToString(key),
+	)
+
+	return
+}
+
+// Exec the service binary with the openvpn output pipes inherited, the
+// processes are killed by a broken pipe if the pipes are closed
+func execHandoff(pth string, env []string, fds []int) (err error) {
+	err = inheritFds(fds)
+	if err == nil {
+		err = execImage(pth, env)
+	}
+	closeOnExec(fds)
+
+	return
+}
+
+// Check that an upgrade can be started
+func Check() (err error) {
+	if !Supported {
+		err = &errortypes.ExecError{
+			errors.New("upgrade: Upgrade not supported on this platform"),
+		}
+		return
+	}
+
+	if listener == nil {
+		err = &errortypes.ExecError{
+			errors.New("upgrade: Service not listening on unix socket"),
+		}
+		return
+	}
+
+	err = profile.CheckHandoff()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Exec the installed service binary in place of the running process, only
+// returns if the upgrade failed
+func Upgrade() (err error) {
+	upgradeLock.Lock()
+	defer upgradeLock.Unlock()
+
+	err = Check()
+	if err != nil {
+		return
+	}
+
+	exePth, err := os.Executable()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "upgrade: Failed to get executable path"),
+		}
+		return
+	}
+
+	exists, err := utils.Exists(exePth)
+	if err != nil {
+		return
+	}
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.Newf("upgrade: Service binary '%s' not found", exePth),
+		}
+		return
+	}
+
+	statePth, err := statePath()
+	if err != nil {
+		return
+	}
+
+	file, err := listenerFile(listener)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	stats.Flush()
+
+	hndoff, err := profile.StartHandoff()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(&State{
+		Version: constants.Version,
+		Pid:     os.Getpid(),
+		Handoff: hndoff,
+	})
+	if err != nil {
+		profile.ResumeHandoff()
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "upgrade: Failed to marshal upgrade state"),
+		}
+		return
+	}
+
+	key, err := utils.RandBytes(32)
+	if err != nil {
+		profile.ResumeHandoff()
+		return
+	}
+
+	ciphertext, err := encryptState(key, data)
+	if err != nil {
+		profile.ResumeHandoff()
+		return
+	}
+
+	_ = utils.Shred(statePth)
+	err = utils.CreateWrite(statePth, string(ciphertext), 0600)
+	if err != nil {
+		profile.ResumeHandoff()
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"path":     exePth,
+		"version":  constants.Version,
+		"profiles": len(hndoff.Profiles),
+	}).Info("upgrade: Executing service binary")
+
+	err = execHandoff(exePth, upgradeEnv(file.Fd(), statePth, key),
+		hndoff.OutputFds())
+
+	_ = utils.Shred(statePth)
+	profile.ResumeHandoff()
+
+	return
+}
@@ -0,0 +1,107 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+const Supported = true
+
+// Signal that starts an upgrade
+var Signal os.Signal = syscall.SIGUSR2
+
+// Duplicate the listener socket without close on exec
+func listenerFile(lstnr net.Listener) (file *os.File, err error) {
+	unixLstnr, ok := lstnr.(*net.UnixListener)
+	if !ok {
+		err = &errortypes.ExecError{
+			errors.New("upgrade: Listener is not a unix socket"),
+		}
+		return
+	}
+
+	file, err = unixLstnr.File()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "upgrade: Failed to get socket file"),
+		}
+		return
+	}
+
+	_, err = unix.FcntlInt(file.Fd(), unix.F_SETFD, 0)
+	if err != nil {
+		_ = file.Close()
+		file = nil
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "upgrade: Failed to clear socket close on exec"),
+		}
+		return
+	}
+
+	return
+}
+
+// Clear close on exec so the descriptors are open in the new image
+func inheritFds(fds []int) (err error) {
+	for _, fd := range fds {
+		_, err = unix.FcntlInt(uintptr(fd), unix.F_SETFD, 0)
+		if err != nil {
+			err = &errortypes.ExecError{
+				errors.Wrap(err, "upgrade: Failed to clear close on exec"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// Inherited descriptors are not passed on to other child processes
+func closeOnExec(fds []int) {
+	for _, fd := range fds {
+		unix.CloseOnExec(fd)
+	}
+}
+
+// Replace the process image, the pid and child processes are kept
+func execImage(pth string, env []string) (err error) {
+	err = syscall.Exec(pth, os.Args, env)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "upgrade: Failed to exec service binary"),
+		}
+		return
+	}
+
+	return
+}
+
+// Restart through the service manager, the restart stops this process.
+// Systemd is not waited on and launchd kills this process from the
+// kickstart command.
+func restartService() (err error) {
+	if runtime.GOOS == "darwin" {
+		err = command.Command("/bin/launchctl", "kickstart", "-k",
+			"system/com.pritunl.service").Start()
+	} else {
+		err = command.Command("systemctl", "--no-block", "restart",
+			"pritunl-client.service").Run()
+	}
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "upgrade: Failed to restart service"),
+		}
+		return
+	}
+
+	return
+}
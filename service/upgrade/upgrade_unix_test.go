@@ -0,0 +1,130 @@
+//go:build !windows
+
+package upgrade
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+)
+
+const (
+	handoffStage  = "PRITUNL_TEST_HANDOFF_STAGE"
+	handoffFd     = "PRITUNL_TEST_HANDOFF_FD"
+	handoffPid    = "PRITUNL_TEST_HANDOFF_PID"
+	handoffLines  = 40
+	handoffScript = "i=1; while [ $i -le %d ]; do echo line$i; " +
+		"i=$((i+1)); sleep 0.02; done"
+)
+
+// Exec with a child that keeps writing to the output pipe after the exec,
+// the child is killed by a broken pipe if the read end is not inherited
+func TestExecHandoffOutput(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExecHandoffOutput$")
+	cmd.Env = append(os.Environ(), handoffStage+"=exec")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("handoff failed: %s\n%s", err, output)
+	}
+}
+
+func init() {
+	switch os.Getenv(handoffStage) {
+	case "exec":
+		os.Exit(handoffExec())
+	case "adopt":
+		os.Exit(handoffAdopt())
+	}
+}
+
+func handoffExec() int {
+	stdout, stdoutWrite, err := os.Pipe()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	cmd := exec.Command("sh", "-c", fmt.Sprintf(handoffScript, handoffLines))
+	cmd.Stdout = stdoutWrite
+	err = cmd.Start()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	stdoutWrite.Close()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "line1\n" {
+		fmt.Printf("first line: %q %v\n", line, err)
+		return 1
+	}
+
+	fd := int(stdout.Fd())
+
+	hndoff := &profile.Handoff{
+		Profiles: []*profile.HandoffProfile{
+			{
+				StdoutFd: fd,
+			},
+		},
+	}
+
+	env := []string{
+		handoffStage + "=adopt",
+		handoffFd + "=" + strconv.Itoa(fd),
+		handoffPid + "=" + strconv.Itoa(cmd.Process.Pid),
+	}
+
+	err = execHandoff(os.Args[0], env, hndoff.OutputFds())
+	fmt.Println(err)
+	return 1
+}
+
+func handoffAdopt() int {
+	fd, _ := strconv.Atoi(os.Getenv(handoffFd))
+	pid, _ := strconv.Atoi(os.Getenv(handoffPid))
+
+	closeOnExec([]int{fd})
+
+	stdout := os.NewFile(uintptr(fd), "stdout")
+	if stdout == nil {
+		fmt.Println("invalid fd")
+		return 1
+	}
+
+	last := ""
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		last = scanner.Text()
+	}
+	stdout.Close()
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	state, err := proc.Wait()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if !state.Success() {
+		fmt.Printf("child exited: %s\n", state)
+		return 1
+	}
+
+	if last != fmt.Sprintf("line%d", handoffLines) {
+		fmt.Printf("last line: %q\n", last)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,62 @@
+package upgrade
+
+import (
+	"net"
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/command"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+// Windows services cannot replace the process image, the service is
+// restarted by the installer
+const Supported = false
+
+var Signal os.Signal
+
+func listenerFile(lstnr net.Listener) (file *os.File, err error) {
+	err = &errortypes.ExecError{
+		errors.New("upgrade: Socket handover not supported on windows"),
+	}
+	return
+}
+
+func inheritFds(fds []int) (err error) {
+	err = &errortypes.ExecError{
+		errors.New("upgrade: Descriptor handover not supported on windows"),
+	}
+	return
+}
+
+func closeOnExec(fds []int) {
+}
+
+func execImage(pth string, env []string) (err error) {
+	err = &errortypes.ExecError{
+		errors.New("upgrade: Exec not supported on windows"),
+	}
+	return
+}
+
+// Restart from a detached process, stopping the service ends this process
+// before the service can be started again
+func restartService() (err error) {
+	cmd := command.Command(
+		"cmd.exe", "/c",
+		"sc.exe stop pritunl & timeout /t 5 /nobreak & sc.exe start pritunl",
+	)
+	cmd.SysProcAttr.CreationFlags = windows.DETACHED_PROCESS |
+		windows.CREATE_NEW_PROCESS_GROUP
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "upgrade: Failed to restart service"),
+		}
+		return
+	}
+
+	return
+}
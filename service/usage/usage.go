@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sampleInterval    = 30 * time.Second
+	cpuThreshold      = 50.0
+	memoryThreshold   = 512 * 1024 * 1024
+	routineThreshold  = 5000
+	openFileThreshold = 1000
+)
+
+var (
+	current     = &Usage{}
+	currentLock = sync.Mutex{}
+	warned      = map[string]bool{}
+)
+
+type Usage struct {
+	Timestamp   time.Time `json:"timestamp"`
+	CpuPercent  float64   `json:"cpu_percent"`
+	CpuTime     float64   `json:"cpu_time"`
+	MemoryHeap  uint64    `json:"memory_heap"`
+	MemorySys   uint64    `json:"memory_sys"`
+	Goroutines  int       `json:"goroutines"`
+	OpenFiles   int       `json:"open_files"`
+	NumCpu      int       `json:"num_cpu"`
+	Warnings    []string  `json:"warnings"`
+	lastCpuTime time.Duration
+}
+
+func Get() (usg *Usage) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	usg = &Usage{}
+	*usg = *current
+
+	usg.Warnings = []string{}
+	usg.Warnings = append(usg.Warnings, current.Warnings...)
+
+	return
+}
+
+func sample() {
+	memStats := &runtime.MemStats{}
+	runtime.ReadMemStats(memStats)
+
+	cpuTime, err := getCpuTime()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("usage: Failed to read process cpu time")
+	}
+
+	openFiles, err := getOpenFiles()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Warn("usage: Failed to read process open files")
+	}
+
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	now := time.Now()
+	usg := &Usage{
+		Timestamp:   now,
+		CpuTime:     cpuTime.Seconds(),
+		MemoryHeap:  memStats.HeapAlloc,
+		MemorySys:   memStats.Sys,
+		Goroutines:  runtime.NumGoroutine(),
+		OpenFiles:   openFiles,
+		NumCpu:      runtime.NumCPU(),
+		Warnings:    []string{},
+		lastCpuTime: cpuTime,
+	}
+
+	if !current.Timestamp.IsZero() {
+		elapsed := now.Sub(current.Timestamp)
+		if elapsed > 0 && cpuTime >= current.lastCpuTime {
+			usg.CpuPercent = float64(cpuTime-current.lastCpuTime) /
+				float64(elapsed) * 100
+		}
+	}
+
+	usg.check("cpu", usg.CpuPercent > cpuThreshold,
+		fmt.Sprintf("CPU usage %.1f%% above %.0f%%",
+			usg.CpuPercent, cpuThreshold))
+	usg.check("memory", usg.MemorySys > memoryThreshold,
+		fmt.Sprintf("Memory usage %d MB above %d MB",
+			usg.MemorySys/1024/1024, memoryThreshold/1024/1024))
+	usg.check("goroutines", usg.Goroutines > routineThreshold,
+		fmt.Sprintf("Goroutine count %d above %d",
+			usg.Goroutines, routineThreshold))
+	usg.check("open_files", usg.OpenFiles > openFileThreshold,
+		fmt.Sprintf("Open file count %d above %d",
+			usg.OpenFiles, openFileThreshold))
+
+	current = usg
+}
+
+func (u *Usage) check(key string, exceeded bool, msg string) {
+	if !exceeded {
+		warned[key] = false
+		return
+	}
+
+	u.Warnings = append(u.Warnings, msg)
+
+	if !warned[key] {
+		warned[key] = true
+
+		logrus.WithFields(logrus.Fields{
+			"cpu_percent": u.CpuPercent,
+			"memory_sys":  u.MemorySys,
+			"goroutines":  u.Goroutines,
+			"open_files":  u.OpenFiles,
+		}).Warn("usage: " + msg)
+	}
+}
+
+func watch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("usage: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		sample()
+		time.Sleep(sampleInterval)
+	}
+}
+
+func StartWatch() {
+	go watch()
+}
@@ -0,0 +1,40 @@
+package usage
+
+import (
+	"io/ioutil"
+	"syscall"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+func getCpuTime() (cpuTime time.Duration, err error) {
+	rusage := &syscall.Rusage{}
+
+	err = syscall.Getrusage(syscall.RUSAGE_SELF, rusage)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "usage: Failed to get resource usage"),
+		}
+		return
+	}
+
+	cpuTime = time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+
+	return
+}
+
+func getOpenFiles() (count int, err error) {
+	files, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "usage: Failed to read file descriptors"),
+		}
+		return
+	}
+
+	count = len(files)
+
+	return
+}
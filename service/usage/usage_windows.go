@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32                  = windows.NewLazySystemDLL("kernel32.dll")
+	procGetProcessHandleCount = kernel32.NewProc("GetProcessHandleCount")
+)
+
+func getCpuTime() (cpuTime time.Duration, err error) {
+	handle, err := windows.GetCurrentProcess()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "usage: Failed to get current process"),
+		}
+		return
+	}
+
+	var creation, exit, kernel, user windows.Filetime
+
+	err = windows.GetProcessTimes(handle, &creation, &exit, &kernel, &user)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "usage: Failed to get process times"),
+		}
+		return
+	}
+
+	kernelTime := int64(kernel.HighDateTime)<<32 | int64(kernel.LowDateTime)
+	userTime := int64(user.HighDateTime)<<32 | int64(user.LowDateTime)
+
+	cpuTime = time.Duration((kernelTime + userTime) * 100)
+
+	return
+}
+
+func getOpenFiles() (count int, err error) {
+	handle, err := windows.GetCurrentProcess()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "usage: Failed to get current process"),
+		}
+		return
+	}
+
+	var handleCount uint32
+
+	ret, _, e := procGetProcessHandleCount.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(&handleCount)),
+	)
+	if ret == 0 {
+		err = &errortypes.ReadError{
+			errors.Wrap(e, "usage: Failed to get process handle count"),
+		}
+		return
+	}
+
+	count = int(handleCount)
+
+	return
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Check for hardware AES support, without it ChaCha20-Poly1305 is faster
+// than AES-GCM
+func HasAesAcceleration() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ
+	case "arm64":
+		if supported, ok := osAesAcceleration(); ok {
+			return supported
+		}
+		return cpu.ARM64.HasAES && cpu.ARM64.HasPMULL
+	case "arm":
+		return cpu.ARM.HasAES && cpu.ARM.HasPMULL
+	case "s390x":
+		return cpu.S390X.HasAES && cpu.S390X.HasAESGCM
+	default:
+		return false
+	}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"runtime"
+)
+
+// The cpu package cannot read the ARM64 feature registers on macOS, every
+// Apple silicon processor supports AES and PMULL
+func osAesAcceleration() (supported, ok bool) {
+	if runtime.GOARCH == "arm64" {
+		supported = true
+		ok = true
+	}
+	return
+}
@@ -0,0 +1,5 @@
+package utils
+
+func osAesAcceleration() (supported, ok bool) {
+	return
+}
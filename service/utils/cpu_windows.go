@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+const pfArmV8CryptoInstructionsAvailable = 30
+
+var (
+	kernel32                      = windows.NewLazySystemDLL("kernel32.dll")
+	procIsProcessorFeaturePresent = kernel32.NewProc(
+		"IsProcessorFeaturePresent")
+)
+
+// The cpu package cannot read the ARM64 feature registers on Windows, the
+// crypto extensions include AES and PMULL
+func osAesAcceleration() (supported, ok bool) {
+	ret, _, _ := procIsProcessorFeaturePresent.Call(
+		uintptr(pfArmV8CryptoInstructionsAvailable))
+
+	supported = ret != 0
+	ok = true
+	return
+}
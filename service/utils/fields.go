@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+)
+
+var (
+	fieldsCache = map[reflect.Type]map[string]int{}
+	fieldsLock  = sync.RWMutex{}
+)
+
+func ParseFields(input string) (fields []string) {
+	fields = []string{}
+
+	for _, field := range strings.Split(input, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+
+	return
+}
+
+func getFieldIndex(typ reflect.Type) (index map[string]int) {
+	fieldsLock.RLock()
+	index = fieldsCache[typ]
+	fieldsLock.RUnlock()
+
+	if index != nil {
+		return
+	}
+
+	index = map[string]int{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		index[name] = i
+	}
+
+	fieldsLock.Lock()
+	fieldsCache[typ] = index
+	fieldsLock.Unlock()
+
+	return
+}
+
+func SelectFields(obj interface{}, fields []string) (
+	data map[string]interface{}, err error) {
+
+	val := reflect.Indirect(reflect.ValueOf(obj))
+	if val.Kind() != reflect.Struct {
+		err = &errortypes.ParseError{
+			errors.New("utils: Field selection requires struct"),
+		}
+		return
+	}
+
+	index := getFieldIndex(val.Type())
+
+	data = map[string]interface{}{}
+	for _, field := range fields {
+		i, ok := index[field]
+		if !ok {
+			err = &errortypes.ParseError{
+				errors.Newf("utils: Unknown field '%s'", field),
+			}
+			return
+		}
+
+		data[field] = val.Field(i).Interface()
+	}
+
+	return
+}
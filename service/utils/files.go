@@ -271,3 +271,85 @@ func Copy(src, dst string) (err error) {
 
 	return
 }
+
+func CreateWriteExcl(path string, data string, perm os.FileMode) (
+	err error) {
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to remove '%s'", path),
+		}
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to create '%s'", path),
+		}
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if e != nil && err == nil {
+			err = &errortypes.WriteError{
+				errors.Wrapf(e, "utils: Failed to write '%s'", path),
+			}
+		}
+	}()
+
+	err = file.Chmod(perm)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to chmod '%s'", path),
+		}
+		return
+	}
+
+	_, err = file.WriteString(data)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to write to file '%s'", path),
+		}
+		return
+	}
+
+	return
+}
+
+func Shred(path string) (err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to open '%s'", path),
+		}
+		return
+	}
+
+	stat, err := file.Stat()
+	if err == nil && stat.Mode().IsRegular() && stat.Size() > 0 {
+		buf, e := RandBytes(int(stat.Size()))
+		if e == nil {
+			_, _ = file.WriteAt(buf, 0)
+			_ = file.Sync()
+		}
+	}
+	_ = file.Close()
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		err = &errortypes.WriteError{
+			errors.Wrapf(err, "utils: Failed to remove '%s'", path),
+		}
+		return
+	}
+	err = nil
+
+	return
+}
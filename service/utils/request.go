@@ -13,8 +13,8 @@ import (
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/render"
 	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/message"
 	"github.com/sirupsen/logrus"
 )
 
@@ -112,16 +112,26 @@ func GetStatusMessage(code int) string {
 	return fmt.Sprintf("%d %s", code, http.StatusText(code))
 }
 
-func AbortWithStatus(c *gin.Context, code int) {
-	r := render.String{
-		Format: GetStatusMessage(code),
+type ErrorData struct {
+	Error   string         `json:"error"`
+	Params  message.Params `json:"params,omitempty"`
+	Message string         `json:"message"`
+}
+
+func NewErrorData(msg *message.Message) *ErrorData {
+	return &ErrorData{
+		Error:   msg.Code,
+		Params:  msg.Params,
+		Message: msg.Message,
 	}
+}
 
-	c.Status(code)
-	r.WriteContentType(c.Writer)
-	c.Writer.WriteHeaderNow()
-	r.Render(c.Writer)
-	c.Abort()
+func AbortWithMessage(c *gin.Context, code int, msg *message.Message) {
+	c.AbortWithStatusJSON(code, NewErrorData(msg))
+}
+
+func AbortWithStatus(c *gin.Context, code int) {
+	AbortWithMessage(c, code, message.Status(code))
 }
 
 func AbortWithError(c *gin.Context, code int, err error) {
@@ -129,6 +139,13 @@ func AbortWithError(c *gin.Context, code int, err error) {
 	c.Error(err)
 }
 
+func AbortWithErrorMessage(c *gin.Context, code int, err error,
+	msg *message.Message) {
+
+	AbortWithMessage(c, code, msg)
+	c.Error(err)
+}
+
 func WriteStatus(w http.ResponseWriter, code int) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
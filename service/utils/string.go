@@ -1,13 +1,60 @@
 package utils
 
 import (
+	"net"
 	"regexp"
+	"strings"
 )
 
 var (
 	alphaNumRe = regexp.MustCompile("[^a-zA-Z0-9]+")
+	domainRe   = regexp.MustCompile("[^a-z0-9.\\-_]+")
+	hostnameRe = regexp.MustCompile(
+		"^([a-z0-9_]([a-z0-9\\-_]{0,61}[a-z0-9_])?\\.)*" +
+			"[a-z0-9_]([a-z0-9\\-_]{0,61}[a-z0-9_])?$")
 )
 
 func FilterStr(input string) string {
 	return string(alphaNumRe.ReplaceAll([]byte(input), []byte("")))
 }
+
+func FilterDomain(input string) string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	input = strings.TrimLeft(input, "~.")
+	input = strings.TrimRight(input, ".")
+	return string(domainRe.ReplaceAll([]byte(input), []byte("")))
+}
+
+// Check for a valid hostname without altering the input, used for values
+// that are passed to system commands
+func ValidDomain(input string) bool {
+	return len(input) <= 253 && hostnameRe.MatchString(input)
+}
+
+func FilterDomains(inputs []string) (domains []string) {
+	domains = []string{}
+
+	for _, input := range inputs {
+		domain := FilterDomain(input)
+		if domain == "" {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+
+	return
+}
+
+func FilterSubnets(inputs []string) (subnets []string) {
+	subnets = []string{}
+
+	for _, input := range inputs {
+		_, subnet, err := net.ParseCIDR(strings.TrimSpace(input))
+		if err != nil {
+			continue
+		}
+		subnets = append(subnets, subnet.String())
+	}
+
+	return
+}
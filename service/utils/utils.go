@@ -2,12 +2,9 @@
 package utils
 
 import (
-	"bufio"
-	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -30,8 +27,7 @@ import (
 
 var (
 	lockedInterfaces set.Set
-	networkResetLock sync.Mutex
-	macDnsLock       = sync.Mutex{}
+	MacDnsLock       = sync.Mutex{}
 )
 
 func init() {
@@ -60,59 +56,23 @@ func (intfs Interfaces) Less(i, j int) bool {
 func GetTaps() (interfaces []*Interface, err error) {
 	interfaces = []*Interface{}
 
-	cmd := command.Command("ipconfig", "/all")
-
-	output, err := cmd.CombinedOutput()
+	adapters, err := platform.GetAdapters()
 	if err != nil {
-		err = &CommandError{
-			errors.Wrap(err, "utils: Failed to exec ipconfig"),
-		}
 		return
 	}
 
-	buf := bytes.NewBuffer(output)
-	scan := bufio.NewReader(buf)
+	for _, adapter := range adapters {
+		if !strings.Contains(adapter.Description, "TAP-Windows Adapter") ||
+			adapter.HardwareAddr == "" {
 
-	intName := ""
-	intTap := false
-	intAddr := ""
-
-	for {
-		lineByte, _, e := scan.ReadLine()
-		if e != nil {
-			if e == io.EOF {
-				break
-			}
-			err = e
-			panic(err)
-			return
-		}
-		line := string(lineByte)
-
-		if line == "" {
 			continue
 		}
 
-		if strings.HasPrefix(line, "Ethernet adapter ") {
-			intName = strings.Split(line, "Ethernet adapter ")[1]
-			intName = intName[:len(intName)-1]
-			intTap = false
-			intAddr = ""
-		} else if intName != "" {
-			if strings.Contains(line, "TAP-Windows Adapter") {
-				intTap = true
-			} else if strings.Contains(line, "Physical Address") {
-				intAddr = strings.Split(line, ":")[1]
-				intAddr = strings.TrimSpace(intAddr)
-			} else if intTap && intAddr != "" {
-				intf := &Interface{
-					Id:   intAddr,
-					Name: intName,
-				}
-				interfaces = append(interfaces, intf)
-				intName = ""
-			}
+		intf := &Interface{
+			Id:   adapter.HardwareAddr,
+			Name: adapter.Name,
 		}
+		interfaces = append(interfaces, intf)
 	}
 
 	sort.Sort(Interfaces(interfaces))
@@ -142,8 +102,8 @@ func ReleaseTap(intf *Interface) {
 }
 
 func GetScutilKey(typ, key string) (val string, err error) {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	cmd := command.Command("/usr/sbin/scutil")
 	cmd.Stdin = strings.NewReader(
@@ -163,8 +123,8 @@ func GetScutilKey(typ, key string) (val string, err error) {
 }
 
 func RemoveScutilKey(typ, key string) (err error) {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	cmd := command.Command("/usr/sbin/scutil")
 	cmd.Stdin = strings.NewReader(
@@ -189,8 +149,8 @@ func SetScutilDns(connId string, addresses, domains []string) (err error) {
 		return
 	}
 
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	cmd := command.Command("/usr/sbin/scutil")
 	cmd.Stdin = strings.NewReader(
@@ -221,8 +181,8 @@ func SetScutilDns(connId string, addresses, domains []string) (err error) {
 func ClearScutilDns(connId string) (err error) {
 	logrus.Info("utils: Clearing DNS state")
 
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	cmd := command.Command("/usr/sbin/scutil")
 	cmd.Stdin = strings.NewReader(
@@ -241,9 +201,63 @@ func ClearScutilDns(connId string) (err error) {
 	return
 }
 
+func SetScutilSplitDns(connId string, addresses, domains []string) (
+	err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"domains": domains,
+	}).Info("utils: Configure split DNS")
+
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
+
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader(
+		fmt.Sprintf("open\n"+
+			"d.init\n"+
+			"d.add ServerAddresses * %s\n"+
+			"d.add SupplementalMatchDomains * %s\n"+
+			"d.add Pritunl true\n"+
+			"set State:/Network/Service/Pritunl-Split-%s/DNS\n"+
+			"quit\n",
+			strings.Join(addresses, " "), strings.Join(domains, " "),
+			connId))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &CommandError{
+			errors.Wrap(err, "utils: Failed to exec scutil"),
+		}
+		return
+	}
+
+	return
+}
+
+func ClearScutilSplitDns(connId string) (err error) {
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
+
+	cmd := command.Command("/usr/sbin/scutil")
+	cmd.Stdin = strings.NewReader(
+		fmt.Sprintf("open\n"+
+			"remove State:/Network/Service/Pritunl-Split-%s/DNS\n"+
+			"quit\n", connId))
+
+	err = cmd.Run()
+	if err != nil {
+		err = &CommandError{
+			errors.Wrap(err, "utils: Failed to exec scutil"),
+		}
+		return
+	}
+
+	return
+}
+
 func CopyScutilKey(typ, src, dst string) (err error) {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	cmd := command.Command("/usr/sbin/scutil")
 	cmd.Stdin = strings.NewReader(
@@ -269,8 +283,8 @@ type ScutilKey struct {
 }
 
 func CopyScutilMultiKey(typ, src string, dsts ...*ScutilKey) (err error) {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	stdin := fmt.Sprintf("open\nget %s:%s\n", typ, src)
 	for _, dst := range dsts {
@@ -295,8 +309,8 @@ func CopyScutilMultiKey(typ, src string, dsts ...*ScutilKey) (err error) {
 func CopyClearScutilMultiKey(typ, src string, dsts ...*ScutilKey) (
 	err error) {
 
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	stdin := fmt.Sprintf("open\nget %s:%s\n", typ, src)
 	for _, dst := range dsts {
@@ -330,6 +344,13 @@ func GetScutilService() (serviceId string, err error) {
 		}
 
 		dataSpl := strings.Split(data, "PrimaryService :")
+		if len(dataSpl) < 2 {
+			// Networks without IPv4 only have an IPv6 primary service
+			data6, e := GetScutilKey("State", "/Network/Global/IPv6")
+			if e == nil {
+				dataSpl = strings.Split(data6, "PrimaryService :")
+			}
+		}
 		if len(dataSpl) < 2 {
 			if i < 79 {
 				time.Sleep(250 * time.Millisecond)
@@ -441,7 +462,7 @@ func RestoreScutilDns(force bool) (err error) {
 		return
 	}
 
-	ClearDNSCache()
+	FlushMacDnsCache()
 
 	return
 }
@@ -473,7 +494,7 @@ func RefreshScutilDns() (err error) {
 		return
 	}
 
-	ClearDNSCacheFast()
+	FlushMacDnsCacheFast()
 
 	return
 }
@@ -539,8 +560,8 @@ func GetScutilConnIds() (ids []string, err error) {
 }
 
 func ClearScutilConnKeys() (err error) {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	connIds, err := GetScutilConnIds()
 	if err != nil {
@@ -572,265 +593,38 @@ func ClearScutilConnKeys() (err error) {
 	return
 }
 
-func ResetNetworking() {
-	logrus.Info("utils: Reseting networking")
-
-	networkResetLock.Lock()
-	defer networkResetLock.Unlock()
-
-	switch runtime.GOOS {
-	case "windows":
-		command.Command("netsh", "interface", "ip", "delete",
-			"destinationcache").Run()
-		command.Command("ipconfig", "/release").Run()
-		command.Command("ipconfig", "/renew").Run()
-		command.Command("arp", "-d", "*").Run()
-		command.Command("nbtstat", "-R").Run()
-		command.Command("nbtstat", "-RR").Run()
-		command.Command("ipconfig", "/flushdns").Run()
-		command.Command("nbtstat", "/registerdns").Run()
-		break
-	case "darwin":
-		cmd := command.Command("/usr/sbin/networksetup", "-getcurrentlocation")
-
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			err = &CommandError{
-				errors.Wrap(err, "utils: Failed to get network location"),
-			}
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("utils: Reset networking error")
-			return
-		}
-
-		location := strings.TrimSpace(string(output))
-
-		if location == "pritunl-reset" {
-			return
-		}
-
-		err = command.Command(
-			"/usr/sbin/networksetup",
-			"-createlocation",
-			"pritunl-reset",
-		).Run()
-		if err != nil {
-			err = &CommandError{
-				errors.Wrap(err, "utils: Failed to create network location"),
-			}
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("utils: Reset networking error")
-		}
-
-		command.Command("route", "-n", "flush").Run()
-
-		err = command.Command(
-			"/usr/sbin/networksetup",
-			"-switchtolocation",
-			"pritunl-reset",
-		).Run()
-		if err != nil {
-			err = &CommandError{
-				errors.Wrap(err, "utils: Failed to set network location"),
-			}
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("utils: Reset networking error")
-		}
-
-		command.Command("route", "-n", "flush").Run()
-
-		err = command.Command(
-			"/usr/sbin/networksetup",
-			"-switchtolocation",
-			location,
-		).Run()
-		if err != nil {
-			err = &CommandError{
-				errors.Wrap(err, "utils: Failed to set network location"),
-			}
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("utils: Reset networking error")
-		}
-
-		command.Command("route", "-n", "flush").Run()
-
-		err = command.Command(
-			"/usr/sbin/networksetup",
-			"-deletelocation",
-			"pritunl-reset",
-		).Run()
-		if err != nil {
-			err = &CommandError{
-				errors.Wrap(err, "utils: Failed to delete network location"),
-			}
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("utils: Reset networking error")
-		}
-		break
-	case "linux":
-		output, _ := ExecOutput("/usr/bin/nmcli", "networking")
-		if strings.Contains(output, "enabled") {
-			command.Command("/usr/bin/nmcli", "connection", "reload").Run()
-			command.Command("/usr/bin/nmcli", "networking", "off").Run()
-			command.Command("/usr/bin/nmcli", "networking", "on").Run()
-		}
-		break
-	default:
-		panic("profile: Not implemented")
-	}
-}
-
-func ClearDns() {
-	if runtime.GOOS != "darwin" {
-		return
-	}
-
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
-
-	logrus.Info("utils: Clearing DNS")
-
-	output, err := ExecCombinedOutputLogged(
-		nil,
-		"/usr/sbin/networksetup",
-		"-listallnetworkservices",
-	)
-	if err != nil {
-		return
-	}
-
-	for _, netService := range strings.Split(output, "\n") {
-		if netService == "" || strings.Contains(netService, "asterisk") {
-			continue
-		}
-
-		_, _ = ExecCombinedOutputLogged(
-			nil,
-			"/usr/sbin/networksetup",
-			"-setdnsservers",
-			netService,
-			"Empty",
-		)
-	}
+func FlushMacDnsCache() {
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
 	command.Command("dscacheutil", "-flushcache").Run()
 	command.Command("killall", "-HUP", "mDNSResponder").Run()
-}
-
-func ResetDns() {
-	if runtime.GOOS != "darwin" {
-		return
-	}
-
-	logrus.Info("utils: Reseting DNS")
-
-	networkResetLock.Lock()
-	defer networkResetLock.Unlock()
-
-	_ = RefreshScutilDns()
-}
-
-func ClearDNSCache() {
-	if runtime.GOOS != "darwin" {
-		return
-	}
-
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
-
-	switch runtime.GOOS {
-	case "windows":
-		command.Command("ipconfig", "/flushdns").Run()
-		go func() {
-			defer func() {
-				panc := recover()
-				if panc != nil {
-					logrus.WithFields(logrus.Fields{
-						"stack": string(debug.Stack()),
-						"panic": panc,
-					}).Error("utils: Panic")
-					panic(panc)
-				}
-			}()
-
-			for i := 0; i < 3; i++ {
-				time.Sleep(1 * time.Second)
-				command.Command("ipconfig", "/flushdns").Run()
-			}
-		}()
-		break
-	case "darwin":
-		command.Command("dscacheutil", "-flushcache").Run()
-		command.Command("killall", "-HUP", "mDNSResponder").Run()
-		go func() {
-			defer func() {
-				panc := recover()
-				if panc != nil {
-					logrus.WithFields(logrus.Fields{
-						"stack": string(debug.Stack()),
-						"panic": panc,
-					}).Error("utils: Panic")
-					panic(panc)
-				}
-			}()
-
-			for i := 0; i < 3; i++ {
-				time.Sleep(1 * time.Second)
-				command.Command("dscacheutil", "-flushcache").Run()
-				command.Command("killall", "-HUP", "mDNSResponder").Run()
+	go func() {
+		defer func() {
+			panc := recover()
+			if panc != nil {
+				logrus.WithFields(logrus.Fields{
+					"stack": string(debug.Stack()),
+					"panic": panc,
+				}).Error("utils: Panic")
+				panic(panc)
 			}
 		}()
-		break
-	case "linux":
-		command.Command("systemd-resolve", "--flush-caches").Run()
-		command.Command("resolvectl", "--flush-caches").Run()
-		go func() {
-			defer func() {
-				panc := recover()
-				if panc != nil {
-					logrus.WithFields(logrus.Fields{
-						"stack": string(debug.Stack()),
-						"panic": panc,
-					}).Error("utils: Panic")
-					panic(panc)
-				}
-			}()
 
-			for i := 0; i < 3; i++ {
-				time.Sleep(1 * time.Second)
-				command.Command("systemd-resolve", "--flush-caches").Run()
-			}
-		}()
-		break
-	default:
-		panic("profile: Not implemented")
-	}
+		for i := 0; i < 3; i++ {
+			time.Sleep(1 * time.Second)
+			command.Command("dscacheutil", "-flushcache").Run()
+			command.Command("killall", "-HUP", "mDNSResponder").Run()
+		}
+	}()
 }
 
-func ClearDNSCacheFast() {
-	macDnsLock.Lock()
-	defer macDnsLock.Unlock()
+func FlushMacDnsCacheFast() {
+	MacDnsLock.Lock()
+	defer MacDnsLock.Unlock()
 
-	switch runtime.GOOS {
-	case "windows":
-		command.Command("ipconfig", "/flushdns").Run()
-		break
-	case "darwin":
-		command.Command("dscacheutil", "-flushcache").Run()
-		command.Command("killall", "-HUP", "mDNSResponder").Run()
-		break
-	case "linux":
-		command.Command("systemd-resolve", "--flush-caches").Run()
-		command.Command("resolvectl", "--flush-caches").Run()
-		break
-	default:
-		panic("profile: Not implemented")
-	}
+	command.Command("dscacheutil", "-flushcache").Run()
+	command.Command("killall", "-HUP", "mDNSResponder").Run()
 }
 
 func Uuid() (id string) {
@@ -905,6 +699,13 @@ func GetLogPath() (pth string) {
 		return
 	}
 
+	if constants.LogDir != "" {
+		_ = platform.MkdirReadSecure(constants.LogDir)
+
+		pth = filepath.Join(constants.LogDir, "pritunl-client.log")
+		return
+	}
+
 	switch runtime.GOOS {
 	case "windows":
 		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl")
@@ -934,6 +735,13 @@ func GetLogPath2() (pth string) {
 		return
 	}
 
+	if constants.LogDir != "" {
+		_ = platform.MkdirReadSecure(constants.LogDir)
+
+		pth = filepath.Join(constants.LogDir, "pritunl-client.log.1")
+		return
+	}
+
 	switch runtime.GOOS {
 	case "windows":
 		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl")
@@ -963,8 +771,8 @@ func InitTempDir() (err error) {
 			}
 			return
 		}
-	} else if runtime.GOOS != "windows" {
-		pth := filepath.Join(string(filepath.Separator), "tmp", "pritunl")
+	} else if runtime.GOOS != "windows" || constants.TempDir != "" {
+		pth := GetTempPath()
 
 		_ = os.RemoveAll(pth)
 		err = platform.MkdirSecure(pth)
@@ -986,31 +794,31 @@ func GetTempDir() (pth string, err error) {
 		return
 	}
 
-	if runtime.GOOS == "windows" {
-		pth = filepath.Join(GetWinDrive(), "ProgramData", "Pritunl", "Temp")
-		err = platform.MkdirSecure(pth)
-		if err != nil {
-			err = &IoError{
-				errors.Wrap(
-					err, "utils: Failed to create temp directory"),
-			}
-			return
-		}
-	} else {
-		pth = filepath.Join(string(filepath.Separator), "tmp", "pritunl")
-		err = platform.MkdirSecure(pth)
-		if err != nil {
-			err = &IoError{
-				errors.Wrap(
-					err, "utils: Failed to create temp directory"),
-			}
-			return
+	pth = GetTempPath()
+	err = platform.MkdirSecure(pth)
+	if err != nil {
+		err = &IoError{
+			errors.Wrap(
+				err, "utils: Failed to create temp directory"),
 		}
+		return
 	}
 
 	return
 }
 
+func GetTempPath() string {
+	if constants.TempDir != "" {
+		return filepath.Join(constants.TempDir, "pritunl")
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(GetWinDrive(), "ProgramData", "Pritunl", "Temp")
+	}
+
+	return filepath.Join(string(filepath.Separator), "tmp", "pritunl")
+}
+
 func GetPidPath() (pth string) {
 	if constants.Development {
 		pth = filepath.Join(GetRootDir(), "..", "dev")
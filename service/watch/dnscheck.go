@@ -0,0 +1,43 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const dnsCheckPoll = 30 * time.Second
+
+// Periodically verify DNS queries resolve through the tunnel servers,
+// disabled unless the check interval is configured
+func dnsCheckWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	lastCheck := time.Now()
+
+	for {
+		time.Sleep(dnsCheckPoll)
+
+		interval := profile.GetDnsCheckInterval()
+		if interval == 0 || !profile.GetStatus() ||
+			utils.SinceAbs(lastCheck) < interval {
+
+			continue
+		}
+		lastCheck = time.Now()
+
+		profile.CheckDns("")
+	}
+}
@@ -0,0 +1,36 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/sirupsen/logrus"
+)
+
+const dnsFallbackPoll = 30 * time.Second
+
+// Periodically check the tunnel DNS servers respond, disabled unless
+// fallback resolvers are configured
+func dnsFallbackWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(dnsFallbackPoll)
+
+		if !profile.GetStatus() {
+			continue
+		}
+
+		profile.CheckDnsFallback()
+	}
+}
@@ -0,0 +1,32 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/sirupsen/logrus"
+)
+
+const expiryPoll = 1 * time.Minute
+
+// Periodically disconnect profiles past the expiry and warn before the
+// expiry is reached
+func expiryWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		profile.CheckExpiry()
+
+		time.Sleep(expiryPoll)
+	}
+}
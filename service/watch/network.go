@@ -0,0 +1,136 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/message"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/netmon"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	networkSettle   = 3 * time.Second
+	networkMinDelay = 30 * time.Second
+)
+
+type NetworkChange struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+func (n *NetworkChange) MessageParams() message.Params {
+	return message.Params{
+		"previous": n.Previous,
+		"current":  n.Current,
+	}
+}
+
+// Connecting changes the routing table, changes are only acted on once
+// every profile has been connected for the settle period
+func networkSettled() bool {
+	prfls := profile.GetProfiles()
+	if len(prfls) == 0 {
+		return false
+	}
+
+	for _, prfl := range prfls {
+		if prfl.Status != "connected" || prfl.Timestamp == 0 ||
+			utils.SinceAbs(time.Unix(prfl.Timestamp, 0)) < triggerSettle {
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reconnect when the default route moves to another interface or gateway,
+// the tunnel sockets remain bound to the previous network until the
+// keepalive times out
+func networkWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	changes := netmon.Start()
+	gateway, _ := getDefaultGateway()
+
+	for {
+		<-changes
+
+		if config.Config.DisableNetworkWatch {
+			continue
+		}
+
+		// Switching networks produces a burst of changes
+		time.Sleep(networkSettle)
+		select {
+		case <-changes:
+		default:
+		}
+
+		current, err := getDefaultGateway()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get default gateway")
+			continue
+		}
+
+		if current == gateway {
+			continue
+		}
+
+		change := &NetworkChange{
+			Previous: gateway,
+			Current:  current,
+		}
+		gateway = current
+
+		if current == "" {
+			logrus.WithFields(logrus.Fields{
+				"previous": change.Previous,
+			}).Info("watch: Default route removed")
+			continue
+		}
+
+		if !networkSettled() {
+			continue
+		}
+
+		restartLock.Lock()
+		if utils.SinceAbs(lastRestart) < networkMinDelay {
+			restartLock.Unlock()
+			continue
+		}
+		lastRestart = time.Now()
+		restartLock.Unlock()
+
+		logrus.WithFields(logrus.Fields{
+			"previous": change.Previous,
+			"current":  change.Current,
+		}).Warn("watch: Default route changed, reconnecting")
+
+		evt := &event.Event{
+			Type: "network_changed",
+			Data: change,
+		}
+		evt.Init()
+
+		netconf.Get().FlushDnsCache()
+		_ = profile.RestartProfiles(false)
+	}
+}
@@ -0,0 +1,324 @@
+package watch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const onDemandIdleDefault = 600
+
+var (
+	onDemandStates = map[string]*onDemandState{}
+	onDemandLock   = sync.Mutex{}
+)
+
+type onDemandState struct {
+	Id         string
+	Iface      string
+	Subnets    []string
+	Armed      bool
+	Triggered  bool
+	TxPackets  int64
+	RxBytes    int64
+	TxBytes    int64
+	LastActive time.Time
+}
+
+func (s *onDemandState) arm() (err error) {
+	_ = s.disarm()
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "add", s.Iface, "type", "dummy",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set", s.Iface, "up",
+	)
+	if err != nil {
+		_ = s.disarm()
+		return
+	}
+
+	for _, subnet := range s.Subnets {
+		_, network, e := net.ParseCIDR(subnet)
+		if e != nil {
+			continue
+		}
+
+		err = netconf.Get().PinRoute(network, s.Iface)
+		if err != nil {
+			_ = s.disarm()
+			return
+		}
+	}
+
+	s.TxPackets, err = s.getTxPackets()
+	if err != nil {
+		_ = s.disarm()
+		return
+	}
+
+	s.Armed = true
+
+	return
+}
+
+func (s *onDemandState) disarm() (err error) {
+	s.Armed = false
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"Cannot find device",
+		},
+		"ip", "link", "del", s.Iface,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *onDemandState) getTxPackets() (packets int64, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(
+		"/sys/class/net", s.Iface, "statistics", "tx_packets"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "watch: Failed to read interface statistics"),
+		}
+		return
+	}
+
+	packets, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "watch: Failed to parse interface statistics"),
+		}
+		return
+	}
+
+	return
+}
+
+func (s *onDemandState) checkIdle(sPrfl *sprofile.Sprofile) {
+	prfl := profile.GetProfile(s.Id)
+	if prfl == nil || prfl.Status != "connected" {
+		s.LastActive = time.Now()
+		return
+	}
+
+	rx, tx := prfl.GetTransfer()
+	if rx != s.RxBytes || tx != s.TxBytes {
+		s.RxBytes = rx
+		s.TxBytes = tx
+		s.LastActive = time.Now()
+		return
+	}
+
+	idle := sPrfl.OnDemandIdle
+	if idle <= 0 {
+		idle = onDemandIdleDefault
+	}
+
+	if utils.SinceAbs(s.LastActive) < time.Duration(idle)*time.Second {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": s.Id,
+		"idle":       idle,
+	}).Info("watch: On-demand profile idle, disconnecting")
+
+	s.Triggered = false
+	sprofile.Deactivate(s.Id)
+}
+
+func (s *onDemandState) checkTrigger(sPrfl *sprofile.Sprofile) {
+	packets, err := s.getTxPackets()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": s.Id,
+			"error":      err,
+		}).Error("watch: Failed to check on-demand interface")
+		_ = s.disarm()
+		return
+	}
+
+	if packets <= s.TxPackets {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"profile_id": s.Id,
+		"subnets":    s.Subnets,
+	}).Info("watch: On-demand traffic detected, connecting")
+
+	err = s.disarm()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": s.Id,
+			"error":      err,
+		}).Error("watch: Failed to remove on-demand interface")
+	}
+
+	err = sprofile.Activate(s.Id, sPrfl.LastMode, sPrfl.Password)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"profile_id": s.Id,
+			"error":      err,
+		}).Error("watch: Failed to activate on-demand profile")
+		return
+	}
+
+	s.Triggered = true
+	s.RxBytes = 0
+	s.TxBytes = 0
+	s.LastActive = time.Now()
+}
+
+func onDemandSync() (err error) {
+	onDemandLock.Lock()
+	defer onDemandLock.Unlock()
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	seen := map[string]bool{}
+
+	for _, sPrfl := range sprfls {
+		if len(sPrfl.OnDemandSubnets) == 0 {
+			continue
+		}
+		seen[sPrfl.Id] = true
+
+		state := onDemandStates[sPrfl.Id]
+		if state == nil {
+			ifaceId := sPrfl.Id
+			if len(ifaceId) > 8 {
+				ifaceId = ifaceId[:8]
+			}
+
+			state = &onDemandState{
+				Id:    sPrfl.Id,
+				Iface: fmt.Sprintf("pritod%s", ifaceId),
+			}
+			onDemandStates[sPrfl.Id] = state
+		}
+
+		if sPrfl.State {
+			if state.Armed {
+				_ = state.disarm()
+			}
+
+			if state.Triggered {
+				state.checkIdle(sPrfl)
+			}
+			continue
+		}
+		state.Triggered = false
+
+		if state.Armed && !reflect.DeepEqual(
+			state.Subnets, sPrfl.OnDemandSubnets) {
+
+			_ = state.disarm()
+		}
+
+		if !state.Armed {
+			if profile.GetProfile(sPrfl.Id) != nil {
+				continue
+			}
+
+			state.Subnets = sPrfl.OnDemandSubnets
+
+			err = state.arm()
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"profile_id": sPrfl.Id,
+					"subnets":    sPrfl.OnDemandSubnets,
+					"error":      err,
+				}).Error("watch: Failed to arm on-demand profile")
+				err = nil
+			}
+			continue
+		}
+
+		state.checkTrigger(sPrfl)
+	}
+
+	for prflId, state := range onDemandStates {
+		if !seen[prflId] {
+			_ = state.disarm()
+			delete(onDemandStates, prflId)
+		}
+	}
+
+	return
+}
+
+func onDemandWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	for {
+		time.Sleep(2 * time.Second)
+
+		err := onDemandSync()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("watch: Failed to sync on-demand profiles")
+		}
+	}
+}
+
+func StopOnDemand() {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	onDemandLock.Lock()
+	defer onDemandLock.Unlock()
+
+	for prflId, state := range onDemandStates {
+		if state.Armed {
+			_ = state.disarm()
+		}
+		delete(onDemandStates, prflId)
+	}
+}
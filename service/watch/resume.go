@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	resumeInterval  = 5 * time.Second
+	resumeThreshold = 5 * time.Second
+	resumeSettle    = 5 * time.Second
+)
+
+// Detect resume from hibernation and Windows fast startup using the time
+// the system spent suspended, the wall clock is not reliable across a
+// hibernation
+func resumeWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	lastSuspend, err := platform.GetSuspendTime()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("watch: Failed to get suspend time, resume watch disabled")
+		return
+	}
+
+	for {
+		time.Sleep(resumeInterval)
+
+		if config.Config.DisableWakeWatch {
+			watchLock.Lock()
+			resumeRunning = false
+			watchLock.Unlock()
+			return
+		}
+
+		suspend, err := platform.GetSuspendTime()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get suspend time")
+			continue
+		}
+
+		suspended := suspend - lastSuspend
+		lastSuspend = suspend
+
+		if suspended < resumeThreshold {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"suspended": suspended.String(),
+		}).Info("watch: System resumed from hibernation")
+
+		if !profile.GetActive() {
+			continue
+		}
+
+		time.Sleep(resumeSettle)
+
+		restartLock.Lock()
+		if utils.SinceAbs(lastRestart) < 60*time.Second {
+			restartLock.Unlock()
+			continue
+		}
+		lastRestart = time.Now()
+		restartLock.Unlock()
+
+		profile.ResyncProfiles()
+	}
+}
@@ -0,0 +1,592 @@
+package watch
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/config"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/proxy"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RuleInterfaceUp        = "interface_up"
+	RuleInterfaceDown      = "interface_down"
+	RuleSsidChange         = "ssid_change"
+	RuleGatewayUnreachable = "gateway_unreachable"
+
+	RuleActionWebhook    = "webhook"
+	RuleActionHook       = "hook"
+	RuleActionConnect    = "connect"
+	RuleActionDisconnect = "disconnect"
+	RuleActionRestart    = "restart"
+
+	ruleInterval        = 5 * time.Second
+	ruleMinDelay        = 30 * time.Second
+	ruleDurationDefault = 30
+	ruleTimeout         = 60 * time.Second
+)
+
+var (
+	ruleStates = map[string]*ruleState{}
+	rulesLock  = sync.Mutex{}
+	ruleClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:               proxy.Func,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				MaxVersion: tls.VersionTLS13,
+			},
+		},
+		Timeout: 10 * time.Second,
+	}
+)
+
+type ruleState struct {
+	Timestamp   int64
+	Present     bool
+	Ssid        string
+	Unreachable time.Time
+	Fired       bool
+	LastFired   time.Time
+}
+
+// Data sent to webhooks and passed to hook scripts when a rule fires
+type RuleEvent struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	Action    string `json:"action"`
+	Interface string `json:"interface,omitempty"`
+	Ssid      string `json:"ssid,omitempty"`
+	ProfileId string `json:"profile_id,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// System state is only collected when a rule requires it and at most once
+// per check
+type ruleSystem struct {
+	ifaces  map[string]bool
+	ssid    *string
+	ssidErr bool
+}
+
+func (s *ruleSystem) Interface(name string) (up, ok bool) {
+	if s.ifaces == nil {
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get network interfaces")
+			return
+		}
+
+		s.ifaces = map[string]bool{}
+		for _, iface := range ifaces {
+			s.ifaces[iface.Name] = iface.Flags&net.FlagUp != 0
+		}
+	}
+
+	ok = true
+	up = s.ifaces[name]
+	return
+}
+
+func (s *ruleSystem) Ssid() (ssid string, ok bool) {
+	if s.ssid == nil && !s.ssidErr {
+		ssid, err := getSsid()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get wireless network")
+			s.ssidErr = true
+		} else {
+			s.ssid = &ssid
+		}
+	}
+	if s.ssidErr {
+		return
+	}
+
+	ok = true
+	ssid = *s.ssid
+	return
+}
+
+func ValidateRule(rule *config.WatchRule) (err error) {
+	rule.Name = utils.FilterStr(rule.Name)
+	rule.Interface = strings.TrimSpace(rule.Interface)
+	rule.Ssid = strings.TrimSpace(rule.Ssid)
+	rule.ProfileId = utils.FilterStr(rule.ProfileId)
+	rule.Target = strings.TrimSpace(rule.Target)
+
+	switch rule.Condition {
+	case RuleInterfaceUp, RuleInterfaceDown:
+		if rule.Interface == "" {
+			err = &errortypes.ParseError{
+				errors.New("watch: Rule interface required"),
+			}
+			return
+		}
+		break
+	case RuleSsidChange:
+		break
+	case RuleGatewayUnreachable:
+		if rule.ProfileId == "" {
+			err = &errortypes.ParseError{
+				errors.New("watch: Rule profile required"),
+			}
+			return
+		}
+		if rule.Duration <= 0 {
+			rule.Duration = ruleDurationDefault
+		}
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("watch: Invalid rule condition '%s'", rule.Condition),
+		}
+		return
+	}
+
+	switch rule.Action {
+	case RuleActionWebhook:
+		u, e := url.Parse(rule.Target)
+		if e != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+
+			err = &errortypes.ParseError{
+				errors.New("watch: Rule webhook url invalid"),
+			}
+			return
+		}
+		break
+	case RuleActionHook:
+		if !filepath.IsAbs(rule.Target) {
+			err = &errortypes.ParseError{
+				errors.New("watch: Rule hook path must be absolute"),
+			}
+			return
+		}
+		rule.Target = filepath.Clean(rule.Target)
+		break
+	case RuleActionConnect, RuleActionDisconnect, RuleActionRestart:
+		if rule.ProfileId == "" {
+			err = &errortypes.ParseError{
+				errors.New("watch: Rule profile required"),
+			}
+			return
+		}
+		rule.Target = ""
+		break
+	default:
+		err = &errortypes.ParseError{
+			errors.Newf("watch: Invalid rule action '%s'", rule.Action),
+		}
+		return
+	}
+
+	return
+}
+
+func GetRules() (rules []*config.WatchRule) {
+	rules = []*config.WatchRule{}
+
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	for _, rule := range config.Config.WatchRules {
+		ruleCopy := *rule
+		rules = append(rules, &ruleCopy)
+	}
+
+	return
+}
+
+func CreateRule(rule *config.WatchRule) (err error) {
+	err = ValidateRule(rule)
+	if err != nil {
+		return
+	}
+
+	ruleId, err := utils.RandStr(16)
+	if err != nil {
+		return
+	}
+
+	rule.Id = strings.ToLower(ruleId)
+	rule.Timestamp = time.Now().Unix()
+
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	prevRules := config.Config.WatchRules
+	config.Config.WatchRules = append(append(
+		[]*config.WatchRule{}, prevRules...), rule)
+
+	err = config.Save()
+	if err != nil {
+		config.Config.WatchRules = prevRules
+		return
+	}
+
+	return
+}
+
+func DeleteRule(ruleId string) (found bool, err error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	prevRules := config.Config.WatchRules
+	rules := []*config.WatchRule{}
+
+	for _, rule := range prevRules {
+		if rule.Id == ruleId {
+			found = true
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	if !found {
+		return
+	}
+
+	config.Config.WatchRules = rules
+
+	err = config.Save()
+	if err != nil {
+		config.Config.WatchRules = prevRules
+		return
+	}
+
+	delete(ruleStates, ruleId)
+
+	return
+}
+
+func ruleWebhook(rule *config.WatchRule, evt *RuleEvent) (err error) {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "watch: Failed to marshal rule event"),
+		}
+		return
+	}
+
+	req, err := http.NewRequest("POST", rule.Target, bytes.NewReader(data))
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "watch: Failed to create webhook request"),
+		}
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pritunl-client")
+
+	resp, err := ruleClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "watch: Webhook request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = &errortypes.RequestError{
+			errors.Newf("watch: Webhook request bad status %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	return
+}
+
+func ruleHook(rule *config.WatchRule, evt *RuleEvent) (err error) {
+	err = platform.CheckSecureFile(rule.Target)
+	if err != nil {
+		return
+	}
+
+	cmd := profile.HookCommand(rule.Target)
+	cmd.Env = append(os.Environ(),
+		"PRITUNL_HOOK=watch",
+		"PRITUNL_RULE_ID="+evt.Id,
+		"PRITUNL_RULE_NAME="+evt.Name,
+		"PRITUNL_RULE_CONDITION="+evt.Condition,
+		"PRITUNL_RULE_INTERFACE="+evt.Interface,
+		"PRITUNL_RULE_SSID="+evt.Ssid,
+		"PRITUNL_PROFILE_ID="+evt.ProfileId,
+	)
+
+	err = cmd.Start()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Failed to start rule hook"),
+		}
+		return
+	}
+
+	timer := time.AfterFunc(ruleTimeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "watch: Rule hook failed"),
+		}
+		return
+	}
+
+	return
+}
+
+func ruleProfile(rule *config.WatchRule) (err error) {
+	switch rule.Action {
+	case RuleActionConnect:
+		sPrfl := sprofile.Get(rule.ProfileId)
+		if sPrfl == nil {
+			err = &errortypes.NotFoundError{
+				errors.New("watch: Rule profile not found"),
+			}
+			return
+		}
+
+		if profile.GetProfile(rule.ProfileId) != nil {
+			return
+		}
+
+		err = sprofile.Activate(sPrfl.Id, sPrfl.LastMode, sPrfl.Password)
+		if err != nil {
+			return
+		}
+		break
+	case RuleActionDisconnect:
+		sprofile.Deactivate(rule.ProfileId)
+
+		prfl := profile.GetProfile(rule.ProfileId)
+		if prfl != nil {
+			prfl.Stop()
+		}
+		break
+	case RuleActionRestart:
+		prfl := profile.GetProfile(rule.ProfileId)
+		if prfl != nil {
+			prfl.Restart()
+		}
+		break
+	}
+
+	return
+}
+
+func runRule(rule *config.WatchRule, evt *RuleEvent) {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"rule_id":    rule.Id,
+		"name":       rule.Name,
+		"condition":  rule.Condition,
+		"action":     rule.Action,
+		"profile_id": rule.ProfileId,
+	}).Info("watch: Rule matched, running action")
+
+	notify := &event.Event{
+		Type: "watch_rule",
+		Data: evt,
+	}
+	notify.Init()
+
+	var err error
+	switch rule.Action {
+	case RuleActionWebhook:
+		err = ruleWebhook(rule, evt)
+		break
+	case RuleActionHook:
+		err = ruleHook(rule, evt)
+		break
+	default:
+		err = ruleProfile(rule)
+		break
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"rule_id": rule.Id,
+			"action":  rule.Action,
+			"error":   err,
+		}).Error("watch: Rule action failed")
+	}
+}
+
+// Conditions are edge triggered, the first check of a rule captures the
+// baseline without firing
+func (s *ruleState) check(rule *config.WatchRule, system *ruleSystem,
+	baseline bool) (evt *RuleEvent) {
+
+	fire := false
+	evt = &RuleEvent{
+		Id:        rule.Id,
+		Name:      rule.Name,
+		Condition: rule.Condition,
+		Action:    rule.Action,
+		ProfileId: rule.ProfileId,
+		Timestamp: time.Now().Unix(),
+	}
+
+	switch rule.Condition {
+	case RuleInterfaceUp, RuleInterfaceDown:
+		up, ok := system.Interface(rule.Interface)
+		if !ok {
+			break
+		}
+		evt.Interface = rule.Interface
+
+		if !baseline && up != s.Present {
+			fire = up == (rule.Condition == RuleInterfaceUp)
+		}
+		s.Present = up
+		break
+	case RuleSsidChange:
+		ssid, ok := system.Ssid()
+		if !ok {
+			break
+		}
+		evt.Ssid = ssid
+
+		if !baseline && ssid != s.Ssid {
+			fire = rule.Ssid == "" || rule.Ssid == ssid
+		}
+		s.Ssid = ssid
+		break
+	case RuleGatewayUnreachable:
+		prfl := profile.GetProfile(rule.ProfileId)
+		if prfl == nil || prfl.Status != "connected" ||
+			prfl.Timestamp == 0 || utils.SinceAbs(
+			time.Unix(prfl.Timestamp, 0)) < triggerSettle {
+
+			s.Unreachable = time.Time{}
+			s.Fired = false
+			break
+		}
+
+		_, err := prfl.ProbeServer()
+		if err == nil {
+			s.Unreachable = time.Time{}
+			s.Fired = false
+			break
+		}
+
+		if s.Unreachable.IsZero() {
+			s.Unreachable = time.Now()
+		}
+
+		if !s.Fired && utils.SinceAbs(s.Unreachable) >= time.Duration(
+			rule.Duration)*time.Second {
+
+			s.Fired = true
+			fire = true
+		}
+		break
+	}
+
+	if !fire {
+		evt = nil
+	}
+
+	return
+}
+
+func rulesSync() {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+
+	system := &ruleSystem{}
+	seen := map[string]bool{}
+
+	for _, rule := range config.Config.WatchRules {
+		seen[rule.Id] = true
+
+		state := ruleStates[rule.Id]
+		if state == nil || state.Timestamp != rule.Timestamp {
+			state = &ruleState{
+				Timestamp: rule.Timestamp,
+			}
+			ruleStates[rule.Id] = state
+
+			state.check(rule, system, true)
+			continue
+		}
+
+		evt := state.check(rule, system, false)
+		if evt == nil {
+			continue
+		}
+
+		if utils.SinceAbs(state.LastFired) < ruleMinDelay {
+			logrus.WithFields(logrus.Fields{
+				"rule_id":   rule.Id,
+				"condition": rule.Condition,
+			}).Info("watch: Rule ignored, recently fired")
+			continue
+		}
+		state.LastFired = time.Now()
+
+		go runRule(rule, evt)
+	}
+
+	for ruleId := range ruleStates {
+		if !seen[ruleId] {
+			delete(ruleStates, ruleId)
+		}
+	}
+}
+
+func ruleWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(ruleInterval)
+
+		rulesSync()
+	}
+}
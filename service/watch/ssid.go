@@ -0,0 +1,16 @@
+package watch
+
+import (
+	"github.com/pritunl/pritunl-client-electron/service/platform"
+)
+
+// Wireless network of the system, empty when not connected to a wireless
+// network
+func getSsid() (ssid string, err error) {
+	ssid, err = platform.GetSsid()
+	if err != nil {
+		return
+	}
+
+	return
+}
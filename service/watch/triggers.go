@@ -0,0 +1,394 @@
+package watch
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-client-electron/service/errortypes"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/profile"
+	"github.com/pritunl/pritunl-client-electron/service/sprofile"
+	"github.com/pritunl/pritunl-client-electron/service/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	triggerInterval = 5 * time.Second
+	triggerSettle   = 15 * time.Second
+	triggerMinDelay = 60 * time.Second
+)
+
+var (
+	triggerStates = map[string]*triggerState{}
+	triggerLock   = sync.Mutex{}
+)
+
+type triggerState struct {
+	Timestamp   int64
+	Gateway     string
+	Dns         string
+	Processes   map[string]bool
+	LastRestart time.Time
+}
+
+// System state is only collected when a trigger requires it and at most
+// once per check.
+type triggerSystem struct {
+	gateway    *string
+	dns        *string
+	processes  map[string]bool
+	processErr bool
+}
+
+func (s *triggerSystem) Gateway() string {
+	if s.gateway == nil {
+		gateway, err := getDefaultGateway()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get default gateway")
+		}
+		s.gateway = &gateway
+	}
+	return *s.gateway
+}
+
+func (s *triggerSystem) Dns() string {
+	if s.dns == nil {
+		servers, err := getDnsServers()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get DNS servers")
+		}
+		sort.Strings(servers)
+		dns := strings.Join(servers, ",")
+		s.dns = &dns
+	}
+	return *s.dns
+}
+
+func (s *triggerSystem) Process(name string) (running, ok bool) {
+	if s.processes == nil && !s.processErr {
+		processes, err := getProcessNames()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Warn("watch: Failed to get process list")
+			s.processErr = true
+		} else {
+			s.processes = processes
+		}
+	}
+	if s.processErr {
+		return
+	}
+
+	ok = true
+	running = s.processes[name] || s.processes[name+".exe"]
+	return
+}
+
+// Gateway with the interface name, a missing default route is reported as
+// an empty gateway
+func getDefaultGateway() (gateway string, err error) {
+	gw, iface, err := netconf.Get().GetDefaultGateway(false)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	gateway = iface + " " + gw.String()
+
+	return
+}
+
+func getDnsServers() (servers []string, err error) {
+	servers = []string{}
+
+	switch runtime.GOOS {
+	case "linux":
+		// Read the upstream servers when resolved is using the stub resolver
+		data, e := ioutil.ReadFile("/run/systemd/resolve/resolv.conf")
+		if e != nil {
+			data, e = ioutil.ReadFile("/etc/resolv.conf")
+			if e != nil {
+				err = &errortypes.ReadError{
+					errors.Wrap(e, "watch: Failed to read resolv.conf"),
+				}
+				return
+			}
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, fields[1])
+			}
+		}
+		break
+	case "darwin":
+		global, e := utils.GetScutilKey("State", "/Network/Global/DNS")
+		if e != nil {
+			err = e
+			return
+		}
+
+		_, servers = parseDns(global)
+		break
+	case "windows":
+		output, e := utils.ExecOutput(
+			"powershell.exe",
+			"-NoProfile",
+			"-Command",
+			"Get-DnsClientServerAddress -AddressFamily IPv4 | "+
+				"Select-Object -ExpandProperty ServerAddresses",
+		)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				servers = append(servers, line)
+			}
+		}
+		break
+	default:
+		panic("watch: Not implemented")
+	}
+
+	return
+}
+
+func getProcessNames() (names map[string]bool, err error) {
+	names = map[string]bool{}
+
+	switch runtime.GOOS {
+	case "linux":
+		pths, e := filepath.Glob("/proc/[0-9]*/comm")
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "watch: Failed to read process list"),
+			}
+			return
+		}
+
+		for _, pth := range pths {
+			data, e := ioutil.ReadFile(pth)
+			if e != nil {
+				continue
+			}
+
+			names[strings.ToLower(strings.TrimSpace(string(data)))] = true
+		}
+		break
+	case "darwin":
+		output, e := utils.ExecOutput("/bin/ps", "-axco", "comm=")
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			line = strings.ToLower(strings.TrimSpace(line))
+			if line != "" {
+				names[line] = true
+			}
+		}
+		break
+	case "windows":
+		output, e := utils.ExecOutput("tasklist", "/fo", "csv", "/nh")
+		if e != nil {
+			err = e
+			return
+		}
+
+		records, e := csv.NewReader(strings.NewReader(output)).ReadAll()
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "watch: Failed to parse tasklist"),
+			}
+			return
+		}
+
+		for _, record := range records {
+			if len(record) > 0 {
+				names[strings.ToLower(record[0])] = true
+			}
+		}
+		break
+	default:
+		panic("watch: Not implemented")
+	}
+
+	return
+}
+
+func (s *triggerState) check(sPrfl *sprofile.Sprofile,
+	system *triggerSystem, baseline bool) (trigger string) {
+
+	for _, trgr := range sPrfl.GetRestartTriggers() {
+		switch {
+		case trgr == sprofile.TriggerGatewayChange:
+			gateway := system.Gateway()
+			if gateway == "" {
+				break
+			}
+
+			if !baseline && s.Gateway != "" && s.Gateway != gateway &&
+				trigger == "" {
+
+				trigger = trgr
+			}
+			s.Gateway = gateway
+			break
+		case trgr == sprofile.TriggerDnsChange:
+			dns := system.Dns()
+			if dns == "" {
+				break
+			}
+
+			if !baseline && s.Dns != "" && s.Dns != dns && trigger == "" {
+				trigger = trgr
+			}
+			s.Dns = dns
+			break
+		case strings.HasPrefix(trgr, sprofile.TriggerProcess):
+			name := trgr[len(sprofile.TriggerProcess):]
+
+			running, ok := system.Process(name)
+			if !ok {
+				break
+			}
+
+			if !baseline && running && !s.Processes[name] &&
+				trigger == "" {
+
+				trigger = trgr
+			}
+			s.Processes[name] = running
+			break
+		}
+	}
+
+	return
+}
+
+func triggerSync() (err error) {
+	triggerLock.Lock()
+	defer triggerLock.Unlock()
+
+	sprfls, err := sprofile.GetAll()
+	if err != nil {
+		return
+	}
+
+	system := &triggerSystem{}
+	seen := map[string]bool{}
+
+	for _, sPrfl := range sprfls {
+		if len(sPrfl.GetRestartTriggers()) == 0 {
+			continue
+		}
+
+		prfl := profile.GetProfile(sPrfl.Id)
+		if prfl == nil || prfl.Status != "connected" ||
+			prfl.Timestamp == 0 {
+
+			continue
+		}
+
+		// Wait for the connection to settle before capturing the baseline,
+		// connecting itself changes DNS and routing
+		if utils.SinceAbs(time.Unix(prfl.Timestamp, 0)) < triggerSettle {
+			continue
+		}
+		seen[sPrfl.Id] = true
+
+		state := triggerStates[sPrfl.Id]
+		if state == nil || state.Timestamp != prfl.Timestamp {
+			lastRestart := time.Time{}
+			if state != nil {
+				lastRestart = state.LastRestart
+			}
+
+			state = &triggerState{
+				Timestamp:   prfl.Timestamp,
+				Processes:   map[string]bool{},
+				LastRestart: lastRestart,
+			}
+			triggerStates[sPrfl.Id] = state
+
+			state.check(sPrfl, system, true)
+			continue
+		}
+
+		trigger := state.check(sPrfl, system, false)
+		if trigger == "" {
+			continue
+		}
+
+		if utils.SinceAbs(state.LastRestart) < triggerMinDelay {
+			logrus.WithFields(logrus.Fields{
+				"profile_id": sPrfl.Id,
+				"trigger":    trigger,
+			}).Info("watch: Restart trigger ignored, recently restarted")
+			continue
+		}
+		state.LastRestart = time.Now()
+
+		logrus.WithFields(logrus.Fields{
+			"profile_id": sPrfl.Id,
+			"trigger":    trigger,
+		}).Warn("watch: Restart trigger matched, restarting profile")
+
+		go prfl.Restart()
+	}
+
+	for prflId, state := range triggerStates {
+		if !seen[prflId] && utils.SinceAbs(
+			state.LastRestart) >= triggerMinDelay {
+
+			delete(triggerStates, prflId)
+		}
+	}
+
+	return
+}
+
+func triggerWatch() {
+	defer func() {
+		panc := recover()
+		if panc != nil {
+			logrus.WithFields(logrus.Fields{
+				"stack": string(debug.Stack()),
+				"panic": panc,
+			}).Error("watch: Panic")
+			panic(panc)
+		}
+	}()
+
+	for {
+		time.Sleep(triggerInterval)
+
+		err := triggerSync()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("watch: Failed to check restart triggers")
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/pritunl/pritunl-client-electron/service/config"
 	"github.com/pritunl/pritunl-client-electron/service/event"
+	"github.com/pritunl/pritunl-client-electron/service/netconf"
+	"github.com/pritunl/pritunl-client-electron/service/platform"
 	"github.com/pritunl/pritunl-client-electron/service/profile"
 	"github.com/pritunl/pritunl-client-electron/service/utils"
 	"github.com/sirupsen/logrus"
@@ -20,6 +22,10 @@ var (
 	lastRestart    = time.Now()
 	lastDnsRefresh = time.Now()
 	restartLock    = sync.Mutex{}
+	watchLock      = sync.Mutex{}
+	wakeRunning    = false
+	resumeRunning  = false
+	dnsRunning     = false
 )
 
 type ConnState struct {
@@ -89,6 +95,13 @@ func wakeWatch() {
 	update := false
 
 	for {
+		if config.Config.DisableWakeWatch {
+			watchLock.Lock()
+			wakeRunning = false
+			watchLock.Unlock()
+			return
+		}
+
 		if !profile.GetActive() {
 			if update {
 				status := profile.GetStatus()
@@ -153,10 +166,17 @@ func dnsWatch() {
 	for {
 		time.Sleep(2 * time.Second)
 
+		if config.Config.DisableDnsWatch {
+			watchLock.Lock()
+			dnsRunning = false
+			watchLock.Unlock()
+			return
+		}
+
 		if !profile.GetStatus() {
 			if check > 0 {
 				if profile.DnsForced {
-					utils.ClearDns()
+					netconf.Get().ClearDns()
 				}
 
 				err := utils.RestoreScutilDns(false)
@@ -169,7 +189,7 @@ func dnsWatch() {
 						}).Error("watch: Failed to restore DNS, " +
 							"resetting network")
 
-						utils.ResetNetworking()
+						netconf.Get().ResetNetworking()
 						check = 0
 						errorCount = 0
 
@@ -202,7 +222,7 @@ func dnsWatch() {
 
 		connIds, err := utils.GetScutilConnIds()
 		if err != nil {
-			utils.ClearDNSCacheFast()
+			netconf.Get().FlushDnsCacheFast()
 			logrus.WithFields(logrus.Fields{
 				"error": err,
 			}).Error("watch: Failed to get DNS connection IDs")
@@ -295,7 +315,7 @@ func dnsWatch() {
 				logrus.WithFields(logrus.Fields{
 					"error": err,
 				}).Error("watch: Failed to refresh DNS settings")
-				utils.ClearDNSCacheFast()
+				netconf.Get().FlushDnsCacheFast()
 			}
 
 			continue
@@ -303,15 +323,55 @@ func dnsWatch() {
 	}
 }
 
-func StartWatch() {
+func startWakeWatch() {
+	watchLock.Lock()
+	defer watchLock.Unlock()
+
 	if config.Config.DisableWakeWatch {
 		logrus.Info("watch: Wake watch disabled")
-	} else {
+		return
+	}
+
+	if !wakeRunning {
+		wakeRunning = true
 		go wakeWatch()
 	}
+	if platform.SuspendSupported && !resumeRunning {
+		resumeRunning = true
+		go resumeWatch()
+	}
+}
+
+func startDnsWatch() {
+	watchLock.Lock()
+	defer watchLock.Unlock()
+
 	if config.Config.DisableDnsWatch {
 		logrus.Info("watch: DNS watch disabled")
-	} else {
+		return
+	}
+
+	if !dnsRunning {
+		dnsRunning = true
 		go dnsWatch()
 	}
 }
+
+func StartWatch() {
+	startWakeWatch()
+	startDnsWatch()
+	go onDemandWatch()
+	go triggerWatch()
+	go networkWatch()
+	go dnsCheckWatch()
+	go dnsFallbackWatch()
+	go expiryWatch()
+	go ruleWatch()
+}
+
+// Start watches enabled since the last reload, disabled watches stop on
+// the next check
+func Reload() {
+	startWakeWatch()
+	startDnsWatch()
+}
@@ -11,7 +11,7 @@ export APP_VER="$(cat client/package.json | grep version | cut -d '"' -f 4)"
 # Service
 cd service
 go get
-go build -v
+GOARCH=arm64 go build -v
 cd ..
 mkdir -p build/resources
 cp service/service build/resources/pritunl-service
@@ -28,7 +28,7 @@ cd ..
 # CLI
 cd cli
 go get
-go build -v
+GOARCH=arm64 go build -v
 cd ..
 mkdir -p build/resources
 cp cli/cli build/resources/pritunl-client